@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/config"
+	"flight_trmnl/internal/decoder"
+	"flight_trmnl/internal/tracker"
+	"flight_trmnl/pkg/schema"
+)
+
+// sparkBlocks draw a sparkline, lowest to highest
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkHistory is how many samples a sector's sparkline shows
+const sparkHistory = 30
+
+// runAntenna helps point the antenna: it polls the running daemon's aircraft and prints the message rate and
+// signal from each direction, with their change from a baseline, until interrupted. Enter makes the current
+// readings the baseline, so the effect of each adjustment shows.
+// Usage: antenna [-api http://localhost:8080] [-interval 2s] [-sectors 8]
+func runAntenna(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("antenna", flag.ContinueOnError)
+	apiURL := fs.String("api", localAPIURL(cfg.API.Addr), "the running daemon's API")
+	interval := fs.Duration("interval", 2*time.Second, "how often to sample")
+	count := fs.Int("sectors", 8, "directions to split the sky into, 4 to 36")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	apiSet := false
+	fs.Visit(func(f *flag.Flag) { apiSet = apiSet || f.Name == "api" })
+	if !cfg.API.Enabled && !apiSet {
+		return fmt.Errorf("the daemon's API must be enabled (api.enabled) to watch reception")
+	}
+	if !cfg.Location.IsSet() {
+		return fmt.Errorf("the receiver's location must be set (location.latitude and location.longitude) to tell directions apart")
+	}
+	if *count < 4 || *count > 36 {
+		return fmt.Errorf("invalid -sectors %d: must be 4 to 36", *count)
+	}
+	if *interval < time.Second {
+		return fmt.Errorf("invalid -interval %s: must be at least 1s", *interval)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Enter resets the baseline; reading stdin blocks, so it runs on its own
+	reset := make(chan struct{}, 1)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			select {
+			case reset <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	receiver := decoder.Position{Latitude: cfg.Location.Latitude, Longitude: cfg.Location.Longitude}
+	url := strings.TrimSuffix(*apiURL, "/") + "/api/aircraft"
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	before, err := fetchAircraft(client, url)
+	if err != nil {
+		return err
+	}
+	sampled := time.Now()
+	var baseline []tracker.AntennaSector
+	history := make([][]float64, *count)
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println()
+			return nil
+		case <-reset:
+			baseline = nil
+			continue
+		case <-ticker.C:
+		}
+
+		after, err := fetchAircraft(client, url)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		sectors := tracker.AntennaSectors(receiver, before, after, now.Sub(sampled), *count)
+		before, sampled = after, now
+		if baseline == nil {
+			baseline = sectors
+		}
+		for i, s := range sectors {
+			history[i] = append(history[i], s.Rate)
+			if len(history[i]) > sparkHistory {
+				history[i] = history[i][1:]
+			}
+		}
+		printAntenna(os.Stdout, sectors, baseline, history)
+	}
+}
+
+// fetchAircraft returns the aircraft the daemon is tracking
+func fetchAircraft(client *http.Client, url string) ([]tracker.AircraftState, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach the daemon at %s (is it running?): %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("aircraft request failed: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var list schema.AircraftList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode aircraft response: %w", err)
+	}
+	return list.Aircraft, nil
+}
+
+// printAntenna redraws the screen with one line per sector: its rate and signal, their change from the baseline,
+// and a sparkline of recent rates
+func printAntenna(w io.Writer, sectors, baseline []tracker.AntennaSector, history [][]float64) {
+	peak := 0.0
+	for _, rates := range history {
+		for _, rate := range rates {
+			peak = max(peak, rate)
+		}
+	}
+
+	fmt.Fprint(w, "\033[H\033[2J") // Home and clear, so each sample replaces the last
+	fmt.Fprintf(w, "%s  press Enter to make these readings the baseline, Ctrl-C to stop\n\n", time.Now().Format(time.TimeOnly))
+	fmt.Fprintf(w, "%-8s %8s %8s %9s %9s  %s\n", "bearing", "aircraft", "msg/s", "Δ msg/s", "Δ signal", "recent msg/s")
+	for i, s := range sectors {
+		signal := "-"
+		if s.Aircraft > 0 && baseline[i].Aircraft > 0 {
+			signal = fmt.Sprintf("%+.1f dB", s.Signal-baseline[i].Signal)
+		}
+		fmt.Fprintf(w, "%-8s %8d %8.1f %+9.1f %9s  %s\n", fmt.Sprintf("%03.0f°", s.Bearing), s.Aircraft, s.Rate,
+			s.Rate-baseline[i].Rate, signal, sparkline(history[i], peak))
+	}
+}
+
+// sparkline draws values scaled to peak
+func sparkline(values []float64, peak float64) string {
+	var b strings.Builder
+	for _, v := range values {
+		level := 0
+		if peak > 0 {
+			level = int(v / peak * float64(len(sparkBlocks)-1))
+		}
+		b.WriteRune(sparkBlocks[level])
+	}
+	return b.String()
+}
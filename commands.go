@@ -0,0 +1,879 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/config"
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/dump1090"
+	"flight_trmnl/internal/hub"
+	"flight_trmnl/internal/importer"
+	"flight_trmnl/internal/metadata"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/report"
+	"flight_trmnl/internal/tasks"
+	"flight_trmnl/internal/trmnl"
+	"flight_trmnl/internal/update"
+)
+
+// runCommand runs a one-shot subcommand instead of the collector daemon
+func runCommand(cfg *config.Config, db *database.DB, args []string) error {
+	switch args[0] {
+	case "import":
+		return runImport(db, args[1:])
+	case "lookup":
+		return runLookup(cfg, db, args[1:])
+	case "layouts":
+		return runLayouts(cfg, args[1:])
+	case "events":
+		return runEvents(db, args[1:])
+	case "fleet":
+		return runFleet(db, args[1:])
+	case "blocks":
+		return runBlocks(db)
+	case "tags":
+		return runTags(cfg, db, args[1:])
+	case "stats":
+		return runStats(db, args[1:])
+	case "enrich":
+		return runEnrich(cfg, db, args[1:])
+	case "stations":
+		return runStations(db, args[1:])
+	case "connections":
+		return runConnections(db, args[1:])
+	case "advisories":
+		return runAdvisories(db, args[1:])
+	case "report":
+		return runReport(cfg, db, args[1:])
+	case "export":
+		return runExport(cfg, db, args[1:])
+	case "sync":
+		return runSync(cfg, db, args[1:])
+	case "capture":
+		return runCapture(cfg, args[1:])
+	case "antenna":
+		return runAntenna(cfg, args[1:])
+	case "tasks":
+		if len(args) > 1 && args[1] == "history" {
+			return runTaskHistory(db, args[2:])
+		}
+		return runTasks(cfg, args[1:])
+	case "db":
+		return runDB(cfg, db, args[1:])
+	case "config":
+		return runConfig(cfg)
+	case "version":
+		fmt.Println(version)
+		return nil
+	case "self-update":
+		return runSelfUpdate(args[1:])
+	default:
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+}
+
+// runStations lists, registers, or revokes the stations allowed to feed this hub
+// Usage: stations | stations add [-rate N] <name> | stations revoke <name>
+func runStations(db *database.DB, args []string) error {
+	repo := db.HubStationRepository()
+	registry := hub.NewRegistry(repo)
+
+	if len(args) == 0 {
+		stations, err := repo.List()
+		if err != nil {
+			return err
+		}
+		for _, s := range stations {
+			status := "active"
+			if s.RevokedAt != nil {
+				status = "revoked " + s.RevokedAt.Local().Format(time.DateTime)
+			}
+			rate := "default rate"
+			if s.RateLimit > 0 {
+				rate = fmt.Sprintf("%g msg/s", s.RateLimit)
+			}
+			fmt.Printf("%-20s %-14s registered %s  %s\n", s.Name, rate, s.CreatedAt.Local().Format(time.DateTime), status)
+		}
+		return nil
+	}
+
+	switch args[0] {
+	case "add":
+		fs := flag.NewFlagSet("stations add", flag.ContinueOnError)
+		rate := fs.Float64("rate", 0, "messages per second the station may send (default hub.rate_limit)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 || *rate < 0 {
+			return fmt.Errorf("usage: stations add [-rate N] <name>")
+		}
+		key, err := registry.Issue(fs.Arg(0), *rate)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Registered station %s. Its key is shown only once; set it as station.token on the station:\n%s\n", fs.Arg(0), key)
+		return nil
+
+	case "revoke":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: stations revoke <name>")
+		}
+		revoked, err := registry.Revoke(args[1])
+		if err != nil {
+			return err
+		}
+		if !revoked {
+			return fmt.Errorf("no active registered station named %s", args[1])
+		}
+		fmt.Printf("Revoked station %s\n", args[1])
+		return nil
+
+	default:
+		return fmt.Errorf("usage: stations | stations add [-rate N] <name> | stations revoke <name>")
+	}
+}
+
+// runConfig prints the effective configuration, with secrets and webhook URLs redacted
+// Usage: config
+func runConfig(cfg *config.Config) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(cfg.Redacted())
+}
+
+// runImport migrates history from another tool's data store
+// Usage: import readsb <file-or-dir>... | import basestation <BaseStation.sqb>
+func runImport(db *database.DB, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: import readsb <file-or-dir>... | import basestation <BaseStation.sqb>")
+	}
+
+	seenRepo := db.SeenAircraftRepository()
+
+	switch args[0] {
+	case "readsb":
+		count, err := importer.ImportReadsbHistory(seenRepo, args[1:])
+		if err != nil {
+			return err
+		}
+		slog.Info("Imported readsb history", "aircraft", count)
+	case "basestation":
+		count, err := importer.ImportBaseStationAircraft(db.AircraftRepository(), args[1])
+		if err != nil {
+			return err
+		}
+		slog.Info("Imported BaseStation aircraft metadata", "aircraft", count)
+
+		count, err = importer.ImportBaseStationFlights(seenRepo, args[1])
+		if err != nil {
+			return err
+		}
+		slog.Info("Imported BaseStation flights", "aircraft", count)
+	default:
+		return fmt.Errorf("unknown import source: %s (must be readsb or basestation)", args[0])
+	}
+
+	return nil
+}
+
+// newResolverChain builds the metadata resolver chain from configuration
+func newResolverChain(cfg *config.Config, db *database.DB) (*metadata.Chain, func() error, error) {
+	return metadata.New(cfg.Metadata.Resolvers, metadata.Options{
+		AircraftRepo:    db.AircraftRepository(),
+		BaseStationPath: cfg.Metadata.BaseStationPath,
+		OpenSkyURL:      cfg.Metadata.OpenSkyURL,
+		CacheTTL:        time.Duration(cfg.Metadata.CacheTTL) * time.Second,
+	})
+}
+
+// newEnrichmentBackfill creates the backfill task with an uncached chain of the enrichment resolvers
+// Misses are remembered by the queue itself, caching them in the chain would only delay retries.
+func newEnrichmentBackfill(cfg *config.Config, db *database.DB) (*tasks.EnrichmentBackfill, func() error, error) {
+	chain, closeChain, err := metadata.New(cfg.Enrichment.Resolvers, metadata.Options{
+		BaseStationPath: cfg.Metadata.BaseStationPath,
+		OpenSkyURL:      cfg.Metadata.OpenSkyURL,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	backfill := tasks.NewEnrichmentBackfill(db.EnrichmentRepository(), db.AircraftRepository(), chain,
+		cfg.Enrichment.Resolvers,
+		time.Duration(cfg.Enrichment.LookupDelay)*time.Millisecond,
+		cfg.Enrichment.MaxAttempts)
+	return backfill, closeChain, nil
+}
+
+// runLookup resolves and prints aircraft metadata for one or more ICAO addresses
+// Usage: lookup <icao>...
+func runLookup(cfg *config.Config, db *database.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: lookup <icao>...")
+	}
+
+	chain, closeChain, err := newResolverChain(cfg, db)
+	if err != nil {
+		return err
+	}
+	defer closeChain()
+
+	for _, icao := range args {
+		ac, source, err := chain.Resolve(context.Background(), icao)
+		if err != nil {
+			return err
+		}
+		if ac == nil {
+			fmt.Printf("%s: not found\n", icao)
+			continue
+		}
+		fmt.Printf("%s (via %s)\n", icao, source)
+		fmt.Printf("  Registration: %s\n", ac.Registration)
+		fmt.Printf("  Type:         %s %s (%s)\n", ac.ManufacturerName, ac.Model, ac.TypeCode)
+		fmt.Printf("  Operator:     %s\n", ac.Operator)
+		fmt.Printf("  Owner:        %s\n", ac.Owner)
+		fmt.Printf("  Country:      %s\n", ac.Country)
+
+		tags, err := db.TagRepository().Get(icao)
+		if err != nil {
+			return err
+		}
+		for _, tag := range tags {
+			fmt.Printf("  Listed:       %s: %s %v\n", tag.Source, tag.Category, tag.Tags)
+		}
+	}
+
+	return nil
+}
+
+// runLayouts loads a TRMNL layouts directory and reports each layout or why it failed to load
+// Usage: layouts [dir] (defaults to trmnl.layouts_dir)
+func runLayouts(cfg *config.Config, args []string) error {
+	dir := cfg.TRMNL.LayoutsDir
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	if dir == "" {
+		return fmt.Errorf("usage: layouts <dir> (or set trmnl.layouts_dir)")
+	}
+
+	set, err := trmnl.NewTemplateSet(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, layout := range set.Layouts() {
+		fmt.Printf("%s %s (data: %s)\n", layout.Name, layout.Version, layout.Data)
+		if layout.Description != "" {
+			fmt.Printf("  %s\n", layout.Description)
+		}
+		if layout.Author != "" {
+			fmt.Printf("  by %s\n", layout.Author)
+		}
+	}
+	errors := set.Errors()
+	for layoutDir, err := range errors {
+		fmt.Printf("%s: %v\n", layoutDir, err)
+	}
+	if len(errors) > 0 {
+		return fmt.Errorf("%d layout(s) failed to load", len(errors))
+	}
+	return nil
+}
+
+// runEvents prints recorded events, oldest first, to review what happened while nobody was watching
+// Usage: events [-since 24h] [-type new_aircraft] [-severity warning] [-icao A1B2C3]
+func runEvents(db *database.DB, args []string) error {
+	fs := flag.NewFlagSet("events", flag.ContinueOnError)
+	since := fs.Duration("since", 24*time.Hour, "show events newer than this")
+	eventType := fs.String("type", "", "only events of this type")
+	severity := fs.String("severity", "", "only events of this severity")
+	icao := fs.String("icao", "", "only events for this aircraft")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	filter := database.EventFilter{
+		Type:     *eventType,
+		Severity: *severity,
+		ICAO:     strings.ToUpper(*icao),
+		From:     time.Now().Add(-*since),
+	}
+	page := database.PageRequest{Sort: "time", Limit: database.MaxPageSize}
+
+	repo := db.EventRepository()
+	count := 0
+	for {
+		events, next, err := repo.QueryHistory(filter, page)
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			fmt.Printf("%s  %-8s  %-12s  %-6s  %s\n",
+				e.Time.Local().Format("2006-01-02 15:04:05"), e.Severity, e.Type, e.ICAO, e.Message)
+		}
+		count += len(events)
+		if next == "" {
+			break
+		}
+		page.Cursor = next
+	}
+
+	if count == 0 {
+		fmt.Printf("No events in the last %s\n", *since)
+	}
+	return nil
+}
+
+// runFleet prints the operators with the most aircraft seen, or one operator's fleet
+// Usage: fleet [operator-icao]
+func runFleet(db *database.DB, args []string) error {
+	repo := db.FleetRepository()
+
+	if len(args) == 0 {
+		fleets, err := repo.Operators(20)
+		if err != nil {
+			return err
+		}
+		for _, f := range fleets {
+			fmt.Printf("%-4s %-40s %5d / %-5d %5.1f%%\n", f.OperatorICAO, f.Operator, f.Seen, f.FleetSize, f.SeenPercent)
+		}
+		return nil
+	}
+
+	report, err := repo.Fleet(args[0])
+	if err != nil {
+		return err
+	}
+	if report == nil {
+		return fmt.Errorf("no aircraft found for operator %s", args[0])
+	}
+	fmt.Printf("%s (%s): seen %d of %d aircraft (%.1f%%)\n",
+		report.Operator, report.OperatorICAO, report.Seen, report.FleetSize, report.SeenPercent)
+	for _, ac := range report.Aircraft {
+		mark := " "
+		if ac.Seen {
+			mark = "*"
+		}
+		fmt.Printf("  %s %-8s %-6s %s\n", mark, ac.Registration, ac.TypeCode, ac.ICAO)
+	}
+	return nil
+}
+
+// runBlocks prints how many aircraft were seen from each ICAO address block
+// Usage: blocks
+func runBlocks(db *database.DB) error {
+	blocks, err := db.FleetRepository().Blocks()
+	if err != nil {
+		return err
+	}
+	for _, b := range blocks {
+		fmt.Printf("%6d  %s\n", b.Seen, b.Country)
+	}
+	return nil
+}
+
+// runConnections prints each input's uptime and its recent connects and disconnects
+// Usage: connections [-since 168h] [-limit 20]
+func runConnections(db *database.DB, args []string) error {
+	fs := flag.NewFlagSet("connections", flag.ContinueOnError)
+	since := fs.Duration("since", 7*24*time.Hour, "period to report on")
+	limit := fs.Int("limit", 20, "recent events to list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	repo := db.ConnectionRepository()
+	now := time.Now()
+	uptimes, err := repo.Uptime(now.Add(-*since), now)
+	if err != nil {
+		return err
+	}
+	for _, u := range uptimes {
+		status := "down"
+		if u.Connected {
+			status = "connected"
+		}
+		fmt.Printf("%-28s %-9s %6.2f%% up  %3d disconnects  longest outage %s\n", u.Input, status, u.UptimePercent,
+			u.Disconnects, time.Duration(u.LongestOutage*float64(time.Second)).Round(time.Second))
+	}
+
+	events, err := repo.List(now.Add(-*since), *limit)
+	if err != nil {
+		return err
+	}
+	if len(events) > 0 {
+		fmt.Println()
+	}
+	for _, e := range events {
+		duration := time.Duration(e.Duration * float64(time.Second)).Round(time.Second)
+		detail := fmt.Sprintf("after %s down", duration)
+		switch {
+		case e.Reason == database.ReasonShutdown:
+			detail = fmt.Sprintf("stopped %s after the previous event", duration)
+		case e.Type == database.ConnectionDown:
+			detail = fmt.Sprintf("after %s up: %s", duration, e.Reason)
+		}
+		fmt.Printf("%s  %-28s %-10s %s\n", e.Time.Local().Format(time.DateTime), e.Input, e.Type, detail)
+	}
+	return nil
+}
+
+// runAdvisories prints the TCAS resolution advisories aircraft broadcast, one line per change
+// Usage: advisories [-since 720h] [-limit 20] [icao]
+func runAdvisories(db *database.DB, args []string) error {
+	fs := flag.NewFlagSet("advisories", flag.ContinueOnError)
+	since := fs.Duration("since", 30*24*time.Hour, "period to list")
+	limit := fs.Int("limit", 20, "advisories to list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return fmt.Errorf("usage: advisories [-since 720h] [-limit 20] [icao]")
+	}
+
+	advisories, err := db.AdvisoryRepository().List(strings.ToUpper(fs.Arg(0)), time.Now().Add(-*since), *limit)
+	if err != nil {
+		return err
+	}
+	for _, a := range advisories {
+		name := a.ICAO
+		if a.Callsign != "" {
+			name = fmt.Sprintf("%s (%s)", a.Callsign, a.ICAO)
+		}
+		altitude := ""
+		if a.Altitude != nil {
+			altitude = fmt.Sprintf("%d ft", *a.Altitude)
+		}
+		threat := ""
+		if a.ThreatICAO != "" {
+			threat = "threat " + a.ThreatICAO
+		}
+		fmt.Printf("%s  %-18s %8s  %-40s %s\n", a.Time.Local().Format(time.DateTime), name, altitude, a.Summary, threat)
+	}
+	return nil
+}
+
+// runReport compares the traffic of the last period with the period before it, e.g. this week against last week;
+// html previews the weekly report email
+// Usage: report [-period 168h] [-format markdown|json|html]
+func runReport(cfg *config.Config, db *database.DB, args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	period := fs.Duration("period", 7*24*time.Hour, "length of the periods to compare")
+	format := fs.String("format", "markdown", "markdown, json, or html")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "markdown" && *format != "json" && *format != "html" {
+		return fmt.Errorf("invalid -format %s: must be markdown, json, or html", *format)
+	}
+
+	if *format == "html" {
+		o, err := report.NewOverview(report.Sources{
+			Flights:  db.FlightRepository(),
+			Receiver: db.ReceiverStatsRepository(),
+			Coverage: db.CoverageRepository(),
+		}, time.Now(), *period, time.Local, report.Location(cfg.Location.Name, cfg.Location.Latitude, cfg.Location.Longitude))
+		if err != nil {
+			return err
+		}
+		html, err := o.HTML()
+		if err != nil {
+			return err
+		}
+		fmt.Print(html)
+		return nil
+	}
+
+	c, err := report.Compare(report.Sources{Flights: db.FlightRepository(), Receiver: db.ReceiverStatsRepository()},
+		time.Now(), *period)
+	if err != nil {
+		return err
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(c)
+	}
+	fmt.Print(c.Markdown())
+	return nil
+}
+
+// runCapture asks the running daemon to capture the next seconds of raw receiver bytes, for debugging decoding problems
+// Usage: capture [-seconds 30] [-api http://localhost:8080]
+func runCapture(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("capture", flag.ContinueOnError)
+	seconds := fs.Int("seconds", 30, "how long to capture, at most 300")
+	apiURL := fs.String("api", localAPIURL(cfg.API.Addr), "the running daemon's API")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	apiSet := false
+	fs.Visit(func(f *flag.Flag) { apiSet = apiSet || f.Name == "api" })
+	if !cfg.API.Enabled && !apiSet {
+		return fmt.Errorf("the daemon's API must be enabled (api.enabled) to trigger a capture")
+	}
+
+	fmt.Printf("Capturing %ds of raw bytes from the receiver...\n", *seconds)
+	client := &http.Client{Timeout: time.Duration(*seconds)*time.Second + time.Minute}
+	resp, err := client.Post(fmt.Sprintf("%s/api/capture?seconds=%d", strings.TrimSuffix(*apiURL, "/"), *seconds), "", nil)
+	if err != nil {
+		return fmt.Errorf("failed to reach the daemon at %s (is it running?): %w", *apiURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("capture failed: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var body struct {
+		Capture dump1090.CaptureResult `json:"capture"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("failed to decode capture response: %w", err)
+	}
+	c := body.Capture
+	fmt.Printf("Captured %d bytes from %s in %.0fs\n", c.Bytes, c.Input, c.Seconds)
+	fmt.Printf("  raw bytes: %s\n  annotated: %s\n", c.File, c.Log)
+	fmt.Printf("  %d frames, %d escaped 1a, %d sync losses, %d unknown types, %d bytes skipped\n",
+		c.Frames, c.Escapes, c.SyncLosses, c.UnknownTypes, c.Skipped)
+	return nil
+}
+
+// runTasks lists the running daemon's background tasks, or runs one now; tasks history is runTaskHistory
+// Usage: tasks [-api http://localhost:8080] [run <name>]
+func runTasks(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("tasks", flag.ContinueOnError)
+	apiURL := fs.String("api", localAPIURL(cfg.API.Addr), "the running daemon's API")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	apiSet := false
+	fs.Visit(func(f *flag.Flag) { apiSet = apiSet || f.Name == "api" })
+	if !cfg.API.Enabled && !apiSet {
+		return fmt.Errorf("the daemon's API must be enabled (api.enabled) to manage tasks")
+	}
+	base := strings.TrimSuffix(*apiURL, "/") + "/api/tasks"
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	var resp *http.Response
+	var err error
+	switch rest := fs.Args(); {
+	case len(rest) == 0:
+		resp, err = client.Get(base)
+	case len(rest) == 2 && rest[0] == "run":
+		resp, err = client.Post(base+"/"+url.PathEscape(rest[1]), "", nil)
+	default:
+		return fmt.Errorf("usage: tasks [-api URL] [run <name>]")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to reach the daemon at %s (is it running?): %w", *apiURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("tasks request failed: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var body struct {
+		Tasks []tasks.TaskStatus `json:"tasks"`
+		Task  *tasks.TaskStatus  `json:"task"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("failed to decode tasks response: %w", err)
+	}
+	if body.Task != nil {
+		fmt.Printf("Started %s, see its progress with: tasks\n", body.Task.Name)
+		return nil
+	}
+	for _, t := range body.Tasks {
+		schedule := t.Schedule
+		switch {
+		case t.Interval > 0:
+			schedule = fmt.Sprintf("every %s", time.Duration(t.Interval)*time.Second)
+		case len(t.After) > 0:
+			schedule = "after " + strings.Join(t.After, ", ")
+		}
+		last := "never run"
+		switch {
+		case t.Pending:
+			last = "waiting for " + strings.Join(t.After, ", ")
+		case t.Running:
+			last = fmt.Sprintf("running since %s", t.LastStarted.Local().Format(time.DateTime))
+		case t.LastStarted != nil:
+			last = fmt.Sprintf("last run %s, took %.1fs", t.LastStarted.Local().Format(time.DateTime), t.LastDuration)
+		}
+		fmt.Printf("%-12s %-16s %s\n", t.Name, schedule, last)
+		if t.LastError != "" {
+			fmt.Printf("%-12s failed: %s\n", "", t.LastError)
+		}
+	}
+	return nil
+}
+
+// runTaskHistory summarizes the stored runs of the background tasks and lists recent ones, reading the database
+// so it works while the daemon is stopped
+// Usage: tasks history [-since 720h] [-limit 20] [name]
+func runTaskHistory(db *database.DB, args []string) error {
+	fs := flag.NewFlagSet("tasks history", flag.ContinueOnError)
+	since := fs.Duration("since", 30*24*time.Hour, "period to report on")
+	limit := fs.Int("limit", 20, "recent runs to list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	name := fs.Arg(0)
+
+	repo := db.TaskRunRepository()
+	from := time.Now().Add(-*since)
+	summaries, err := repo.Summary(from)
+	if err != nil {
+		return err
+	}
+	for _, s := range summaries {
+		if name != "" && s.Task != name {
+			continue
+		}
+		last := "never succeeded"
+		if s.LastSuccess != nil {
+			last = "last success " + s.LastSuccess.Local().Format(time.DateTime)
+		}
+		fmt.Printf("%-12s %4d runs  %3d failed  %6.2f%% ok  avg %s  %s\n", s.Task, s.Runs, s.Failures, s.SuccessPercent,
+			time.Duration(s.AvgDuration*float64(time.Second)).Round(100*time.Millisecond), last)
+	}
+
+	runs, err := repo.List(name, from, *limit)
+	if err != nil {
+		return err
+	}
+	if len(runs) > 0 {
+		fmt.Println()
+	}
+	for _, run := range runs {
+		outcome := "ok"
+		if run.Error != "" {
+			outcome = "failed: " + run.Error
+		}
+		fmt.Printf("%s  %-12s %-9s %8s  %s\n", run.Started.Local().Format(time.DateTime), run.Task, run.Trigger,
+			time.Duration(run.Duration*float64(time.Second)).Round(100*time.Millisecond), outcome)
+	}
+	return nil
+}
+
+// localAPIURL is the URL of this machine's API from its listen address, e.g. :8080 is http://localhost:8080
+func localAPIURL(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://" + addr
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// runDecode prints a field by field breakdown of Mode S frames given as hex, or read one per line from stdin.
+// AVR lines as sent on port 30002 (*8d4840d6...;) are accepted too, and so are 2-byte Mode A/C replies.
+// Usage: decode [hex]...
+func runDecode(args []string) error {
+	frames := args
+	if len(frames) == 0 {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				frames = append(frames, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("failed to read frames: %w", err)
+		}
+	}
+	if len(frames) == 0 {
+		return fmt.Errorf("usage: decode <hex>... (or one frame per line on stdin)")
+	}
+
+	for i, frame := range frames {
+		if i > 0 {
+			fmt.Println()
+		}
+		clean := strings.TrimPrefix(strings.TrimSuffix(strings.TrimPrefix(frame, "*"), ";"), "0x")
+		msg, err := hex.DecodeString(strings.ReplaceAll(clean, " ", ""))
+		if err != nil {
+			return fmt.Errorf("%s is not a hex frame: %w", frame, err)
+		}
+		describe := models.DescribeModeS
+		if len(msg) == models.BeastDataLenModeAC {
+			describe = models.DescribeModeAC
+		}
+		fields, err := describe(msg)
+		if err != nil {
+			return fmt.Errorf("%s: %w", frame, err)
+		}
+		for _, f := range fields {
+			fmt.Println(strings.TrimRight(fmt.Sprintf("%-14s %-28s %s", f.Name, f.Value, f.Note), " "))
+		}
+	}
+	return nil
+}
+
+// runStats prints the stored messages by downlink format and ADS-B type code
+func runStats(db *database.DB, args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	since := fs.Duration("since", 0, "only messages newer than this (default all stored messages)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var filter database.MessageFilter
+	if *since > 0 {
+		filter.From = time.Now().Add(-*since)
+	}
+	breakdown, err := db.BeastMessageRepository().MessageTypes(filter)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d messages\n", breakdown.Total)
+	for _, f := range breakdown.Formats {
+		format := fmt.Sprintf("DF%d", f.Code)
+		if f.Code == models.DFModeAC {
+			format = "A/C"
+		}
+		fmt.Printf("%-5s      %10d  %5.1f%%  %s\n", format, f.Count, f.Percent, f.Name)
+		for _, tc := range f.TypeCodes {
+			fmt.Printf("      TC%-2d %10d  %5.1f%%  %s\n", tc.Code, tc.Count, tc.Percent, tc.Name)
+		}
+	}
+	return nil
+}
+
+// runTags shows the imported special aircraft lists, imports them now, or shows the tags of aircraft
+// Usage: tags | tags sync | tags <icao>...
+func runTags(cfg *config.Config, db *database.DB, args []string) error {
+	repo := db.TagRepository()
+
+	if len(args) == 0 {
+		sources, err := repo.Sources()
+		if err != nil {
+			return err
+		}
+		for _, s := range sources {
+			fmt.Printf("%-20s %6d aircraft  updated %s\n", s.Name, s.Aircraft, s.UpdatedAt.Local().Format(time.DateTime))
+		}
+		return nil
+	}
+
+	if args[0] == "sync" {
+		if len(cfg.Tags.Lists) == 0 {
+			return fmt.Errorf("no tag lists configured in tags.lists")
+		}
+		return tasks.NewTagSync(repo, newTagLists(cfg), 0).Sync(context.Background())
+	}
+
+	for _, icao := range args {
+		tags, err := repo.Get(icao)
+		if err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			fmt.Printf("%s: not on any list\n", strings.ToUpper(icao))
+			continue
+		}
+		for _, tag := range tags {
+			fmt.Printf("%s [%s] %s %s (%s) %s: %s\n", tag.ICAO, tag.Source, tag.Registration, tag.Operator,
+				tag.Type, tag.Category, strings.Join(tag.Tags, ", "))
+		}
+	}
+	return nil
+}
+
+// runEnrich runs one enrichment backfill pass now, or shows the queue
+// Usage: enrich | enrich status
+func runEnrich(cfg *config.Config, db *database.DB, args []string) error {
+	if len(args) > 0 && args[0] == "status" {
+		counts, err := db.EnrichmentRepository().Counts()
+		if err != nil {
+			return err
+		}
+		for _, status := range []string{database.EnrichmentPending, database.EnrichmentResolved, database.EnrichmentNotFound, database.EnrichmentFailed} {
+			fmt.Printf("%-10s %d\n", status, counts[status])
+		}
+		return nil
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("usage: enrich | enrich status")
+	}
+
+	backfill, closeChain, err := newEnrichmentBackfill(cfg, db)
+	if err != nil {
+		return err
+	}
+	defer closeChain()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	result, err := backfill.RunOnce(ctx)
+	fmt.Printf("queued %d, resolved %d, not found %d, errors %d\n", result.Queued, result.Resolved, result.NotFound, result.Errors)
+	if err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+// runSelfUpdate replaces the running binary with the latest verified GitHub release
+func runSelfUpdate(args []string) error {
+	fs := flag.NewFlagSet("self-update", flag.ContinueOnError)
+	check := fs.Bool("check", false, "only report whether an update is available")
+	force := fs.Bool("force", false, "reinstall even when already up to date")
+	repo := fs.String("repo", update.DefaultRepository, "GitHub repository to update from")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	updater := update.New(*repo, version)
+	release, err := updater.Latest(ctx)
+	if err != nil {
+		return err
+	}
+	if updater.UpToDate(release) && !*force {
+		fmt.Printf("already up to date (%s)\n", version)
+		return nil
+	}
+	if *check {
+		fmt.Printf("update available: %s -> %s\n", version, release.Tag)
+		return nil
+	}
+
+	path, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the running binary: %w", err)
+	}
+	if path, err = filepath.EvalSymlinks(path); err != nil {
+		return fmt.Errorf("failed to locate the running binary: %w", err)
+	}
+	if update.PublicKey == "" {
+		slog.Warn("Binary has no release public key, verifying the checksum only")
+	}
+
+	if err := updater.Apply(ctx, release, path); err != nil {
+		return err
+	}
+	fmt.Printf("updated %s -> %s, restart flight_trmnl to use it\n", version, release.Tag)
+	return nil
+}
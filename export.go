@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"flight_trmnl/internal/config"
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/privacy"
+	"flight_trmnl/internal/report"
+)
+
+// runExport writes the recorded flights or seen aircraft to stdout as CSV or JSON lines. With -anonymize the
+// addresses become salted hashes and callsigns are dropped, for sharing coverage data publicly. The log kind is the
+// sighting log spotters keep, with registration, type and operator, for the -from and -to dates or else -since.
+// Usage: export [-since 720h] [-from DATE] [-to DATE] [-format csv|json] [-anonymize] flights|aircraft|log
+func runExport(cfg *config.Config, db *database.DB, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	since := fs.Duration("since", 30*24*time.Hour, "export records seen in this period")
+	fromDate := fs.String("from", "", "log: first day, as 2006-01-02 or RFC3339")
+	toDate := fs.String("to", "", "log: last day, as 2006-01-02 or RFC3339 (default now)")
+	format := fs.String("format", "csv", "csv or json")
+	anonymize := fs.Bool("anonymize", false, "replace addresses with salted hashes and drop callsigns")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || (fs.Arg(0) != "flights" && fs.Arg(0) != "aircraft" && fs.Arg(0) != "log") {
+		return fmt.Errorf("usage: export [-since 720h] [-from DATE] [-to DATE] [-format csv|json] [-anonymize] " +
+			"flights|aircraft|log")
+	}
+	if *format != "csv" && *format != "json" {
+		return fmt.Errorf("invalid -format %s: must be csv or json", *format)
+	}
+	if fs.Arg(0) == "log" && *anonymize {
+		return fmt.Errorf("-anonymize doesn't apply to log: a sighting log is its registrations")
+	}
+	if fs.Arg(0) != "log" && (*fromDate != "" || *toDate != "") {
+		return fmt.Errorf("-from and -to only apply to log, use -since")
+	}
+
+	var anonymizer *privacy.Anonymizer
+	if *anonymize {
+		if cfg.Export.Salt == "" {
+			fmt.Fprintln(os.Stderr, "No export.salt configured; using a random salt, so hashes won't match other exports")
+		}
+		anonymizer = privacy.NewAnonymizer(cfg.Export.Salt)
+	}
+
+	from := time.Now().Add(-*since)
+	var w exportWriter = &jsonExportWriter{enc: json.NewEncoder(os.Stdout)}
+	if *format == "csv" {
+		w = &csvExportWriter{w: csv.NewWriter(os.Stdout)}
+	}
+	var err error
+	switch fs.Arg(0) {
+	case "flights":
+		err = exportFlights(db.FlightRepository(), from, anonymizer, w)
+	case "aircraft":
+		err = exportSightings(db.SeenAircraftRepository(), from, anonymizer, w)
+	default:
+		to := time.Now()
+		if *toDate != "" {
+			if to, err = parseDate(*toDate, true); err != nil {
+				return err
+			}
+		}
+		if *fromDate != "" {
+			if from, err = parseDate(*fromDate, false); err != nil {
+				return err
+			}
+		} else if *toDate != "" {
+			from = to.Add(-*since)
+		}
+		location := report.Location(cfg.Location.Name, cfg.Location.Latitude, cfg.Location.Longitude)
+		err = exportLog(db.FlightRepository(), from, to, location, w)
+	}
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// exportFlights writes the flights last seen since from, oldest first
+func exportFlights(repo database.FlightRepository, from time.Time, anonymizer *privacy.Anonymizer, w exportWriter) error {
+	flights, err := repo.List(database.FlightFilter{Since: from}, -1) // SQLite reads a negative limit as none
+	if err != nil {
+		return err
+	}
+	if err := w.Header([]string{"icao", "callsign", "category", "first_seen", "last_seen", "messages", "quality", "gaps",
+		"longest_gap", "positions", "position_rate", "signal_mean", "signal_stddev", "max_range"}); err != nil {
+		return err
+	}
+	for i := len(flights) - 1; i >= 0; i-- {
+		f := flights[i]
+		if anonymizer != nil {
+			f = anonymizer.Flight(f)
+		}
+		row := []string{f.ICAO, f.Callsign, f.Category, f.FirstSeen.UTC().Format(time.RFC3339),
+			f.LastSeen.UTC().Format(time.RFC3339), strconv.FormatInt(f.Messages, 10), strconv.Itoa(f.Quality),
+			strconv.Itoa(f.Gaps), formatFloat(f.LongestGap), strconv.FormatInt(f.Positions, 10),
+			formatFloat(f.PositionRate), formatFloat(f.SignalMean), formatFloat(f.SignalStdDev), formatFloat(f.MaxRange)}
+		if err := w.Write(row, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportSightings writes the aircraft seen since from, in address order
+func exportSightings(repo database.SeenAircraftRepository, from time.Time, anonymizer *privacy.Anonymizer, w exportWriter) error {
+	if err := w.Header([]string{"icao", "first_seen", "last_seen", "message_count", "callsign", "category", "source"}); err != nil {
+		return err
+	}
+	filter := database.SightingFilter{SeenFrom: from}
+	page := database.PageRequest{Sort: "icao", Limit: database.MaxPageSize}
+	for {
+		sightings, next, err := repo.QueryHistory(filter, page)
+		if err != nil {
+			return err
+		}
+		for _, s := range sightings {
+			out := s
+			if anonymizer != nil {
+				out = anonymizer.Sighting(s)
+			}
+			row := []string{out.ICAO, out.FirstSeen.UTC().Format(time.RFC3339), out.LastSeen.UTC().Format(time.RFC3339),
+				strconv.FormatInt(out.MessageCount, 10), out.Callsign, out.Category, out.Source}
+			if err := w.Write(row, out); err != nil {
+				return err
+			}
+		}
+		if next == "" {
+			return nil
+		}
+		page.Cursor = next
+	}
+}
+
+// exportLog writes the sighting log of the flights that started from from until to, oldest first
+func exportLog(repo database.FlightRepository, from, to time.Time, location string, w exportWriter) error {
+	log, err := report.Log(repo, from, to, location)
+	if err != nil {
+		return err
+	}
+	if err := w.Header(report.LogColumns); err != nil {
+		return err
+	}
+	for _, e := range log.Entries {
+		if err := w.Write(log.Row(e, time.Local), e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseDate reads a -from or -to date: a local day, which as the end of a range takes in the whole day, or an
+// RFC3339 time
+func parseDate(s string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %s: use 2006-01-02 or RFC3339", s)
+	}
+	if end {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day, nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// exportWriter writes exported records as CSV rows or as JSON objects, one per line
+type exportWriter interface {
+	Header(columns []string) error
+	Write(row []string, record any) error
+	Flush() error
+}
+
+type csvExportWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvExportWriter) Header(columns []string) error { return c.w.Write(columns) }
+
+func (c *csvExportWriter) Write(row []string, _ any) error { return c.w.Write(row) }
+
+func (c *csvExportWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonExportWriter struct {
+	enc *json.Encoder
+}
+
+func (j *jsonExportWriter) Header([]string) error { return nil }
+
+func (j *jsonExportWriter) Write(_ []string, record any) error { return j.enc.Encode(record) }
+
+func (j *jsonExportWriter) Flush() error { return nil }
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"flight_trmnl/internal/config"
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/dump1090"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/service"
+)
+
+// inputs runs the inputs besides beast_addr - receivers, udp_inputs and serial_inputs - each as a service sending to
+// the pipeline, and applies reloaded settings to the running ones
+type inputs struct {
+	services    *service.Group
+	ch          chan<- *models.BeastMessage
+	connections database.ConnectionRepository
+
+	receivers map[string]*dump1090.BeastClient    // By ID
+	udp       map[string]config.UDPInputConfig    // By address
+	serial    map[string]config.SerialInputConfig // By device
+}
+
+func newInputs(services *service.Group, ch chan<- *models.BeastMessage, connections database.ConnectionRepository) *inputs {
+	return &inputs{services: services, ch: ch, connections: connections,
+		receivers: make(map[string]*dump1090.BeastClient),
+		udp:       make(map[string]config.UDPInputConfig),
+		serial:    make(map[string]config.SerialInputConfig)}
+}
+
+// apply makes the running inputs the configuration's: a receiver whose ID is kept is switched to its new settings
+// as beast_addr is, a UDP or serial input whose settings changed is restarted, and the others are started or
+// stopped. An input that fails to start is skipped and its error returned with the others.
+func (in *inputs) apply(ctx context.Context, cfg *config.Config) error {
+	var errs []error
+
+	ids := make(map[string]bool, len(cfg.Receivers))
+	for _, r := range cfg.Receivers {
+		ids[r.ID] = true
+	}
+	for id := range in.receivers {
+		if !ids[id] {
+			in.services.Remove("beast " + id)
+			delete(in.receivers, id)
+			slog.Info("Stopped Beast message collector", "receiver", id)
+		}
+	}
+	for _, r := range cfg.Receivers {
+		settings := dump1090.Input{Addr: r.Addr, Format: r.Format, Timestamps: r.Clock, Receiver: r.ID}
+		if client, ok := in.receivers[r.ID]; ok {
+			if err := client.Reconfigure(settings); err != nil {
+				errs = append(errs, fmt.Errorf("receiver %s: %w", r.ID, err))
+			}
+			continue
+		}
+		client := dump1090.NewBeastClient("")
+		if err := client.Reconfigure(settings); err != nil {
+			errs = append(errs, fmt.Errorf("receiver %s: %w", r.ID, err))
+			continue
+		}
+		client.RecordConnections(in.connections)
+		in.receivers[r.ID] = client
+		slog.Info("Starting Beast message collector", "receiver", r.ID, "addr", r.Addr)
+		in.services.Start(ctx, service.New("beast "+r.ID, func(ctx context.Context) error {
+			defer client.Close()
+			return client.StreamMessages(ctx, in.ch)
+		}, func() error {
+			if !client.Stats().Connected {
+				return fmt.Errorf("not connected to %s", client.Addr())
+			}
+			return nil
+		}))
+	}
+
+	udp := make(map[string]config.UDPInputConfig, len(cfg.UDPInputs))
+	for _, c := range cfg.UDPInputs {
+		udp[c.Addr] = c
+	}
+	for addr, running := range in.udp {
+		if c, ok := udp[addr]; !ok || c != running {
+			in.services.Remove("udp " + addr)
+			delete(in.udp, addr)
+		}
+	}
+	for _, c := range cfg.UDPInputs {
+		if _, ok := in.udp[c.Addr]; ok {
+			continue
+		}
+		listener, err := dump1090.NewUDPListener(c.Addr, c.Format, c.Clock, c.Receiver)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("UDP input %s: %w", c.Addr, err))
+			continue
+		}
+		in.udp[c.Addr] = c
+		in.services.Start(ctx, service.New("udp "+c.Addr, func(ctx context.Context) error {
+			return listener.StreamMessages(ctx, in.ch)
+		}, nil))
+	}
+
+	serial := make(map[string]config.SerialInputConfig, len(cfg.SerialInputs))
+	for _, c := range cfg.SerialInputs {
+		serial[c.Device] = c
+	}
+	for device, running := range in.serial {
+		if c, ok := serial[device]; !ok || c != running {
+			in.services.Remove("serial " + device)
+			delete(in.serial, device)
+		}
+	}
+	for _, c := range cfg.SerialInputs {
+		if _, ok := in.serial[c.Device]; ok {
+			continue
+		}
+		reader, err := dump1090.NewSerialReader(c.Device, c.Baud, c.Format, c.Clock, c.Receiver)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("serial input %s: %w", c.Device, err))
+			continue
+		}
+		in.serial[c.Device] = c
+		in.services.Start(ctx, service.New("serial "+c.Device, func(ctx context.Context) error {
+			return reader.StreamMessages(ctx, in.ch)
+		}, reader.Healthy))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/config"
+	"flight_trmnl/internal/dump1090"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serviceNames(services *service.Group) []string {
+	var names []string
+	for _, h := range services.Health() {
+		names = append(names, h.Name)
+	}
+	return names
+}
+
+func TestInputs_Apply(t *testing.T) {
+	services := service.NewGroup(time.Second)
+	defer services.Stop()
+	in := newInputs(services, make(chan *models.BeastMessage, 10), nil)
+	ctx := context.Background()
+
+	udp := config.UDPInputConfig{Addr: "127.0.0.1:0", Format: dump1090.FormatBeast, Clock: models.ClockFreeRunning}
+	require.NoError(t, in.apply(ctx, &config.Config{
+		Receivers: []config.ReceiverConfig{{ID: "roof", Addr: "127.0.0.1:1"}, {ID: "garden", Addr: "127.0.0.1:2"}},
+		UDPInputs: []config.UDPInputConfig{udp},
+	}))
+	assert.Equal(t, []string{"beast roof", "beast garden", "udp 127.0.0.1:0"}, serviceNames(services))
+	roof := in.receivers["roof"]
+
+	// Reloaded: roof moved, garden removed, shed added, and the UDP input switched to AVR
+	udp.Format = dump1090.FormatAVR
+	require.NoError(t, in.apply(ctx, &config.Config{
+		Receivers: []config.ReceiverConfig{{ID: "roof", Addr: "127.0.0.1:3"}, {ID: "shed", Addr: "127.0.0.1:4"}},
+		UDPInputs: []config.UDPInputConfig{udp},
+	}))
+	assert.Equal(t, []string{"beast roof", "beast shed", "udp 127.0.0.1:0"}, serviceNames(services))
+	assert.Same(t, roof, in.receivers["roof"], "a kept receiver keeps its client")
+	assert.Equal(t, "127.0.0.1:3", roof.Addr())
+	assert.Equal(t, udp, in.udp["127.0.0.1:0"])
+	for _, h := range services.Health() {
+		assert.Equal(t, service.StateRunning, h.State, h.Name)
+	}
+
+	require.NoError(t, in.apply(ctx, &config.Config{}))
+	assert.Empty(t, serviceNames(services))
+}
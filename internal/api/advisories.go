@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/privacy"
+	"flight_trmnl/pkg/schema"
+)
+
+const (
+	defaultAdvisoriesPeriod = 30 * 24 * time.Hour
+	defaultAdvisoriesLimit  = 50
+)
+
+// advisoriesHandler lists the TCAS resolution advisories aircraft broadcast, one entry per change in an advisory
+// GET /api/advisories?since=720h&icao=A05F21&limit=50; since defaults to 30 days and limit to 50. icao selects the
+// advisories an aircraft received or was the threat in.
+type advisoriesHandler struct {
+	repo    database.AdvisoryRepository
+	privacy *privacy.Output
+}
+
+func (h *advisoriesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	period, ok := parsePeriod(w, r, defaultAdvisoriesPeriod)
+	if !ok {
+		return
+	}
+	limit := defaultAdvisoriesLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > database.MaxPageSize {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	since := time.Now().Add(-period)
+	advisories, err := h.repo.List(strings.ToUpper(r.URL.Query().Get("icao")), since, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	advisories = privacy.Filter(advisories, h.privacy.Advisory)
+	if advisories == nil {
+		advisories = []*database.Advisory{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"schema_version": schema.APIVersion,
+		"since":          since.UTC(),
+		"advisories":     advisories,
+	})
+}
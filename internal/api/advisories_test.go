@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/privacy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockAdvisoryRepository returns fixed advisories and remembers the query
+type mockAdvisoryRepository struct {
+	advisories []*database.Advisory
+	icao       string
+	since      time.Time
+	limit      int
+}
+
+func (m *mockAdvisoryRepository) Insert(advisory *database.Advisory) error { return nil }
+
+func (m *mockAdvisoryRepository) List(icao string, since time.Time, limit int) ([]*database.Advisory, error) {
+	m.icao, m.since, m.limit = icao, since, limit
+	return m.advisories, nil
+}
+
+func TestAdvisoriesHandler(t *testing.T) {
+	altitude, threatAltitude := 12000, 12400
+	repo := &mockAdvisoryRepository{advisories: []*database.Advisory{{
+		ICAO: "A05F21", Callsign: "UAL123", Altitude: &altitude, Summary: "Climb, corrective",
+		ThreatICAO: "A00001", ThreatCallsign: "N1", ThreatAltitude: &threatAltitude,
+	}}}
+	handler := &advisoriesHandler{repo: repo}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/advisories?since=24h&icao=a05f21&limit=5", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "A05F21", repo.icao)
+	assert.WithinDuration(t, time.Now().Add(-24*time.Hour), repo.since, time.Minute)
+	assert.Equal(t, 5, repo.limit)
+
+	var body struct {
+		SchemaVersion int                  `json:"schema_version"`
+		Advisories    []*database.Advisory `json:"advisories"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, 1, body.SchemaVersion)
+	require.Len(t, body.Advisories, 1)
+	assert.Equal(t, "Climb, corrective", body.Advisories[0].Summary)
+	assert.Equal(t, "N1", body.Advisories[0].ThreatCallsign)
+	require.NotNil(t, body.Advisories[0].ThreatAltitude)
+	assert.Equal(t, 12400, *body.Advisories[0].ThreatAltitude)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/advisories", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "", repo.icao)
+	assert.WithinDuration(t, time.Now().Add(-defaultAdvisoriesPeriod), repo.since, time.Minute)
+	assert.Equal(t, defaultAdvisoriesLimit, repo.limit)
+
+	for _, query := range []string{"since=yesterday", "limit=0", "limit=many"} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/advisories?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/advisories", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestAdvisoriesHandler_Privacy(t *testing.T) {
+	repo := &mockAdvisoryRepository{advisories: []*database.Advisory{
+		{ICAO: "A05F21", Callsign: "UAL123", ThreatICAO: "A00001", ThreatCallsign: "N1"},
+		{ICAO: "A1B2C3", Callsign: "DAL9"},
+	}}
+	blocklist := privacy.NewBlocklist([]string{"A00001"}, nil, nil)
+
+	tests := []struct {
+		policy  string
+		threats []string
+	}{
+		{privacy.PolicyShow, []string{"A00001", ""}},
+		{privacy.PolicyExclude, []string{""}},
+		{privacy.PolicyAnonymize, []string{blocklist.Alias("A00001"), ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			handler := &advisoriesHandler{repo: repo, privacy: blocklist.Output(tt.policy)}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/advisories", nil))
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			var body struct {
+				Advisories []*database.Advisory `json:"advisories"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			var threats []string
+			for _, a := range body.Advisories {
+				threats = append(threats, a.ThreatICAO)
+				if a.ThreatICAO != "A00001" {
+					assert.Empty(t, a.ThreatCallsign)
+				}
+			}
+			assert.Equal(t, tt.threats, threats)
+		})
+	}
+}
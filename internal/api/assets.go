@@ -0,0 +1,238 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+)
+
+//go:embed web
+var webFS embed.FS
+
+// webAssets returns the embedded web UI rooted at the web directory
+func webAssets() fs.FS {
+	sub, err := fs.Sub(webFS, "web")
+	if err != nil {
+		panic(err) // The directory is embedded at compile time, this can't fail
+	}
+	return sub
+}
+
+// Cache-Control policies for web assets
+const (
+	cacheRevalidate = "no-cache"                            // HTML: always revalidate so UI updates show up immediately
+	cacheShort      = "public, max-age=3600"                // App assets: short cache, ETag revalidation afterwards
+	cacheImmutable  = "public, max-age=31536000, immutable" // vendor/ assets are versioned by directory (e.g. vendor/leaflet-1.9.4)
+)
+
+// asset is a web file prepared once at startup in every encoding we serve
+type asset struct {
+	contentType  string
+	cacheControl string
+	etag         string // Hash of the identity content; encodings get a suffix
+	identity     []byte
+	gzip         []byte // nil if the asset isn't compressible or compression didn't help
+	brotli       []byte // Only available when a pre-compressed .br file is embedded
+}
+
+// AssetHandler serves static web assets with ETags, pre-compressed encodings, and cache headers
+// Everything is compressed once at startup so the Pi isn't recompressing large libraries on every page load.
+type AssetHandler struct {
+	assets map[string]*asset
+}
+
+// NewAssetHandler loads every file from fsys; name.gz and name.br siblings are used as pre-compressed encodings
+func NewAssetHandler(fsys fs.FS) (*AssetHandler, error) {
+	h := &AssetHandler{assets: make(map[string]*asset)}
+	precompressed := make(map[string][]byte)
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return fmt.Errorf("failed to read asset %s: %w", name, err)
+		}
+
+		if strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".br") {
+			precompressed[name] = data
+			return nil
+		}
+
+		h.assets[name] = newAsset(name, data)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for name, data := range precompressed {
+		a, ok := h.assets[name[:len(name)-3]]
+		if !ok {
+			continue // Compressed file without an original, nothing to attach it to
+		}
+		if strings.HasSuffix(name, ".br") {
+			a.brotli = data
+		} else {
+			a.gzip = data
+		}
+	}
+
+	return h, nil
+}
+
+// newAsset prepares a single file, compressing text-like content with gzip
+func newAsset(name string, data []byte) *asset {
+	sum := sha256.Sum256(data)
+
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+
+	a := &asset{
+		contentType:  contentType,
+		cacheControl: cachePolicy(name),
+		etag:         hex.EncodeToString(sum[:8]),
+		identity:     data,
+	}
+
+	if isCompressible(contentType) {
+		var buf bytes.Buffer
+		zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		zw.Write(data)
+		zw.Close()
+		if buf.Len() < len(data) {
+			a.gzip = buf.Bytes()
+		}
+	}
+
+	return a
+}
+
+// cachePolicy picks the Cache-Control header for an asset path
+func cachePolicy(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".html"):
+		return cacheRevalidate
+	case strings.HasPrefix(name, "vendor/"):
+		return cacheImmutable
+	default:
+		return cacheShort
+	}
+}
+
+// isCompressible reports whether compressing a content type is worth it; images and fonts are already compressed
+func isCompressible(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case mediaType == "application/javascript", mediaType == "application/json",
+		mediaType == "image/svg+xml", mediaType == "application/xml":
+		return true
+	default:
+		return false
+	}
+}
+
+func (h *AssetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	a, ok := h.assets[name]
+	if !ok {
+		// Directory requests serve their index.html
+		a, ok = h.assets[path.Join(name, "index.html")]
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, encoding := a.identity, ""
+	acceptEncoding := r.Header.Get("Accept-Encoding")
+	switch {
+	case a.brotli != nil && acceptsEncoding(acceptEncoding, "br"):
+		body, encoding = a.brotli, "br"
+	case a.gzip != nil && acceptsEncoding(acceptEncoding, "gzip"):
+		body, encoding = a.gzip, "gzip"
+	}
+
+	// Each encoding is a distinct representation and needs its own strong ETag
+	etag := a.etag
+	if encoding != "" {
+		etag += "-" + encoding
+	}
+	etag = `"` + etag + `"`
+
+	header := w.Header()
+	header.Set("ETag", etag)
+	header.Set("Cache-Control", a.cacheControl)
+	if a.gzip != nil || a.brotli != nil {
+		header.Set("Vary", "Accept-Encoding")
+	}
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	header.Set("Content-Type", a.contentType)
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	if encoding != "" {
+		header.Set("Content-Encoding", encoding)
+	}
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method == http.MethodGet {
+		w.Write(body)
+	}
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header allows the given coding (q=0 means refused)
+func acceptsEncoding(header, coding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), coding) {
+			continue
+		}
+		q := strings.TrimSpace(params)
+		if strings.HasPrefix(q, "q=") {
+			if v, err := strconv.ParseFloat(q[2:], 64); err == nil && v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// etagMatches implements the weak comparison If-None-Match requires
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
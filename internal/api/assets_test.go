@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAssetHandler(t *testing.T) *AssetHandler {
+	fsys := fstest.MapFS{
+		"index.html":                         {Data: []byte("<html>" + strings.Repeat("flight terminal ", 50) + "</html>")},
+		"app.js":                             {Data: []byte(strings.Repeat("console.log('hi');\n", 50))},
+		"vendor/leaflet-1.9.4/leaflet.js":    {Data: []byte(strings.Repeat("L.map();\n", 100))},
+		"vendor/leaflet-1.9.4/leaflet.js.br": {Data: []byte("brotli-bytes")},
+		"logo.png":                           {Data: []byte("\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 100))},
+	}
+	h, err := NewAssetHandler(fsys)
+	require.NoError(t, err)
+	return h
+}
+
+func serve(h http.Handler, method, target string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAssetHandler_ServesIndexWithRevalidation(t *testing.T) {
+	h := newTestAssetHandler(t)
+
+	rec := serve(h, http.MethodGet, "/", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-cache", rec.Header().Get("Cache-Control"))
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.NotEmpty(t, rec.Header().Get("ETag"))
+	assert.Contains(t, rec.Body.String(), "flight terminal")
+}
+
+func TestAssetHandler_Gzip(t *testing.T) {
+	h := newTestAssetHandler(t)
+
+	rec := serve(h, http.MethodGet, "/app.js", map[string]string{"Accept-Encoding": "gzip, deflate"})
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+	assert.Equal(t, "public, max-age=3600", rec.Header().Get("Cache-Control"))
+
+	zr, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+	require.NoError(t, err)
+	body, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "console.log")
+
+	// gzip refused explicitly
+	rec = serve(h, http.MethodGet, "/app.js", map[string]string{"Accept-Encoding": "gzip;q=0"})
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+}
+
+func TestAssetHandler_PrecompressedBrotliAndImmutable(t *testing.T) {
+	h := newTestAssetHandler(t)
+
+	rec := serve(h, http.MethodGet, "/vendor/leaflet-1.9.4/leaflet.js", map[string]string{"Accept-Encoding": "gzip, br"})
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "br", rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "brotli-bytes", rec.Body.String())
+	assert.Contains(t, rec.Header().Get("Cache-Control"), "immutable")
+
+	// The .br file itself is not served as a separate asset
+	rec = serve(h, http.MethodGet, "/vendor/leaflet-1.9.4/leaflet.js.br", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAssetHandler_NotModified(t *testing.T) {
+	h := newTestAssetHandler(t)
+
+	first := serve(h, http.MethodGet, "/app.js", map[string]string{"Accept-Encoding": "gzip"})
+	etag := first.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	rec := serve(h, http.MethodGet, "/app.js", map[string]string{"Accept-Encoding": "gzip", "If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.Bytes())
+
+	// The identity representation has a different ETag and must not match the gzip one
+	rec = serve(h, http.MethodGet, "/app.js", map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestAssetHandler_NoCompressionForImages(t *testing.T) {
+	h := newTestAssetHandler(t)
+
+	rec := serve(h, http.MethodGet, "/logo.png", map[string]string{"Accept-Encoding": "gzip"})
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+}
+
+func TestAssetHandler_MethodsAndMissing(t *testing.T) {
+	h := newTestAssetHandler(t)
+
+	assert.Equal(t, http.StatusNotFound, serve(h, http.MethodGet, "/missing.js", nil).Code)
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(h, http.MethodPost, "/", nil).Code)
+
+	rec := serve(h, http.MethodHead, "/app.js", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Body.Bytes())
+	assert.NotEmpty(t, rec.Header().Get("Content-Length"))
+}
+
+func TestEmbeddedWebAssets(t *testing.T) {
+	_, err := NewServer(Options{Addr: "127.0.0.1:0"})
+	require.NoError(t, err)
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Groups of cached queries, for invalidating the ones a finished job changed
+const (
+	CacheMessageStats  = "message_stats"  // Stored message types, /api/stats?source=stored
+	CacheBandStats     = "band_stats"     // Stored altitude bands, /api/stats/bands?source=stored
+	CacheRecords       = "records"        // Station records, /api/stats/records
+	CacheCoverage      = "coverage"       // Coverage summaries, /api/stats/coverage
+	CacheReceiverStats = "receiver_stats" // Receiver comparisons, /api/stats/receivers
+)
+
+// maxCacheEntries bounds the cache, whose keys include query parameters the client picks
+const maxCacheEntries = 256
+
+// QueryCache keeps the results of expensive aggregate queries for a short time, so a dashboard polling them
+// doesn't scan the database on every request while a Pi is busy writing. Concurrent requests for the same result
+// wait for one query instead of each running it. A nil cache, or one without a TTL, caches nothing.
+type QueryCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu         sync.Mutex
+	entries    map[string]*cacheEntry
+	generation uint64 // Bumped by each invalidation, so a query running across one isn't kept
+}
+
+// cacheEntry is one cached result, or a query in progress until ready is closed
+type cacheEntry struct {
+	group   string
+	ready   chan struct{}
+	value   any
+	err     error
+	expires time.Time
+}
+
+// NewQueryCache creates a cache that keeps results for ttl, 0 disables it
+func NewQueryCache(ttl time.Duration) *QueryCache {
+	return &QueryCache{ttl: ttl, now: time.Now, entries: make(map[string]*cacheEntry)}
+}
+
+// Invalidate drops the cached results of the groups, e.g. CacheCoverage after a coverage check, or all of them
+// when none are given
+func (c *QueryCache) Invalidate(groups ...string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	for key, e := range c.entries {
+		if len(groups) == 0 || slices.Contains(groups, e.group) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// get returns the cached result for key in a group, or loads and caches it. Errors aren't cached.
+// The result is shared between requests, so callers must not modify it.
+func (c *QueryCache) get(group, key string, load func() (any, error)) (any, error) {
+	if c == nil || c.ttl <= 0 {
+		return load()
+	}
+	key = group + "?" + key
+
+	c.mu.Lock()
+	now := c.now()
+	if e, ok := c.entries[key]; ok && (e.expires.IsZero() || now.Before(e.expires)) {
+		c.mu.Unlock()
+		<-e.ready
+		return e.value, e.err
+	}
+	c.prune(now)
+	e := &cacheEntry{group: group, ready: make(chan struct{})}
+	c.entries[key] = e
+	generation := c.generation
+	c.mu.Unlock()
+
+	value, err := load()
+
+	c.mu.Lock()
+	e.value, e.err = value, err
+	e.expires = c.now().Add(c.ttl)
+	if (err != nil || c.generation != generation) && c.entries[key] == e {
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+	close(e.ready)
+	return value, err
+}
+
+// prune drops expired results, and everything once the cache is full; the caller holds the lock
+func (c *QueryCache) prune(now time.Time) {
+	for key, e := range c.entries {
+		if !e.expires.IsZero() && !now.Before(e.expires) {
+			delete(c.entries, key)
+		}
+	}
+	if len(c.entries) >= maxCacheEntries {
+		for key, e := range c.entries {
+			if !e.expires.IsZero() {
+				delete(c.entries, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryCache(t *testing.T) {
+	cache := NewQueryCache(30 * time.Second)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	var loads int
+	load := func() (any, error) {
+		loads++
+		return loads, nil
+	}
+	get := func(group, key string) any {
+		v, err := cache.get(group, key, load)
+		require.NoError(t, err)
+		return v
+	}
+
+	assert.Equal(t, 1, get(CacheCoverage, "168h0m0s"))
+	assert.Equal(t, 1, get(CacheCoverage, "168h0m0s"), "cached")
+	assert.Equal(t, 2, get(CacheCoverage, "24h0m0s"), "other parameters")
+
+	now = now.Add(30 * time.Second)
+	assert.Equal(t, 3, get(CacheCoverage, "168h0m0s"), "expired")
+	assert.Equal(t, 4, get(CacheRecords, "168h0m0s"), "other group")
+
+	cache.Invalidate(CacheCoverage)
+	assert.Equal(t, 5, get(CacheCoverage, "168h0m0s"))
+	assert.Equal(t, 4, get(CacheRecords, "168h0m0s"), "other groups are kept")
+	cache.Invalidate()
+	assert.Equal(t, 6, get(CacheRecords, "168h0m0s"))
+
+	failing := func() (any, error) { return nil, errors.New("database is locked") }
+	_, err := cache.get(CacheBandStats, "", failing)
+	assert.Error(t, err)
+	assert.Equal(t, 7, get(CacheBandStats, ""), "errors aren't cached")
+}
+
+func TestQueryCache_LoadsOnce(t *testing.T) {
+	cache := NewQueryCache(time.Minute)
+	release := make(chan struct{})
+	var loads atomic.Int32
+	load := func() (any, error) {
+		loads.Add(1)
+		<-release
+		return "summary", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := cache.get(CacheMessageStats, "source=stored", load)
+			assert.NoError(t, err)
+			assert.Equal(t, "summary", v)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), loads.Load(), "concurrent requests wait for the one query")
+}
+
+func TestQueryCache_InvalidatedWhileLoading(t *testing.T) {
+	cache := NewQueryCache(time.Minute)
+	var loads int
+	_, err := cache.get(CacheBandStats, "", func() (any, error) {
+		loads++
+		cache.Invalidate(CacheBandStats) // A snapshot was stored meanwhile
+		return loads, nil
+	})
+	require.NoError(t, err)
+	v, err := cache.get(CacheBandStats, "", func() (any, error) {
+		loads++
+		return loads, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, v, "the result from before the invalidation isn't kept")
+}
+
+func TestQueryCache_Disabled(t *testing.T) {
+	var loads int
+	load := func() (any, error) {
+		loads++
+		return loads, nil
+	}
+	var nilCache *QueryCache
+	for _, cache := range []*QueryCache{nilCache, NewQueryCache(0)} {
+		cache.get(CacheRecords, "", load)
+		cache.get(CacheRecords, "", load)
+		cache.Invalidate()
+	}
+	assert.Equal(t, 4, loads)
+}
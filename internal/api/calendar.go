@@ -0,0 +1,157 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/privacy"
+)
+
+const (
+	defaultCalendarPeriod = 30 * 24 * time.Hour
+	calendarEventLength   = 15 * time.Minute // Events are instants; calendars hide zero-length entries
+	calendarLineLength    = 75               // Octets per line before folding (RFC 5545 3.1)
+)
+
+// defaultCalendarTypes are the notable events: first-time airframes, watchlist hits, and emergencies
+var defaultCalendarTypes = []string{models.EventNewAircraft, models.EventAlert, models.EventEmergency}
+
+// calendarHandler serves notable events as an iCalendar feed, so calendar apps can subscribe to what happened
+// while nobody was watching
+// GET /api/events.ics?since=720h&types=new_aircraft,alert,emergency; since defaults to 30 days. The newest
+// events are served, up to the maximum page size.
+type calendarHandler struct {
+	repo    database.EventRepository
+	privacy *privacy.Output
+}
+
+func (h *calendarHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	period, ok := parsePeriod(w, r, defaultCalendarPeriod)
+	if !ok {
+		return
+	}
+	types := defaultCalendarTypes
+	if v := r.URL.Query().Get("types"); v != "" {
+		types = nil
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				types = append(types, t)
+			}
+		}
+		if len(types) == 0 {
+			http.Error(w, "invalid types", http.StatusBadRequest)
+			return
+		}
+	}
+
+	filter := database.EventFilter{Types: types, From: time.Now().Add(-period)}
+	page := database.PageRequest{Sort: "time", Descending: true, Limit: database.MaxPageSize}
+	events, _, err := h.repo.QueryHistory(filter, page)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	events = privacy.Filter(events, h.privacy.Event)
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="flight_trmnl.ics"`)
+	w.Write([]byte(calendar(events, time.Now())))
+}
+
+// calendar renders events as an iCalendar document stamped at now
+func calendar(events []*models.Event, now time.Time) string {
+	var b strings.Builder
+	line := func(name, value string) {
+		b.WriteString(foldCalendarLine(name + ":" + value))
+		b.WriteString("\r\n")
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//flight_trmnl//Notable sightings//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	line("X-WR-CALNAME", "Notable sightings")
+	line("REFRESH-INTERVAL;VALUE=DURATION", "PT1H")
+	for _, e := range events {
+		line("BEGIN", "VEVENT")
+		line("UID", "event-"+strconv.FormatInt(e.ID, 10)+"@flight_trmnl")
+		line("DTSTAMP", calendarTime(now))
+		line("DTSTART", calendarTime(e.Time))
+		line("DTEND", calendarTime(e.Time.Add(calendarEventLength)))
+		line("SUMMARY", escapeCalendarText(e.Message))
+		line("DESCRIPTION", escapeCalendarText(eventDescription(e)))
+		line("CATEGORIES", escapeCalendarText(e.Type))
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	return b.String()
+}
+
+// eventDescription lists what identifies an event, one detail per line
+func eventDescription(e *models.Event) string {
+	details := []string{"Type: " + e.Type}
+	if e.Severity != "" {
+		details = append(details, "Severity: "+e.Severity)
+	}
+	if e.ICAO != "" {
+		details = append(details, "ICAO: "+e.ICAO)
+	}
+	if e.Callsign != "" {
+		details = append(details, "Callsign: "+e.Callsign)
+	}
+	keys := make([]string, 0, len(e.Data))
+	for key := range e.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		details = append(details, fmt.Sprintf("%s: %v", key, e.Data[key]))
+	}
+	return strings.Join(details, "\n")
+}
+
+// calendarTime formats t as an iCalendar UTC date-time
+func calendarTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// calendarEscaper escapes the characters iCalendar TEXT values reserve
+var calendarEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// escapeCalendarText escapes an iCalendar TEXT value
+func escapeCalendarText(s string) string {
+	return calendarEscaper.Replace(s)
+}
+
+// foldCalendarLine splits a content line longer than 75 octets, continuing it on lines that start with a space.
+// Lines are split between UTF-8 characters, never inside one.
+func foldCalendarLine(s string) string {
+	if len(s) <= calendarLineLength {
+		return s
+	}
+	var b strings.Builder
+	width := 0
+	for i, r := range s {
+		size := len(string(r))
+		if width+size > calendarLineLength {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteString(s[i : i+size])
+		width += size
+	}
+	return b.String()
+}
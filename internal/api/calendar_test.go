@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/privacy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockEventRepository returns fixed events and remembers the query
+type mockEventRepository struct {
+	events []*models.Event
+	filter database.EventFilter
+	page   database.PageRequest
+}
+
+func (m *mockEventRepository) Insert(event *models.Event) error { return nil }
+
+func (m *mockEventRepository) QueryHistory(filter database.EventFilter, page database.PageRequest) ([]*models.Event, string, error) {
+	m.filter, m.page = filter, page
+	return m.events, "", nil
+}
+
+func TestCalendarHandler(t *testing.T) {
+	repo := &mockEventRepository{events: []*models.Event{{
+		ID: 42, Time: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC), Type: models.EventEmergency,
+		Severity: models.SeverityCritical, ICAO: "A1B2C3", Callsign: "UAL1", Message: "Squawk 7700, general emergency",
+		Data: map[string]any{"squawk": "7700"},
+	}}}
+	handler := &calendarHandler{repo: repo}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/events.ics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/calendar; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, defaultCalendarTypes, repo.filter.Types)
+	assert.WithinDuration(t, time.Now().Add(-defaultCalendarPeriod), repo.filter.From, time.Minute)
+	assert.True(t, repo.page.Descending)
+
+	body := strings.ReplaceAll(rec.Body.String(), "\r\n ", "") // Unfolded
+	assert.True(t, strings.HasPrefix(body, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(body, "END:VCALENDAR\r\n"))
+	assert.Contains(t, body, "UID:event-42@flight_trmnl\r\n")
+	assert.Contains(t, body, "DTSTART:20240501T123000Z\r\n")
+	assert.Contains(t, body, "DTEND:20240501T124500Z\r\n")
+	assert.Contains(t, body, `SUMMARY:Squawk 7700\, general emergency`)
+	assert.Contains(t, body, `DESCRIPTION:Type: emergency\nSeverity: critical\nICAO: A1B2C3\nCallsign: UAL1\nsquawk: 7700`)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/events.ics?types=record,+advisory&since=24h", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"record", "advisory"}, repo.filter.Types)
+	assert.WithinDuration(t, time.Now().Add(-24*time.Hour), repo.filter.From, time.Minute)
+
+	for _, query := range []string{"since=yesterday", "types=,"} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/events.ics?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/events.ics", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestCalendarHandler_Privacy(t *testing.T) {
+	repo := &mockEventRepository{events: []*models.Event{
+		{ID: 1, Type: models.EventNewAircraft, ICAO: "A00001", Message: "First sighting of N1"},
+		{ID: 2, Type: models.EventNewAircraft, ICAO: "A1B2C3", Message: "First sighting of N2"},
+	}}
+	blocklist := privacy.NewBlocklist([]string{"A00001"}, nil, nil)
+
+	handler := &calendarHandler{repo: repo, privacy: blocklist.Output(privacy.PolicyExclude)}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/events.ics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "A00001")
+	assert.NotContains(t, rec.Body.String(), "N1")
+	assert.Contains(t, rec.Body.String(), "UID:event-2@flight_trmnl")
+}
+
+func TestFoldCalendarLine(t *testing.T) {
+	assert.Equal(t, "SUMMARY:short", foldCalendarLine("SUMMARY:short"))
+
+	long := "SUMMARY:" + strings.Repeat("é", 60)
+	folded := foldCalendarLine(long)
+	lines := strings.Split(folded, "\r\n")
+	require.Len(t, lines, 2)
+	for _, line := range lines {
+		assert.LessOrEqual(t, len(line), calendarLineLength)
+	}
+	assert.True(t, strings.HasPrefix(lines[1], " "))
+	assert.Equal(t, long, strings.ReplaceAll(folded, "\r\n ", ""))
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"flight_trmnl/internal/dump1090"
+	"flight_trmnl/pkg/schema"
+)
+
+const defaultCaptureSeconds = 30
+
+// CaptureSource is an input whose raw bytes can be captured, e.g. the Beast client
+type CaptureSource interface {
+	StartCapture(dir string, d time.Duration) (*dump1090.Capture, error)
+}
+
+// captureHandler captures the next seconds of raw bytes from the receiver into the capture directory
+// POST /api/capture?seconds=30 responds once the capture is written and annotated. File names are chosen
+// by the server and captures are at most dump1090.MaxCaptureDuration, so callers can't write elsewhere or fill the disk.
+type captureHandler struct {
+	source CaptureSource
+	dir    string
+}
+
+func (h *captureHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	seconds := defaultCaptureSeconds
+	if v := r.URL.Query().Get("seconds"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || time.Duration(n)*time.Second > dump1090.MaxCaptureDuration {
+			http.Error(w, "invalid seconds", http.StatusBadRequest)
+			return
+		}
+		seconds = n
+	}
+
+	capture, err := h.source.StartCapture(h.dir, time.Duration(seconds)*time.Second)
+	if errors.Is(err, dump1090.ErrCaptureRunning) || errors.Is(err, dump1090.ErrDetached) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The capture carries on if the caller goes away, its files are still written
+	result, err := capture.Wait(r.Context())
+	if r.Context().Err() != nil {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"schema_version": schema.APIVersion,
+		"capture":        result,
+	})
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/dump1090"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureHandler(t *testing.T) {
+	// An unconnected client captures nothing, but the files are still written
+	client := dump1090.NewBeastClient("127.0.0.1:1")
+	handler := &captureHandler{source: client, dir: t.TempDir()}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/capture", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "POST", rec.Header().Get("Allow"))
+
+	for _, seconds := range []string{"0", "abc", "301"} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/capture?seconds="+seconds, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, seconds)
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/capture?seconds=1", nil))
+		done <- rec
+	}()
+
+	// Only one capture runs at a time
+	require.Eventually(t, func() bool {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/capture?seconds=1", nil))
+		return rec.Code == http.StatusConflict
+	}, time.Second, 10*time.Millisecond)
+
+	rec = <-done
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var body struct {
+		SchemaVersion int                    `json:"schema_version"`
+		Capture       dump1090.CaptureResult `json:"capture"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, 1, body.SchemaVersion)
+	assert.Equal(t, "127.0.0.1:1", body.Capture.Input)
+	assert.FileExists(t, body.Capture.File)
+	assert.FileExists(t, body.Capture.Log)
+	assert.Zero(t, body.Capture.Bytes)
+}
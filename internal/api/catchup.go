@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+
+	"flight_trmnl/internal/dump1090"
+	"flight_trmnl/pkg/schema"
+)
+
+// catchUpHandler reports how far replaying the backlog of catch_up_file has got
+// GET /api/catchup keeps answering once the backlog is done, with finished set.
+type catchUpHandler struct {
+	replay *dump1090.Replay
+}
+
+func (h *catchUpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"schema_version": schema.APIVersion,
+		"catch_up":       h.replay.Progress(),
+	})
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"flight_trmnl/internal/dump1090"
+	"flight_trmnl/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatchUpHandler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backlog.bin")
+	require.NoError(t, os.WriteFile(path, []byte("not replayed yet"), 0o644))
+	replay, err := dump1090.NewReplay(path, dump1090.FormatBeast, models.ClockFreeRunning)
+	require.NoError(t, err)
+	handler := &catchUpHandler{replay: replay}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/catchup", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/catchup", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		CatchUp dump1090.ReplayProgress `json:"catch_up"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, path, body.CatchUp.File)
+	assert.Equal(t, int64(16), body.CatchUp.Size)
+	assert.Zero(t, body.CatchUp.Bytes)
+	assert.Nil(t, body.CatchUp.Finished)
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/pkg/schema"
+)
+
+const (
+	defaultConnectionsPeriod = 7 * 24 * time.Hour
+	defaultConnectionsLimit  = 50
+)
+
+// connectionsHandler reports each input's uptime and recent connects and disconnects
+// GET /api/connections?since=24h&limit=50; since defaults to 7 days and limit, the events listed, to 50.
+type connectionsHandler struct {
+	repo database.ConnectionRepository
+}
+
+func (h *connectionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	period, ok := parsePeriod(w, r, defaultConnectionsPeriod)
+	if !ok {
+		return
+	}
+	limit := defaultConnectionsLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > database.MaxPageSize {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	now := time.Now()
+	uptimes, err := h.repo.Uptime(now.Add(-period), now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	events, err := h.repo.List(now.Add(-period), limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if uptimes == nil {
+		uptimes = []*database.InputUptime{}
+	}
+	if events == nil {
+		events = []*database.ConnectionEvent{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"schema_version": schema.APIVersion,
+		"since":          now.Add(-period).UTC(),
+		"inputs":         uptimes,
+		"events":         events,
+	})
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockConnectionRepository returns fixed connection history and remembers the period asked for
+type mockConnectionRepository struct {
+	since time.Time
+	limit int
+}
+
+func (m *mockConnectionRepository) Insert(event *database.ConnectionEvent) error { return nil }
+
+func (m *mockConnectionRepository) List(since time.Time, limit int) ([]*database.ConnectionEvent, error) {
+	m.since, m.limit = since, limit
+	return []*database.ConnectionEvent{{Input: "pi:30005", Type: database.ConnectionDown, Reason: "connection closed", Duration: 3600}}, nil
+}
+
+func (m *mockConnectionRepository) Uptime(since, now time.Time) ([]*database.InputUptime, error) {
+	return []*database.InputUptime{{Input: "pi:30005", UptimePercent: 99.5, Disconnects: 1}}, nil
+}
+
+func TestConnectionsHandler(t *testing.T) {
+	repo := &mockConnectionRepository{}
+	handler := &connectionsHandler{repo: repo}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/connections?since=24h&limit=5", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.WithinDuration(t, time.Now().Add(-24*time.Hour), repo.since, time.Minute)
+	assert.Equal(t, 5, repo.limit)
+
+	var body struct {
+		SchemaVersion int                         `json:"schema_version"`
+		Inputs        []*database.InputUptime     `json:"inputs"`
+		Events        []*database.ConnectionEvent `json:"events"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, 1, body.SchemaVersion)
+	require.Len(t, body.Inputs, 1)
+	assert.Equal(t, 99.5, body.Inputs[0].UptimePercent)
+	require.Len(t, body.Events, 1)
+	assert.Equal(t, "connection closed", body.Events[0].Reason)
+
+	for _, query := range []string{"since=yesterday", "since=-1h", "limit=0"} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/connections?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions controls which browser origins may call the API
+// Dashboards hosted elsewhere (e.g. a map on GitHub Pages) need this to read from the Pi.
+type CORSOptions struct {
+	AllowedOrigins []string // Exact origins like https://me.github.io, or * for any origin
+	AllowedHeaders []string // Request headers browsers may send in addition to the CORS-safelisted ones
+	MaxAge         time.Duration
+}
+
+// corsMethods are the methods the API exposes; it is read-only
+const corsMethods = "GET, HEAD, OPTIONS"
+
+// corsHandler adds CORS headers for allowed origins and answers preflight requests
+type corsHandler struct {
+	next      http.Handler
+	anyOrigin bool
+	origins   map[string]bool
+	headers   string
+	maxAge    string
+}
+
+// newCORSHandler wraps next with CORS handling; requests from other origins pass through without CORS headers
+func newCORSHandler(opts CORSOptions, next http.Handler) *corsHandler {
+	h := &corsHandler{
+		next:    next,
+		origins: make(map[string]bool),
+		headers: strings.Join(opts.AllowedHeaders, ", "),
+	}
+	for _, origin := range opts.AllowedOrigins {
+		if origin == "*" {
+			h.anyOrigin = true
+		}
+		h.origins[strings.TrimSuffix(origin, "/")] = true
+	}
+	if opts.MaxAge > 0 {
+		h.maxAge = strconv.Itoa(int(opts.MaxAge.Seconds()))
+	}
+	return h
+}
+
+func (h *corsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	header := w.Header()
+	header.Add("Vary", "Origin")
+
+	allowed := origin != "" && (h.anyOrigin || h.origins[origin])
+	if allowed {
+		if h.anyOrigin {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		header.Set("Access-Control-Expose-Headers", "ETag")
+	}
+
+	if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	// Preflight: answer here, the API handlers only know GET
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	if allowed {
+		header.Set("Access-Control-Allow-Methods", corsMethods)
+		if h.headers != "" {
+			header.Set("Access-Control-Allow-Headers", h.headers)
+		}
+		if h.maxAge != "" {
+			header.Set("Access-Control-Max-Age", h.maxAge)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
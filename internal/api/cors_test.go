@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORSHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := newCORSHandler(CORSOptions{
+		AllowedOrigins: []string{"https://me.github.io"},
+		AllowedHeaders: []string{"X-Api-Key"},
+		MaxAge:         10 * time.Minute,
+	}, ok)
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		preflight   bool
+		wantStatus  int
+		wantOrigin  string
+		wantHeaders string
+	}{
+		{"allowed origin", http.MethodGet, "https://me.github.io", false, http.StatusOK, "https://me.github.io", ""},
+		{"other origin", http.MethodGet, "https://evil.example", false, http.StatusOK, "", ""},
+		{"same origin", http.MethodGet, "", false, http.StatusOK, "", ""},
+		{"preflight", http.MethodOptions, "https://me.github.io", true, http.StatusNoContent, "https://me.github.io", "X-Api-Key"},
+		{"preflight other origin", http.MethodOptions, "https://evil.example", true, http.StatusNoContent, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/aircraft", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantOrigin, rec.Header().Get("Access-Control-Allow-Origin"))
+			assert.Equal(t, tt.wantHeaders, rec.Header().Get("Access-Control-Allow-Headers"))
+			assert.Contains(t, rec.Header().Values("Vary"), "Origin")
+			if tt.preflight && tt.wantOrigin != "" {
+				assert.Equal(t, "600", rec.Header().Get("Access-Control-Max-Age"))
+			}
+		})
+	}
+}
+
+func TestCORSHandler_AnyOrigin(t *testing.T) {
+	handler := newCORSHandler(CORSOptions{AllowedOrigins: []string{"*"}}, http.NotFoundHandler())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/aircraft", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"flight_trmnl/internal/coverage"
+	"flight_trmnl/internal/database"
+	"flight_trmnl/pkg/schema"
+)
+
+const defaultCoveragePeriod = 7 * 24 * time.Hour
+
+// coverageStatsHandler reports how many of the aircraft an aggregator knew of within the receiver's radius the
+// receiver heard, overall and by direction, flagging blind sectors
+// GET /api/stats/coverage?since=168h; since defaults to 7 days.
+type coverageStatsHandler struct {
+	repo    database.CoverageRepository
+	terrain []float64 // Line-of-sight range per degree of bearing, nil without a terrain model
+	cache   *QueryCache
+}
+
+// coverageStats is the /api/stats/coverage response
+type coverageStats struct {
+	SchemaVersion int       `json:"schema_version"`
+	From          time.Time `json:"from"`
+	*coverage.Summary
+}
+
+func (h *coverageStatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	period, ok := parsePeriod(w, r, defaultCoveragePeriod)
+	if !ok {
+		return
+	}
+	stats, err := h.cache.get(CacheCoverage, period.String(), func() (any, error) {
+		from := time.Now().Add(-period)
+		checks, err := h.repo.List(from)
+		if err != nil {
+			return nil, err
+		}
+		summary := coverage.Summarize(checks)
+		summary.Overlay(h.terrain)
+		return coverageStats{SchemaVersion: schema.APIVersion, From: from, Summary: summary}, nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
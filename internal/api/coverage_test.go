@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockCoverageRepository struct {
+	checks []*database.CoverageCheck
+	since  time.Time
+}
+
+func (m *mockCoverageRepository) Insert(check *database.CoverageCheck) error { return nil }
+
+func (m *mockCoverageRepository) List(since time.Time) ([]*database.CoverageCheck, error) {
+	m.since = since
+	return m.checks, nil
+}
+
+func TestCoverageStatsHandler(t *testing.T) {
+	repo := &mockCoverageRepository{checks: []*database.CoverageCheck{
+		{Expected: 20, Seen: 15, Sectors: []database.CoverageSector{{Bearing: 0, Expected: 8, Seen: 8}, {Bearing: 180, Expected: 12, Seen: 7}}},
+		{Expected: 10, Seen: 5, Sectors: []database.CoverageSector{{Bearing: 0, Expected: 5, Seen: 5}, {Bearing: 180, Expected: 5, Seen: 0}}},
+	}}
+	terrain := make([]float64, 360)
+	for b := range terrain {
+		terrain[b] = float64(b)
+	}
+	handler := &coverageStatsHandler{repo: repo, terrain: terrain}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stats/coverage?since=24h", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.WithinDuration(t, time.Now().Add(-24*time.Hour), repo.since, time.Minute)
+
+	var body struct {
+		Checks       int     `json:"checks"`
+		Completeness float64 `json:"completeness"`
+		Sectors      []struct {
+			Bearing      float64 `json:"bearing"`
+			Completeness float64 `json:"completeness"`
+			Blind        bool    `json:"blind"`
+			Theoretical  float64 `json:"theoretical"`
+		} `json:"sectors"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, 2, body.Checks)
+	assert.Equal(t, 66.7, body.Completeness)
+	require.Len(t, body.Sectors, 2)
+	assert.False(t, body.Sectors[0].Blind)
+	assert.Equal(t, 41.2, body.Sectors[1].Completeness)
+	assert.True(t, body.Sectors[1].Blind)
+	assert.Equal(t, 269.0, body.Sectors[1].Theoretical, "the farthest line-of-sight range within the sector")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stats/coverage?since=soon", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/privacy"
+)
+
+const defaultFeedPeriod = 7 * 24 * time.Hour
+
+// atomFeed is an Atom 1.0 feed (RFC 4287)
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID       string        `xml:"id"`
+	Title    string        `xml:"title"`
+	Updated  string        `xml:"updated"`
+	Category *atomCategory `xml:"category,omitempty"`
+	Content  string        `xml:"content"`
+
+	time time.Time // Orders the entries, newest first
+}
+
+// feedHandler serves recent station events as an Atom feed for feed readers, with a summary of each day's flights
+// GET /api/feed.atom?since=168h&types=alert,record; since defaults to 7 days and types to every event type. Daily
+// summaries cover the complete days in the period and are left out when the flights table isn't available.
+type feedHandler struct {
+	events  database.EventRepository
+	flights database.FlightRepository
+	privacy *privacy.Output
+}
+
+func (h *feedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	period, ok := parsePeriod(w, r, defaultFeedPeriod)
+	if !ok {
+		return
+	}
+	var types []string
+	if v := r.URL.Query().Get("types"); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				types = append(types, t)
+			}
+		}
+		if len(types) == 0 {
+			http.Error(w, "invalid types", http.StatusBadRequest)
+			return
+		}
+	}
+
+	now := time.Now()
+	since := now.Add(-period)
+	filter := database.EventFilter{Types: types, From: since}
+	page := database.PageRequest{Sort: "time", Descending: true, Limit: database.MaxPageSize}
+	events, _, err := h.events.QueryHistory(filter, page)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	events = privacy.Filter(events, h.privacy.Event)
+
+	var entries []atomEntry
+	for _, e := range events {
+		entries = append(entries, eventEntry(e))
+	}
+	if h.flights != nil {
+		summaries, err := h.dailySummaries(since, now)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		entries = append(entries, summaries...)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].time.After(entries[j].time) })
+
+	updated := now
+	if len(entries) > 0 {
+		updated = entries[0].time
+	}
+	feed := atomFeed{
+		ID:      "urn:flight_trmnl:feed",
+		Title:   "flight_trmnl station events",
+		Updated: updated.UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: "flight_trmnl"},
+		Link:    atomLink{Rel: "self", Href: requestURL(r)},
+		Entries: entries,
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(feed)
+}
+
+// dailySummaries returns an entry for each complete local day between since and now that had flights
+func (h *feedHandler) dailySummaries(since, now time.Time) ([]atomEntry, error) {
+	var entries []atomEntry
+	y, m, d := now.Date()
+	for end := time.Date(y, m, d, 0, 0, 0, 0, now.Location()); ; {
+		start := end.AddDate(0, 0, -1)
+		if start.Before(since) {
+			return entries, nil
+		}
+		summary, err := h.flights.Summary(start, end)
+		if err != nil {
+			return nil, err
+		}
+		if summary.Flights > 0 {
+			entries = append(entries, summaryEntry(start, end, summary))
+		}
+		end = start
+	}
+}
+
+// eventEntry is the feed entry of an event
+func eventEntry(e *models.Event) atomEntry {
+	return atomEntry{
+		ID:       "urn:flight_trmnl:event:" + strconv.FormatInt(e.ID, 10),
+		Title:    e.Message,
+		Updated:  e.Time.UTC().Format(time.RFC3339),
+		Category: &atomCategory{Term: e.Type},
+		Content:  eventDescription(e),
+		time:     e.Time,
+	}
+}
+
+// summaryEntry is the feed entry of the day from start to end, dated at its end
+func summaryEntry(start, end time.Time, s *database.FlightSummary) atomEntry {
+	content := fmt.Sprintf("%d flights by %d aircraft", s.Flights, s.Aircraft)
+	if s.MaxRange > 0 {
+		content += fmt.Sprintf(", farthest %.1f km", s.MaxRange)
+	}
+	content += fmt.Sprintf(", mean reception quality %.0f", s.MeanQuality)
+	return atomEntry{
+		ID:       "urn:flight_trmnl:summary:" + start.Format(time.DateOnly),
+		Title:    fmt.Sprintf("Daily summary for %s: %d flights", start.Format("Mon Jan 2"), s.Flights),
+		Updated:  end.UTC().Format(time.RFC3339),
+		Category: &atomCategory{Term: "summary"},
+		Content:  content,
+		time:     end,
+	}
+}
+
+// requestURL rebuilds the URL a request was made to, for links back to it
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
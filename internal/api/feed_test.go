@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/privacy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// summaryFlightRepository summarizes every day as the same traffic and remembers the days asked for
+type summaryFlightRepository struct {
+	mockFlightRepository
+	days []time.Time
+}
+
+func (m *summaryFlightRepository) Summary(from, to time.Time) (*database.FlightSummary, error) {
+	m.days = append(m.days, from)
+	return &database.FlightSummary{Flights: 120, Aircraft: 98, MaxRange: 231.46, MeanQuality: 74.2}, nil
+}
+
+func TestFeedHandler(t *testing.T) {
+	events := &mockEventRepository{events: []*models.Event{
+		{ID: 7, Time: time.Now().Add(-time.Hour), Type: models.EventRecord, ICAO: "A1B2C3", Message: "New highest: 45000 ft <FL450>"},
+		{ID: 6, Time: time.Now().Add(-30 * time.Hour), Type: models.EventAlert, ICAO: "A00001", Message: "Watched N1 in range"},
+	}}
+	flights := &summaryFlightRepository{}
+	blocklist := privacy.NewBlocklist([]string{"A00001"}, nil, nil)
+	handler := &feedHandler{events: events, flights: flights, privacy: blocklist.Output(privacy.PolicyExclude)}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/feed.atom?since=72h", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/atom+xml; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Nil(t, events.filter.Types, "every event type by default")
+	assert.WithinDuration(t, time.Now().Add(-72*time.Hour), events.filter.From, time.Minute)
+	assert.Len(t, flights.days, 2, "the complete days in the period")
+
+	var feed atomFeed
+	require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &feed))
+	assert.Equal(t, "http://example.com/api/feed.atom?since=72h", feed.Link.Href)
+	require.Len(t, feed.Entries, 3, "the blocked aircraft's alert is excluded")
+	assert.Equal(t, "urn:flight_trmnl:event:7", feed.Entries[0].ID)
+	assert.Equal(t, "New highest: 45000 ft <FL450>", feed.Entries[0].Title)
+	assert.Equal(t, models.EventRecord, feed.Entries[0].Category.Term)
+	assert.Equal(t, feed.Entries[0].Updated, feed.Updated)
+	for _, entry := range feed.Entries[1:] {
+		assert.Equal(t, "summary", entry.Category.Term)
+		assert.Contains(t, entry.Title, "120 flights")
+		assert.Equal(t, "120 flights by 98 aircraft, farthest 231.5 km, mean reception quality 74", entry.Content)
+	}
+	assert.Greater(t, feed.Entries[1].Updated, feed.Entries[2].Updated, "newest first")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/feed.atom?types=alert,record", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"alert", "record"}, events.filter.Types)
+
+	for _, query := range []string{"since=-1h", "types=,"} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/feed.atom?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/feed.atom", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/privacy"
+	"flight_trmnl/pkg/schema"
+)
+
+const defaultFleetLimit = 20
+
+// fleetHandler reports how much of each operator's fleet has been seen
+// GET /api/fleets lists the top operators (limit, default 20); GET /api/fleets/UAL lists one operator's fleet.
+type fleetHandler struct {
+	repo    database.FleetRepository
+	privacy *privacy.Output
+}
+
+func (h *fleetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if operator := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/fleets"), "/"); operator != "" {
+		report, err := h.repo.Fleet(operator)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if report == nil {
+			http.Error(w, "unknown operator", http.StatusNotFound)
+			return
+		}
+		// The fleet itself is public registry data, only whether a blocked aircraft was seen is hidden
+		for _, ac := range report.Aircraft {
+			if ac.Seen && h.privacy.Blocked(ac.ICAO) {
+				ac.Seen = false
+				report.Seen--
+			}
+		}
+		report.SeenPercent = database.SeenPercent(report.Seen, report.FleetSize)
+		writeJSON(w, http.StatusOK, struct {
+			SchemaVersion int `json:"schema_version"`
+			*database.FleetReport
+		}{schema.APIVersion, report})
+		return
+	}
+
+	limit := defaultFleetLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > database.MaxPageSize {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	fleets, err := h.repo.Operators(limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if fleets == nil {
+		fleets = []*database.OperatorFleet{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"schema_version": schema.APIVersion, "operators": fleets})
+}
+
+// blocksHandler counts seen aircraft per ICAO address block (state of registry)
+type blocksHandler struct {
+	repo database.FleetRepository
+}
+
+func (h *blocksHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	blocks, err := h.repo.Blocks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"schema_version": schema.APIVersion, "blocks": blocks})
+}
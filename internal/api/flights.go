@@ -0,0 +1,136 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/privacy"
+	"flight_trmnl/internal/report"
+	"flight_trmnl/pkg/schema"
+)
+
+const (
+	defaultFlightsPeriod = 24 * time.Hour
+	defaultFlightsLimit  = 100
+)
+
+// flightsHandler lists the visits aircraft made to the station with their reception quality
+// GET /api/flights?since=24h&icao=A05F21&min_quality=50&limit=100; since defaults to 24 hours and limit to 100.
+type flightsHandler struct {
+	repo    database.FlightRepository
+	privacy *privacy.Output
+}
+
+func (h *flightsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	period, ok := parsePeriod(w, r, defaultFlightsPeriod)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	limit := defaultFlightsLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > database.MaxPageSize {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	filter := database.FlightFilter{ICAO: strings.ToUpper(query.Get("icao")), Since: time.Now().Add(-period)}
+	if v := query.Get("min_quality"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > 100 {
+			http.Error(w, "invalid min_quality: must be 0-100", http.StatusBadRequest)
+			return
+		}
+		filter.MinQuality = n
+	}
+
+	flights, err := h.repo.List(filter, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	flights = privacy.Filter(flights, h.privacy.Flight)
+	if flights == nil {
+		flights = []*database.Flight{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"schema_version": schema.APIVersion,
+		"since":          filter.Since.UTC(),
+		"flights":        flights,
+	})
+}
+
+// defaultLogPeriod is how far back a sighting log reaches without a from parameter
+const defaultLogPeriod = 30 * 24 * time.Hour
+
+// sightingLogHandler serves the flights of a date range as spotters log them, for spreadsheets
+// GET /api/flights/log?from=...&to=...&format=csv; from defaults to 30 days before to, to to now, and format to json.
+type sightingLogHandler struct {
+	repo     database.FlightRepository
+	location string // Where the station is, as the log names it
+	privacy  *privacy.Output
+}
+
+func (h *sightingLogHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		http.Error(w, "invalid format "+format+": use json or csv", http.StatusBadRequest)
+		return
+	}
+	from, err := parseTimeParam(query, "from")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeParam(query, "to")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.Add(-defaultLogPeriod)
+	}
+
+	log, err := report.Log(h.repo, from, to, h.location)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Entries = privacy.Filter(log.Entries, h.privacy.LogEntry)
+	if format == "json" {
+		writeJSON(w, http.StatusOK, log)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="sightings-%s-%s.csv"`,
+		from.Local().Format("20060102"), to.Local().Format("20060102")))
+	if err := log.WriteCSV(w, time.Local); err != nil {
+		slog.Debug("Failed to write sighting log", "error", err)
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/privacy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockFlightRepository returns fixed flights and sightings and remembers the query
+type mockFlightRepository struct {
+	flights  []*database.Flight
+	log      []*database.LogEntry
+	filter   database.FlightFilter
+	limit    int
+	from, to time.Time
+}
+
+func (m *mockFlightRepository) Insert(flight *database.Flight) error { return nil }
+
+func (m *mockFlightRepository) List(filter database.FlightFilter, limit int) ([]*database.Flight, error) {
+	m.filter, m.limit = filter, limit
+	return m.flights, nil
+}
+
+func (m *mockFlightRepository) Summary(from, to time.Time) (*database.FlightSummary, error) {
+	return &database.FlightSummary{}, nil
+}
+
+func (m *mockFlightRepository) SightingLog(from, to time.Time) ([]*database.LogEntry, error) {
+	m.from, m.to = from, to
+	return m.log, nil
+}
+
+func TestFlightsHandler(t *testing.T) {
+	repo := &mockFlightRepository{flights: []*database.Flight{
+		{ICAO: "A05F21", Callsign: "UAL123", Messages: 4800, Quality: 87},
+		{ICAO: "A00001", Callsign: "N1", Messages: 40, Quality: 12},
+	}}
+	blocklist := privacy.NewBlocklist([]string{"A00001"}, nil, nil)
+	handler := &flightsHandler{repo: repo, privacy: blocklist.Output(privacy.PolicyAnonymize)}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/flights?since=2h&icao=a05f21&min_quality=50&limit=5", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "A05F21", repo.filter.ICAO)
+	assert.Equal(t, 50, repo.filter.MinQuality)
+	assert.WithinDuration(t, time.Now().Add(-2*time.Hour), repo.filter.Since, time.Minute)
+	assert.Equal(t, 5, repo.limit)
+
+	var body struct {
+		Flights []*database.Flight `json:"flights"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Flights, 2)
+	assert.Equal(t, 87, body.Flights[0].Quality)
+	assert.Equal(t, blocklist.Alias("A00001"), body.Flights[1].ICAO)
+	assert.Empty(t, body.Flights[1].Callsign)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/flights", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Zero(t, repo.filter.MinQuality)
+	assert.Equal(t, defaultFlightsLimit, repo.limit)
+
+	for _, query := range []string{"since=yesterday", "limit=0", "min_quality=101", "min_quality=good"} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/flights?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
+
+func TestSightingLogHandler(t *testing.T) {
+	seen := time.Date(2024, 5, 1, 12, 5, 0, 0, time.UTC)
+	repo := &mockFlightRepository{log: []*database.LogEntry{
+		{ICAO: "4840D6", Callsign: "KLM1023", FirstSeen: seen, Registration: "PH-BXA", TypeCode: "B738",
+			Type: "Boeing 737-8K2", Operator: "KLM"},
+		{ICAO: "A00001", Callsign: "N1", FirstSeen: seen, Registration: "N1", Type: "Cessna 172S", Operator: "Private"},
+	}}
+	blocklist := privacy.NewBlocklist([]string{"A00001"}, nil, nil)
+	handler := &sightingLogHandler{repo: repo, location: "Schiphol", privacy: blocklist.Output(privacy.PolicyAnonymize)}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/flights/log", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.WithinDuration(t, time.Now(), repo.to, time.Minute)
+	assert.Equal(t, defaultLogPeriod, repo.to.Sub(repo.from))
+
+	var body struct {
+		Location string               `json:"location"`
+		Entries  []*database.LogEntry `json:"entries"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "Schiphol", body.Location)
+	require.Len(t, body.Entries, 2)
+	assert.Equal(t, "PH-BXA", body.Entries[0].Registration)
+	assert.Equal(t, blocklist.Alias("A00001"), body.Entries[1].ICAO)
+	assert.Empty(t, body.Entries[1].Registration)
+	assert.Equal(t, "Cessna 172S", body.Entries[1].Type, "the type doesn't identify the aircraft")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet,
+		"/api/flights/log?from=2024-05-01T00:00:00Z&to=2024-05-02T00:00:00Z&format=csv", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), repo.from.UTC())
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), "attachment")
+	assert.Contains(t, rec.Body.String(), "date,time,registration,type,type_code,operator,callsign,icao,location\n")
+	assert.Contains(t, rec.Body.String(), ",PH-BXA,Boeing 737-8K2,B738,KLM,KLM1023,4840D6,Schiphol\n")
+
+	for _, query := range []string{"format=xls", "from=yesterday", "from=1714600000&to=1714500000"} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/flights/log?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
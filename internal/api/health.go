@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+
+	"flight_trmnl/internal/service"
+	"flight_trmnl/pkg/schema"
+)
+
+// healthHandler reports whether each service of the daemon is running and healthy
+// It responds 503 when any isn't, so it can back a container or load balancer health check.
+type healthHandler struct {
+	services *service.Group
+}
+
+func (h *healthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	health := h.services.Health()
+	healthy := true
+	for _, s := range health {
+		healthy = healthy && s.Healthy
+	}
+	status := http.StatusOK
+	if !healthy {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, map[string]any{
+		"schema_version": schema.APIVersion,
+		"healthy":        healthy,
+		"services":       health,
+	})
+}
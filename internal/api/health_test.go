@@ -0,0 +1,51 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthHandler(t *testing.T) {
+	var healthy error
+	group := service.NewGroup(time.Second)
+	group.Start(context.Background(), service.New("beast", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, func() error { return healthy }))
+	defer group.Stop()
+	handler := &healthHandler{services: group}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		Healthy  bool             `json:"healthy"`
+		Services []service.Health `json:"services"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.True(t, body.Healthy)
+	assert.Equal(t, []service.Health{{Name: "beast", State: service.StateRunning, Healthy: true}}, body.Services)
+
+	healthy = errors.New("not connected")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.False(t, body.Healthy)
+	assert.Equal(t, "not connected", body.Services[0].Error)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/health", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET", rec.Header().Get("Allow"))
+}
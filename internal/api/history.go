@@ -0,0 +1,297 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/privacy"
+	"flight_trmnl/pkg/schema"
+)
+
+// Fields selectable with ?fields= on each history endpoint, matching the JSON names of the records
+var (
+	messageFields = []string{"id", "timestamp", "ticks", "icao", "message_type", "signal_level", "signal_dbfs", "message_hex", "crc_error", "created_at",
+		"callsign", "category", "squawk", "altitude", "lat", "lon", "speed", "track", "heading", "vertical_rate",
+		"bds", "selected_altitude", "roll", "nic", "nacp", "sil"}
+	sightingFields = []string{"icao", "first_seen", "last_seen", "message_count", "callsign", "category", "source"}
+	eventFields    = []string{"id", "time", "type", "severity", "icao", "callsign", "message", "data"}
+)
+
+// historyPage is the response envelope shared by history endpoints
+type historyPage struct {
+	SchemaVersion int    `json:"schema_version"`
+	Data          []any  `json:"data"`
+	NextCursor    string `json:"next_cursor,omitempty"`
+}
+
+// messageHistoryHandler pages through stored Beast messages
+// Query parameters: icao, type, crc_error (true or false), min_nic, min_nacp, min_sil, from, to (RFC3339 or unix
+// seconds), sort, cursor, limit, fields.
+type messageHistoryHandler struct {
+	repo    database.BeastMessageRepository
+	privacy *privacy.Output
+}
+
+func (h *messageHistoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	page, fields, err := parseHistoryParams(query, messageFields)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter := database.MessageFilter{
+		ICAO:        strings.ToUpper(query.Get("icao")),
+		MessageType: query.Get("type"),
+		Receiver:    query.Get("receiver"),
+	}
+	if v := query.Get("crc_error"); v != "" {
+		corrupted, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "invalid crc_error", http.StatusBadRequest)
+			return
+		}
+		filter.CRCError = &corrupted
+	}
+	for _, p := range []struct {
+		name  string
+		max   int
+		value *int
+	}{{"min_nic", 11, &filter.MinNIC}, {"min_nacp", 11, &filter.MinNACp}, {"min_sil", 3, &filter.MinSIL}} {
+		if v := query.Get(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 || n > p.max {
+				http.Error(w, fmt.Sprintf("invalid %s %q: must be 0 to %d", p.name, v, p.max), http.StatusBadRequest)
+				return
+			}
+			*p.value = n
+		}
+	}
+	if filter.From, err = parseTimeParam(query, "from"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = parseTimeParam(query, "to"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	records, next, err := h.repo.QueryHistory(filter, page)
+	if err != nil {
+		// Query errors are caused by bad sort columns or cursors
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	records = privacy.Filter(records, h.privacy.Message)
+	writeHistoryPage(w, records, next, fields)
+}
+
+// sightingHistoryHandler pages through the seen aircraft history
+// Query parameters: from, to (RFC3339 or unix seconds), source, sort, cursor, limit, fields.
+type sightingHistoryHandler struct {
+	repo    database.SeenAircraftRepository
+	privacy *privacy.Output
+}
+
+func (h *sightingHistoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	page, fields, err := parseHistoryParams(query, sightingFields)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter := database.SightingFilter{Source: query.Get("source")}
+	if filter.SeenFrom, err = parseTimeParam(query, "from"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.SeenTo, err = parseTimeParam(query, "to"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sightings, next, err := h.repo.QueryHistory(filter, page)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sightings = privacy.Filter(sightings, h.privacy.Sighting)
+	writeHistoryPage(w, sightings, next, fields)
+}
+
+// eventHistoryHandler pages through recorded events
+// Query parameters: type, severity, icao, from, to (RFC3339 or unix seconds), sort, cursor, limit, fields.
+type eventHistoryHandler struct {
+	repo    database.EventRepository
+	privacy *privacy.Output
+}
+
+func (h *eventHistoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	page, fields, err := parseHistoryParams(query, eventFields)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter := database.EventFilter{
+		Type:     query.Get("type"),
+		Severity: query.Get("severity"),
+		ICAO:     strings.ToUpper(query.Get("icao")),
+	}
+	if filter.From, err = parseTimeParam(query, "from"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = parseTimeParam(query, "to"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, next, err := h.repo.QueryHistory(filter, page)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events = privacy.Filter(events, h.privacy.Event)
+	writeHistoryPage(w, events, next, fields)
+}
+
+// parseHistoryParams reads the sort, cursor, limit, and fields parameters common to history endpoints
+// sort takes a column name, prefixed with - for descending order (e.g. sort=-timestamp).
+func parseHistoryParams(query url.Values, allowed []string) (database.PageRequest, []string, error) {
+	page := database.PageRequest{Cursor: query.Get("cursor")}
+
+	if sort := query.Get("sort"); sort != "" {
+		page.Sort = strings.TrimPrefix(sort, "-")
+		page.Descending = strings.HasPrefix(sort, "-")
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return page, nil, fmt.Errorf("invalid limit %q: must be a positive integer", v)
+		}
+		if limit > database.MaxPageSize {
+			return page, nil, fmt.Errorf("limit %d exceeds the maximum of %d", limit, database.MaxPageSize)
+		}
+		page.Limit = limit
+	}
+
+	var fields []string
+	if v := query.Get("fields"); v != "" {
+		for _, field := range strings.Split(v, ",") {
+			field = strings.TrimSpace(field)
+			if !contains(allowed, field) {
+				return page, nil, fmt.Errorf("unknown field %q, valid fields: %s", field, strings.Join(allowed, ", "))
+			}
+			fields = append(fields, field)
+		}
+	}
+
+	return page, fields, nil
+}
+
+// parseTimeParam parses an RFC3339 or unix seconds query parameter; zero time when absent
+func parseTimeParam(query url.Values, name string) (time.Time, error) {
+	v := query.Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: use RFC3339 or unix seconds", name, v)
+	}
+	return t, nil
+}
+
+// writeHistoryPage writes a page of records, reduced to the requested fields when any were selected
+func writeHistoryPage[T any](w http.ResponseWriter, records []T, next string, fields []string) {
+	page := historyPage{SchemaVersion: schema.APIVersion, Data: make([]any, 0, len(records)), NextCursor: next}
+	for _, record := range records {
+		if len(fields) == 0 {
+			page.Data = append(page.Data, record)
+			continue
+		}
+		selected, err := selectFields(record, fields)
+		if err != nil {
+			http.Error(w, "failed to encode record", http.StatusInternalServerError)
+			return
+		}
+		page.Data = append(page.Data, selected)
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// selectFields picks fields by JSON name from a record
+func selectFields(record any, fields []string) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	selected := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if v, ok := all[field]; ok {
+			selected[field] = v
+		}
+	}
+	return selected, nil
+}
+
+func contains(values []string, v string) bool {
+	for _, candidate := range values {
+		if candidate == v {
+			return true
+		}
+	}
+	return false
+}
+
+// parsePeriod reads the since parameter as a duration, writing a 400 when it's invalid
+func parsePeriod(w http.ResponseWriter, r *http.Request, fallback time.Duration) (time.Duration, bool) {
+	v := r.URL.Query().Get("since")
+	if v == "" {
+		return fallback, true
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		http.Error(w, "invalid since", http.StatusBadRequest)
+		return 0, false
+	}
+	return d, true
+}
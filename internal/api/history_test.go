@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/privacy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockSightingRepository records the last history query and returns canned sightings
+type mockSightingRepository struct {
+	sightings []*models.Sighting
+	filter    database.SightingFilter
+	page      database.PageRequest
+}
+
+func (m *mockSightingRepository) UpsertBatch(sightings []*models.Sighting) error { return nil }
+
+func (m *mockSightingRepository) Get(icao string) (*models.Sighting, error) {
+	for _, s := range m.sightings {
+		if s.ICAO == icao {
+			return s, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockSightingRepository) Summary(since time.Time) (*database.SightingSummary, error) {
+	return &database.SightingSummary{}, nil
+}
+
+func (m *mockSightingRepository) QueryHistory(filter database.SightingFilter, page database.PageRequest) ([]*models.Sighting, string, error) {
+	m.filter, m.page = filter, page
+	return m.sightings, "next-page", nil
+}
+
+func TestSightingHistoryHandler(t *testing.T) {
+	repo := &mockSightingRepository{sightings: []*models.Sighting{
+		{ICAO: "A1B2C3", LastSeen: time.Unix(1714564800, 0).UTC(), MessageCount: 42, Callsign: "UAL1", Source: "live"},
+	}}
+	handler := &sightingHistoryHandler{repo: repo}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/history/aircraft?sort=-message_count&limit=10&fields=icao,message_count&from=1714564800", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Equal(t, database.PageRequest{Sort: "message_count", Descending: true, Limit: 10}, repo.page)
+	assert.Equal(t, int64(1714564800), repo.filter.SeenFrom.Unix())
+
+	var body struct {
+		Data       []map[string]any `json:"data"`
+		NextCursor string           `json:"next_cursor"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "next-page", body.NextCursor)
+	require.Len(t, body.Data, 1)
+	assert.Equal(t, map[string]any{"icao": "A1B2C3", "message_count": float64(42)}, body.Data[0])
+}
+
+func TestSightingHistoryHandler_Privacy(t *testing.T) {
+	repo := &mockSightingRepository{sightings: []*models.Sighting{
+		{ICAO: "A1B2C3", Callsign: "UAL1"},
+		{ICAO: "A00001", Callsign: "N1"},
+	}}
+	blocklist := privacy.NewBlocklist([]string{"A00001"}, nil, nil)
+
+	tests := []struct {
+		policy string
+		want   []string
+	}{
+		{privacy.PolicyShow, []string{"A1B2C3", "A00001"}},
+		{privacy.PolicyExclude, []string{"A1B2C3"}},
+		{privacy.PolicyAnonymize, []string{"A1B2C3", blocklist.Alias("A00001")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			handler := &sightingHistoryHandler{repo: repo, privacy: blocklist.Output(tt.policy)}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/history/aircraft", nil))
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			var body struct {
+				Data []models.Sighting `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			var icaos []string
+			for _, s := range body.Data {
+				icaos = append(icaos, s.ICAO)
+			}
+			assert.Equal(t, tt.want, icaos)
+		})
+	}
+}
+
+func TestSightingHistoryHandler_BadRequests(t *testing.T) {
+	handler := &sightingHistoryHandler{repo: &mockSightingRepository{}}
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"unknown field", "fields=icao,password"},
+		{"zero limit", "limit=0"},
+		{"limit over maximum", "limit=100000"},
+		{"bad time", "from=yesterday"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/history/aircraft?"+tt.query, nil))
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+
+	"flight_trmnl/internal/notify"
+	"flight_trmnl/pkg/schema"
+)
+
+// notifyStatsHandler reports delivery success for each notification webhook
+type notifyStatsHandler struct {
+	dispatcher *notify.Dispatcher
+}
+
+func (h *notifyStatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"schema_version": schema.APIVersion,
+		"webhooks":       h.dispatcher.Stats(),
+	})
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/privacy"
+	"flight_trmnl/internal/tracker"
+	"flight_trmnl/pkg/schema"
+)
+
+const (
+	maxPlaybackSpeed = 3600            // One hour of sky per second
+	maxPlaybackWait  = 5 * time.Second // Gaps while the station was down aren't replayed in full
+	playbackPageSize = 100
+)
+
+// playbackHandler replays stored tracker state snapshots as server-sent events
+// Query parameters: from (required), to (default now), speed (default 1, real time), and the live filters.
+// Each snapshot is sent as a "snapshot" event, paced by the time between snapshots divided by speed; "end" follows the last,
+// or "shutdown" ends the replay early when the daemon stops.
+type playbackHandler struct {
+	repo     database.StateSnapshotRepository
+	privacy  *privacy.Output
+	shutdown <-chan struct{} // Closed when the server stops
+}
+
+func (h *playbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter, err := tracker.ParseFilter(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := parseTimeParam(query, "from")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if from.IsZero() {
+		http.Error(w, "from is required", http.StatusBadRequest)
+		return
+	}
+	to, err := parseTimeParam(query, "to")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if to.IsZero() {
+		to = time.Now()
+	}
+
+	speed := 1.0
+	if v := query.Get("speed"); v != "" {
+		speed, err = strconv.ParseFloat(v, 64)
+		if err != nil || speed <= 0 || speed > maxPlaybackSpeed {
+			http.Error(w, fmt.Sprintf("invalid speed %q: must be greater than 0 and at most %d", v, maxPlaybackSpeed), http.StatusBadRequest)
+			return
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set(schema.VersionHeader, strconv.Itoa(schema.APIVersion))
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	var previous time.Time
+	for cursor := from; ; {
+		snapshots, err := h.repo.Range(cursor, to, playbackPageSize)
+		if err != nil {
+			fmt.Fprintf(w, "event: error\ndata: %q\n\n", err.Error())
+			flusher.Flush()
+			return
+		}
+
+		for _, snapshot := range snapshots {
+			if !previous.IsZero() {
+				wait := min(time.Duration(float64(snapshot.Time.Sub(previous))/speed), maxPlaybackWait)
+				select {
+				case <-r.Context().Done():
+					return
+				case <-h.shutdown:
+					writeShutdown(w, flusher)
+					return
+				case <-time.After(wait):
+				}
+			}
+			previous = snapshot.Time
+
+			if err := h.writeFrame(w, snapshot, filter); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+
+		if len(snapshots) < playbackPageSize {
+			break
+		}
+		// Range is inclusive of its start, snapshots are stored with nanosecond times
+		cursor = snapshots[len(snapshots)-1].Time.Add(time.Nanosecond)
+	}
+
+	fmt.Fprint(w, "event: end\ndata: {}\n\n")
+	flusher.Flush()
+}
+
+// writeFrame writes one snapshot as a "snapshot" event, reduced to the aircraft the filter and privacy policy allow
+func (h *playbackHandler) writeFrame(w http.ResponseWriter, snapshot *database.StateSnapshot, filter tracker.Filter) error {
+	var states []tracker.AircraftState
+	if err := json.Unmarshal(snapshot.Aircraft, &states); err != nil {
+		slog.Warn("Skipping unreadable state snapshot", "id", snapshot.ID, "error", err)
+		return nil
+	}
+
+	frame := schema.PlaybackFrame{SchemaVersion: schema.APIVersion, Time: snapshot.Time, Aircraft: make([]tracker.AircraftState, 0, len(states))}
+	for _, state := range states {
+		if !filter.Match(state) {
+			continue
+		}
+		if state, ok := h.privacy.State(state); ok {
+			frame.Aircraft = append(frame.Aircraft, state)
+		}
+	}
+
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: snapshot\ndata: %s\n\n", data)
+	return err
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/privacy"
+	"flight_trmnl/pkg/schema"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockSnapshotRepository serves snapshots from memory, honouring the range and limit
+type mockSnapshotRepository struct {
+	snapshots []*database.StateSnapshot
+	bands     []*database.BandCount
+	from, to  time.Time // Of the last band query
+}
+
+func (m *mockSnapshotRepository) Insert(snapshot *database.StateSnapshot) error { return nil }
+
+func (m *mockSnapshotRepository) Range(from, to time.Time, limit int) ([]*database.StateSnapshot, error) {
+	var found []*database.StateSnapshot
+	for _, s := range m.snapshots {
+		if !s.Time.Before(from) && s.Time.Before(to) && len(found) < limit {
+			found = append(found, s)
+		}
+	}
+	return found, nil
+}
+
+func (m *mockSnapshotRepository) DeleteBefore(t time.Time) (int64, error) { return 0, nil }
+
+func (m *mockSnapshotRepository) BandCounts(from, to time.Time) ([]*database.BandCount, int, error) {
+	m.from, m.to = from, to
+	return m.bands, len(m.snapshots), nil
+}
+
+// playbackEvents splits an SSE body into event names and data
+func playbackEvents(t *testing.T, body string) ([]string, []schema.PlaybackFrame) {
+	var names []string
+	var frames []schema.PlaybackFrame
+	for _, block := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		lines := strings.SplitN(block, "\n", 2)
+		require.Len(t, lines, 2)
+		name := strings.TrimPrefix(lines[0], "event: ")
+		names = append(names, name)
+		if name == "snapshot" {
+			var frame schema.PlaybackFrame
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &frame))
+			frames = append(frames, frame)
+		}
+	}
+	return names, frames
+}
+
+func TestPlaybackHandler(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	repo := &mockSnapshotRepository{}
+	for i := 0; i < playbackPageSize+2; i++ {
+		repo.snapshots = append(repo.snapshots, &database.StateSnapshot{
+			ID:       int64(i + 1),
+			Time:     start.Add(time.Duration(i) * 10 * time.Millisecond),
+			Aircraft: json.RawMessage(`[{"icao":"A1B2C3","signal_level":100},{"icao":"A00001","signal_level":20}]`),
+		})
+	}
+	blocklist := privacy.NewBlocklist([]string{"A00001"}, nil, nil)
+	handler := &playbackHandler{repo: repo, privacy: blocklist.Output(privacy.PolicyExclude)}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/playback?from=1714564800&to=1714564900&speed=100", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/event-stream", rec.Header().Get("Content-Type"))
+
+	names, frames := playbackEvents(t, rec.Body.String())
+	require.Len(t, frames, playbackPageSize+2, "replay pages through every snapshot")
+	assert.Equal(t, "end", names[len(names)-1])
+	assert.Equal(t, schema.APIVersion, frames[0].SchemaVersion)
+	assert.True(t, frames[0].Time.Equal(start))
+	require.Len(t, frames[0].Aircraft, 1, "blocked aircraft are excluded")
+	assert.Equal(t, "A1B2C3", frames[0].Aircraft[0].ICAO)
+}
+
+func TestPlaybackHandler_BadRequests(t *testing.T) {
+	handler := &playbackHandler{repo: &mockSnapshotRepository{}}
+	for _, query := range []string{"", "from=yesterday", "from=1714564800&speed=0", "from=1714564800&speed=100000", "from=1714564800&min_signal=abc"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/playback?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
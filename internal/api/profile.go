@@ -0,0 +1,225 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/links"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/privacy"
+	"flight_trmnl/internal/tracker"
+	"flight_trmnl/pkg/schema"
+)
+
+const (
+	defaultProfileTracks = 10
+	profileEvents        = 10
+	// trackGap splits an aircraft's messages into separate tracks, longer than a turnaround at a nearby airport
+	// is short enough to tell an outbound flight from the return
+	trackGap = 30 * time.Minute
+)
+
+var icaoPattern = regexp.MustCompile(`^[0-9A-F]{6}$`)
+
+// MetadataSource resolves registry details of an aircraft, such as the metadata resolver chain
+type MetadataSource interface {
+	Resolve(ctx context.Context, icao string) (*models.Aircraft, string, error)
+}
+
+// aircraftMetadata is the registry part of a profile
+type aircraftMetadata struct {
+	Registration string `json:"registration,omitempty"`
+	TypeCode     string `json:"type_code,omitempty"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Model        string `json:"model,omitempty"`
+	Operator     string `json:"operator,omitempty"`
+	OperatorICAO string `json:"operator_icao,omitempty"`
+	Owner        string `json:"owner,omitempty"`
+	Country      string `json:"country,omitempty"` // Country of registration
+	Built        string `json:"built,omitempty"`
+	Source       string `json:"source"` // Resolver that knew the aircraft
+}
+
+// profileStats summarizes how the station heard an aircraft over all its tracks
+type profileStats struct {
+	Messages          int64   `json:"messages"`
+	SecondsInRange    float64 `json:"seconds_in_range"`
+	MessagesPerFlight float64 `json:"messages_per_flight"`
+}
+
+// profileHandler aggregates everything known about one aircraft
+// GET /api/aircraft/{icao}/profile?tracks=10; tracks limits the recent tracks listed and defaults to 10.
+// Flights are counted from stored messages, so they stay empty in the state storage mode.
+type profileHandler struct {
+	sightings database.SeenAircraftRepository
+	messages  database.BeastMessageRepository // Optional
+	events    database.EventRepository        // Optional
+	tags      database.TagRepository          // Optional
+	metadata  MetadataSource                  // Optional
+	tracker   *tracker.Tracker                // Optional
+	links     *links.Generator                // Optional
+	photoURL  string
+	privacy   *privacy.Output
+}
+
+func (h *profileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rest, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/api/aircraft/"), "/profile")
+	if !ok || strings.Contains(rest, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	icao := strings.ToUpper(rest)
+	if !icaoPattern.MatchString(icao) {
+		http.Error(w, "invalid icao, expected 6 hex digits", http.StatusBadRequest)
+		return
+	}
+	// A profile names its aircraft, so blocked aircraft have none whatever the output policy
+	if h.privacy.Blocked(icao) {
+		http.Error(w, "unknown aircraft", http.StatusNotFound)
+		return
+	}
+
+	limit := defaultProfileTracks
+	if v := r.URL.Query().Get("tracks"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > database.MaxPageSize {
+			http.Error(w, "invalid tracks", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	sighting, err := h.sightings.Get(icao)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var live *tracker.AircraftState
+	if h.tracker != nil {
+		if state, ok := h.tracker.Get(icao); ok {
+			live = &state
+		}
+	}
+	var metadata *aircraftMetadata
+	if h.metadata != nil {
+		ac, source, err := h.metadata.Resolve(r.Context(), icao)
+		if err != nil {
+			slog.Debug("Metadata lookup failed", "icao", icao, "error", err)
+		} else if ac != nil {
+			metadata = newAircraftMetadata(ac, source)
+		}
+	}
+	tags := []*models.AircraftTag{}
+	if h.tags != nil {
+		if tags, err = h.tags.Get(icao); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if sighting == nil && live == nil && metadata == nil && len(tags) == 0 {
+		http.Error(w, "unknown aircraft", http.StatusNotFound)
+		return
+	}
+
+	tracks := &database.TrackHistory{Recent: []*database.Track{}}
+	if h.messages != nil {
+		if tracks, err = h.messages.Tracks(icao, trackGap, limit); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	events := []*models.Event{}
+	if h.events != nil {
+		events, _, err = h.events.QueryHistory(database.EventFilter{ICAO: icao},
+			database.PageRequest{Sort: "time", Descending: true, Limit: profileEvents})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	stats := profileStats{SecondsInRange: tracks.Seconds}
+	var firstSeen, lastSeen *time.Time
+	callsign := ""
+	if sighting != nil {
+		firstSeen, lastSeen, callsign = &sighting.FirstSeen, &sighting.LastSeen, sighting.Callsign
+		stats.Messages = sighting.MessageCount
+	}
+	if tracks.Count > 0 {
+		stats.MessagesPerFlight = float64(stats.Messages) / float64(tracks.Count)
+	}
+	if tags == nil {
+		tags = []*models.AircraftTag{}
+	}
+	registration := ""
+	if metadata != nil {
+		registration = metadata.Registration
+	}
+	if events == nil {
+		events = []*models.Event{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"schema_version": schema.APIVersion,
+		"icao":           icao,
+		"country":        models.CountryForICAO(icao), // Of the address block, the registration may differ
+		"callsign":       callsign,
+		"first_seen":     firstSeen,
+		"last_seen":      lastSeen,
+		"flight_count":   tracks.Count,
+		"live":           live,
+		"metadata":       metadata,
+		"tags":           tags,
+		"photo_url":      h.photo(icao, metadata),
+		"links":          h.links.For(links.Aircraft{ICAO: icao, Registration: registration, Callsign: callsign}),
+		"tracks":         tracks.Recent,
+		"recent_events":  events,
+		"stats":          stats,
+	})
+}
+
+// photo fills in the photo URL template, empty when it needs a registration that isn't known
+func (h *profileHandler) photo(icao string, metadata *aircraftMetadata) string {
+	if h.photoURL == "" {
+		return ""
+	}
+	registration := ""
+	if metadata != nil {
+		registration = metadata.Registration
+	}
+	if registration == "" && strings.Contains(h.photoURL, "{registration}") {
+		return ""
+	}
+	return strings.NewReplacer(
+		"{icao}", strings.ToLower(icao),
+		"{registration}", url.PathEscape(registration),
+	).Replace(h.photoURL)
+}
+
+func newAircraftMetadata(ac *models.Aircraft, source string) *aircraftMetadata {
+	return &aircraftMetadata{
+		Registration: ac.Registration,
+		TypeCode:     ac.TypeCode,
+		Manufacturer: ac.ManufacturerName,
+		Model:        ac.Model,
+		Operator:     ac.Operator,
+		OperatorICAO: ac.OperatorICAO,
+		Owner:        ac.Owner,
+		Country:      ac.Country,
+		Built:        ac.Built,
+		Source:       source,
+	}
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/links"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/privacy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockMetadata knows the registry details of a fixed set of aircraft
+type mockMetadata map[string]*models.Aircraft
+
+func (m mockMetadata) Resolve(ctx context.Context, icao string) (*models.Aircraft, string, error) {
+	return m[icao], "database", nil
+}
+
+// mockTrackRepository returns canned tracks; the other message queries aren't used by profiles
+type mockTrackRepository struct {
+	database.BeastMessageRepository
+	history *database.TrackHistory
+	icao    string
+	limit   int
+}
+
+func (m *mockTrackRepository) Tracks(icao string, gap time.Duration, limit int) (*database.TrackHistory, error) {
+	m.icao, m.limit = icao, limit
+	return m.history, nil
+}
+
+func newTestProfileHandler() (*profileHandler, *mockTrackRepository) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tracks := &mockTrackRepository{history: &database.TrackHistory{
+		Count:   4,
+		Seconds: 1200,
+		Recent:  []*database.Track{{Start: start, End: start.Add(5 * time.Minute), Messages: 300, MaxSignal: 120}},
+	}}
+	generator, err := links.New(links.Options{Trackers: []string{"adsbexchange", "fr24"}})
+	if err != nil {
+		panic(err)
+	}
+	return &profileHandler{
+		links: generator,
+		sightings: &mockSightingRepository{sightings: []*models.Sighting{
+			{ICAO: "4840D6", FirstSeen: start.Add(-48 * time.Hour), LastSeen: start, MessageCount: 1000, Callsign: "KLM1023"},
+		}},
+		messages: tracks,
+		metadata: mockMetadata{
+			"4840D6": {ICAO24: "4840D6", Registration: "PH-BXA", TypeCode: "B738", Operator: "KLM"},
+			"A00001": {ICAO24: "A00001", Registration: "N1"},
+		},
+		photoURL: "https://photos.example/{icao}?reg={registration}",
+	}, tracks
+}
+
+func TestProfileHandler(t *testing.T) {
+	handler, tracks := newTestProfileHandler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/aircraft/4840d6/profile?tracks=5", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "4840D6", tracks.icao)
+	assert.Equal(t, 5, tracks.limit)
+
+	var body struct {
+		ICAO        string            `json:"icao"`
+		Country     string            `json:"country"`
+		Callsign    string            `json:"callsign"`
+		FirstSeen   *time.Time        `json:"first_seen"`
+		FlightCount int               `json:"flight_count"`
+		Live        *json.RawMessage  `json:"live"`
+		Metadata    aircraftMetadata  `json:"metadata"`
+		PhotoURL    string            `json:"photo_url"`
+		Links       map[string]string `json:"links"`
+		Tracks      []database.Track  `json:"tracks"`
+		Tags        []any             `json:"tags"`
+		Stats       profileStats      `json:"stats"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "4840D6", body.ICAO)
+	assert.Equal(t, "Netherlands", body.Country)
+	assert.Equal(t, "KLM1023", body.Callsign)
+	require.NotNil(t, body.FirstSeen)
+	assert.Equal(t, 4, body.FlightCount)
+	assert.Nil(t, body.Live, "without a tracker the aircraft isn't live")
+	assert.Equal(t, "PH-BXA", body.Metadata.Registration)
+	assert.Equal(t, "database", body.Metadata.Source)
+	assert.Equal(t, "https://photos.example/4840d6?reg=PH-BXA", body.PhotoURL)
+	assert.Equal(t, "https://www.flightradar24.com/data/aircraft/PH-BXA", body.Links["fr24"])
+	require.Len(t, body.Tracks, 1)
+	assert.Equal(t, int64(300), body.Tracks[0].Messages)
+	assert.NotNil(t, body.Tags, "tags are an empty list, not null")
+	assert.Equal(t, profileStats{Messages: 1000, SecondsInRange: 1200, MessagesPerFlight: 250}, body.Stats)
+}
+
+func TestProfileHandler_Privacy(t *testing.T) {
+	handler, _ := newTestProfileHandler()
+	blocklist := privacy.NewBlocklist([]string{"A00001"}, nil, nil)
+
+	// Even anonymizing outputs have no profile to give, it would name the aircraft
+	for _, policy := range []string{privacy.PolicyAnonymize, privacy.PolicyExclude} {
+		handler.privacy = blocklist.Output(policy)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/aircraft/A00001/profile", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code, policy)
+	}
+
+	handler.privacy = blocklist.Output(privacy.PolicyShow)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/aircraft/A00001/profile", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestProfileHandler_BadRequests(t *testing.T) {
+	handler, _ := newTestProfileHandler()
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/api/aircraft/ABCDEF/profile", http.StatusNotFound}, // Nothing known
+		{"/api/aircraft/4840D6", http.StatusNotFound},
+		{"/api/aircraft/4840D6/profile/x", http.StatusNotFound},
+		{"/api/aircraft/XYZ/profile", http.StatusBadRequest},
+		{"/api/aircraft/4840D6/profile?tracks=0", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		assert.Equal(t, tt.want, rec.Code, tt.path)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/aircraft/4840D6/profile", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/dump1090"
+	"flight_trmnl/internal/links"
+	"flight_trmnl/internal/notify"
+	"flight_trmnl/internal/privacy"
+	"flight_trmnl/internal/service"
+	"flight_trmnl/internal/tasks"
+	"flight_trmnl/internal/tracker"
+)
+
+// Server serves the HTTP API and embedded web UI
+type Server struct {
+	httpServer *http.Server
+	mux        *http.ServeMux
+	shutdown   chan struct{} // Closed as the server stops, ending event streams
+}
+
+// drainTimeout is how long in-flight requests get to finish when the server stops
+const drainTimeout = 5 * time.Second
+
+// Options holds the API server address and the subsystems its endpoints read from
+// Endpoints whose dependency is nil are not registered.
+type Options struct {
+	Addr        string
+	Tracker     *tracker.Tracker
+	Messages    database.BeastMessageRepository
+	Sightings   database.SeenAircraftRepository
+	Events      database.EventRepository
+	Notify      *notify.Dispatcher
+	Fleets      database.FleetRepository
+	Snapshots   database.StateSnapshotRepository
+	Connections database.ConnectionRepository
+	Tags        database.TagRepository
+	Records     database.RecordRepository
+	Advisories  database.AdvisoryRepository
+	Flights     database.FlightRepository
+	Coverage    database.CoverageRepository
+	Terrain     []float64        // Line-of-sight range per degree of bearing for the coverage report, see terrain.Ranges
+	Tasks       *tasks.Scheduler // Background tasks that can be run on demand
+	TaskRuns    database.TaskRunRepository
+	Services    *service.Group   // Services of the daemon, for health checks
+	Metadata    MetadataSource   // Registry details for aircraft profiles
+	PhotoURL    string           // Photo page template for aircraft profiles, see config api.photo_url
+	Location    string           // Where the station is, as sighting logs name it, see report.Location
+	Links       *links.Generator // Deep links in aircraft profiles
+	Capture     CaptureSource    // Raw byte captures of the receiver, written to CaptureDir
+	CaptureDir  string
+	CatchUp     *dump1090.Replay // Backlog replayed before the live stream, nil without one
+	CORS        CORSOptions      // CORS is disabled when no origins are allowed
+	Privacy     *privacy.Output  // Hides or anonymizes blocked aircraft, nil publishes everything
+	Cache       *QueryCache      // Keeps aggregate statistics for a short time, nil queries them on every request
+}
+
+// NewServer creates an API server with the web UI mounted at /
+func NewServer(opts Options) (*Server, error) {
+	mux := http.NewServeMux()
+	shutdown := make(chan struct{})
+
+	assets, err := NewAssetHandler(webAssets())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load web assets: %w", err)
+	}
+	mux.Handle("/", assets)
+
+	if opts.Tracker != nil {
+		mux.Handle("/api/aircraft", &aircraftHandler{tracker: opts.Tracker, privacy: opts.Privacy})
+		mux.Handle("/api/stream", &streamHandler{tracker: opts.Tracker, privacy: opts.Privacy, shutdown: shutdown})
+		mux.Handle("/api/stats/spacing", &spacingStatsHandler{tracker: opts.Tracker, privacy: opts.Privacy})
+		mux.Handle("/api/stats/interrogators", &interrogatorStatsHandler{tracker: opts.Tracker})
+	}
+	if opts.Messages != nil {
+		mux.Handle("/api/history/messages", &messageHistoryHandler{repo: opts.Messages, privacy: opts.Privacy})
+		mux.Handle("/api/stats/receivers", &receiverStatsHandler{repo: opts.Messages, cache: opts.Cache})
+	}
+	if opts.Tracker != nil || opts.Messages != nil {
+		mux.Handle("/api/stats", &messageStatsHandler{tracker: opts.Tracker, repo: opts.Messages, cache: opts.Cache})
+	}
+	if opts.Tracker != nil || opts.Snapshots != nil {
+		mux.Handle("/api/stats/bands", &bandStatsHandler{tracker: opts.Tracker, repo: opts.Snapshots, cache: opts.Cache})
+	}
+	if opts.Records != nil {
+		mux.Handle("/api/stats/records", &recordStatsHandler{repo: opts.Records, privacy: opts.Privacy, cache: opts.Cache})
+	}
+	if opts.Coverage != nil {
+		mux.Handle("/api/stats/coverage", &coverageStatsHandler{repo: opts.Coverage, terrain: opts.Terrain, cache: opts.Cache})
+	}
+	if opts.Sightings != nil {
+		mux.Handle("/api/history/aircraft", &sightingHistoryHandler{repo: opts.Sightings, privacy: opts.Privacy})
+		mux.Handle("/api/aircraft/", &profileHandler{
+			sightings: opts.Sightings,
+			messages:  opts.Messages,
+			events:    opts.Events,
+			tags:      opts.Tags,
+			metadata:  opts.Metadata,
+			tracker:   opts.Tracker,
+			links:     opts.Links,
+			photoURL:  opts.PhotoURL,
+			privacy:   opts.Privacy,
+		})
+	}
+	if opts.Events != nil {
+		mux.Handle("/api/events", &eventHistoryHandler{repo: opts.Events, privacy: opts.Privacy})
+		mux.Handle("/api/events.ics", &calendarHandler{repo: opts.Events, privacy: opts.Privacy})
+		mux.Handle("/api/feed.atom", &feedHandler{events: opts.Events, flights: opts.Flights, privacy: opts.Privacy})
+	}
+	if opts.Snapshots != nil {
+		mux.Handle("/api/playback", &playbackHandler{repo: opts.Snapshots, privacy: opts.Privacy, shutdown: shutdown})
+	}
+	if opts.Fleets != nil {
+		fleets := &fleetHandler{repo: opts.Fleets, privacy: opts.Privacy}
+		mux.Handle("/api/fleets", fleets)
+		mux.Handle("/api/fleets/", fleets)
+		mux.Handle("/api/blocks", &blocksHandler{repo: opts.Fleets})
+	}
+	if opts.Connections != nil {
+		mux.Handle("/api/connections", &connectionsHandler{repo: opts.Connections})
+	}
+	if opts.Advisories != nil {
+		mux.Handle("/api/advisories", &advisoriesHandler{repo: opts.Advisories, privacy: opts.Privacy})
+	}
+	if opts.Flights != nil {
+		mux.Handle("/api/flights", &flightsHandler{repo: opts.Flights, privacy: opts.Privacy})
+		mux.Handle("/api/flights/log", &sightingLogHandler{repo: opts.Flights, location: opts.Location, privacy: opts.Privacy})
+	}
+	if opts.Capture != nil {
+		mux.Handle("/api/capture", &captureHandler{source: opts.Capture, dir: opts.CaptureDir})
+	}
+	if opts.CatchUp != nil {
+		mux.Handle("/api/catchup", &catchUpHandler{replay: opts.CatchUp})
+	}
+	if opts.Tasks != nil {
+		tasksHandler := &tasksHandler{scheduler: opts.Tasks, history: opts.TaskRuns}
+		mux.Handle("/api/tasks", tasksHandler)
+		mux.Handle("/api/tasks/", tasksHandler)
+	}
+	if opts.Services != nil {
+		mux.Handle("/api/health", &healthHandler{services: opts.Services})
+	}
+	if opts.Notify != nil {
+		mux.Handle("/api/notifications/stats", &notifyStatsHandler{dispatcher: opts.Notify})
+	}
+
+	var handler http.Handler = mux
+	if len(opts.CORS.AllowedOrigins) > 0 {
+		handler = newCORSHandler(opts.CORS, mux)
+	}
+
+	return &Server{
+		httpServer: &http.Server{
+			Addr:              opts.Addr,
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		mux:      mux,
+		shutdown: shutdown,
+	}, nil
+}
+
+// Handle registers an additional handler, e.g. API endpoints provided by other packages
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start serves requests until the context is cancelled
+// This method blocks. After cancellation the server stops accepting connections, event streams are ended with a
+// shutdown event, and in-flight requests get up to drainTimeout to finish before Start returns.
+func (s *Server) Start(ctx context.Context) error {
+	errChan := make(chan error, 1)
+	go func() {
+		slog.Info("API server listening", "addr", s.httpServer.Addr)
+		errChan <- s.httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errChan:
+		return fmt.Errorf("API server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	// Streams never finish on their own, Shutdown would wait the whole drain timeout for them
+	close(s.shutdown)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down API server: %w", err)
+	}
+
+	if err := <-errChan; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return ctx.Err()
+}
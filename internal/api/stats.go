@@ -0,0 +1,362 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/privacy"
+	"flight_trmnl/internal/tracker"
+	"flight_trmnl/pkg/schema"
+)
+
+// messageStatsHandler reports what the receiver hears, by downlink format and ADS-B type code
+// Query parameters: source (live counts since startup, or stored messages), from, to (stored only).
+type messageStatsHandler struct {
+	tracker *tracker.Tracker
+	repo    database.BeastMessageRepository
+	cache   *QueryCache
+}
+
+// messageStats is the /api/stats response
+type messageStats struct {
+	SchemaVersion int                         `json:"schema_version"`
+	Source        string                      `json:"source"`
+	Since         *time.Time                  `json:"since,omitempty"` // Start of live counting
+	Messages      models.MessageTypeBreakdown `json:"messages"`
+}
+
+func (h *messageStatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	source := query.Get("source")
+	if source == "" {
+		source = "live"
+		if h.tracker == nil {
+			source = "stored"
+		}
+	}
+
+	switch {
+	case source == "live" && h.tracker != nil:
+		breakdown, since := h.tracker.MessageTypes()
+		writeJSON(w, http.StatusOK, messageStats{SchemaVersion: schema.APIVersion, Source: source, Since: &since, Messages: breakdown})
+
+	case source == "stored" && h.repo != nil:
+		var filter database.MessageFilter
+		var err error
+		if filter.From, err = parseTimeParam(query, "from"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if filter.To, err = parseTimeParam(query, "to"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		breakdown, err := h.cache.get(CacheMessageStats, query.Encode(), func() (any, error) {
+			return h.repo.MessageTypes(filter)
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, messageStats{SchemaVersion: schema.APIVersion, Source: source, Messages: breakdown.(models.MessageTypeBreakdown)})
+
+	default:
+		http.Error(w, "unavailable source "+source+": use live or stored", http.StatusBadRequest)
+	}
+}
+
+// defaultBandWindow is how far back stored altitude band statistics reach without a from parameter
+const defaultBandWindow = 24 * time.Hour
+
+// bandStatsHandler reports how aircraft are spread over the altitude bands
+// Query parameters: source (live aircraft in range now, or stored state snapshots), from and to (stored only,
+// the last 24 hours by default).
+type bandStatsHandler struct {
+	tracker *tracker.Tracker
+	repo    database.StateSnapshotRepository
+	cache   *QueryCache
+}
+
+// bandStat is one altitude band of the /api/stats/bands response
+type bandStat struct {
+	Band     string  `json:"band"`     // surface, low, mid, high, or unknown
+	Aircraft int     `json:"aircraft"` // Distinct aircraft
+	Samples  int64   `json:"samples"`  // Aircraft summed over the snapshots, for live counts the aircraft in range
+	Percent  float64 `json:"percent"`  // Share of the samples, i.e. of the time aircraft spent in range
+}
+
+// bandStats is the /api/stats/bands response
+type bandStats struct {
+	SchemaVersion int        `json:"schema_version"`
+	Source        string     `json:"source"`
+	From          *time.Time `json:"from,omitempty"`
+	To            *time.Time `json:"to,omitempty"`
+	Snapshots     int        `json:"snapshots,omitempty"` // Stored snapshots rolled up
+	Bands         []bandStat `json:"bands"`
+}
+
+func (h *bandStatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	source := query.Get("source")
+	if source == "" {
+		source = "live"
+		if h.tracker == nil {
+			source = "stored"
+		}
+	}
+
+	switch {
+	case source == "live" && h.tracker != nil:
+		counts := make(map[string]*database.BandCount)
+		for _, state := range h.tracker.Snapshot() {
+			c, ok := counts[state.AltitudeBand]
+			if !ok {
+				c = &database.BandCount{Band: state.AltitudeBand}
+				counts[state.AltitudeBand] = c
+			}
+			c.Aircraft++
+			c.Samples++
+		}
+		writeJSON(w, http.StatusOK, bandStats{SchemaVersion: schema.APIVersion, Source: source, Bands: newBandStats(counts)})
+
+	case source == "stored" && h.repo != nil:
+		from, err := parseTimeParam(query, "from")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		to, err := parseTimeParam(query, "to")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if to.IsZero() {
+			to = time.Now()
+		}
+		if from.IsZero() {
+			from = to.Add(-defaultBandWindow)
+		}
+		// Keyed on the parameters as given, so the default window is cached too; it's at most a TTL behind
+		stats, err := h.cache.get(CacheBandStats, query.Encode(), func() (any, error) {
+			rows, snapshots, err := h.repo.BandCounts(from, to)
+			if err != nil {
+				return nil, err
+			}
+			counts := make(map[string]*database.BandCount)
+			for _, c := range rows {
+				counts[c.Band] = c
+			}
+			return bandStats{SchemaVersion: schema.APIVersion, Source: source, From: &from, To: &to,
+				Snapshots: snapshots, Bands: newBandStats(counts)}, nil
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, stats)
+
+	default:
+		http.Error(w, "unavailable source "+source+": use live or stored", http.StatusBadRequest)
+	}
+}
+
+// newBandStats lists every band from the ground up, then aircraft without a known altitude as unknown
+func newBandStats(counts map[string]*database.BandCount) []bandStat {
+	var total int64
+	for _, c := range counts {
+		total += c.Samples
+	}
+	stats := make([]bandStat, 0, len(models.AltitudeBands)+1)
+	for _, band := range append(append([]string(nil), models.AltitudeBands...), "") {
+		stat := bandStat{Band: band}
+		if band == "" {
+			stat.Band = "unknown"
+		}
+		if c, ok := counts[band]; ok {
+			stat.Aircraft, stat.Samples = c.Aircraft, c.Samples
+		}
+		if total > 0 {
+			stat.Percent = math.Round(float64(stat.Samples)*1000/float64(total)) / 10
+		}
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
+// recordStatsHandler lists the station records, highest, fastest, slowest airborne, and farthest, across all
+// aircraft and per ADS-B emitter category
+type recordStatsHandler struct {
+	repo    database.RecordRepository
+	privacy *privacy.Output
+	cache   *QueryCache
+}
+
+// recordStat is one station record of the /api/stats/records response
+type recordStat struct {
+	*database.StationRecord
+	CategoryName string `json:"category_name,omitempty"` // Description of the emitter category, e.g. Heavy
+	Unit         string `json:"unit"`                    // ft, kt, or km
+}
+
+// recordStats is the /api/stats/records response
+type recordStats struct {
+	SchemaVersion int          `json:"schema_version"`
+	Records       []recordStat `json:"records"`
+}
+
+func (h *recordStatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cached, err := h.cache.get(CacheRecords, "", func() (any, error) {
+		return h.repo.List()
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Filtered after the cache, so a reloaded blocklist applies right away; Filter copies what it changes
+	records := privacy.Filter(cached.([]*database.StationRecord), h.privacy.Record)
+
+	stats := make([]recordStat, 0, len(records))
+	for _, record := range records {
+		stats = append(stats, recordStat{StationRecord: record, CategoryName: models.CategoryName(record.Category), Unit: record.Unit()})
+	}
+	writeJSON(w, http.StatusOK, recordStats{SchemaVersion: schema.APIVersion, Records: stats})
+}
+
+// spacingStatsHandler annotates pairs of aircraft in trail on approach with their spacing and wake turbulence
+// categories, flagging those closer than the approach minimum for the pair
+type spacingStatsHandler struct {
+	tracker *tracker.Tracker
+	privacy *privacy.Output
+}
+
+// spacingStats is the /api/stats/spacing response
+type spacingStats struct {
+	SchemaVersion int               `json:"schema_version"`
+	Tight         int               `json:"tight"` // Pairs closer than their minimum spacing
+	Pairs         []tracker.Spacing `json:"pairs"` // Tightest first, relative to their minimum
+}
+
+func (h *spacingStatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Pairs are found among every aircraft, so an excluded one still counts as traffic ahead; pairs with an
+	// excluded aircraft are dropped afterwards
+	stats := spacingStats{SchemaVersion: schema.APIVersion, Pairs: []tracker.Spacing{}}
+	for _, pair := range tracker.ApproachSpacing(h.tracker.Snapshot()) {
+		var ok, followerOK bool
+		pair.Leader, ok = h.privacy.State(pair.Leader)
+		pair.Follower, followerOK = h.privacy.State(pair.Follower)
+		if !ok || !followerOK {
+			continue
+		}
+		if pair.Tight {
+			stats.Tight++
+		}
+		stats.Pairs = append(stats.Pairs, pair)
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+// interrogatorStatsHandler lists the secondary surveillance radars heard through the all-call replies they elicited
+type interrogatorStatsHandler struct {
+	tracker *tracker.Tracker
+}
+
+// interrogatorStats is the /api/stats/interrogators response
+type interrogatorStats struct {
+	SchemaVersion int                           `json:"schema_version"`
+	Since         time.Time                     `json:"since"` // Start of live counting
+	Interrogators []models.InterrogatorActivity `json:"interrogators"`
+}
+
+func (h *interrogatorStatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	activity, since := h.tracker.Interrogators()
+	writeJSON(w, http.StatusOK, interrogatorStats{SchemaVersion: schema.APIVersion, Since: since, Interrogators: activity})
+}
+
+// defaultReceiverWindow is how far back the receiver comparison reaches without a from parameter
+const defaultReceiverWindow = 24 * time.Hour
+
+// receiverStatsHandler compares what each configured receiver heard, from stored messages
+// Query parameters: from and to, the last 24 hours by default.
+type receiverStatsHandler struct {
+	repo  database.BeastMessageRepository
+	cache *QueryCache
+}
+
+// receiverStats is the /api/stats/receivers response
+type receiverStats struct {
+	SchemaVersion int                          `json:"schema_version"`
+	From          time.Time                    `json:"from"`
+	To            time.Time                    `json:"to"`
+	Receivers     []*database.ReceiverCoverage `json:"receivers"`
+}
+
+func (h *receiverStatsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	var filter database.MessageFilter
+	var err error
+	if filter.From, err = parseTimeParam(query, "from"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = parseTimeParam(query, "to"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.To.IsZero() {
+		filter.To = time.Now()
+	}
+	if filter.From.IsZero() {
+		filter.From = filter.To.Add(-defaultReceiverWindow)
+	}
+	// Keyed on the parameters as given, so the default window is cached too; it's at most a TTL behind
+	stats, err := h.cache.get(CacheReceiverStats, query.Encode(), func() (any, error) {
+		receivers, err := h.repo.Receivers(filter)
+		if err != nil {
+			return nil, err
+		}
+		return receiverStats{SchemaVersion: schema.APIVersion, From: filter.From, To: filter.To, Receivers: receivers}, nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/privacy"
+	"flight_trmnl/internal/tracker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageStatsHandler_Live(t *testing.T) {
+	tr := tracker.New(time.Minute)
+	tr.Update(trackedMessage("A1B2C3", 100))
+	tr.Update(trackedMessage("A1B2C3", 100))
+
+	handler := &messageStatsHandler{tracker: tr}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stats", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body messageStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "live", body.Source)
+	assert.NotNil(t, body.Since)
+	assert.Equal(t, int64(2), body.Messages.Total)
+	require.Len(t, body.Messages.Formats, 1)
+	assert.Equal(t, 17, body.Messages.Formats[0].Code)
+	assert.Equal(t, 100.0, body.Messages.Formats[0].Percent)
+}
+
+func TestMessageStatsHandler_UnavailableSource(t *testing.T) {
+	handler := &messageStatsHandler{tracker: tracker.New(time.Minute)}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stats?source=stored", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestBandStatsHandler_Live(t *testing.T) {
+	tr := tracker.New(time.Minute)
+	tr.Update(trackedMessage("A1B2C3", 100))
+	airborne := &models.BeastMessage{
+		MessageTypeCode: models.BeastTypeModeSLong,
+		Message:         []byte{0x8D, 0x40, 0x62, 0x1D, 0x58, 0xC3, 0x82, 0xD6, 0x90, 0xC8, 0xAC, 0x28, 0x63, 0xA7},
+		ICAO:            "40621D",
+	}
+	tr.Update(airborne)
+
+	handler := &bandStatsHandler{tracker: tr}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stats/bands", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body bandStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "live", body.Source)
+	assert.Equal(t, []bandStat{
+		{Band: "surface"},
+		{Band: "low"},
+		{Band: "mid"},
+		{Band: "high", Aircraft: 1, Samples: 1, Percent: 50},
+		{Band: "unknown", Aircraft: 1, Samples: 1, Percent: 50},
+	}, body.Bands)
+}
+
+func TestBandStatsHandler_Stored(t *testing.T) {
+	repo := &mockSnapshotRepository{
+		snapshots: make([]*database.StateSnapshot, 4),
+		bands: []*database.BandCount{
+			{Band: "low", Aircraft: 3, Samples: 30},
+			{Band: "mid", Aircraft: 2, Samples: 10},
+		},
+	}
+	handler := &bandStatsHandler{repo: repo}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stats/bands?to=1714564800", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, time.Unix(1714564800, 0).Add(-24*time.Hour), repo.from, "the last day by default")
+
+	var body bandStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, "stored", body.Source)
+	assert.Equal(t, 4, body.Snapshots)
+	require.Len(t, body.Bands, 5)
+	assert.Equal(t, bandStat{Band: "low", Aircraft: 3, Samples: 30, Percent: 75}, body.Bands[1])
+	assert.Equal(t, bandStat{Band: "mid", Aircraft: 2, Samples: 10, Percent: 25}, body.Bands[2])
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stats/bands?source=live", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "no tracker")
+}
+
+// mockReceiverRepository returns a canned receiver comparison; the other message queries aren't used by it
+type mockReceiverRepository struct {
+	database.BeastMessageRepository
+	receivers []*database.ReceiverCoverage
+	filter    database.MessageFilter
+}
+
+func (m *mockReceiverRepository) Receivers(filter database.MessageFilter) ([]*database.ReceiverCoverage, error) {
+	m.filter = filter
+	return m.receivers, nil
+}
+
+func TestReceiverStatsHandler(t *testing.T) {
+	repo := &mockReceiverRepository{receivers: []*database.ReceiverCoverage{
+		{Receiver: "", Messages: 1000, Aircraft: 40, UniqueAircraft: 5},
+		{Receiver: "roof", Messages: 3000, Aircraft: 60, UniqueAircraft: 25},
+	}}
+	handler := &receiverStatsHandler{repo: repo}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stats/receivers?to=1714564800", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, time.Unix(1714564800, 0).Add(-24*time.Hour), repo.filter.From, "the last day by default")
+
+	var body receiverStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, repo.receivers, body.Receivers)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stats/receivers?from=yesterday", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+type mockRecordRepository struct {
+	records []*database.StationRecord
+}
+
+func (m *mockRecordRepository) Offer(record *database.StationRecord) (bool, error) { return false, nil }
+
+func (m *mockRecordRepository) List() ([]*database.StationRecord, error) { return m.records, nil }
+
+func TestRecordStatsHandler(t *testing.T) {
+	repo := &mockRecordRepository{records: []*database.StationRecord{
+		{Category: database.RecordAllCategories, Kind: database.RecordHighest, Value: 43000, ICAO: "A00001", Callsign: "N1"},
+		{Category: "A5", Kind: database.RecordFastest, Value: 612, ICAO: "A1B2C3", Callsign: "UAE9"},
+		{Category: "A5", Kind: database.RecordFarthest, Value: 412, ICAO: "A1B2C3", Callsign: "UAE9"},
+	}}
+	blocklist := privacy.NewBlocklist([]string{"A00001"}, nil, nil)
+
+	handler := &recordStatsHandler{repo: repo, privacy: blocklist.Output(privacy.PolicyAnonymize)}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stats/records", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body recordStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Records, 3)
+	assert.Equal(t, blocklist.Alias("A00001"), body.Records[0].ICAO)
+	assert.Empty(t, body.Records[0].Callsign)
+	assert.Equal(t, "ft", body.Records[0].Unit)
+	assert.Empty(t, body.Records[0].CategoryName)
+	assert.Equal(t, "UAE9", body.Records[1].Callsign)
+	assert.Equal(t, "kt", body.Records[1].Unit)
+	assert.Equal(t, models.CategoryName("A5"), body.Records[1].CategoryName)
+	assert.Equal(t, database.RecordFarthest, body.Records[2].Kind)
+	assert.Equal(t, 412, body.Records[2].Value)
+	assert.Equal(t, "km", body.Records[2].Unit)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/stats/records", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestSpacingStatsHandler(t *testing.T) {
+	tr := tracker.New(time.Minute)
+	tr.Update(trackedMessage("A1B2C3", 100)) // No position, so never on approach
+
+	handler := &spacingStatsHandler{tracker: tr}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stats/spacing", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"schema_version": 1, "tight": 0, "pairs": []}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/stats/spacing", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestInterrogatorStatsHandler(t *testing.T) {
+	tr := tracker.New(time.Minute)
+	reply := []byte{0x5D, 0x48, 0x40, 0xD6, 0, 0, 0}
+	parity := models.ModeSCRC(reply[:4]) ^ 5 // Reply to II 5
+	reply[4], reply[5], reply[6] = byte(parity>>16), byte(parity>>8), byte(parity)
+	tr.Update(&models.BeastMessage{MessageTypeCode: models.BeastTypeModeSShort, Message: reply, ICAO: "4840D6"})
+
+	handler := &interrogatorStatsHandler{tracker: tr}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stats/interrogators", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body interrogatorStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body.Interrogators, 1)
+	assert.Equal(t, "II5", body.Interrogators[0].Name)
+	assert.Equal(t, int64(1), body.Interrogators[0].Replies)
+	assert.Equal(t, 1, body.Interrogators[0].Aircraft)
+}
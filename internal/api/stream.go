@@ -0,0 +1,193 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"flight_trmnl/internal/privacy"
+	"flight_trmnl/internal/tracker"
+	"flight_trmnl/pkg/schema"
+)
+
+const (
+	defaultStreamInterval = 1 * time.Second
+	minStreamInterval     = 250 * time.Millisecond
+	streamHeartbeat       = 15 * time.Second
+	shutdownRetry         = 10 * time.Second // How long clients wait before reconnecting after a shutdown event
+)
+
+// streamHandler streams tracker updates as server-sent events
+// SSE works over plain HTTP, so TRMNL-like devices and `curl -N` dashboards can follow live traffic.
+// Updates are coalesced per aircraft and flushed every interval seconds (query parameter, default 1).
+type streamHandler struct {
+	tracker  *tracker.Tracker
+	privacy  *privacy.Output
+	shutdown <-chan struct{} // Closed when the server stops
+}
+
+func (h *streamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := tracker.ParseFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	interval := defaultStreamInterval
+	if v := r.URL.Query().Get("interval"); v != "" {
+		seconds, err := strconv.ParseFloat(v, 64)
+		if err != nil || seconds <= 0 {
+			http.Error(w, fmt.Sprintf("invalid interval %q: must be a positive number of seconds", v), http.StatusBadRequest)
+			return
+		}
+		interval = max(time.Duration(seconds*float64(time.Second)), minStreamInterval)
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	sub := h.tracker.Subscribe(1000)
+	defer sub.Unsubscribe()
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set(schema.VersionHeader, strconv.Itoa(schema.APIVersion)) // Event data are bare aircraft states, without a version field
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no") // Disable proxy buffering (nginx) in front of the Pi
+	w.WriteHeader(http.StatusOK)
+
+	// Start with the current picture so clients don't wait for the next message from every aircraft
+	for _, state := range h.tracker.Snapshot() {
+		if !filter.Match(state) {
+			continue
+		}
+		if state, ok := h.privacy.State(state); ok {
+			if err := writeEvent(w, tracker.Update{Type: tracker.UpdateAircraft, Aircraft: state}); err != nil {
+				return
+			}
+		}
+	}
+	flusher.Flush()
+
+	pending := make(map[string]tracker.Update)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-h.shutdown:
+			writeShutdown(w, flusher)
+			return
+
+		case update, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if !filter.Match(update.Aircraft) {
+				continue
+			}
+			if state, ok := h.privacy.State(update.Aircraft); ok {
+				update.Aircraft = state
+				pending[state.ICAO] = update // Latest update per aircraft wins
+			}
+
+		case <-ticker.C:
+			if len(pending) == 0 {
+				continue
+			}
+			for icao, update := range pending {
+				if err := writeEvent(w, update); err != nil {
+					return
+				}
+				delete(pending, icao)
+			}
+			flusher.Flush()
+
+		case <-heartbeat.C:
+			// Comment lines keep idle connections from being closed by proxies
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes one SSE event named after the update type
+func writeEvent(w http.ResponseWriter, update tracker.Update) error {
+	data, err := json.Marshal(update.Aircraft)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", update.Type, data)
+	return err
+}
+
+// writeShutdown ends a stream with a shutdown event, asking EventSource clients to wait shutdownRetry before
+// reconnecting rather than retrying a stopped daemon at once
+func writeShutdown(w http.ResponseWriter, flusher http.Flusher) {
+	fmt.Fprintf(w, "retry: %d\nevent: shutdown\ndata: {}\n\n", shutdownRetry.Milliseconds())
+	flusher.Flush()
+}
+
+// aircraftHandler returns the current tracker state as JSON, accepting the same filters as the stream
+type aircraftHandler struct {
+	tracker *tracker.Tracker
+	privacy *privacy.Output
+}
+
+func (h *aircraftHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := tracker.ParseFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	aircraft := make([]tracker.AircraftState, 0)
+	for _, state := range h.tracker.Snapshot() {
+		if !filter.Match(state) {
+			continue
+		}
+		if state, ok := h.privacy.State(state); ok {
+			aircraft = append(aircraft, state)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, schema.AircraftList{
+		SchemaVersion: schema.APIVersion,
+		Now:           time.Now().Unix(),
+		Aircraft:      aircraft,
+	})
+}
+
+// writeJSON writes a JSON response body with the given status
+// Bodies are objects with a schema_version field, see pkg/schema.
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(schema.VersionHeader, strconv.Itoa(schema.APIVersion))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
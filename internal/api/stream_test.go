@@ -0,0 +1,132 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/tracker"
+	"flight_trmnl/pkg/schema"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func trackedMessage(icao string, signal uint8) *models.BeastMessage {
+	return &models.BeastMessage{
+		SignalLevel:     signal,
+		MessageTypeCode: models.BeastTypeModeSLong,
+		Message:         []byte{0x8D, 0x00, 0x00, 0x00},
+		ICAO:            icao,
+		MessageType:     "extended_squitter",
+	}
+}
+
+// readEvent reads one SSE event, skipping comment lines
+func readEvent(t *testing.T, r *bufio.Reader) (string, tracker.AircraftState) {
+	var event string
+	var state tracker.AircraftState
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &state))
+		case line == "" && event != "":
+			return event, state
+		}
+	}
+}
+
+func TestStreamHandler(t *testing.T) {
+	trk := tracker.New(time.Minute)
+	trk.Update(trackedMessage("A1B2C3", 100))
+	trk.Update(trackedMessage("ABCDEF", 100))
+
+	server := httptest.NewServer(&streamHandler{tracker: trk})
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?icao=A1B2C3&interval=0.25")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	reader := bufio.NewReader(resp.Body)
+
+	// Initial snapshot only contains the filtered aircraft
+	event, state := readEvent(t, reader)
+	assert.Equal(t, "update", event)
+	assert.Equal(t, "A1B2C3", state.ICAO)
+	assert.Equal(t, int64(1), state.Messages)
+
+	// Live updates are coalesced, the next event carries the latest state
+	trk.Update(trackedMessage("ABCDEF", 100))
+	trk.Update(trackedMessage("A1B2C3", 110))
+	trk.Update(trackedMessage("A1B2C3", 120))
+
+	event, state = readEvent(t, reader)
+	assert.Equal(t, "update", event)
+	assert.Equal(t, "A1B2C3", state.ICAO)
+	assert.Equal(t, int64(3), state.Messages)
+	assert.Equal(t, uint8(120), state.SignalLevel)
+}
+
+func TestStreamHandler_Shutdown(t *testing.T) {
+	trk := tracker.New(time.Minute)
+	trk.Update(trackedMessage("A1B2C3", 100))
+	shutdown := make(chan struct{})
+
+	server := httptest.NewServer(&streamHandler{tracker: trk, shutdown: shutdown})
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	event, _ := readEvent(t, reader)
+	assert.Equal(t, "update", event)
+
+	close(shutdown)
+	rest, err := io.ReadAll(reader)
+	require.NoError(t, err, "the stream ends")
+	assert.Equal(t, "retry: 10000\nevent: shutdown\ndata: {}\n\n", string(rest))
+}
+
+func TestStreamHandler_BadFilter(t *testing.T) {
+	h := &streamHandler{tracker: tracker.New(time.Minute)}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stream?min_signal=abc", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/stream?interval=-1", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestAircraftHandler(t *testing.T) {
+	trk := tracker.New(time.Minute)
+	trk.Update(trackedMessage("A1B2C3", 100))
+	trk.Update(trackedMessage("ABCDEF", 10))
+
+	rec := httptest.NewRecorder()
+	(&aircraftHandler{tracker: trk}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/aircraft?min_signal=50", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Equal(t, "1", rec.Header().Get(schema.VersionHeader))
+
+	var body schema.AircraftList
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Equal(t, schema.APIVersion, body.SchemaVersion)
+	require.Len(t, body.Aircraft, 1)
+	assert.Equal(t, "A1B2C3", body.Aircraft[0].ICAO)
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/tasks"
+	"flight_trmnl/pkg/schema"
+)
+
+const (
+	defaultTaskHistoryPeriod = 30 * 24 * time.Hour
+	defaultTaskRunsLimit     = 50
+)
+
+// tasksHandler lists the background tasks and runs them on demand
+// GET /api/tasks lists every task with its schedule and last run, and with stored history a summary of each
+// task's runs over since (default 30 days); GET /api/tasks/<name>/runs?since=720h&limit=50 lists a task's runs,
+// newest first. POST /api/tasks/<name> starts one now and responds 202 without waiting for it, or 409 when it
+// is already running.
+type tasksHandler struct {
+	scheduler *tasks.Scheduler
+	history   database.TaskRunRepository
+}
+
+func (h *tasksHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/tasks"), "/")
+	switch {
+	case name == "":
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.list(w, r)
+
+	case strings.HasSuffix(name, "/runs") && h.history != nil:
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.runs(w, r, strings.TrimSuffix(name, "/runs"))
+
+	default:
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.run(w, name)
+	}
+}
+
+func (h *tasksHandler) list(w http.ResponseWriter, r *http.Request) {
+	body := map[string]any{"schema_version": schema.APIVersion, "tasks": h.scheduler.Tasks()}
+	if h.history != nil {
+		period, ok := parsePeriod(w, r, defaultTaskHistoryPeriod)
+		if !ok {
+			return
+		}
+		since := time.Now().Add(-period)
+		summaries, err := h.history.Summary(since)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if summaries == nil {
+			summaries = []*database.TaskRunSummary{}
+		}
+		body["since"] = since.UTC()
+		body["history"] = summaries
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+func (h *tasksHandler) runs(w http.ResponseWriter, r *http.Request, name string) {
+	period, ok := parsePeriod(w, r, defaultTaskHistoryPeriod)
+	if !ok {
+		return
+	}
+	limit := defaultTaskRunsLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > database.MaxPageSize {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	since := time.Now().Add(-period)
+	runs, err := h.history.List(name, since, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if runs == nil {
+		runs = []*database.TaskRun{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"schema_version": schema.APIVersion, "since": since.UTC(), "runs": runs})
+}
+
+func (h *tasksHandler) run(w http.ResponseWriter, name string) {
+	status, err := h.scheduler.Run(name)
+	switch {
+	case errors.Is(err, tasks.ErrUnknownTask):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, tasks.ErrTaskRunning):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		writeJSON(w, http.StatusAccepted, map[string]any{"schema_version": schema.APIVersion, "task": status})
+	}
+}
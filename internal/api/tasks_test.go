@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/tasks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTasksHandler(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	scheduler := tasks.NewScheduler(0, nil)
+	scheduler.Add(tasks.Task{Name: "dataset", Run: func(ctx context.Context) error {
+		<-release
+		return nil
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go scheduler.Start(ctx)
+	handler := &tasksHandler{scheduler: scheduler}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/tasks", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list struct {
+		Tasks []tasks.TaskStatus `json:"tasks"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list.Tasks, 1)
+	assert.Equal(t, tasks.ScheduleOnDemand, list.Tasks[0].Schedule)
+
+	require.Eventually(t, func() bool {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/tasks/dataset", nil))
+		return rec.Code == http.StatusAccepted
+	}, time.Second, 5*time.Millisecond, "accepted once the scheduler is started")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/tasks/dataset", nil))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/tasks/purge", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/tasks/dataset", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "POST", rec.Header().Get("Allow"))
+}
+
+type mockTaskRunRepository struct {
+	runs []*database.TaskRun
+}
+
+func (m *mockTaskRunRepository) Insert(run *database.TaskRun) error { return nil }
+
+func (m *mockTaskRunRepository) List(task string, since time.Time, limit int) ([]*database.TaskRun, error) {
+	var runs []*database.TaskRun
+	for _, run := range m.runs {
+		if run.Task == task && !run.Started.Before(since) {
+			runs = append(runs, run)
+		}
+	}
+	return runs, nil
+}
+
+func (m *mockTaskRunRepository) Summary(since time.Time) ([]*database.TaskRunSummary, error) {
+	return []*database.TaskRunSummary{{Task: "tags", Runs: len(m.runs), SuccessPercent: 100}}, nil
+}
+
+func TestTasksHandler_History(t *testing.T) {
+	now := time.Now()
+	history := &mockTaskRunRepository{runs: []*database.TaskRun{
+		{Task: "tags", Trigger: tasks.ScheduleInterval, Started: now.Add(-time.Hour), Finished: now.Add(-time.Hour)},
+		{Task: "tags", Trigger: tasks.ScheduleStartup, Started: now.Add(-40 * 24 * time.Hour)},
+	}}
+	handler := &tasksHandler{scheduler: tasks.NewScheduler(0, nil), history: history}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/tasks", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var list struct {
+		Since   time.Time                 `json:"since"`
+		History []database.TaskRunSummary `json:"history"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	assert.WithinDuration(t, now.Add(-30*24*time.Hour), list.Since, time.Minute)
+	require.Len(t, list.History, 1)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/tasks/tags/runs", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var runs struct {
+		Runs []database.TaskRun `json:"runs"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &runs))
+	require.Len(t, runs.Runs, 1, "the last 30 days")
+	assert.Equal(t, tasks.ScheduleInterval, runs.Runs[0].Trigger)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/tasks/tags/runs?since=1000h", nil))
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &runs))
+	assert.Len(t, runs.Runs, 2)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/tasks/tags/runs?since=month", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,93 @@
+// Aircraft detail view: shows /api/aircraft/{icao}/profile, opened as aircraft.html?icao=4840D6
+(function () {
+  const form = document.getElementById('lookup');
+  const status = document.getElementById('status');
+  const profile = document.getElementById('profile');
+
+  function rows(target, items, columns) {
+    target.replaceChildren(...items.map(function (item) {
+      const row = document.createElement('tr');
+      columns(item).forEach(function (value) {
+        const cell = document.createElement('td');
+        cell.textContent = value;
+        row.appendChild(cell);
+      });
+      return row;
+    }));
+  }
+
+  function time(value) {
+    return value ? new Date(value).toLocaleString() : 'never';
+  }
+
+  function duration(seconds) {
+    const minutes = Math.round(seconds / 60);
+    return minutes < 120 ? minutes + ' min' : Math.round(minutes / 60) + ' h';
+  }
+
+  function render(p) {
+    const m = p.metadata || {};
+    document.getElementById('title').textContent = [p.icao, m.registration, m.type_code].filter(Boolean).join(' ');
+    const photo = document.getElementById('photo');
+    photo.hidden = !p.photo_url;
+    photo.firstElementChild.href = p.photo_url || '';
+    const links = document.getElementById('links');
+    links.replaceChildren(...Object.entries(p.links || {})
+      .filter(([name]) => name !== 'local')
+      .flatMap(([name, href]) => {
+        const a = document.createElement('a');
+        a.href = href;
+        a.target = '_blank';
+        a.rel = 'noopener';
+        a.textContent = name;
+        return [a, ' '];
+      }));
+
+    const details = [
+      ['Registration', m.registration],
+      ['Type', [m.manufacturer, m.model].filter(Boolean).join(' ')],
+      ['Operator', m.operator],
+      ['Owner', m.owner],
+      ['Registered in', m.country],
+      ['Address block', p.country],
+      ['Callsign', p.callsign],
+      ['In range now', p.live ? 'yes, signal ' + p.live.signal_dbfs + ' dBFS' : 'no'],
+      ['First seen', time(p.first_seen)],
+      ['Last seen', time(p.last_seen)],
+      ['Flights', p.flight_count],
+      ['Time in range', duration(p.stats.seconds_in_range)],
+      ['Messages', p.stats.messages],
+    ].filter(function (d) { return d[1] !== undefined && d[1] !== ''; });
+    rows(document.getElementById('details'), details, function (d) { return d; });
+    rows(document.getElementById('tags'), p.tags, function (t) { return [t.source, t.category, (t.tags || []).join(', ')]; });
+    rows(document.getElementById('tracks'), p.tracks, function (t) {
+      return [time(t.start), time(t.end), t.messages, t.max_signal, (t.positions || []).length];
+    });
+    rows(document.getElementById('events'), p.recent_events, function (e) { return [time(e.time), e.type, e.message]; });
+    profile.hidden = false;
+    status.textContent = m.source ? 'Registry details from ' + m.source + '.' : 'No registry details known.';
+  }
+
+  function load(icao) {
+    form.icao.value = icao;
+    profile.hidden = true;
+    status.textContent = 'Loading ' + icao + '...';
+    fetch('api/aircraft/' + encodeURIComponent(icao) + '/profile')
+      .then(function (resp) {
+        if (!resp.ok) throw new Error(resp.status === 404 ? 'nothing is known about ' + icao : resp.statusText);
+        return resp.json();
+      })
+      .then(render)
+      .catch(function (err) { status.textContent = 'Failed to load aircraft: ' + err.message; });
+  }
+
+  form.addEventListener('submit', function (e) {
+    e.preventDefault();
+    const icao = form.icao.value.trim().toUpperCase();
+    history.replaceState(null, '', '?icao=' + icao);
+    load(icao);
+  });
+
+  const icao = new URLSearchParams(location.search).get('icao');
+  if (icao) load(icao.toUpperCase());
+})();
@@ -0,0 +1,53 @@
+// Replay mode: streams /api/playback and renders each snapshot of the sky
+(function () {
+  const form = document.getElementById('replay');
+  const stop = document.getElementById('stop');
+  const status = document.getElementById('status');
+  const body = document.getElementById('aircraft');
+  let source = null;
+
+  function close(message) {
+    if (source) {
+      source.close();
+      source = null;
+    }
+    stop.disabled = true;
+    status.textContent = message;
+  }
+
+  function render(frame) {
+    status.textContent = new Date(frame.time).toLocaleString() + ' - ' + frame.aircraft.length + ' aircraft';
+    body.replaceChildren(...frame.aircraft.map(function (a) {
+      const row = document.createElement('tr');
+      const altitude = a.on_ground ? 'ground' : a.altitude != null ? a.altitude + ' ft' : '';
+      [a.icao, a.messages, a.signal_level, altitude, a.message_type, new Date(a.last_seen).toLocaleTimeString()].forEach(function (value) {
+        const cell = document.createElement('td');
+        cell.textContent = value;
+        row.appendChild(cell);
+      });
+      return row;
+    }));
+  }
+
+  form.addEventListener('submit', function (e) {
+    e.preventDefault();
+    close('Starting replay...');
+
+    const from = new Date(form.elements.from.value);
+    const to = new Date(from.getTime() + Number(form.elements.hours.value) * 3600 * 1000);
+    const params = new URLSearchParams({
+      from: Math.floor(from.getTime() / 1000),
+      to: Math.floor(to.getTime() / 1000),
+      speed: form.elements.speed.value,
+    });
+
+    source = new EventSource('api/playback?' + params);
+    stop.disabled = false;
+    source.addEventListener('snapshot', function (e) { render(JSON.parse(e.data)); });
+    source.addEventListener('end', function () { close('Replay finished.'); });
+    source.addEventListener('shutdown', function () { close('Replay stopped: the station is shutting down.'); });
+    source.onerror = function () { close('Replay stopped: connection lost.'); };
+  });
+
+  stop.addEventListener('click', function () { close('Replay stopped.'); });
+})();
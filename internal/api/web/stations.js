@@ -0,0 +1,56 @@
+// Station dashboard: polls /api/stations for availability and coverage of each station feeding the hub
+(function () {
+  const status = document.getElementById('status');
+  const body = document.getElementById('stations');
+  const pairs = document.getElementById('pairs');
+  const system = document.getElementById('system');
+
+  function rows(target, items, columns) {
+    target.replaceChildren(...items.map(function (item) {
+      const row = document.createElement('tr');
+      columns(item).forEach(function (value) {
+        const cell = document.createElement('td');
+        cell.textContent = value;
+        row.appendChild(cell);
+      });
+      return row;
+    }));
+  }
+
+  function state(s) {
+    if (!s.source) return 'revoked';
+    return s.online ? 'online' : 'offline';
+  }
+
+  function render(stations) {
+    status.textContent = stations.filter(function (s) { return s.online; }).length + ' of ' + stations.length + ' stations online';
+    rows(body, stations, function (s) {
+      const lastSeen = s.last_seen.startsWith('0001') ? 'never' : new Date(s.last_seen).toLocaleString();
+      return [s.name, state(s), s.availability + '%', s.aircraft, s.unique_aircraft, s.messages, s.duplicates,
+        s.rate_limit ? s.rate_limit + ' msg/s' : 'none', s.rate_limited, lastSeen];
+    });
+  }
+
+  function renderClocks(data) {
+    rows(pairs, data.pairs, function (p) {
+      return [p.a + ' / ' + p.b, p.quality, p.drift_ppm, p.offset_us, p.spread_us, p.samples, p.resets];
+    });
+    rows(system, data.system, function (c) { return [c.station, c.offset_ms, c.samples]; });
+  }
+
+  function refresh() {
+    fetch('api/stations')
+      .then(function (resp) {
+        if (!resp.ok) throw new Error(resp.status === 404 ? 'hub mode is not enabled' : resp.statusText);
+        return resp.json();
+      })
+      .then(function (data) { render(data.stations); })
+      .catch(function (err) { status.textContent = 'Failed to load stations: ' + err.message; });
+    fetch('api/stations/clocks')
+      .then(function (resp) { return resp.ok ? resp.json() : null; })
+      .then(function (data) { if (data) renderClocks(data); });
+  }
+
+  refresh();
+  setInterval(refresh, 10000);
+})();
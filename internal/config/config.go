@@ -2,38 +2,506 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/mail"
 	"os"
+	"regexp"
 	"strings"
+	"time"
+
+	"flight_trmnl/internal/crypt"
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/dbsync"
+	"flight_trmnl/internal/dump1090"
+	"flight_trmnl/internal/filter"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/secrets"
 
 	"github.com/spf13/viper"
 )
 
 // Config holds all configuration for the daemon
 type Config struct {
-	BeastAddr    string
-	DBPath       string
-	BatchSize    int
-	BatchTimeout int
-	Log          LogConfig
+	BeastAddr      string
+	BeastFormat    string // What the receiver at BeastAddr sends: beast, or avr for the hex text of port 30002
+	BeastClock     string // Format of the receiver's timestamps: 12mhz, a free-running counter, or gps for a Radarcape
+	BeastTransport TransportConfig
+	UDPInputs      []UDPInputConfig
+	SerialInputs   []SerialInputConfig
+	Receivers      []ReceiverConfig
+	CatchUpFile    string // Backlog of beast_addr's output replayed at full speed before streaming live
+	Replay         ReplayConfig
+	AircraftJSON   AircraftJSONConfig
+	DBPath         string
+	BatchSize      int
+	BatchTimeout   int
+	StorageMode    string   // raw, decoded, or state: how much of each received message is stored
+	OmitColumns    []string // beast_messages columns left empty when storing messages, see database.OmittableColumns
+	DropCorrupt    bool     // Drop messages failing the Mode S parity check instead of storing them flagged
+	Encryption     EncryptionConfig
+	Export         ExportConfig
+	Location       LocationConfig
+	Log            LogConfig
+	Metadata       MetadataConfig
+	API            APIConfig
+	Tracker        TrackerConfig
+	TRMNL          TRMNLConfig
+	Notify         NotifyConfig
+	Links          LinksConfig
+	Tags           TagsConfig
+	Privacy        PrivacyConfig
+	Dataset        DatasetConfig
+	Enrichment     EnrichmentConfig
+	Coverage       CoverageConfig
+	Scheduler      SchedulerConfig
+	Sync           SyncConfig
+	Station        StationConfig
+	Hub            HubConfig
+	Maintenance    MaintenanceConfig
+	DeepDive       DeepDiveConfig
+}
+
+// LocationConfig is where the receiver's antenna is, in decimal degrees; 0, 0 means not set
+type LocationConfig struct {
+	Name      string // Shown where sightings are listed, e.g. "Home"
+	Latitude  float64
+	Longitude float64
+}
+
+// IsSet reports whether a location was configured
+func (l LocationConfig) IsSet() bool {
+	return l.Latitude != 0 || l.Longitude != 0
+}
+
+// MaintenanceConfig holds the thresholds of maintenance alerts about the local receiver
+type MaintenanceConfig struct {
+	Enabled        bool
+	RateDrop       int    // Percent fewer messages than the same hour yesterday
+	MinBaseline    int    // Messages yesterday's hour needs before it is compared against
+	ParseErrorRate int    // Percent of frames failing to parse over 10 minutes
+	Silence        int    // Seconds without any message
+	CaptureDir     string // Where raw byte captures of the receiver are written
+}
+
+// DeepDiveConfig holds the settings of deep_dive reports on aircraft heard for the first time
+type DeepDiveConfig struct {
+	Enabled    bool
+	Wait       int // Seconds a new aircraft is followed before its report, unless it leaves first
+	MaxPerHour int // Reports per hour at most, 0 for no limit
 }
 
 // LogConfig holds logging configuration
 type LogConfig struct {
-	Level  string
-	Format string
+	Level    string
+	Format   string
+	CrashDir string // Where a diagnostic bundle is written when the daemon panics
+}
+
+// MetadataConfig holds aircraft metadata resolver configuration
+type MetadataConfig struct {
+	Resolvers       []string // Resolver names in lookup order (database, basestation, opensky, country)
+	CacheTTL        int      // Seconds to cache each resolver's results
+	BaseStationPath string   // Path to BaseStation.sqb, required by the basestation resolver
+	OpenSkyURL      string   // OpenSky metadata endpoint, the ICAO address is appended
+}
+
+// APIConfig holds HTTP API and web UI configuration
+type APIConfig struct {
+	Enabled  bool
+	Addr     string
+	PhotoURL string // Aircraft photo page linked from profiles, {icao} and {registration} are filled in; empty for none
+	CacheTTL int    // Seconds aggregate statistics are cached, 0 disables the cache
+	CORS     CORSConfig
+}
+
+// CORSConfig holds cross-origin settings for browser dashboards hosted elsewhere
+type CORSConfig struct {
+	AllowedOrigins []string // Origins allowed to call the API, * for any; empty disables CORS
+	AllowedHeaders []string // Extra request headers allowed in cross-origin requests
+	MaxAge         int      // Seconds browsers may cache preflight responses
+}
+
+// TrackerConfig holds live aircraft tracking configuration
+type TrackerConfig struct {
+	Expiry            int // Seconds without messages before an aircraft is dropped from the live view
+	SnapshotInterval  int // Seconds between stored snapshots of the tracker state, 0 disables snapshots
+	SnapshotRetention int // Days to keep snapshots, 0 keeps them forever
+}
+
+// TRMNLConfig holds TRMNL e-ink display configuration
+type TRMNLConfig struct {
+	LayoutsDir string // Directory of layout directories, reloaded when files change; empty for built-in layouts only
+	Profiles   []TRMNLProfileConfig
+}
+
+// TRMNLProfileConfig configures one TRMNL device or private plugin webhook
+type TRMNLProfileConfig struct {
+	Name            string            `mapstructure:"name"`
+	WebhookURL      string            `mapstructure:"webhook_url"`      // May reference ${ENV} variables
+	WebhookURLFile  string            `mapstructure:"webhook_url_file"` // File holding the webhook URL, instead of webhook_url
+	Layout          string            `mapstructure:"layout"`           // nearest, stats, special, comparison, or a layout from layouts_dir
+	RefreshInterval int               `mapstructure:"refresh_interval"` // Seconds between pushes
+	Favorites       []string          `mapstructure:"favorites"`        // ICAO addresses highlighted on this device
+	QR              bool              `mapstructure:"qr"`               // Also send the link as a QR code image (built-in layouts)
+	Filter          TRMNLFilterConfig `mapstructure:"filter"`
+}
+
+// TRMNLFilterConfig limits which aircraft a profile shows, same semantics as the API filters
+type TRMNLFilterConfig struct {
+	ICAO      []string `mapstructure:"icao"`
+	Type      []string `mapstructure:"type"`
+	Band      []string `mapstructure:"band"` // Altitude bands: surface, low, mid, high
+	MinSignal int      `mapstructure:"min_signal"`
+	Where     string   `mapstructure:"where"` // Filter expression over the aircraft fields, e.g. speed > 400
+}
+
+// NotifyConfig holds outbound event notification configuration
+type NotifyConfig struct {
+	Webhooks       []WebhookConfig
+	Retry          RetryConfig
+	DeadLetterPath string // JSON lines file receiving payloads that could not be delivered
+	Email          EmailConfig
+}
+
+// EmailConfig configures the SMTP server events and the weekly report are emailed through
+type EmailConfig struct {
+	Addr         string   // SMTP server host:port, empty disables email
+	TLS          bool     // Connect over TLS (port 465); otherwise STARTTLS is used when offered
+	Username     string   // Empty sends without logging in
+	Password     string   // May reference ${ENV} variables
+	PasswordFile string   // File holding the password, instead of password
+	From         string   // Sender, e.g. "flight_trmnl <pi@example.com>"
+	To           []string // Recipients
+	Types        []string // Event types to email, none when empty
+	MinSeverity  string   // info, warning, or critical
+	WeeklyReport WeeklyReportConfig
+}
+
+// WeeklyReportConfig schedules the weekly traffic report email
+type WeeklyReportConfig struct {
+	Enabled bool
+	Day     string // Weekday it is sent, e.g. monday
+	Hour    int    // Local hour it is sent, 0-23
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+// Weekday is the day the report is sent
+func (w WeeklyReportConfig) Weekday() time.Weekday {
+	return weekdays[strings.ToLower(w.Day)] // Checked by validation
 }
 
+// LinksConfig holds the deep links added to notifications and TRMNL screens
+type LinksConfig struct {
+	BaseURL  string            // This station's web UI as reached from phones, e.g. http://pi.local:8080; adds the local link
+	Trackers []string          // Built-in trackers to link to: adsbexchange, fr24, opensky, planefinder
+	Custom   map[string]string // Additional link templates by name, with {icao}, {registration}, and {callsign}
+	QR       string            // Link encoded in TRMNL QR codes, empty for local or else the first tracker
+}
+
+// WebhookConfig configures one webhook receiving events
+type WebhookConfig struct {
+	Name        string   `mapstructure:"name"`
+	URL         string   `mapstructure:"url"`          // May reference ${ENV} variables
+	URLFile     string   `mapstructure:"url_file"`     // File holding the URL, instead of url
+	Secret      string   `mapstructure:"secret"`       // HMAC-SHA256 signing key, unsigned when empty; may reference ${ENV} variables
+	SecretFile  string   `mapstructure:"secret_file"`  // File holding the signing key, instead of secret
+	Types       []string `mapstructure:"types"`        // Event types to send, all when empty
+	MinSeverity string   `mapstructure:"min_severity"` // info, warning, or critical
+
+	DedupeWindow      int    `mapstructure:"dedupe_window"`       // Seconds to suppress repeats of an event for the same flight, 0 disables
+	AggregateWindow   int    `mapstructure:"aggregate_window"`    // Seconds to collect a burst of events into one payload, 0 disables
+	DigestInterval    int    `mapstructure:"digest_interval"`     // Seconds between digests of low-priority events, 0 disables
+	DigestMaxSeverity string `mapstructure:"digest_max_severity"` // Highest severity batched into digests (default info)
+
+	Where string `mapstructure:"where"` // Filter expression over the event fields, e.g. severity == "critical"
+}
+
+// RetryConfig holds webhook retry settings
+type RetryConfig struct {
+	MaxAttempts    int // Total attempts including the first
+	InitialBackoff int // Seconds before the first retry, doubled for each retry after
+	MaxBackoff     int // Upper bound in seconds for the wait between retries
+}
+
+// TagsConfig holds special aircraft lists (e.g. plane-alert-db) imported on a schedule
+type TagsConfig struct {
+	RefreshInterval int // Hours between imports of each list
+	Lists           []TagListConfig
+}
+
+// TagListConfig configures one special aircraft list
+type TagListConfig struct {
+	Name string `mapstructure:"name"`
+	URL  string `mapstructure:"url"` // http(s) URL or local path of a CSV in plane-alert-db format
+}
+
+// PrivacyConfig holds aircraft hidden from public-facing outputs, e.g. FAA LADD participants
+// Blocked aircraft are still stored locally; each output applies its own policy.
+type PrivacyConfig struct {
+	Blocked    []string // ICAO addresses
+	BlockLists []string // Names of tags.lists whose aircraft are all blocked
+	Outputs    PrivacyOutputsConfig
+}
+
+// PrivacyOutputsConfig holds the policy of each output: show, anonymize, or exclude
+type PrivacyOutputsConfig struct {
+	API    string
+	TRMNL  string
+	Notify string
+}
+
+// DatasetConfig holds the bulk aircraft dataset loaded into an empty aircraft table
+type DatasetConfig struct {
+	Paths   []string          // Dataset files (.csv, .csv.gz, or .zip) in load order; empty for the bundled OpenSky files
+	Format  string            // Known dataset whose header names to expect: opensky or opensky-legacy
+	Columns map[string]string // Aircraft column to dataset header, overriding the format's names
+}
+
+// EnrichmentConfig holds the backfill of metadata for seen aircraft missing from the aircraft table
+type EnrichmentConfig struct {
+	Enabled     bool
+	Resolvers   []string // External resolvers to ask, in order (basestation, opensky)
+	Interval    int      // Seconds between backfill passes
+	LookupDelay int      // Milliseconds between lookups, keeps remote APIs from rate limiting the station
+	MaxAttempts int      // Failed lookups of an aircraft before giving up on it
+}
+
+// CoverageConfig holds the periodic comparison of the aircraft heard with an aggregator's within the same radius
+type CoverageConfig struct {
+	Enabled      bool
+	URL          string // Aggregator endpoint with {lat}, {lon}, and {dist} (nautical miles) placeholders
+	APIKey       string // May reference ${ENV} variables
+	APIKeyFile   string // File holding the API key, instead of api_key
+	APIKeyHeader string // Header the API key is sent in
+	Radius       int    // Kilometres around the receiver to compare
+	Interval     int    // Seconds between checks
+	Sectors      int    // Directions to split coverage into, 4 to 36
+
+	TerrainDir     string // Directory of SRTM .hgt tiles for the line-of-sight range model, empty to go without
+	AntennaHeight  int    // Metres above the ground
+	TargetAltitude int    // Feet, the altitude of the aircraft the range is modelled for
+}
+
+// SchedulerConfig holds how background tasks are spread out after startup
+type SchedulerConfig struct {
+	Jitter int // Most seconds a startup or first interval run is delayed at random, 0 starts them all at once
+}
+
+// SyncConfig copies new rows to a remote database on a schedule, enabled when To is set
+type SyncConfig struct {
+	To            string   // ssh://[user@]host[:port]/path of the remote database, or a local path
+	Source        string   // Name the remote keeps this database's progress under, defaults to the host name
+	Interval      int      // Seconds between syncs
+	RateLimit     int      // Kilobytes per second sent at most, 0 is unlimited
+	Tables        []string // Tables to copy, all that can be when empty
+	RemoteCommand string   // How to run flight_trmnl on the remote host
+}
+
+// EncryptionConfig encrypts the stored columns that reveal where the receiver is, enabled when Key is set
+type EncryptionConfig struct {
+	Key     string // 32 bytes as 64 hex digits or base64; may reference ${ENV} variables
+	KeyFile string // File holding the key, instead of key
+}
+
+// ExportConfig configures the export command
+type ExportConfig struct {
+	// Salt keys the hashes replacing aircraft addresses in anonymized exports; may reference ${ENV} variables. Keep
+	// it secret, anyone holding it can hash all 2^24 addresses and reverse them. A random salt is used when empty.
+	Salt     string
+	SaltFile string // File holding the salt, instead of salt
+}
+
+// StationConfig forwards received messages to a hub, enabled when HubURL is set
+type StationConfig struct {
+	HubURL    string // Base URL of the hub's ingest listener, e.g. https://hub.example.com:8443
+	Name      string // Station identity, defaults to the host name
+	Token     string // Must match the hub's token for Name; may reference ${ENV} variables
+	TokenFile string // File holding the token, instead of token
+	CAFile    string // Extra CA certificate to trust for the hub, e.g. a self-signed one
+	Where     string // Filter expression choosing the messages forwarded, e.g. df == 17; all when empty
+}
+
+// HubConfig accepts messages from stations into this instance's database and tracker
+type HubConfig struct {
+	Enabled     bool
+	Addr        string // Ingest listener address, separate from the API
+	PushAddr    string // Listener for Beast streams stations push, empty disables it
+	TLSCertFile string // Serves plain HTTP when empty, e.g. behind a TLS-terminating proxy
+	TLSKeyFile  string
+	RateLimit   int // Default messages per second per station, 0 is unlimited
+	Stations    []HubStationConfig
+}
+
+// TransportConfig is how beast_addr is reached when it isn't on the local network
+type TransportConfig struct {
+	SSH        string // ssh://[user@]host[:port] to tunnel through with the system's ssh
+	TLS        bool   // The receiver's port is wrapped in TLS
+	CAFile     string // Extra CA certificate to trust for it, e.g. a self-signed one
+	ServerName string // Name to verify its certificate against, when it isn't beast_addr's host
+}
+
+// ReplayConfig is a recording of receiver output read instead of beast_addr, e.g. to develop or test decoding offline
+type ReplayConfig struct {
+	File  string  // In beast_format and beast_clock, empty reads beast_addr
+	Speed float64 // Times the pace it was recorded at, 0 as fast as the pipeline takes it
+}
+
+// AircraftJSONConfig is a receiver's aircraft.json polled over HTTP, for a receiver whose TCP outputs aren't reachable
+type AircraftJSONConfig struct {
+	URL      string // e.g. http://raspberrypi.local/tar1090/data/aircraft.json, empty disables polling
+	Interval int    // Seconds between polls
+}
+
+// UDPInputConfig is a UDP port frames are forwarded to, alongside or instead of beast_addr
+type UDPInputConfig struct {
+	Addr     string `mapstructure:"addr"`     // Address to listen on, e.g. :30005
+	Format   string `mapstructure:"format"`   // beast or avr
+	Clock    string `mapstructure:"clock"`    // The senders' timestamp format, as beast_clock
+	Receiver string `mapstructure:"receiver"` // Tags its messages as receivers ids do, empty for none
+}
+
+// SerialInputConfig is a receiver on a serial port, e.g. a Mode-S Beast on USB, read without dump1090
+type SerialInputConfig struct {
+	Device   string `mapstructure:"device"`   // e.g. /dev/ttyUSB0
+	Baud     int    `mapstructure:"baud"`     // Bits per second
+	Format   string `mapstructure:"format"`   // beast or avr
+	Clock    string `mapstructure:"clock"`    // The receiver's timestamp format, as beast_clock
+	Receiver string `mapstructure:"receiver"` // Tags its messages as receivers ids do, empty for none
+}
+
+// ReceiverConfig is another receiver's Beast output read alongside beast_addr, its messages tagged with its ID
+type ReceiverConfig struct {
+	ID     string `mapstructure:"id"`     // Tags its messages, e.g. roof
+	Addr   string `mapstructure:"addr"`   // host:port
+	Format string `mapstructure:"format"` // beast or avr
+	Clock  string `mapstructure:"clock"`  // Its timestamp format, as beast_clock
+}
+
+// HubStationConfig is one station allowed to feed the hub
+type HubStationConfig struct {
+	Name      string `mapstructure:"name"`
+	Token     string `mapstructure:"token"`      // May reference ${ENV} variables
+	TokenFile string `mapstructure:"token_file"` // File holding the token, instead of token
+	RateLimit int    `mapstructure:"rate_limit"` // Messages per second, 0 uses hub.rate_limit
+}
+
+// minTRMNLRefresh keeps each webhook under TRMNL's limit of 12 requests an hour
+const minTRMNLRefresh = 300
+
+// validBands are the altitude bands filters accept, as in models.AltitudeBands
+var validBands = map[string]bool{"surface": true, "low": true, "mid": true, "high": true}
+
+// receiverIDPattern is what a receiver ID may hold, so it reads the same in logs, URLs and the database
+var receiverIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
 // Load loads configuration from config file and environment variables
 func Load() (*Config, error) {
 	v := viper.New()
 
 	// Set defaults
 	v.SetDefault("beast_addr", "raspberrypi.local:30006")
+	v.SetDefault("beast_format", "beast")
+	v.SetDefault("beast_clock", "12mhz")
+	v.SetDefault("beast_transport.ssh", "")
+	v.SetDefault("beast_transport.tls", false)
+	v.SetDefault("beast_transport.ca_file", "")
+	v.SetDefault("beast_transport.server_name", "")
+	v.SetDefault("catch_up_file", "")
+	v.SetDefault("replay.file", "")
+	v.SetDefault("replay.speed", 1)
+	v.SetDefault("aircraft_json.url", "")
+	v.SetDefault("aircraft_json.interval", 1)
 	v.SetDefault("db_path", "adsb_data.db")
 	v.SetDefault("batch_size", 100)
 	v.SetDefault("batch_timeout", 5)
+	v.SetDefault("storage_mode", "raw")
+	v.SetDefault("drop_corrupt", false)
+	v.SetDefault("location.name", "")
+	v.SetDefault("location.latitude", 0)
+	v.SetDefault("location.longitude", 0)
 	v.SetDefault("log.level", "info")
 	v.SetDefault("log.format", "text")
+	v.SetDefault("log.crash_dir", "crash_reports")
+	v.SetDefault("tracker.expiry", 60)
+	v.SetDefault("tracker.snapshot_interval", 0)
+	v.SetDefault("tracker.snapshot_retention", 7)
+	v.SetDefault("api.enabled", false)
+	v.SetDefault("api.addr", ":8080")
+	v.SetDefault("api.photo_url", "https://www.planespotters.net/hex/{icao}")
+	v.SetDefault("api.cache_ttl", 30)
+	v.SetDefault("api.cors.allowed_origins", []string{})
+	v.SetDefault("api.cors.allowed_headers", []string{})
+	v.SetDefault("api.cors.max_age", 600)
+	v.SetDefault("trmnl.layouts_dir", "")
+	v.SetDefault("notify.retry.max_attempts", 5)
+	v.SetDefault("notify.retry.initial_backoff", 2)
+	v.SetDefault("notify.retry.max_backoff", 300)
+	v.SetDefault("notify.dead_letter_path", "notify_dead_letter.jsonl")
+	v.SetDefault("notify.email.addr", "")
+	v.SetDefault("notify.email.tls", false)
+	v.SetDefault("notify.email.username", "")
+	v.SetDefault("notify.email.password", "")
+	v.SetDefault("notify.email.password_file", "")
+	v.SetDefault("notify.email.from", "")
+	v.SetDefault("notify.email.to", []string{})
+	v.SetDefault("notify.email.types", []string{})
+	v.SetDefault("notify.email.min_severity", "info")
+	v.SetDefault("notify.email.weekly_report.enabled", false)
+	v.SetDefault("notify.email.weekly_report.day", "monday")
+	v.SetDefault("notify.email.weekly_report.hour", 8)
+	v.SetDefault("links.base_url", "")
+	v.SetDefault("links.trackers", []string{"adsbexchange"})
+	v.SetDefault("links.custom", map[string]string{})
+	v.SetDefault("links.qr", "")
+	v.SetDefault("tags.refresh_interval", 24)
+	v.SetDefault("privacy.blocked", []string{})
+	v.SetDefault("privacy.block_lists", []string{})
+	v.SetDefault("privacy.outputs.api", "exclude")
+	v.SetDefault("privacy.outputs.trmnl", "exclude")
+	v.SetDefault("privacy.outputs.notify", "exclude")
+	v.SetDefault("dataset.paths", []string{})
+	v.SetDefault("dataset.format", "opensky")
+	v.SetDefault("enrichment.enabled", false)
+	v.SetDefault("enrichment.resolvers", []string{"opensky"})
+	v.SetDefault("enrichment.interval", 3600)
+	v.SetDefault("enrichment.lookup_delay", 1000)
+	v.SetDefault("enrichment.max_attempts", 3)
+	v.SetDefault("coverage.enabled", false)
+	v.SetDefault("coverage.url", "https://adsbexchange.com/api/aircraft/v2/lat/{lat}/lon/{lon}/dist/{dist}/")
+	v.SetDefault("coverage.api_key_header", "api-auth")
+	v.SetDefault("coverage.radius", 150)
+	v.SetDefault("coverage.interval", 900)
+	v.SetDefault("coverage.sectors", 8)
+	v.SetDefault("coverage.terrain_dir", "")
+	v.SetDefault("coverage.antenna_height", 10)
+	v.SetDefault("coverage.target_altitude", 35000)
+	v.SetDefault("scheduler.jitter", 60)
+	v.SetDefault("sync.to", "")
+	v.SetDefault("sync.interval", 3600)
+	v.SetDefault("sync.rate_limit", 0)
+	v.SetDefault("sync.remote_command", "flight_trmnl")
+	v.SetDefault("station.hub_url", "")
+	v.SetDefault("hub.enabled", false)
+	v.SetDefault("hub.addr", ":8443")
+	v.SetDefault("hub.push_addr", "")
+	v.SetDefault("hub.rate_limit", 0)
+	v.SetDefault("maintenance.enabled", true)
+	v.SetDefault("maintenance.rate_drop", 90)
+	v.SetDefault("maintenance.min_baseline", 1000)
+	v.SetDefault("maintenance.parse_error_rate", 5)
+	v.SetDefault("maintenance.silence", 600)
+	v.SetDefault("maintenance.capture_dir", "captures")
+	v.SetDefault("deep_dive.enabled", false)
+	v.SetDefault("deep_dive.wait", 300)
+	v.SetDefault("deep_dive.max_per_hour", 10)
+	v.SetDefault("metadata.resolvers", []string{"database", "country"})
+	v.SetDefault("metadata.cache_ttl", 3600)
+	v.SetDefault("metadata.basestation_path", "")
+	v.SetDefault("metadata.opensky_url", "https://opensky-network.org/api/metadata/aircraft/icao/")
 
 	// Set config file name and type
 	v.SetConfigName("config")
@@ -60,10 +528,9 @@ func Load() (*Config, error) {
 		}
 		// Config file not found is OK - we'll use defaults + env vars
 		// Don't log here since logger isn't initialized yet
-	} else {
-		// Config file was loaded successfully
-		// Don't log here since logger isn't initialized yet
-		_ = v.ConfigFileUsed() // Store for potential future use
+	} else if err := checkFile(v.ConfigFileUsed()); err != nil {
+		// Config file was loaded successfully, but viper ignores unknown keys and mistyped values
+		return nil, fmt.Errorf("invalid configuration:\n%w", err)
 	}
 
 	// Set environment variable prefix
@@ -73,14 +540,292 @@ func Load() (*Config, error) {
 
 	// Build config struct
 	cfg := &Config{
-		BeastAddr:    v.GetString("beast_addr"),
+		BeastAddr:   v.GetString("beast_addr"),
+		BeastFormat: v.GetString("beast_format"),
+		BeastClock:  v.GetString("beast_clock"),
+		BeastTransport: TransportConfig{
+			SSH:        v.GetString("beast_transport.ssh"),
+			TLS:        v.GetBool("beast_transport.tls"),
+			CAFile:     v.GetString("beast_transport.ca_file"),
+			ServerName: v.GetString("beast_transport.server_name"),
+		},
+		CatchUpFile: v.GetString("catch_up_file"),
+		Replay: ReplayConfig{
+			File:  v.GetString("replay.file"),
+			Speed: v.GetFloat64("replay.speed"),
+		},
+		AircraftJSON: AircraftJSONConfig{
+			URL:      v.GetString("aircraft_json.url"),
+			Interval: v.GetInt("aircraft_json.interval"),
+		},
 		DBPath:       v.GetString("db_path"),
 		BatchSize:    v.GetInt("batch_size"),
 		BatchTimeout: v.GetInt("batch_timeout"),
+		StorageMode:  v.GetString("storage_mode"),
+		OmitColumns:  v.GetStringSlice("omit_columns"),
+		DropCorrupt:  v.GetBool("drop_corrupt"),
+		Encryption: EncryptionConfig{
+			Key:     v.GetString("encryption.key"),
+			KeyFile: v.GetString("encryption.key_file"),
+		},
+		Export: ExportConfig{
+			Salt:     v.GetString("export.salt"),
+			SaltFile: v.GetString("export.salt_file"),
+		},
+		Location: LocationConfig{
+			Name:      v.GetString("location.name"),
+			Latitude:  v.GetFloat64("location.latitude"),
+			Longitude: v.GetFloat64("location.longitude"),
+		},
 		Log: LogConfig{
-			Level:  v.GetString("log.level"),
-			Format: v.GetString("log.format"),
+			Level:    v.GetString("log.level"),
+			Format:   v.GetString("log.format"),
+			CrashDir: v.GetString("log.crash_dir"),
+		},
+		Metadata: MetadataConfig{
+			Resolvers:       v.GetStringSlice("metadata.resolvers"),
+			CacheTTL:        v.GetInt("metadata.cache_ttl"),
+			BaseStationPath: v.GetString("metadata.basestation_path"),
+			OpenSkyURL:      v.GetString("metadata.opensky_url"),
+		},
+		API: APIConfig{
+			Enabled:  v.GetBool("api.enabled"),
+			Addr:     v.GetString("api.addr"),
+			PhotoURL: v.GetString("api.photo_url"),
+			CacheTTL: v.GetInt("api.cache_ttl"),
+			CORS: CORSConfig{
+				AllowedOrigins: v.GetStringSlice("api.cors.allowed_origins"),
+				AllowedHeaders: v.GetStringSlice("api.cors.allowed_headers"),
+				MaxAge:         v.GetInt("api.cors.max_age"),
+			},
+		},
+		Tracker: TrackerConfig{
+			Expiry:            v.GetInt("tracker.expiry"),
+			SnapshotInterval:  v.GetInt("tracker.snapshot_interval"),
+			SnapshotRetention: v.GetInt("tracker.snapshot_retention"),
+		},
+		TRMNL: TRMNLConfig{
+			LayoutsDir: v.GetString("trmnl.layouts_dir"),
+		},
+		Notify: NotifyConfig{
+			Retry: RetryConfig{
+				MaxAttempts:    v.GetInt("notify.retry.max_attempts"),
+				InitialBackoff: v.GetInt("notify.retry.initial_backoff"),
+				MaxBackoff:     v.GetInt("notify.retry.max_backoff"),
+			},
+			DeadLetterPath: v.GetString("notify.dead_letter_path"),
+			Email: EmailConfig{
+				Addr:         v.GetString("notify.email.addr"),
+				TLS:          v.GetBool("notify.email.tls"),
+				Username:     v.GetString("notify.email.username"),
+				Password:     v.GetString("notify.email.password"),
+				PasswordFile: v.GetString("notify.email.password_file"),
+				From:         v.GetString("notify.email.from"),
+				To:           v.GetStringSlice("notify.email.to"),
+				Types:        v.GetStringSlice("notify.email.types"),
+				MinSeverity:  v.GetString("notify.email.min_severity"),
+				WeeklyReport: WeeklyReportConfig{
+					Enabled: v.GetBool("notify.email.weekly_report.enabled"),
+					Day:     v.GetString("notify.email.weekly_report.day"),
+					Hour:    v.GetInt("notify.email.weekly_report.hour"),
+				},
+			},
+		},
+		Links: LinksConfig{
+			BaseURL:  v.GetString("links.base_url"),
+			Trackers: v.GetStringSlice("links.trackers"),
+			Custom:   v.GetStringMapString("links.custom"),
+			QR:       v.GetString("links.qr"),
+		},
+		Tags: TagsConfig{
+			RefreshInterval: v.GetInt("tags.refresh_interval"),
+		},
+		Dataset: DatasetConfig{
+			Paths:   v.GetStringSlice("dataset.paths"),
+			Format:  v.GetString("dataset.format"),
+			Columns: v.GetStringMapString("dataset.columns"),
+		},
+		Enrichment: EnrichmentConfig{
+			Enabled:     v.GetBool("enrichment.enabled"),
+			Resolvers:   v.GetStringSlice("enrichment.resolvers"),
+			Interval:    v.GetInt("enrichment.interval"),
+			LookupDelay: v.GetInt("enrichment.lookup_delay"),
+			MaxAttempts: v.GetInt("enrichment.max_attempts"),
+		},
+		Coverage: CoverageConfig{
+			Enabled:      v.GetBool("coverage.enabled"),
+			URL:          v.GetString("coverage.url"),
+			APIKey:       v.GetString("coverage.api_key"),
+			APIKeyFile:   v.GetString("coverage.api_key_file"),
+			APIKeyHeader: v.GetString("coverage.api_key_header"),
+			Radius:       v.GetInt("coverage.radius"),
+			Interval:     v.GetInt("coverage.interval"),
+			Sectors:      v.GetInt("coverage.sectors"),
+
+			TerrainDir:     v.GetString("coverage.terrain_dir"),
+			AntennaHeight:  v.GetInt("coverage.antenna_height"),
+			TargetAltitude: v.GetInt("coverage.target_altitude"),
+		},
+		Scheduler: SchedulerConfig{
+			Jitter: v.GetInt("scheduler.jitter"),
+		},
+		Sync: SyncConfig{
+			To:            v.GetString("sync.to"),
+			Source:        v.GetString("sync.source"),
+			Interval:      v.GetInt("sync.interval"),
+			RateLimit:     v.GetInt("sync.rate_limit"),
+			Tables:        v.GetStringSlice("sync.tables"),
+			RemoteCommand: v.GetString("sync.remote_command"),
+		},
+		Station: StationConfig{
+			HubURL:    v.GetString("station.hub_url"),
+			Name:      v.GetString("station.name"),
+			Token:     v.GetString("station.token"),
+			TokenFile: v.GetString("station.token_file"),
+			CAFile:    v.GetString("station.ca_file"),
+			Where:     v.GetString("station.where"),
+		},
+		Hub: HubConfig{
+			Enabled:     v.GetBool("hub.enabled"),
+			Addr:        v.GetString("hub.addr"),
+			PushAddr:    v.GetString("hub.push_addr"),
+			TLSCertFile: v.GetString("hub.tls_cert_file"),
+			TLSKeyFile:  v.GetString("hub.tls_key_file"),
+			RateLimit:   v.GetInt("hub.rate_limit"),
+		},
+		Maintenance: MaintenanceConfig{
+			Enabled:        v.GetBool("maintenance.enabled"),
+			RateDrop:       v.GetInt("maintenance.rate_drop"),
+			MinBaseline:    v.GetInt("maintenance.min_baseline"),
+			ParseErrorRate: v.GetInt("maintenance.parse_error_rate"),
+			Silence:        v.GetInt("maintenance.silence"),
+			CaptureDir:     v.GetString("maintenance.capture_dir"),
+		},
+		DeepDive: DeepDiveConfig{
+			Enabled:    v.GetBool("deep_dive.enabled"),
+			Wait:       v.GetInt("deep_dive.wait"),
+			MaxPerHour: v.GetInt("deep_dive.max_per_hour"),
 		},
+		Privacy: PrivacyConfig{
+			Blocked:    v.GetStringSlice("privacy.blocked"),
+			BlockLists: v.GetStringSlice("privacy.block_lists"),
+			Outputs: PrivacyOutputsConfig{
+				API:    v.GetString("privacy.outputs.api"),
+				TRMNL:  v.GetString("privacy.outputs.trmnl"),
+				Notify: v.GetString("privacy.outputs.notify"),
+			},
+		},
+	}
+
+	if err := v.UnmarshalKey("trmnl.profiles", &cfg.TRMNL.Profiles); err != nil {
+		return nil, fmt.Errorf("error reading trmnl.profiles: %w", err)
+	}
+	for i := range cfg.TRMNL.Profiles {
+		p := &cfg.TRMNL.Profiles[i]
+		if p.RefreshInterval == 0 {
+			p.RefreshInterval = 900
+		}
+		if err := resolveSecret(&p.WebhookURL, &p.WebhookURLFile, fmt.Sprintf("trmnl.profiles[%d].webhook_url", i)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := v.UnmarshalKey("notify.webhooks", &cfg.Notify.Webhooks); err != nil {
+		return nil, fmt.Errorf("error reading notify.webhooks: %w", err)
+	}
+	for i := range cfg.Notify.Webhooks {
+		w := &cfg.Notify.Webhooks[i]
+		if err := resolveSecret(&w.URL, &w.URLFile, fmt.Sprintf("notify.webhooks[%d].url", i)); err != nil {
+			return nil, err
+		}
+		if err := resolveSecret(&w.Secret, &w.SecretFile, fmt.Sprintf("notify.webhooks[%d].secret", i)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := resolveSecret(&cfg.Notify.Email.Password, &cfg.Notify.Email.PasswordFile, "notify.email.password"); err != nil {
+		return nil, err
+	}
+
+	if cfg.Station.HubURL != "" {
+		if cfg.Station.Name == "" {
+			cfg.Station.Name, _ = os.Hostname()
+		}
+		if err := resolveSecret(&cfg.Station.Token, &cfg.Station.TokenFile, "station.token"); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Sync.Source == "" {
+		cfg.Sync.Source, _ = os.Hostname()
+	}
+
+	if err := resolveSecret(&cfg.Encryption.Key, &cfg.Encryption.KeyFile, "encryption.key"); err != nil {
+		return nil, err
+	}
+	if err := resolveSecret(&cfg.Export.Salt, &cfg.Export.SaltFile, "export.salt"); err != nil {
+		return nil, err
+	}
+	if cfg.Coverage.Enabled {
+		if err := resolveSecret(&cfg.Coverage.APIKey, &cfg.Coverage.APIKeyFile, "coverage.api_key"); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := v.UnmarshalKey("udp_inputs", &cfg.UDPInputs); err != nil {
+		return nil, fmt.Errorf("error reading udp_inputs: %w", err)
+	}
+	for i := range cfg.UDPInputs {
+		in := &cfg.UDPInputs[i]
+		if in.Format == "" {
+			in.Format = "beast"
+		}
+		if in.Clock == "" {
+			in.Clock = models.ClockFreeRunning
+		}
+	}
+
+	if err := v.UnmarshalKey("serial_inputs", &cfg.SerialInputs); err != nil {
+		return nil, fmt.Errorf("error reading serial_inputs: %w", err)
+	}
+	for i := range cfg.SerialInputs {
+		in := &cfg.SerialInputs[i]
+		if in.Baud == 0 {
+			in.Baud = dump1090.DefaultSerialBaud
+		}
+		if in.Format == "" {
+			in.Format = "beast"
+		}
+		if in.Clock == "" {
+			in.Clock = models.ClockFreeRunning
+		}
+	}
+
+	if err := v.UnmarshalKey("receivers", &cfg.Receivers); err != nil {
+		return nil, fmt.Errorf("error reading receivers: %w", err)
+	}
+	for i := range cfg.Receivers {
+		r := &cfg.Receivers[i]
+		if r.Format == "" {
+			r.Format = "beast"
+		}
+		if r.Clock == "" {
+			r.Clock = models.ClockFreeRunning
+		}
+	}
+
+	if err := v.UnmarshalKey("hub.stations", &cfg.Hub.Stations); err != nil {
+		return nil, fmt.Errorf("error reading hub.stations: %w", err)
+	}
+	for i := range cfg.Hub.Stations {
+		s := &cfg.Hub.Stations[i]
+		if err := resolveSecret(&s.Token, &s.TokenFile, fmt.Sprintf("hub.stations[%d].token", i)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := v.UnmarshalKey("tags.lists", &cfg.Tags.Lists); err != nil {
+		return nil, fmt.Errorf("error reading tags.lists: %w", err)
 	}
 
 	// Validate configuration
@@ -92,11 +837,164 @@ func Load() (*Config, error) {
 }
 
 // validate validates the configuration values
+// resolveSecret replaces a secret with its file content or expanded environment references
+// key names the inline setting; the file setting is key with a _file suffix.
+func resolveSecret(value, file *string, key string) error {
+	resolved, err := secrets.Resolve(*value, *file)
+	if err != nil {
+		if *file != "" {
+			key += "_file"
+		}
+		return fmt.Errorf("invalid configuration: %s: %w", key, err)
+	}
+	*value = resolved
+	return nil
+}
+
+// Redacted returns a copy of the configuration safe to print or log, with secrets and webhook URLs hidden
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redacted.TRMNL.Profiles = append([]TRMNLProfileConfig(nil), c.TRMNL.Profiles...)
+	for i := range redacted.TRMNL.Profiles {
+		redacted.TRMNL.Profiles[i].WebhookURL = secrets.RedactURL(redacted.TRMNL.Profiles[i].WebhookURL)
+	}
+	redacted.Notify.Webhooks = append([]WebhookConfig(nil), c.Notify.Webhooks...)
+	for i := range redacted.Notify.Webhooks {
+		w := &redacted.Notify.Webhooks[i]
+		w.URL = secrets.RedactURL(w.URL)
+		if w.Secret != "" {
+			w.Secret = secrets.Redacted
+		}
+	}
+	if redacted.Notify.Email.Password != "" {
+		redacted.Notify.Email.Password = secrets.Redacted
+	}
+	if redacted.Station.Token != "" {
+		redacted.Station.Token = secrets.Redacted
+	}
+	if redacted.Encryption.Key != "" {
+		redacted.Encryption.Key = secrets.Redacted
+	}
+	if redacted.Export.Salt != "" {
+		redacted.Export.Salt = secrets.Redacted
+	}
+	if redacted.Coverage.APIKey != "" {
+		redacted.Coverage.APIKey = secrets.Redacted
+	}
+	redacted.Hub.Stations = append([]HubStationConfig(nil), c.Hub.Stations...)
+	for i := range redacted.Hub.Stations {
+		redacted.Hub.Stations[i].Token = secrets.Redacted
+	}
+	return &redacted
+}
+
 func validate(cfg *Config) error {
-	if cfg.BeastAddr == "" {
+	// A hub may only aggregate stations, frames may only come over UDP or a serial port, from a recording, or states
+	// from aircraft.json, and every receiver may be listed under receivers, without beast_addr to connect to
+	if cfg.BeastAddr == "" && !cfg.Hub.Enabled && len(cfg.UDPInputs) == 0 && len(cfg.SerialInputs) == 0 &&
+		cfg.Replay.File == "" && cfg.AircraftJSON.URL == "" && len(cfg.Receivers) == 0 {
 		return fmt.Errorf("beast_addr is required")
 	}
 
+	if cfg.BeastFormat != "beast" && cfg.BeastFormat != "avr" {
+		return fmt.Errorf("beast_format must be beast or avr")
+	}
+
+	if _, err := models.NewBeastClock(cfg.BeastClock); err != nil {
+		return fmt.Errorf("invalid beast_clock: %w", err)
+	}
+
+	if cfg.BeastTransport.SSH != "" {
+		if _, err := dump1090.ParseSSH(cfg.BeastTransport.SSH); err != nil {
+			return fmt.Errorf("beast_transport.ssh: %w", err)
+		}
+	}
+	if !cfg.BeastTransport.TLS && (cfg.BeastTransport.CAFile != "" || cfg.BeastTransport.ServerName != "") {
+		return fmt.Errorf("beast_transport.ca_file and server_name need beast_transport.tls")
+	}
+
+	if cfg.Replay.File != "" && cfg.CatchUpFile != "" {
+		return fmt.Errorf("replay.file and catch_up_file can't be used together")
+	}
+	if cfg.Replay.Speed < 0 {
+		return fmt.Errorf("replay.speed must not be negative")
+	}
+
+	if u := cfg.AircraftJSON.URL; u != "" && !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		return fmt.Errorf("aircraft_json.url must be an http(s) URL")
+	}
+	if cfg.AircraftJSON.Interval < 1 {
+		return fmt.Errorf("aircraft_json.interval must be at least 1 second")
+	}
+
+	udpAddrs := make(map[string]bool)
+	for _, in := range cfg.UDPInputs {
+		if in.Addr == "" {
+			return fmt.Errorf("udp_inputs entries require an addr")
+		}
+		if udpAddrs[in.Addr] {
+			return fmt.Errorf("duplicate udp_inputs addr: %s", in.Addr)
+		}
+		udpAddrs[in.Addr] = true
+		if in.Format != "beast" && in.Format != "avr" {
+			return fmt.Errorf("udp input %s: format must be beast or avr", in.Addr)
+		}
+		if _, err := models.NewBeastClock(in.Clock); err != nil {
+			return fmt.Errorf("udp input %s: invalid clock: %w", in.Addr, err)
+		}
+		if in.Receiver != "" && !receiverIDPattern.MatchString(in.Receiver) {
+			return fmt.Errorf("udp input %s: receiver must be letters, digits, - and _: %q", in.Addr, in.Receiver)
+		}
+	}
+
+	serialDevices := make(map[string]bool)
+	for _, in := range cfg.SerialInputs {
+		if in.Device == "" {
+			return fmt.Errorf("serial_inputs entries require a device")
+		}
+		if serialDevices[in.Device] {
+			return fmt.Errorf("duplicate serial_inputs device: %s", in.Device)
+		}
+		serialDevices[in.Device] = true
+		if err := dump1090.CheckBaud(in.Baud); err != nil {
+			return fmt.Errorf("serial input %s: %w", in.Device, err)
+		}
+		if in.Format != "beast" && in.Format != "avr" {
+			return fmt.Errorf("serial input %s: format must be beast or avr", in.Device)
+		}
+		if _, err := models.NewBeastClock(in.Clock); err != nil {
+			return fmt.Errorf("serial input %s: invalid clock: %w", in.Device, err)
+		}
+		if in.Receiver != "" && !receiverIDPattern.MatchString(in.Receiver) {
+			return fmt.Errorf("serial input %s: receiver must be letters, digits, - and _: %q", in.Device, in.Receiver)
+		}
+	}
+
+	receiverIDs := make(map[string]bool)
+	receiverAddrs := map[string]bool{cfg.BeastAddr: true}
+	for _, r := range cfg.Receivers {
+		if !receiverIDPattern.MatchString(r.ID) {
+			return fmt.Errorf("receivers entries require an id of letters, digits, - and _: %q", r.ID)
+		}
+		if receiverIDs[r.ID] {
+			return fmt.Errorf("duplicate receivers id: %s", r.ID)
+		}
+		receiverIDs[r.ID] = true
+		if r.Addr == "" {
+			return fmt.Errorf("receiver %s: addr is required", r.ID)
+		}
+		if receiverAddrs[r.Addr] {
+			return fmt.Errorf("receiver %s: %s is already read, by beast_addr or another receiver", r.ID, r.Addr)
+		}
+		receiverAddrs[r.Addr] = true
+		if r.Format != "beast" && r.Format != "avr" {
+			return fmt.Errorf("receiver %s: format must be beast or avr", r.ID)
+		}
+		if _, err := models.NewBeastClock(r.Clock); err != nil {
+			return fmt.Errorf("receiver %s: invalid clock: %w", r.ID, err)
+		}
+	}
+
 	if cfg.BatchSize <= 0 {
 		return fmt.Errorf("batch_size must be greater than 0")
 	}
@@ -105,6 +1003,33 @@ func validate(cfg *Config) error {
 		return fmt.Errorf("batch_timeout must be greater than 0")
 	}
 
+	validStorageModes := map[string]bool{
+		"raw":     true,
+		"decoded": true,
+		"state":   true,
+	}
+	if !validStorageModes[cfg.StorageMode] {
+		return fmt.Errorf("invalid storage_mode: %s (must be raw, decoded, or state)", cfg.StorageMode)
+	}
+	for _, column := range cfg.OmitColumns {
+		if err := database.CheckOmittedColumn(column); err != nil {
+			return fmt.Errorf("invalid omit_columns: %w", err)
+		}
+	}
+
+	if cfg.Encryption.Key != "" {
+		if _, err := crypt.ParseKey(cfg.Encryption.Key); err != nil {
+			return fmt.Errorf("invalid encryption.key: %w", err)
+		}
+	}
+
+	if cfg.Location.Latitude < -90 || cfg.Location.Latitude > 90 {
+		return fmt.Errorf("location.latitude must be between -90 and 90")
+	}
+	if cfg.Location.Longitude < -180 || cfg.Location.Longitude > 180 {
+		return fmt.Errorf("location.longitude must be between -180 and 180")
+	}
+
 	validLogLevels := map[string]bool{
 		"debug": true,
 		"info":  true,
@@ -122,6 +1047,367 @@ func validate(cfg *Config) error {
 	if !validLogFormats[strings.ToLower(cfg.Log.Format)] {
 		return fmt.Errorf("invalid log format: %s (must be text or json)", cfg.Log.Format)
 	}
+	if cfg.Log.CrashDir == "" {
+		return fmt.Errorf("log.crash_dir is required")
+	}
+
+	validResolvers := map[string]bool{
+		"database":    true,
+		"basestation": true,
+		"opensky":     true,
+		"country":     true,
+	}
+	for _, name := range cfg.Metadata.Resolvers {
+		if !validResolvers[name] {
+			return fmt.Errorf("invalid metadata resolver: %s (must be database, basestation, opensky, or country)", name)
+		}
+		if name == "basestation" && cfg.Metadata.BaseStationPath == "" {
+			return fmt.Errorf("metadata.basestation_path is required when the basestation resolver is enabled")
+		}
+	}
+
+	// The database resolver is what is being filled in, and country results are derived, not metadata
+	validEnrichmentResolvers := map[string]bool{
+		"basestation": true,
+		"opensky":     true,
+	}
+	for _, name := range cfg.Enrichment.Resolvers {
+		if !validEnrichmentResolvers[name] {
+			return fmt.Errorf("invalid enrichment resolver: %s (must be basestation or opensky)", name)
+		}
+		if name == "basestation" && cfg.Metadata.BaseStationPath == "" {
+			return fmt.Errorf("metadata.basestation_path is required when the basestation resolver is enabled")
+		}
+	}
+	if cfg.Enrichment.Enabled && len(cfg.Enrichment.Resolvers) == 0 {
+		return fmt.Errorf("enrichment.resolvers is required when enrichment is enabled")
+	}
+	if cfg.Enrichment.Interval <= 0 || cfg.Enrichment.LookupDelay < 0 || cfg.Enrichment.MaxAttempts <= 0 {
+		return fmt.Errorf("enrichment.interval and enrichment.max_attempts must be greater than 0, enrichment.lookup_delay must not be negative")
+	}
+
+	if cfg.Coverage.Enabled {
+		if !cfg.Location.IsSet() {
+			return fmt.Errorf("location is required when coverage is enabled")
+		}
+		if !strings.HasPrefix(cfg.Coverage.URL, "http://") && !strings.HasPrefix(cfg.Coverage.URL, "https://") {
+			return fmt.Errorf("invalid coverage.url: must be an http(s) URL")
+		}
+		if cfg.Coverage.Radius <= 0 || cfg.Coverage.Interval <= 0 {
+			return fmt.Errorf("coverage.radius and coverage.interval must be greater than 0")
+		}
+		if cfg.Coverage.Sectors < 4 || cfg.Coverage.Sectors > 36 {
+			return fmt.Errorf("coverage.sectors must be 4 to 36")
+		}
+		if cfg.Coverage.AntennaHeight < 0 || cfg.Coverage.TargetAltitude <= 0 {
+			return fmt.Errorf("coverage.antenna_height must not be negative, coverage.target_altitude must be greater than 0")
+		}
+	}
+
+	if cfg.Scheduler.Jitter < 0 {
+		return fmt.Errorf("scheduler.jitter must not be negative")
+	}
+
+	if cfg.Sync.To != "" {
+		if err := dbsync.ParseDestination(cfg.Sync.To); err != nil {
+			return fmt.Errorf("invalid sync.to: %w", err)
+		}
+		if cfg.Sync.To == cfg.DBPath {
+			return fmt.Errorf("sync.to must be another database than db_path")
+		}
+		if cfg.Sync.Interval <= 0 || cfg.Sync.RateLimit < 0 {
+			return fmt.Errorf("sync.interval must be greater than 0, sync.rate_limit must not be negative")
+		}
+		if cfg.Sync.Source == "" {
+			return fmt.Errorf("sync.source is required when the host name is unknown")
+		}
+		for _, table := range cfg.Sync.Tables {
+			if err := database.CheckSyncTable(table); err != nil {
+				return fmt.Errorf("invalid sync.tables: %w", err)
+			}
+		}
+	}
+
+	if cfg.Links.BaseURL != "" && !strings.HasPrefix(cfg.Links.BaseURL, "http://") && !strings.HasPrefix(cfg.Links.BaseURL, "https://") {
+		return fmt.Errorf("links.base_url must be an http(s) URL")
+	}
+	validTrackers := map[string]bool{
+		"adsbexchange": true,
+		"fr24":         true,
+		"opensky":      true,
+		"planefinder":  true,
+	}
+	linkNames := map[string]bool{"local": cfg.Links.BaseURL != ""}
+	for _, name := range cfg.Links.Trackers {
+		if !validTrackers[name] {
+			return fmt.Errorf("invalid links.trackers entry: %s (must be adsbexchange, fr24, opensky, or planefinder)", name)
+		}
+		linkNames[name] = true
+	}
+	for name, template := range cfg.Links.Custom {
+		if name == "local" || validTrackers[name] {
+			return fmt.Errorf("links.custom entry %s clashes with a built-in link", name)
+		}
+		if !strings.HasPrefix(template, "http://") && !strings.HasPrefix(template, "https://") {
+			return fmt.Errorf("links.custom entry %s must be an http(s) URL", name)
+		}
+		linkNames[name] = true
+	}
+	if cfg.Links.QR != "" && !linkNames[cfg.Links.QR] {
+		return fmt.Errorf("links.qr %s is not a configured link (local requires links.base_url)", cfg.Links.QR)
+	}
+
+	if cfg.Station.HubURL != "" {
+		if !strings.HasPrefix(cfg.Station.HubURL, "https://") && !strings.HasPrefix(cfg.Station.HubURL, "http://") {
+			return fmt.Errorf("station.hub_url must be an http(s) URL")
+		}
+		if cfg.Station.Token == "" {
+			return fmt.Errorf("station.token is required when station.hub_url is set")
+		}
+		if _, err := filter.Compile(cfg.Station.Where, filter.KindMessage); err != nil {
+			return fmt.Errorf("invalid station.where: %w", err)
+		}
+	}
+
+	if cfg.Hub.Enabled {
+		if cfg.Hub.Addr == "" {
+			return fmt.Errorf("hub.addr is required when the hub is enabled")
+		}
+		if (cfg.Hub.TLSCertFile == "") != (cfg.Hub.TLSKeyFile == "") {
+			return fmt.Errorf("hub.tls_cert_file and hub.tls_key_file must be set together")
+		}
+		if cfg.Hub.PushAddr != "" && cfg.Hub.PushAddr == cfg.Hub.Addr {
+			return fmt.Errorf("hub.push_addr must differ from hub.addr")
+		}
+	} else if cfg.Hub.PushAddr != "" {
+		return fmt.Errorf("hub.push_addr requires the hub to be enabled")
+	}
+	if cfg.Hub.RateLimit < 0 {
+		return fmt.Errorf("hub.rate_limit must not be negative")
+	}
+	stationNames := make(map[string]bool)
+	for _, s := range cfg.Hub.Stations {
+		if s.Name == "" || s.Token == "" {
+			return fmt.Errorf("hub.stations entries require a name and a token")
+		}
+		if stationNames[s.Name] {
+			return fmt.Errorf("duplicate hub station name: %s", s.Name)
+		}
+		if s.RateLimit < 0 {
+			return fmt.Errorf("hub station %s: rate_limit must not be negative", s.Name)
+		}
+		stationNames[s.Name] = true
+	}
+
+	if cfg.Maintenance.RateDrop < 1 || cfg.Maintenance.RateDrop > 100 || cfg.Maintenance.ParseErrorRate < 1 || cfg.Maintenance.ParseErrorRate > 100 {
+		return fmt.Errorf("maintenance.rate_drop and maintenance.parse_error_rate must be 1-100 percent")
+	}
+	if cfg.Maintenance.MinBaseline < 0 || cfg.Maintenance.Silence <= 0 {
+		return fmt.Errorf("maintenance.silence must be greater than 0, maintenance.min_baseline must not be negative")
+	}
+	if cfg.Maintenance.CaptureDir == "" {
+		return fmt.Errorf("maintenance.capture_dir is required")
+	}
+	if cfg.DeepDive.Wait < 1 || cfg.DeepDive.MaxPerHour < 0 {
+		return fmt.Errorf("deep_dive.wait must be at least 1, deep_dive.max_per_hour must not be negative")
+	}
+
+	if cfg.Metadata.CacheTTL < 0 {
+		return fmt.Errorf("metadata.cache_ttl must not be negative")
+	}
+
+	if cfg.Tracker.Expiry <= 0 {
+		return fmt.Errorf("tracker.expiry must be greater than 0")
+	}
+
+	if cfg.Tracker.SnapshotInterval < 0 || cfg.Tracker.SnapshotRetention < 0 {
+		return fmt.Errorf("tracker.snapshot_interval and tracker.snapshot_retention must not be negative")
+	}
+
+	if cfg.API.Enabled && cfg.API.Addr == "" {
+		return fmt.Errorf("api.addr is required when the API is enabled")
+	}
+
+	if u := cfg.API.PhotoURL; u != "" && !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		return fmt.Errorf("invalid api.photo_url: %s (must start with http:// or https://)", u)
+	}
+
+	for _, origin := range cfg.API.CORS.AllowedOrigins {
+		if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			return fmt.Errorf("invalid api.cors.allowed_origins entry: %s (must be * or start with http:// or https://)", origin)
+		}
+	}
+
+	if cfg.API.CORS.MaxAge < 0 {
+		return fmt.Errorf("api.cors.max_age must not be negative")
+	}
+	if cfg.API.CacheTTL < 0 {
+		return fmt.Errorf("api.cache_ttl must not be negative")
+	}
+
+	profileNames := make(map[string]bool)
+	for _, p := range cfg.TRMNL.Profiles {
+		if p.Name == "" {
+			return fmt.Errorf("trmnl.profiles entries require a name")
+		}
+		if profileNames[p.Name] {
+			return fmt.Errorf("duplicate trmnl profile name: %s", p.Name)
+		}
+		profileNames[p.Name] = true
+
+		if !strings.HasPrefix(p.WebhookURL, "https://") && !strings.HasPrefix(p.WebhookURL, "http://") {
+			return fmt.Errorf("trmnl profile %s: webhook_url must be an http(s) URL", p.Name)
+		}
+		// Layout names are checked against the built-in and loaded layouts at startup
+		if p.Layout == "" {
+			return fmt.Errorf("trmnl profile %s: layout is required", p.Name)
+		}
+		if p.RefreshInterval < minTRMNLRefresh {
+			return fmt.Errorf("trmnl profile %s: refresh_interval must be at least %d seconds", p.Name, minTRMNLRefresh)
+		}
+		if p.Filter.MinSignal < 0 || p.Filter.MinSignal > 255 {
+			return fmt.Errorf("trmnl profile %s: filter.min_signal must be 0-255", p.Name)
+		}
+		for _, band := range p.Filter.Band {
+			if !validBands[strings.ToLower(band)] {
+				return fmt.Errorf("trmnl profile %s: unknown filter.band %s (must be surface, low, mid, or high)", p.Name, band)
+			}
+		}
+		if _, err := filter.Compile(p.Filter.Where, filter.KindAircraft); err != nil {
+			return fmt.Errorf("trmnl profile %s: invalid filter.where: %w", p.Name, err)
+		}
+	}
+
+	validEventTypes := map[string]bool{
+		"new_aircraft": true,
+		"alert":        true,
+		"geofence":     true,
+		"emergency":    true,
+		"advisory":     true,
+		"maintenance":  true,
+		"deep_dive":    true,
+		"record":       true,
+	}
+	validSeverities := map[string]bool{
+		"":         true,
+		"info":     true,
+		"warning":  true,
+		"critical": true,
+	}
+	webhookNames := make(map[string]bool)
+	for _, w := range cfg.Notify.Webhooks {
+		if w.Name == "" {
+			return fmt.Errorf("notify.webhooks entries require a name")
+		}
+		if webhookNames[w.Name] {
+			return fmt.Errorf("duplicate webhook name: %s", w.Name)
+		}
+		webhookNames[w.Name] = true
+
+		if !strings.HasPrefix(w.URL, "https://") && !strings.HasPrefix(w.URL, "http://") {
+			return fmt.Errorf("webhook %s: url must be an http(s) URL", w.Name)
+		}
+		for _, t := range w.Types {
+			if !validEventTypes[t] {
+				return fmt.Errorf("webhook %s: invalid event type: %s (must be new_aircraft, alert, geofence, emergency, advisory, maintenance, record, or deep_dive)", w.Name, t)
+			}
+		}
+		if !validSeverities[w.MinSeverity] {
+			return fmt.Errorf("webhook %s: invalid min_severity: %s (must be info, warning, or critical)", w.Name, w.MinSeverity)
+		}
+		if !validSeverities[w.DigestMaxSeverity] {
+			return fmt.Errorf("webhook %s: invalid digest_max_severity: %s (must be info, warning, or critical)", w.Name, w.DigestMaxSeverity)
+		}
+		if w.DedupeWindow < 0 || w.AggregateWindow < 0 || w.DigestInterval < 0 {
+			return fmt.Errorf("webhook %s: dedupe_window, aggregate_window, and digest_interval must not be negative", w.Name)
+		}
+		if _, err := filter.Compile(w.Where, filter.KindEvent); err != nil {
+			return fmt.Errorf("webhook %s: invalid where: %w", w.Name, err)
+		}
+	}
+
+	if email := cfg.Notify.Email; email.Addr != "" {
+		if _, _, err := net.SplitHostPort(email.Addr); err != nil {
+			return fmt.Errorf("notify.email.addr must be host:port: %w", err)
+		}
+		if _, err := mail.ParseAddress(email.From); err != nil {
+			return fmt.Errorf("notify.email.from must be an email address: %w", err)
+		}
+		if len(email.To) == 0 {
+			return fmt.Errorf("notify.email.to requires at least one recipient")
+		}
+		for _, to := range email.To {
+			if _, err := mail.ParseAddress(to); err != nil {
+				return fmt.Errorf("notify.email.to: invalid address %s: %w", to, err)
+			}
+		}
+		for _, t := range email.Types {
+			if !validEventTypes[t] {
+				return fmt.Errorf("notify.email: invalid event type: %s (must be new_aircraft, alert, geofence, emergency, advisory, maintenance, record, or deep_dive)", t)
+			}
+		}
+		if !validSeverities[email.MinSeverity] {
+			return fmt.Errorf("notify.email: invalid min_severity: %s (must be info, warning, or critical)", email.MinSeverity)
+		}
+	} else if len(cfg.Notify.Email.Types) > 0 || cfg.Notify.Email.WeeklyReport.Enabled {
+		return fmt.Errorf("notify.email.types and weekly_report need notify.email.addr")
+	}
+	if _, ok := weekdays[strings.ToLower(cfg.Notify.Email.WeeklyReport.Day)]; !ok {
+		return fmt.Errorf("notify.email.weekly_report.day must be a weekday, e.g. monday")
+	}
+	if h := cfg.Notify.Email.WeeklyReport.Hour; h < 0 || h > 23 {
+		return fmt.Errorf("notify.email.weekly_report.hour must be from 0 to 23")
+	}
+
+	if cfg.Notify.Retry.MaxAttempts <= 0 {
+		return fmt.Errorf("notify.retry.max_attempts must be greater than 0")
+	}
+	if cfg.Notify.Retry.InitialBackoff <= 0 || cfg.Notify.Retry.MaxBackoff < cfg.Notify.Retry.InitialBackoff {
+		return fmt.Errorf("notify.retry.initial_backoff must be greater than 0 and not exceed notify.retry.max_backoff")
+	}
+
+	if cfg.Tags.RefreshInterval <= 0 {
+		return fmt.Errorf("tags.refresh_interval must be greater than 0")
+	}
+	listNames := make(map[string]bool)
+	for _, l := range cfg.Tags.Lists {
+		if l.Name == "" {
+			return fmt.Errorf("tags.lists entries require a name")
+		}
+		if listNames[l.Name] {
+			return fmt.Errorf("duplicate tag list name: %s", l.Name)
+		}
+		listNames[l.Name] = true
+		if l.URL == "" {
+			return fmt.Errorf("tag list %s: url is required", l.Name)
+		}
+	}
+
+	for _, icao := range cfg.Privacy.Blocked {
+		if len(icao) != 6 || strings.Trim(strings.ToUpper(icao), "0123456789ABCDEF") != "" {
+			return fmt.Errorf("invalid privacy.blocked entry: %s (must be a 6 hex digit ICAO address)", icao)
+		}
+	}
+	for _, list := range cfg.Privacy.BlockLists {
+		if !listNames[list] {
+			return fmt.Errorf("privacy.block_lists entry %s is not a configured tags.lists name", list)
+		}
+	}
+	validPolicies := map[string]bool{
+		"show":      true,
+		"anonymize": true,
+		"exclude":   true,
+	}
+	outputs := map[string]string{
+		"api":    cfg.Privacy.Outputs.API,
+		"trmnl":  cfg.Privacy.Outputs.TRMNL,
+		"notify": cfg.Privacy.Outputs.Notify,
+	}
+	for output, policy := range outputs {
+		if !validPolicies[policy] {
+			return fmt.Errorf("invalid privacy.outputs.%s: %s (must be show, anonymize, or exclude)", output, policy)
+		}
+	}
 
 	return nil
 }
@@ -0,0 +1,309 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_Secrets(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "trmnl_url")
+	require.NoError(t, os.WriteFile(secretFile, []byte("https://usetrmnl.com/api/custom_plugins/uuid\n"), 0o600))
+	t.Setenv("FT_TEST_WEBHOOK_SECRET", "hmac-key")
+	t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, `notify:
+  webhooks:
+    - name: ha
+      url: "https://ha.local/api/webhook/flights"
+      secret: "${FT_TEST_WEBHOOK_SECRET}"
+trmnl:
+  profiles:
+    - name: kitchen
+      webhook_url_file: "`+secretFile+`"
+      layout: nearest
+`))
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "hmac-key", cfg.Notify.Webhooks[0].Secret)
+	assert.Equal(t, "https://usetrmnl.com/api/custom_plugins/uuid", cfg.TRMNL.Profiles[0].WebhookURL)
+
+	redacted := cfg.Redacted()
+	assert.Equal(t, "[redacted]", redacted.Notify.Webhooks[0].Secret)
+	assert.Equal(t, "https://ha.local/[redacted]", redacted.Notify.Webhooks[0].URL)
+	assert.Equal(t, "https://usetrmnl.com/[redacted]", redacted.TRMNL.Profiles[0].WebhookURL)
+	assert.Equal(t, "hmac-key", cfg.Notify.Webhooks[0].Secret, "the original is unchanged")
+}
+
+func TestLoad_MissingSecret(t *testing.T) {
+	t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, `notify:
+  webhooks:
+    - name: ha
+      url: "https://ha.local/api/webhook/flights"
+      secret_file: "/nonexistent/secret"
+`))
+
+	_, err := Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "notify.webhooks[0].secret_file")
+}
+
+func TestLoad_EncryptionKey(t *testing.T) {
+	t.Setenv("FT_TEST_DB_KEY", strings.Repeat("ab", 32))
+	t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, `encryption:
+  key: "${FT_TEST_DB_KEY}"
+`))
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("ab", 32), cfg.Encryption.Key)
+	assert.Equal(t, "[redacted]", cfg.Redacted().Encryption.Key)
+
+	t.Setenv("FT_TEST_DB_KEY", "too short")
+	_, err = Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "encryption.key")
+}
+
+func TestRenderStarter(t *testing.T) {
+	example, err := os.ReadFile("../../config.yaml.example")
+	require.NoError(t, err)
+
+	out, err := RenderStarter(string(example), Starter{
+		BeastAddr:         "piaware.local:30005",
+		Location:          LocationConfig{Name: "Home", Latitude: 51.47, Longitude: -0.4543},
+		TRMNLWebhookURL:   "https://usetrmnl.com/api/custom_plugins/uuid",
+		StorageMode:       "decoded",
+		SnapshotRetention: 30,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, out, "# Beast format server address", "comments are kept")
+
+	path := writeConfig(t, out)
+	require.NoError(t, checkFile(path))
+	t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", path)
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "piaware.local:30005", cfg.BeastAddr)
+	assert.Equal(t, LocationConfig{Name: "Home", Latitude: 51.47, Longitude: -0.4543}, cfg.Location)
+	assert.Equal(t, "decoded", cfg.StorageMode)
+	assert.Equal(t, 30, cfg.Tracker.SnapshotRetention)
+	require.Len(t, cfg.TRMNL.Profiles, 1)
+	assert.Equal(t, "https://usetrmnl.com/api/custom_plugins/uuid", cfg.TRMNL.Profiles[0].WebhookURL)
+
+	// Skipped answers leave the example's values
+	out, err = RenderStarter(string(example), Starter{BeastAddr: "localhost:30005", StorageMode: "raw", SnapshotRetention: 7})
+	require.NoError(t, err)
+	t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, out))
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.Location.IsSet())
+	assert.Empty(t, cfg.TRMNL.Profiles)
+
+	_, err = RenderStarter("db_path: x\n", Starter{})
+	assert.ErrorContains(t, err, "beast_addr")
+}
+
+func TestLoad_Location(t *testing.T) {
+	t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, "location:\n  latitude: 95\n  longitude: 10\n"))
+	_, err := Load()
+	assert.ErrorContains(t, err, "location.latitude")
+
+	t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, "location:\n  latitude: north\n"))
+	_, err = Load()
+	assert.ErrorContains(t, err, `location.latitude: must be a number, got "north"`)
+}
+
+func TestLoad_InvalidWhere(t *testing.T) {
+	t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, `notify:
+  webhooks:
+    - name: ha
+      url: "https://ha.local/api/webhook/flights"
+      where: severity == critical
+`))
+
+	_, err := Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `webhook ha: invalid where: column 13: unexpected "critical", strings need quotes`)
+}
+
+func TestLoad_UDPInputs(t *testing.T) {
+	t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, `beast_addr: ""
+udp_inputs:
+  - addr: ":30005"
+  - addr: ":30002"
+    format: avr
+    clock: gps
+    receiver: shed
+`))
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, []UDPInputConfig{{Addr: ":30005", Format: "beast", Clock: "12mhz"},
+		{Addr: ":30002", Format: "avr", Clock: "gps", Receiver: "shed"}}, cfg.UDPInputs)
+
+	t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, "udp_inputs:\n  - addr: \":30005\"\n    receiver: the shed\n"))
+	_, err = Load()
+	assert.ErrorContains(t, err, `udp input :30005: receiver must be letters, digits, - and _: "the shed"`)
+
+	t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, "udp_inputs:\n  - addr: \":30005\"\n  - addr: \":30005\"\n"))
+	_, err = Load()
+	assert.ErrorContains(t, err, "duplicate udp_inputs addr: :30005")
+}
+
+func TestLoad_SerialInputs(t *testing.T) {
+	t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, `beast_addr: ""
+serial_inputs:
+  - device: /dev/ttyUSB0
+  - device: /dev/ttyACM0
+    baud: 921600
+    format: avr
+    receiver: gns
+`))
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, []SerialInputConfig{{Device: "/dev/ttyUSB0", Baud: 3000000, Format: "beast", Clock: "12mhz"},
+		{Device: "/dev/ttyACM0", Baud: 921600, Format: "avr", Clock: "12mhz", Receiver: "gns"}}, cfg.SerialInputs)
+
+	for config, want := range map[string]string{
+		"serial_inputs:\n  - baud: 921600\n":                                   "serial_inputs entries require a device",
+		"serial_inputs:\n  - device: /dev/ttyUSB0\n  - device: /dev/ttyUSB0\n": "duplicate serial_inputs device: /dev/ttyUSB0",
+		"serial_inputs:\n  - device: /dev/ttyUSB0\n    baud: 12345\n":          "serial input /dev/ttyUSB0: unsupported baud rate 12345",
+	} {
+		t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, config))
+		_, err = Load()
+		assert.ErrorContains(t, err, want)
+	}
+}
+
+func TestLoad_Email(t *testing.T) {
+	t.Setenv("SMTP_PASSWORD", "s3cret")
+	t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, `notify:
+  email:
+    addr: smtp.example.com:587
+    username: pi
+    password: ${SMTP_PASSWORD}
+    from: "flight_trmnl <pi@example.com>"
+    to: [ops@example.com]
+    types: [emergency]
+    weekly_report:
+      enabled: true
+      day: friday
+      hour: 18
+`))
+	cfg, err := Load()
+	require.NoError(t, err)
+	email := cfg.Notify.Email
+	assert.Equal(t, "s3cret", email.Password)
+	assert.Equal(t, "[redacted]", cfg.Redacted().Notify.Email.Password)
+	assert.Equal(t, "info", email.MinSeverity)
+	assert.Equal(t, time.Friday, email.WeeklyReport.Weekday())
+	assert.Equal(t, 18, email.WeeklyReport.Hour)
+
+	base := "notify:\n  email:\n    addr: smtp.example.com:587\n    from: pi@example.com\n"
+	for content, problem := range map[string]string{
+		"notify:\n  email:\n    types: [emergency]\n": "notify.email.types and weekly_report need notify.email.addr",
+		base:                                "notify.email.to requires at least one recipient",
+		base + "    to: [not an address]\n": "notify.email.to: invalid address not an address",
+		base + "    to: [ops@example.com]\n    types: [landing]\n":                   `notify.email.types[0]: invalid value "landing"`,
+		base + "    to: [ops@example.com]\n    weekly_report:\n      day: someday\n": `notify.email.weekly_report.day: invalid value "someday"`,
+	} {
+		t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, content))
+		_, err = Load()
+		assert.ErrorContains(t, err, problem)
+	}
+}
+
+func TestLoad_Replay(t *testing.T) {
+	t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, `beast_addr: ""
+replay:
+  file: capture.bin
+  speed: 2.5
+`))
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, ReplayConfig{File: "capture.bin", Speed: 2.5}, cfg.Replay)
+
+	for content, problem := range map[string]string{
+		"replay:\n  file: capture.bin\ncatch_up_file: backlog.bin\n": "replay.file and catch_up_file can't be used together",
+		"replay:\n  file: capture.bin\n  speed: -1\n":                "replay.speed must not be negative",
+	} {
+		t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, content))
+		_, err = Load()
+		assert.ErrorContains(t, err, problem)
+	}
+
+	t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, "replay:\n  file: capture.bin\n"))
+	t.Setenv("FLIGHT_TRMNL_REPLAY_SPEED", "0")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Zero(t, cfg.Replay.Speed, "as fast as possible")
+}
+
+func TestLoad_Receivers(t *testing.T) {
+	t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, `beast_addr: ""
+receivers:
+  - id: roof
+    addr: "192.168.1.20:30005"
+  - id: shed
+    addr: "192.168.1.21:30002"
+    format: avr
+    clock: gps
+`))
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, []ReceiverConfig{
+		{ID: "roof", Addr: "192.168.1.20:30005", Format: "beast", Clock: "12mhz"},
+		{ID: "shed", Addr: "192.168.1.21:30002", Format: "avr", Clock: "gps"},
+	}, cfg.Receivers)
+
+	for content, problem := range map[string]string{
+		"receivers:\n  - id: roof\n    addr: \"a:1\"\n  - id: roof\n    addr: \"b:1\"\n":             "duplicate receivers id: roof",
+		"receivers:\n  - id: \"roof top\"\n    addr: \"a:1\"\n":                                      `require an id of letters, digits, - and _: "roof top"`,
+		"beast_addr: \"localhost:30005\"\nreceivers:\n  - id: roof\n    addr: \"localhost:30005\"\n": "receiver roof: localhost:30005 is already read",
+		"receivers:\n  - id: roof\n": "receiver roof: addr is required",
+	} {
+		t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, content))
+		_, err = Load()
+		assert.ErrorContains(t, err, problem)
+	}
+}
+
+func TestLoad_BeastTransport(t *testing.T) {
+	t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, `beast_addr: "localhost:30005"
+beast_transport:
+  ssh: "ssh://pi@receiver.example.com:2222"
+  tls: true
+  server_name: receiver.example.com
+`))
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, TransportConfig{SSH: "ssh://pi@receiver.example.com:2222", TLS: true, ServerName: "receiver.example.com"}, cfg.BeastTransport)
+
+	t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, "beast_transport:\n  ssh: pi@receiver.example.com\n"))
+	_, err = Load()
+	assert.ErrorContains(t, err, "beast_transport.ssh: invalid ssh tunnel")
+
+	t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, "beast_transport:\n  ca_file: ca.pem\n"))
+	_, err = Load()
+	assert.ErrorContains(t, err, "need beast_transport.tls")
+}
+
+func TestLoad_AircraftJSON(t *testing.T) {
+	// Enough on its own, without beast_addr
+	t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, `beast_addr: ""
+aircraft_json:
+  url: "http://raspberrypi.local/tar1090/data/aircraft.json"
+  interval: 2
+`))
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, AircraftJSONConfig{URL: "http://raspberrypi.local/tar1090/data/aircraft.json", Interval: 2}, cfg.AircraftJSON)
+
+	t.Setenv("FLIGHT_TRMNL_CONFIG_PATH", writeConfig(t, "aircraft_json:\n  url: raspberrypi.local/data/aircraft.json\n"))
+	_, err = Load()
+	assert.ErrorContains(t, err, "aircraft_json.url must be an http(s) URL")
+}
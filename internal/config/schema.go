@@ -0,0 +1,497 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"flight_trmnl/internal/database"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fieldKind is the YAML shape a config key accepts
+type fieldKind int
+
+const (
+	kindString      fieldKind = iota // Any scalar, viper converts numbers and booleans to strings
+	kindInt                          // Integer scalar
+	kindNumber                       // Integer or decimal scalar
+	kindBool                         // Boolean scalar
+	kindStringList                   // Sequence of scalars
+	kindStringMap                    // Mapping of scalar values, keys are free-form
+	kindSection                      // Mapping of known keys
+	kindSectionList                  // Sequence of mappings of known keys
+)
+
+var kindNames = map[fieldKind]string{
+	kindString:      "a string",
+	kindInt:         "an integer",
+	kindNumber:      "a number",
+	kindBool:        "true or false",
+	kindStringList:  "a list",
+	kindStringMap:   "a mapping",
+	kindSection:     "a mapping",
+	kindSectionList: "a list of mappings",
+}
+
+// field describes one config key
+type field struct {
+	kind   fieldKind
+	fields schema   // Keys of a section, or of each entry of a section list
+	min    *int     // Lowest accepted integer
+	oneOf  []string // Accepted strings, or accepted entries of a string list
+}
+
+// schema maps config keys to their description
+type schema map[string]*field
+
+func str(oneOf ...string) *field     { return &field{kind: kindString, oneOf: oneOf} }
+func strList(oneOf ...string) *field { return &field{kind: kindStringList, oneOf: oneOf} }
+func boolean() *field                { return &field{kind: kindBool} }
+func strMap() *field                 { return &field{kind: kindStringMap} }
+func section(s schema) *field        { return &field{kind: kindSection, fields: s} }
+func sectionList(s schema) *field    { return &field{kind: kindSectionList, fields: s} }
+func integer(min int) *field         { return &field{kind: kindInt, min: &min} }
+func number() *field                 { return &field{kind: kindNumber} }
+
+// configSchema lists every key Load reads; keep it in sync when adding settings
+var configSchema = schema{
+	"beast_addr":   str(),
+	"beast_clock":  str("12mhz", "gps"),
+	"beast_format": str("beast", "avr"),
+	"beast_transport": section(schema{
+		"ssh":         str(),
+		"tls":         boolean(),
+		"ca_file":     str(),
+		"server_name": str(),
+	}),
+	"catch_up_file": str(),
+	"replay": section(schema{
+		"file":  str(),
+		"speed": number(),
+	}),
+	"aircraft_json": section(schema{
+		"url":      str(),
+		"interval": integer(1),
+	}),
+	"udp_inputs": sectionList(schema{
+		"addr":     str(),
+		"format":   str("beast", "avr"),
+		"clock":    str("12mhz", "gps"),
+		"receiver": str(),
+	}),
+	"serial_inputs": sectionList(schema{
+		"device":   str(),
+		"baud":     integer(1),
+		"format":   str("beast", "avr"),
+		"clock":    str("12mhz", "gps"),
+		"receiver": str(),
+	}),
+	"receivers": sectionList(schema{
+		"id":     str(),
+		"addr":   str(),
+		"format": str("beast", "avr"),
+		"clock":  str("12mhz", "gps"),
+	}),
+	"db_path":       str(),
+	"batch_size":    integer(1),
+	"batch_timeout": integer(1),
+	"storage_mode":  str(database.StorageRaw, database.StorageDecoded, database.StorageState),
+	"drop_corrupt":  boolean(),
+	"omit_columns":  strList(database.OmittableColumns...),
+	"encryption": section(schema{
+		"key":      str(),
+		"key_file": str(),
+	}),
+	"export": section(schema{
+		"salt":      str(),
+		"salt_file": str(),
+	}),
+	"location": section(schema{
+		"name":      str(),
+		"latitude":  number(),
+		"longitude": number(),
+	}),
+	"log": section(schema{
+		"level":     str("debug", "info", "warn", "error"),
+		"format":    str("text", "json"),
+		"crash_dir": str(),
+	}),
+	"metadata": section(schema{
+		"resolvers":        strList("database", "basestation", "opensky", "country"),
+		"cache_ttl":        integer(0),
+		"basestation_path": str(),
+		"opensky_url":      str(),
+	}),
+	"api": section(schema{
+		"enabled":   boolean(),
+		"addr":      str(),
+		"photo_url": str(),
+		"cache_ttl": integer(0),
+		"cors": section(schema{
+			"allowed_origins": strList(),
+			"allowed_headers": strList(),
+			"max_age":         integer(0),
+		}),
+	}),
+	"tracker": section(schema{
+		"expiry":             integer(1),
+		"snapshot_interval":  integer(0),
+		"snapshot_retention": integer(0),
+	}),
+	"dataset": section(schema{
+		"paths":   strList(),
+		"format":  str("opensky", "opensky-legacy"),
+		"columns": strMap(),
+	}),
+	"enrichment": section(schema{
+		"enabled":      boolean(),
+		"resolvers":    strList("basestation", "opensky"),
+		"interval":     integer(1),
+		"lookup_delay": integer(0),
+		"max_attempts": integer(1),
+	}),
+	"coverage": section(schema{
+		"enabled":         boolean(),
+		"url":             str(),
+		"api_key":         str(),
+		"api_key_file":    str(),
+		"api_key_header":  str(),
+		"radius":          integer(1),
+		"interval":        integer(1),
+		"sectors":         integer(4),
+		"terrain_dir":     str(),
+		"antenna_height":  integer(0),
+		"target_altitude": integer(1),
+	}),
+	"scheduler": section(schema{
+		"jitter": integer(0),
+	}),
+	"tags": section(schema{
+		"refresh_interval": integer(1),
+		"lists": sectionList(schema{
+			"name": str(),
+			"url":  str(),
+		}),
+	}),
+	"privacy": section(schema{
+		"blocked":     strList(),
+		"block_lists": strList(),
+		"outputs": section(schema{
+			"api":    str("show", "anonymize", "exclude"),
+			"trmnl":  str("show", "anonymize", "exclude"),
+			"notify": str("show", "anonymize", "exclude"),
+		}),
+	}),
+	"links": section(schema{
+		"base_url": str(),
+		"trackers": strList("adsbexchange", "fr24", "opensky", "planefinder"),
+		"custom":   strMap(),
+		"qr":       str(),
+	}),
+	"notify": section(schema{
+		"webhooks": sectionList(schema{
+			"name":                str(),
+			"url":                 str(),
+			"url_file":            str(),
+			"secret":              str(),
+			"secret_file":         str(),
+			"types":               strList(),
+			"min_severity":        str("info", "warning", "critical"),
+			"dedupe_window":       integer(0),
+			"aggregate_window":    integer(0),
+			"digest_interval":     integer(0),
+			"digest_max_severity": str("info", "warning", "critical"),
+			"where":               str(),
+		}),
+		"retry": section(schema{
+			"max_attempts":    integer(1),
+			"initial_backoff": integer(1),
+			"max_backoff":     integer(1),
+		}),
+		"dead_letter_path": str(),
+		"email": section(schema{
+			"addr":          str(),
+			"tls":           boolean(),
+			"username":      str(),
+			"password":      str(),
+			"password_file": str(),
+			"from":          str(),
+			"to":            strList(),
+			"types":         strList("new_aircraft", "alert", "geofence", "emergency", "advisory", "maintenance", "record", "deep_dive"),
+			"min_severity":  str("info", "warning", "critical"),
+			"weekly_report": section(schema{
+				"enabled": boolean(),
+				"day":     str("sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"),
+				"hour":    integer(0),
+			}),
+		}),
+	}),
+	"sync": section(schema{
+		"to":             str(),
+		"source":         str(),
+		"interval":       integer(1),
+		"rate_limit":     integer(0),
+		"tables":         strList("beast_messages", "events", "flights", "resolution_advisories", "connection_events", "coverage_checks"),
+		"remote_command": str(),
+	}),
+	"station": section(schema{
+		"hub_url":    str(),
+		"name":       str(),
+		"token":      str(),
+		"token_file": str(),
+		"ca_file":    str(),
+		"where":      str(),
+	}),
+	"hub": section(schema{
+		"enabled":       boolean(),
+		"addr":          str(),
+		"push_addr":     str(),
+		"tls_cert_file": str(),
+		"tls_key_file":  str(),
+		"rate_limit":    integer(0),
+		"stations": sectionList(schema{
+			"name":       str(),
+			"token":      str(),
+			"token_file": str(),
+			"rate_limit": integer(0),
+		}),
+	}),
+	"maintenance": section(schema{
+		"enabled":          boolean(),
+		"rate_drop":        integer(1),
+		"min_baseline":     integer(0),
+		"parse_error_rate": integer(1),
+		"silence":          integer(1),
+		"capture_dir":      str(),
+	}),
+	"deep_dive": section(schema{
+		"enabled":      boolean(),
+		"wait":         integer(1),
+		"max_per_hour": integer(0),
+	}),
+	"trmnl": section(schema{
+		"layouts_dir": str(),
+		"profiles": sectionList(schema{
+			"name":             str(),
+			"webhook_url":      str(),
+			"webhook_url_file": str(),
+			"layout":           str(),
+			"refresh_interval": integer(minTRMNLRefresh),
+			"favorites":        strList(),
+			"qr":               boolean(),
+			"filter": section(schema{
+				"icao":       strList(),
+				"type":       strList(),
+				"band":       strList("surface", "low", "mid", "high"),
+				"min_signal": integer(0),
+				"where":      str(),
+			}),
+		}),
+	}),
+}
+
+// checkFile validates a YAML config file against the schema, reporting every problem with its key path and line
+// Viper silently ignores unknown keys, so a typo like "beast_adress" would otherwise fall back to the default.
+func checkFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil // Empty file
+	}
+
+	var problems []error
+	report := func(node *yaml.Node, key, format string, args ...any) {
+		problems = append(problems, fmt.Errorf("%s:%d: %s: %s", path, node.Line, key, fmt.Sprintf(format, args...)))
+	}
+	checkSection(doc.Content[0], "", configSchema, report)
+	return errors.Join(problems...)
+}
+
+type reportFunc func(node *yaml.Node, key, format string, args ...any)
+
+// checkSection checks the keys of a mapping node against a schema
+func checkSection(node *yaml.Node, path string, s schema, report reportFunc) {
+	node = resolveAlias(node)
+	if node.Kind != yaml.MappingNode {
+		report(node, displayPath(path), "must be a mapping")
+		return
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		key := joinPath(path, keyNode.Value)
+		if keyNode.Value == "<<" {
+			continue // YAML merge key, the merged mapping was checked where its anchor was defined
+		}
+
+		// Viper lowercases keys, so matching is case-insensitive
+		f, ok := s[strings.ToLower(keyNode.Value)]
+		if !ok {
+			if suggestion := closestKey(strings.ToLower(keyNode.Value), s); suggestion != "" {
+				report(keyNode, key, "unknown key (did you mean %s?)", suggestion)
+			} else {
+				report(keyNode, key, "unknown key")
+			}
+			continue
+		}
+		checkField(valueNode, key, f, report)
+	}
+}
+
+// checkField checks one value against its description; null values leave the default in place
+func checkField(node *yaml.Node, key string, f *field, report reportFunc) {
+	node = resolveAlias(node)
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+
+	switch f.kind {
+	case kindString:
+		if node.Kind != yaml.ScalarNode {
+			report(node, key, "must be %s", kindNames[f.kind])
+			return
+		}
+		checkOneOf(node, key, f.oneOf, report)
+
+	case kindInt:
+		var n int
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!int" || node.Decode(&n) != nil {
+			report(node, key, "must be %s, got %q", kindNames[f.kind], node.Value)
+			return
+		}
+		if f.min != nil && n < *f.min {
+			report(node, key, "must be at least %d, got %d", *f.min, n)
+		}
+
+	case kindNumber:
+		if node.Kind != yaml.ScalarNode || (node.Tag != "!!int" && node.Tag != "!!float") {
+			report(node, key, "must be %s, got %q", kindNames[f.kind], node.Value)
+		}
+
+	case kindBool:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!bool" {
+			report(node, key, "must be %s, got %q", kindNames[f.kind], node.Value)
+		}
+
+	case kindStringList:
+		if node.Kind != yaml.SequenceNode {
+			report(node, key, "must be %s", kindNames[f.kind])
+			return
+		}
+		for i, item := range node.Content {
+			itemKey := fmt.Sprintf("%s[%d]", key, i)
+			if item.Kind != yaml.ScalarNode {
+				report(item, itemKey, "must be %s", kindNames[kindString])
+				continue
+			}
+			checkOneOf(item, itemKey, f.oneOf, report)
+		}
+
+	case kindStringMap:
+		if node.Kind != yaml.MappingNode {
+			report(node, key, "must be %s", kindNames[f.kind])
+			return
+		}
+		for i := 1; i < len(node.Content); i += 2 {
+			if node.Content[i].Kind != yaml.ScalarNode {
+				report(node.Content[i], joinPath(key, node.Content[i-1].Value), "must be %s", kindNames[kindString])
+			}
+		}
+
+	case kindSection:
+		checkSection(node, key, f.fields, report)
+
+	case kindSectionList:
+		if node.Kind != yaml.SequenceNode {
+			report(node, key, "must be %s", kindNames[f.kind])
+			return
+		}
+		for i, item := range node.Content {
+			checkSection(item, fmt.Sprintf("%s[%d]", key, i), f.fields, report)
+		}
+	}
+}
+
+func checkOneOf(node *yaml.Node, key string, oneOf []string, report reportFunc) {
+	if len(oneOf) == 0 {
+		return
+	}
+	for _, v := range oneOf {
+		if node.Value == v {
+			return
+		}
+	}
+	report(node, key, "invalid value %q (must be one of %s)", node.Value, strings.Join(oneOf, ", "))
+}
+
+// closestKey suggests the known key a typo was probably meant to be, or "" when none is close
+func closestKey(key string, s schema) string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names) // Deterministic choice between equally close keys
+
+	// Allow two edits, or a third of a long key (e.g. "beast_adress" is four edits from "beast_addr")
+	best, bestDistance := "", max(3, len(key)/3+1)
+	for _, name := range names {
+		if d := editDistance(key, name); d < bestDistance {
+			best, bestDistance = name, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between two keys
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// resolveAlias returns the node an alias (*name) refers to
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "config"
+	}
+	return path
+}
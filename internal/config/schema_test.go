@@ -0,0 +1,72 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestCheckFile_Example(t *testing.T) {
+	assert.NoError(t, checkFile("../../config.yaml.example"))
+
+	// The commented-out examples (profiles, webhooks, lists, columns) must be valid too once uncommented
+	data, err := os.ReadFile("../../config.yaml.example")
+	require.NoError(t, err)
+	uncommented := regexp.MustCompile(`(?m)^(\s*)# ?(\s*(- )?[a-z_0-9]+:( .*)?)$`).ReplaceAllString(string(data), "$1$2")
+	uncommented = strings.NewReplacer("lists: []", "lists:", "webhooks: []", "webhooks:", "profiles: []", "profiles:", "stations: []", "stations:", "udp_inputs: []", "udp_inputs:", "serial_inputs: []", "serial_inputs:", "receivers: []", "receivers:").Replace(uncommented)
+	assert.NoError(t, checkFile(writeConfig(t, uncommented)))
+}
+
+func TestCheckFile_Problems(t *testing.T) {
+	path := writeConfig(t, `beast_adress: "localhost:30005"
+batch_size: ten
+API:
+  enabled: yes please
+tracker:
+  expiry: 0
+privacy:
+  outputs:
+    api: hide
+notify:
+  webhooks:
+    - name: ha
+      min_severty: warning
+`)
+
+	err := checkFile(path)
+	require.Error(t, err)
+	lines := strings.Split(err.Error(), "\n")
+	assert.Equal(t, []string{
+		path + ":1: beast_adress: unknown key (did you mean beast_addr?)",
+		path + `:2: batch_size: must be an integer, got "ten"`,
+		path + `:4: API.enabled: must be true or false, got "yes please"`,
+		path + ":6: tracker.expiry: must be at least 1, got 0",
+		path + `:9: privacy.outputs.api: invalid value "hide" (must be one of show, anonymize, exclude)`,
+		path + ":13: notify.webhooks[0].min_severty: unknown key (did you mean min_severity?)",
+	}, lines)
+}
+
+func TestCheckFile_NullsAndAliases(t *testing.T) {
+	path := writeConfig(t, `log:
+defaults: &retry
+  max_attempts: 3
+notify:
+  retry: *retry
+`)
+	err := checkFile(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), ":2: defaults: unknown key")
+	assert.NotContains(t, err.Error(), "log", "empty sections keep their defaults")
+	assert.NotContains(t, err.Error(), "notify", "aliases are checked like the values they refer to")
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Starter holds the answers of a first-run setup
+type Starter struct {
+	BeastAddr         string
+	Location          LocationConfig // Left at the example's unset location when not set
+	TRMNLWebhookURL   string         // Adds a "display" profile with the nearest layout when set
+	StorageMode       string
+	SnapshotRetention int
+}
+
+// RenderStarter fills a first-run setup's answers into the example config, keeping its comments
+// so the written file still documents every other setting.
+func RenderStarter(example string, s Starter) (string, error) {
+	type replacement struct {
+		key     string
+		pattern string // Matches the example's lines for key
+		value   string
+	}
+	replacements := []replacement{
+		{"beast_addr", `^beast_addr: .*$`, "beast_addr: " + strconv.Quote(s.BeastAddr)},
+		{"storage_mode", `^storage_mode: .*$`, "storage_mode: " + s.StorageMode},
+		{"tracker.snapshot_retention", `^  snapshot_retention: .*$`, "  snapshot_retention: " + strconv.Itoa(s.SnapshotRetention)},
+	}
+	if s.Location.IsSet() {
+		replacements = append(replacements, replacement{"location", `^location:\n  name: .*\n  latitude: .*\n  longitude: .*$`,
+			fmt.Sprintf("location:\n  name: %s\n  latitude: %s\n  longitude: %s",
+				strconv.Quote(s.Location.Name),
+				strconv.FormatFloat(s.Location.Latitude, 'f', -1, 64),
+				strconv.FormatFloat(s.Location.Longitude, 'f', -1, 64))})
+	}
+	if s.TRMNLWebhookURL != "" {
+		replacements = append(replacements, replacement{"trmnl.profiles", `^  profiles: \[\]$`,
+			"  profiles:\n    - name: display\n      webhook_url: " + strconv.Quote(s.TRMNLWebhookURL) +
+				"\n      layout: nearest\n      refresh_interval: 900"})
+	}
+
+	out := example
+	for _, r := range replacements {
+		pattern := regexp.MustCompile(`(?m)` + r.pattern)
+		loc := pattern.FindStringIndex(out)
+		if loc == nil {
+			return "", fmt.Errorf("example config has no %s to set", r.key)
+		}
+		out = out[:loc[0]] + r.value + out[loc[1]:]
+	}
+	return out, nil
+}
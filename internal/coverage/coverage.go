@@ -0,0 +1,250 @@
+// Package coverage measures how complete the receiver's view of the sky is by comparing the aircraft it hears with
+// those an ADS-B aggregator such as ADS-B Exchange reports around it
+package coverage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/decoder"
+	"flight_trmnl/internal/tracker"
+)
+
+// DefaultURL is the ADS-B Exchange API for aircraft within a radius; {lat}, {lon}, and {dist} (nautical miles) are
+// filled in. Aggregators with the same response format, such as adsb.lol and airplanes.live, work too.
+const DefaultURL = "https://adsbexchange.com/api/aircraft/v2/lat/{lat}/lon/{lon}/dist/{dist}/"
+
+// maxPositionAge is how old an aggregator position may be for the aircraft to count as expected
+const maxPositionAge = 60 * time.Second
+
+// blindCompleteness is the completeness below which a sector with enough expected aircraft counts as blind
+const blindCompleteness = 0.5
+
+// minBlindExpected is how many aircraft a sector must have been expected to hear before it can count as blind
+const minBlindExpected = 10
+
+// Target is an aircraft an aggregator reports
+type Target struct {
+	ICAO     string
+	Position decoder.Position
+}
+
+// Client asks an aggregator for the aircraft around a position
+type Client struct {
+	url    string // Template, see DefaultURL
+	header string // Header carrying the API key
+	key    string
+	client *http.Client
+}
+
+// NewClient creates an aggregator client; the API key is sent in header when set
+func NewClient(urlTemplate, header, key string) *Client {
+	return &Client{url: urlTemplate, header: header, key: key, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Source is the host of the aggregator, recorded with each check
+func (c *Client) Source() string {
+	u, err := url.Parse(strings.NewReplacer("{lat}", "0", "{lon}", "0", "{dist}", "0").Replace(c.url))
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// aggregatorResponse is the readsb-style response of ADS-B Exchange v2 compatible APIs
+type aggregatorResponse struct {
+	Aircraft []struct {
+		Hex     string   `json:"hex"`
+		Lat     *float64 `json:"lat"`
+		Lon     *float64 `json:"lon"`
+		SeenPos *float64 `json:"seen_pos"` // Seconds since the position was received
+	} `json:"ac"`
+}
+
+// Fetch returns the ICAO-addressed aircraft with a recent position within radius kilometres of center
+func (c *Client) Fetch(ctx context.Context, center decoder.Position, radius float64) ([]Target, error) {
+	u := strings.NewReplacer(
+		"{lat}", strconv.FormatFloat(center.Latitude, 'f', 4, 64),
+		"{lon}", strconv.FormatFloat(center.Longitude, 'f', 4, 64),
+		"{dist}", strconv.Itoa(int(math.Ceil(radius/1.852))),
+	).Replace(c.url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create aggregator request: %w", err)
+	}
+	if c.key != "" {
+		req.Header.Set(c.header, c.key)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("aggregator request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("aggregator returned status %d", resp.StatusCode)
+	}
+
+	var body aggregatorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode aggregator response: %w", err)
+	}
+	var targets []Target
+	for _, ac := range body.Aircraft {
+		// Non-ICAO addresses (TIS-B, anonymous) start with ~ and can't be matched
+		if strings.HasPrefix(ac.Hex, "~") || ac.Lat == nil || ac.Lon == nil {
+			continue
+		}
+		if ac.SeenPos != nil && time.Duration(*ac.SeenPos*float64(time.Second)) > maxPositionAge {
+			continue
+		}
+		position := decoder.Position{Latitude: *ac.Lat, Longitude: *ac.Lon}
+		if decoder.Distance(center, position) > radius {
+			continue
+		}
+		targets = append(targets, Target{ICAO: strings.ToUpper(ac.Hex), Position: position})
+	}
+	return targets, nil
+}
+
+// Compare counts which of the aggregator's targets the receiver was hearing, overall and in count sectors by bearing
+// from the receiver, the first centred on north. Each sector also gets the range of the farthest aircraft heard in it.
+func Compare(receiver decoder.Position, targets []Target, heard []tracker.AircraftState, count int) *database.CoverageCheck {
+	width := 360 / float64(count)
+	check := &database.CoverageCheck{Sectors: make([]database.CoverageSector, count)}
+	for i := range check.Sectors {
+		check.Sectors[i].Bearing = float64(i) * width
+	}
+	sectorOf := func(p decoder.Position) *database.CoverageSector {
+		bearing := decoder.Bearing(receiver, p)
+		return &check.Sectors[int(math.Floor(math.Mod(bearing+width/2, 360)/width))%count]
+	}
+
+	local := make(map[string]bool, len(heard))
+	for _, state := range heard {
+		local[strings.ToUpper(state.ICAO)] = true
+		if state.Latitude != nil && state.Longitude != nil {
+			position := decoder.Position{Latitude: *state.Latitude, Longitude: *state.Longitude}
+			sector := sectorOf(position)
+			sector.Range = max(sector.Range, decoder.Distance(receiver, position))
+		}
+	}
+	for _, t := range targets {
+		sector := sectorOf(t.Position)
+		check.Expected++
+		sector.Expected++
+		if local[t.ICAO] {
+			check.Seen++
+			sector.Seen++
+		}
+	}
+	return check
+}
+
+// Summary is the coverage over a range of checks
+type Summary struct {
+	Checks       int                     `json:"checks"`
+	Expected     int                     `json:"expected"`     // Summed over the checks
+	Seen         int                     `json:"seen"`         // Summed over the checks
+	Completeness float64                 `json:"completeness"` // Percent of the expected aircraft the receiver heard
+	Sectors      []SectorSummary         `json:"sectors"`
+	Latest       *database.CoverageCheck `json:"latest,omitempty"`
+}
+
+// SectorSummary is the coverage in one direction over a range of checks
+type SectorSummary struct {
+	database.CoverageSector
+	Completeness float64 `json:"completeness"`          // Percent, 0 when nothing was expected
+	Blind        bool    `json:"blind"`                 // Enough aircraft were expected, and fewer than half were heard
+	Theoretical  float64 `json:"theoretical,omitempty"` // Kilometres the terrain allows, see Overlay
+}
+
+// Summarize totals checks, oldest first. Sectors are summed by position, so checks with a different sector count
+// than the latest are left out of the sectors.
+func Summarize(checks []*database.CoverageCheck) *Summary {
+	s := &Summary{Checks: len(checks)}
+	if len(checks) == 0 {
+		return s
+	}
+	s.Latest = checks[len(checks)-1]
+	s.Sectors = make([]SectorSummary, len(s.Latest.Sectors))
+	for i, sector := range s.Latest.Sectors {
+		s.Sectors[i].Bearing = sector.Bearing
+	}
+	for _, c := range checks {
+		s.Expected += c.Expected
+		s.Seen += c.Seen
+		if len(c.Sectors) != len(s.Sectors) {
+			continue
+		}
+		for i, sector := range c.Sectors {
+			s.Sectors[i].Expected += sector.Expected
+			s.Sectors[i].Seen += sector.Seen
+			s.Sectors[i].Range = max(s.Sectors[i].Range, sector.Range)
+		}
+	}
+	s.Completeness = percent(s.Seen, s.Expected)
+	for i := range s.Sectors {
+		sector := &s.Sectors[i]
+		sector.Completeness = percent(sector.Seen, sector.Expected)
+		sector.Blind = sector.Expected >= minBlindExpected && sector.Completeness < blindCompleteness*100
+	}
+	return s
+}
+
+// Overlay sets each sector's theoretical range from a line-of-sight range per whole degree of bearing (see
+// terrain.Ranges), the farthest within the sector, so it can be set against the farthest aircraft actually heard
+func (s *Summary) Overlay(ranges []float64) {
+	if len(ranges) != 360 || len(s.Sectors) == 0 {
+		return
+	}
+	width := 360 / float64(len(s.Sectors))
+	for i := range s.Sectors {
+		sector := &s.Sectors[i]
+		sector.Theoretical = 0
+		for b := math.Ceil(sector.Bearing - width/2); b < sector.Bearing+width/2; b++ {
+			sector.Theoretical = max(sector.Theoretical, ranges[int(math.Mod(b+360, 360))])
+		}
+	}
+}
+
+func percent(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(whole)*1000) / 10
+}
+
+// Checker compares the tracker with the aggregator on a schedule and stores the results
+type Checker struct {
+	client   *Client
+	tracker  *tracker.Tracker
+	repo     database.CoverageRepository
+	receiver decoder.Position
+	radius   float64 // Kilometres
+	sectors  int
+}
+
+func NewChecker(client *Client, t *tracker.Tracker, repo database.CoverageRepository, receiver decoder.Position, radius float64, sectors int) *Checker {
+	return &Checker{client: client, tracker: t, repo: repo, receiver: receiver, radius: radius, sectors: sectors}
+}
+
+// Run is the coverage task: one check against the aircraft the tracker holds now
+func (c *Checker) Run(ctx context.Context) error {
+	targets, err := c.client.Fetch(ctx, c.receiver, c.radius)
+	if err != nil {
+		return err
+	}
+	check := Compare(c.receiver, targets, c.tracker.Snapshot(), c.sectors)
+	check.Time = time.Now()
+	check.Source = c.client.Source()
+	return c.repo.Insert(check)
+}
@@ -0,0 +1,115 @@
+package coverage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/decoder"
+	"flight_trmnl/internal/tracker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var receiver = decoder.Position{Latitude: 52.0, Longitude: 4.0}
+
+func TestClient_Fetch(t *testing.T) {
+	var path, key string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, key = r.URL.Path, r.Header.Get("api-auth")
+		w.Write([]byte(`{"ac": [
+			{"hex": "4840d6", "lat": 52.5, "lon": 4.0, "seen_pos": 1.5},
+			{"hex": "~1a2b3c", "lat": 52.1, "lon": 4.1},
+			{"hex": "485020"},
+			{"hex": "a05f21", "lat": 52.2, "lon": 4.2, "seen_pos": 300},
+			{"hex": "40621d", "lat": 55.0, "lon": 4.0}
+		]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL+"/v2/lat/{lat}/lon/{lon}/dist/{dist}/", "api-auth", "secret")
+	targets, err := client.Fetch(context.Background(), receiver, 100)
+	require.NoError(t, err)
+	assert.Equal(t, "/v2/lat/52.0000/lon/4.0000/dist/54/", path, "the radius is asked for in nautical miles")
+	assert.Equal(t, "secret", key)
+	require.Len(t, targets, 1, "non-ICAO addresses, aircraft without a recent position, and those outside the radius are left out")
+	assert.Equal(t, "4840D6", targets[0].ICAO)
+	assert.Equal(t, "127.0.0.1", client.Source())
+}
+
+func TestCompare(t *testing.T) {
+	targets := []Target{
+		{ICAO: "4840D6", Position: decoder.Position{Latitude: 52.5, Longitude: 4.0}},  // North
+		{ICAO: "485020", Position: decoder.Position{Latitude: 52.4, Longitude: 4.05}}, // North
+		{ICAO: "A05F21", Position: decoder.Position{Latitude: 51.5, Longitude: 4.0}},  // South
+	}
+	lat, lon := 53.0, 4.0 // North, about 111 km out
+	heard := []tracker.AircraftState{{ICAO: "4840D6"}, {ICAO: "A05F21"}, {ICAO: "ABCDEF", Latitude: &lat, Longitude: &lon}}
+
+	check := Compare(receiver, targets, heard, 4)
+	assert.Equal(t, 3, check.Expected)
+	assert.Equal(t, 2, check.Seen, "aircraft heard but unknown to the aggregator don't count")
+	require.Len(t, check.Sectors, 4)
+	assert.InDelta(t, 111.2, check.Sectors[0].Range, 0.1, "the farthest aircraft heard sets the range")
+	check.Sectors[0].Range = 0
+	assert.Equal(t, database.CoverageSector{Bearing: 0, Expected: 2, Seen: 1}, check.Sectors[0])
+	assert.Equal(t, database.CoverageSector{Bearing: 180, Expected: 1, Seen: 1}, check.Sectors[2])
+}
+
+func TestSummarize(t *testing.T) {
+	assert.Equal(t, 0, Summarize(nil).Checks)
+
+	check := func(north, south database.CoverageSector) *database.CoverageCheck {
+		return &database.CoverageCheck{
+			Expected: north.Expected + south.Expected,
+			Seen:     north.Seen + south.Seen,
+			Sectors:  []database.CoverageSector{north, {Bearing: 90}, south, {Bearing: 270}},
+		}
+	}
+	checks := []*database.CoverageCheck{
+		check(database.CoverageSector{Expected: 6, Seen: 1}, database.CoverageSector{Bearing: 180, Expected: 4, Seen: 4}),
+		check(database.CoverageSector{Expected: 6, Seen: 2}, database.CoverageSector{Bearing: 180, Expected: 4, Seen: 3}),
+		{Expected: 2, Seen: 2, Sectors: make([]database.CoverageSector, 8)},
+		check(database.CoverageSector{Expected: 1, Seen: 1}, database.CoverageSector{Bearing: 180, Expected: 1, Seen: 1}),
+	}
+
+	s := Summarize(checks)
+	assert.Equal(t, 4, s.Checks)
+	assert.Equal(t, 24, s.Expected)
+	assert.Equal(t, 14, s.Seen)
+	assert.Equal(t, 58.3, s.Completeness)
+	require.Len(t, s.Sectors, 4)
+	assert.Equal(t, 13, s.Sectors[0].Expected, "checks with other sectors are left out of the sectors")
+	assert.Equal(t, 30.8, s.Sectors[0].Completeness)
+	assert.True(t, s.Sectors[0].Blind)
+	assert.False(t, s.Sectors[2].Blind, "too few aircraft expected to tell")
+	assert.False(t, s.Sectors[1].Blind)
+	assert.Same(t, checks[3], s.Latest)
+}
+
+func TestSummary_Overlay(t *testing.T) {
+	s := Summarize([]*database.CoverageCheck{{Sectors: []database.CoverageSector{
+		{Bearing: 0, Range: 180}, {Bearing: 90, Range: 40}, {Bearing: 180, Range: 200}, {Bearing: 270, Range: 150},
+	}}})
+	ranges := make([]float64, 360)
+	for b := range ranges {
+		ranges[b] = 300
+	}
+	ranges[350] = 380
+	// A ridge to the east holds the range down across that sector
+	for b := 45; b < 135; b++ {
+		ranges[b] = min(ranges[b], 60)
+	}
+
+	s.Overlay(ranges)
+	assert.Equal(t, 380.0, s.Sectors[0].Theoretical, "north spans 315 to 45 degrees")
+	assert.Equal(t, 60.0, s.Sectors[1].Theoretical)
+	assert.Equal(t, 300.0, s.Sectors[2].Theoretical)
+	assert.Equal(t, 40.0, s.Sectors[1].Range)
+
+	s.Overlay(nil)
+	assert.Equal(t, 380.0, s.Sectors[0].Theoretical, "without a model the sectors are left alone")
+}
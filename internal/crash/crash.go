@@ -0,0 +1,221 @@
+package crash
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"flight_trmnl/internal/database"
+)
+
+// maxStacks bounds the dump of every goroutine's stack
+const maxStacks = 8 << 20
+
+// LogBuffer keeps the last lines written to it, so a crash report shows the log leading up to the panic
+type LogBuffer struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+// NewLogBuffer creates a buffer of the last size lines
+func NewLogBuffer(size int) *LogBuffer {
+	return &LogBuffer{lines: make([]string, size)}
+}
+
+func (b *LogBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		b.lines[b.next] = line
+		b.next = (b.next + 1) % len(b.lines)
+		b.full = b.full || b.next == 0
+	}
+	return len(p), nil
+}
+
+// Lines returns the buffered lines, oldest first
+func (b *LogBuffer) Lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.full {
+		return append([]string(nil), b.lines[:b.next]...)
+	}
+	return append(append([]string(nil), b.lines[b.next:]...), b.lines[:b.next]...)
+}
+
+// Options are what a crash report includes besides the stacks
+type Options struct {
+	Dir     string // Where reports are written, created when needed
+	Version string
+	Logs    *LogBuffer   // Recent log lines, nil leaves them out
+	Config  any          // Written as JSON, so secrets should already be redacted
+	DB      *database.DB // Database stats, nil leaves them out
+	DBPath  string       // Where the database is, for the size of its WAL
+}
+
+// Reporter writes diagnostic bundles for panics
+type Reporter struct {
+	opts    Options
+	started time.Time
+	mu      sync.Mutex // One report at a time when several goroutines panic together
+}
+
+// NewReporter creates a reporter; uptime in its reports counts from now
+func NewReporter(opts Options) *Reporter {
+	return &Reporter{opts: opts, started: time.Now()}
+}
+
+var defaultReporter atomic.Pointer[Reporter]
+
+// SetDefault makes Recover write its reports with r
+func SetDefault(r *Reporter) {
+	defaultReporter.Store(r)
+}
+
+// Recover writes a crash report when its goroutine is panicking, then panics again so the process still crashes
+// with Go's usual output; defer it at the top of long-running goroutines. Without a default reporter it only
+// panics again.
+func Recover() {
+	v := recover()
+	if v == nil {
+		return
+	}
+	if r := defaultReporter.Load(); r != nil {
+		if path, err := r.Write(v, debug.Stack()); err != nil {
+			slog.Error("Failed to write crash report", "error", err)
+		} else {
+			slog.Error("Crashed, wrote a crash report to attach to a bug report", "path", path, "panic", v)
+		}
+	}
+	panic(v)
+}
+
+// Go runs f in a goroutine that writes a crash report if it panics
+func Go(f func()) {
+	go func() {
+		defer Recover()
+		f()
+	}()
+}
+
+// Write writes a zip bundle of the panic value and stack, every goroutine's stack, the recent log, the config,
+// database stats, and build and runtime info, and returns its path
+func (r *Reporter) Write(v any, stack []byte) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if err := os.MkdirAll(r.opts.Dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create crash report directory: %w", err)
+	}
+	path := filepath.Join(r.opts.Dir, "crash-"+now.UTC().Format("20060102-150405")+".zip")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create crash report: %w", err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	add := func(name string, content []byte) error {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(content)
+		return err
+	}
+	addJSON := func(name string, value any) error {
+		content, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			content = []byte(fmt.Sprintf("%q\n", err.Error()))
+		}
+		return add(name, content)
+	}
+
+	files := []func() error{
+		func() error { return add("panic.txt", []byte(fmt.Sprintf("panic: %v\n\n%s", v, stack))) },
+		func() error { return add("goroutines.txt", allStacks()) },
+		func() error { return addJSON("info.json", r.info(now)) },
+	}
+	if r.opts.Logs != nil {
+		files = append(files, func() error {
+			return add("log.txt", []byte(strings.Join(r.opts.Logs.Lines(), "\n")+"\n"))
+		})
+	}
+	if r.opts.Config != nil {
+		files = append(files, func() error { return addJSON("config.json", r.opts.Config) })
+	}
+	if r.opts.DB != nil {
+		files = append(files, func() error { return addJSON("database.json", r.databaseStats()) })
+	}
+	for _, file := range files {
+		if err := file(); err != nil {
+			return "", fmt.Errorf("failed to write crash report: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return "", fmt.Errorf("failed to write crash report: %w", err)
+	}
+	return path, f.Sync()
+}
+
+// info describes the build and the process at the time of the crash
+func (r *Reporter) info(now time.Time) map[string]any {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	return map[string]any{
+		"version":    r.opts.Version,
+		"go":         runtime.Version(),
+		"os":         runtime.GOOS,
+		"arch":       runtime.GOARCH,
+		"started":    r.started.UTC(),
+		"crashed":    now.UTC(),
+		"uptime":     now.Sub(r.started).Round(time.Second).String(),
+		"goroutines": runtime.NumGoroutine(),
+		"memory": map[string]uint64{
+			"heap_alloc": mem.HeapAlloc,
+			"sys":        mem.Sys,
+			"num_gc":     uint64(mem.NumGC),
+		},
+	}
+}
+
+// databaseStats reads the database stats, with the error in their place when the database is stuck or broken
+func (r *Reporter) databaseStats() map[string]any {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	body := map[string]any{}
+	if stats, err := r.opts.DB.Stats(ctx); err != nil {
+		body["error"] = err.Error()
+	} else {
+		body["stats"] = stats
+	}
+	if info, err := os.Stat(r.opts.DBPath + "-wal"); err == nil {
+		body["wal_size"] = info.Size()
+	}
+	return body
+}
+
+// allStacks returns the stack of every goroutine
+func allStacks() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxStacks {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,111 @@
+package crash
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"flight_trmnl/internal/database"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogBuffer(t *testing.T) {
+	logs := NewLogBuffer(3)
+	assert.Empty(t, logs.Lines())
+
+	logs.Write([]byte("one\n"))
+	logs.Write([]byte("two\n"))
+	assert.Equal(t, []string{"one", "two"}, logs.Lines())
+
+	logs.Write([]byte("three\nfour\n"))
+	logs.Write([]byte("five\n"))
+	assert.Equal(t, []string{"three", "four", "five"}, logs.Lines())
+}
+
+// readBundle returns the contents of every file in a crash report
+func readBundle(t *testing.T, path string) map[string]string {
+	zr, err := zip.OpenReader(path)
+	require.NoError(t, err)
+	defer zr.Close()
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+func TestReporter_Write(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "adsb_data.db")
+	db, err := database.New(dbPath)
+	require.NoError(t, err)
+	defer db.Close()
+
+	logs := NewLogBuffer(10)
+	logs.Write([]byte("level=INFO msg=\"Connected to Beast server\"\n"))
+	reporter := NewReporter(Options{
+		Dir:     filepath.Join(dir, "crash_reports"),
+		Version: "v1.2.3",
+		Logs:    logs,
+		Config:  map[string]string{"beast_addr": "localhost:30005"},
+		DB:      db,
+		DBPath:  dbPath,
+	})
+
+	path, err := reporter.Write("index out of range", debug.Stack())
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "reports include the config")
+
+	files := readBundle(t, path)
+	assert.True(t, strings.HasPrefix(files["panic.txt"], "panic: index out of range\n"))
+	assert.Contains(t, files["panic.txt"], "TestReporter_Write")
+	assert.Contains(t, files["goroutines.txt"], "goroutine ")
+	assert.Equal(t, "level=INFO msg=\"Connected to Beast server\"\n", files["log.txt"])
+	assert.JSONEq(t, `{"beast_addr": "localhost:30005"}`, files["config.json"])
+
+	var body struct {
+		Version string `json:"version"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(files["info.json"]), &body))
+	assert.Equal(t, "v1.2.3", body.Version)
+
+	var stats struct {
+		Stats *database.Stats `json:"stats"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(files["database.json"]), &stats))
+	require.NotNil(t, stats.Stats)
+	assert.Equal(t, "wal", stats.Stats.JournalMode)
+}
+
+func TestRecover(t *testing.T) {
+	dir := t.TempDir()
+	SetDefault(NewReporter(Options{Dir: dir}))
+	defer SetDefault(nil)
+
+	assert.PanicsWithValue(t, "boom", func() {
+		defer Recover()
+		panic("boom")
+	}, "the panic continues after the report")
+	reports, err := filepath.Glob(filepath.Join(dir, "crash-*.zip"))
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.True(t, strings.HasPrefix(readBundle(t, reports[0])["panic.txt"], "panic: boom\n"))
+
+	assert.NotPanics(t, func() {
+		defer Recover()
+	})
+}
@@ -0,0 +1,79 @@
+// Package crypt encrypts sensitive values before they are stored, for databases kept on shared or cloud-synced disks
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeySize is the length of an AES-256 key in bytes
+const KeySize = 32
+
+// ErrDecrypt is returned for ciphertext that was tampered with or sealed with another key
+var ErrDecrypt = errors.New("failed to decrypt: wrong key or corrupted value")
+
+// Cipher encrypts and decrypts stored values; implementations must authenticate what they decrypt
+type Cipher interface {
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+// ParseKey decodes a 32-byte key given as 64 hex digits or as base64
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if key, err := hex.DecodeString(s); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	return nil, fmt.Errorf("the key must be %d bytes, as %d hex digits or base64 (e.g. from openssl rand -hex %d)",
+		KeySize, 2*KeySize, KeySize)
+}
+
+// aesGCM seals values with AES-256-GCM, each with a random nonce stored in front of it
+type aesGCM struct {
+	aead cipher.AEAD
+}
+
+// NewAES creates an AES-256-GCM cipher
+func NewAES(key []byte) (Cipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("the key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &aesGCM{aead: aead}, nil
+}
+
+func (c *aesGCM) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plaintext)+c.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (c *aesGCM) Decrypt(ciphertext []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	if len(ciphertext) < n+c.aead.Overhead() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := c.aead.Open(nil, ciphertext[:n], ciphertext[n:], nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,59 @@
+package crypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAES(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, KeySize)
+	c, err := NewAES(key)
+	require.NoError(t, err)
+
+	sealed, err := c.Encrypt([]byte("8d4840d6202cc371c32ce0576098"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "8d4840d6")
+	again, err := c.Encrypt([]byte("8d4840d6202cc371c32ce0576098"))
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again, "each value gets its own nonce")
+
+	opened, err := c.Decrypt(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "8d4840d6202cc371c32ce0576098", string(opened))
+
+	sealed[len(sealed)-1] ^= 1
+	_, err = c.Decrypt(sealed)
+	assert.ErrorIs(t, err, ErrDecrypt, "tampering is detected")
+
+	other, err := NewAES(bytes.Repeat([]byte{8}, KeySize))
+	require.NoError(t, err)
+	_, err = other.Decrypt(again)
+	assert.ErrorIs(t, err, ErrDecrypt)
+	_, err = other.Decrypt([]byte{1, 2})
+	assert.ErrorIs(t, err, ErrDecrypt)
+
+	_, err = NewAES([]byte("short"))
+	assert.Error(t, err)
+}
+
+func TestParseKey(t *testing.T) {
+	key := bytes.Repeat([]byte{0xAB}, KeySize)
+
+	parsed, err := ParseKey(hex.EncodeToString(key) + "\n")
+	require.NoError(t, err)
+	assert.Equal(t, key, parsed)
+
+	parsed, err = ParseKey(base64.StdEncoding.EncodeToString(key))
+	require.NoError(t, err)
+	assert.Equal(t, key, parsed)
+
+	for _, bad := range []string{"", "abcd", "correct horse battery staple", hex.EncodeToString(key[:16])} {
+		_, err := ParseKey(bad)
+		assert.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Advisory is a TCAS resolution advisory an aircraft broadcast, one row per change in what it was told
+type Advisory struct {
+	ID              int64     `json:"id"`
+	Time            time.Time `json:"time"`
+	ICAO            string    `json:"icao"`
+	Callsign        string    `json:"callsign,omitempty"`
+	Altitude        *int      `json:"altitude,omitempty"` // Feet, when known
+	ARA             int       `json:"ara"`                // Active resolution advisories, the raw 14 bits
+	RAC             int       `json:"rac"`                // Resolution advisory complements, the raw 4 bits
+	Summary         string    `json:"summary"`            // e.g. "Climb, corrective"
+	Terminated      bool      `json:"terminated"`
+	MultipleThreats bool      `json:"multiple_threats"`
+	ThreatICAO      string    `json:"threat_icao,omitempty"`
+	ThreatCallsign  string    `json:"threat_callsign,omitempty"` // When the station was tracking the threat
+	ThreatAltitude  *int      `json:"threat_altitude,omitempty"` // Feet, when the station was tracking the threat
+}
+
+type AdvisoryRepository interface {
+	Insert(advisory *Advisory) error
+	List(icao string, since time.Time, limit int) ([]*Advisory, error)
+}
+
+type advisoryRepository struct {
+	db *sql.DB
+}
+
+func NewAdvisoryRepository(db *sql.DB) AdvisoryRepository {
+	return &advisoryRepository{db: db}
+}
+
+func (r *advisoryRepository) Insert(advisory *Advisory) error {
+	result, err := r.db.Exec(`INSERT INTO resolution_advisories
+		(time, icao, callsign, altitude, ara, rac, summary, terminated, multiple_threats, threat_icao,
+			threat_callsign, threat_altitude)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		advisory.Time.UTC(), advisory.ICAO, advisory.Callsign, advisory.Altitude, advisory.ARA, advisory.RAC,
+		advisory.Summary, advisory.Terminated, advisory.MultipleThreats, advisory.ThreatICAO,
+		advisory.ThreatCallsign, advisory.ThreatAltitude)
+	if err != nil {
+		return fmt.Errorf("failed to record resolution advisory: %w", err)
+	}
+	advisory.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// List returns the advisories since a time, newest first; an empty icao lists every aircraft's, otherwise those
+// the aircraft received or was the threat in
+func (r *advisoryRepository) List(icao string, since time.Time, limit int) ([]*Advisory, error) {
+	rows, err := r.db.Query(`SELECT id, time, icao, callsign, altitude, ara, rac, summary, terminated,
+			multiple_threats, threat_icao, threat_callsign, threat_altitude
+		FROM resolution_advisories WHERE time >= ? AND (? = '' OR icao = ? OR threat_icao = ?)
+		ORDER BY time DESC, id DESC LIMIT ?`,
+		since.UTC(), icao, icao, icao, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query resolution advisories: %w", err)
+	}
+	defer rows.Close()
+
+	var advisories []*Advisory
+	for rows.Next() {
+		a := &Advisory{}
+		var altitude, threatAltitude sql.NullInt64
+		if err := rows.Scan(&a.ID, &a.Time, &a.ICAO, &a.Callsign, &altitude, &a.ARA, &a.RAC, &a.Summary,
+			&a.Terminated, &a.MultipleThreats, &a.ThreatICAO, &a.ThreatCallsign, &threatAltitude); err != nil {
+			return nil, fmt.Errorf("failed to scan resolution advisory: %w", err)
+		}
+		a.Altitude, a.ThreatAltitude = nullInt(altitude), nullInt(threatAltitude)
+		advisories = append(advisories, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read resolution advisories: %w", err)
+	}
+	return advisories, nil
+}
@@ -1,20 +1,28 @@
 package database
 
 import (
+	"archive/zip"
+	"compress/gzip"
 	"database/sql"
 	"encoding/csv"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 
 	"flight_trmnl/internal/models"
 )
 
 type AircraftRepository interface {
 	InsertBatch(aircraft []*models.Aircraft) error
+	MergeCurated(aircraft []*models.Aircraft) error
+	Get(icao24 string) (*models.Aircraft, error)
 	IsTablePopulated() (bool, error)
-	LoadFromMultipleCSV(csvPaths []string, batchSize int) error
+	LoadFromMultipleCSV(csvPaths []string, mapping ColumnMapping, batchSize int) error
 }
 
 type aircraftRepository struct {
@@ -25,8 +33,76 @@ func NewAircraftRepository(db *sql.DB) AircraftRepository {
 	return &aircraftRepository{db: db}
 }
 
-// InsertBatch inserts one or more aircraft records in a single transaction
+// aircraftColumns lists the aircraft table columns in the order aircraftValues binds them
+var aircraftColumns = []string{
+	"icao24", "timestamp", "acars", "adsb", "built", "categoryDescription", "country",
+	"engines", "firstFlightDate", "firstSeen", "icaoAircraftClass", "lineNumber",
+	"manufacturerIcao", "manufacturerName", "model", "modes", "nextReg", "notes",
+	"operator", "operatorCallsign", "operatorIata", "operatorIcao", "owner",
+	"prevReg", "regUntil", "registered", "registration", "selCal", "serialNumber",
+	"status", "typecode", "vdl",
+}
+
+// aircraftValues returns the aircraft fields in aircraftColumns order
+func aircraftValues(ac *models.Aircraft) []any {
+	return []any{
+		ac.ICAO24, ac.Timestamp, ac.ACARS, ac.ADSB, ac.Built,
+		ac.CategoryDescription, ac.Country, ac.Engines,
+		ac.FirstFlightDate, ac.FirstSeen, ac.ICAOAircraftClass,
+		ac.LineNumber, ac.ManufacturerICAO, ac.ManufacturerName,
+		ac.Model, ac.Modes, ac.NextReg, ac.Notes, ac.Operator,
+		ac.OperatorCallsign, ac.OperatorIATA, ac.OperatorICAO,
+		ac.Owner, ac.PrevReg, ac.RegUntil, ac.Registered,
+		ac.Registration, ac.SelCal, ac.SerialNumber, ac.Status,
+		ac.TypeCode, ac.VDL,
+	}
+}
+
+// aircraftUpsertSQL builds an upsert over aircraftColumns, using assign to produce the SET expression for each column
+func aircraftUpsertSQL(curated bool, assign func(column string) string) string {
+	columns := strings.Join(aircraftColumns, ", ")
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(aircraftColumns)), ", ")
+
+	sets := make([]string, 0, len(aircraftColumns))
+	for _, col := range aircraftColumns[1:] { // icao24 is the conflict key
+		sets = append(sets, fmt.Sprintf("%s = %s", col, assign(col)))
+	}
+	if curated {
+		sets = append(sets, "curated = 1")
+	}
+
+	curatedValue := 0
+	if curated {
+		curatedValue = 1
+	}
+
+	return fmt.Sprintf("INSERT INTO aircraft (%s, curated) VALUES (%s, %d) ON CONFLICT(icao24) DO UPDATE SET %s",
+		columns, placeholders, curatedValue, strings.Join(sets, ", "))
+}
+
+// Bulk dataset rows replace existing data, except fields a user has curated (e.g. from BaseStation.sqb)
+var bulkUpsertSQL = aircraftUpsertSQL(false, func(col string) string {
+	return fmt.Sprintf("CASE WHEN aircraft.curated = 1 AND COALESCE(aircraft.%[1]s, '') != '' THEN aircraft.%[1]s ELSE excluded.%[1]s END", col)
+})
+
+// Curated rows override existing data only where they actually carry a value
+var curatedUpsertSQL = aircraftUpsertSQL(true, func(col string) string {
+	return fmt.Sprintf("COALESCE(NULLIF(excluded.%[1]s, ''), aircraft.%[1]s)", col)
+})
+
+// InsertBatch inserts one or more aircraft records from a bulk dataset in a single transaction
+// Fields previously set by MergeCurated are preserved.
 func (r *aircraftRepository) InsertBatch(aircraft []*models.Aircraft) error {
+	return r.upsertBatch(bulkUpsertSQL, aircraft)
+}
+
+// MergeCurated merges user-curated aircraft records (e.g. from BaseStation.sqb) in a single transaction
+// Non-empty curated fields win over bulk dataset values, both now and on later dataset reloads.
+func (r *aircraftRepository) MergeCurated(aircraft []*models.Aircraft) error {
+	return r.upsertBatch(curatedUpsertSQL, aircraft)
+}
+
+func (r *aircraftRepository) upsertBatch(query string, aircraft []*models.Aircraft) error {
 	if len(aircraft) == 0 {
 		return nil
 	}
@@ -37,31 +113,14 @@ func (r *aircraftRepository) InsertBatch(aircraft []*models.Aircraft) error {
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO aircraft (
-		icao24, timestamp, acars, adsb, built, categoryDescription, country,
-		engines, firstFlightDate, firstSeen, icaoAircraftClass, lineNumber,
-		manufacturerIcao, manufacturerName, model, modes, nextReg, notes,
-		operator, operatorCallsign, operatorIata, operatorIcao, owner,
-		prevReg, regUntil, registered, registration, selCal, serialNumber,
-		status, typecode, vdl
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	stmt, err := tx.Prepare(query)
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
 	for _, ac := range aircraft {
-		if _, err := stmt.Exec(
-			ac.ICAO24, ac.Timestamp, ac.ACARS, ac.ADSB, ac.Built,
-			ac.CategoryDescription, ac.Country, ac.Engines,
-			ac.FirstFlightDate, ac.FirstSeen, ac.ICAOAircraftClass,
-			ac.LineNumber, ac.ManufacturerICAO, ac.ManufacturerName,
-			ac.Model, ac.Modes, ac.NextReg, ac.Notes, ac.Operator,
-			ac.OperatorCallsign, ac.OperatorIATA, ac.OperatorICAO,
-			ac.Owner, ac.PrevReg, ac.RegUntil, ac.Registered,
-			ac.Registration, ac.SelCal, ac.SerialNumber, ac.Status,
-			ac.TypeCode, ac.VDL,
-		); err != nil {
+		if _, err := stmt.Exec(aircraftValues(ac)...); err != nil {
 			return fmt.Errorf("failed to insert aircraft: %w", err)
 		}
 	}
@@ -73,9 +132,44 @@ func (r *aircraftRepository) InsertBatch(aircraft []*models.Aircraft) error {
 	return nil
 }
 
+// Get returns the aircraft record for an ICAO address, or nil if it is not in the database
+func (r *aircraftRepository) Get(icao24 string) (*models.Aircraft, error) {
+	ac := &models.Aircraft{}
+	dest := []any{
+		&ac.ICAO24, &ac.Timestamp, &ac.ACARS, &ac.ADSB, &ac.Built,
+		&ac.CategoryDescription, &ac.Country, &ac.Engines,
+		&ac.FirstFlightDate, &ac.FirstSeen, &ac.ICAOAircraftClass,
+		&ac.LineNumber, &ac.ManufacturerICAO, &ac.ManufacturerName,
+		&ac.Model, &ac.Modes, &ac.NextReg, &ac.Notes, &ac.Operator,
+		&ac.OperatorCallsign, &ac.OperatorIATA, &ac.OperatorICAO,
+		&ac.Owner, &ac.PrevReg, &ac.RegUntil, &ac.Registered,
+		&ac.Registration, &ac.SelCal, &ac.SerialNumber, &ac.Status,
+		&ac.TypeCode, &ac.VDL,
+	}
+
+	// COALESCE each column so rows from older imports with NULLs still scan into strings
+	selects := make([]string, len(aircraftColumns))
+	for i, col := range aircraftColumns {
+		selects[i] = fmt.Sprintf("COALESCE(%s, '')", col)
+	}
+
+	// The aircraft dataset keys rows by lowercase hex, while live messages use uppercase
+	query := fmt.Sprintf("SELECT %s FROM aircraft WHERE icao24 = ?", strings.Join(selects, ", "))
+	err := r.db.QueryRow(query, strings.ToLower(icao24)).Scan(dest...)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get aircraft %s: %w", icao24, err)
+	}
+	return ac, nil
+}
+
+// IsTablePopulated reports whether the bulk aircraft dataset has been loaded
+// Curated rows alone don't count, otherwise importing a BaseStation.sqb first would skip the CSV load.
 func (r *aircraftRepository) IsTablePopulated() (bool, error) {
 	var ignored int
-	err := r.db.QueryRow("SELECT 1 FROM aircraft LIMIT 1").Scan(&ignored)
+	err := r.db.QueryRow("SELECT 1 FROM aircraft WHERE curated = 0 LIMIT 1").Scan(&ignored)
 	if err == sql.ErrNoRows {
 		return false, nil
 	}
@@ -85,119 +179,261 @@ func (r *aircraftRepository) IsTablePopulated() (bool, error) {
 	return true, nil
 }
 
+// csvChunkSize is the number of CSV records handed to a parse worker at once
+const csvChunkSize = 1000
+
+// csvChunk is a run of raw records from one file, with the position of each aircraft column in them
+type csvChunk struct {
+	columns map[string]int
+	records [][]string
+}
+
 // LoadFromMultipleCSV loads aircraft data from multiple CSV files into the database.
 // File was split so that it could be uploaded to GitHub without hitting the 100MB size limit.
-func (r *aircraftRepository) LoadFromMultipleCSV(csvPaths []string, batchSize int) error {
-	var headerMap map[string]int
-	var expectedFields int
-	batch := make([]*models.Aircraft, 0, batchSize)
+// Files ending in .gz or .zip are decompressed while they are read. mapping names the dataset's headers
+// for aircraft columns; nil expects the OpenSky header names.
+// Records are read by one goroutine, converted by a worker per CPU, and written by the caller's goroutine
+// in transactions of batchSize rows, since SQLite only has one writer anyway.
+func (r *aircraftRepository) LoadFromMultipleCSV(csvPaths []string, mapping ColumnMapping, batchSize int) error {
+	done := make(chan struct{}) // Closed on return so the reader and workers stop early on insert errors
+	defer close(done)
 
-	for fileIdx, csvPath := range csvPaths {
-		file, err := os.Open(csvPath)
-		if err != nil {
-			return fmt.Errorf("failed to open CSV file %s: %w", csvPath, err)
-		}
-		defer file.Close()
+	workers := runtime.NumCPU()
+	chunks := make(chan csvChunk, workers*2)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(chunks)
+		readErr <- readCSVChunks(csvPaths, mapping, chunks, done)
+	}()
 
-		reader := csv.NewReader(file)
-		reader.LazyQuotes = true    // Handle malformed quotes in CSV
-		reader.FieldsPerRecord = -1 // Allow variable number of fields per record
+	parsed := make(chan []*models.Aircraft, workers*2)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range chunks {
+				select {
+				case parsed <- parseAircraftRecords(chunk):
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(parsed)
+	}()
 
-		// Read header row (process and validate for every file, but only build headerMap from first)
-		header, err := reader.Read()
-		if err != nil {
-			return fmt.Errorf("failed to read CSV header from %s: %w", csvPath, err)
+	batch := make([]*models.Aircraft, 0, batchSize)
+	for aircraft := range parsed {
+		batch = append(batch, aircraft...)
+		if len(batch) >= batchSize {
+			if err := r.InsertBatch(batch); err != nil {
+				return fmt.Errorf("failed to insert batch: %w", err)
+			}
+			batch = batch[:0] // Reset slice but keep capacity
 		}
+	}
 
-		// Initialize header map on first file
-		if fileIdx == 0 {
-			expectedFields = len(header)
-			headerMap = make(map[string]int)
-			for i, h := range header {
-				// Remove quotes and trim whitespace
-				headerMap[strings.Trim(strings.TrimSpace(h), "'\"")] = i
-			}
+	if err := <-readErr; err != nil {
+		return err
+	}
+
+	// Insert remaining records
+	if len(batch) > 0 {
+		if err := r.InsertBatch(batch); err != nil {
+			return fmt.Errorf("failed to insert final batch: %w", err)
 		}
+	}
 
-		for {
-			record, err := reader.Read()
-			if err == io.EOF {
-				break
-			}
+	return nil
+}
+
+// readCSVChunks reads every file in order and sends their records in chunks until done is closed
+// The column positions and field count come from the first file; the split dataset files share one header.
+func readCSVChunks(csvPaths []string, mapping ColumnMapping, out chan<- csvChunk, done <-chan struct{}) error {
+	var columns map[string]int
+	var expectedFields int
+
+	for _, csvPath := range csvPaths {
+		err := eachCSV(csvPath, func(name string, r io.Reader) error {
+			reader := csv.NewReader(r)
+			reader.LazyQuotes = true    // Handle malformed quotes in CSV
+			reader.FieldsPerRecord = -1 // Allow variable number of fields per record
+
+			// Read header row (process and validate for every file, but only map columns from the first)
+			header, err := reader.Read()
 			if err != nil {
-				return fmt.Errorf("failed to read CSV record from %s: %w", csvPath, err)
+				return fmt.Errorf("failed to read CSV header from %s: %w", name, err)
 			}
 
-			if len(record) != expectedFields {
-				continue
+			// Initialize column positions on first file
+			if columns == nil {
+				expectedFields = len(header)
+				if columns, err = mapping.columnIndexes(header); err != nil {
+					return fmt.Errorf("failed to map CSV columns of %s: %w", name, err)
+				}
 			}
 
-			// Create Aircraft struct from CSV record
-			ac := &models.Aircraft{
-				ICAO24:              getField(record, headerMap, "icao24"),
-				Timestamp:           getField(record, headerMap, "timestamp"),
-				ACARS:               getField(record, headerMap, "acars"),
-				ADSB:                getField(record, headerMap, "adsb"),
-				Built:               getField(record, headerMap, "built"),
-				CategoryDescription: getField(record, headerMap, "categoryDescription"),
-				Country:             getField(record, headerMap, "country"),
-				Engines:             getField(record, headerMap, "engines"),
-				FirstFlightDate:     getField(record, headerMap, "firstFlightDate"),
-				FirstSeen:           getField(record, headerMap, "firstSeen"),
-				ICAOAircraftClass:   getField(record, headerMap, "icaoAircraftClass"),
-				LineNumber:          getField(record, headerMap, "lineNumber"),
-				ManufacturerICAO:    getField(record, headerMap, "manufacturerIcao"),
-				ManufacturerName:    getField(record, headerMap, "manufacturerName"),
-				Model:               getField(record, headerMap, "model"),
-				Modes:               getField(record, headerMap, "modes"),
-				NextReg:             getField(record, headerMap, "nextReg"),
-				Notes:               getField(record, headerMap, "notes"),
-				Operator:            getField(record, headerMap, "operator"),
-				OperatorCallsign:    getField(record, headerMap, "operatorCallsign"),
-				OperatorIATA:        getField(record, headerMap, "operatorIata"),
-				OperatorICAO:        getField(record, headerMap, "operatorIcao"),
-				Owner:               getField(record, headerMap, "owner"),
-				PrevReg:             getField(record, headerMap, "prevReg"),
-				RegUntil:            getField(record, headerMap, "regUntil"),
-				Registered:          getField(record, headerMap, "registered"),
-				Registration:        getField(record, headerMap, "registration"),
-				SelCal:              getField(record, headerMap, "selCal"),
-				SerialNumber:        getField(record, headerMap, "serialNumber"),
-				Status:              getField(record, headerMap, "status"),
-				TypeCode:            getField(record, headerMap, "typecode"),
-				VDL:                 getField(record, headerMap, "vdl"),
+			records := make([][]string, 0, csvChunkSize)
+			send := func() bool {
+				select {
+				case out <- csvChunk{columns: columns, records: records}:
+					records = make([][]string, 0, csvChunkSize)
+					return true
+				case <-done:
+					return false
+				}
 			}
 
-			// Skip records without ICAO24 (invalid data)
-			if ac.ICAO24 == "" {
-				continue
-			}
+			for {
+				record, err := reader.Read()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return fmt.Errorf("failed to read CSV record from %s: %w", name, err)
+				}
 
-			batch = append(batch, ac)
+				if len(record) != expectedFields {
+					continue
+				}
 
-			// Insert batch when it reaches the specified size
-			if len(batch) >= batchSize {
-				if err := r.InsertBatch(batch); err != nil {
-					return fmt.Errorf("failed to insert batch: %w", err)
+				records = append(records, record)
+				if len(records) == csvChunkSize && !send() {
+					return nil
 				}
-				batch = batch[:0] // Reset slice but keep capacity
 			}
+			if len(records) > 0 {
+				send()
+			}
+			return nil
+		})
+		if err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	// Insert remaining records
-	if len(batch) > 0 {
-		if err := r.InsertBatch(batch); err != nil {
-			return fmt.Errorf("failed to insert final batch: %w", err)
+// eachCSV calls fn with the CSV data in a dataset file, decompressing it as it is read
+// .gz files hold one CSV; .zip archives may hold several, which are read in name order. Other files are plain CSV.
+func eachCSV(path string, fn func(name string, r io.Reader) error) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".zip":
+		archive, err := zip.OpenReader(path)
+		if err != nil {
+			return fmt.Errorf("failed to open zip file %s: %w", path, err)
+		}
+		defer archive.Close()
+
+		var entries []*zip.File
+		for _, f := range archive.File {
+			if strings.EqualFold(filepath.Ext(f.Name), ".csv") {
+				entries = append(entries, f)
+			}
 		}
+		if len(entries) == 0 {
+			return fmt.Errorf("zip file %s contains no CSV files", path)
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+		for _, entry := range entries {
+			err := func() error {
+				r, err := entry.Open()
+				if err != nil {
+					return fmt.Errorf("failed to open %s in %s: %w", entry.Name, path, err)
+				}
+				defer r.Close()
+				return fn(path+":"+entry.Name, r)
+			}()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case ".gz":
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open CSV file %s: %w", path, err)
+		}
+		defer file.Close()
+
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to read gzip file %s: %w", path, err)
+		}
+		defer gz.Close()
+		return fn(path, gz)
+
+	default:
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open CSV file %s: %w", path, err)
+		}
+		defer file.Close()
+		return fn(path, file)
 	}
+}
 
-	return nil
+// parseAircraftRecords converts raw CSV records into aircraft, skipping records without an ICAO address
+func parseAircraftRecords(chunk csvChunk) []*models.Aircraft {
+	columns := chunk.columns
+	aircraft := make([]*models.Aircraft, 0, len(chunk.records))
+	for _, record := range chunk.records {
+		// Create Aircraft struct from CSV record
+		ac := &models.Aircraft{
+			ICAO24:              getField(record, columns, "icao24"),
+			Timestamp:           getField(record, columns, "timestamp"),
+			ACARS:               getField(record, columns, "acars"),
+			ADSB:                getField(record, columns, "adsb"),
+			Built:               getField(record, columns, "built"),
+			CategoryDescription: getField(record, columns, "categoryDescription"),
+			Country:             getField(record, columns, "country"),
+			Engines:             getField(record, columns, "engines"),
+			FirstFlightDate:     getField(record, columns, "firstFlightDate"),
+			FirstSeen:           getField(record, columns, "firstSeen"),
+			ICAOAircraftClass:   getField(record, columns, "icaoAircraftClass"),
+			LineNumber:          getField(record, columns, "lineNumber"),
+			ManufacturerICAO:    getField(record, columns, "manufacturerIcao"),
+			ManufacturerName:    getField(record, columns, "manufacturerName"),
+			Model:               getField(record, columns, "model"),
+			Modes:               getField(record, columns, "modes"),
+			NextReg:             getField(record, columns, "nextReg"),
+			Notes:               getField(record, columns, "notes"),
+			Operator:            getField(record, columns, "operator"),
+			OperatorCallsign:    getField(record, columns, "operatorCallsign"),
+			OperatorIATA:        getField(record, columns, "operatorIata"),
+			OperatorICAO:        getField(record, columns, "operatorIcao"),
+			Owner:               getField(record, columns, "owner"),
+			PrevReg:             getField(record, columns, "prevReg"),
+			RegUntil:            getField(record, columns, "regUntil"),
+			Registered:          getField(record, columns, "registered"),
+			Registration:        getField(record, columns, "registration"),
+			SelCal:              getField(record, columns, "selCal"),
+			SerialNumber:        getField(record, columns, "serialNumber"),
+			Status:              getField(record, columns, "status"),
+			TypeCode:            getField(record, columns, "typecode"),
+			VDL:                 getField(record, columns, "vdl"),
+		}
+
+		// Skip records without ICAO24 (invalid data)
+		if ac.ICAO24 == "" {
+			continue
+		}
+		ac.ICAO24 = strings.ToLower(ac.ICAO24) // Get looks addresses up in lowercase, as OpenSky stores them
+
+		aircraft = append(aircraft, ac)
+	}
+	return aircraft
 }
 
-// getField safely retrieves a field from a CSV record by header name
-func getField(record []string, headerMap map[string]int, fieldName string) string {
-	if idx, ok := headerMap[fieldName]; ok && idx < len(record) {
+// getField safely retrieves a field from a CSV record by aircraft column name
+func getField(record []string, columns map[string]int, fieldName string) string {
+	if idx, ok := columns[fieldName]; ok && idx < len(record) {
 		return strings.Trim(strings.TrimSpace(record[idx]), "'\"")
 	}
 	return ""
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/models"
+)
+
+// baseStationTimeLayout is the strftime output used when reading BaseStation datetimes
+const baseStationTimeLayout = "2006-01-02 15:04:05"
+
+// BaseStation provides read-only access to a Virtual Radar Server / Kinetic BaseStation.sqb database
+type BaseStation struct {
+	db *sql.DB
+}
+
+// BaseStationFlight is one row of the BaseStation Flights table joined with its aircraft
+// BaseStation stores times in the local time zone of the machine that recorded them.
+type BaseStationFlight struct {
+	ICAO     string
+	Callsign string
+	Start    time.Time
+	End      time.Time
+	Messages int64
+}
+
+// OpenBaseStation opens a BaseStation.sqb database read-only
+func OpenBaseStation(path string) (*BaseStation, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open BaseStation database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open BaseStation database %s: %w", path, err)
+	}
+	return &BaseStation{db: db}, nil
+}
+
+// Close closes the BaseStation database
+func (b *BaseStation) Close() error {
+	return b.db.Close()
+}
+
+const baseStationAircraftSQL = `SELECT
+		ModeS,
+		COALESCE(Registration, ''),
+		COALESCE(ICAOTypeCode, ''),
+		COALESCE(Manufacturer, ''),
+		COALESCE(Type, ''),
+		COALESCE(SerialNo, ''),
+		COALESCE(RegisteredOwners, ''),
+		COALESCE(OperatorFlagCode, ''),
+		COALESCE(YearBuilt, ''),
+		COALESCE(ModeSCountry, '')
+	FROM Aircraft`
+
+// Aircraft returns every aircraft record in the BaseStation database
+func (b *BaseStation) Aircraft() ([]*models.Aircraft, error) {
+	rows, err := b.db.Query(baseStationAircraftSQL + ` WHERE ModeS IS NOT NULL AND ModeS != ''`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query BaseStation aircraft: %w", err)
+	}
+	defer rows.Close()
+
+	var aircraft []*models.Aircraft
+	for rows.Next() {
+		ac, err := scanBaseStationAircraft(rows)
+		if err != nil {
+			return nil, err
+		}
+		aircraft = append(aircraft, ac)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read BaseStation aircraft: %w", err)
+	}
+	return aircraft, nil
+}
+
+// LookupAircraft returns the BaseStation record for an ICAO address, or nil if there is none
+func (b *BaseStation) LookupAircraft(icao string) (*models.Aircraft, error) {
+	row := b.db.QueryRow(baseStationAircraftSQL+` WHERE ModeS = ? COLLATE NOCASE`, icao)
+	ac, err := scanBaseStationAircraft(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ac, nil
+}
+
+// scanBaseStationAircraft maps a baseStationAircraftSQL row onto the aircraft model
+// ICAO addresses are lowercased to match the bulk dataset, and fixed-width padding is trimmed.
+func scanBaseStationAircraft(row interface{ Scan(...any) error }) (*models.Aircraft, error) {
+	ac := &models.Aircraft{}
+	if err := row.Scan(
+		&ac.ICAO24, &ac.Registration, &ac.TypeCode, &ac.ManufacturerName, &ac.Model,
+		&ac.SerialNumber, &ac.Owner, &ac.OperatorICAO, &ac.Built, &ac.Country,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan BaseStation aircraft: %w", err)
+	}
+
+	for _, field := range []*string{
+		&ac.Registration, &ac.TypeCode, &ac.ManufacturerName, &ac.Model,
+		&ac.SerialNumber, &ac.Owner, &ac.OperatorICAO, &ac.Built, &ac.Country,
+	} {
+		*field = strings.TrimSpace(*field)
+	}
+	ac.ICAO24 = strings.ToLower(strings.TrimSpace(ac.ICAO24))
+	return ac, nil
+}
+
+// Flights returns every flight with a usable start time, oldest first
+func (b *BaseStation) Flights() ([]*BaseStationFlight, error) {
+	// strftime normalizes the assorted datetime encodings VRS and BaseStation have used over the years
+	rows, err := b.db.Query(`SELECT
+			a.ModeS,
+			COALESCE(strftime('%Y-%m-%d %H:%M:%S', f.StartTime), ''),
+			COALESCE(strftime('%Y-%m-%d %H:%M:%S', f.EndTime), ''),
+			COALESCE(f.NumModeSMsgRec, 0),
+			COALESCE(f.Callsign, '')
+		FROM Flights f
+		JOIN Aircraft a ON a.AircraftID = f.AircraftID
+		WHERE a.ModeS IS NOT NULL AND a.ModeS != ''
+		ORDER BY f.StartTime`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query BaseStation flights: %w", err)
+	}
+	defer rows.Close()
+
+	var flights []*BaseStationFlight
+	for rows.Next() {
+		var modeS, start, end, callsign string
+		var messages int64
+		if err := rows.Scan(&modeS, &start, &end, &messages, &callsign); err != nil {
+			return nil, fmt.Errorf("failed to scan BaseStation flight: %w", err)
+		}
+
+		startTime, err := time.ParseInLocation(baseStationTimeLayout, start, time.Local)
+		if err != nil {
+			continue // Flight without a usable start time carries no sighting information
+		}
+		endTime, err := time.ParseInLocation(baseStationTimeLayout, end, time.Local)
+		if err != nil || endTime.Before(startTime) {
+			endTime = startTime
+		}
+
+		flights = append(flights, &BaseStationFlight{
+			ICAO:     strings.ToUpper(strings.TrimSpace(modeS)),
+			Callsign: strings.TrimSpace(callsign),
+			Start:    startTime,
+			End:      endTime,
+			Messages: messages,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read BaseStation flights: %w", err)
+	}
+	return flights, nil
+}
@@ -2,25 +2,218 @@ package database
 
 import (
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"flight_trmnl/internal/crypt"
+	"flight_trmnl/internal/decoder"
 	"flight_trmnl/internal/models"
 )
 
 type BeastMessageRepository interface {
 	InsertBatch(msgs []*models.BeastMessage) error
+	QueryHistory(filter MessageFilter, page PageRequest) ([]*MessageRecord, string, error)
+	MessageTypes(filter MessageFilter) (models.MessageTypeBreakdown, error)
+	Tracks(icao string, gap time.Duration, limit int) (*TrackHistory, error)
+	Receivers(filter MessageFilter) ([]*ReceiverCoverage, error)
+}
+
+// MessageRecord is a stored Beast message row as returned by history queries
+type MessageRecord struct {
+	ID          int64     `json:"id"`
+	Timestamp   time.Time `json:"timestamp"`
+	Ticks       *int64    `json:"ticks,omitempty"` // The raw 48-bit Beast timestamp, missing when the receiver sent none
+	ICAO        string    `json:"icao"`
+	MessageType string    `json:"message_type"`
+	SignalLevel int       `json:"signal_level"`
+	SignalDBFS  float64   `json:"signal_dbfs"`
+	MessageHex  string    `json:"message_hex"`
+	CRCError    bool      `json:"crc_error"`          // The parity check failed, so the message was corrupted in reception
+	Receiver    string    `json:"receiver,omitempty"` // The configured receiver that heard it, see models.BeastMessage
+	CreatedAt   time.Time `json:"created_at"`
+
+	// Decoded from ADS-B extended squitters and Mode A/C replies, each set only by the message types that carry it
+	Callsign     string   `json:"callsign,omitempty"`
+	Category     string   `json:"category,omitempty"` // ADS-B emitter category of an identification message, e.g. A3
+	Squawk       string   `json:"squawk,omitempty"`   // Mode A code of a Mode A/C reply
+	Altitude     *int     `json:"altitude,omitempty"`
+	Latitude     *float64 `json:"lat,omitempty"`
+	Longitude    *float64 `json:"lon,omitempty"`
+	Speed        *int     `json:"speed,omitempty"`
+	Track        *float64 `json:"track,omitempty"`
+	Heading      *float64 `json:"heading,omitempty"` // Magnetic, from airspeed velocity messages
+	VerticalRate *int     `json:"vertical_rate,omitempty"`
+
+	// Decoded from DF20/DF21 Comm-B replies whose register could be inferred
+	BDS              string   `json:"bds,omitempty"`               // Comm-B register, e.g. 4,0
+	SelectedAltitude *int     `json:"selected_altitude,omitempty"` // Set on the MCP/FCU, or else in the FMS; also from TC 29
+	Roll             *float64 `json:"roll,omitempty"`              // Degrees, negative when left wing down
+
+	// Integrity of the position, see decoder.Integrity; NACp and SIL are missing until an operational status was heard
+	NIC  *int `json:"nic,omitempty"`
+	NACp *int `json:"nacp,omitempty"`
+	SIL  *int `json:"sil,omitempty"`
+}
+
+// MessageFilter narrows a message history query; zero values don't filter
+type MessageFilter struct {
+	ICAO        string
+	MessageType string
+	CRCError    *bool     // Only messages whose parity check failed, or only those that didn't
+	MinNIC      int       // Only positions with at least this navigation integrity category
+	MinNACp     int       // Only positions with at least this navigation accuracy category
+	MinSIL      int       // Only positions with at least this source integrity level
+	Receiver    string    // Only messages heard by this configured receiver
+	From        time.Time // Inclusive
+	To          time.Time // Exclusive
+}
+
+// conditions returns the WHERE conditions and arguments selecting the filtered messages
+func (f MessageFilter) conditions() ([]string, []any) {
+	var conditions []string
+	var args []any
+	if f.ICAO != "" {
+		conditions = append(conditions, "icao = ?")
+		args = append(args, f.ICAO)
+	}
+	if f.MessageType != "" {
+		conditions = append(conditions, "message_type = ?")
+		args = append(args, f.MessageType)
+	}
+	if f.Receiver != "" {
+		conditions = append(conditions, "receiver = ?")
+		args = append(args, f.Receiver)
+	}
+	if f.CRCError != nil {
+		conditions = append(conditions, "crc_error = ?")
+		args = append(args, *f.CRCError)
+	}
+	for _, least := range []struct {
+		column string
+		value  int
+	}{{"nic", f.MinNIC}, {"nacp", f.MinNACp}, {"sil", f.MinSIL}} {
+		if least.value > 0 {
+			conditions = append(conditions, least.column+" >= ?")
+			args = append(args, least.value)
+		}
+	}
+	if !f.From.IsZero() {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, f.From)
+	}
+	if !f.To.IsZero() {
+		conditions = append(conditions, "timestamp < ?")
+		args = append(args, f.To)
+	}
+	return conditions, args
+}
+
+var messageSortable = sortableTable{
+	columns: map[string]sortKind{
+		"id":           sortInt,
+		"timestamp":    sortTime,
+		"signal_level": sortInt,
+	},
+	key:         "id",
+	keyKind:     sortInt,
+	defaultSort: "id",
+}
+
+// Track is one pass of an aircraft through the receiver's range, its messages without a gap longer than the split
+type Track struct {
+	Start     time.Time     `json:"start"`
+	End       time.Time     `json:"end"`
+	Messages  int64         `json:"messages"`
+	MaxSignal int           `json:"max_signal"`
+	Positions []*TrackPoint `json:"positions"` // Where it flew, oldest first
+}
+
+// TrackPoint is a position decoded on a track
+type TrackPoint struct {
+	Time      time.Time `json:"time"`
+	Latitude  float64   `json:"lat"`
+	Longitude float64   `json:"lon"`
+	Altitude  *int      `json:"altitude,omitempty"` // Feet, from the same message
+}
+
+// trackPointSpacing thins a track's positions, which come up to twice a second, to what draws its path
+const trackPointSpacing = 10 * time.Second
+
+// TrackHistory summarizes every track of one aircraft and lists the most recent
+type TrackHistory struct {
+	Count   int      `json:"count"`
+	Seconds float64  `json:"seconds"` // Time in range over all tracks
+	Recent  []*Track `json:"recent"`  // Newest first
+}
+
+// Storage modes, from most to least data kept per received message
+const (
+	StorageRaw     = "raw"     // Every message, raw bytes and decoded fields
+	StorageDecoded = "decoded" // Decoded fields of messages from identified aircraft, no raw bytes
+	StorageState   = "state"   // No messages, only the seen aircraft summary (and state snapshots)
+)
+
+// OmittableColumns are the beast_messages columns storage can leave empty to shrink rows. The time, address,
+// message type, parity flag, downlink format and type code, and receiver are always stored: the indexes, filters,
+// the message type breakdown, and the receiver comparison rely on them, and they take a few bytes.
+var OmittableColumns = []string{
+	"ticks", "signal_level", "signal_dbfs", "message_hex", "callsign", "category", "squawk", "altitude", "latitude",
+	"longitude", "speed", "track", "heading", "vertical_rate", "bds", "selected_altitude", "roll", "nic", "nacp", "sil",
+}
+
+// CheckOmittedColumn rejects a beast_messages column that can't be omitted
+func CheckOmittedColumn(column string) error {
+	if !slices.Contains(OmittableColumns, column) {
+		return fmt.Errorf("column %s can't be omitted (must be one of %s)", column, strings.Join(OmittableColumns, ", "))
+	}
+	return nil
+}
+
+// messageColumns are the beast_messages columns insertMessages writes, in the order of its values
+var messageColumns = []string{
+	"timestamp", "ticks", "icao", "message_type", "signal_level", "signal_dbfs", "message_hex", "crc_error",
+	"downlink_format", "type_code", "callsign", "category", "squawk", "altitude", "latitude", "longitude", "speed",
+	"track", "heading", "vertical_rate", "bds", "selected_altitude", "roll", "nic", "nacp", "sil", "receiver",
 }
 
 type beastMessageRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	mode   string
+	omit   map[string]bool // Columns left out of inserts, so they stay NULL; message_hex is stored empty instead
+	cipher crypt.Cipher    // Encrypts message_hex and the decoded position, nil stores them in the clear
 }
 
+// NewBeastMessageRepository creates a repository that stores every message in raw mode
 func NewBeastMessageRepository(db *sql.DB) BeastMessageRepository {
-	return &beastMessageRepository{db: db}
+	return NewBeastMessageRepositoryWithMode(db, StorageRaw)
+}
+
+// NewBeastMessageRepositoryWithMode creates a repository whose InsertBatch keeps only what the storage mode keeps,
+// and leaves the omitted columns, see OmittableColumns, empty. The seen aircraft summary is updated in every mode.
+func NewBeastMessageRepositoryWithMode(db *sql.DB, mode string, omit ...string) BeastMessageRepository {
+	return &beastMessageRepository{db: db, mode: mode, omit: omittedColumns(omit)}
+}
+
+// omittedColumns returns the set of columns to omit, nil for none
+func omittedColumns(columns []string) map[string]bool {
+	if len(columns) == 0 {
+		return nil
+	}
+	omit := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		omit[column] = true
+	}
+	return omit
 }
 
 // InsertBatch inserts one or more Beast messages in a single transaction
 // Batching is preferred over individual inserts, especially on Raspberry Pi with SD card storage.
+// The seen_aircraft summary is updated in the same transaction so it never drifts from the stored messages.
 func (r *beastMessageRepository) InsertBatch(msgs []*models.BeastMessage) error {
 	if len(msgs) == 0 {
 		return nil
@@ -32,29 +225,616 @@ func (r *beastMessageRepository) InsertBatch(msgs []*models.BeastMessage) error
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.Prepare(`INSERT INTO beast_messages (
-		timestamp, icao, message_type, signal_level, message_hex
-	) VALUES (?, ?, ?, ?, ?)`)
+	if r.mode != StorageState {
+		if err := r.insertMessages(tx, msgs); err != nil {
+			return err
+		}
+	}
+
+	if err := upsertSightings(tx, sightingsFromMessages(msgs)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// insertMessages stores the messages the storage mode keeps
+func (r *beastMessageRepository) insertMessages(tx *sql.Tx, msgs []*models.BeastMessage) error {
+	// A NULL takes no room in a row, so omitted columns are left out of the insert; message_hex can't be NULL
+	var columns []string
+	var keep []int
+	for i, column := range messageColumns {
+		if !r.omit[column] || column == "message_hex" {
+			columns = append(columns, column)
+			keep = append(keep, i)
+		}
+	}
+	stmt, err := tx.Prepare(fmt.Sprintf(`INSERT INTO beast_messages (%s) VALUES (%s)`, strings.Join(columns, ", "),
+		strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")))
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
 	for _, msg := range msgs {
-		if _, err := stmt.Exec(
+		raw := msg.Hex()
+		if r.mode == StorageDecoded {
+			if !hasClearAddress(msg) {
+				continue
+			}
+			raw = ""
+		}
+		if r.omit["message_hex"] {
+			raw = ""
+		}
+		d := decodedFields(msg)
+		if raw, err = seal(r.cipher, raw); err != nil {
+			return err
+		}
+		latitude, err := sealFloat(r.cipher, d.Latitude)
+		if err != nil {
+			return err
+		}
+		longitude, err := sealFloat(r.cipher, d.Longitude)
+		if err != nil {
+			return err
+		}
+		values := []any{
 			msg.Timestamp,
+			storedTicks(msg.Ticks),
 			msg.ICAO,
 			msg.MessageType,
 			msg.SignalLevel,
-			msg.Hex(),
-		); err != nil {
+			msg.SignalDBFS(),
+			raw,
+			msg.CRCError(),
+			msg.DownlinkFormat(),
+			msg.TypeCode(),
+			d.Callsign,
+			d.Category,
+			d.Squawk,
+			d.Altitude,
+			latitude,
+			longitude,
+			d.Speed,
+			d.Track,
+			d.Heading,
+			d.VerticalRate,
+			d.BDS,
+			d.SelectedAltitude,
+			d.Roll,
+			d.NIC,
+			d.NACp,
+			d.SIL,
+			storedReceiver(msg.Receiver),
+		}
+		args := make([]any, len(keep))
+		for i, v := range keep {
+			args[i] = values[v]
+		}
+		if _, err := stmt.Exec(args...); err != nil {
 			return fmt.Errorf("failed to insert message: %w", err)
 		}
 	}
+	return nil
+}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+// decodedFields returns the fields decoded from an extended squitter whose parity checks, as a record; the position
+// is the one the tracker decoded. A Mode A/C reply has its squawk, and the altitude its code would be if it
+// answered a Mode C interrogation. A Comm-B reply has its altitude and the fields of the register it was inferred to
+// carry; its parity is overlaid with the address, so it can't be checked.
+func decodedFields(msg *models.BeastMessage) MessageRecord {
+	var d MessageRecord
+	if msg.MessageTypeCode == models.BeastTypeModeAC {
+		if m, err := decoder.DecodeModeAC(msg.Message); err == nil {
+			d.Squawk, d.Altitude = fmt.Sprintf("%04o", m.Squawk), m.Altitude
+		}
+		return d
+	}
+	if df := msg.DownlinkFormat(); df == 20 || df == 21 {
+		return commBFields(msg)
+	}
+	if models.ModeSResidual(msg.Message) != 0 {
+		return d
+	}
+	m, err := decoder.Decode(msg.Message)
+	if err != nil {
+		return d
+	}
+	if msg.Position != nil {
+		d.Latitude, d.Longitude = &msg.Position.Latitude, &msg.Position.Longitude
+	}
+	if in := msg.Integrity; in != nil {
+		d.NIC, d.NACp, d.SIL = &in.NIC, in.NACp, in.SIL
+	}
+	switch {
+	case m.Identification != nil:
+		d.Callsign, d.Category = m.Identification.Callsign, m.Identification.Category
+	case m.AirbornePosition != nil:
+		d.Altitude = m.AirbornePosition.Altitude
+	case m.SurfacePosition != nil:
+		d.Track = m.SurfacePosition.Track
+	case m.Velocity != nil:
+		d.Speed, d.Track, d.VerticalRate = m.Velocity.Speed, m.Velocity.Track, m.Velocity.VerticalRate
+		d.Heading = m.Velocity.Heading
+	case m.TargetState != nil:
+		d.SelectedAltitude = m.TargetState.SelectedAltitude
+	}
+	return d
+}
+
+// commBFields returns the fields decoded from a DF20/DF21 Comm-B reply
+func commBFields(msg *models.BeastMessage) MessageRecord {
+	var d MessageRecord
+	if feet, ok := msg.Altitude(); ok {
+		d.Altitude = &feet
+	}
+	c, err := decoder.DecodeCommB(msg.Message)
+	if err != nil {
+		return d
+	}
+	d.BDS = c.BDS
+	switch {
+	case c.SelectedVertical != nil:
+		d.SelectedAltitude = c.SelectedVertical.MCPAltitude
+		if d.SelectedAltitude == nil {
+			d.SelectedAltitude = c.SelectedVertical.FMSAltitude
+		}
+	case c.TrackAndTurn != nil:
+		d.Roll, d.Track, d.Speed = c.TrackAndTurn.Roll, c.TrackAndTurn.Track, c.TrackAndTurn.GroundSpeed
+		if d.Speed == nil {
+			d.Speed = c.TrackAndTurn.TrueAirspeed
+		}
+	case c.HeadingAndSpeed != nil:
+		d.Heading, d.Speed = c.HeadingAndSpeed.Heading, c.HeadingAndSpeed.IndicatedAirspeed
+		d.VerticalRate = c.HeadingAndSpeed.BaroVerticalRate
+		if d.VerticalRate == nil {
+			d.VerticalRate = c.HeadingAndSpeed.InertialRate
+		}
+	}
+	return d
+}
+
+// hasClearAddress reports whether the message identifies its aircraft
+// Only DF11 all-call replies and DF17/DF18 extended squitters carry the ICAO address in the clear;
+// other formats overlay the address with parity and would create bogus aircraft. TIS-B and ADS-R targets without
+// an ICAO address are left out too, their addresses aren't an airframe's, and so are reserved and unallocated
+// addresses, which are mostly corrupt frames'.
+func hasClearAddress(msg *models.BeastMessage) bool {
+	return msg.AddressType() == models.AddressICAO
+}
+
+// sightingsFromMessages aggregates a batch of messages from identified aircraft into one sighting per aircraft
+func sightingsFromMessages(msgs []*models.BeastMessage) []*models.Sighting {
+	byICAO := make(map[string]*models.Sighting)
+	var sightings []*models.Sighting
+
+	for _, msg := range msgs {
+		if !hasClearAddress(msg) {
+			continue
+		}
+
+		s, ok := byICAO[msg.ICAO]
+		if !ok {
+			s = &models.Sighting{
+				ICAO:      msg.ICAO,
+				FirstSeen: msg.Timestamp,
+				LastSeen:  msg.Timestamp,
+				Source:    "live",
+			}
+			byICAO[msg.ICAO] = s
+			sightings = append(sightings, s)
+		}
+
+		if msg.Timestamp.Before(s.FirstSeen) {
+			s.FirstSeen = msg.Timestamp
+		}
+		if msg.Timestamp.After(s.LastSeen) {
+			s.LastSeen = msg.Timestamp
+		}
+		s.MessageCount++
+		if category, _, ok := msg.Identification(); ok && category != "" && !msg.Timestamp.Before(s.LastSeen) {
+			s.Category = category
+		}
+	}
+
+	return sightings
+}
+
+// QueryHistory returns one page of stored messages and the cursor for the next page ("" on the last page)
+func (r *beastMessageRepository) QueryHistory(filter MessageFilter, page PageRequest) ([]*MessageRecord, string, error) {
+	column, order, seek, seekArgs, err := messageSortable.orderAndSeek(page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	conditions, args := filter.conditions()
+	if seek != "" {
+		conditions = append(conditions, seek)
+		args = append(args, seekArgs...)
+	}
+
+	limit := page.limit()
+	// Fetch one extra row to learn whether another page exists
+	query := fmt.Sprintf(`SELECT id, timestamp, ticks, icao, COALESCE(message_type, ''), COALESCE(signal_level, 0), signal_dbfs, message_hex, crc_error, COALESCE(receiver, ''), created_at,
+			COALESCE(callsign, ''), COALESCE(category, ''), COALESCE(squawk, ''), altitude, latitude, longitude, speed, track, heading, vertical_rate,
+			COALESCE(bds, ''), selected_altitude, roll, nic, nacp, sil
+		FROM beast_messages %s %s LIMIT %d`, whereClause(conditions), order, limit+1)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*MessageRecord
+	for rows.Next() {
+		rec := &MessageRecord{}
+		var ticks, altitude, speed, verticalRate, selectedAltitude, nic, nacp, sil sql.NullInt64
+		var lat, lon sql.NullString // Numbers, or text when encrypted
+		var track, heading, roll, dbfs sql.NullFloat64
+		if err := rows.Scan(&rec.ID, &rec.Timestamp, &ticks, &rec.ICAO, &rec.MessageType, &rec.SignalLevel, &dbfs, &rec.MessageHex, &rec.CRCError, &rec.Receiver, &rec.CreatedAt,
+			&rec.Callsign, &rec.Category, &rec.Squawk, &altitude, &lat, &lon, &speed, &track, &heading, &verticalRate,
+			&rec.BDS, &selectedAltitude, &roll, &nic, &nacp, &sil); err != nil {
+			return nil, "", fmt.Errorf("failed to scan message: %w", err)
+		}
+		if ticks.Valid {
+			rec.Ticks = &ticks.Int64
+		}
+		rec.Altitude, rec.Speed, rec.VerticalRate = nullInt(altitude), nullInt(speed), nullInt(verticalRate)
+		rec.Track, rec.Heading = nullFloat(track), nullFloat(heading)
+		rec.SelectedAltitude, rec.Roll = nullInt(selectedAltitude), nullFloat(roll)
+		rec.NIC, rec.NACp, rec.SIL = nullInt(nic), nullInt(nacp), nullInt(sil)
+		rec.SignalDBFS = dbfs.Float64
+		if !dbfs.Valid {
+			rec.SignalDBFS = models.SignalDBFS(uint8(rec.SignalLevel)) // Stored before the column existed
+		}
+		if rec.MessageHex, err = unseal(r.cipher, rec.MessageHex); err != nil {
+			return nil, "", err
+		}
+		if rec.Latitude, err = unsealFloat(r.cipher, lat); err != nil {
+			return nil, "", err
+		}
+		if rec.Longitude, err = unsealFloat(r.cipher, lon); err != nil {
+			return nil, "", err
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to read messages: %w", err)
+	}
+
+	if len(records) <= limit {
+		return records, "", nil
 	}
 
+	records = records[:limit]
+	last := records[limit-1]
+	var value any
+	switch column {
+	case "timestamp":
+		value = last.Timestamp
+	case "signal_level":
+		value = last.SignalLevel
+	default:
+		value = last.ID
+	}
+	return records, encodeCursor(page, column, value, last.ID), nil
+}
+
+// MessageTypes counts the filtered messages by downlink format and ADS-B type code
+// Messages stored before the decoded columns existed are grouped on the hex of their first byte (DF)
+// and fifth byte (TC of long messages); either way the scan stays in SQLite and few groups come back.
+func (r *beastMessageRepository) MessageTypes(filter MessageFilter) (models.MessageTypeBreakdown, error) {
+	conditions, args := filter.conditions()
+	query := fmt.Sprintf(`SELECT COALESCE(message_type, '') = 'mode_ac', downlink_format, type_code,
+		CASE WHEN downlink_format IS NULL THEN substr(message_hex, 1, 2) ELSE '' END,
+		CASE WHEN downlink_format IS NULL AND length(message_hex) = %d THEN substr(message_hex, 9, 2) ELSE '' END,
+		COUNT(*)
+		FROM beast_messages %s GROUP BY 1, 2, 3, 4, 5`, models.BeastDataLenModeSLong*2, whereClause(conditions))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return models.MessageTypeBreakdown{}, fmt.Errorf("failed to count message types: %w", err)
+	}
+	defer rows.Close()
+
+	counter := models.NewMessageTypeCounter()
+	for rows.Next() {
+		var modeAC bool
+		var df, tc sql.NullInt64
+		var first, fifth string
+		var count int64
+		if err := rows.Scan(&modeAC, &df, &tc, &first, &fifth, &count); err != nil {
+			return models.MessageTypeBreakdown{}, fmt.Errorf("failed to scan message type count: %w", err)
+		}
+		switch {
+		case modeAC:
+			counter.AddCount(models.DFModeAC, -1, count)
+		case df.Valid:
+			counter.AddCount(int(df.Int64), int(tc.Int64), count)
+		default:
+			df, tc, err := decodeMessageType(first, fifth)
+			if err != nil {
+				return models.MessageTypeBreakdown{}, err
+			}
+			counter.AddCount(df, tc, count)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return models.MessageTypeBreakdown{}, fmt.Errorf("failed to read message type counts: %w", err)
+	}
+	return counter.Breakdown(), nil
+}
+
+// ReceiverCoverage is what one receiver heard, for comparing receivers feeding the same station
+type ReceiverCoverage struct {
+	Receiver       string `json:"receiver"` // Empty for the messages of inputs without a receiver ID, e.g. beast_addr
+	Messages       int64  `json:"messages"`
+	Positions      int64  `json:"positions"`       // Messages with a decoded position
+	Aircraft       int    `json:"aircraft"`        // Aircraft heard, by DF11 and DF17 replies naming them in the clear
+	UniqueAircraft int    `json:"unique_aircraft"` // Aircraft no other receiver heard
+	MaxSignal      int    `json:"max_signal"`
+}
+
+// Receivers compares the filtered messages by the receiver that heard them, ordered by receiver
+func (r *beastMessageRepository) Receivers(filter MessageFilter) ([]*ReceiverCoverage, error) {
+	conditions, args := filter.conditions()
+	query := fmt.Sprintf(`WITH m AS (
+			SELECT COALESCE(receiver, '') AS receiver, latitude, signal_level,
+				CASE WHEN downlink_format IN (11, 17) THEN icao END AS aircraft
+			FROM beast_messages %s
+		), heard AS (
+			SELECT aircraft, COUNT(DISTINCT receiver) AS receivers FROM m WHERE aircraft IS NOT NULL GROUP BY aircraft
+		)
+		SELECT m.receiver, COUNT(*), COUNT(m.latitude), COUNT(DISTINCT m.aircraft),
+			COUNT(DISTINCT CASE WHEN heard.receivers = 1 THEN m.aircraft END), COALESCE(MAX(m.signal_level), 0)
+		FROM m LEFT JOIN heard ON heard.aircraft = m.aircraft
+		GROUP BY m.receiver ORDER BY m.receiver`, whereClause(conditions))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare receivers: %w", err)
+	}
+	defer rows.Close()
+
+	receivers := []*ReceiverCoverage{}
+	for rows.Next() {
+		c := &ReceiverCoverage{}
+		if err := rows.Scan(&c.Receiver, &c.Messages, &c.Positions, &c.Aircraft, &c.UniqueAircraft, &c.MaxSignal); err != nil {
+			return nil, fmt.Errorf("failed to scan receiver: %w", err)
+		}
+		receivers = append(receivers, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read receivers: %w", err)
+	}
+	return receivers, nil
+}
+
+// decodeMessageType returns the downlink format and type code (-1 if none) from the hex of a message's first and fifth bytes
+func decodeMessageType(first, fifth string) (int, int, error) {
+	b, err := strconv.ParseUint(first, 16, 8)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid stored message hex %q: %w", first, err)
+	}
+	df, tc := int(b>>3), -1
+	if (df == 17 || df == 18) && fifth != "" {
+		b, err := strconv.ParseUint(fifth, 16, 8)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid stored message hex %q: %w", fifth, err)
+		}
+		tc = int(b >> 3)
+	}
+	return df, tc, nil
+}
+
+// Tracks splits an aircraft's stored messages into tracks wherever it went unheard for longer than gap
+// The split runs in SQLite over the icao index, so only one row per returned track comes back.
+// Aircraft are tracked from stored messages, which the state storage mode doesn't keep.
+func (r *beastMessageRepository) Tracks(icao string, gap time.Duration, limit int) (*TrackHistory, error) {
+	// Julian days are fractional days; start and end come back as unix milliseconds
+	rows, err := r.db.Query(`WITH m AS (
+			SELECT julianday(timestamp) AS jd, signal_level FROM beast_messages WHERE icao = ?
+		), gaps AS (
+			SELECT jd, signal_level, CASE WHEN jd - LAG(jd) OVER (ORDER BY jd) > ? THEN 1 ELSE 0 END AS split FROM m
+		), numbered AS (
+			SELECT jd, signal_level, SUM(split) OVER (ORDER BY jd ROWS UNBOUNDED PRECEDING) AS track FROM gaps
+		)
+		SELECT CAST(ROUND((MIN(jd) - 2440587.5) * 86400000) AS INTEGER),
+			CAST(ROUND((MAX(jd) - 2440587.5) * 86400000) AS INTEGER),
+			COUNT(*), COALESCE(MAX(signal_level), 0),
+			COUNT(*) OVER (), SUM((MAX(jd) - MIN(jd)) * 86400) OVER ()
+		FROM numbered GROUP BY track ORDER BY track DESC LIMIT ?`,
+		strings.ToUpper(icao), gap.Hours()/24, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tracks: %w", err)
+	}
+	defer rows.Close()
+
+	history := &TrackHistory{Recent: []*Track{}}
+	for rows.Next() {
+		var start, end int64
+		t := &Track{}
+		if err := rows.Scan(&start, &end, &t.Messages, &t.MaxSignal, &history.Count, &history.Seconds); err != nil {
+			return nil, fmt.Errorf("failed to scan track: %w", err)
+		}
+		t.Start, t.End = time.UnixMilli(start).UTC(), time.UnixMilli(end).UTC()
+		t.Positions = []*TrackPoint{}
+		history.Recent = append(history.Recent, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tracks: %w", err)
+	}
+	rows.Close()
+	if err := r.trackPositions(strings.ToUpper(icao), history.Recent); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// trackPositions adds the decoded positions to tracks, newest first as Tracks returns them, one every
+// trackPointSpacing at most. Positions encrypted without the key to read them are left out.
+func (r *beastMessageRepository) trackPositions(icao string, tracks []*Track) error {
+	if len(tracks) == 0 {
+		return nil
+	}
+	// The track times are rounded to the millisecond
+	from, to := tracks[len(tracks)-1].Start.Add(-time.Millisecond), tracks[0].End.Add(time.Millisecond)
+	rows, err := r.db.Query(`SELECT timestamp, latitude, longitude, altitude FROM beast_messages
+		WHERE icao = ? AND latitude IS NOT NULL AND longitude IS NOT NULL AND timestamp >= ? AND timestamp <= ?
+		ORDER BY timestamp`, icao, from, to)
+	if err != nil {
+		return fmt.Errorf("failed to query track positions: %w", err)
+	}
+	defer rows.Close()
+
+	i := len(tracks) - 1 // Oldest first, as the positions come
+	for rows.Next() {
+		var at time.Time
+		var lat, lon sql.NullString // Numbers, or text when encrypted
+		var altitude sql.NullInt64
+		if err := rows.Scan(&at, &lat, &lon, &altitude); err != nil {
+			return fmt.Errorf("failed to scan track position: %w", err)
+		}
+		for i > 0 && at.After(tracks[i].End.Add(time.Millisecond)) {
+			i--
+		}
+		t := tracks[i]
+		if n := len(t.Positions); n > 0 && at.Sub(t.Positions[n-1].Time) < trackPointSpacing {
+			continue
+		}
+		latitude, err := unsealFloat(r.cipher, lat)
+		if errors.Is(err, ErrEncrypted) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		longitude, err := unsealFloat(r.cipher, lon)
+		if errors.Is(err, ErrEncrypted) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		t.Positions = append(t.Positions, &TrackPoint{Time: at.UTC(), Latitude: *latitude, Longitude: *longitude,
+			Altitude: nullInt(altitude)})
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read track positions: %w", err)
+	}
 	return nil
 }
+
+// storedTicks returns a Beast timestamp as stored: all 48 bits as they came, since the time derived from them is
+// anchored to the host clock and MLAT needs the receiver's own; NULL for 0, which receivers send when they have none
+func storedTicks(ticks uint64) any {
+	if ticks == 0 {
+		return nil
+	}
+	return int64(ticks)
+}
+
+// storedReceiver returns a message's receiver as stored, NULL when its input has none so the row takes no room for it
+func storedReceiver(receiver string) any {
+	if receiver == "" {
+		return nil
+	}
+	return receiver
+}
+
+// nullInt returns a nullable column's value, nil when it's NULL
+func nullInt(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	n := int(v.Int64)
+	return &n
+}
+
+// nullFloat returns a nullable column's value, nil when it's NULL
+func nullFloat(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+// migrateBatch is how many messages migrateMessageAddresses reads and rewrites per transaction
+const migrateBatch = 5000
+
+// migrateMessageAddresses corrects the address of messages stored by earlier versions of extractICAO, by parsing
+// their frames again. The first took it from the CA field and the first two address bytes, e.g. 054840 for
+// 4840D6; those are the rows without a downlink format, which was added later, and are given one. Later ones read
+// bytes 1-3 of every format, which only DF11, DF17, and DF18 carry the address in; replies that overlay it on
+// their parity get it from the CRC residual now, and other formats none. Rows whose frame wasn't kept, or is
+// encrypted, stay as they are.
+func migrateMessageAddresses(db *sql.DB) error {
+	typeCodes := map[int]byte{
+		models.BeastDataLenModeAC * 2:     models.BeastTypeModeAC,
+		models.BeastDataLenModeSShort * 2: models.BeastTypeModeSShort,
+		models.BeastDataLenModeSLong * 2:  models.BeastTypeModeSLong,
+	}
+	type fix struct {
+		id     int64
+		icao   string
+		df, tc int
+	}
+	var after int64
+	for {
+		rows, err := db.Query(`SELECT id, message_hex FROM beast_messages
+			WHERE length(message_hex) IN (?, ?, ?) AND id > ? AND (downlink_format IS NULL OR
+				(downlink_format >= 0 AND downlink_format NOT IN (11, 17, 18) AND icao = upper(substr(message_hex, 3, 6))))
+			ORDER BY id LIMIT ?`,
+			models.BeastDataLenModeAC*2, models.BeastDataLenModeSShort*2, models.BeastDataLenModeSLong*2, after, migrateBatch)
+		if err != nil {
+			return fmt.Errorf("failed to read message addresses: %w", err)
+		}
+		var fixes []fix
+		count := 0
+		for rows.Next() {
+			var id int64
+			var raw string
+			if err := rows.Scan(&id, &raw); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan message address: %w", err)
+			}
+			count++
+			after = id
+			frame, err := hex.DecodeString(raw)
+			if err != nil {
+				continue
+			}
+			msg, err := models.NewBeastMessage(typeCodes[len(raw)], 0, 0, frame, time.Time{})
+			if err != nil {
+				continue
+			}
+			fixes = append(fixes, fix{id: id, icao: msg.ICAO, df: msg.DownlinkFormat(), tc: msg.TypeCode()})
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("failed to read message addresses: %w", err)
+		}
+		if count == 0 {
+			return nil
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		for _, f := range fixes {
+			if _, err := tx.Exec(`UPDATE beast_messages SET icao = ?, downlink_format = ?, type_code = ? WHERE id = ?`,
+				f.icao, f.df, f.tc, f.id); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("failed to correct message address: %w", err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Connection event types
+const (
+	ConnectionUp   = "connect"
+	ConnectionDown = "disconnect"
+)
+
+// ReasonShutdown is the disconnect reason recorded when the daemon stops; the time until it
+// connects again is neither uptime nor downtime.
+const ReasonShutdown = "shutdown"
+
+// ConnectionEvent is an input connecting or losing its connection
+type ConnectionEvent struct {
+	ID       int64     `json:"id"`
+	Input    string    `json:"input"` // The input's address, e.g. piaware.local:30005
+	Type     string    `json:"type"`  // connect or disconnect
+	Time     time.Time `json:"time"`
+	Reason   string    `json:"reason,omitempty"` // Why the connection was lost
+	Duration float64   `json:"duration"`         // Seconds since the previous event: down before a connect, up before a disconnect
+}
+
+// InputUptime summarizes an input's connections over a period
+type InputUptime struct {
+	Input         string     `json:"input"`
+	Connected     bool       `json:"connected"`
+	Tracked       float64    `json:"tracked_seconds"` // Time the daemon was running within the period
+	Up            float64    `json:"up_seconds"`
+	UptimePercent float64    `json:"uptime_percent"`
+	Disconnects   int        `json:"disconnects"` // Lost connections, not counting shutdowns
+	LongestOutage float64    `json:"longest_outage_seconds"`
+	LastChange    *time.Time `json:"last_change,omitempty"`
+}
+
+type ConnectionRepository interface {
+	Insert(event *ConnectionEvent) error
+	List(since time.Time, limit int) ([]*ConnectionEvent, error)
+	Uptime(since, now time.Time) ([]*InputUptime, error)
+}
+
+type connectionRepository struct {
+	db *sql.DB
+}
+
+func NewConnectionRepository(db *sql.DB) ConnectionRepository {
+	return &connectionRepository{db: db}
+}
+
+func (r *connectionRepository) Insert(event *ConnectionEvent) error {
+	result, err := r.db.Exec(`INSERT INTO connection_events (input, type, time, reason, duration) VALUES (?, ?, ?, ?, ?)`,
+		event.Input, event.Type, event.Time.UTC(), event.Reason, event.Duration)
+	if err != nil {
+		return fmt.Errorf("failed to record connection event: %w", err)
+	}
+	event.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// List returns the connection events since a time, newest first
+func (r *connectionRepository) List(since time.Time, limit int) ([]*ConnectionEvent, error) {
+	rows, err := r.db.Query(`SELECT id, input, type, time, reason, duration FROM connection_events
+		WHERE time >= ? ORDER BY time DESC, id DESC LIMIT ?`, since.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query connection events: %w", err)
+	}
+	return scanConnectionEvents(rows)
+}
+
+// Uptime replays each input's events over [since, now] and sums the time it was connected.
+// Time after a shutdown is untracked, so restarting the daemon doesn't count as an outage.
+func (r *connectionRepository) Uptime(since, now time.Time) ([]*InputUptime, error) {
+	inputs, err := r.inputs()
+	if err != nil {
+		return nil, err
+	}
+
+	uptimes := make([]*InputUptime, 0, len(inputs))
+	for _, input := range inputs {
+		// The last event before the period tells the state it started in
+		var before *ConnectionEvent
+		rows, err := r.db.Query(`SELECT id, input, type, time, reason, duration FROM connection_events
+			WHERE input = ? AND time < ? ORDER BY time DESC, id DESC LIMIT 1`, input, since.UTC())
+		if err != nil {
+			return nil, fmt.Errorf("failed to query connection events: %w", err)
+		}
+		prior, err := scanConnectionEvents(rows)
+		if err != nil {
+			return nil, err
+		}
+		if len(prior) > 0 {
+			before = prior[0]
+		}
+
+		rows, err = r.db.Query(`SELECT id, input, type, time, reason, duration FROM connection_events
+			WHERE input = ? AND time >= ? AND time <= ? ORDER BY time, id`, input, since.UTC(), now.UTC())
+		if err != nil {
+			return nil, fmt.Errorf("failed to query connection events: %w", err)
+		}
+		events, err := scanConnectionEvents(rows)
+		if err != nil {
+			return nil, err
+		}
+		uptimes = append(uptimes, replayUptime(input, before, events, since, now))
+	}
+	return uptimes, nil
+}
+
+const (
+	stateUntracked = iota
+	stateDown
+	stateUp
+)
+
+// replayUptime sums up and down time over [since, now] from the events in that period, in order
+func replayUptime(input string, before *ConnectionEvent, events []*ConnectionEvent, since, now time.Time) *InputUptime {
+	u := &InputUptime{Input: input}
+	state := stateUntracked
+	cursor := since
+	var downSince time.Time
+
+	switch {
+	case before != nil && before.Type == ConnectionUp:
+		state = stateUp
+		u.LastChange = &before.Time
+	case before != nil && before.Reason != ReasonShutdown:
+		state, downSince = stateDown, before.Time
+		u.LastChange = &before.Time
+	case len(events) > 0 && events[0].Type == ConnectionUp:
+		// The daemon started within the period and took a while to connect for the first time
+		if start := events[0].Time.Add(-time.Duration(events[0].Duration * float64(time.Second))); start.After(since) {
+			cursor = start
+		}
+		state, downSince = stateDown, cursor
+	}
+
+	advance := func(to time.Time) {
+		seconds := to.Sub(cursor).Seconds()
+		if state != stateUntracked {
+			u.Tracked += seconds
+		}
+		if state == stateUp {
+			u.Up += seconds
+		}
+		cursor = to
+	}
+	endOutage := func(at time.Time) {
+		if state == stateDown {
+			if start := maxTime(downSince, since); at.Sub(start).Seconds() > u.LongestOutage {
+				u.LongestOutage = at.Sub(start).Seconds()
+			}
+		}
+	}
+
+	for _, e := range events {
+		advance(e.Time)
+		endOutage(e.Time)
+		switch {
+		case e.Type == ConnectionUp:
+			state = stateUp
+		case e.Reason == ReasonShutdown:
+			state = stateUntracked
+		default:
+			state, downSince = stateDown, e.Time
+			u.Disconnects++
+		}
+		u.LastChange = &e.Time
+	}
+	advance(now)
+	endOutage(now)
+
+	u.Connected = state == stateUp
+	if u.Tracked > 0 {
+		u.UptimePercent = u.Up / u.Tracked * 100
+	}
+	return u
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func (r *connectionRepository) inputs() ([]string, error) {
+	rows, err := r.db.Query(`SELECT DISTINCT input FROM connection_events ORDER BY input`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query connection inputs: %w", err)
+	}
+	defer rows.Close()
+
+	var inputs []string
+	for rows.Next() {
+		var input string
+		if err := rows.Scan(&input); err != nil {
+			return nil, fmt.Errorf("failed to scan connection input: %w", err)
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs, rows.Err()
+}
+
+func scanConnectionEvents(rows *sql.Rows) ([]*ConnectionEvent, error) {
+	defer rows.Close()
+
+	var events []*ConnectionEvent
+	for rows.Next() {
+		e := &ConnectionEvent{}
+		if err := rows.Scan(&e.ID, &e.Input, &e.Type, &e.Time, &e.Reason, &e.Duration); err != nil {
+			return nil, fmt.Errorf("failed to scan connection event: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// CoverageCheck compares the aircraft the receiver heard at one moment with those an aggregator knew of within
+// the same radius
+type CoverageCheck struct {
+	ID       int64            `json:"id"`
+	Time     time.Time        `json:"time"`
+	Source   string           `json:"source"`   // Host of the aggregator asked, e.g. adsbexchange.com
+	Expected int              `json:"expected"` // Aircraft the aggregator had a position for within the radius
+	Seen     int              `json:"seen"`     // Of those, aircraft the receiver was hearing too
+	Sectors  []CoverageSector `json:"sectors"`  // By bearing from the receiver, the first centred on north
+}
+
+// CoverageSector counts the expected and seen aircraft in one direction from the receiver
+type CoverageSector struct {
+	Bearing  float64 `json:"bearing"` // Centre of the sector, degrees true
+	Expected int     `json:"expected"`
+	Seen     int     `json:"seen"`
+	Range    float64 `json:"range"` // Kilometres to the farthest aircraft heard in the sector, expected or not
+}
+
+type CoverageRepository interface {
+	Insert(check *CoverageCheck) error
+	List(since time.Time) ([]*CoverageCheck, error)
+}
+
+type coverageRepository struct {
+	db *sql.DB
+}
+
+func NewCoverageRepository(db *sql.DB) CoverageRepository {
+	return &coverageRepository{db: db}
+}
+
+// Insert stores a check and sets its ID
+func (r *coverageRepository) Insert(check *CoverageCheck) error {
+	sectors, err := json.Marshal(check.Sectors)
+	if err != nil {
+		return fmt.Errorf("failed to encode coverage sectors: %w", err)
+	}
+	result, err := r.db.Exec(`INSERT INTO coverage_checks (time, source, expected, seen, sectors) VALUES (?, ?, ?, ?, ?)`,
+		check.Time.UTC(), check.Source, check.Expected, check.Seen, string(sectors))
+	if err != nil {
+		return fmt.Errorf("failed to insert coverage check: %w", err)
+	}
+	if check.ID, err = result.LastInsertId(); err != nil {
+		return fmt.Errorf("failed to read coverage check id: %w", err)
+	}
+	return nil
+}
+
+// List returns the checks made at or after since, oldest first
+func (r *coverageRepository) List(since time.Time) ([]*CoverageCheck, error) {
+	rows, err := r.db.Query(`SELECT id, time, source, expected, seen, sectors FROM coverage_checks
+		WHERE time >= ? ORDER BY time, id`, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query coverage checks: %w", err)
+	}
+	defer rows.Close()
+
+	var checks []*CoverageCheck
+	for rows.Next() {
+		c := &CoverageCheck{}
+		var sectors string
+		if err := rows.Scan(&c.ID, &c.Time, &c.Source, &c.Expected, &c.Seen, &sectors); err != nil {
+			return nil, fmt.Errorf("failed to scan coverage check: %w", err)
+		}
+		if err := json.Unmarshal([]byte(sectors), &c.Sectors); err != nil {
+			return nil, fmt.Errorf("failed to decode coverage sectors: %w", err)
+		}
+		checks = append(checks, c)
+	}
+	return checks, rows.Err()
+}
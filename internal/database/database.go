@@ -1,15 +1,20 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+
+	"flight_trmnl/internal/crypt"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 // DB holds the database connection and provides access to repositories
 type DB struct {
-	db *sql.DB
+	db     *sql.DB
+	cipher crypt.Cipher // Encrypts sensitive columns, nil stores them in the clear
 }
 
 // DB returns the underlying *sql.DB connection for use by repositories
@@ -24,7 +29,88 @@ func (d *DB) AircraftRepository() AircraftRepository {
 
 // BeastMessageRepository returns a new BeastMessageRepository instance
 func (d *DB) BeastMessageRepository() BeastMessageRepository {
-	return NewBeastMessageRepository(d.db)
+	return &beastMessageRepository{db: d.db, mode: StorageRaw, cipher: d.cipher}
+}
+
+// BeastMessageRepositoryWithMode returns a BeastMessageRepository that stores messages per the storage mode, leaving
+// the omitted columns empty
+func (d *DB) BeastMessageRepositoryWithMode(mode string, omit ...string) BeastMessageRepository {
+	return &beastMessageRepository{db: d.db, mode: mode, omit: omittedColumns(omit), cipher: d.cipher}
+}
+
+// SeenAircraftRepository returns a new SeenAircraftRepository instance
+func (d *DB) SeenAircraftRepository() SeenAircraftRepository {
+	return NewSeenAircraftRepository(d.db)
+}
+
+// EventRepository returns a new EventRepository instance
+func (d *DB) EventRepository() EventRepository {
+	return NewEventRepository(d.db)
+}
+
+// FleetRepository returns a new FleetRepository instance
+func (d *DB) FleetRepository() FleetRepository {
+	return NewFleetRepository(d.db)
+}
+
+// StateSnapshotRepository returns a new StateSnapshotRepository instance
+func (d *DB) StateSnapshotRepository() StateSnapshotRepository {
+	return &stateSnapshotRepository{db: d.db, cipher: d.cipher}
+}
+
+// TagRepository returns a new TagRepository instance
+func (d *DB) TagRepository() TagRepository {
+	return NewTagRepository(d.db)
+}
+
+// EnrichmentRepository returns a new EnrichmentRepository instance
+func (d *DB) EnrichmentRepository() EnrichmentRepository {
+	return NewEnrichmentRepository(d.db)
+}
+
+// HubStationRepository returns a new HubStationRepository instance
+func (d *DB) HubStationRepository() HubStationRepository {
+	return NewHubStationRepository(d.db)
+}
+
+// ReceiverStatsRepository returns a new ReceiverStatsRepository instance
+func (d *DB) ReceiverStatsRepository() ReceiverStatsRepository {
+	return NewReceiverStatsRepository(d.db)
+}
+
+// ConnectionRepository returns a new ConnectionRepository instance
+func (d *DB) ConnectionRepository() ConnectionRepository {
+	return NewConnectionRepository(d.db)
+}
+
+// TaskRunRepository returns a new TaskRunRepository instance
+func (d *DB) TaskRunRepository() TaskRunRepository {
+	return NewTaskRunRepository(d.db)
+}
+
+// RecordRepository returns a new RecordRepository instance
+func (d *DB) RecordRepository() RecordRepository {
+	return NewRecordRepository(d.db)
+}
+
+// AdvisoryRepository returns a new AdvisoryRepository instance
+func (d *DB) AdvisoryRepository() AdvisoryRepository {
+	return NewAdvisoryRepository(d.db)
+}
+
+// FlightRepository returns a new FlightRepository instance
+func (d *DB) FlightRepository() FlightRepository {
+	return NewFlightRepository(d.db)
+}
+
+// CoverageRepository returns a new CoverageRepository instance
+func (d *DB) CoverageRepository() CoverageRepository {
+	return NewCoverageRepository(d.db)
+}
+
+// SyncRepository returns a new SyncRepository instance
+func (d *DB) SyncRepository() SyncRepository {
+	return NewSyncRepository(d.db)
 }
 
 // New creates and initializes a new database connection
@@ -54,6 +140,26 @@ func New(dbPath string) (*DB, error) {
 	return database, nil
 }
 
+// OpenReadOnly opens a separate connection for ad-hoc queries that SQLite refuses to write through.
+// The file is opened with mode=ro, so the setting can't be switched off with a PRAGMA and holds
+// for every connection the pool opens.
+func OpenReadOnly(dbPath string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", "file:"+escapeURIPath(dbPath)+"?mode=ro&_query_only=1&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database read-only: %w", err)
+	}
+	return db, nil
+}
+
+// escapeURIPath escapes the characters that would end or change the path part of a file: URI
+func escapeURIPath(path string) string {
+	return strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23").Replace(path)
+}
+
 // optimizeSQLite applies performance optimizations for Raspberry Pi
 func optimizeSQLite(db *sql.DB) error {
 	// Enable WAL mode for better concurrency (allows concurrent reads)
@@ -91,6 +197,37 @@ func (d *DB) Close() error {
 	return d.db.Close()
 }
 
+// Stats describes the database file and connection pool, e.g. for crash reports
+type Stats struct {
+	JournalMode string      `json:"journal_mode"`
+	PageSize    int64       `json:"page_size"`
+	Pages       int64       `json:"pages"`
+	FreePages   int64       `json:"free_pages"`
+	Size        int64       `json:"size"` // Bytes in the main file, without the WAL
+	Pool        sql.DBStats `json:"pool"`
+}
+
+// Stats reads the database stats, quickly enough to run while the daemon is crashing
+func (d *DB) Stats(ctx context.Context) (*Stats, error) {
+	stats := &Stats{Pool: d.db.Stats()}
+	pragmas := []struct {
+		name string
+		dest any
+	}{
+		{"journal_mode", &stats.JournalMode},
+		{"page_size", &stats.PageSize},
+		{"page_count", &stats.Pages},
+		{"freelist_count", &stats.FreePages},
+	}
+	for _, p := range pragmas {
+		if err := d.db.QueryRowContext(ctx, "PRAGMA "+p.name).Scan(p.dest); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", p.name, err)
+		}
+	}
+	stats.Size = stats.PageSize * stats.Pages
+	return stats, nil
+}
+
 // initSchema creates the database schema if it doesn't exist
 // Keeping schema with database.go instead of repository as it is a database level concern.
 func (d *DB) initSchema() error {
@@ -136,12 +273,171 @@ func (d *DB) initSchema() error {
 		serialNumber TEXT,
 		status TEXT,
 		typecode TEXT,
-		vdl TEXT
+		vdl TEXT,
+		curated INTEGER NOT NULL DEFAULT 0
+	);`
+
+	seenAircraftSchema := `CREATE TABLE IF NOT EXISTS seen_aircraft (
+		icao TEXT PRIMARY KEY,
+		first_seen TIMESTAMP NOT NULL,
+		last_seen TIMESTAMP NOT NULL,
+		message_count INTEGER NOT NULL DEFAULT 0,
+		callsign TEXT NOT NULL DEFAULT '',
+		source TEXT NOT NULL DEFAULT ''
+	);`
+
+	eventsSchema := `CREATE TABLE IF NOT EXISTS events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		time TIMESTAMP NOT NULL,
+		type TEXT NOT NULL,
+		severity TEXT NOT NULL,
+		icao TEXT NOT NULL DEFAULT '',
+		callsign TEXT NOT NULL DEFAULT '',
+		message TEXT NOT NULL,
+		data TEXT NOT NULL DEFAULT ''
+	);`
+
+	aircraftTagsSchema := `CREATE TABLE IF NOT EXISTS aircraft_tags (
+		icao TEXT NOT NULL,
+		source TEXT NOT NULL,
+		registration TEXT NOT NULL DEFAULT '',
+		operator TEXT NOT NULL DEFAULT '',
+		type TEXT NOT NULL DEFAULT '',
+		grp TEXT NOT NULL DEFAULT '',
+		category TEXT NOT NULL DEFAULT '',
+		tags TEXT NOT NULL DEFAULT '',
+		link TEXT NOT NULL DEFAULT '',
+		updated_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (icao, source)
+	);`
+
+	enrichmentQueueSchema := `CREATE TABLE IF NOT EXISTS enrichment_queue (
+		icao TEXT PRIMARY KEY,
+		status TEXT NOT NULL,
+		queued_at TIMESTAMP NOT NULL,
+		resolvers TEXT NOT NULL DEFAULT '',
+		resolver TEXT NOT NULL DEFAULT '',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_attempt TIMESTAMP,
+		last_error TEXT NOT NULL DEFAULT ''
+	);`
+
+	stateSnapshotsSchema := `CREATE TABLE IF NOT EXISTS state_snapshots (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		time TIMESTAMP NOT NULL,
+		aircraft_count INTEGER NOT NULL,
+		aircraft TEXT NOT NULL
+	);`
+
+	hubStationsSchema := `CREATE TABLE IF NOT EXISTS hub_stations (
+		name TEXT PRIMARY KEY,
+		key_hash TEXT NOT NULL,
+		rate_limit REAL NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL,
+		revoked_at TIMESTAMP
+	);`
+
+	// Hours are unix seconds at the start of the hour, so the same hour yesterday is 86400 earlier
+	receiverHoursSchema := `CREATE TABLE IF NOT EXISTS receiver_hours (
+		hour INTEGER PRIMARY KEY,
+		messages INTEGER NOT NULL DEFAULT 0,
+		parse_errors INTEGER NOT NULL DEFAULT 0,
+		reconnects INTEGER NOT NULL DEFAULT 0
+	);`
+
+	connectionEventsSchema := `CREATE TABLE IF NOT EXISTS connection_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		input TEXT NOT NULL,
+		type TEXT NOT NULL,
+		time TIMESTAMP NOT NULL,
+		reason TEXT NOT NULL DEFAULT '',
+		duration REAL NOT NULL DEFAULT 0
+	);`
+
+	stationRecordsSchema := `CREATE TABLE IF NOT EXISTS station_records (
+		category TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		value INTEGER NOT NULL,
+		icao TEXT NOT NULL,
+		callsign TEXT NOT NULL DEFAULT '',
+		time TIMESTAMP NOT NULL,
+		PRIMARY KEY (category, kind)
+	);`
+
+	taskRunsSchema := `CREATE TABLE IF NOT EXISTS task_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task TEXT NOT NULL,
+		trigger TEXT NOT NULL DEFAULT '',
+		started TIMESTAMP NOT NULL,
+		finished TIMESTAMP NOT NULL,
+		error TEXT NOT NULL DEFAULT ''
+	);`
+
+	resolutionAdvisoriesSchema := `CREATE TABLE IF NOT EXISTS resolution_advisories (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		time TIMESTAMP NOT NULL,
+		icao TEXT NOT NULL,
+		callsign TEXT NOT NULL DEFAULT '',
+		altitude INTEGER,
+		ara INTEGER NOT NULL,
+		rac INTEGER NOT NULL DEFAULT 0,
+		summary TEXT NOT NULL,
+		terminated INTEGER NOT NULL DEFAULT 0,
+		multiple_threats INTEGER NOT NULL DEFAULT 0,
+		threat_icao TEXT NOT NULL DEFAULT ''
+	);`
+
+	flightsSchema := `CREATE TABLE IF NOT EXISTS flights (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		icao TEXT NOT NULL,
+		callsign TEXT NOT NULL DEFAULT '',
+		category TEXT NOT NULL DEFAULT '',
+		first_seen TIMESTAMP NOT NULL,
+		last_seen TIMESTAMP NOT NULL,
+		messages INTEGER NOT NULL,
+		quality INTEGER NOT NULL,
+		gaps INTEGER NOT NULL DEFAULT 0,
+		longest_gap REAL NOT NULL DEFAULT 0,
+		positions INTEGER NOT NULL DEFAULT 0,
+		position_rate REAL NOT NULL DEFAULT 0,
+		signal_mean REAL NOT NULL DEFAULT 0,
+		signal_stddev REAL NOT NULL DEFAULT 0
+	);`
+
+	coverageChecksSchema := `CREATE TABLE IF NOT EXISTS coverage_checks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		time TIMESTAMP NOT NULL,
+		source TEXT NOT NULL DEFAULT '',
+		expected INTEGER NOT NULL,
+		seen INTEGER NOT NULL,
+		sectors TEXT NOT NULL DEFAULT '[]'
+	);`
+
+	// The last row copied from each source database by sync, by the id it has there
+	syncCursorsSchema := `CREATE TABLE IF NOT EXISTS sync_cursors (
+		source TEXT NOT NULL,
+		table_name TEXT NOT NULL,
+		last_id INTEGER NOT NULL,
+		updated_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (source, table_name)
 	);`
 
 	indexes := []string{
 		`CREATE INDEX IF NOT EXISTS idx_beast_messages_icao ON beast_messages(icao)`,
 		`CREATE INDEX IF NOT EXISTS idx_beast_messages_timestamp ON beast_messages(timestamp)`,
+		`CREATE INDEX IF NOT EXISTS idx_seen_aircraft_last_seen ON seen_aircraft(last_seen)`,
+		`CREATE INDEX IF NOT EXISTS idx_aircraft_operator_icao ON aircraft(operatorIcao)`,
+		`CREATE INDEX IF NOT EXISTS idx_events_time ON events(time)`,
+		`CREATE INDEX IF NOT EXISTS idx_events_icao ON events(icao)`,
+		`CREATE INDEX IF NOT EXISTS idx_aircraft_tags_source ON aircraft_tags(source)`,
+		`CREATE INDEX IF NOT EXISTS idx_enrichment_queue_status ON enrichment_queue(status)`,
+		`CREATE INDEX IF NOT EXISTS idx_state_snapshots_time ON state_snapshots(time)`,
+		`CREATE INDEX IF NOT EXISTS idx_connection_events_input_time ON connection_events(input, time)`,
+		`CREATE INDEX IF NOT EXISTS idx_task_runs_task_started ON task_runs(task, started)`,
+		`CREATE INDEX IF NOT EXISTS idx_resolution_advisories_time ON resolution_advisories(time)`,
+		`CREATE INDEX IF NOT EXISTS idx_flights_last_seen ON flights(last_seen)`,
+		`CREATE INDEX IF NOT EXISTS idx_flights_icao ON flights(icao)`,
+		`CREATE INDEX IF NOT EXISTS idx_coverage_checks_time ON coverage_checks(time)`,
 	}
 
 	if _, err := d.db.Exec(messagesSchema); err != nil {
@@ -152,6 +448,118 @@ func (d *DB) initSchema() error {
 		return fmt.Errorf("failed to create aircraft table: %w", err)
 	}
 
+	if _, err := d.db.Exec(seenAircraftSchema); err != nil {
+		return fmt.Errorf("failed to create seen_aircraft table: %w", err)
+	}
+
+	if _, err := d.db.Exec(eventsSchema); err != nil {
+		return fmt.Errorf("failed to create events table: %w", err)
+	}
+
+	if _, err := d.db.Exec(aircraftTagsSchema); err != nil {
+		return fmt.Errorf("failed to create aircraft_tags table: %w", err)
+	}
+
+	if _, err := d.db.Exec(enrichmentQueueSchema); err != nil {
+		return fmt.Errorf("failed to create enrichment_queue table: %w", err)
+	}
+
+	if _, err := d.db.Exec(stateSnapshotsSchema); err != nil {
+		return fmt.Errorf("failed to create state_snapshots table: %w", err)
+	}
+
+	if _, err := d.db.Exec(hubStationsSchema); err != nil {
+		return fmt.Errorf("failed to create hub_stations table: %w", err)
+	}
+
+	if _, err := d.db.Exec(receiverHoursSchema); err != nil {
+		return fmt.Errorf("failed to create receiver_hours table: %w", err)
+	}
+
+	if _, err := d.db.Exec(connectionEventsSchema); err != nil {
+		return fmt.Errorf("failed to create connection_events table: %w", err)
+	}
+
+	if _, err := d.db.Exec(stationRecordsSchema); err != nil {
+		return fmt.Errorf("failed to create station_records table: %w", err)
+	}
+
+	if _, err := d.db.Exec(taskRunsSchema); err != nil {
+		return fmt.Errorf("failed to create task_runs table: %w", err)
+	}
+
+	if _, err := d.db.Exec(resolutionAdvisoriesSchema); err != nil {
+		return fmt.Errorf("failed to create resolution_advisories table: %w", err)
+	}
+
+	if _, err := d.db.Exec(flightsSchema); err != nil {
+		return fmt.Errorf("failed to create flights table: %w", err)
+	}
+
+	if _, err := d.db.Exec(coverageChecksSchema); err != nil {
+		return fmt.Errorf("failed to create coverage_checks table: %w", err)
+	}
+
+	if _, err := d.db.Exec(syncCursorsSchema); err != nil {
+		return fmt.Errorf("failed to create sync_cursors table: %w", err)
+	}
+
+	// Columns added after the original schema; CREATE TABLE IF NOT EXISTS won't add them to existing databases
+	if err := d.ensureColumn("aircraft", "curated", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := d.ensureColumn("beast_messages", "downlink_format", "INTEGER"); err != nil {
+		return err
+	}
+	if err := d.ensureColumn("beast_messages", "type_code", "INTEGER"); err != nil {
+		return err
+	}
+	if err := d.ensureColumn("resolution_advisories", "threat_callsign", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := d.ensureColumn("resolution_advisories", "threat_altitude", "INTEGER"); err != nil {
+		return err
+	}
+	if err := d.ensureColumn("flights", "max_range", "REAL NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := d.ensureColumn("seen_aircraft", "category", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	for _, column := range []struct{ name, definition string }{
+		{"callsign", "TEXT"},
+		{"category", "TEXT"},
+		{"squawk", "TEXT"},
+		{"altitude", "INTEGER"},
+		{"latitude", "REAL"},
+		{"longitude", "REAL"},
+		{"speed", "INTEGER"},
+		{"track", "REAL"},
+		{"heading", "REAL"},
+		{"vertical_rate", "INTEGER"},
+		{"bds", "TEXT"},
+		{"selected_altitude", "INTEGER"},
+		{"roll", "REAL"},
+		{"crc_error", "INTEGER NOT NULL DEFAULT 0"},
+		{"nic", "INTEGER"},
+		{"nacp", "INTEGER"},
+		{"sil", "INTEGER"},
+		{"signal_dbfs", "REAL"},
+		{"ticks", "INTEGER"},
+		{"receiver", "TEXT"},
+	} {
+		if err := d.ensureColumn("beast_messages", column.name, column.definition); err != nil {
+			return err
+		}
+	}
+
+	if err := migrateFlightSignals(d.db); err != nil {
+		return err
+	}
+	if err := migrateMessageAddresses(d.db); err != nil {
+		return err
+	}
+
 	for _, idx := range indexes {
 		if _, err := d.db.Exec(idx); err != nil {
 			return fmt.Errorf("failed to create index: %w", err)
@@ -160,3 +568,37 @@ func (d *DB) initSchema() error {
 
 	return nil
 }
+
+// ensureColumn adds a column to an existing table if it is missing
+func (d *DB) ensureColumn(table, column, definition string) error {
+	rows, err := d.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to read %s columns: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return fmt.Errorf("failed to scan %s column info: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read %s columns: %w", table, err)
+	}
+
+	if _, err := d.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
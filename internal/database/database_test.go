@@ -1,19 +1,36 @@
 package database
 
 import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"flight_trmnl/internal/crypt"
+	"flight_trmnl/internal/decoder"
 	"flight_trmnl/internal/models"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+// testDBPath returns the temporary database file of a test; subtest names contain slashes
+func testDBPath(t *testing.T) string {
+	return "/tmp/test_adsb_" + strings.ReplaceAll(t.Name(), "/", "_") + ".db"
+}
+
 func setupTestDB(t *testing.T) *DB {
 	// Create a temporary database file
-	tmpFile := "/tmp/test_adsb_" + t.Name() + ".db"
+	tmpFile := testDBPath(t)
 	// Clean up any existing test database
 	os.Remove(tmpFile)
 
@@ -30,8 +47,7 @@ func cleanupTestDB(t *testing.T, db *DB) {
 		assert.NoError(t, err)
 	}
 	// Clean up test database file
-	tmpFile := "/tmp/test_adsb_" + t.Name() + ".db"
-	os.Remove(tmpFile)
+	os.Remove(testDBPath(t))
 }
 
 func TestNew(t *testing.T) {
@@ -42,6 +58,17 @@ func TestNew(t *testing.T) {
 	assert.NotNil(t, db)
 }
 
+func TestStats(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	stats, err := db.Stats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "wal", stats.JournalMode)
+	assert.Positive(t, stats.Pages)
+	assert.Equal(t, stats.PageSize*stats.Pages, stats.Size)
+}
+
 func TestInsertBeastMessagesBatch(t *testing.T) {
 	db := setupTestDB(t)
 	defer cleanupTestDB(t, db)
@@ -67,6 +94,409 @@ func TestInsertBeastMessagesBatch(t *testing.T) {
 
 	err := repo.InsertBatch(msgs)
 	assert.NoError(t, err)
+
+	records, _, err := repo.QueryHistory(MessageFilter{ICAO: "484040"}, PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, 128, records[0].SignalLevel)
+	assert.Equal(t, -6.0, records[0].SignalDBFS)
+
+	_, err = db.DB().Exec(`UPDATE beast_messages SET signal_dbfs = NULL WHERE icao = '484041'`)
+	require.NoError(t, err)
+	records, _, err = repo.QueryHistory(MessageFilter{ICAO: "484041"}, PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, -5.9, records[0].SignalDBFS, "worked out for messages stored before the column")
+}
+
+func TestBeastMessageTypes(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	repo := db.BeastMessageRepository()
+	now := time.Now()
+	require.NoError(t, repo.InsertBatch([]*models.BeastMessage{
+		{Timestamp: now, MessageTypeCode: models.BeastTypeModeSLong, Message: []byte{0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71, 0xC2, 0xD7, 0x20, 0x00, 0x00, 0x00}, ICAO: "4840D6", MessageType: "extended_squitter"},
+		{Timestamp: now, MessageTypeCode: models.BeastTypeModeSLong, Message: []byte{0x8D, 0x48, 0x40, 0xD6, 0x99, 0x44, 0x09, 0x94, 0x08, 0x38, 0x17, 0x5B, 0x28, 0x4F}, ICAO: "4840D6", MessageType: "extended_squitter"},
+		{Timestamp: now, MessageTypeCode: models.BeastTypeModeSShort, Message: []byte{0x5D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3}, ICAO: "4840D6", MessageType: "surveillance"},
+		{Timestamp: now.Add(-time.Hour), MessageTypeCode: models.BeastTypeModeSShort, Message: []byte{0x20, 0x00, 0x0F, 0x1F, 0x68, 0x4A, 0x6C}, ICAO: "A1B2C3", MessageType: "surveillance"},
+		{Timestamp: now, MessageTypeCode: models.BeastTypeModeAC, Message: []byte{0x12, 0x34}, MessageType: "mode_ac"},
+	}))
+
+	breakdown, err := repo.MessageTypes(MessageFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), breakdown.Total)
+	require.Len(t, breakdown.Formats, 4)
+	assert.Equal(t, models.DFModeAC, breakdown.Formats[0].Code)
+	assert.Equal(t, 4, breakdown.Formats[1].Code)
+	assert.Equal(t, 11, breakdown.Formats[2].Code)
+
+	es := breakdown.Formats[3]
+	assert.Equal(t, 17, es.Code)
+	assert.Equal(t, int64(2), es.Count)
+	require.Len(t, es.TypeCodes, 2)
+	assert.Equal(t, 4, es.TypeCodes[0].Code)
+	assert.Equal(t, 19, es.TypeCodes[1].Code)
+
+	recent, err := repo.MessageTypes(MessageFilter{From: now.Add(-time.Minute)})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), recent.Total)
+
+	// Rows stored before the decoded columns existed are decoded from their hex
+	_, err = db.DB().Exec(`INSERT INTO beast_messages (timestamp, icao, message_type, message_hex)
+		VALUES (?, '4840D6', 'extended_squitter', '8d4840d6994409940838175b284f')`, now)
+	require.NoError(t, err)
+	breakdown, err = repo.MessageTypes(MessageFilter{})
+	require.NoError(t, err)
+	require.Len(t, breakdown.Formats, 4)
+	assert.Equal(t, int64(2), breakdown.Formats[3].TypeCodes[1].Count)
+}
+
+func TestBeastMessageReceivers(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	repo := db.BeastMessageRepository()
+	now := time.Now()
+	es := func(icao string, receiver string) *models.BeastMessage {
+		b, _ := hex.DecodeString(icao)
+		msg := []byte{0x8D, b[0], b[1], b[2], 0x20, 0x2C, 0xC3, 0x71, 0xC2, 0xD7, 0x20, 0x00, 0x00, 0x00}
+		return &models.BeastMessage{Timestamp: now, MessageTypeCode: models.BeastTypeModeSLong, Message: msg, ICAO: icao,
+			MessageType: "extended_squitter", SignalLevel: 100, Receiver: receiver}
+	}
+	require.NoError(t, repo.InsertBatch([]*models.BeastMessage{
+		es("4840D6", ""), es("4840D6", "roof"), es("A1B2C3", "roof"), es("A1B2C3", "roof"), es("3C6444", ""),
+	}))
+
+	receivers, err := repo.Receivers(MessageFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []*ReceiverCoverage{
+		{Receiver: "", Messages: 2, Aircraft: 2, UniqueAircraft: 1, MaxSignal: 100},
+		{Receiver: "roof", Messages: 3, Aircraft: 2, UniqueAircraft: 1, MaxSignal: 100},
+	}, receivers)
+
+	records, _, err := repo.QueryHistory(MessageFilter{Receiver: "roof"}, PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, "roof", records[0].Receiver)
+}
+
+func TestBeastMessageStorageModes(t *testing.T) {
+	msgs := []*models.BeastMessage{
+		{Timestamp: time.Now(), MessageTypeCode: models.BeastTypeModeSLong, Message: []byte{0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71, 0xC2, 0xD7, 0x20, 0x00, 0x00, 0x00}, ICAO: "4840D6", MessageType: "extended_squitter"},
+		{Timestamp: time.Now(), MessageTypeCode: models.BeastTypeModeSShort, Message: []byte{0x20, 0x00, 0x0F, 0x1F, 0x68, 0x4A, 0x6C}, ICAO: "A1B2C3", MessageType: "surveillance"},
+		{Timestamp: time.Now(), MessageTypeCode: models.BeastTypeModeAC, Message: []byte{0x12, 0x34}, MessageType: "mode_ac"},
+	}
+
+	tests := []struct {
+		mode     string
+		messages int
+		hex      string
+	}{
+		{StorageRaw, 3, "8d4840d6202cc371c2d720000000"},
+		{StorageDecoded, 1, ""},
+		{StorageState, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			db := setupTestDB(t)
+			defer cleanupTestDB(t, db)
+
+			require.NoError(t, db.BeastMessageRepositoryWithMode(tt.mode).InsertBatch(msgs))
+
+			records, _, err := db.BeastMessageRepository().QueryHistory(MessageFilter{ICAO: "4840D6"}, PageRequest{})
+			require.NoError(t, err)
+			var count int
+			require.NoError(t, db.DB().QueryRow(`SELECT COUNT(*) FROM beast_messages`).Scan(&count))
+			assert.Equal(t, tt.messages, count)
+			if tt.messages > 0 {
+				require.Len(t, records, 1)
+				assert.Equal(t, tt.hex, records[0].MessageHex)
+			}
+
+			sighting, err := db.SeenAircraftRepository().Get("4840D6")
+			require.NoError(t, err)
+			require.NotNil(t, sighting, "the seen aircraft summary is kept in every mode")
+			assert.Equal(t, int64(1), sighting.MessageCount)
+		})
+	}
+}
+
+func TestBeastMessageOmitColumns(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	msg, err := hex.DecodeString("8D4840D6202CC371C32CE0576098")
+	require.NoError(t, err)
+	require.NoError(t, db.BeastMessageRepositoryWithMode(StorageRaw, "message_hex", "signal_level").InsertBatch([]*models.BeastMessage{
+		{Timestamp: time.Now(), MessageTypeCode: models.BeastTypeModeSLong, Message: msg, ICAO: "4840D6", MessageType: "extended_squitter", SignalLevel: 128},
+	}))
+
+	records, _, err := db.BeastMessageRepository().QueryHistory(MessageFilter{ICAO: "4840D6"}, PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Empty(t, records[0].MessageHex)
+	assert.Equal(t, 0, records[0].SignalLevel)
+	assert.Equal(t, -6.0, records[0].SignalDBFS, "the kept signal column")
+	assert.Equal(t, "KLM1023", records[0].Callsign, "decoded fields are kept")
+
+	breakdown, err := db.BeastMessageRepository().MessageTypes(MessageFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), breakdown.Total)
+
+	assert.NoError(t, CheckOmittedColumn("message_hex"))
+	assert.Error(t, CheckOmittedColumn("icao"))
+}
+
+func TestBeastMessageTicks(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	msg, err := hex.DecodeString("8D4840D6202CC371C32CE0576098")
+	require.NoError(t, err)
+	const ticks = 0xFFFF_FFFF_FFF0 // Near the 48-bit wrap, past what a float would keep exactly
+	require.NoError(t, db.BeastMessageRepository().InsertBatch([]*models.BeastMessage{
+		{Timestamp: time.Now(), Ticks: ticks, MessageTypeCode: models.BeastTypeModeSLong, Message: msg, ICAO: "4840D6", MessageType: "extended_squitter"},
+		{Timestamp: time.Now().Add(time.Second), MessageTypeCode: models.BeastTypeModeSLong, Message: msg, ICAO: "4840D6", MessageType: "extended_squitter"},
+	}))
+
+	records, _, err := db.BeastMessageRepository().QueryHistory(MessageFilter{ICAO: "4840D6"}, PageRequest{Sort: "timestamp"})
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.NotNil(t, records[0].Ticks)
+	assert.Equal(t, int64(ticks), *records[0].Ticks)
+	assert.Nil(t, records[1].Ticks, "no timestamp from the receiver")
+}
+
+func TestBeastMessageDecodedFields(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	frame := func(s string) *models.BeastMessage {
+		msg, err := hex.DecodeString(s)
+		require.NoError(t, err)
+		m, err := models.NewBeastMessage(models.BeastTypeModeSLong, 0, 0, msg, time.Now()) // Derives the address and type
+		require.NoError(t, err)
+		return m
+	}
+	position := frame("8D40621D58C382D690C8AC2863A7")
+	position.Position = &decoder.Position{Latitude: 52.2572, Longitude: 3.9194} // As decoded by the tracker
+	corrupt := frame("8D4840D6202CC371C32CE0576099")
+	corrupt.ICAO = "ABCDEF"
+	require.NoError(t, db.BeastMessageRepositoryWithMode(StorageDecoded).InsertBatch([]*models.BeastMessage{
+		frame("8D4840D6202CC371C32CE0576098"), position, frame("8D485020994409940838175B284F"),
+		frame("8DA05F219B06B6AF189400CBC33F"), corrupt,
+	}))
+
+	repo := db.BeastMessageRepository()
+	records, _, err := repo.QueryHistory(MessageFilter{ICAO: "4840D6"}, PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "KLM1023", records[0].Callsign)
+	assert.Nil(t, records[0].Altitude)
+
+	records, _, err = repo.QueryHistory(MessageFilter{ICAO: "40621D"}, PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, 38000, *records[0].Altitude)
+	assert.Equal(t, 52.2572, *records[0].Latitude)
+	assert.Equal(t, 3.9194, *records[0].Longitude)
+
+	records, _, err = repo.QueryHistory(MessageFilter{ICAO: "485020"}, PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, 159, *records[0].Speed)
+	assert.InDelta(t, 182.88, *records[0].Track, 0.01)
+	assert.Equal(t, -832, *records[0].VerticalRate)
+	assert.Nil(t, records[0].Heading)
+	assert.Nil(t, records[0].Latitude)
+
+	records, _, err = repo.QueryHistory(MessageFilter{ICAO: "A05F21"}, PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, 375, *records[0].Speed)
+	assert.Nil(t, records[0].Track)
+	assert.InDelta(t, 243.98, *records[0].Heading, 0.01)
+	assert.Equal(t, -2304, *records[0].VerticalRate)
+
+	records, _, err = repo.QueryHistory(MessageFilter{ICAO: "ABCDEF"}, PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Empty(t, records[0].Callsign, "frames failing the parity check aren't decoded")
+	assert.True(t, records[0].CRCError)
+
+	corrupted, intact := true, false
+	records, _, err = repo.QueryHistory(MessageFilter{CRCError: &corrupted}, PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "ABCDEF", records[0].ICAO)
+	records, _, err = repo.QueryHistory(MessageFilter{CRCError: &intact}, PageRequest{})
+	require.NoError(t, err)
+	assert.Len(t, records, 4)
+}
+
+func TestBeastMessageIntegrity(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	position := func(nic, nacp int) *models.BeastMessage {
+		msg, err := hex.DecodeString("8D40621D58C382D690C8AC2863A7")
+		require.NoError(t, err)
+		m := &models.BeastMessage{Timestamp: time.Now(), MessageTypeCode: models.BeastTypeModeSLong, Message: msg, ICAO: "40621D", MessageType: "extended_squitter"}
+		m.Position = &decoder.Position{Latitude: 52.2572, Longitude: 3.9194}
+		m.Integrity = &decoder.Integrity{NIC: nic}
+		if nacp >= 0 {
+			sil := 3
+			m.Integrity.NACp, m.Integrity.SIL = &nacp, &sil
+		}
+		return m
+	}
+	repo := db.BeastMessageRepository()
+	require.NoError(t, repo.InsertBatch([]*models.BeastMessage{position(8, 9), position(2, 4), position(8, -1)}))
+
+	records, _, err := repo.QueryHistory(MessageFilter{}, PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, 8, *records[0].NIC)
+	assert.Equal(t, 9, *records[0].NACp)
+	assert.Equal(t, 3, *records[0].SIL)
+	assert.Nil(t, records[2].NACp, "no operational status heard")
+
+	records, _, err = repo.QueryHistory(MessageFilter{MinNIC: 7}, PageRequest{})
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+	records, _, err = repo.QueryHistory(MessageFilter{MinNIC: 7, MinNACp: 8}, PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, records, 1, "positions without a NACp are left out")
+	assert.Equal(t, 9, *records[0].NACp)
+}
+
+func TestBeastMessageEncryption(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	frame := func(s string) *models.BeastMessage {
+		msg, err := hex.DecodeString(s)
+		require.NoError(t, err)
+		m, err := models.NewBeastMessage(models.BeastTypeModeSLong, 0, 0, msg, time.Now()) // Derives the address and type
+		require.NoError(t, err)
+		return m
+	}
+	// Stored before encryption was turned on
+	require.NoError(t, db.BeastMessageRepository().InsertBatch([]*models.BeastMessage{frame("8D4840D6202CC371C32CE0576098")}))
+
+	c, err := crypt.NewAES(bytes.Repeat([]byte{1}, crypt.KeySize))
+	require.NoError(t, err)
+	db.SetCipher(c)
+	position := frame("8D40621D58C382D690C8AC2863A7")
+	position.Position = &decoder.Position{Latitude: 52.2572, Longitude: 3.9194}
+	require.NoError(t, db.BeastMessageRepository().InsertBatch([]*models.BeastMessage{position}))
+
+	var raw, latitude string
+	require.NoError(t, db.DB().QueryRow(`SELECT message_hex, latitude FROM beast_messages WHERE icao = '40621D'`).Scan(&raw, &latitude))
+	assert.True(t, strings.HasPrefix(raw, sealedPrefix))
+	assert.True(t, strings.HasPrefix(latitude, sealedPrefix), "the position is encrypted too")
+
+	records, _, err := db.BeastMessageRepository().QueryHistory(MessageFilter{}, PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "8d4840d6202cc371c32ce0576098", records[0].MessageHex, "rows from before encryption read as they are")
+	assert.Equal(t, "8d40621d58c382d690c8ac2863a7", records[1].MessageHex)
+	require.NotNil(t, records[1].Latitude)
+	assert.Equal(t, 52.2572, *records[1].Latitude)
+	assert.Equal(t, 3.9194, *records[1].Longitude)
+	assert.Equal(t, 38000, *records[1].Altitude, "other columns stay queryable")
+
+	_, _, err = NewBeastMessageRepository(db.DB()).QueryHistory(MessageFilter{}, PageRequest{})
+	assert.ErrorIs(t, err, ErrEncrypted)
+
+	other, err := crypt.NewAES(bytes.Repeat([]byte{2}, crypt.KeySize))
+	require.NoError(t, err)
+	db.SetCipher(other)
+	_, _, err = db.BeastMessageRepository().QueryHistory(MessageFilter{}, PageRequest{})
+	assert.ErrorIs(t, err, crypt.ErrDecrypt)
+}
+
+func TestBeastMessageCategory(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	frame := func(s string, at time.Time) *models.BeastMessage {
+		msg, err := hex.DecodeString(s)
+		require.NoError(t, err)
+		m, err := models.NewBeastMessage(models.BeastTypeModeSLong, 0, 0, msg, at)
+		require.NoError(t, err)
+		return m
+	}
+	now := time.Now()
+	repo := db.BeastMessageRepository()
+	// Identification of a heavy, then one without a category
+	require.NoError(t, repo.InsertBatch([]*models.BeastMessage{frame("8D4840D6252CC371C32CE00519A1", now)}))
+	require.NoError(t, repo.InsertBatch([]*models.BeastMessage{frame("8D4840D6202CC371C32CE0576098", now.Add(time.Second))}))
+
+	records, _, err := repo.QueryHistory(MessageFilter{}, PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "A5", records[0].Category)
+	assert.Empty(t, records[1].Category)
+
+	seen, err := db.SeenAircraftRepository().Get("4840D6")
+	require.NoError(t, err)
+	require.NotNil(t, seen)
+	assert.Equal(t, "A5", seen.Category, "a message without a category keeps the known one")
+}
+
+func TestBeastMessageCommB(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	frame := func(s string) *models.BeastMessage {
+		msg, err := hex.DecodeString(s)
+		require.NoError(t, err)
+		return &models.BeastMessage{Timestamp: time.Now(), MessageTypeCode: models.BeastTypeModeSLong, Message: msg, ICAO: "80C2D4", MessageType: "comm_b"}
+	}
+	repo := db.BeastMessageRepository()
+	require.NoError(t, repo.InsertBatch([]*models.BeastMessage{
+		frame("A000029C85E42F313000007047D3"), // 4,0
+		frame("A00004128F39F91A7E27C46ADC21"), // 6,0
+		frame("A000183880200000000000000000"), // Fits more than one register
+	}))
+
+	records, _, err := repo.QueryHistory(MessageFilter{}, PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, "4,0", records[0].BDS)
+	require.NotNil(t, records[0].SelectedAltitude)
+	assert.Equal(t, 3008, *records[0].SelectedAltitude)
+	assert.Equal(t, "6,0", records[1].BDS)
+	assert.NotNil(t, records[1].Heading)
+	assert.NotNil(t, records[1].Speed)
+	assert.Empty(t, records[2].BDS)
+	assert.Nil(t, records[2].Speed)
+
+	seen, err := db.SeenAircraftRepository().Get("80C2D4")
+	require.NoError(t, err)
+	assert.Nil(t, seen, "the address of a Comm-B reply is recovered from parity")
+}
+
+func TestBeastMessageModeAC(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	repo := db.BeastMessageRepository()
+	require.NoError(t, repo.InsertBatch([]*models.BeastMessage{
+		{Timestamp: time.Now(), MessageTypeCode: models.BeastTypeModeAC, Message: []byte{0x03, 0x20}, MessageType: "mode_ac"},
+		{Timestamp: time.Now(), MessageTypeCode: models.BeastTypeModeAC, Message: []byte{0x77, 0x00}, MessageType: "mode_ac"},
+	}))
+
+	records, _, err := repo.QueryHistory(MessageFilter{MessageType: "mode_ac"}, PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "0320", records[0].Squawk)
+	require.NotNil(t, records[0].Altitude)
+	assert.Equal(t, 1000, *records[0].Altitude, "the code read as a Mode C altitude")
+	assert.Equal(t, "7700", records[1].Squawk)
+	assert.Nil(t, records[1].Altitude, "not a valid altitude code")
 }
 
 func TestInsertBeastMessagesBatch_Empty(t *testing.T) {
@@ -100,3 +530,1186 @@ func TestInsertBeastMessagesBatch_Duplicates(t *testing.T) {
 	err := repo.InsertBatch(msgs)
 	assert.NoError(t, err)
 }
+
+func TestInsertBeastMessagesBatch_UpdatesSeenAircraft(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	repo := db.BeastMessageRepository()
+	now := time.Now()
+
+	msgs := []*models.BeastMessage{
+		{
+			Timestamp:       now,
+			MessageTypeCode: models.BeastTypeModeSLong,
+			Message:         []byte{0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71, 0xC2, 0xD7, 0x20, 0x00, 0x00, 0x00},
+			ICAO:            "4840D6",
+			MessageType:     "extended_squitter",
+		},
+		{
+			Timestamp:       now.Add(time.Second),
+			MessageTypeCode: models.BeastTypeModeSLong,
+			Message:         []byte{0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71, 0xC2, 0xD7, 0x20, 0x00, 0x00, 0x00},
+			ICAO:            "4840D6",
+			MessageType:     "extended_squitter",
+		},
+		{
+			// DF4 surveillance reply: address is overlaid with parity and must not create a sighting
+			Timestamp:       now,
+			MessageTypeCode: models.BeastTypeModeSShort,
+			Message:         []byte{0x20, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+			ICAO:            "001122",
+			MessageType:     "surveillance",
+		},
+	}
+
+	require.NoError(t, repo.InsertBatch(msgs))
+
+	seen, err := db.SeenAircraftRepository().Get("4840D6")
+	require.NoError(t, err)
+	require.NotNil(t, seen)
+	assert.Equal(t, int64(2), seen.MessageCount)
+	assert.Equal(t, "live", seen.Source)
+	assert.WithinDuration(t, now, seen.FirstSeen, time.Millisecond)
+	assert.WithinDuration(t, now.Add(time.Second), seen.LastSeen, time.Millisecond)
+
+	missing, err := db.SeenAircraftRepository().Get("001122")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+func TestBeastMessageTracks(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	repo := db.BeastMessageRepository()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	frame := []byte{0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71, 0xC2, 0xD7, 0x20, 0x00, 0x00, 0x00}
+
+	// Two passes an hour apart, heard every 10 seconds for 5 and 3 minutes
+	var msgs []*models.BeastMessage
+	for _, pass := range []struct {
+		start   time.Time
+		minutes int
+		signal  uint8
+	}{{start, 5, 90}, {start.Add(time.Hour), 3, 140}} {
+		for s := 0; s <= pass.minutes*60; s += 10 {
+			msgs = append(msgs, &models.BeastMessage{
+				Timestamp:       pass.start.Add(time.Duration(s) * time.Second),
+				MessageTypeCode: models.BeastTypeModeSLong,
+				SignalLevel:     pass.signal,
+				Message:         frame,
+				ICAO:            "4840D6",
+				MessageType:     "extended_squitter",
+			})
+		}
+	}
+	require.NoError(t, repo.InsertBatch(msgs))
+
+	history, err := repo.Tracks("4840d6", 30*time.Minute, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, history.Count)
+	assert.InDelta(t, 8*60, history.Seconds, 0.5)
+	require.Len(t, history.Recent, 2)
+
+	latest := history.Recent[0]
+	assert.True(t, latest.Start.Equal(start.Add(time.Hour)), latest.Start)
+	assert.True(t, latest.End.Equal(start.Add(time.Hour+3*time.Minute)), latest.End)
+	assert.Equal(t, int64(19), latest.Messages)
+	assert.Equal(t, 140, latest.MaxSignal)
+	assert.Equal(t, int64(31), history.Recent[1].Messages)
+
+	limited, err := repo.Tracks("4840D6", 30*time.Minute, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, limited.Count, "the count covers tracks beyond the limit")
+	assert.Len(t, limited.Recent, 1)
+
+	none, err := repo.Tracks("ABCDEF", 30*time.Minute, 10)
+	require.NoError(t, err)
+	assert.Zero(t, none.Count)
+	assert.Empty(t, none.Recent)
+}
+
+func TestBeastMessageTrackPositions(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	frame, err := hex.DecodeString("8D40621D58C382D690C8AC2863A7")
+	require.NoError(t, err)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	position := func(at time.Time, lat, lon float64) *models.BeastMessage {
+		m, err := models.NewBeastMessage(models.BeastTypeModeSLong, 0, 0, frame, at)
+		require.NoError(t, err)
+		m.Position = &decoder.Position{Latitude: lat, Longitude: lon}
+		return m
+	}
+	// A minute of positions every two seconds, and another pass an hour later
+	var msgs []*models.BeastMessage
+	for s := 0; s <= 60; s += 2 {
+		msgs = append(msgs, position(start.Add(time.Duration(s)*time.Second), 52+float64(s)/100, 4))
+	}
+	msgs = append(msgs, position(start.Add(time.Hour), 53, 5), position(start.Add(time.Hour+5*time.Second), 53.1, 5))
+	require.NoError(t, db.BeastMessageRepository().InsertBatch(msgs))
+
+	history, err := db.BeastMessageRepository().Tracks("40621D", 30*time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, history.Recent, 2)
+	first := history.Recent[1].Positions
+	require.Len(t, first, 7, "thinned to one every 10 seconds")
+	assert.True(t, first[0].Time.Equal(start), first[0].Time)
+	assert.Equal(t, 52.0, first[0].Latitude)
+	assert.Equal(t, 4.0, first[0].Longitude)
+	require.NotNil(t, first[0].Altitude)
+	assert.Equal(t, 38000, *first[0].Altitude)
+	assert.InDelta(t, 52.6, first[6].Latitude, 1e-9)
+	latest := history.Recent[0].Positions
+	require.Len(t, latest, 1)
+	assert.Equal(t, 53.0, latest[0].Latitude)
+
+	limited, err := db.BeastMessageRepository().Tracks("40621D", 30*time.Minute, 1)
+	require.NoError(t, err)
+	require.Len(t, limited.Recent, 1)
+	assert.Len(t, limited.Recent[0].Positions, 1)
+
+	// Encrypted positions are left out without the key, the tracks are still listed
+	c, err := crypt.NewAES(bytes.Repeat([]byte{1}, crypt.KeySize))
+	require.NoError(t, err)
+	db.SetCipher(c)
+	require.NoError(t, db.BeastMessageRepository().InsertBatch([]*models.BeastMessage{position(start.Add(2*time.Hour), 54, 6)}))
+	history, err = db.BeastMessageRepository().Tracks("40621D", 30*time.Minute, 10)
+	require.NoError(t, err)
+	require.Len(t, history.Recent, 3)
+	require.Len(t, history.Recent[0].Positions, 1)
+	assert.Equal(t, 54.0, history.Recent[0].Positions[0].Latitude)
+	history, err = NewBeastMessageRepository(db.DB()).Tracks("40621D", 30*time.Minute, 10)
+	require.NoError(t, err)
+	assert.Empty(t, history.Recent[0].Positions)
+	assert.Len(t, history.Recent[1].Positions, 1)
+}
+
+func TestSeenAircraftUpsertBatch_Merges(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	repo := db.SeenAircraftRepository()
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, repo.UpsertBatch([]*models.Sighting{
+		{ICAO: "A1B2C3", FirstSeen: base, LastSeen: base.Add(time.Hour), MessageCount: 10, Callsign: "UAL1", Source: "readsb"},
+	}))
+	require.NoError(t, repo.UpsertBatch([]*models.Sighting{
+		{ICAO: "A1B2C3", FirstSeen: base.Add(-time.Hour), LastSeen: base.Add(30 * time.Minute), MessageCount: 5, Callsign: "OLD1", Source: "basestation"},
+	}))
+
+	seen, err := repo.Get("A1B2C3")
+	require.NoError(t, err)
+	require.NotNil(t, seen)
+	assert.Equal(t, int64(15), seen.MessageCount)
+	assert.True(t, seen.FirstSeen.Equal(base.Add(-time.Hour)))
+	assert.True(t, seen.LastSeen.Equal(base.Add(time.Hour)))
+	assert.Equal(t, "UAL1", seen.Callsign, "callsign from an older sighting must not overwrite a newer one")
+}
+
+func TestAircraftMergeCurated_PreferredOverBulk(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	repo := db.AircraftRepository()
+
+	// Curated record arrives first (e.g. BaseStation import before the CSV load)
+	require.NoError(t, repo.MergeCurated([]*models.Aircraft{
+		{ICAO24: "a1b2c3", Registration: "N12345", Owner: "Me"},
+	}))
+
+	populated, err := repo.IsTablePopulated()
+	require.NoError(t, err)
+	assert.False(t, populated, "curated rows alone must not skip the bulk dataset load")
+
+	require.NoError(t, repo.InsertBatch([]*models.Aircraft{
+		{ICAO24: "a1b2c3", Registration: "N99999", Owner: "Bank", Model: "737-824"},
+		{ICAO24: "abcdef", Registration: "G-ABCD"},
+	}))
+
+	ac, err := repo.Get("A1B2C3")
+	require.NoError(t, err)
+	require.NotNil(t, ac)
+	assert.Equal(t, "N12345", ac.Registration)
+	assert.Equal(t, "Me", ac.Owner)
+	assert.Equal(t, "737-824", ac.Model, "bulk data still fills fields the curated record left empty")
+
+	// A later curated merge with empty fields must not blank out existing values
+	require.NoError(t, repo.MergeCurated([]*models.Aircraft{
+		{ICAO24: "abcdef", Owner: "Someone"},
+	}))
+	ac, err = repo.Get("abcdef")
+	require.NoError(t, err)
+	assert.Equal(t, "G-ABCD", ac.Registration)
+	assert.Equal(t, "Someone", ac.Owner)
+
+	missing, err := repo.Get("000000")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+func TestSeenAircraftQueryHistory_Pages(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	repo := db.SeenAircraftRepository()
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// Two aircraft share a last_seen time so paging has to break the tie on icao
+	require.NoError(t, repo.UpsertBatch([]*models.Sighting{
+		{ICAO: "000001", FirstSeen: base, LastSeen: base.Add(1 * time.Minute), MessageCount: 5, Source: "live"},
+		{ICAO: "000002", FirstSeen: base, LastSeen: base.Add(2 * time.Minute), MessageCount: 3, Source: "live"},
+		{ICAO: "000003", FirstSeen: base, LastSeen: base.Add(2 * time.Minute), MessageCount: 9, Source: "readsb"},
+		{ICAO: "000004", FirstSeen: base, LastSeen: base.Add(4 * time.Minute), MessageCount: 1, Source: "live"},
+	}))
+
+	var icaos []string
+	page := PageRequest{Sort: "last_seen", Descending: true, Limit: 2}
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 3, "paging did not terminate")
+		sightings, next, err := repo.QueryHistory(SightingFilter{}, page)
+		require.NoError(t, err)
+		for _, s := range sightings {
+			icaos = append(icaos, s.ICAO)
+		}
+		if next == "" {
+			break
+		}
+		page.Cursor = next
+	}
+	assert.Equal(t, []string{"000004", "000003", "000002", "000001"}, icaos)
+
+	sightings, next, err := repo.QueryHistory(SightingFilter{Source: "live"}, PageRequest{Sort: "message_count"})
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	require.Len(t, sightings, 3)
+	assert.Equal(t, "000004", sightings[0].ICAO)
+
+	_, _, err = repo.QueryHistory(SightingFilter{}, PageRequest{Sort: "callsign"})
+	assert.Error(t, err, "unsortable columns are rejected")
+
+	// A cursor only continues the sort it was issued for
+	_, next, err = repo.QueryHistory(SightingFilter{}, PageRequest{Sort: "icao", Limit: 1})
+	require.NoError(t, err)
+	_, _, err = repo.QueryHistory(SightingFilter{}, PageRequest{Sort: "last_seen", Cursor: next})
+	assert.Error(t, err)
+}
+
+func TestBeastMessageQueryHistory_Pages(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	repo := db.BeastMessageRepository()
+	base := time.Now()
+
+	var msgs []*models.BeastMessage
+	for i := 0; i < 5; i++ {
+		msgs = append(msgs, &models.BeastMessage{
+			Timestamp:   base.Add(time.Duration(i) * time.Second),
+			SignalLevel: uint8(100 + i),
+			Message:     []byte{0x5D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3},
+			ICAO:        "4840D6",
+			MessageType: "all_call_reply",
+		})
+	}
+	require.NoError(t, repo.InsertBatch(msgs))
+
+	var levels []int
+	page := PageRequest{Sort: "timestamp", Descending: true, Limit: 2}
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 4, "paging did not terminate")
+		records, next, err := repo.QueryHistory(MessageFilter{ICAO: "4840D6"}, page)
+		require.NoError(t, err)
+		for _, rec := range records {
+			levels = append(levels, rec.SignalLevel)
+		}
+		if next == "" {
+			break
+		}
+		page.Cursor = next
+	}
+	assert.Equal(t, []int{104, 103, 102, 101, 100}, levels)
+
+	records, _, err := repo.QueryHistory(MessageFilter{ICAO: "000000"}, PageRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	_, _, err = repo.QueryHistory(MessageFilter{}, PageRequest{Cursor: "not-a-cursor"})
+	assert.Error(t, err)
+}
+
+func TestSeenAircraftSummary(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	repo := db.SeenAircraftRepository()
+	midnight := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	require.NoError(t, repo.UpsertBatch([]*models.Sighting{
+		{ICAO: "000001", FirstSeen: midnight.Add(-48 * time.Hour), LastSeen: midnight.Add(-time.Hour), MessageCount: 5, Source: "live"},
+		{ICAO: "000002", FirstSeen: midnight.Add(-48 * time.Hour), LastSeen: midnight.Add(time.Hour), MessageCount: 7, Source: "live"},
+		{ICAO: "000003", FirstSeen: midnight.Add(2 * time.Hour), LastSeen: midnight.Add(3 * time.Hour), MessageCount: 3, Source: "live"},
+	}))
+
+	summary, err := repo.Summary(midnight)
+	require.NoError(t, err)
+	assert.Equal(t, &SightingSummary{Aircraft: 2, NewAircraft: 1, Messages: 10}, summary)
+}
+
+func TestEventRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	repo := db.EventRepository()
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	events := []*models.Event{
+		{Time: base, Type: models.EventNewAircraft, Severity: models.SeverityInfo, ICAO: "3C6586", Message: "First sighting", Data: map[string]any{"country": "Germany"}},
+		{Time: base.Add(time.Hour), Type: models.EventEmergency, Severity: models.SeverityCritical, ICAO: "A1B2C3", Callsign: "UAL1", Message: "Squawk 7700"},
+		{Time: base.Add(2 * time.Hour), Type: models.EventNewAircraft, Severity: models.SeverityInfo, ICAO: "ABCDEF", Message: "First sighting"},
+	}
+	for _, e := range events {
+		require.NoError(t, repo.Insert(e))
+		assert.NotZero(t, e.ID)
+	}
+
+	got, next, err := repo.QueryHistory(EventFilter{Type: models.EventNewAircraft}, PageRequest{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.NotEmpty(t, next)
+	assert.Equal(t, "3C6586", got[0].ICAO)
+	assert.Equal(t, map[string]any{"country": "Germany"}, got[0].Data)
+	assert.True(t, got[0].Time.Equal(base))
+
+	got, next, err = repo.QueryHistory(EventFilter{Type: models.EventNewAircraft}, PageRequest{Limit: 1, Cursor: next})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Empty(t, next)
+	assert.Equal(t, "ABCDEF", got[0].ICAO)
+	assert.Nil(t, got[0].Data)
+
+	got, _, err = repo.QueryHistory(EventFilter{From: base.Add(30 * time.Minute), To: base.Add(90 * time.Minute)}, PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "UAL1", got[0].Callsign)
+
+	got, _, err = repo.QueryHistory(EventFilter{Types: []string{models.EventEmergency, models.EventAlert}}, PageRequest{})
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "A1B2C3", got[0].ICAO)
+}
+
+func TestFleetRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	require.NoError(t, db.AircraftRepository().InsertBatch([]*models.Aircraft{
+		{ICAO24: "a00001", Registration: "N1UA", TypeCode: "B738", Operator: "United Airlines", OperatorICAO: "UAL"},
+		{ICAO24: "a00002", Registration: "N2UA", TypeCode: "B738", Operator: "United Airlines", OperatorICAO: "UAL"},
+		{ICAO24: "a00003", Registration: "N3UA", TypeCode: "A320", Operator: "United Airlines", OperatorICAO: "UAL"},
+		{ICAO24: "a00004", Registration: "N1DL", TypeCode: "A321", Operator: "Delta Air Lines", OperatorICAO: "DAL"},
+	}))
+
+	now := time.Now()
+	require.NoError(t, db.SeenAircraftRepository().UpsertBatch([]*models.Sighting{
+		{ICAO: "A00001", FirstSeen: now, LastSeen: now, MessageCount: 1},
+		{ICAO: "A00002", FirstSeen: now, LastSeen: now, MessageCount: 1},
+		{ICAO: "A00004", FirstSeen: now, LastSeen: now, MessageCount: 1},
+		{ICAO: "4CA123", FirstSeen: now, LastSeen: now, MessageCount: 1},
+	}))
+
+	repo := db.FleetRepository()
+
+	fleets, err := repo.Operators(10)
+	require.NoError(t, err)
+	require.Len(t, fleets, 2)
+	assert.Equal(t, "UAL", fleets[0].OperatorICAO)
+	assert.Equal(t, "United Airlines", fleets[0].Operator)
+	assert.Equal(t, 2, fleets[0].Seen)
+	assert.Equal(t, 3, fleets[0].FleetSize)
+	assert.InDelta(t, 66.7, fleets[0].SeenPercent, 0.1)
+	assert.Equal(t, "DAL", fleets[1].OperatorICAO)
+
+	report, err := repo.Fleet("ual")
+	require.NoError(t, err)
+	require.NotNil(t, report)
+	assert.Equal(t, 3, report.FleetSize)
+	assert.Equal(t, 2, report.Seen)
+	require.Len(t, report.Aircraft, 3)
+	assert.Equal(t, "A00003", report.Aircraft[2].ICAO)
+	assert.False(t, report.Aircraft[2].Seen)
+
+	unknown, err := repo.Fleet("XXX")
+	require.NoError(t, err)
+	assert.Nil(t, unknown)
+
+	blocks, err := repo.Blocks()
+	require.NoError(t, err)
+	require.Len(t, blocks, 2)
+	assert.Equal(t, 3, blocks[0].Seen)
+	assert.Equal(t, 1, blocks[1].Seen)
+}
+
+func TestEnrichmentRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	require.NoError(t, db.AircraftRepository().InsertBatch([]*models.Aircraft{
+		{ICAO24: "a00001", Registration: "N1UA"},
+	}))
+	now := time.Now()
+	require.NoError(t, db.SeenAircraftRepository().UpsertBatch([]*models.Sighting{
+		{ICAO: "A00001", FirstSeen: now, LastSeen: now, MessageCount: 1},
+		{ICAO: "A00002", FirstSeen: now, LastSeen: now, MessageCount: 1},
+		{ICAO: "A00003", FirstSeen: now, LastSeen: now, MessageCount: 1},
+	}))
+
+	repo := db.EnrichmentRepository()
+
+	queued, err := repo.QueueMissing("opensky")
+	require.NoError(t, err)
+	assert.Equal(t, 2, queued, "aircraft already in the table are not queued")
+	queued, err = repo.QueueMissing("opensky")
+	require.NoError(t, err)
+	assert.Equal(t, 0, queued)
+
+	pending, err := repo.Pending(time.Now(), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A00002", "A00003"}, pending)
+
+	require.NoError(t, repo.MarkDone("A00002", EnrichmentNotFound, ""))
+	require.NoError(t, repo.MarkError("A00003", errors.New("timeout"), 2))
+
+	// A failed lookup stays pending, but not within the same pass
+	pending, err = repo.Pending(now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	pending, err = repo.Pending(time.Now().Add(time.Second), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A00003"}, pending)
+
+	require.NoError(t, repo.MarkError("A00003", errors.New("timeout"), 2))
+	counts, err := repo.Counts()
+	require.NoError(t, err)
+	assert.Equal(t, 0, counts[EnrichmentPending])
+	assert.Equal(t, 1, counts[EnrichmentNotFound])
+	assert.Equal(t, 1, counts[EnrichmentFailed])
+
+	// A new resolver set retries everything left unresolved
+	_, err = repo.QueueMissing("basestation,opensky")
+	require.NoError(t, err)
+	counts, err = repo.Counts()
+	require.NoError(t, err)
+	assert.Equal(t, 2, counts[EnrichmentPending])
+
+	// Aircraft that reached the table meanwhile drop out of the queue
+	require.NoError(t, db.AircraftRepository().InsertBatch([]*models.Aircraft{
+		{ICAO24: "a00002", Registration: "N2UA"},
+	}))
+	_, err = repo.QueueMissing("basestation,opensky")
+	require.NoError(t, err)
+	pending, err = repo.Pending(time.Now().Add(time.Second), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A00003"}, pending)
+}
+
+func TestStateSnapshotRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	repo := db.StateSnapshotRepository()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		snapshot := &StateSnapshot{Time: start.Add(time.Duration(i) * 10 * time.Second), Count: i, Aircraft: []byte(`[]`)}
+		require.NoError(t, repo.Insert(snapshot))
+		assert.NotZero(t, snapshot.ID)
+	}
+
+	snapshots, err := repo.Range(start.Add(5*time.Second), start.Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.True(t, snapshots[0].Time.Equal(start.Add(10*time.Second)))
+	assert.Equal(t, 1, snapshots[0].Count)
+	assert.JSONEq(t, `[]`, string(snapshots[0].Aircraft))
+
+	limited, err := repo.Range(start, start.Add(time.Minute), 1)
+	require.NoError(t, err)
+	assert.Len(t, limited, 1)
+
+	deleted, err := repo.DeleteBefore(start.Add(15 * time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+}
+
+func TestStateSnapshotBandCounts(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	repo := db.StateSnapshotRepository()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, aircraft := range []string{
+		`[{"icao":"A00001","altitude_band":"low"},{"icao":"A00002","altitude_band":"high"}]`,
+		`[{"icao":"A00001","altitude_band":"surface"},{"icao":"A00002","altitude_band":"high"},{"icao":"A00003"}]`,
+		`[{"icao":"A00004","altitude_band":"high"}]`,
+	} {
+		require.NoError(t, repo.Insert(&StateSnapshot{Time: start.Add(time.Duration(i) * time.Minute), Aircraft: []byte(aircraft)}))
+	}
+
+	counts, snapshots, err := repo.BandCounts(start, start.Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 2, snapshots)
+	assert.Equal(t, []*BandCount{
+		{Band: "", Aircraft: 1, Samples: 1},
+		{Band: "high", Aircraft: 1, Samples: 2},
+		{Band: "low", Aircraft: 1, Samples: 1},
+		{Band: "surface", Aircraft: 1, Samples: 1},
+	}, counts)
+
+	counts, snapshots, err = repo.BandCounts(start.Add(time.Hour), start.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, snapshots)
+	assert.Empty(t, counts)
+}
+
+func TestStateSnapshotEncryption(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	// Stored before encryption was turned on
+	plain := `[{"icao":"A00001","altitude_band":"low","lat":52.2572,"lon":3.9194}]`
+	require.NoError(t, db.StateSnapshotRepository().Insert(&StateSnapshot{Time: start, Count: 1, Aircraft: []byte(plain)}))
+
+	c, err := crypt.NewAES(bytes.Repeat([]byte{1}, crypt.KeySize))
+	require.NoError(t, err)
+	db.SetCipher(c)
+	repo := db.StateSnapshotRepository()
+	sealed := `[{"icao":"A00001","altitude_band":"high","lat":52.3,"lon":4.1},{"icao":"A00002","altitude_band":"high"}]`
+	require.NoError(t, repo.Insert(&StateSnapshot{Time: start.Add(time.Minute), Count: 2, Aircraft: []byte(sealed)}))
+
+	var raw string
+	require.NoError(t, db.DB().QueryRow(`SELECT aircraft FROM state_snapshots WHERE aircraft_count = 2`).Scan(&raw))
+	assert.True(t, strings.HasPrefix(raw, sealedPrefix), "positions aren't stored in the clear")
+
+	snapshots, err := repo.Range(start, start.Add(time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.JSONEq(t, plain, string(snapshots[0].Aircraft), "rows from before encryption read as they are")
+	assert.JSONEq(t, sealed, string(snapshots[1].Aircraft))
+
+	counts, n, err := repo.BandCounts(start, start.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []*BandCount{
+		{Band: "high", Aircraft: 2, Samples: 2},
+		{Band: "low", Aircraft: 1, Samples: 1},
+	}, counts)
+
+	_, err = NewStateSnapshotRepository(db.DB()).Range(start, start.Add(time.Hour), 10)
+	assert.ErrorIs(t, err, ErrEncrypted)
+}
+
+func TestHubStationRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	repo := db.HubStationRepository()
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.Create(&HubStation{Name: "north", KeyHash: "abc", RateLimit: 50, CreatedAt: created}))
+	require.NoError(t, repo.Create(&HubStation{Name: "south", KeyHash: "def", CreatedAt: created}))
+	assert.ErrorIs(t, repo.Create(&HubStation{Name: "north", KeyHash: "ghi", CreatedAt: created}), ErrStationExists)
+
+	station, err := repo.Get("north")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", station.KeyHash, "registering a name again keeps the original key")
+	assert.Equal(t, 50.0, station.RateLimit)
+	assert.Nil(t, station.RevokedAt)
+
+	revoked, err := repo.Revoke("north", created.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = repo.Revoke("north", created.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.False(t, revoked, "already revoked")
+
+	stations, err := repo.List()
+	require.NoError(t, err)
+	require.Len(t, stations, 2)
+	require.NotNil(t, stations[0].RevokedAt)
+	assert.True(t, stations[0].RevokedAt.Equal(created.Add(time.Hour)))
+	assert.Nil(t, stations[1].RevokedAt)
+
+	missing, err := repo.Get("west")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+func TestReceiverStatsRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	repo := db.ReceiverStatsRepository()
+	hour := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.Add(&ReceiverHour{Hour: hour.Add(5 * time.Minute), Messages: 1000, ParseErrors: 3}))
+	require.NoError(t, repo.Add(&ReceiverHour{Hour: hour.Add(50 * time.Minute), Messages: 500, Reconnects: 1}))
+	require.NoError(t, repo.Add(&ReceiverHour{Hour: hour.Add(time.Hour), Messages: 200}))
+
+	got, err := repo.Get(hour.Add(30 * time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, &ReceiverHour{Hour: hour, Messages: 1500, ParseErrors: 3, Reconnects: 1}, got, "counts within an hour add up")
+
+	missing, err := repo.Get(hour.Add(-24 * time.Hour))
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	hours, err := repo.List(hour.Add(30 * time.Minute))
+	require.NoError(t, err)
+	require.Len(t, hours, 2)
+	assert.Equal(t, int64(200), hours[1].Messages)
+}
+
+func TestConnectionRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	repo := db.ConnectionRepository()
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	for _, e := range []*ConnectionEvent{
+		{Input: "pi:30005", Type: ConnectionUp, Time: at(10), Duration: 600}, // Took 10 minutes to connect after starting
+		{Input: "pi:30005", Type: ConnectionDown, Time: at(40), Reason: "connection closed", Duration: 1800},
+		{Input: "pi:30005", Type: ConnectionUp, Time: at(50), Duration: 600},
+		{Input: "pi:30005", Type: ConnectionDown, Time: at(60), Reason: ReasonShutdown, Duration: 600},
+		{Input: "pi:30005", Type: ConnectionUp, Time: at(90), Duration: 1}, // Restarted, the 30 minutes stopped don't count
+		{Input: "other:30005", Type: ConnectionUp, Time: at(0)},
+	} {
+		require.NoError(t, repo.Insert(e))
+	}
+
+	uptimes, err := repo.Uptime(start, at(100))
+	require.NoError(t, err)
+	require.Len(t, uptimes, 2)
+	other, pi := uptimes[0], uptimes[1]
+	assert.Equal(t, "pi:30005", pi.Input)
+	assert.True(t, pi.Connected)
+	assert.InDelta(t, 70*60, pi.Tracked, 1, "0-60 and 90-100")
+	assert.InDelta(t, 50*60, pi.Up, 1, "10-40, 50-60 and 90-100")
+	assert.InDelta(t, 100*50/70.0, pi.UptimePercent, 0.1)
+	assert.Equal(t, 1, pi.Disconnects, "the shutdown isn't a lost connection")
+	assert.InDelta(t, 600, pi.LongestOutage, 1)
+	assert.InDelta(t, 100, other.UptimePercent, 0.1)
+
+	// A period starting mid-outage starts down
+	uptimes, err = repo.Uptime(at(45), at(55))
+	require.NoError(t, err)
+	assert.InDelta(t, 50, uptimes[1].UptimePercent, 0.1)
+	assert.InDelta(t, 300, uptimes[1].LongestOutage, 1)
+
+	events, err := repo.List(at(50), 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, at(90), events[0].Time.UTC(), "newest first")
+	assert.Equal(t, ReasonShutdown, events[1].Reason)
+}
+
+func TestTagRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	repo := db.TagRepository()
+	require.NoError(t, repo.ReplaceSource("plane-alert", []*models.AircraftTag{
+		{ICAO: "ae1234", Registration: "12-3456", Category: "USAF", Tags: []string{"Moose", "Heavy"}},
+		{ICAO: "A0B1C2", Registration: "N1234", Category: "Celebrity"},
+	}))
+	require.NoError(t, repo.ReplaceSource("local", []*models.AircraftTag{
+		{ICAO: "AE1234", Category: "Watched"},
+	}))
+
+	tags, err := repo.Get("ae1234")
+	require.NoError(t, err)
+	require.Len(t, tags, 2)
+	assert.Equal(t, "local", tags[0].Source)
+	assert.Equal(t, "plane-alert", tags[1].Source)
+	assert.Equal(t, []string{"Moose", "Heavy"}, tags[1].Tags)
+
+	// Re-importing replaces the whole list, dropping aircraft no longer on it
+	require.NoError(t, repo.ReplaceSource("plane-alert", []*models.AircraftTag{
+		{ICAO: "AE1234", Category: "USAF"},
+	}))
+	tags, err = repo.Get("A0B1C2")
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+
+	sources, err := repo.Sources()
+	require.NoError(t, err)
+	require.Len(t, sources, 2)
+	assert.Equal(t, "local", sources[0].Name)
+	assert.Equal(t, 1, sources[1].Aircraft)
+	assert.WithinDuration(t, time.Now(), sources[1].UpdatedAt, time.Minute)
+}
+
+func TestAircraftLoadFromMultipleCSV(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	// Enough rows to span several parse chunks and transactions
+	dir := t.TempDir()
+	var part1, part2 strings.Builder
+	part1.WriteString("'icao24','registration','operatorIcao'\n")
+	part2.WriteString("'icao24','registration','operatorIcao'\n")
+	for i := 0; i < 2500; i++ {
+		fmt.Fprintf(&part1, "'%06x','N%d','UAL'\n", i, i)
+	}
+	for i := 2500; i < 3000; i++ {
+		fmt.Fprintf(&part2, "'%06x','N%d','DAL'\n", i, i)
+	}
+	part2.WriteString("'','NOICAO','DAL'\n")         // No address, skipped
+	part2.WriteString("'abcdef','TOO','MANY','X'\n") // Wrong field count, skipped
+
+	paths := []string{filepath.Join(dir, "part1.csv"), filepath.Join(dir, "part2.csv")}
+	require.NoError(t, os.WriteFile(paths[0], []byte(part1.String()), 0o644))
+	require.NoError(t, os.WriteFile(paths[1], []byte(part2.String()), 0o644))
+
+	repo := db.AircraftRepository()
+	require.NoError(t, repo.LoadFromMultipleCSV(paths, nil, 700))
+
+	var count int
+	require.NoError(t, db.db.QueryRow("SELECT COUNT(*) FROM aircraft").Scan(&count))
+	assert.Equal(t, 3000, count)
+
+	ac, err := repo.Get("000bb7") // 2999, the last row of the second file
+	require.NoError(t, err)
+	require.NotNil(t, ac)
+	assert.Equal(t, "N2999", ac.Registration)
+	assert.Equal(t, "DAL", ac.OperatorICAO)
+
+	missing, err := repo.Get("abcdef")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	err = repo.LoadFromMultipleCSV([]string{filepath.Join(dir, "missing.csv")}, nil, 700)
+	assert.Error(t, err)
+}
+
+func TestAircraftLoadFromMultipleCSV_Compressed(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	dir := t.TempDir()
+	header := "'icao24','registration'\n"
+
+	gzPath := filepath.Join(dir, "part1.csv.gz")
+	f, err := os.Create(gzPath)
+	require.NoError(t, err)
+	gz := gzip.NewWriter(f)
+	_, err = gz.Write([]byte(header + "'a00001','N1'\n"))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.NoError(t, f.Close())
+
+	zipPath := filepath.Join(dir, "part2.zip")
+	f, err = os.Create(zipPath)
+	require.NoError(t, err)
+	zw := zip.NewWriter(f)
+	for name, body := range map[string]string{
+		"b.csv":      header + "'a00003','N3'\n",
+		"a.csv":      header + "'a00002','N2'\n",
+		"README.txt": "not a dataset",
+	} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(body))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+
+	repo := db.AircraftRepository()
+	require.NoError(t, repo.LoadFromMultipleCSV([]string{gzPath, zipPath}, nil, 100))
+
+	for icao, reg := range map[string]string{"a00001": "N1", "a00002": "N2", "a00003": "N3"} {
+		ac, err := repo.Get(icao)
+		require.NoError(t, err)
+		require.NotNil(t, ac, icao)
+		assert.Equal(t, reg, ac.Registration)
+	}
+}
+
+func TestAircraftLoadFromMultipleCSV_ColumnMapping(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	_, err := DatasetMapping("unknown", nil)
+	assert.Error(t, err)
+	_, err = DatasetMapping("opensky", map[string]string{"tailNumber": "Reg"})
+	assert.Error(t, err, "mapped columns must exist in the aircraft table")
+
+	// Config keys arrive lowercased, they still match the camelCase columns
+	mapping, err := DatasetMapping("opensky", map[string]string{"icao24": "Hex", "registration": "Reg", "typecode": "ICAO Type", "operatoricao": "Airline"})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "custom.csv")
+	require.NoError(t, os.WriteFile(path, []byte("Hex,Reg,ICAO Type,AIRLINE,Model\nA1B2C3,N123,B738,UAL,737-824\n"), 0o644))
+
+	repo := db.AircraftRepository()
+	require.NoError(t, repo.LoadFromMultipleCSV([]string{path}, mapping, 100))
+
+	ac, err := repo.Get("A1B2C3")
+	require.NoError(t, err)
+	require.NotNil(t, ac, "addresses are stored lowercase whatever the dataset uses")
+	assert.Equal(t, "N123", ac.Registration)
+	assert.Equal(t, "B738", ac.TypeCode)
+	assert.Equal(t, "UAL", ac.OperatorICAO, "headers match case-insensitively")
+	assert.Equal(t, "737-824", ac.Model, "unmapped columns use their own name")
+
+	legacy, err := DatasetMapping("opensky-legacy", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "icaoaircrafttype", legacy["icaoAircraftClass"])
+
+	err = repo.LoadFromMultipleCSV([]string{path}, ColumnMapping{"icao24": "mode_s"}, 100)
+	assert.ErrorContains(t, err, "mode_s")
+}
+
+func TestRecordRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	repo := db.RecordRepository()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	offer := func(category, kind string, value int, icao string) bool {
+		t.Helper()
+		set, err := repo.Offer(&StationRecord{Category: category, Kind: kind, Value: value, ICAO: icao, Time: now})
+		require.NoError(t, err)
+		return set
+	}
+
+	assert.True(t, offer("A3", RecordHighest, 38000, "A00001"), "the first value is a record")
+	assert.False(t, offer("A3", RecordHighest, 37000, "A00002"))
+	assert.False(t, offer("A3", RecordHighest, 38000, "A00002"), "a tie keeps the first aircraft")
+	assert.True(t, offer("A3", RecordHighest, 41000, "A00003"))
+	assert.True(t, offer("A3", RecordSlowest, 140, "A00001"))
+	assert.False(t, offer("A3", RecordSlowest, 150, "A00002"))
+	assert.True(t, offer("A3", RecordSlowest, 120, "A00002"))
+	assert.True(t, offer(RecordAllCategories, RecordFastest, 560, "A00004"))
+
+	records, err := repo.List()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, RecordAllCategories, records[0].Category, "records across categories come first")
+	assert.Equal(t, &StationRecord{Category: "A3", Kind: RecordHighest, Value: 41000, ICAO: "A00003", Time: now}, records[1])
+	assert.Equal(t, "A00002", records[2].ICAO)
+	assert.Equal(t, 120, records[2].Value)
+}
+
+func TestTaskRunRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	repo := db.TaskRunRepository()
+	start := time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC)
+	day := func(n int) time.Time { return start.AddDate(0, 0, n) }
+	for _, run := range []*TaskRun{
+		{Task: "vacuum", Trigger: "interval", Started: day(-40), Finished: day(-40).Add(time.Minute)},
+		{Task: "vacuum", Trigger: "interval", Started: day(0), Finished: day(0).Add(30 * time.Second)},
+		{Task: "vacuum", Trigger: "interval", Started: day(1), Finished: day(1).Add(90 * time.Second), Error: "database is locked"},
+		{Task: "vacuum", Trigger: "on_demand", Started: day(2), Finished: day(2).Add(60 * time.Second)},
+		{Task: "tags", Trigger: "startup", Started: day(1), Finished: day(1).Add(5 * time.Second)},
+	} {
+		require.NoError(t, repo.Insert(run))
+		assert.NotZero(t, run.ID)
+	}
+
+	summaries, err := repo.Summary(day(-30))
+	require.NoError(t, err)
+	require.Len(t, summaries, 2)
+	tags, vacuum := summaries[0], summaries[1]
+	assert.Equal(t, "tags", tags.Task)
+	assert.Equal(t, 100.0, tags.SuccessPercent)
+	assert.Nil(t, tags.LastFailure)
+	assert.Equal(t, 3, vacuum.Runs, "the run before the period isn't counted")
+	assert.Equal(t, 1, vacuum.Failures)
+	assert.Equal(t, 66.7, vacuum.SuccessPercent)
+	assert.Equal(t, 60.0, vacuum.AvgDuration)
+	assert.True(t, vacuum.LastSuccess.Equal(day(2)))
+	assert.True(t, vacuum.LastFailure.Equal(day(1)))
+	assert.Equal(t, "database is locked", vacuum.LastError)
+
+	runs, err := repo.List("vacuum", day(-30), 2)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, "on_demand", runs[0].Trigger, "newest first")
+	assert.Equal(t, 60.0, runs[0].Duration)
+	assert.Equal(t, "database is locked", runs[1].Error)
+
+	runs, err = repo.List("", day(-30), 10)
+	require.NoError(t, err)
+	assert.Len(t, runs, 4)
+}
+
+func TestAdvisoryRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	repo := db.AdvisoryRepository()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	altitude, threatAltitude := 12000, 12300
+	for _, a := range []*Advisory{
+		{Time: start, ICAO: "A05F21", Callsign: "UAL123", Altitude: &altitude, ARA: 0b11000010000000, Summary: "Climb, corrective",
+			ThreatICAO: "4840D6", ThreatCallsign: "KLM605", ThreatAltitude: &threatAltitude},
+		{Time: start.Add(8 * time.Second), ICAO: "A05F21", Callsign: "UAL123", ARA: 0, Summary: "Clear of conflict", Terminated: true},
+		{Time: start.Add(time.Second), ICAO: "4840D6", ARA: 0b10100000000000, Summary: "Limit climb, preventive", MultipleThreats: true},
+	} {
+		require.NoError(t, repo.Insert(a))
+		assert.NotZero(t, a.ID)
+	}
+
+	advisories, err := repo.List("A05F21", start, 10)
+	require.NoError(t, err)
+	require.Len(t, advisories, 2)
+	assert.Equal(t, "Clear of conflict", advisories[0].Summary, "newest first")
+	assert.True(t, advisories[0].Terminated)
+	assert.Nil(t, advisories[0].Altitude)
+	require.NotNil(t, advisories[1].Altitude)
+	assert.Equal(t, 12000, *advisories[1].Altitude)
+	assert.Equal(t, "4840D6", advisories[1].ThreatICAO)
+	assert.Equal(t, "KLM605", advisories[1].ThreatCallsign)
+	require.NotNil(t, advisories[1].ThreatAltitude)
+	assert.Equal(t, 12300, *advisories[1].ThreatAltitude)
+	assert.Nil(t, advisories[0].ThreatAltitude)
+
+	advisories, err = repo.List("4840D6", start, 10)
+	require.NoError(t, err)
+	require.Len(t, advisories, 2, "its own advisory and the one it was the threat in")
+	assert.Equal(t, "4840D6", advisories[0].ICAO)
+	assert.Equal(t, "A05F21", advisories[1].ICAO)
+
+	advisories, err = repo.List("", start.Add(time.Second), 10)
+	require.NoError(t, err)
+	require.Len(t, advisories, 2)
+	assert.True(t, advisories[1].MultipleThreats)
+}
+
+func TestFlightRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	repo := db.FlightRepository()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, f := range []*Flight{
+		{ICAO: "A05F21", Callsign: "UAL123", Category: "A3", FirstSeen: start, LastSeen: start.Add(20 * time.Minute),
+			Messages: 4800, Quality: 87, Gaps: 2, LongestGap: 12.5, Positions: 900, PositionRate: 0.75, SignalMean: -8.5, SignalStdDev: 2.1,
+			MaxRange: 212.5},
+		{ICAO: "4840D6", FirstSeen: start, LastSeen: start.Add(5 * time.Minute), Messages: 40, Quality: 12},
+		{ICAO: "A05F21", FirstSeen: start.Add(-2 * time.Hour), LastSeen: start.Add(-time.Hour), Messages: 10, Quality: 55},
+	} {
+		require.NoError(t, repo.Insert(f))
+		assert.NotZero(t, f.ID)
+	}
+
+	flights, err := repo.List(FlightFilter{Since: start}, 10)
+	require.NoError(t, err)
+	require.Len(t, flights, 2)
+	assert.Equal(t, "A05F21", flights[0].ICAO)
+	assert.Equal(t, "UAL123", flights[0].Callsign)
+	assert.Equal(t, 87, flights[0].Quality)
+	assert.Equal(t, 12.5, flights[0].LongestGap)
+	assert.Equal(t, int64(900), flights[0].Positions)
+	assert.Equal(t, 2.1, flights[0].SignalStdDev)
+	assert.Equal(t, "4840D6", flights[1].ICAO)
+
+	flights, err = repo.List(FlightFilter{MinQuality: 50}, 10)
+	require.NoError(t, err)
+	assert.Len(t, flights, 2, "the poorly received flight is left out")
+
+	flights, err = repo.List(FlightFilter{ICAO: "A05F21"}, 1)
+	require.NoError(t, err)
+	require.Len(t, flights, 1)
+	assert.Equal(t, 87, flights[0].Quality)
+	assert.Equal(t, 212.5, flights[0].MaxRange)
+
+	summary, err := repo.Summary(start.Add(-3*time.Hour), start.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, &FlightSummary{Flights: 3, Aircraft: 2, MaxRange: 212.5, MeanQuality: 51.333333333333336}, summary)
+
+	summary, err = repo.Summary(start, start.Add(10*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, &FlightSummary{Flights: 1, Aircraft: 1, MeanQuality: 12}, summary, "flights count when they end")
+}
+
+func TestFlightRepository_SightingLog(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	require.NoError(t, db.AircraftRepository().InsertBatch([]*models.Aircraft{{ICAO24: "4840d6", Registration: "PH-BXA",
+		ManufacturerName: "Boeing", Model: "737-8K2", TypeCode: "B738", Operator: "KLM"}}))
+	repo := db.FlightRepository()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, f := range []*Flight{
+		{ICAO: "A05F21", Callsign: "UAL123", FirstSeen: start.Add(time.Hour), LastSeen: start.Add(2 * time.Hour)},
+		{ICAO: "4840D6", Callsign: "KLM1023", FirstSeen: start, LastSeen: start.Add(5 * time.Minute)},
+		{ICAO: "4840D6", FirstSeen: start.Add(-time.Hour), LastSeen: start.Add(-time.Minute)},
+	} {
+		require.NoError(t, repo.Insert(f))
+	}
+
+	entries, err := repo.SightingLog(start, start.Add(24*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, entries, 2, "flights that started before the range are left out")
+	assert.Equal(t, "4840D6", entries[0].ICAO)
+	assert.Equal(t, "KLM1023", entries[0].Callsign)
+	assert.Equal(t, "PH-BXA", entries[0].Registration)
+	assert.Equal(t, "Boeing 737-8K2", entries[0].Type)
+	assert.Equal(t, "B738", entries[0].TypeCode)
+	assert.Equal(t, "KLM", entries[0].Operator)
+	assert.True(t, start.Equal(entries[0].FirstSeen))
+	assert.Equal(t, "A05F21", entries[1].ICAO)
+	assert.Empty(t, entries[1].Registration, "an aircraft the registry doesn't know is still logged")
+
+	entries, err = repo.SightingLog(start.Add(3*time.Hour), start.Add(4*time.Hour))
+	require.NoError(t, err)
+	assert.NotNil(t, entries)
+	assert.Empty(t, entries)
+}
+
+func TestMigrateFlightSignals(t *testing.T) {
+	path := testDBPath(t)
+	os.Remove(path)
+	db, err := New(path)
+	require.NoError(t, err)
+	// Stored before signal levels were in dBFS, and after
+	_, err = db.DB().Exec(`INSERT INTO flights (icao, first_seen, last_seen, messages, quality, signal_mean, signal_stddev)
+		VALUES ('4840D6', ?, ?, 10, 50, 128, 12.8), ('A05F21', ?, ?, 10, 50, -8.5, 2.1)`, time.Now(), time.Now(), time.Now(), time.Now())
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	db, err = New(path)
+	require.NoError(t, err)
+	defer cleanupTestDB(t, db)
+	for _, tc := range []struct {
+		icao         string
+		mean, stddev float64
+	}{{"4840D6", -6.0, 0.9}, {"A05F21", -8.5, 2.1}} {
+		flights, err := db.FlightRepository().List(FlightFilter{ICAO: tc.icao}, 1)
+		require.NoError(t, err)
+		require.Len(t, flights, 1)
+		assert.Equal(t, tc.mean, flights[0].SignalMean, tc.icao)
+		assert.Equal(t, tc.stddev, flights[0].SignalStdDev, tc.icao)
+	}
+}
+
+func TestMigrateMessageAddresses(t *testing.T) {
+	path := testDBPath(t)
+	os.Remove(path)
+	db, err := New(path)
+	require.NoError(t, err)
+	// Stored before the address was read from bytes 1-3 and the downlink format was kept, then while bytes 1-3
+	// were read from every format, and after
+	_, err = db.DB().Exec(`INSERT INTO beast_messages (timestamp, icao, message_type, message_hex, downlink_format, type_code) VALUES
+		(?, '054840', 'extended_squitter', '8d4840d6202cc371c32ce0576098', NULL, NULL),
+		(?, '054840', 'surveillance', '5d4840d6000000', NULL, NULL),
+		(?, '054840', 'surveillance', '', NULL, NULL),
+		(?, '000000', 'surveillance', '20000c38812d8d', NULL, NULL),
+		(?, '000C38', 'surveillance', '20000c38812d8d', 4, -1),
+		(?, '4840D6', 'extended_squitter', '984840d600000000000000000000', 19, -1),
+		(?, 'ABCDEF', 'extended_squitter', '8d4840d6202cc371c32ce0576098', 17, 4),
+		(?, '4840D6', 'surveillance', '20000c38812d8d', 4, -1)`,
+		time.Now(), time.Now(), time.Now(), time.Now(), time.Now(), time.Now(), time.Now(), time.Now())
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	db, err = New(path)
+	require.NoError(t, err)
+	defer cleanupTestDB(t, db)
+	rows, err := db.DB().Query(`SELECT icao, downlink_format, type_code FROM beast_messages ORDER BY id`)
+	require.NoError(t, err)
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var icao string
+		var df, tc sql.NullInt64
+		require.NoError(t, rows.Scan(&icao, &df, &tc))
+		got = append(got, fmt.Sprintf("%s %v %v", icao, df, tc))
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{
+		"4840D6 {17 true} {4 true}",
+		"4840D6 {11 true} {-1 true}",
+		"054840 {0 false} {0 false}", // The frame wasn't kept
+		"4840D6 {4 true} {-1 true}",  // Recovered from the parity of an altitude reply
+		"4840D6 {4 true} {-1 true}",
+		" {19 true} {-1 true}",      // A military squitter carries no address in bytes 1-3
+		"ABCDEF {17 true} {4 true}", // Already stored with the address of its frame
+		"4840D6 {4 true} {-1 true}",
+	}, got)
+}
+
+func TestCoverageRepository(t *testing.T) {
+	db := setupTestDB(t)
+	defer cleanupTestDB(t, db)
+
+	repo := db.CoverageRepository()
+	now := time.Now()
+	old := &CoverageCheck{Time: now.Add(-2 * time.Hour), Source: "adsbexchange.com", Expected: 3, Seen: 1}
+	require.NoError(t, repo.Insert(old))
+	check := &CoverageCheck{Time: now, Source: "adsbexchange.com", Expected: 5, Seen: 4, Sectors: []CoverageSector{
+		{Bearing: 0, Expected: 3, Seen: 3}, {Bearing: 180, Expected: 2, Seen: 1},
+	}}
+	require.NoError(t, repo.Insert(check))
+	assert.NotZero(t, check.ID)
+
+	checks, err := repo.List(now.Add(-time.Hour))
+	require.NoError(t, err)
+	require.Len(t, checks, 1)
+	assert.Equal(t, 4, checks[0].Seen)
+	assert.Equal(t, "adsbexchange.com", checks[0].Source)
+	assert.Equal(t, check.Sectors, checks[0].Sectors)
+
+	checks, err = repo.List(now.Add(-3 * time.Hour))
+	require.NoError(t, err)
+	require.Len(t, checks, 2)
+	assert.Empty(t, checks[0].Sectors)
+}
+
+func TestSyncApply(t *testing.T) {
+	source := setupTestDB(t)
+	defer cleanupTestDB(t, source)
+	remote, err := New(filepath.Join(t.TempDir(), "remote.db"))
+	require.NoError(t, err)
+	defer remote.Close()
+
+	at := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	for _, icao := range []string{"A1B2C3", "ABCDEF", "4840D6"} {
+		require.NoError(t, source.EventRepository().Insert(&models.Event{Time: at, Type: "alert", Severity: "info", ICAO: icao, Message: "seen"}))
+	}
+
+	batch, err := source.SyncRepository().Read("events", 0, 2)
+	require.NoError(t, err)
+	require.Len(t, batch.Rows, 2)
+	assert.Equal(t, "id", batch.Columns[0])
+	assert.Equal(t, int64(2), batch.Last())
+
+	// A column the remote doesn't have is dropped, and a batch sent again adds nothing
+	batch.Columns = append(batch.Columns, "station")
+	for i := range batch.Rows {
+		batch.Rows[i] = append(batch.Rows[i], "north")
+	}
+	for i := 0; i < 2; i++ {
+		last, err := remote.SyncRepository().Apply("pi", batch)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), last)
+	}
+	cursors, err := remote.SyncRepository().Cursors("pi")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"events": 2}, cursors)
+
+	events, _, err := remote.EventRepository().QueryHistory(EventFilter{}, PageRequest{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.True(t, events[0].Time.Equal(at), "timestamps keep their stored form")
+
+	_, err = source.SyncRepository().Read("aircraft", 0, 10)
+	assert.ErrorContains(t, err, "can't be synced")
+}
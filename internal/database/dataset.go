@@ -0,0 +1,90 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ColumnMapping maps aircraft table columns to the header a dataset uses for them
+// Columns left out use their own name. Headers match case-insensitively, ignoring surrounding quotes.
+type ColumnMapping map[string]string
+
+// datasetMappings are the column mappings of known aircraft datasets
+var datasetMappings = map[string]ColumnMapping{
+	// OpenSky aircraft-database-complete (2024 onwards), the dataset shipped in internal/database/datasets
+	"opensky": {},
+	// OpenSky aircraftDatabase.csv, published until 2023 with lowercase headers
+	"opensky-legacy": {
+		"icaoAircraftClass": "icaoaircrafttype",
+	},
+}
+
+// DatasetMapping returns the mapping of a known dataset with overrides applied on top
+func DatasetMapping(dataset string, overrides map[string]string) (ColumnMapping, error) {
+	preset, ok := datasetMappings[dataset]
+	if !ok {
+		names := make([]string, 0, len(datasetMappings))
+		for name := range datasetMappings {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown dataset %q (known datasets: %s)", dataset, strings.Join(names, ", "))
+	}
+
+	mapping := make(ColumnMapping, len(preset)+len(overrides))
+	for column, header := range preset {
+		mapping[column] = header
+	}
+	for name, header := range overrides {
+		column, ok := aircraftColumn(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown aircraft column %q in column mapping", name)
+		}
+		mapping[column] = header
+	}
+	return mapping, nil
+}
+
+// columnIndexes finds the position of each aircraft column in a dataset header
+// Columns the dataset doesn't have are left out, except icao24 which every dataset must provide.
+func (m ColumnMapping) columnIndexes(header []string) (map[string]int, error) {
+	positions := make(map[string]int, len(header))
+	for i, h := range header {
+		positions[normalizeHeader(h)] = i
+	}
+
+	indexes := make(map[string]int, len(aircraftColumns))
+	for _, column := range aircraftColumns {
+		name := column
+		if mapped, ok := m[column]; ok {
+			name = mapped
+		}
+		if i, ok := positions[normalizeHeader(name)]; ok {
+			indexes[column] = i
+		}
+	}
+
+	if _, ok := indexes["icao24"]; !ok {
+		name := "icao24"
+		if mapped, ok := m["icao24"]; ok {
+			name = mapped
+		}
+		return nil, fmt.Errorf("dataset header has no %q column for icao24", name)
+	}
+	return indexes, nil
+}
+
+func normalizeHeader(h string) string {
+	return strings.ToLower(strings.Trim(strings.TrimSpace(h), "'\""))
+}
+
+// aircraftColumn returns the aircraft column matching name case-insensitively, config keys arrive lowercased
+func aircraftColumn(name string) (string, bool) {
+	for _, c := range aircraftColumns {
+		if strings.EqualFold(c, name) {
+			return c, true
+		}
+	}
+	return "", false
+}
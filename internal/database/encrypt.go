@@ -0,0 +1,79 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"flight_trmnl/internal/crypt"
+)
+
+// sealedPrefix marks an encrypted column value; values without it were stored in the clear, before encryption
+// was turned on, and are read as they are
+const sealedPrefix = "enc1:"
+
+// ErrEncrypted is returned when reading encrypted values without the key
+var ErrEncrypted = errors.New("the value is encrypted and no encryption key is configured")
+
+// SetCipher encrypts the sensitive columns written by repositories created afterwards, and decrypts them on read
+func (d *DB) SetCipher(c crypt.Cipher) {
+	d.cipher = c
+}
+
+// seal encrypts a column value; empty values and values written without a cipher are stored as they are
+func seal(c crypt.Cipher, value string) (string, error) {
+	if c == nil || value == "" {
+		return value, nil
+	}
+	sealed, err := c.Encrypt([]byte(value))
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt: %w", err)
+	}
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// unseal decrypts a column value written by seal
+func unseal(c crypt.Cipher, value string) (string, error) {
+	if !strings.HasPrefix(value, sealedPrefix) {
+		return value, nil
+	}
+	if c == nil {
+		return "", ErrEncrypted
+	}
+	sealed, err := base64.StdEncoding.DecodeString(value[len(sealedPrefix):])
+	if err != nil {
+		return "", crypt.ErrDecrypt
+	}
+	plaintext, err := c.Decrypt(sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// sealFloat encrypts a nullable number; without a cipher it stays a number
+func sealFloat(c crypt.Cipher, value *float64) (any, error) {
+	if c == nil || value == nil {
+		return value, nil
+	}
+	return seal(c, strconv.FormatFloat(*value, 'f', -1, 64))
+}
+
+// unsealFloat reads a nullable number written by sealFloat, scanned as a string
+func unsealFloat(c crypt.Cipher, value sql.NullString) (*float64, error) {
+	if !value.Valid {
+		return nil, nil
+	}
+	plaintext, err := unseal(c, value.String)
+	if err != nil {
+		return nil, err
+	}
+	f, err := strconv.ParseFloat(plaintext, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stored number %q: %w", plaintext, err)
+	}
+	return &f, nil
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Enrichment queue states
+const (
+	EnrichmentPending  = "pending"   // Waiting for a lookup
+	EnrichmentResolved = "resolved"  // Found and stored in the aircraft table
+	EnrichmentNotFound = "not_found" // No configured resolver knows the aircraft
+	EnrichmentFailed   = "failed"    // Lookups failed max attempts times, e.g. the API was down
+)
+
+type EnrichmentRepository interface {
+	QueueMissing(resolvers string) (int, error)
+	Pending(notTriedSince time.Time, limit int) ([]string, error)
+	MarkDone(icao, status, resolver string) error
+	MarkError(icao string, lookupErr error, maxAttempts int) error
+	Counts() (map[string]int, error)
+}
+
+type enrichmentRepository struct {
+	db *sql.DB
+}
+
+func NewEnrichmentRepository(db *sql.DB) EnrichmentRepository {
+	return &enrichmentRepository{db: db}
+}
+
+// QueueMissing queues seen aircraft that are absent from the aircraft table, returning how many were added
+// It scans seen_aircraft rather than beast_messages: the summary only holds addresses from DF11/DF17,
+// while raw messages include phantom addresses recovered from the parity of other formats.
+// resolvers identifies the configured resolver set; unresolved entries tried with a different set are retried.
+func (r *enrichmentRepository) QueueMissing(resolvers string) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE enrichment_queue SET status = ?, attempts = 0, last_error = ''
+		WHERE status IN (?, ?) AND resolvers != ?`,
+		EnrichmentPending, EnrichmentNotFound, EnrichmentFailed, resolvers)
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue enrichment entries: %w", err)
+	}
+
+	result, err := tx.Exec(`INSERT OR IGNORE INTO enrichment_queue (icao, status, queued_at, resolvers)
+		SELECT s.icao, ?, ?, ? FROM seen_aircraft s
+		WHERE NOT EXISTS (SELECT 1 FROM aircraft a WHERE a.icao24 = lower(s.icao))`,
+		EnrichmentPending, time.Now().UTC(), resolvers)
+	if err != nil {
+		return 0, fmt.Errorf("failed to queue missing aircraft: %w", err)
+	}
+	queued, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count queued aircraft: %w", err)
+	}
+
+	// Aircraft added to the table since they were queued (e.g. by a dataset reload) need no lookup
+	_, err = tx.Exec(`DELETE FROM enrichment_queue WHERE status = ?
+		AND EXISTS (SELECT 1 FROM aircraft a WHERE a.icao24 = lower(enrichment_queue.icao))`, EnrichmentPending)
+	if err != nil {
+		return 0, fmt.Errorf("failed to drop known aircraft from the enrichment queue: %w", err)
+	}
+
+	// Later requeues compare against the set the entry was last tried with
+	if _, err := tx.Exec(`UPDATE enrichment_queue SET resolvers = ? WHERE status = ?`, resolvers, EnrichmentPending); err != nil {
+		return 0, fmt.Errorf("failed to update enrichment entries: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return int(queued), nil
+}
+
+// Pending returns up to limit queued addresses not attempted since notTriedSince, fewest attempts first
+// Failed lookups stay pending until they reach max attempts; the cutoff keeps one pass from retrying them.
+func (r *enrichmentRepository) Pending(notTriedSince time.Time, limit int) ([]string, error) {
+	rows, err := r.db.Query(`SELECT icao FROM enrichment_queue
+		WHERE status = ? AND (last_attempt IS NULL OR last_attempt < ?)
+		ORDER BY attempts, queued_at, icao LIMIT ?`, EnrichmentPending, notTriedSince.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query enrichment queue: %w", err)
+	}
+	defer rows.Close()
+
+	var icaos []string
+	for rows.Next() {
+		var icao string
+		if err := rows.Scan(&icao); err != nil {
+			return nil, fmt.Errorf("failed to scan enrichment entry: %w", err)
+		}
+		icaos = append(icaos, icao)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read enrichment queue: %w", err)
+	}
+	return icaos, nil
+}
+
+// MarkDone records the outcome of a successful lookup (resolved or not_found)
+func (r *enrichmentRepository) MarkDone(icao, status, resolver string) error {
+	_, err := r.db.Exec(`UPDATE enrichment_queue SET status = ?, resolver = ?, attempts = attempts + 1,
+		last_attempt = ?, last_error = '' WHERE icao = ?`,
+		status, resolver, time.Now().UTC(), strings.ToUpper(icao))
+	if err != nil {
+		return fmt.Errorf("failed to update enrichment entry %s: %w", icao, err)
+	}
+	return nil
+}
+
+// MarkError records a failed lookup, giving up on the entry after maxAttempts
+func (r *enrichmentRepository) MarkError(icao string, lookupErr error, maxAttempts int) error {
+	_, err := r.db.Exec(`UPDATE enrichment_queue SET attempts = attempts + 1, last_attempt = ?, last_error = ?,
+		status = CASE WHEN attempts + 1 >= ? THEN ? ELSE status END
+		WHERE icao = ?`,
+		time.Now().UTC(), lookupErr.Error(), maxAttempts, EnrichmentFailed, strings.ToUpper(icao))
+	if err != nil {
+		return fmt.Errorf("failed to update enrichment entry %s: %w", icao, err)
+	}
+	return nil
+}
+
+// Counts returns the number of queue entries in each state
+func (r *enrichmentRepository) Counts() (map[string]int, error) {
+	rows, err := r.db.Query(`SELECT status, COUNT(*) FROM enrichment_queue GROUP BY status`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count enrichment queue: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[string]int{
+		EnrichmentPending:  0,
+		EnrichmentResolved: 0,
+		EnrichmentNotFound: 0,
+		EnrichmentFailed:   0,
+	}
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan enrichment count: %w", err)
+		}
+		counts[status] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read enrichment counts: %w", err)
+	}
+	return counts, nil
+}
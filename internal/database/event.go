@@ -0,0 +1,149 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/models"
+)
+
+type EventRepository interface {
+	Insert(event *models.Event) error
+	QueryHistory(filter EventFilter, page PageRequest) ([]*models.Event, string, error)
+}
+
+// EventFilter narrows an event history query; zero values don't filter
+type EventFilter struct {
+	Type     string
+	Types    []string // Any of these types
+	Severity string
+	ICAO     string
+	From     time.Time // Inclusive
+	To       time.Time // Exclusive
+}
+
+var eventSortable = sortableTable{
+	columns: map[string]sortKind{
+		"id":   sortInt,
+		"time": sortTime,
+	},
+	key:         "id",
+	keyKind:     sortInt,
+	defaultSort: "time",
+}
+
+type eventRepository struct {
+	db *sql.DB
+}
+
+func NewEventRepository(db *sql.DB) EventRepository {
+	return &eventRepository{db: db}
+}
+
+// Insert stores an event and sets its ID
+func (r *eventRepository) Insert(event *models.Event) error {
+	var data []byte
+	if len(event.Data) > 0 {
+		var err error
+		if data, err = json.Marshal(event.Data); err != nil {
+			return fmt.Errorf("failed to encode event data: %w", err)
+		}
+	}
+
+	result, err := r.db.Exec(`INSERT INTO events (time, type, severity, icao, callsign, message, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		event.Time.UTC(), event.Type, event.Severity, event.ICAO, event.Callsign, event.Message, string(data),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert event: %w", err)
+	}
+
+	if event.ID, err = result.LastInsertId(); err != nil {
+		return fmt.Errorf("failed to read event id: %w", err)
+	}
+	return nil
+}
+
+// QueryHistory returns one page of events and the cursor for the next page ("" on the last page)
+func (r *eventRepository) QueryHistory(filter EventFilter, page PageRequest) ([]*models.Event, string, error) {
+	column, order, seek, seekArgs, err := eventSortable.orderAndSeek(page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var conditions []string
+	var args []any
+	if filter.Type != "" {
+		conditions = append(conditions, "type = ?")
+		args = append(args, filter.Type)
+	}
+	if len(filter.Types) > 0 {
+		conditions = append(conditions, "type IN (?"+strings.Repeat(", ?", len(filter.Types)-1)+")")
+		for _, t := range filter.Types {
+			args = append(args, t)
+		}
+	}
+	if filter.Severity != "" {
+		conditions = append(conditions, "severity = ?")
+		args = append(args, filter.Severity)
+	}
+	if filter.ICAO != "" {
+		conditions = append(conditions, "icao = ?")
+		args = append(args, filter.ICAO)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "time >= ?")
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "time < ?")
+		args = append(args, filter.To.UTC())
+	}
+	if seek != "" {
+		conditions = append(conditions, seek)
+		args = append(args, seekArgs...)
+	}
+
+	limit := page.limit()
+	query := fmt.Sprintf(`SELECT id, time, type, severity, icao, callsign, message, data
+		FROM events %s %s LIMIT %d`, whereClause(conditions), order, limit+1)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*models.Event
+	for rows.Next() {
+		e := &models.Event{}
+		var data string
+		if err := rows.Scan(&e.ID, &e.Time, &e.Type, &e.Severity, &e.ICAO, &e.Callsign, &e.Message, &data); err != nil {
+			return nil, "", fmt.Errorf("failed to scan event: %w", err)
+		}
+		if data != "" {
+			if err := json.Unmarshal([]byte(data), &e.Data); err != nil {
+				return nil, "", fmt.Errorf("failed to decode data of event %d: %w", e.ID, err)
+			}
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to read events: %w", err)
+	}
+
+	if len(events) <= limit {
+		return events, "", nil
+	}
+
+	events = events[:limit]
+	last := events[limit-1]
+	var value any = last.ID
+	if column == "time" {
+		value = last.Time
+	}
+	return events, encodeCursor(page, column, value, last.ID), nil
+}
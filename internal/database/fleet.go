@@ -0,0 +1,170 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"flight_trmnl/internal/models"
+)
+
+type FleetRepository interface {
+	Operators(limit int) ([]*OperatorFleet, error)
+	Fleet(operatorICAO string) (*FleetReport, error)
+	Blocks() ([]*BlockCount, error)
+}
+
+// OperatorFleet is how much of one operator's fleet the station has seen
+type OperatorFleet struct {
+	OperatorICAO string  `json:"operator_icao"`
+	Operator     string  `json:"operator"`
+	FleetSize    int     `json:"fleet_size"` // Aircraft listed for the operator in the aircraft database
+	Seen         int     `json:"seen"`
+	SeenPercent  float64 `json:"seen_percent"`
+}
+
+// FleetAircraft is one aircraft of an operator's fleet and whether the station has seen it
+type FleetAircraft struct {
+	ICAO         string `json:"icao"`
+	Registration string `json:"registration"`
+	TypeCode     string `json:"type_code"`
+	Seen         bool   `json:"seen"`
+}
+
+// FleetReport lists an operator's fleet with seen and unseen aircraft
+type FleetReport struct {
+	OperatorFleet
+	Aircraft []*FleetAircraft `json:"aircraft"`
+}
+
+// BlockCount is the number of aircraft seen from one ICAO address allocation
+type BlockCount struct {
+	Country string `json:"country"`
+	Seen    int    `json:"seen"`
+}
+
+type fleetRepository struct {
+	db *sql.DB
+}
+
+func NewFleetRepository(db *sql.DB) FleetRepository {
+	return &fleetRepository{db: db}
+}
+
+// Operators returns the operators with the most aircraft seen, up to limit
+// Starts from seen_aircraft so only the (much smaller) set of seen aircraft is joined against the dataset.
+func (r *fleetRepository) Operators(limit int) ([]*OperatorFleet, error) {
+	rows, err := r.db.Query(`SELECT a.operatorIcao, MAX(COALESCE(a.operator, '')), COUNT(*) AS seen,
+			(SELECT COUNT(*) FROM aircraft f WHERE f.operatorIcao = a.operatorIcao)
+		FROM seen_aircraft s
+		JOIN aircraft a ON a.icao24 = lower(s.icao)
+		WHERE a.operatorIcao IS NOT NULL AND a.operatorIcao != ''
+		GROUP BY a.operatorIcao
+		ORDER BY seen DESC, a.operatorIcao
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query operator fleets: %w", err)
+	}
+	defer rows.Close()
+
+	var fleets []*OperatorFleet
+	for rows.Next() {
+		f := &OperatorFleet{}
+		if err := rows.Scan(&f.OperatorICAO, &f.Operator, &f.Seen, &f.FleetSize); err != nil {
+			return nil, fmt.Errorf("failed to scan operator fleet: %w", err)
+		}
+		f.SeenPercent = SeenPercent(f.Seen, f.FleetSize)
+		fleets = append(fleets, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read operator fleets: %w", err)
+	}
+	return fleets, nil
+}
+
+// Fleet returns every aircraft listed for an operator ICAO code (e.g. UAL), or nil if the operator is unknown
+func (r *fleetRepository) Fleet(operatorICAO string) (*FleetReport, error) {
+	rows, err := r.db.Query(`SELECT a.icao24, COALESCE(a.operator, ''), COALESCE(a.registration, ''), COALESCE(a.typecode, ''),
+			s.icao IS NOT NULL
+		FROM aircraft a
+		LEFT JOIN seen_aircraft s ON s.icao = upper(a.icao24)
+		WHERE a.operatorIcao = ?
+		ORDER BY a.registration`, strings.ToUpper(operatorICAO))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fleet for %s: %w", operatorICAO, err)
+	}
+	defer rows.Close()
+
+	report := &FleetReport{OperatorFleet: OperatorFleet{OperatorICAO: strings.ToUpper(operatorICAO)}}
+	for rows.Next() {
+		ac := &FleetAircraft{}
+		var operator string
+		if err := rows.Scan(&ac.ICAO, &operator, &ac.Registration, &ac.TypeCode, &ac.Seen); err != nil {
+			return nil, fmt.Errorf("failed to scan fleet aircraft: %w", err)
+		}
+		ac.ICAO = strings.ToUpper(ac.ICAO)
+		if report.Operator == "" {
+			report.Operator = operator
+		}
+		if ac.Seen {
+			report.Seen++
+		}
+		report.Aircraft = append(report.Aircraft, ac)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read fleet: %w", err)
+	}
+
+	if len(report.Aircraft) == 0 {
+		return nil, nil
+	}
+	report.FleetSize = len(report.Aircraft)
+	report.SeenPercent = SeenPercent(report.Seen, report.FleetSize)
+	return report, nil
+}
+
+// Blocks counts seen aircraft by the ICAO address block (state of registry) they belong to, most first
+func (r *fleetRepository) Blocks() ([]*BlockCount, error) {
+	rows, err := r.db.Query(`SELECT icao FROM seen_aircraft`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query seen aircraft: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var icao string
+		if err := rows.Scan(&icao); err != nil {
+			return nil, fmt.Errorf("failed to scan seen aircraft: %w", err)
+		}
+		country := "Unallocated"
+		if block, ok := models.LookupICAOBlock(icao); ok {
+			country = block.Country
+		}
+		counts[country]++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read seen aircraft: %w", err)
+	}
+
+	blocks := make([]*BlockCount, 0, len(counts))
+	for country, seen := range counts {
+		blocks = append(blocks, &BlockCount{Country: country, Seen: seen})
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		if blocks[i].Seen != blocks[j].Seen {
+			return blocks[i].Seen > blocks[j].Seen
+		}
+		return blocks[i].Country < blocks[j].Country
+	})
+	return blocks, nil
+}
+
+// SeenPercent returns seen as a percentage of total, 0 for an empty fleet
+func SeenPercent(seen, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(seen) * 100 / float64(total)
+}
@@ -0,0 +1,201 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Flight is one visit of an aircraft to the station, from its first message until the tracker expired it, with
+// how well it was received
+type Flight struct {
+	ID           int64     `json:"id"`
+	ICAO         string    `json:"icao"`
+	Callsign     string    `json:"callsign,omitempty"`
+	Category     string    `json:"category,omitempty"`
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+	Messages     int64     `json:"messages"`
+	Quality      int       `json:"quality"`             // Reception quality score, 0 to 100
+	Gaps         int       `json:"gaps"`                // Silences longer than 5 seconds
+	LongestGap   float64   `json:"longest_gap"`         // Seconds
+	Positions    int64     `json:"positions"`           // Decoded positions
+	PositionRate float64   `json:"position_rate"`       // Decoded positions per second
+	SignalMean   float64   `json:"signal_mean"`         // dBFS
+	SignalStdDev float64   `json:"signal_stddev"`       // dB
+	MaxRange     float64   `json:"max_range,omitempty"` // Kilometres to the farthest decoded position, when known
+}
+
+// FlightFilter narrows a flight query; zero values don't filter
+type FlightFilter struct {
+	ICAO       string
+	Since      time.Time // Flights last seen at or after this time
+	MinQuality int
+}
+
+// FlightSummary totals the flights of a period
+type FlightSummary struct {
+	Flights     int     `json:"flights"`
+	Aircraft    int     `json:"aircraft"`     // Distinct aircraft
+	MaxRange    float64 `json:"max_range"`    // Kilometres, 0 when no flight had a known range
+	MeanQuality float64 `json:"mean_quality"` // Mean reception quality score
+}
+
+// LogEntry is a flight as spotters log it: when it was seen, the airframe, and who operates it; the registry
+// details come from the aircraft table and are empty for aircraft it doesn't know
+type LogEntry struct {
+	ICAO         string    `json:"icao"`
+	Callsign     string    `json:"callsign,omitempty"`
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+	Registration string    `json:"registration,omitempty"`
+	TypeCode     string    `json:"type_code,omitempty"` // ICAO type designator, e.g. B738
+	Type         string    `json:"type,omitempty"`      // Manufacturer and model, e.g. Boeing 737-8K2
+	Operator     string    `json:"operator,omitempty"`
+}
+
+type FlightRepository interface {
+	Insert(flight *Flight) error
+	List(filter FlightFilter, limit int) ([]*Flight, error)
+	Summary(from, to time.Time) (*FlightSummary, error)
+	SightingLog(from, to time.Time) ([]*LogEntry, error)
+}
+
+type flightRepository struct {
+	db *sql.DB
+}
+
+func NewFlightRepository(db *sql.DB) FlightRepository {
+	return &flightRepository{db: db}
+}
+
+// migrateFlightSignals converts the signal statistics of flights stored as raw Beast signal levels to dBFS. Those
+// are positive, dBFS never is. The standard deviation is converted to first order, around the mean.
+func migrateFlightSignals(db *sql.DB) error {
+	rows, err := db.Query(`SELECT id, signal_mean, signal_stddev FROM flights WHERE signal_mean > 0`)
+	if err != nil {
+		return fmt.Errorf("failed to read flight signals: %w", err)
+	}
+	type signal struct {
+		id           int64
+		mean, stddev float64
+	}
+	var raw []signal
+	for rows.Next() {
+		var s signal
+		if err := rows.Scan(&s.id, &s.mean, &s.stddev); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan flight signal: %w", err)
+		}
+		raw = append(raw, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read flight signals: %w", err)
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	for _, s := range raw {
+		mean := math.Round(200*math.Log10(s.mean/255)) / 10
+		stddev := math.Round(200/math.Ln10*s.stddev/s.mean) / 10
+		if _, err := tx.Exec(`UPDATE flights SET signal_mean = ?, signal_stddev = ? WHERE id = ?`, mean, stddev, s.id); err != nil {
+			return fmt.Errorf("failed to convert flight signal: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *flightRepository) Insert(flight *Flight) error {
+	result, err := r.db.Exec(`INSERT INTO flights
+		(icao, callsign, category, first_seen, last_seen, messages, quality, gaps, longest_gap, positions,
+			position_rate, signal_mean, signal_stddev, max_range)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		flight.ICAO, flight.Callsign, flight.Category, flight.FirstSeen.UTC(), flight.LastSeen.UTC(), flight.Messages,
+		flight.Quality, flight.Gaps, flight.LongestGap, flight.Positions, flight.PositionRate, flight.SignalMean,
+		flight.SignalStdDev, flight.MaxRange)
+	if err != nil {
+		return fmt.Errorf("failed to record flight: %w", err)
+	}
+	flight.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// List returns the flights matching the filter, most recently seen first
+func (r *flightRepository) List(filter FlightFilter, limit int) ([]*Flight, error) {
+	rows, err := r.db.Query(`SELECT id, icao, callsign, category, first_seen, last_seen, messages, quality, gaps,
+			longest_gap, positions, position_rate, signal_mean, signal_stddev, max_range
+		FROM flights WHERE last_seen >= ? AND (? = '' OR icao = ?) AND quality >= ?
+		ORDER BY last_seen DESC, id DESC LIMIT ?`,
+		filter.Since.UTC(), filter.ICAO, filter.ICAO, filter.MinQuality, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query flights: %w", err)
+	}
+	defer rows.Close()
+
+	var flights []*Flight
+	for rows.Next() {
+		f := &Flight{}
+		if err := rows.Scan(&f.ID, &f.ICAO, &f.Callsign, &f.Category, &f.FirstSeen, &f.LastSeen, &f.Messages,
+			&f.Quality, &f.Gaps, &f.LongestGap, &f.Positions, &f.PositionRate, &f.SignalMean,
+			&f.SignalStdDev, &f.MaxRange); err != nil {
+			return nil, fmt.Errorf("failed to scan flight: %w", err)
+		}
+		flights = append(flights, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read flights: %w", err)
+	}
+	return flights, nil
+}
+
+// Summary totals the flights that ended from from until to
+func (r *flightRepository) Summary(from, to time.Time) (*FlightSummary, error) {
+	s := &FlightSummary{}
+	err := r.db.QueryRow(`SELECT COUNT(*), COUNT(DISTINCT icao), COALESCE(MAX(max_range), 0), COALESCE(AVG(quality), 0)
+		FROM flights WHERE last_seen >= ? AND last_seen < ?`, from.UTC(), to.UTC()).Scan(
+		&s.Flights, &s.Aircraft, &s.MaxRange, &s.MeanQuality)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize flights: %w", err)
+	}
+	return s, nil
+}
+
+// SightingLog returns the flights that started from from until to, oldest first, with what the aircraft table
+// knows of each airframe
+func (r *flightRepository) SightingLog(from, to time.Time) ([]*LogEntry, error) {
+	rows, err := r.db.Query(`SELECT f.icao, f.callsign, f.first_seen, f.last_seen, COALESCE(a.registration, ''),
+			COALESCE(a.typecode, ''), TRIM(COALESCE(a.manufacturerName, '') || ' ' || COALESCE(a.model, '')),
+			COALESCE(a.operator, '')
+		FROM flights f LEFT JOIN aircraft a ON a.icao24 = lower(f.icao)
+		WHERE f.first_seen >= ? AND f.first_seen < ?
+		ORDER BY f.first_seen, f.id`, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sighting log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*LogEntry{}
+	for rows.Next() {
+		e := &LogEntry{}
+		if err := rows.Scan(&e.ICAO, &e.Callsign, &e.FirstSeen, &e.LastSeen, &e.Registration, &e.TypeCode, &e.Type,
+			&e.Operator); err != nil {
+			return nil, fmt.Errorf("failed to scan sighting: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read sighting log: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// History queries return at most MaxPageSize rows so a bad query can't dump weeks of messages at once
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// PageRequest selects one page of a history query using keyset (cursor) pagination
+// Keyset pagination stays fast on large tables where OFFSET would rescan every skipped row.
+type PageRequest struct {
+	Sort       string // Column to sort by, must be sortable for the queried table
+	Descending bool
+	Cursor     string // Opaque cursor returned with the previous page, empty for the first page
+	Limit      int    // Clamped to 1..MaxPageSize, DefaultPageSize when 0
+}
+
+// sortKind describes how a sortable column's cursor value is encoded
+type sortKind int
+
+const (
+	sortInt sortKind = iota
+	sortText
+	sortTime
+)
+
+// sortableTable describes the sortable columns of a table and its unique tie-breaker key
+type sortableTable struct {
+	columns     map[string]sortKind
+	key         string // Unique column appended to every sort so the cursor is unambiguous
+	keyKind     sortKind
+	defaultSort string
+}
+
+// pageCursor is the decoded form of a cursor: the sort value and key of the last row on the previous page
+type pageCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value any    `json:"v"`
+	Key   any    `json:"k"`
+}
+
+// limit returns the effective page size
+func (p PageRequest) limit() int {
+	switch {
+	case p.Limit <= 0:
+		return DefaultPageSize
+	case p.Limit > MaxPageSize:
+		return MaxPageSize
+	default:
+		return p.Limit
+	}
+}
+
+// orderAndSeek builds the ORDER BY clause and the keyset condition for a page request
+// Returns the sort column so callers can capture the cursor value of the last row.
+func (t sortableTable) orderAndSeek(p PageRequest) (column, order, seek string, args []any, err error) {
+	column = p.Sort
+	if column == "" {
+		column = t.defaultSort
+	}
+	kind, ok := t.columns[column]
+	if !ok {
+		return "", "", "", nil, fmt.Errorf("cannot sort by %q", column)
+	}
+
+	direction, cmp := "ASC", ">"
+	if p.Descending {
+		direction, cmp = "DESC", "<"
+	}
+	order = fmt.Sprintf("ORDER BY %s %s, %s %s", column, direction, t.key, direction)
+
+	if p.Cursor == "" {
+		return column, order, "", nil, nil
+	}
+
+	cursor, err := decodeCursor(p.Cursor)
+	if err != nil {
+		return "", "", "", nil, err
+	}
+	if cursor.Sort != column || cursor.Desc != p.Descending {
+		return "", "", "", nil, fmt.Errorf("cursor was issued for a different sort order")
+	}
+
+	value, err := cursorValue(kind, cursor.Value)
+	if err != nil {
+		return "", "", "", nil, err
+	}
+	key, err := cursorValue(t.keyKind, cursor.Key)
+	if err != nil {
+		return "", "", "", nil, err
+	}
+
+	// Row value comparison: everything strictly after (value, key) in sort order
+	seek = fmt.Sprintf("(%s, %s) %s (?, ?)", column, t.key, cmp)
+	return column, order, seek, []any{value, key}, nil
+}
+
+// encodeCursor builds the cursor pointing after a row with the given sort value and key
+func encodeCursor(p PageRequest, column string, value, key any) string {
+	data, _ := json.Marshal(pageCursor{Sort: column, Desc: p.Descending, Value: value, Key: key})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var c pageCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &c, nil
+}
+
+// cursorValue converts a JSON-decoded cursor value back into the type bound for its column
+func cursorValue(kind sortKind, v any) (any, error) {
+	switch kind {
+	case sortInt:
+		if f, ok := v.(float64); ok {
+			return int64(f), nil
+		}
+	case sortText:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case sortTime:
+		if s, ok := v.(string); ok {
+			// Bound as time.Time so the driver formats it the same way stored timestamps were written
+			if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("invalid cursor")
+}
+
+// whereClause joins conditions into a WHERE clause, empty when there are none
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
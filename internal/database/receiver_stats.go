@@ -0,0 +1,81 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ReceiverHour is what the local receiver delivered during one hour
+type ReceiverHour struct {
+	Hour        time.Time `json:"hour"` // Start of the hour, UTC
+	Messages    int64     `json:"messages"`
+	ParseErrors int64     `json:"parse_errors"` // Frames that were unknown or failed to parse
+	Reconnects  int64     `json:"reconnects"`
+}
+
+type ReceiverStatsRepository interface {
+	Add(hour *ReceiverHour) error
+	Get(hour time.Time) (*ReceiverHour, error)
+	List(since time.Time) ([]*ReceiverHour, error)
+}
+
+type receiverStatsRepository struct {
+	db *sql.DB
+}
+
+func NewReceiverStatsRepository(db *sql.DB) ReceiverStatsRepository {
+	return &receiverStatsRepository{db: db}
+}
+
+// Add adds counts to an hour, creating it on the first counts of the hour
+func (r *receiverStatsRepository) Add(hour *ReceiverHour) error {
+	_, err := r.db.Exec(`INSERT INTO receiver_hours (hour, messages, parse_errors, reconnects) VALUES (?, ?, ?, ?)
+		ON CONFLICT(hour) DO UPDATE SET
+			messages = messages + excluded.messages,
+			parse_errors = parse_errors + excluded.parse_errors,
+			reconnects = reconnects + excluded.reconnects`,
+		hour.Hour.UTC().Truncate(time.Hour).Unix(), hour.Messages, hour.ParseErrors, hour.Reconnects)
+	if err != nil {
+		return fmt.Errorf("failed to record receiver stats: %w", err)
+	}
+	return nil
+}
+
+// Get returns the counts of the hour containing t, or nil when nothing was recorded then
+func (r *receiverStatsRepository) Get(t time.Time) (*ReceiverHour, error) {
+	var unix int64
+	h := &ReceiverHour{}
+	err := r.db.QueryRow(`SELECT hour, messages, parse_errors, reconnects FROM receiver_hours WHERE hour = ?`,
+		t.UTC().Truncate(time.Hour).Unix()).Scan(&unix, &h.Messages, &h.ParseErrors, &h.Reconnects)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get receiver stats: %w", err)
+	}
+	h.Hour = time.Unix(unix, 0).UTC()
+	return h, nil
+}
+
+// List returns the recorded hours from the one containing since, oldest first
+func (r *receiverStatsRepository) List(since time.Time) ([]*ReceiverHour, error) {
+	rows, err := r.db.Query(`SELECT hour, messages, parse_errors, reconnects FROM receiver_hours WHERE hour >= ? ORDER BY hour`,
+		since.UTC().Truncate(time.Hour).Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query receiver stats: %w", err)
+	}
+	defer rows.Close()
+
+	var hours []*ReceiverHour
+	for rows.Next() {
+		var unix int64
+		h := &ReceiverHour{}
+		if err := rows.Scan(&unix, &h.Messages, &h.ParseErrors, &h.Reconnects); err != nil {
+			return nil, fmt.Errorf("failed to scan receiver stats: %w", err)
+		}
+		h.Hour = time.Unix(unix, 0).UTC()
+		hours = append(hours, h)
+	}
+	return hours, rows.Err()
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Station record kinds
+const (
+	RecordHighest  = "highest"  // Altitude in feet
+	RecordFastest  = "fastest"  // Speed in knots
+	RecordSlowest  = "slowest"  // Speed in knots while airborne
+	RecordFarthest = "farthest" // Distance from the receiver in kilometres, of a decoded position
+)
+
+// RecordAllCategories is the category of the records across every aircraft, including those without a category
+const RecordAllCategories = "all"
+
+// StationRecord is the best value the station heard for one kind of record in one aircraft category
+type StationRecord struct {
+	Category string    `json:"category"` // ADS-B emitter category such as A3, or all
+	Kind     string    `json:"kind"`     // highest, fastest, slowest, or farthest
+	Value    int       `json:"value"`    // Feet, knots, or kilometres
+	ICAO     string    `json:"icao"`     // Aircraft that set the record
+	Callsign string    `json:"callsign,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Unit is ft for altitudes, kt for speeds, and km for distances
+func (r *StationRecord) Unit() string {
+	switch r.Kind {
+	case RecordHighest:
+		return "ft"
+	case RecordFarthest:
+		return "km"
+	}
+	return "kt"
+}
+
+// Beats reports whether value is a better record of kind than current
+func Beats(kind string, value, current int) bool {
+	if kind == RecordSlowest {
+		return value < current
+	}
+	return value > current
+}
+
+type RecordRepository interface {
+	Offer(record *StationRecord) (bool, error)
+	List() ([]*StationRecord, error)
+}
+
+type recordRepository struct {
+	db *sql.DB
+}
+
+func NewRecordRepository(db *sql.DB) RecordRepository {
+	return &recordRepository{db: db}
+}
+
+// Offer stores a record when it beats the current one of its category and kind, reporting whether it did
+func (r *recordRepository) Offer(record *StationRecord) (bool, error) {
+	result, err := r.db.Exec(`INSERT INTO station_records (category, kind, value, icao, callsign, time)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (category, kind) DO UPDATE SET
+			value = excluded.value, icao = excluded.icao, callsign = excluded.callsign, time = excluded.time
+		WHERE CASE WHEN excluded.kind = ? THEN excluded.value < station_records.value
+			ELSE excluded.value > station_records.value END`,
+		record.Category, record.Kind, record.Value, record.ICAO, record.Callsign, record.Time.UTC(), RecordSlowest)
+	if err != nil {
+		return false, fmt.Errorf("failed to store station record: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check station record: %w", err)
+	}
+	return n > 0, nil
+}
+
+// List returns every station record by category and kind
+func (r *recordRepository) List() ([]*StationRecord, error) {
+	rows, err := r.db.Query(`SELECT category, kind, value, icao, callsign, time FROM station_records
+		ORDER BY category = ? DESC, category, kind`, RecordAllCategories)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query station records: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*StationRecord
+	for rows.Next() {
+		rec := &StationRecord{}
+		if err := rows.Scan(&rec.Category, &rec.Kind, &rec.Value, &rec.ICAO, &rec.Callsign, &rec.Time); err != nil {
+			return nil, fmt.Errorf("failed to scan station record: %w", err)
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read station records: %w", err)
+	}
+	return records, nil
+}
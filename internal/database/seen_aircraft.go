@@ -0,0 +1,215 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"flight_trmnl/internal/models"
+)
+
+type SeenAircraftRepository interface {
+	UpsertBatch(sightings []*models.Sighting) error
+	Get(icao string) (*models.Sighting, error)
+	QueryHistory(filter SightingFilter, page PageRequest) ([]*models.Sighting, string, error)
+	Summary(since time.Time) (*SightingSummary, error)
+}
+
+// SightingSummary counts aircraft activity since a point in time
+type SightingSummary struct {
+	Aircraft    int   `json:"aircraft"`     // Aircraft seen since the start time
+	NewAircraft int   `json:"new_aircraft"` // Aircraft seen for the first time ever since the start time
+	Messages    int64 `json:"messages"`     // Messages from aircraft seen since the start time, including earlier ones
+}
+
+// SightingFilter narrows a seen aircraft history query; zero values don't filter
+type SightingFilter struct {
+	SeenFrom time.Time // Aircraft last seen at or after this time
+	SeenTo   time.Time // Aircraft first seen before this time
+	Source   string
+}
+
+var sightingSortable = sortableTable{
+	columns: map[string]sortKind{
+		"icao":          sortText,
+		"first_seen":    sortTime,
+		"last_seen":     sortTime,
+		"message_count": sortInt,
+	},
+	key:         "icao",
+	keyKind:     sortText,
+	defaultSort: "last_seen",
+}
+
+type seenAircraftRepository struct {
+	db *sql.DB
+}
+
+func NewSeenAircraftRepository(db *sql.DB) SeenAircraftRepository {
+	return &seenAircraftRepository{db: db}
+}
+
+// upsertSightingSQL merges a sighting into seen_aircraft, widening the first/last seen window and summing message counts
+const upsertSightingSQL = `INSERT INTO seen_aircraft (
+		icao, first_seen, last_seen, message_count, callsign, category, source
+	) VALUES (?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(icao) DO UPDATE SET
+		first_seen = MIN(seen_aircraft.first_seen, excluded.first_seen),
+		last_seen = MAX(seen_aircraft.last_seen, excluded.last_seen),
+		message_count = seen_aircraft.message_count + excluded.message_count,
+		callsign = CASE
+			WHEN excluded.callsign != '' AND excluded.last_seen >= seen_aircraft.last_seen THEN excluded.callsign
+			ELSE seen_aircraft.callsign
+		END,
+		category = CASE
+			WHEN excluded.category != '' AND excluded.last_seen >= seen_aircraft.last_seen THEN excluded.category
+			ELSE seen_aircraft.category
+		END`
+
+// UpsertBatch merges one or more sightings into the seen_aircraft table in a single transaction
+func (r *seenAircraftRepository) UpsertBatch(sightings []*models.Sighting) error {
+	if len(sightings) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := upsertSightings(tx, sightings); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the sighting summary for an ICAO address, or nil if the aircraft has never been seen
+func (r *seenAircraftRepository) Get(icao string) (*models.Sighting, error) {
+	s := &models.Sighting{}
+	err := r.db.QueryRow(`SELECT icao, first_seen, last_seen, message_count, callsign, category, source
+		FROM seen_aircraft WHERE icao = ?`, icao).Scan(
+		&s.ICAO, &s.FirstSeen, &s.LastSeen, &s.MessageCount, &s.Callsign, &s.Category, &s.Source,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sighting for %s: %w", icao, err)
+	}
+	return s, nil
+}
+
+// QueryHistory returns one page of seen aircraft and the cursor for the next page ("" on the last page)
+func (r *seenAircraftRepository) QueryHistory(filter SightingFilter, page PageRequest) ([]*models.Sighting, string, error) {
+	column, order, seek, seekArgs, err := sightingSortable.orderAndSeek(page)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var conditions []string
+	var args []any
+	if !filter.SeenFrom.IsZero() {
+		conditions = append(conditions, "last_seen >= ?")
+		args = append(args, filter.SeenFrom.UTC())
+	}
+	if !filter.SeenTo.IsZero() {
+		conditions = append(conditions, "first_seen < ?")
+		args = append(args, filter.SeenTo.UTC())
+	}
+	if filter.Source != "" {
+		conditions = append(conditions, "source = ?")
+		args = append(args, filter.Source)
+	}
+	if seek != "" {
+		conditions = append(conditions, seek)
+		args = append(args, seekArgs...)
+	}
+
+	limit := page.limit()
+	query := fmt.Sprintf(`SELECT icao, first_seen, last_seen, message_count, callsign, category, source
+		FROM seen_aircraft %s %s LIMIT %d`, whereClause(conditions), order, limit+1)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query seen aircraft: %w", err)
+	}
+	defer rows.Close()
+
+	var sightings []*models.Sighting
+	for rows.Next() {
+		s := &models.Sighting{}
+		if err := rows.Scan(&s.ICAO, &s.FirstSeen, &s.LastSeen, &s.MessageCount, &s.Callsign, &s.Category, &s.Source); err != nil {
+			return nil, "", fmt.Errorf("failed to scan sighting: %w", err)
+		}
+		sightings = append(sightings, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to read seen aircraft: %w", err)
+	}
+
+	if len(sightings) <= limit {
+		return sightings, "", nil
+	}
+
+	sightings = sightings[:limit]
+	last := sightings[limit-1]
+	var value any
+	switch column {
+	case "icao":
+		value = last.ICAO
+	case "first_seen":
+		value = last.FirstSeen
+	case "message_count":
+		value = last.MessageCount
+	default:
+		value = last.LastSeen
+	}
+	return sightings, encodeCursor(page, column, value, last.ICAO), nil
+}
+
+// Summary counts the aircraft seen since a point in time
+// Message counts are lifetime totals per aircraft, the table doesn't keep per-day counts.
+func (r *seenAircraftRepository) Summary(since time.Time) (*SightingSummary, error) {
+	summary := &SightingSummary{}
+	err := r.db.QueryRow(`SELECT COUNT(*),
+			COALESCE(SUM(CASE WHEN first_seen >= ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(message_count), 0)
+		FROM seen_aircraft WHERE last_seen >= ?`, since.UTC(), since.UTC()).Scan(
+		&summary.Aircraft, &summary.NewAircraft, &summary.Messages,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize seen aircraft: %w", err)
+	}
+	return summary, nil
+}
+
+// upsertSightings writes sightings using an existing transaction so callers can combine it with other writes
+func upsertSightings(tx *sql.Tx, sightings []*models.Sighting) error {
+	stmt, err := tx.Prepare(upsertSightingSQL)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, s := range sightings {
+		if _, err := stmt.Exec(
+			s.ICAO,
+			s.FirstSeen.UTC(),
+			s.LastSeen.UTC(),
+			s.MessageCount,
+			s.Callsign,
+			s.Category,
+			s.Source,
+		); err != nil {
+			return fmt.Errorf("failed to upsert sighting for %s: %w", s.ICAO, err)
+		}
+	}
+
+	return nil
+}
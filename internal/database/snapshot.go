@@ -0,0 +1,189 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"flight_trmnl/internal/crypt"
+)
+
+// StateSnapshot is the live tracker state at one moment, the equivalent of an aircraft.json per interval
+type StateSnapshot struct {
+	ID       int64           `json:"id"`
+	Time     time.Time       `json:"time"`
+	Count    int             `json:"count"`    // Aircraft in the snapshot
+	Aircraft json.RawMessage `json:"aircraft"` // JSON array of tracker aircraft states
+}
+
+// BandCount is how often aircraft were in one altitude band across a range of snapshots
+type BandCount struct {
+	Band     string `json:"band"`     // Empty for aircraft that hadn't reported an altitude
+	Aircraft int    `json:"aircraft"` // Distinct aircraft seen in the band
+	Samples  int64  `json:"samples"`  // Aircraft in the band summed over the snapshots, proportional to time spent there
+}
+
+type StateSnapshotRepository interface {
+	Insert(snapshot *StateSnapshot) error
+	Range(from, to time.Time, limit int) ([]*StateSnapshot, error)
+	DeleteBefore(t time.Time) (int64, error)
+	BandCounts(from, to time.Time) ([]*BandCount, int, error)
+}
+
+type stateSnapshotRepository struct {
+	db     *sql.DB
+	cipher crypt.Cipher // Encrypts the aircraft, which carry their positions; nil stores them in the clear
+}
+
+func NewStateSnapshotRepository(db *sql.DB) StateSnapshotRepository {
+	return &stateSnapshotRepository{db: db}
+}
+
+// Insert stores a snapshot and sets its ID
+func (r *stateSnapshotRepository) Insert(snapshot *StateSnapshot) error {
+	aircraft, err := seal(r.cipher, string(snapshot.Aircraft))
+	if err != nil {
+		return err
+	}
+	result, err := r.db.Exec(`INSERT INTO state_snapshots (time, aircraft_count, aircraft) VALUES (?, ?, ?)`,
+		snapshot.Time.UTC(), snapshot.Count, aircraft)
+	if err != nil {
+		return fmt.Errorf("failed to insert state snapshot: %w", err)
+	}
+	if snapshot.ID, err = result.LastInsertId(); err != nil {
+		return fmt.Errorf("failed to read state snapshot id: %w", err)
+	}
+	return nil
+}
+
+// Range returns up to limit snapshots taken in [from, to), oldest first
+func (r *stateSnapshotRepository) Range(from, to time.Time, limit int) ([]*StateSnapshot, error) {
+	rows, err := r.db.Query(`SELECT id, time, aircraft_count, aircraft FROM state_snapshots
+		WHERE time >= ? AND time < ? ORDER BY time LIMIT ?`, from.UTC(), to.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query state snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []*StateSnapshot
+	for rows.Next() {
+		s := &StateSnapshot{}
+		var aircraft string
+		if err := rows.Scan(&s.ID, &s.Time, &s.Count, &aircraft); err != nil {
+			return nil, fmt.Errorf("failed to scan state snapshot: %w", err)
+		}
+		if aircraft, err = unseal(r.cipher, aircraft); err != nil {
+			return nil, fmt.Errorf("failed to read state snapshot %d: %w", s.ID, err)
+		}
+		s.Aircraft = json.RawMessage(aircraft)
+		snapshots = append(snapshots, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read state snapshots: %w", err)
+	}
+	return snapshots, nil
+}
+
+// BandCounts rolls up the snapshots taken in [from, to) by altitude band, returning the counts by band name and
+// how many snapshots were read. Snapshots stored before altitudes were tracked count as an unknown band.
+func (r *stateSnapshotRepository) BandCounts(from, to time.Time) ([]*BandCount, int, error) {
+	var snapshots int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM state_snapshots WHERE time >= ? AND time < ?`,
+		from.UTC(), to.UTC()).Scan(&snapshots); err != nil {
+		return nil, 0, fmt.Errorf("failed to count state snapshots: %w", err)
+	}
+
+	if r.cipher != nil {
+		counts, err := r.sealedBandCounts(from, to)
+		return counts, snapshots, err
+	}
+
+	rows, err := r.db.Query(`SELECT COALESCE(json_extract(a.value, '$.altitude_band'), '') AS band,
+			COUNT(DISTINCT json_extract(a.value, '$.icao')), COUNT(*)
+		FROM state_snapshots s, json_each(s.aircraft) a
+		WHERE s.time >= ? AND s.time < ?
+		GROUP BY band ORDER BY band`, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query altitude bands: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []*BandCount
+	for rows.Next() {
+		c := &BandCount{}
+		if err := rows.Scan(&c.Band, &c.Aircraft, &c.Samples); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan altitude band: %w", err)
+		}
+		counts = append(counts, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read altitude bands: %w", err)
+	}
+	return counts, snapshots, nil
+}
+
+// sealedBandCounts rolls up the snapshots like BandCounts does in SQL, decrypting them here since SQLite can't read
+// encrypted aircraft
+func (r *stateSnapshotRepository) sealedBandCounts(from, to time.Time) ([]*BandCount, error) {
+	rows, err := r.db.Query(`SELECT aircraft FROM state_snapshots WHERE time >= ? AND time < ?`, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query altitude bands: %w", err)
+	}
+	defer rows.Close()
+
+	bands := map[string]*BandCount{}
+	seen := map[string]map[string]bool{}
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, fmt.Errorf("failed to scan altitude band: %w", err)
+		}
+		if raw, err = unseal(r.cipher, raw); err != nil {
+			return nil, fmt.Errorf("failed to read state snapshot: %w", err)
+		}
+		var aircraft []struct {
+			ICAO string `json:"icao"`
+			Band string `json:"altitude_band"`
+		}
+		if err := json.Unmarshal([]byte(raw), &aircraft); err != nil {
+			return nil, fmt.Errorf("invalid state snapshot: %w", err)
+		}
+		for _, a := range aircraft {
+			c := bands[a.Band]
+			if c == nil {
+				c = &BandCount{Band: a.Band}
+				bands[a.Band], seen[a.Band] = c, map[string]bool{}
+			}
+			c.Samples++
+			if !seen[a.Band][a.ICAO] {
+				seen[a.Band][a.ICAO] = true
+				c.Aircraft++
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read altitude bands: %w", err)
+	}
+
+	var counts []*BandCount
+	for _, c := range bands {
+		counts = append(counts, c)
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Band < counts[j].Band })
+	return counts, nil
+}
+
+// DeleteBefore removes snapshots taken before t, returning how many were removed
+func (r *stateSnapshotRepository) DeleteBefore(t time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM state_snapshots WHERE time < ?`, t.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete state snapshots: %w", err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count deleted state snapshots: %w", err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,105 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrStationExists is returned when registering a station name that is already registered
+var ErrStationExists = errors.New("station already registered")
+
+// HubStation is a station registered to feed the hub, identified by the hash of its API key
+type HubStation struct {
+	Name      string     `json:"name"`
+	KeyHash   string     `json:"-"`          // Hex sha256 of the key, the key itself is only shown when issued
+	RateLimit float64    `json:"rate_limit"` // Messages per second, 0 uses the hub default
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type HubStationRepository interface {
+	Create(station *HubStation) error
+	Get(name string) (*HubStation, error)
+	List() ([]*HubStation, error)
+	Revoke(name string, at time.Time) (bool, error)
+}
+
+type hubStationRepository struct {
+	db *sql.DB
+}
+
+func NewHubStationRepository(db *sql.DB) HubStationRepository {
+	return &hubStationRepository{db: db}
+}
+
+// Create registers a station; a revoked name can't be registered again, so its old key stays revoked
+func (r *hubStationRepository) Create(station *HubStation) error {
+	result, err := r.db.Exec(`INSERT OR IGNORE INTO hub_stations (name, key_hash, rate_limit, created_at) VALUES (?, ?, ?, ?)`,
+		station.Name, station.KeyHash, station.RateLimit, station.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to register station: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: %s", ErrStationExists, station.Name)
+	}
+	return nil
+}
+
+// Get returns a station by name, revoked or not, or nil when it isn't registered
+func (r *hubStationRepository) Get(name string) (*HubStation, error) {
+	s := &HubStation{}
+	var revoked sql.NullTime
+	err := r.db.QueryRow(`SELECT name, key_hash, rate_limit, created_at, revoked_at FROM hub_stations WHERE name = ?`, name).
+		Scan(&s.Name, &s.KeyHash, &s.RateLimit, &s.CreatedAt, &revoked)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get station: %w", err)
+	}
+	if revoked.Valid {
+		s.RevokedAt = &revoked.Time
+	}
+	return s, nil
+}
+
+// List returns every registered station by name
+func (r *hubStationRepository) List() ([]*HubStation, error) {
+	rows, err := r.db.Query(`SELECT name, key_hash, rate_limit, created_at, revoked_at FROM hub_stations ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stations: %w", err)
+	}
+	defer rows.Close()
+
+	var stations []*HubStation
+	for rows.Next() {
+		s := &HubStation{}
+		var revoked sql.NullTime
+		if err := rows.Scan(&s.Name, &s.KeyHash, &s.RateLimit, &s.CreatedAt, &revoked); err != nil {
+			return nil, fmt.Errorf("failed to scan station: %w", err)
+		}
+		if revoked.Valid {
+			s.RevokedAt = &revoked.Time
+		}
+		stations = append(stations, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stations: %w", err)
+	}
+	return stations, nil
+}
+
+// Revoke disables a station's key, reporting whether an active station was revoked
+func (r *hubStationRepository) Revoke(name string, at time.Time) (bool, error) {
+	result, err := r.db.Exec(`UPDATE hub_stations SET revoked_at = ? WHERE name = ? AND revoked_at IS NULL`, at.UTC(), name)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke station: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to count revoked stations: %w", err)
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,243 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// SyncTables are the tables copied to a remote database, those whose rows are only ever added, so a row's id says
+// whether it was copied yet
+var SyncTables = []string{"beast_messages", "events", "flights", "resolution_advisories", "connection_events", "coverage_checks"}
+
+// SyncBatch is consecutive rows of one table on their way to a remote database
+// Values are stored as they are, so encrypted columns arrive encrypted, and timestamps as the text SQLite holds.
+type SyncBatch struct {
+	Table   string   `json:"table"`
+	Columns []string `json:"columns"` // The first is the id in the sending database
+	Rows    [][]any  `json:"rows"`
+}
+
+// Last returns the sending database's id of the last row, 0 when there are none
+func (b *SyncBatch) Last() int64 {
+	if len(b.Rows) == 0 {
+		return 0
+	}
+	id, _ := syncID(b.Rows[len(b.Rows)-1][0])
+	return id
+}
+
+// SyncRepository reads the rows to copy to a remote database, and adds copied rows on the receiving end
+type SyncRepository interface {
+	// Read returns up to limit rows of a table after the row with id after, in id order
+	Read(table string, after int64, limit int) (*SyncBatch, error)
+	// Cursors returns the last id copied from a source database for each table, missing when none were
+	Cursors(source string) (map[string]int64, error)
+	// Apply adds the rows of a batch from a source database that weren't added before, with new ids, and moves the
+	// source's cursor to the last one in the same transaction, so a batch cut off half way is never half applied.
+	// Columns this database doesn't have are dropped.
+	Apply(source string, batch *SyncBatch) (int64, error)
+}
+
+type syncRepository struct {
+	db *sql.DB
+}
+
+func NewSyncRepository(db *sql.DB) SyncRepository {
+	return &syncRepository{db: db}
+}
+
+// CheckSyncTable rejects a table that can't be synced
+func CheckSyncTable(table string) error {
+	if !slices.Contains(SyncTables, table) {
+		return fmt.Errorf("table %s can't be synced (must be one of %s)", table, strings.Join(SyncTables, ", "))
+	}
+	return nil
+}
+
+func (r *syncRepository) Read(table string, after int64, limit int) (*SyncBatch, error) {
+	if err := CheckSyncTable(table); err != nil {
+		return nil, err
+	}
+	rows, err := r.db.Query(fmt.Sprintf(`SELECT * FROM %s WHERE id > ? ORDER BY id LIMIT ?`, table), after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s columns: %w", table, err)
+	}
+	if len(columns) == 0 || columns[0] != "id" {
+		return nil, fmt.Errorf("table %s doesn't start with an id column", table)
+	}
+	batch := &SyncBatch{Table: table, Columns: columns}
+	for rows.Next() {
+		values := make([]any, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", table, err)
+		}
+		for i, v := range values {
+			switch v := v.(type) {
+			case time.Time:
+				// As the driver writes it, so the copy compares with timestamps stored there directly
+				values[i] = v.Format(sqlite3.SQLiteTimestampFormats[0])
+			case []byte:
+				values[i] = string(v)
+			}
+		}
+		batch.Rows = append(batch.Rows, values)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", table, err)
+	}
+	return batch, nil
+}
+
+func (r *syncRepository) Cursors(source string) (map[string]int64, error) {
+	rows, err := r.db.Query(`SELECT table_name, last_id FROM sync_cursors WHERE source = ?`, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync cursors: %w", err)
+	}
+	defer rows.Close()
+
+	cursors := make(map[string]int64)
+	for rows.Next() {
+		var table string
+		var last int64
+		if err := rows.Scan(&table, &last); err != nil {
+			return nil, fmt.Errorf("failed to scan sync cursor: %w", err)
+		}
+		cursors[table] = last
+	}
+	return cursors, rows.Err()
+}
+
+func (r *syncRepository) Apply(source string, batch *SyncBatch) (int64, error) {
+	if err := CheckSyncTable(batch.Table); err != nil {
+		return 0, err
+	}
+	if len(batch.Columns) == 0 || batch.Columns[0] != "id" {
+		return 0, fmt.Errorf("%s batch doesn't start with an id column", batch.Table)
+	}
+	known, err := r.columns(batch.Table)
+	if err != nil {
+		return 0, err
+	}
+	var insert []string
+	var positions []int
+	for i, column := range batch.Columns[1:] {
+		if known[column] {
+			insert = append(insert, column)
+			positions = append(positions, i+1)
+		}
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin sync transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var cursor int64
+	err = tx.QueryRow(`SELECT last_id FROM sync_cursors WHERE source = ? AND table_name = ?`, source, batch.Table).Scan(&cursor)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to read sync cursor: %w", err)
+	}
+
+	stmt, err := tx.Prepare(fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`, batch.Table, strings.Join(insert, ", "),
+		strings.TrimSuffix(strings.Repeat("?, ", len(insert)), ", ")))
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare %s insert: %w", batch.Table, err)
+	}
+	defer stmt.Close()
+
+	last := cursor
+	args := make([]any, len(insert))
+	for _, row := range batch.Rows {
+		if len(row) != len(batch.Columns) {
+			return 0, fmt.Errorf("%s row has %d values for %d columns", batch.Table, len(row), len(batch.Columns))
+		}
+		id, ok := syncID(row[0])
+		if !ok {
+			return 0, fmt.Errorf("%s row has an invalid id %v", batch.Table, row[0])
+		}
+		if id <= last {
+			continue // Copied before, by an attempt whose acknowledgement was lost
+		}
+		for i, p := range positions {
+			args[i] = syncValue(row[p])
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			return 0, fmt.Errorf("failed to insert into %s: %w", batch.Table, err)
+		}
+		last = id
+	}
+
+	_, err = tx.Exec(`INSERT INTO sync_cursors (source, table_name, last_id, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(source, table_name) DO UPDATE SET last_id = excluded.last_id, updated_at = excluded.updated_at`,
+		source, batch.Table, last, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to update sync cursor: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit sync transaction: %w", err)
+	}
+	return last, nil
+}
+
+// columns returns the names of a table's columns
+func (r *syncRepository) columns(table string) (map[string]bool, error) {
+	rows, err := r.db.Query(fmt.Sprintf(`SELECT name FROM pragma_table_info('%s')`, table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s columns: %w", table, err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan %s column: %w", table, err)
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+// syncID reads a row id, which arrives as a JSON number when a batch was sent
+func syncID(v any) (int64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case json.Number:
+		id, err := v.Int64()
+		return id, err == nil
+	case float64:
+		return int64(v), v == float64(int64(v))
+	}
+	return 0, false
+}
+
+// syncValue turns a JSON number back into an integer or a float, whichever it was
+func syncValue(v any) any {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v
+	}
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	f, _ := n.Float64()
+	return f
+}
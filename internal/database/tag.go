@@ -0,0 +1,142 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/models"
+)
+
+type TagRepository interface {
+	ReplaceSource(source string, tags []*models.AircraftTag) error
+	Get(icao string) ([]*models.AircraftTag, error)
+	Sources() ([]*TagSource, error)
+	SourceICAOs(source string) ([]string, error)
+}
+
+// TagSource summarizes one imported special aircraft list
+type TagSource struct {
+	Name      string    `json:"name"`
+	Aircraft  int       `json:"aircraft"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type tagRepository struct {
+	db *sql.DB
+}
+
+func NewTagRepository(db *sql.DB) TagRepository {
+	return &tagRepository{db: db}
+}
+
+// ReplaceSource swaps every tag from a list for a freshly imported copy in one transaction
+// Aircraft dropped from the list lose their tag, and readers never see a half-imported list.
+func (r *tagRepository) ReplaceSource(source string, tags []*models.AircraftTag) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM aircraft_tags WHERE source = ?`, source); err != nil {
+		return fmt.Errorf("failed to clear tags for %s: %w", source, err)
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO aircraft_tags (
+			icao, source, registration, operator, type, grp, category, tags, link, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(icao, source) DO NOTHING`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC()
+	for _, tag := range tags {
+		_, err := stmt.Exec(strings.ToUpper(tag.ICAO), source, tag.Registration, tag.Operator, tag.Type,
+			tag.Group, tag.Category, strings.Join(tag.Tags, ","), tag.Link, now)
+		if err != nil {
+			return fmt.Errorf("failed to insert tag for %s: %w", tag.ICAO, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Get returns the tags of an aircraft from every list, empty if it is on none
+func (r *tagRepository) Get(icao string) ([]*models.AircraftTag, error) {
+	rows, err := r.db.Query(`SELECT icao, source, registration, operator, type, grp, category, tags, link, updated_at
+		FROM aircraft_tags WHERE icao = ? ORDER BY source`, strings.ToUpper(icao))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags for %s: %w", icao, err)
+	}
+	defer rows.Close()
+
+	var tags []*models.AircraftTag
+	for rows.Next() {
+		tag := &models.AircraftTag{}
+		var list string
+		if err := rows.Scan(&tag.ICAO, &tag.Source, &tag.Registration, &tag.Operator, &tag.Type,
+			&tag.Group, &tag.Category, &list, &tag.Link, &tag.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		if list != "" {
+			tag.Tags = strings.Split(list, ",")
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tags: %w", err)
+	}
+	return tags, nil
+}
+
+// Sources lists the imported lists with their size and last import time
+func (r *tagRepository) Sources() ([]*TagSource, error) {
+	// Every row of a list shares the import time, so the bare updated_at column is safe to select
+	rows, err := r.db.Query(`SELECT source, COUNT(*), updated_at FROM aircraft_tags GROUP BY source ORDER BY source`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tag sources: %w", err)
+	}
+	defer rows.Close()
+
+	var sources []*TagSource
+	for rows.Next() {
+		s := &TagSource{}
+		if err := rows.Scan(&s.Name, &s.Aircraft, &s.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tag source: %w", err)
+		}
+		sources = append(sources, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tag sources: %w", err)
+	}
+	return sources, nil
+}
+
+// SourceICAOs returns the addresses of every aircraft on one list
+func (r *tagRepository) SourceICAOs(source string) ([]string, error) {
+	rows, err := r.db.Query(`SELECT icao FROM aircraft_tags WHERE source = ?`, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags for %s: %w", source, err)
+	}
+	defer rows.Close()
+
+	var icaos []string
+	for rows.Next() {
+		var icao string
+		if err := rows.Scan(&icao); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		icaos = append(icaos, icao)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tags: %w", err)
+	}
+	return icaos, nil
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+)
+
+// TaskRun is one finished run of a background task
+type TaskRun struct {
+	ID       int64     `json:"id"`
+	Task     string    `json:"task"`
+	Trigger  string    `json:"trigger"` // interval, startup, after, calendar, or on_demand
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Duration float64   `json:"duration"`        // Seconds
+	Error    string    `json:"error,omitempty"` // Empty when the run succeeded
+}
+
+// TaskRunSummary sums up a task's runs over a period
+type TaskRunSummary struct {
+	Task           string     `json:"task"`
+	Runs           int        `json:"runs"`
+	Failures       int        `json:"failures"`
+	SuccessPercent float64    `json:"success_percent"`
+	AvgDuration    float64    `json:"avg_duration"` // Seconds
+	LastSuccess    *time.Time `json:"last_success,omitempty"`
+	LastFailure    *time.Time `json:"last_failure,omitempty"`
+	LastError      string     `json:"last_error,omitempty"` // Error of the last failure
+}
+
+type TaskRunRepository interface {
+	Insert(run *TaskRun) error
+	List(task string, since time.Time, limit int) ([]*TaskRun, error)
+	Summary(since time.Time) ([]*TaskRunSummary, error)
+}
+
+type taskRunRepository struct {
+	db *sql.DB
+}
+
+func NewTaskRunRepository(db *sql.DB) TaskRunRepository {
+	return &taskRunRepository{db: db}
+}
+
+func (r *taskRunRepository) Insert(run *TaskRun) error {
+	result, err := r.db.Exec(`INSERT INTO task_runs (task, trigger, started, finished, error) VALUES (?, ?, ?, ?, ?)`,
+		run.Task, run.Trigger, run.Started.UTC(), run.Finished.UTC(), run.Error)
+	if err != nil {
+		return fmt.Errorf("failed to record task run: %w", err)
+	}
+	run.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// List returns the runs started since a time, newest first, of one task or of every task when task is empty
+func (r *taskRunRepository) List(task string, since time.Time, limit int) ([]*TaskRun, error) {
+	rows, err := r.db.Query(`SELECT id, task, trigger, started, finished, error FROM task_runs
+		WHERE started >= ? AND (? = '' OR task = ?) ORDER BY started DESC, id DESC LIMIT ?`,
+		since.UTC(), task, task, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query task runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*TaskRun
+	for rows.Next() {
+		run := &TaskRun{}
+		if err := rows.Scan(&run.ID, &run.Task, &run.Trigger, &run.Started, &run.Finished, &run.Error); err != nil {
+			return nil, fmt.Errorf("failed to scan task run: %w", err)
+		}
+		run.Duration = run.Finished.Sub(run.Started).Seconds()
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read task runs: %w", err)
+	}
+	return runs, nil
+}
+
+// Summary sums up each task's runs started since a time, by task name
+// Runs are few (a task runs at most every few minutes), so they're summed here rather than in SQL.
+func (r *taskRunRepository) Summary(since time.Time) ([]*TaskRunSummary, error) {
+	rows, err := r.db.Query(`SELECT task, started, finished, error FROM task_runs WHERE started >= ? ORDER BY task, started`,
+		since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query task runs: %w", err)
+	}
+	defer rows.Close()
+
+	var summaries []*TaskRunSummary
+	var current *TaskRunSummary
+	var total float64
+	for rows.Next() {
+		var task, runErr string
+		var started, finished time.Time
+		if err := rows.Scan(&task, &started, &finished, &runErr); err != nil {
+			return nil, fmt.Errorf("failed to scan task run: %w", err)
+		}
+		if current == nil || current.Task != task {
+			current = &TaskRunSummary{Task: task}
+			summaries = append(summaries, current)
+			total = 0
+		}
+		current.Runs++
+		total += finished.Sub(started).Seconds()
+		current.AvgDuration = math.Round(total/float64(current.Runs)*10) / 10
+		if runErr != "" {
+			current.Failures++
+			current.LastFailure = &started
+			current.LastError = runErr
+		} else {
+			current.LastSuccess = &started
+		}
+		current.SuccessPercent = math.Round(float64(current.Runs-current.Failures)*1000/float64(current.Runs)) / 10
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read task runs: %w", err)
+	}
+	return summaries, nil
+}
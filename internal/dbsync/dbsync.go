@@ -0,0 +1,251 @@
+// Package dbsync copies new database rows to a remote flight_trmnl database, e.g. from a Pi on a metered link to a
+// home server. Rows go a batch at a time and the remote records how far it got in the same transaction, so a sync
+// cut off by a flaky link resumes where it stopped and never copies a row twice.
+package dbsync
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"flight_trmnl/internal/database"
+)
+
+// Version is the version of the protocol spoken between the two ends
+const Version = 1
+
+const (
+	batchRows   = 1000             // Rows per batch at most
+	maxAttempts = 5                // Connections in a row that may fail before a sync gives up until its next run
+	minBackoff  = 10 * time.Second // Wait before reconnecting after a failure, doubled for each failure in a row
+	maxBackoff  = 2 * time.Minute
+)
+
+// hello opens a session, from the sending end
+type hello struct {
+	Version int    `json:"version"`
+	Source  string `json:"source"`
+}
+
+// reply is the receiving end's answer to the hello, with its cursors, and to each batch, with the last row applied
+type reply struct {
+	Cursors map[string]int64 `json:"cursors,omitempty"`
+	Table   string           `json:"table,omitempty"`
+	Last    int64            `json:"last,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+// Serve is the receiving end of a session: it reads a hello and then batches from r, a gzip stream, applies them,
+// and acknowledges each on w. It returns when r ends.
+func Serve(repo database.SyncRepository, r io.Reader, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	fail := func(err error) error {
+		enc.Encode(reply{Error: err.Error()})
+		return err
+	}
+
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to read sync stream: %w", err)
+	}
+	dec := json.NewDecoder(zr)
+	dec.UseNumber() // Integers arrive whole, see database.SyncBatch
+
+	var h hello
+	if err := dec.Decode(&h); err != nil {
+		return fmt.Errorf("failed to read sync hello: %w", err)
+	}
+	if h.Version != Version {
+		return fail(fmt.Errorf("sync protocol version %d isn't supported, this end speaks %d", h.Version, Version))
+	}
+	if h.Source == "" {
+		return fail(errors.New("sync source is required"))
+	}
+	cursors, err := repo.Cursors(h.Source)
+	if err != nil {
+		return fail(err)
+	}
+	if err := enc.Encode(reply{Cursors: cursors}); err != nil {
+		return err
+	}
+
+	for {
+		var batch database.SyncBatch
+		if err := dec.Decode(&batch); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read sync batch: %w", err)
+		}
+		last, err := repo.Apply(h.Source, &batch)
+		if err != nil {
+			return fail(err)
+		}
+		if err := enc.Encode(reply{Table: batch.Table, Last: last}); err != nil {
+			return err
+		}
+	}
+}
+
+// Options configures a Client
+type Options struct {
+	To            string   // ssh://[user@]host[:port]/path, or the path of a database on this machine
+	Source        string   // Name the remote keeps this database's progress under, unique per sending database
+	Tables        []string // Tables to copy, all of database.SyncTables when empty
+	RateLimit     int      // Bytes per second sent at most, 0 is unlimited
+	RemoteCommand string   // flight_trmnl on the remote host, run over ssh
+}
+
+// Client copies the rows added since its last sync to the remote
+type Client struct {
+	repo database.SyncRepository
+	opts Options
+}
+
+func NewClient(repo database.SyncRepository, opts Options) *Client {
+	if len(opts.Tables) == 0 {
+		opts.Tables = database.SyncTables
+	}
+	if opts.RemoteCommand == "" {
+		opts.RemoteCommand = "flight_trmnl"
+	}
+	return &Client{repo: repo, opts: opts}
+}
+
+// Sync copies the new rows of each table, returning how many went by table. A lost connection is retried with
+// backoff, resuming from what the remote acknowledged, until maxAttempts fail in a row; a batch the remote refuses
+// isn't retried, it would only be refused again.
+func (c *Client) Sync(ctx context.Context) (map[string]int64, error) {
+	copied := make(map[string]int64)
+	backoff := minBackoff
+	for failures := 0; ; {
+		before := total(copied)
+		err := c.session(ctx, copied)
+		var refused *remoteError
+		if err == nil || ctx.Err() != nil || errors.As(err, &refused) {
+			return copied, err
+		}
+		if total(copied) > before {
+			failures, backoff = 0, minBackoff // Progress was made, so the link works at least some of the time
+		}
+		if failures++; failures >= maxAttempts {
+			return copied, err
+		}
+		slog.Warn("Database sync interrupted, reconnecting", "to", c.opts.To, "retry_in", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return copied, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// Run is the sync task
+func (c *Client) Run(ctx context.Context) error {
+	copied, err := c.Sync(ctx)
+	if err != nil {
+		return err
+	}
+	args := []any{"to", c.opts.To, "rows", total(copied)}
+	for _, table := range c.opts.Tables {
+		if copied[table] > 0 {
+			args = append(args, table, copied[table])
+		}
+	}
+	slog.Info("Synced database", args...)
+	return nil
+}
+
+// session connects once and sends batches until every table is caught up
+func (c *Client) session(ctx context.Context, copied map[string]int64) (err error) {
+	conn, err := dial(ctx, c.opts.To, c.opts.RemoteCommand)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := conn.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	zw := gzip.NewWriter(newLimitedWriter(ctx, conn, c.opts.RateLimit))
+	enc := json.NewEncoder(zw)
+	send := func(v any) error {
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("failed to send to sync remote: %w", err)
+		}
+		if err := zw.Flush(); err != nil {
+			return fmt.Errorf("failed to send to sync remote: %w", err)
+		}
+		return nil
+	}
+	dec := json.NewDecoder(conn)
+	receive := func() (*reply, error) {
+		var r reply
+		if err := dec.Decode(&r); err != nil {
+			return nil, fmt.Errorf("failed to read from sync remote: %w", err)
+		}
+		if r.Error != "" {
+			return nil, &remoteError{msg: r.Error}
+		}
+		return &r, nil
+	}
+
+	if err := send(hello{Version: Version, Source: c.opts.Source}); err != nil {
+		return err
+	}
+	welcome, err := receive()
+	if err != nil {
+		return err
+	}
+	for _, table := range c.opts.Tables {
+		after := welcome.Cursors[table]
+		for {
+			batch, err := c.repo.Read(table, after, batchRows)
+			if err != nil {
+				return err
+			}
+			if len(batch.Rows) == 0 {
+				break
+			}
+			if err := send(batch); err != nil {
+				return err
+			}
+			ack, err := receive()
+			if err != nil {
+				return err
+			}
+			if ack.Table != table || ack.Last != batch.Last() {
+				return fmt.Errorf("sync remote acknowledged %s up to %d, expected %s up to %d", ack.Table, ack.Last, table, batch.Last())
+			}
+			copied[table] += int64(len(batch.Rows))
+			after = ack.Last
+			if len(batch.Rows) < batchRows {
+				break
+			}
+		}
+	}
+	return zw.Close()
+}
+
+// remoteError is an error the receiving end reported
+type remoteError struct {
+	msg string
+}
+
+func (e *remoteError) Error() string {
+	return "sync remote: " + e.msg
+}
+
+func total(copied map[string]int64) int64 {
+	var n int64
+	for _, rows := range copied {
+		n += rows
+	}
+	return n
+}
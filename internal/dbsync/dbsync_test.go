@@ -0,0 +1,124 @@
+package dbsync
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+)
+
+func openDB(t *testing.T, path string) *database.DB {
+	t.Helper()
+	db, err := database.New(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func insertEvents(t *testing.T, db *database.DB, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		require.NoError(t, db.EventRepository().Insert(&models.Event{Time: time.Now(), Type: "alert", Severity: "info", Message: "seen"}))
+	}
+}
+
+func countEvents(t *testing.T, path string) int {
+	t.Helper()
+	db := openDB(t, path)
+	var n int
+	require.NoError(t, db.DB().QueryRow(`SELECT COUNT(*) FROM events`).Scan(&n))
+	return n
+}
+
+func TestClient_SyncsNewRows(t *testing.T) {
+	dir := t.TempDir()
+	local := openDB(t, filepath.Join(dir, "local.db"))
+	remote := filepath.Join(dir, "remote.db")
+	client := NewClient(local.SyncRepository(), Options{To: remote, Source: "pi-north", Tables: []string{"events", "flights"}})
+
+	insertEvents(t, local, batchRows+5)
+	copied, err := client.Sync(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"events": batchRows + 5}, copied)
+	assert.Equal(t, batchRows+5, countEvents(t, remote))
+
+	insertEvents(t, local, 3)
+	copied, err = client.Sync(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"events": 3}, copied, "only rows added since")
+	assert.Equal(t, batchRows+8, countEvents(t, remote))
+
+	copied, err = client.Sync(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, copied)
+}
+
+func TestServe_RejectsOtherVersions(t *testing.T) {
+	remote := openDB(t, filepath.Join(t.TempDir(), "remote.db"))
+	var in, out bytes.Buffer
+	zw := gzip.NewWriter(&in)
+	_, err := zw.Write([]byte(`{"version":2,"source":"pi"}` + "\n"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	err = Serve(remote.SyncRepository(), &in, &out)
+	assert.ErrorContains(t, err, "sync protocol version 2 isn't supported")
+	assert.Contains(t, out.String(), `"error":"sync protocol version 2`)
+}
+
+func TestParseDestination(t *testing.T) {
+	assert.NoError(t, ParseDestination("ssh://pi@home.example.com:2222/srv/flight_trmnl.db"))
+	assert.NoError(t, ParseDestination("ssh://home/~/flight_trmnl.db"))
+	assert.NoError(t, ParseDestination("/mnt/nas/flight_trmnl.db"))
+	assert.Error(t, ParseDestination(""))
+	assert.Error(t, ParseDestination("ssh://home"))
+	assert.Error(t, ParseDestination("https://home/flight_trmnl.db"))
+	assert.ErrorContains(t, ParseDestination("ssh://-oProxyCommand=touch/srv/flight_trmnl.db"), "starts with -")
+	assert.ErrorContains(t, ParseDestination("ssh://-oProxyCommand=x@home/srv/flight_trmnl.db"), "starts with -")
+}
+
+func TestSSHArgs(t *testing.T) {
+	u, err := url.Parse("ssh://pi@home.example.com:2222/~/flight_trmnl.db")
+	require.NoError(t, err)
+	args, err := sshArgs(u, "flight_trmnl")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"-o", "BatchMode=yes", "-o", "ServerAliveInterval=15", "-o", "ServerAliveCountMax=4", "-p", "2222",
+		"--", "pi@home.example.com", "flight_trmnl sync serve ~/'flight_trmnl.db'",
+	}, args)
+
+	u, err = url.Parse("ssh://-oProxyCommand=x/srv/flight_trmnl.db")
+	require.NoError(t, err)
+	_, err = sshArgs(u, "flight_trmnl")
+	assert.Error(t, err)
+}
+
+func TestShellQuote(t *testing.T) {
+	assert.Equal(t, `'/srv/flight data.db'`, shellQuote("/srv/flight data.db"))
+	assert.Equal(t, `~/'it'\''s.db'`, shellQuote("~/it's.db"))
+}
+
+func TestLimitedWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := newLimitedWriter(context.Background(), &buf, 10000)
+	start := time.Now()
+	n, err := w.Write(make([]byte, 3000))
+	require.NoError(t, err)
+	assert.Equal(t, 3000, n)
+	assert.InDelta(t, 300*time.Millisecond, time.Since(start), float64(100*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = newLimitedWriter(ctx, io.Discard, 10).Write(make([]byte, 100))
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,223 @@
+package dbsync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/database"
+)
+
+// dial connects to the receiving end at to: over ssh, running "sync serve" on the remote host like rsync does, or
+// in this process for a database on this machine, e.g. on a mounted network share. Writes go to the receiving end,
+// reads come from it, and Close ends the session and waits for the receiving end to finish.
+func dial(ctx context.Context, to, remoteCommand string) (io.ReadWriteCloser, error) {
+	if !strings.HasPrefix(to, "ssh://") {
+		return dialLocal(to)
+	}
+	u, err := url.Parse(to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sync destination: %w", err)
+	}
+	return dialSSH(ctx, u, remoteCommand)
+}
+
+// ParseDestination checks a sync destination: ssh://[user@]host[:port]/path or a local path
+func ParseDestination(to string) error {
+	switch {
+	case to == "":
+		return fmt.Errorf("sync destination is required")
+	case strings.HasPrefix(to, "ssh://"):
+		u, err := url.Parse(to)
+		if err != nil {
+			return fmt.Errorf("invalid sync destination: %w", err)
+		}
+		if u.Hostname() == "" || remotePath(u) == "" {
+			return fmt.Errorf("sync destination %s needs a host and a database path, e.g. ssh://user@host/srv/flight_trmnl.db", to)
+		}
+		if _, err := sshTarget(u); err != nil {
+			return err
+		}
+	case strings.Contains(to, "://"):
+		return fmt.Errorf("sync destination %s must be ssh:// or a local path", to)
+	}
+	return nil
+}
+
+// remotePath is the database path of an ssh destination; a path starting with /~/ is relative to the home directory
+func remotePath(u *url.URL) string {
+	if strings.HasPrefix(u.Path, "/~/") {
+		return u.Path[1:]
+	}
+	return u.Path
+}
+
+type sshConn struct {
+	io.Reader
+	stdin  io.WriteCloser
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+}
+
+// sshTarget is the [user@]host argument for ssh; one starting with - would be read as an option
+func sshTarget(u *url.URL) (string, error) {
+	host := u.Hostname()
+	if u.User != nil {
+		host = u.User.Username() + "@" + host
+	}
+	if strings.HasPrefix(host, "-") {
+		return "", fmt.Errorf("invalid sync destination: host %s starts with -", host)
+	}
+	return host, nil
+}
+
+// sshArgs builds the ssh command line that runs remoteCommand's sync serve on the destination
+func sshArgs(u *url.URL, remoteCommand string) ([]string, error) {
+	host, err := sshTarget(u)
+	if err != nil {
+		return nil, err
+	}
+	// Fail instead of prompting for a password, and notice a dead link within a minute
+	args := []string{"-o", "BatchMode=yes", "-o", "ServerAliveInterval=15", "-o", "ServerAliveCountMax=4"}
+	if port := u.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+	// -- ends the options, so nothing after it is taken for one
+	return append(args, "--", host, remoteCommand+" sync serve "+shellQuote(remotePath(u))), nil
+}
+
+func dialSSH(ctx context.Context, u *url.URL, remoteCommand string) (io.ReadWriteCloser, error) {
+	args, err := sshArgs(u, remoteCommand)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, "ssh", args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start ssh: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start ssh: %w", err)
+	}
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ssh: %w", err)
+	}
+	return &sshConn{Reader: stdout, stdin: stdin, cmd: cmd, stderr: stderr}, nil
+}
+
+func (c *sshConn) Write(p []byte) (int, error) {
+	return c.stdin.Write(p)
+}
+
+func (c *sshConn) Close() error {
+	c.stdin.Close()
+	if err := c.cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(c.stderr.String()); msg != "" {
+			return fmt.Errorf("ssh failed: %w: %s", err, msg)
+		}
+		return fmt.Errorf("ssh failed: %w", err)
+	}
+	return nil
+}
+
+// shellQuote quotes a path for the remote shell, leaving a leading ~/ for it to expand
+func shellQuote(path string) string {
+	prefix := ""
+	if strings.HasPrefix(path, "~/") {
+		prefix, path = "~/", path[2:]
+	}
+	return prefix + "'" + strings.ReplaceAll(path, "'", `'\''`) + "'"
+}
+
+type localConn struct {
+	*io.PipeReader
+	w    *io.PipeWriter
+	done chan error
+}
+
+func dialLocal(path string) (io.ReadWriteCloser, error) {
+	db, err := database.New(path)
+	if err != nil {
+		return nil, err
+	}
+	toServer, fromClient := io.Pipe()
+	toClient, fromServer := io.Pipe()
+	c := &localConn{PipeReader: toClient, w: fromClient, done: make(chan error, 1)}
+	go func() {
+		err := Serve(db.SyncRepository(), toServer, fromServer)
+		toServer.CloseWithError(err) // Unblocks the client if it is still writing
+		fromServer.CloseWithError(err)
+		db.Close()
+		c.done <- err
+	}()
+	return c, nil
+}
+
+func (c *localConn) Write(p []byte) (int, error) {
+	return c.w.Write(p)
+}
+
+func (c *localConn) Close() error {
+	c.w.Close()
+	c.PipeReader.Close() // Unblocks the receiving end if it is still answering
+	return <-c.done
+}
+
+// limitedWriter paces writes to rate bytes per second on average
+type limitedWriter struct {
+	ctx     context.Context
+	w       io.Writer
+	rate    int
+	start   time.Time
+	written int64
+}
+
+// newLimitedWriter limits writes to w to rate bytes per second, or returns w when rate is 0
+func newLimitedWriter(ctx context.Context, w io.Writer, rate int) io.Writer {
+	if rate <= 0 {
+		return w
+	}
+	return &limitedWriter{ctx: ctx, w: w, rate: rate, start: time.Now()}
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	var total int
+	// Time spent waiting for the other end doesn't save up for a burst afterwards
+	if due := l.due(); time.Since(due) > time.Second {
+		l.start, l.written = time.Now(), 0
+	}
+	for len(p) > 0 {
+		// A tenth of a second's worth at a time keeps the link from seeing bursts
+		chunk := p[:min(len(p), max(l.rate/10, 1))]
+		n, err := l.w.Write(chunk)
+		total += n
+		l.written += int64(n)
+		if err != nil {
+			return total, err
+		}
+		p = p[n:]
+
+		if wait := time.Until(l.due()); wait > 0 {
+			select {
+			case <-l.ctx.Done():
+				return total, l.ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+	}
+	return total, nil
+}
+
+// due is when the bytes written so far are paid for
+func (l *limitedWriter) due() time.Time {
+	return l.start.Add(time.Duration(float64(l.written) / float64(l.rate) * float64(time.Second)))
+}
@@ -0,0 +1,113 @@
+package decoder
+
+import "fmt"
+
+// Sources of an extended squitter, from its downlink format and, for DF18, its control field
+const (
+	SourceADSB = "adsb" // Broadcast by the aircraft or vehicle itself
+	SourceTISB = "tisb" // Traffic information a ground station broadcasts from radar or other surveillance
+	SourceADSR = "adsr" // ADS-B a ground station rebroadcasts from another data link, such as UAT
+)
+
+// Kinds of address an extended squitter carries
+const (
+	AddressICAO      = "icao"      // An aircraft's or vehicle's ICAO address
+	AddressAnonymous = "anonymous" // Self-assigned by a device without one, or hiding its own, e.g. a privacy address
+	AddressTrackFile = "trackfile" // A track file number or Mode A code a TIS-B ground station gave a radar target
+)
+
+// Address returns the address a DF17 or DF18 frame describes and where the frame came from. An address that isn't
+// an aircraft's ICAO address, such as an anonymous one or a TIS-B track number, is prefixed with ~ like readsb
+// does, so it can't be mistaken for one. ok is false for other frames, and for DF18 management messages and
+// reserved control fields, which describe no aircraft.
+func Address(frame []byte) (address, source string, ok bool) {
+	address, source, kind := classifyAddress(frame)
+	if kind != AddressICAO && kind != "" {
+		address = "~" + address
+	}
+	return address, source, kind != ""
+}
+
+// AddressKind returns the kind of address a DF17 or DF18 frame carries, e.g. AddressTrackFile, "" for frames that
+// describe no aircraft as for Address
+func AddressKind(frame []byte) string {
+	_, _, kind := classifyAddress(frame)
+	return kind
+}
+
+// classifyAddress returns the address field of a DF17 or DF18 frame, where the frame came from, and the kind of
+// address it is, "" when the frame describes no aircraft
+func classifyAddress(frame []byte) (address, source, kind string) {
+	if len(frame) != frameLen {
+		return "", "", ""
+	}
+	aa := fmt.Sprintf("%06X", bits(frame, 9, 32))
+	switch bits(frame, 1, 5) {
+	case 17:
+		return aa, SourceADSB, AddressICAO
+	case 18:
+	default:
+		return "", "", ""
+	}
+
+	me := func(first, last int) uint64 { return bits(frame, 32+first, 32+last) }
+	switch cf := bits(frame, 6, 8); cf {
+	case 0:
+		return aa, SourceADSB, AddressICAO
+	case 1:
+		return aa, SourceADSB, AddressAnonymous
+	case 2:
+		if imf(me) {
+			return aa, SourceTISB, AddressTrackFile
+		}
+		return aa, SourceTISB, AddressICAO
+	case 3:
+		// Coarse TIS-B starts with its IMF bit
+		if me(1, 1) == 1 {
+			return aa, SourceTISB, AddressTrackFile
+		}
+		return aa, SourceTISB, AddressICAO
+	case 5:
+		return aa, SourceTISB, AddressTrackFile
+	case 6:
+		// ADS-R relays the target's own address, which for a UAT target may be self-assigned
+		if imf(me) {
+			return aa, SourceADSR, AddressAnonymous
+		}
+		return aa, SourceADSR, AddressICAO
+	default:
+		return "", "", ""
+	}
+}
+
+// imf reports whether a fine TIS-B or ADS-R message sets its ICAO/Mode A flag, meaning the address isn't an ICAO
+// address. The flag takes a bit that DF17 uses for something else, and only position and velocity messages have it.
+func imf(me func(first, last int) uint64) bool {
+	switch tc := me(1, 5); {
+	case tc >= 5 && tc <= 8:
+		return me(21, 21) == 1
+	case tc >= 9 && tc <= 18, tc >= 20 && tc <= 22:
+		return me(8, 8) == 1
+	case tc == 19:
+		return me(9, 9) == 1
+	}
+	return false
+}
+
+// IsExtendedSquitter reports whether a frame is a DF17, or a DF18 whose ME field is laid out the same: from a
+// non-transponder device, fine TIS-B, or ADS-R. Coarse TIS-B and management messages are laid out differently.
+func IsExtendedSquitter(frame []byte) bool {
+	if len(frame) != frameLen {
+		return false
+	}
+	switch bits(frame, 1, 5) {
+	case 17:
+		return true
+	case 18:
+		switch bits(frame, 6, 8) {
+		case 0, 1, 2, 5, 6:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package decoder
+
+import "fmt"
+
+// DecodeID13 reorders the 13-bit identity field (C1 A1 C2 A2 C4 A4 X B1 D1 B2 D2 B4 D4)
+// into Mode A digits, one octal digit per 3 bits: A4 A2 A1 B4 B2 B1 C4 C2 C1 D4 D2 D1
+func DecodeID13(id uint64) int {
+	bit := func(n uint) int { return int(id>>n) & 1 }
+	a := bit(7)<<2 | bit(9)<<1 | bit(11)
+	b := bit(1)<<2 | bit(3)<<1 | bit(5)
+	c := bit(8)<<2 | bit(10)<<1 | bit(12)
+	dd := bit(0)<<2 | bit(2)<<1 | bit(4)
+	return a<<9 | b<<6 | c<<3 | dd
+}
+
+// DecodeAC13 decodes a 13-bit altitude code in feet: in 25 ft steps when the Q bit is set,
+// otherwise in 100 ft steps of Gillham code. Metric altitudes (M bit set) aren't decoded.
+func DecodeAC13(ac uint64) (int, bool) {
+	if ac == 0 || ac&0x40 != 0 {
+		return 0, false
+	}
+	if ac&0x10 != 0 {
+		n := (ac&0x1F80)>>2 | (ac&0x20)>>1 | ac&0x0F
+		return int(n)*25 - 1000, true
+	}
+	hundreds, ok := gillhamAltitude(DecodeID13(ac))
+	return hundreds * 100, ok
+}
+
+// gillhamAltitude converts Mode A style digits carrying a Gillham coded altitude to hundreds of feet.
+// The D1 bit is the Q bit in the AC field and is clear here.
+func gillhamAltitude(code int) (int, bool) {
+	a, b, c, dd := code>>9&7, code>>6&7, code>>3&7, code&7
+	if c == 0 || dd&1 != 0 {
+		return 0, false
+	}
+
+	// The 500 ft increments are a Gray code over D2 D4 A1 A2 A4 B1 B2 B4
+	gray := (dd>>1&1)<<7 | (dd>>2&1)<<6 | (a&1)<<5 | (a>>1&1)<<4 | (a>>2&1)<<3 | (b&1)<<2 | (b>>1&1)<<1 | b>>2&1
+	fiveHundreds := 0
+	for g := gray; g != 0; g >>= 1 {
+		fiveHundreds ^= g
+	}
+
+	// The 100 ft increments are a reflected code over C1 C2 C4
+	oneHundreds := 0
+	for g := (c&1)<<2 | (c>>1&1)<<1 | c>>2&1; g != 0; g >>= 1 {
+		oneHundreds ^= g
+	}
+	if oneHundreds == 5 || oneHundreds == 6 {
+		return 0, false
+	}
+	if oneHundreds == 7 {
+		oneHundreds = 5
+	}
+	if fiveHundreds&1 != 0 {
+		oneHundreds = 6 - oneHundreds
+	}
+	return fiveHundreds*5 + oneHundreds - 13, true
+}
+
+// ModeAC is a decoded Mode A/C reply. The same reply answers a Mode A (identity) or a Mode C (altitude)
+// interrogation and nothing in it says which, so it is read both ways.
+type ModeAC struct {
+	Squawk   int  // Mode A digits, one octal digit per 3 bits as DecodeID13 returns them
+	SPI      bool // The special position identification pulse, sent while the pilot presses ident
+	Altitude *int // Feet, when the code is also a valid Gillham altitude
+}
+
+// DecodeModeAC decodes a 2-byte Mode A/C reply as dump1090 forwards it in Beast format: one hex digit per Mode A
+// digit (0xABCD, each 0-7), with the SPI pulse in bit 7.
+func DecodeModeAC(reply []byte) (*ModeAC, error) {
+	if len(reply) != 2 {
+		return nil, fmt.Errorf("a Mode A/C reply is 2 bytes, got %d", len(reply))
+	}
+	v := int(reply[0])<<8 | int(reply[1])
+	m := &ModeAC{
+		Squawk: (v>>12&7)<<9 | (v>>8&7)<<6 | (v>>4&7)<<3 | v&7,
+		SPI:    v&0x80 != 0,
+	}
+	// A reply with the ident pulse or stray bits between the digits can only be a Mode A reply
+	if v&0x8888 == 0 {
+		if hundreds, ok := gillhamAltitude(m.Squawk); ok {
+			feet := hundreds * 100
+			m.Altitude = &feet
+		}
+	}
+	return m, nil
+}
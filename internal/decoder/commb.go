@@ -0,0 +1,269 @@
+package decoder
+
+import "errors"
+
+// Errors returned by DecodeCommB
+var (
+	ErrNotCommB        = errors.New("not a DF20/DF21 Comm-B reply")
+	ErrUnknownRegister = errors.New("Comm-B register not identified")
+	ErrAmbiguousBDS    = errors.New("Comm-B register ambiguous, the reply fits more than one")
+)
+
+// Comm-B Data Selector registers that are identified
+const (
+	BDS40 = "4,0" // Selected vertical intention
+	BDS50 = "5,0" // Track and turn report
+	BDS60 = "6,0" // Heading and speed report
+)
+
+// CommB is the decoded MB field of a Comm-B reply. Only the part for its register is set.
+// Replies don't say which register they carry, the ground station asked for it. The register is inferred from
+// which layouts the bits fit: unused fields must be zero, reserved bits clear, and the values plausible. So an
+// identification can be wrong, particularly for replies with few fields set.
+type CommB struct {
+	BDS string // e.g. 4,0
+
+	SelectedVertical *SelectedVertical // BDS 4,0
+	TrackAndTurn     *TrackAndTurn     // BDS 5,0
+	HeadingAndSpeed  *HeadingAndSpeed  // BDS 6,0
+}
+
+// SelectedVertical is BDS 4,0, the altitude the crew selected
+type SelectedVertical struct {
+	MCPAltitude *int     // Feet, selected on the mode control panel or flight control unit
+	FMSAltitude *int     // Feet, selected in the flight management system
+	BaroSetting *float64 // Millibars
+}
+
+// TrackAndTurn is BDS 5,0
+type TrackAndTurn struct {
+	Roll         *float64 // Degrees, negative when left wing down
+	Track        *float64 // Degrees true
+	GroundSpeed  *int     // Knots
+	TrackRate    *float64 // Degrees per second, negative when turning left
+	TrueAirspeed *int     // Knots
+}
+
+// HeadingAndSpeed is BDS 6,0
+type HeadingAndSpeed struct {
+	Heading           *float64 // Degrees magnetic
+	IndicatedAirspeed *int     // Knots
+	Mach              *float64
+	BaroVerticalRate  *int // Feet per minute, negative when descending
+	InertialRate      *int // Feet per minute, from inertial or GNSS sources
+}
+
+// DecodeCommB identifies and decodes the register in a DF20 or DF21 Comm-B reply. The parity, overlaid with the
+// aircraft address, can't be checked on its own.
+func DecodeCommB(frame []byte) (*CommB, error) {
+	if len(frame) != frameLen {
+		return nil, ErrNotCommB
+	}
+	if df := bits(frame, 1, 5); df != 20 && df != 21 {
+		return nil, ErrNotCommB
+	}
+	mb := func(first, last int) uint64 { return bits(frame, 32+first, 32+last) }
+	if mb(1, 56) == 0 {
+		return nil, ErrUnknownRegister
+	}
+
+	var candidates []*CommB
+	if v, ok := decodeBDS40(mb); ok {
+		candidates = append(candidates, &CommB{BDS: BDS40, SelectedVertical: v})
+	}
+	if v, ok := decodeBDS50(mb); ok {
+		candidates = append(candidates, &CommB{BDS: BDS50, TrackAndTurn: v})
+	}
+	if v, ok := decodeBDS60(mb); ok {
+		candidates = append(candidates, &CommB{BDS: BDS60, HeadingAndSpeed: v})
+	}
+	switch len(candidates) {
+	case 0:
+		return nil, ErrUnknownRegister
+	case 1:
+		return candidates[0], nil
+	default:
+		return nil, ErrAmbiguousBDS
+	}
+}
+
+// field reads a field with a status bit in front of it; the value bits must be zero when the status is clear
+func field(mb func(first, last int) uint64, status, first, last int) (value uint64, set, ok bool) {
+	value = mb(first, last)
+	if mb(status, status) == 0 {
+		return 0, false, value == 0
+	}
+	return value, true, true
+}
+
+// signed reads a two's complement value whose sign bit comes right before it
+func signed(mb func(first, last int) uint64, sign, first, last int) int {
+	v := int(mb(first, last))
+	if mb(sign, sign) == 1 {
+		v -= 1 << (last - first + 1)
+	}
+	return v
+}
+
+func decodeBDS40(mb func(first, last int) uint64) (*SelectedVertical, bool) {
+	// Reserved bits
+	if mb(40, 47) != 0 || mb(52, 53) != 0 {
+		return nil, false
+	}
+	if _, _, ok := field(mb, 48, 49, 51); !ok {
+		return nil, false
+	}
+	if _, _, ok := field(mb, 54, 55, 56); !ok {
+		return nil, false
+	}
+
+	v := &SelectedVertical{}
+	mcp, set, ok := field(mb, 1, 2, 13)
+	if !ok {
+		return nil, false
+	}
+	if set {
+		feet := int(mcp) * 16
+		v.MCPAltitude = &feet
+	}
+	fms, set, ok := field(mb, 14, 15, 26)
+	if !ok {
+		return nil, false
+	}
+	if set {
+		feet := int(fms) * 16
+		v.FMSAltitude = &feet
+	}
+	baro, set, ok := field(mb, 27, 28, 39)
+	if !ok {
+		return nil, false
+	}
+	if set {
+		mbar := float64(baro)*0.1 + 800
+		v.BaroSetting = &mbar
+	}
+
+	if v.MCPAltitude == nil && v.FMSAltitude == nil && v.BaroSetting == nil {
+		return nil, false
+	}
+	for _, alt := range []*int{v.MCPAltitude, v.FMSAltitude} {
+		if alt != nil && (*alt <= 0 || *alt > 50000) {
+			return nil, false
+		}
+	}
+	if v.BaroSetting != nil && (*v.BaroSetting < 900 || *v.BaroSetting > 1100) {
+		return nil, false
+	}
+	return v, true
+}
+
+func decodeBDS50(mb func(first, last int) uint64) (*TrackAndTurn, bool) {
+	v := &TrackAndTurn{}
+	if _, set, ok := field(mb, 1, 2, 11); !ok {
+		return nil, false
+	} else if set {
+		roll := float64(signed(mb, 2, 3, 11)) * 45 / 256
+		v.Roll = &roll
+	}
+	if _, set, ok := field(mb, 12, 13, 23); !ok {
+		return nil, false
+	} else if set {
+		track := mod(float64(signed(mb, 13, 14, 23))*90/512, 360)
+		v.Track = &track
+	}
+	if gs, set, ok := field(mb, 24, 25, 34); !ok {
+		return nil, false
+	} else if set {
+		knots := int(gs) * 2
+		v.GroundSpeed = &knots
+	}
+	if _, set, ok := field(mb, 35, 36, 45); !ok {
+		return nil, false
+	} else if set {
+		rate := float64(signed(mb, 36, 37, 45)) * 8 / 256
+		v.TrackRate = &rate
+	}
+	if tas, set, ok := field(mb, 46, 47, 56); !ok {
+		return nil, false
+	} else if set {
+		knots := int(tas) * 2
+		v.TrueAirspeed = &knots
+	}
+
+	if v.Roll == nil && v.Track == nil && v.GroundSpeed == nil && v.TrackRate == nil && v.TrueAirspeed == nil {
+		return nil, false
+	}
+	if v.Roll != nil && (*v.Roll < -50 || *v.Roll > 50) {
+		return nil, false
+	}
+	if v.GroundSpeed != nil && (*v.GroundSpeed == 0 || *v.GroundSpeed > 600) {
+		return nil, false
+	}
+	if v.TrueAirspeed != nil && (*v.TrueAirspeed == 0 || *v.TrueAirspeed > 600) {
+		return nil, false
+	}
+	if v.GroundSpeed != nil && v.TrueAirspeed != nil && abs(*v.GroundSpeed-*v.TrueAirspeed) > 200 {
+		return nil, false
+	}
+	return v, true
+}
+
+func decodeBDS60(mb func(first, last int) uint64) (*HeadingAndSpeed, bool) {
+	v := &HeadingAndSpeed{}
+	if _, set, ok := field(mb, 1, 2, 12); !ok {
+		return nil, false
+	} else if set {
+		heading := mod(float64(signed(mb, 2, 3, 12))*90/512, 360)
+		v.Heading = &heading
+	}
+	if ias, set, ok := field(mb, 13, 14, 23); !ok {
+		return nil, false
+	} else if set {
+		knots := int(ias)
+		v.IndicatedAirspeed = &knots
+	}
+	if mach, set, ok := field(mb, 24, 25, 34); !ok {
+		return nil, false
+	} else if set {
+		m := float64(mach) * 2.048 / 512
+		v.Mach = &m
+	}
+	if _, set, ok := field(mb, 35, 36, 45); !ok {
+		return nil, false
+	} else if set {
+		rate := signed(mb, 36, 37, 45) * 32
+		v.BaroVerticalRate = &rate
+	}
+	if _, set, ok := field(mb, 46, 47, 56); !ok {
+		return nil, false
+	} else if set {
+		rate := signed(mb, 47, 48, 56) * 32
+		v.InertialRate = &rate
+	}
+
+	if v.Heading == nil && v.IndicatedAirspeed == nil && v.Mach == nil && v.BaroVerticalRate == nil && v.InertialRate == nil {
+		return nil, false
+	}
+	if v.IndicatedAirspeed != nil && (*v.IndicatedAirspeed == 0 || *v.IndicatedAirspeed > 500) {
+		return nil, false
+	}
+	if v.Mach != nil && (*v.Mach == 0 || *v.Mach > 1) {
+		return nil, false
+	}
+	for _, rate := range []*int{v.BaroVerticalRate, v.InertialRate} {
+		if rate != nil && abs(*rate) > 6000 {
+			return nil, false
+		}
+	}
+	if v.BaroVerticalRate != nil && v.InertialRate != nil && abs(*v.BaroVerticalRate-*v.InertialRate) > 2000 {
+		return nil, false
+	}
+	return v, true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
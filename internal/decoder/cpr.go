@@ -0,0 +1,211 @@
+package decoder
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// pairWindow is how far apart an even and an odd airborne frame may be to decode a position from the pair;
+	// further apart the aircraft may have crossed into another zone
+	pairWindow = 10 * time.Second
+	// localWindow is how long a decoded position serves as the reference for decoding single frames, which only
+	// works while the aircraft is within half a zone of it
+	localWindow = time.Minute
+	// maxRange is how far from the receiver a position may be, in kilometres; further means a bad decode
+	maxRange = 700
+)
+
+// Position is a decoded latitude and longitude in decimal degrees
+type Position struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// cprFrame is a CPR position and when it was received
+type cprFrame struct {
+	cpr  CPR
+	time time.Time
+}
+
+// aircraftCPR is what Positions remembers about one aircraft
+type aircraftCPR struct {
+	even, odd cprFrame // Last airborne frames of each format
+	last      Position
+	lastTime  time.Time // When last was decoded, zero before the first position
+}
+
+// Positions decodes the CPR positions of each aircraft. Airborne positions need an even and an odd frame close
+// together for the first fix, then each frame decodes on its own relative to the last position. Surface positions
+// only decode relative to a reference: the aircraft's last position, or else the receiver's location.
+type Positions struct {
+	mu       sync.Mutex
+	receiver *Position
+	aircraft map[string]*aircraftCPR
+}
+
+// NewPositions creates a position decoder; receiver is the receiver's location, nil when not known
+func NewPositions(receiver *Position) *Positions {
+	return &Positions{receiver: receiver, aircraft: make(map[string]*aircraftCPR)}
+}
+
+// Resolve decodes the position of a position message from aircraft icao received at t; ok is false when the
+// message isn't a position or there isn't enough to decode it yet
+func (p *Positions) Resolve(icao string, t time.Time, m *Message) (Position, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	a := p.aircraft[icao]
+	if a == nil {
+		a = &aircraftCPR{}
+		p.aircraft[icao] = a
+	}
+	var pos Position
+	var ok bool
+	switch {
+	case m.AirbornePosition != nil:
+		pos, ok = p.airborne(a, t, m.AirbornePosition.CPR)
+	case m.SurfacePosition != nil:
+		pos, ok = p.surface(a, t, m.SurfacePosition.CPR)
+	}
+	if !ok || !p.inRange(pos) {
+		return Position{}, false
+	}
+	a.last, a.lastTime = pos, t
+	return pos, true
+}
+
+// Forget drops what is remembered about an aircraft, e.g. when it goes out of range
+func (p *Positions) Forget(icao string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.aircraft, icao)
+}
+
+func (p *Positions) airborne(a *aircraftCPR, t time.Time, cpr CPR) (Position, bool) {
+	if cpr.Odd {
+		a.odd = cprFrame{cpr, t}
+	} else {
+		a.even = cprFrame{cpr, t}
+	}
+	if !a.lastTime.IsZero() && t.Sub(a.lastTime) <= localWindow {
+		return decodeLocal(cpr, a.last, 360), true
+	}
+	if a.even.time.IsZero() || a.odd.time.IsZero() {
+		return Position{}, false
+	}
+	if d := a.even.time.Sub(a.odd.time); d > pairWindow || d < -pairWindow {
+		return Position{}, false
+	}
+	return decodeGlobal(a.even.cpr, a.odd.cpr, cpr.Odd)
+}
+
+func (p *Positions) surface(a *aircraftCPR, t time.Time, cpr CPR) (Position, bool) {
+	switch {
+	case !a.lastTime.IsZero() && t.Sub(a.lastTime) <= localWindow:
+		return decodeLocal(cpr, a.last, 90), true
+	case p.receiver != nil:
+		return decodeLocal(cpr, *p.receiver, 90), true
+	}
+	return Position{}, false
+}
+
+// inRange rejects positions implausibly far from the receiver, when its location is known
+func (p *Positions) inRange(pos Position) bool {
+	if pos.Latitude < -90 || pos.Latitude > 90 {
+		return false
+	}
+	return p.receiver == nil || Distance(*p.receiver, pos) <= maxRange
+}
+
+// decodeGlobal decodes an airborne position from an even and an odd frame, at the latitude of the newer one
+func decodeGlobal(even, odd CPR, oddNewer bool) (Position, bool) {
+	latE, latO := float64(even.Lat)/131072, float64(odd.Lat)/131072
+	lonE, lonO := float64(even.Lon)/131072, float64(odd.Lon)/131072
+
+	j := math.Floor(59*latE - 60*latO + 0.5)
+	latEven := 360.0 / 60 * (mod(j, 60) + latE)
+	latOdd := 360.0 / 59 * (mod(j, 59) + latO)
+	if latEven >= 270 {
+		latEven -= 360
+	}
+	if latOdd >= 270 {
+		latOdd -= 360
+	}
+	if nl(latEven) != nl(latOdd) {
+		return Position{}, false // The frames straddle a longitude zone boundary
+	}
+
+	lat, cprLon, n := latEven, lonE, nl(latEven)
+	if oddNewer {
+		lat, cprLon, n = latOdd, lonO, nl(latOdd)-1
+	}
+	n = max(n, 1)
+	m := math.Floor(lonE*float64(nl(lat)-1) - lonO*float64(nl(lat)) + 0.5)
+	lon := 360 / float64(n) * (mod(m, float64(n)) + cprLon)
+	if lon >= 180 {
+		lon -= 360
+	}
+	return Position{Latitude: lat, Longitude: lon}, true
+}
+
+// decodeLocal decodes a position from a single frame relative to a reference within half a zone of it; zones
+// span 360 degrees for airborne and 90 for surface positions
+func decodeLocal(cpr CPR, ref Position, span float64) Position {
+	i := 0.0
+	if cpr.Odd {
+		i = 1
+	}
+	cprLat, cprLon := float64(cpr.Lat)/131072, float64(cpr.Lon)/131072
+
+	dLat := span / (60 - i)
+	j := math.Floor(ref.Latitude/dLat) + math.Floor(mod(ref.Latitude, dLat)/dLat-cprLat+0.5)
+	lat := dLat * (j + cprLat)
+
+	dLon := span / math.Max(float64(nl(lat))-i, 1)
+	m := math.Floor(ref.Longitude/dLon) + math.Floor(mod(ref.Longitude, dLon)/dLon-cprLon+0.5)
+	lon := dLon * (m + cprLon)
+	return Position{Latitude: lat, Longitude: lon}
+}
+
+// nl is the number of longitude zones at a latitude
+func nl(lat float64) int {
+	lat = math.Abs(lat)
+	switch {
+	case lat == 0:
+		return 59
+	case lat == 87:
+		return 2
+	case lat > 87:
+		return 1
+	}
+	const nz = 15
+	a := 1 - math.Cos(math.Pi/(2*nz))
+	b := math.Pow(math.Cos(math.Pi/180*lat), 2)
+	return int(math.Floor(2 * math.Pi / math.Acos(1-a/b)))
+}
+
+// mod is the modulo that is always positive, as CPR decoding needs
+func mod(a, b float64) float64 {
+	return a - b*math.Floor(a/b)
+}
+
+// Distance returns the great circle distance between two positions in kilometres
+func Distance(a, b Position) float64 {
+	const earthRadius = 6371.0
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Bearing returns the initial great circle bearing from a to b in degrees true, 0 to 360
+func Bearing(a, b Position) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+	y := math.Sin(dLon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLon)
+	return mod(math.Atan2(y, x)*180/math.Pi, 360)
+}
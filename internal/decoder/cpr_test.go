@@ -0,0 +1,67 @@
+package decoder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPositions_Airborne(t *testing.T) {
+	positions := NewPositions(nil)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	odd := decode(t, "8D40621D58C386435CC412692AD6")
+	even := decode(t, "8D40621D58C382D690C8AC2863A7")
+
+	_, ok := positions.Resolve("40621D", start, odd)
+	assert.False(t, ok, "one frame isn't enough for a first fix")
+
+	pos, ok := positions.Resolve("40621D", start.Add(2*time.Second), even)
+	require.True(t, ok)
+	assert.InDelta(t, 52.2572, pos.Latitude, 0.0001)
+	assert.InDelta(t, 3.9194, pos.Longitude, 0.0001)
+
+	pos, ok = positions.Resolve("40621D", start.Add(3*time.Second), odd)
+	require.True(t, ok, "decoded relative to the last position")
+	assert.InDelta(t, 52.2658, pos.Latitude, 0.0001)
+	assert.InDelta(t, 3.9389, pos.Longitude, 0.0001)
+
+	positions.Forget("40621D")
+	_, ok = positions.Resolve("40621D", start.Add(4*time.Second), even)
+	assert.False(t, ok)
+	_, ok = positions.Resolve("40621D", start.Add(30*time.Second), odd)
+	assert.False(t, ok, "frames too far apart to pair")
+}
+
+func TestPositions_Surface(t *testing.T) {
+	surface := decode(t, "8C4841753A9A153237AEF0F275BE")
+	_, ok := NewPositions(nil).Resolve("484175", time.Now(), surface)
+	assert.False(t, ok, "needs a reference")
+
+	pos, ok := NewPositions(&Position{Latitude: 51.990, Longitude: 4.375}).Resolve("484175", time.Now(), surface)
+	require.True(t, ok)
+	assert.InDelta(t, 52.3206, pos.Latitude, 0.0001)
+	assert.InDelta(t, 4.7357, pos.Longitude, 0.0001)
+}
+
+func TestPositions_OutOfRange(t *testing.T) {
+	positions := NewPositions(&Position{Latitude: 40.6413, Longitude: -73.7781}) // JFK, far from the Netherlands
+	now := time.Now()
+	positions.Resolve("40621D", now, decode(t, "8D40621D58C386435CC412692AD6"))
+	_, ok := positions.Resolve("40621D", now, decode(t, "8D40621D58C382D690C8AC2863A7"))
+	assert.False(t, ok)
+}
+
+func TestDistance(t *testing.T) {
+	amsterdam := Position{Latitude: 52.3086, Longitude: 4.7639}
+	london := Position{Latitude: 51.4700, Longitude: -0.4543}
+	assert.InDelta(t, 371, Distance(amsterdam, london), 2)
+}
+
+func TestBearing(t *testing.T) {
+	origin := Position{Latitude: 52, Longitude: 4}
+	assert.InDelta(t, 0, Bearing(origin, Position{Latitude: 52.1, Longitude: 4}), 0.01)
+	assert.InDelta(t, 90, Bearing(origin, Position{Latitude: 52, Longitude: 4.1}), 0.1)
+	assert.InDelta(t, 270, Bearing(origin, Position{Latitude: 52, Longitude: 3.9}), 0.1)
+}
@@ -0,0 +1,325 @@
+package decoder
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"flight_trmnl/pkg/schema"
+)
+
+// Errors returned by Decode
+var (
+	ErrNotExtendedSquitter = errors.New("not a DF17/DF18 extended squitter")
+	ErrNotDecoded          = errors.New("type code not decoded")
+)
+
+// callsignChars maps the 6-bit characters of an identification message
+const callsignChars = "#ABCDEFGHIJKLMNOPQRSTUVWXYZ##### ###############0123456789######"
+
+// frameLen is the length of an extended squitter in bytes
+const frameLen = 14
+
+// emergencyStates names the emergency states of an emergency/priority status message; 0 is none, 7 reserved
+var emergencyStates = []string{"", "general", "lifeguard", "minimum_fuel", "no_communications", "unlawful_interference", "downed", ""}
+
+// Message is the decoded ME field of an extended squitter. Only the part for its type code is set.
+type Message struct {
+	ICAO     string // Prefixed with ~ when it isn't an ICAO address, see Address
+	Source   string // adsb, tisb, or adsr
+	TypeCode int
+
+	Identification    *Identification    // TC 1-4
+	SurfacePosition   *SurfacePosition   // TC 5-8
+	AirbornePosition  *AirbornePosition  // TC 9-18 and 20-22
+	Velocity          *Velocity          // TC 19
+	Status            *Status            // TC 28 subtype 1
+	Advisory          *schema.Advisory   // TC 28 subtype 2, without its Time
+	TargetState       *TargetState       // TC 29 subtype 1
+	OperationalStatus *OperationalStatus // TC 31
+}
+
+// Identification is an aircraft identification message
+type Identification struct {
+	Category string // ADS-B emitter category, e.g. A3; empty when not set
+	Callsign string
+}
+
+// CPR is a position in Compact Position Reporting format; it takes a second frame or a reference position to
+// become a latitude and longitude
+type CPR struct {
+	Odd bool
+	Lat int // 17 bits, a fraction of a zone
+	Lon int
+}
+
+// AirbornePosition is an airborne position message
+type AirbornePosition struct {
+	SurveillanceStatus int  // 0 none, 1 permanent alert, 2 temporary alert, 3 SPI
+	Altitude           *int // Feet; nil when not available or in metres
+	GNSS               bool // The altitude is GNSS height rather than barometric
+	NICB               bool // NIC supplement-B in version 2, the single antenna flag before; see Integrity
+	CPR                CPR
+}
+
+// SurfacePosition is a surface position message
+type SurfacePosition struct {
+	Speed *float64 // Ground speed in knots; nil when not available
+	Track *float64 // Degrees true; nil when not valid
+	CPR   CPR
+}
+
+// Velocity is an airborne velocity message
+type Velocity struct {
+	Subtype      int      // 1 and 2 ground speed, 3 and 4 airspeed; 2 and 4 are supersonic
+	Speed        *int     // Knots: the ground speed, or the airspeed for subtypes 3 and 4
+	TrueAirspeed bool     // The airspeed is true, not indicated
+	Track        *float64 // Degrees true, with the ground speed
+	Heading      *float64 // Degrees magnetic, with the airspeed
+	VerticalRate *int     // Feet per minute, negative when descending
+	GNSSRate     bool     // The vertical rate is from GNSS rather than barometric
+}
+
+// Status is an emergency/priority status message
+type Status struct {
+	Emergency string // e.g. general or minimum_fuel; empty when none
+	Squawk    string // Mode A code, e.g. 7700
+}
+
+// Decode decodes a DF17 or DF18 extended squitter. The parity isn't checked; callers should drop frames whose
+// Mode S CRC doesn't match first. DF18 coarse TIS-B and management messages aren't decoded.
+func Decode(frame []byte) (*Message, error) {
+	if len(frame) != frameLen {
+		return nil, ErrNotExtendedSquitter
+	}
+	if df := bits(frame, 1, 5); df != 17 && df != 18 {
+		return nil, ErrNotExtendedSquitter
+	}
+	if !IsExtendedSquitter(frame) {
+		return nil, ErrNotDecoded
+	}
+	address, source, _ := Address(frame)
+	me := func(first, last int) uint64 { return bits(frame, 32+first, 32+last) }
+	tc := me(1, 5)
+	m := &Message{ICAO: address, Source: source, TypeCode: int(tc)}
+
+	switch {
+	case tc >= 1 && tc <= 4:
+		id := &Identification{}
+		if set := me(6, 8); set != 0 {
+			id.Category = string(rune('A'+4-tc)) + string(rune('0'+set))
+		}
+		var sb strings.Builder
+		for i := 0; i < 8; i++ {
+			sb.WriteByte(callsignChars[me(9+6*i, 14+6*i)])
+		}
+		id.Callsign = strings.TrimSpace(strings.ReplaceAll(sb.String(), "#", ""))
+		m.Identification = id
+
+	case tc >= 5 && tc <= 8:
+		pos := &SurfacePosition{CPR: cpr(me)}
+		if knots, ok := movementSpeed(me(6, 12)); ok {
+			pos.Speed = &knots
+		}
+		if me(13, 13) == 1 {
+			track := float64(me(14, 20)) * 360 / 128
+			pos.Track = &track
+		}
+		m.SurfacePosition = pos
+
+	case tc >= 9 && tc <= 18, tc >= 20 && tc <= 22:
+		pos := &AirbornePosition{SurveillanceStatus: int(me(6, 7)), GNSS: tc >= 20, NICB: me(8, 8) == 1, CPR: cpr(me)}
+		alt := me(9, 20)
+		if tc >= 20 {
+			if alt != 0 {
+				feet := int(float64(alt)*3.28084 + 0.5)
+				pos.Altitude = &feet
+			}
+		} else if feet, ok := DecodeAC13((alt&0xFC0)<<1 | alt&0x3F); ok {
+			// The 12-bit field is the 13-bit AC field without its M bit
+			pos.Altitude = &feet
+		}
+		m.AirbornePosition = pos
+
+	case tc == 19:
+		v, ok := decodeVelocity(me)
+		if !ok {
+			return nil, ErrNotDecoded
+		}
+		m.Velocity = v
+
+	case tc == 28 && me(6, 8) == 1:
+		m.Status = &Status{Emergency: emergencyStates[me(9, 11)], Squawk: fmt.Sprintf("%04o", DecodeID13(me(12, 24)))}
+
+	case tc == 28 && me(6, 8) == 2:
+		ra := &schema.Advisory{
+			ARA:             int(me(9, 22)),
+			RAC:             int(me(23, 26)),
+			Terminated:      me(27, 27) == 1,
+			MultipleThreats: me(28, 28) == 1,
+		}
+		ra.Summary = AdvisorySummary(me(9, 22), ra.Terminated, ra.MultipleThreats)
+		if me(29, 30) == 1 {
+			ra.ThreatICAO = fmt.Sprintf("%06X", me(31, 54))
+		}
+		m.Advisory = ra
+
+	case tc == 29 && me(6, 7) == 1:
+		m.TargetState = decodeTargetState(me)
+
+	case tc == 31 && me(6, 8) <= 1:
+		m.OperationalStatus = decodeOperationalStatus(me)
+
+	default:
+		return nil, ErrNotDecoded
+	}
+	return m, nil
+}
+
+// cpr reads the CPR format and encoded position that end position messages
+func cpr(me func(first, last int) uint64) CPR {
+	return CPR{Odd: me(22, 22) == 1, Lat: int(me(23, 39)), Lon: int(me(40, 56))}
+}
+
+// decodeVelocity decodes the subtypes of an airborne velocity message; ok is false for reserved subtypes
+func decodeVelocity(me func(first, last int) uint64) (*Velocity, bool) {
+	v := &Velocity{Subtype: int(me(6, 8))}
+	scale := 1.0
+	if v.Subtype == 2 || v.Subtype == 4 {
+		scale = 4
+	}
+
+	switch v.Subtype {
+	case 1, 2:
+		if vew, vns := me(15, 24), me(26, 35); vew != 0 && vns != 0 {
+			vx, vy := (float64(vew)-1)*scale, (float64(vns)-1)*scale
+			if me(14, 14) == 1 {
+				vx = -vx
+			}
+			if me(25, 25) == 1 {
+				vy = -vy
+			}
+			knots := int(math.Round(math.Hypot(vx, vy)))
+			track := math.Mod(math.Atan2(vx, vy)*180/math.Pi+360, 360)
+			v.Speed, v.Track = &knots, &track
+		}
+	case 3, 4:
+		if me(14, 14) == 1 {
+			heading := float64(me(15, 24)) * 360 / 1024
+			v.Heading = &heading
+		}
+		v.TrueAirspeed = me(25, 25) == 1
+		if as := me(26, 35); as != 0 {
+			knots := int(math.Round((float64(as) - 1) * scale))
+			v.Speed = &knots
+		}
+	default:
+		return nil, false
+	}
+
+	v.GNSSRate = me(36, 36) == 0
+	if vr := me(38, 46); vr != 0 {
+		rate := int(vr-1) * 64
+		if me(37, 37) == 1 {
+			rate = -rate
+		}
+		v.VerticalRate = &rate
+	}
+	return v, true
+}
+
+// movementSpeed converts the surface movement code to a ground speed in knots, the lower end of its range
+func movementSpeed(m uint64) (float64, bool) {
+	v := float64(m)
+	switch {
+	case m == 0 || m > 124:
+		return 0, false
+	case m == 1:
+		return 0, true
+	case m <= 8:
+		return 0.125 + (v-2)*0.125, true
+	case m <= 12:
+		return 1 + (v-9)*0.25, true
+	case m <= 38:
+		return 2 + (v-13)*0.5, true
+	case m <= 93:
+		return 15 + (v - 39), true
+	case m <= 108:
+		return 70 + (v-94)*2, true
+	case m <= 123:
+		return 100 + (v-109)*5, true
+	default:
+		return 175, true
+	}
+}
+
+// AdvisorySummary describes the vertical advisory of the 14 ARA bits, e.g. "Climb, corrective". Only the first
+// seven bits are used; the rest are for ACAS III.
+func AdvisorySummary(ara uint64, terminated, multipleThreats bool) string {
+	a := func(n int) bool { return ara>>(14-n)&1 == 1 }
+	var parts []string
+	switch {
+	case a(1):
+		// One threat, or several all resolved in the same sense
+		up := !a(3)
+		switch {
+		case a(7) && up:
+			parts = append(parts, "Climb")
+		case a(7):
+			parts = append(parts, "Descend")
+		case up:
+			parts = append(parts, "Limit descent")
+		default:
+			parts = append(parts, "Limit climb")
+		}
+		if a(2) {
+			parts = append(parts, "corrective")
+		} else {
+			parts = append(parts, "preventive")
+		}
+		if a(4) {
+			parts = append(parts, "increase rate")
+		}
+		if a(5) {
+			parts = append(parts, "reversal")
+		}
+		if a(6) {
+			parts = append(parts, "crossing")
+		}
+	case multipleThreats:
+		// Several threats resolved in different senses
+		if a(2) {
+			parts = append(parts, map[bool]string{true: "Climb", false: "Limit descent"}[a(3)])
+		}
+		if a(4) {
+			parts = append(parts, map[bool]string{true: "Descend", false: "Limit climb"}[a(5)])
+		}
+		if a(6) {
+			parts = append(parts, "crossing")
+		}
+		if a(7) {
+			parts = append(parts, "reversal")
+		}
+	}
+	switch {
+	case len(parts) > 0 && terminated:
+		return strings.Join(parts, ", ") + ", terminated"
+	case len(parts) > 0:
+		return strings.Join(parts, ", ")
+	case terminated:
+		return "Clear of conflict"
+	default:
+		return "No advisory"
+	}
+}
+
+// bits returns bits first to last of a Mode S frame, numbered from 1 like the Mode S specification
+func bits(msg []byte, first, last int) uint64 {
+	var v uint64
+	for i := first; i <= last; i++ {
+		bit := (msg[(i-1)/8] >> (7 - uint((i-1)%8))) & 1
+		v = v<<1 | uint64(bit)
+	}
+	return v
+}
@@ -0,0 +1,306 @@
+package decoder
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decode decodes a hex frame
+func decode(t *testing.T, frame string) *Message {
+	t.Helper()
+	msg, err := hex.DecodeString(frame)
+	require.NoError(t, err)
+	m, err := Decode(msg)
+	require.NoError(t, err)
+	return m
+}
+
+func TestDecode_Identification(t *testing.T) {
+	m := decode(t, "8D4840D6202CC371C32CE0576098")
+	assert.Equal(t, "4840D6", m.ICAO)
+	assert.Equal(t, 4, m.TypeCode)
+	require.NotNil(t, m.Identification)
+	assert.Equal(t, "KLM1023", m.Identification.Callsign)
+	assert.Empty(t, m.Identification.Category, "category set A without details")
+}
+
+func TestDecode_AirbornePosition(t *testing.T) {
+	m := decode(t, "8D40621D58C382D690C8AC2863A7")
+	require.NotNil(t, m.AirbornePosition)
+	require.NotNil(t, m.AirbornePosition.Altitude)
+	assert.Equal(t, 38000, *m.AirbornePosition.Altitude)
+	assert.False(t, m.AirbornePosition.GNSS)
+	assert.Equal(t, CPR{Odd: false, Lat: 93000, Lon: 51372}, m.AirbornePosition.CPR)
+}
+
+func TestDecode_SurfacePosition(t *testing.T) {
+	m := decode(t, "8C4841753A9A153237AEF0F275BE")
+	require.NotNil(t, m.SurfacePosition)
+	require.NotNil(t, m.SurfacePosition.Speed)
+	assert.Equal(t, 17.0, *m.SurfacePosition.Speed)
+	require.NotNil(t, m.SurfacePosition.Track)
+	assert.InDelta(t, 92.8, *m.SurfacePosition.Track, 0.1)
+	assert.True(t, m.SurfacePosition.CPR.Odd)
+}
+
+func TestDecode_Velocity(t *testing.T) {
+	v := decode(t, "8D485020994409940838175B284F").Velocity
+	require.NotNil(t, v)
+	assert.Equal(t, 1, v.Subtype)
+	assert.Equal(t, 159, *v.Speed)
+	assert.InDelta(t, 182.88, *v.Track, 0.01)
+	assert.Nil(t, v.Heading)
+	assert.Equal(t, -832, *v.VerticalRate)
+	assert.True(t, v.GNSSRate)
+
+	v = decode(t, "8DA05F219B06B6AF189400CBC33F").Velocity
+	require.NotNil(t, v)
+	assert.Equal(t, 375, *v.Speed)
+	assert.True(t, v.TrueAirspeed)
+	assert.InDelta(t, 243.98, *v.Heading, 0.01)
+	assert.Nil(t, v.Track)
+	assert.Equal(t, -2304, *v.VerticalRate)
+	assert.False(t, v.GNSSRate)
+}
+
+func TestDecode_Errors(t *testing.T) {
+	msg, _ := hex.DecodeString("5D4840D6A7A6F1")
+	_, err := Decode(msg)
+	assert.ErrorIs(t, err, ErrNotExtendedSquitter, "a DF11 all-call reply")
+
+	msg, _ = hex.DecodeString("8D4840D6E8000000000000000000")
+	_, err = Decode(msg)
+	assert.ErrorIs(t, err, ErrNotDecoded, "TC29 subtype 0, version 1 target state and status")
+}
+
+// df18 builds a DF18 frame with a control field, address, and ME field; the parity isn't set
+func df18(cf byte, aa uint32, me uint64) []byte {
+	frame := []byte{18<<3 | cf, byte(aa >> 16), byte(aa >> 8), byte(aa), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	for i := 0; i < 7; i++ {
+		frame[4+i] = byte(me >> (48 - 8*uint(i)))
+	}
+	return frame
+}
+
+func TestAddress(t *testing.T) {
+	const position = 0x58C382D690C8AC // TC11 airborne position
+	const imf = 1 << 48               // ME bit 8
+	df17, _ := hex.DecodeString("8D4840D6202CC371C32CE0576098")
+	df11, _ := hex.DecodeString("5D4840D6000000")
+
+	for _, tc := range []struct {
+		name            string
+		frame           []byte
+		address, source string
+		kind            string
+		ok              bool
+	}{
+		{"DF17", df17, "4840D6", SourceADSB, AddressICAO, true},
+		{"non-transponder device", df18(0, 0x4840D6, position), "4840D6", SourceADSB, AddressICAO, true},
+		{"anonymous non-transponder device", df18(1, 0xABCDEF, position), "~ABCDEF", SourceADSB, AddressAnonymous, true},
+		{"fine TIS-B", df18(2, 0x4840D6, position), "4840D6", SourceTISB, AddressICAO, true},
+		{"fine TIS-B track number", df18(2, 0xABCDEF, position|imf), "~ABCDEF", SourceTISB, AddressTrackFile, true},
+		{"coarse TIS-B track number", df18(3, 0xABCDEF, 1<<55), "~ABCDEF", SourceTISB, AddressTrackFile, true},
+		{"TIS-B non-ICAO address", df18(5, 0xABCDEF, position), "~ABCDEF", SourceTISB, AddressTrackFile, true},
+		{"ADS-R", df18(6, 0xA05F21, position), "A05F21", SourceADSR, AddressICAO, true},
+		{"ADS-R non-ICAO address", df18(6, 0xABCDEF, position|imf), "~ABCDEF", SourceADSR, AddressAnonymous, true},
+		{"management", df18(4, 0xABCDEF, 0), "", "", "", false},
+		{"all-call reply", df11, "", "", "", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			address, source, ok := Address(tc.frame)
+			assert.Equal(t, tc.ok, ok)
+			assert.Equal(t, tc.address, address)
+			assert.Equal(t, tc.source, source)
+			assert.Equal(t, tc.kind, AddressKind(tc.frame))
+		})
+	}
+}
+
+func TestDecode_DF18(t *testing.T) {
+	m, err := Decode(df18(6, 0xA05F21, 0x58C382D690C8AC))
+	require.NoError(t, err)
+	assert.Equal(t, "A05F21", m.ICAO)
+	assert.Equal(t, SourceADSR, m.Source)
+	require.NotNil(t, m.AirbornePosition)
+	assert.Equal(t, 38000, *m.AirbornePosition.Altitude, "laid out like DF17")
+
+	m, err = Decode(df18(2, 0xABCDEF, 0x59C382D690C8AC))
+	require.NoError(t, err)
+	assert.Equal(t, "~ABCDEF", m.ICAO)
+	assert.Equal(t, SourceTISB, m.Source)
+
+	_, err = Decode(df18(3, 0xABCDEF, 0x58C382D690C8AC))
+	assert.ErrorIs(t, err, ErrNotDecoded, "coarse TIS-B is laid out differently")
+	_, err = Decode(df18(4, 0xABCDEF, 0))
+	assert.ErrorIs(t, err, ErrNotDecoded)
+}
+
+func TestDecode_OperationalStatus(t *testing.T) {
+	// Airborne, version 2 with NIC-A set, NACp 10, SIL 3 per sample
+	m, err := Decode(df18(0, 0x4840D6, 31<<51|2<<13|1<<12|10<<8|3<<4|1<<1))
+	require.NoError(t, err)
+	s := m.OperationalStatus
+	require.NotNil(t, s)
+	assert.Equal(t, OperationalStatus{Version: Version2, NICA: true, NACp: 10, SIL: 3, SILPerSample: true}, *s)
+
+	m, err = Decode(df18(0, 0x4840D6, 31<<51|1<<48|2<<13|1<<36|9<<8))
+	require.NoError(t, err)
+	assert.Equal(t, OperationalStatus{Surface: true, Version: Version2, NICC: true, NACp: 9}, *m.OperationalStatus)
+
+	m, err = Decode(df18(0, 0x4840D6, 31<<51|1<<12|10<<8))
+	require.NoError(t, err)
+	assert.Equal(t, OperationalStatus{}, *m.OperationalStatus, "version 0 has no integrity fields")
+}
+
+func TestDecode_TargetState(t *testing.T) {
+	m := decode(t, "8DA05629EA21485CBF3F8CADAEEB")
+	assert.Equal(t, 29, m.TypeCode)
+	s := m.TargetState
+	require.NotNil(t, s)
+	require.NotNil(t, s.SelectedAltitude)
+	assert.Equal(t, 16992, *s.SelectedAltitude)
+	assert.False(t, s.FMSAltitude, "set on the MCP/FCU")
+	require.NotNil(t, s.BaroSetting)
+	assert.Equal(t, 1012.8, *s.BaroSetting)
+	require.NotNil(t, s.SelectedHeading)
+	assert.InDelta(t, 66.8, *s.SelectedHeading, 0.1)
+	assert.Equal(t, []string{ModeAutopilot, ModeVNAV, ModeLNAV, ModeTCAS}, s.Modes)
+
+	// Nothing available, and the mode bits not valid apart from ACAS
+	m, err := Decode(df18(0, 0x4840D6, 29<<51|1<<49|1<<8|1<<3))
+	require.NoError(t, err)
+	assert.Equal(t, TargetState{Modes: []string{ModeTCAS}}, *m.TargetState)
+}
+
+func TestMessage_Integrity(t *testing.T) {
+	const tc11 = 0x58C382D690C8AC // Airborne position, TC11
+	const nicB = 1 << 48
+	position := func(me uint64) *Message {
+		m, err := Decode(df18(0, 0x4840D6, me))
+		require.NoError(t, err)
+		return m
+	}
+	v2 := &OperationalStatus{Version: Version2, NICA: true, NACp: 9, SIL: 3}
+
+	in := position(tc11 | nicB).Integrity(v2)
+	require.NotNil(t, in)
+	assert.Equal(t, 9, in.NIC, "Rc < 75 m with both supplements")
+	assert.Equal(t, 9, *in.NACp)
+	assert.Equal(t, 3, *in.SIL)
+
+	assert.Equal(t, 8, position(tc11).Integrity(v2).NIC, "Rc < 0.1 NM without NIC-B")
+	assert.Equal(t, 9, position(tc11).Integrity(&OperationalStatus{Version: Version1, NICA: true}).NIC,
+		"version 1 has one supplement")
+
+	in = position(tc11 | nicB).Integrity(nil)
+	assert.Equal(t, 8, in.NIC, "supplements taken as clear without a status")
+	assert.Nil(t, in.NACp)
+	assert.Nil(t, in.SIL)
+
+	assert.Equal(t, 11, position(0x48C382D690C8AC).Integrity(nil).NIC, "TC9")
+	assert.Equal(t, 0, position(0x90C382D690C8AC).Integrity(nil).NIC, "TC18")
+
+	surface := position(0x429A153237AEF0) // TC8
+	assert.Equal(t, 0, surface.Integrity(nil).NIC)
+	assert.Equal(t, 7, surface.Integrity(&OperationalStatus{Surface: true, Version: Version2, NICA: true, NICC: true}).NIC)
+
+	assert.Nil(t, decode(t, "8D4840D6202CC371C32CE0576098").Integrity(v2), "not a position")
+}
+
+func TestDecodeAC13_Gillham(t *testing.T) {
+	// Gillham code in 100 ft steps: C2, B1, and B2 set is 1000 ft
+	feet, ok := DecodeAC13(1<<10 | 1<<5 | 1<<3)
+	require.True(t, ok)
+	assert.Equal(t, 1000, feet)
+
+	_, ok = DecodeAC13(1 << 1)
+	assert.False(t, ok, "the C bits can't all be clear")
+}
+
+func TestDecodeModeAC(t *testing.T) {
+	// C2, B1, and B2 set: squawk 0320, or 1000 ft
+	m, err := DecodeModeAC([]byte{0x03, 0x20})
+	require.NoError(t, err)
+	assert.Equal(t, 0o0320, m.Squawk)
+	assert.False(t, m.SPI)
+	require.NotNil(t, m.Altitude)
+	assert.Equal(t, 1000, *m.Altitude)
+
+	m, err = DecodeModeAC([]byte{0x77, 0x00})
+	require.NoError(t, err)
+	assert.Equal(t, 0o7700, m.Squawk)
+	assert.Nil(t, m.Altitude, "the C bits can't all be clear in an altitude")
+
+	m, err = DecodeModeAC([]byte{0x12, 0xB4})
+	require.NoError(t, err)
+	assert.Equal(t, 0o1234, m.Squawk)
+	assert.True(t, m.SPI)
+	assert.Nil(t, m.Altitude, "Mode C replies have no ident pulse")
+
+	_, err = DecodeModeAC([]byte{0x12})
+	assert.Error(t, err)
+}
+
+// decodeCommB decodes a hex Comm-B reply
+func decodeCommB(t *testing.T, frame string) (*CommB, error) {
+	t.Helper()
+	msg, err := hex.DecodeString(frame)
+	require.NoError(t, err)
+	return DecodeCommB(msg)
+}
+
+func TestDecodeCommB_SelectedVertical(t *testing.T) {
+	c, err := decodeCommB(t, "A000029C85E42F313000007047D3")
+	require.NoError(t, err)
+	assert.Equal(t, BDS40, c.BDS)
+	require.NotNil(t, c.SelectedVertical)
+	assert.Equal(t, 3008, *c.SelectedVertical.MCPAltitude)
+	assert.Equal(t, 3008, *c.SelectedVertical.FMSAltitude)
+	assert.InDelta(t, 1020.0, *c.SelectedVertical.BaroSetting, 0.01)
+}
+
+func TestDecodeCommB_TrackAndTurn(t *testing.T) {
+	c, err := decodeCommB(t, "A000139381951536E024D4CCF6B5")
+	require.NoError(t, err)
+	assert.Equal(t, BDS50, c.BDS)
+	v := c.TrackAndTurn
+	require.NotNil(t, v)
+	assert.InDelta(t, 2.1, *v.Roll, 0.01)
+	assert.InDelta(t, 114.26, *v.Track, 0.01)
+	assert.Equal(t, 438, *v.GroundSpeed)
+	assert.InDelta(t, 0.125, *v.TrackRate, 0.001)
+	assert.Equal(t, 424, *v.TrueAirspeed)
+
+	c, err = decodeCommB(t, "A8001EBCFFFB23286004A73F6A5B")
+	require.NoError(t, err)
+	assert.InDelta(t, -0.18, *c.TrackAndTurn.Roll, 0.01, "left wing down")
+	assert.InDelta(t, 250.49, *c.TrackAndTurn.Track, 0.01)
+}
+
+func TestDecodeCommB_HeadingAndSpeed(t *testing.T) {
+	c, err := decodeCommB(t, "A00004128F39F91A7E27C46ADC21")
+	require.NoError(t, err)
+	assert.Equal(t, BDS60, c.BDS)
+	v := c.HeadingAndSpeed
+	require.NotNil(t, v)
+	assert.InDelta(t, 42.71, *v.Heading, 0.01)
+	assert.Equal(t, 252, *v.IndicatedAirspeed)
+	assert.InDelta(t, 0.42, *v.Mach, 0.001)
+	assert.Equal(t, -1920, *v.BaroVerticalRate)
+	assert.Equal(t, -1920, *v.InertialRate)
+}
+
+func TestDecodeCommB_Errors(t *testing.T) {
+	_, err := decodeCommB(t, "A0001838201584F23468207CDFA5") // BDS 2,0 aircraft identification
+	assert.ErrorIs(t, err, ErrUnknownRegister)
+	_, err = decodeCommB(t, "A0001838000000000000007CDFA5")
+	assert.ErrorIs(t, err, ErrUnknownRegister, "an empty MB field fits every layout")
+	_, err = decodeCommB(t, "A000183880200000000000000000")
+	assert.ErrorIs(t, err, ErrAmbiguousBDS, "a single small field fits several layouts")
+	_, err = decodeCommB(t, "8D4840D6202CC371C32CE0576098")
+	assert.ErrorIs(t, err, ErrNotCommB)
+}
@@ -0,0 +1,111 @@
+package decoder
+
+// Versions of the ADS-B standard an aircraft reports in its operational status
+const (
+	Version0 = 0 // DO-260, no integrity indicators beyond the type code
+	Version1 = 1 // DO-260A, one NIC supplement
+	Version2 = 2 // DO-260B, NIC supplements A, B, and C
+)
+
+// OperationalStatus is an aircraft operational status message. The NIC supplements, NACp, and SIL are only set
+// from version 1 on.
+type OperationalStatus struct {
+	Surface      bool // Subtype 1, sent on the ground; subtype 0 is airborne
+	Version      int  // ADS-B version, e.g. Version2
+	NICA         bool // NIC supplement-A, the only NIC supplement in version 1
+	NICC         bool // NIC supplement-C, in version 2 surface messages
+	NACp         int  // Navigation accuracy category for position, 0 (unknown) to 11 (within 3 m)
+	SIL          int  // Source integrity level, 0 (unknown) to 3 (a 1 in 10 million chance of leaving the containment radius)
+	SILPerSample bool // The SIL probability is per sample rather than per flight hour, version 2
+}
+
+// decodeOperationalStatus decodes an operational status message, TC 31 subtype 0 or 1
+func decodeOperationalStatus(me func(first, last int) uint64) *OperationalStatus {
+	s := &OperationalStatus{Surface: me(6, 8) == 1, Version: int(me(41, 43))}
+	if s.Version == Version0 {
+		return s
+	}
+	s.NICA = me(44, 44) == 1
+	s.NACp = int(me(45, 48))
+	s.SIL = int(me(51, 52))
+	if s.Version >= Version2 {
+		s.NICC = s.Surface && me(20, 20) == 1
+		s.SILPerSample = me(55, 55) == 1
+	}
+	return s
+}
+
+// Integrity is how far a decoded position can be trusted
+type Integrity struct {
+	NIC  int  // Navigation integrity category, 0 (unknown) to 11 (containment radius under 7.5 m)
+	NACp *int // Nil until the aircraft's operational status reports it, see OperationalStatus
+	SIL  *int
+}
+
+// Integrity returns the integrity of a position message given the aircraft's last operational status, nil for
+// other messages. The NIC comes from the type code and the NIC supplements; without an operational status, or
+// before version 2 for those only it carries, they are taken as clear, which can only understate the NIC.
+func (m *Message) Integrity(status *OperationalStatus) *Integrity {
+	if m.AirbornePosition == nil && m.SurfacePosition == nil {
+		return nil
+	}
+	var a, b, c bool
+	if status != nil && status.Version >= Version1 {
+		a = status.NICA
+		b = status.NICA // Version 1 has the one supplement for both
+		if status.Version >= Version2 {
+			b = m.AirbornePosition != nil && m.AirbornePosition.NICB
+			c = status.NICC
+		}
+	}
+	in := &Integrity{NIC: nic(m.TypeCode, a, b, c)}
+	if status != nil && status.Version >= Version1 {
+		nacp, sil := status.NACp, status.SIL
+		in.NACp, in.SIL = &nacp, &sil
+	}
+	return in
+}
+
+// nic returns the navigation integrity category of a position type code, by DO-260B table 2-14 and 2-15
+func nic(tc int, a, b, c bool) int {
+	switch tc {
+	case 5, 9, 20:
+		return 11
+	case 6, 10, 21:
+		return 10
+	case 7:
+		if a {
+			return 9
+		}
+		return 8
+	case 8:
+		switch {
+		case a && c:
+			return 7
+		case a || c:
+			return 6
+		}
+		return 0
+	case 11:
+		if a && b {
+			return 9
+		}
+		return 8
+	case 12:
+		return 7
+	case 13:
+		return 6
+	case 14:
+		return 5
+	case 15:
+		return 4
+	case 16:
+		if a && b {
+			return 3
+		}
+		return 2
+	case 17:
+		return 1
+	}
+	return 0
+}
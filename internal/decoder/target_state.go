@@ -0,0 +1,54 @@
+package decoder
+
+import "math"
+
+// Autopilot modes a target state and status message can report engaged, as readsb names them
+const (
+	ModeAutopilot    = "autopilot"
+	ModeVNAV         = "vnav"
+	ModeAltitudeHold = "althold"
+	ModeApproach     = "approach"
+	ModeLNAV         = "lnav"
+	ModeTCAS         = "tcas" // ACAS is operational, reported whether or not the other modes are
+)
+
+// TargetState is a target state and status message, TC 29 subtype 1 from version 2 on: what the crew set the
+// autopilot to. Version 1's subtype 0 is laid out differently and isn't decoded.
+type TargetState struct {
+	SelectedAltitude *int     // Feet; nil when not available
+	FMSAltitude      bool     // The selected altitude is from the FMS rather than the MCP/FCU
+	BaroSetting      *float64 // Millibars; nil when not available
+	SelectedHeading  *float64 // Degrees; nil when not available
+	Modes            []string // Engaged modes, e.g. ModeLNAV; the autopilot's only when its mode bits are valid
+}
+
+// decodeTargetState decodes a target state and status message, TC 29 subtype 1
+func decodeTargetState(me func(first, last int) uint64) *TargetState {
+	s := &TargetState{FMSAltitude: me(9, 9) == 1}
+	if alt := me(10, 20); alt != 0 {
+		feet := int(alt-1) * 32
+		s.SelectedAltitude = &feet
+	}
+	if baro := me(21, 29); baro != 0 {
+		mb := math.Round((800+float64(baro-1)*0.8)*10) / 10
+		s.BaroSetting = &mb
+	}
+	if me(30, 30) == 1 {
+		heading := float64(me(31, 39)) * 180 / 256
+		s.SelectedHeading = &heading
+	}
+	if me(47, 47) == 1 {
+		for _, m := range []struct {
+			bit  int
+			name string
+		}{{48, ModeAutopilot}, {49, ModeVNAV}, {50, ModeAltitudeHold}, {52, ModeApproach}, {54, ModeLNAV}} {
+			if me(m.bit, m.bit) == 1 {
+				s.Modes = append(s.Modes, m.name)
+			}
+		}
+	}
+	if me(53, 53) == 1 {
+		s.Modes = append(s.Modes, ModeTCAS)
+	}
+	return s
+}
@@ -0,0 +1,215 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultPorts are the Beast output ports dump1090 and readsb listen on out of the box
+var DefaultPorts = []int{30005, 30006}
+
+// Source records how a receiver was found
+const (
+	SourceLocal = "local" // This machine
+	SourceMDNS  = "mdns"  // A well-known receiver hostname answered on mDNS
+	SourceScan  = "scan"  // An address on a local subnet
+)
+
+// Receiver is a Beast server found on the network
+type Receiver struct {
+	Addr     string // host:port, ready for beast_addr
+	Host     string // mDNS hostname when known, e.g. piaware.local
+	Source   string
+	Verified bool // The server sent Beast frames; false means the port is open but was quiet
+}
+
+// Options controls a discovery run
+type Options struct {
+	Ports       []int
+	Timeout     time.Duration // Per connection attempt
+	Concurrency int
+	Interfaces  []net.Interface // Defaults to every interface that is up
+}
+
+// Scan looks for Beast servers on this machine, at well-known mDNS hostnames, and on the local /24 subnets
+func Scan(ctx context.Context, opts Options) ([]Receiver, error) {
+	if len(opts.Ports) == 0 {
+		opts.Ports = DefaultPorts
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 500 * time.Millisecond
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 64
+	}
+	if opts.Interfaces == nil {
+		ifaces, err := net.Interfaces()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list network interfaces: %w", err)
+		}
+		opts.Interfaces = ifaces
+	}
+
+	type candidate struct {
+		ip     net.IP
+		host   string
+		source string
+	}
+	seen := make(map[string]bool)
+	var candidates []candidate
+	add := func(ip net.IP, host, source string) {
+		if seen[ip.String()] {
+			return
+		}
+		seen[ip.String()] = true
+		candidates = append(candidates, candidate{ip, host, source})
+	}
+
+	add(net.IPv4(127, 0, 0, 1), "localhost", SourceLocal)
+	// mDNS goes first so a receiver found both ways keeps its hostname
+	hosts, _ := ResolveMDNS(ctx, WellKnownHosts, 2*time.Second)
+	for _, h := range hosts {
+		add(h.IP, h.Name, SourceMDNS)
+	}
+	for _, ip := range subnetHosts(opts.Interfaces) {
+		add(ip, "", SourceScan)
+	}
+
+	var (
+		mu    sync.Mutex
+		found []Receiver
+		wg    sync.WaitGroup
+	)
+	sem := make(chan struct{}, opts.Concurrency)
+	for _, c := range candidates {
+		for _, port := range opts.Ports {
+			select {
+			case <-ctx.Done():
+				wg.Wait()
+				return found, ctx.Err()
+			case sem <- struct{}{}:
+			}
+			wg.Add(1)
+			go func(c candidate, port int) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				host := c.ip.String()
+				if c.host != "" {
+					host = c.host
+				}
+				open, beast := Probe(ctx, net.JoinHostPort(c.ip.String(), strconv.Itoa(port)), opts.Timeout)
+				if !open {
+					return
+				}
+				mu.Lock()
+				found = append(found, Receiver{
+					Addr:     net.JoinHostPort(host, strconv.Itoa(port)),
+					Host:     c.host,
+					Source:   c.source,
+					Verified: beast,
+				})
+				mu.Unlock()
+			}(c, port)
+		}
+	}
+	wg.Wait()
+
+	// Verified receivers first, then local, mDNS, and scanned ones
+	rank := map[string]int{SourceLocal: 0, SourceMDNS: 1, SourceScan: 2}
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].Verified != found[j].Verified {
+			return found[i].Verified
+		}
+		if rank[found[i].Source] != rank[found[j].Source] {
+			return rank[found[i].Source] < rank[found[j].Source]
+		}
+		return found[i].Addr < found[j].Addr
+	})
+	return found, nil
+}
+
+// Probe connects to addr and reports whether the port is open and whether it streams Beast frames.
+// Beast servers push data as soon as a client connects, so a few seconds of silence only means no aircraft are in range.
+func Probe(ctx context.Context, addr string, timeout time.Duration) (open, beast bool) {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return false, false
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(4 * timeout))
+	buf := make([]byte, 512)
+	n, _ := conn.Read(buf)
+	return true, looksLikeBeast(buf[:n])
+}
+
+// looksLikeBeast reports whether data contains an escape byte followed by a Beast frame type
+func looksLikeBeast(data []byte) bool {
+	for i := 0; i+1 < len(data); i++ {
+		if data[i] == 0x1a && data[i+1] >= '1' && data[i+1] <= '4' {
+			return true
+		}
+	}
+	return false
+}
+
+// subnetHosts lists the other addresses on each up, non-loopback IPv4 interface.
+// Networks larger than a /24 are narrowed to the /24 around this machine to keep the scan short.
+func subnetHosts(ifaces []net.Interface) []net.IP {
+	var hosts []net.IP
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			ipnet, ok := a.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			hosts = append(hosts, networkHosts(ipnet)...)
+		}
+	}
+	return hosts
+}
+
+// networkHosts lists the host addresses of an IPv4 network, excluding the network, broadcast, and own address
+func networkHosts(ipnet *net.IPNet) []net.IP {
+	own := ipnet.IP.To4()
+	if own == nil {
+		return nil
+	}
+	ones, bits := ipnet.Mask.Size()
+	if bits != 32 || ones >= 31 {
+		return nil
+	}
+	if ones < 24 {
+		ones = 24
+	}
+	mask := net.CIDRMask(ones, 32)
+	network := own.Mask(mask)
+	size := 1 << (32 - ones)
+
+	hosts := make([]net.IP, 0, size-2)
+	for i := 1; i < size-1; i++ {
+		ip := make(net.IP, 4)
+		copy(ip, network)
+		ip[2] += byte(i >> 8)
+		ip[3] += byte(i)
+		if ip.Equal(own) {
+			continue
+		}
+		hosts = append(hosts, ip)
+	}
+	return hosts
+}
@@ -0,0 +1,110 @@
+package discovery
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listen starts a TCP server that writes greeting to each client
+func listen(t *testing.T, greeting []byte) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write(greeting)
+			time.Sleep(100 * time.Millisecond)
+			conn.Close()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestProbe(t *testing.T) {
+	ctx := context.Background()
+	frame := []byte{0x1a, '2', 0, 0, 0, 0, 0, 0, 0x80, 0x5d, 0x4c, 0xa2, 0xd3, 0x1e, 0x2b, 0x00}
+
+	open, beast := Probe(ctx, listen(t, frame), 200*time.Millisecond)
+	assert.True(t, open)
+	assert.True(t, beast)
+
+	open, beast = Probe(ctx, listen(t, []byte("HTTP/1.1 400 Bad Request\r\n")), 200*time.Millisecond)
+	assert.True(t, open)
+	assert.False(t, beast, "a non-Beast service isn't a receiver")
+
+	// A closed port
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+	open, _ = Probe(ctx, addr, 200*time.Millisecond)
+	assert.False(t, open)
+}
+
+func TestNetworkHosts(t *testing.T) {
+	_, ipnet, err := net.ParseCIDR("192.168.1.0/24")
+	require.NoError(t, err)
+	ipnet.IP = net.ParseIP("192.168.1.20")
+
+	hosts := networkHosts(ipnet)
+	assert.Len(t, hosts, 253, "network, broadcast, and own address are skipped")
+	assert.Equal(t, "192.168.1.1", hosts[0].String())
+	assert.Equal(t, "192.168.1.254", hosts[len(hosts)-1].String())
+	for _, h := range hosts {
+		assert.NotEqual(t, "192.168.1.20", h.String())
+	}
+
+	// A /16 is narrowed to the /24 around this machine
+	_, wide, err := net.ParseCIDR("10.1.0.0/16")
+	require.NoError(t, err)
+	wide.IP = net.ParseIP("10.1.7.9")
+	hosts = networkHosts(wide)
+	assert.Len(t, hosts, 253)
+	assert.Equal(t, "10.1.7.1", hosts[0].String())
+
+	// Point-to-point links have no neighbours to scan
+	_, p2p, err := net.ParseCIDR("10.0.0.0/31")
+	require.NoError(t, err)
+	assert.Empty(t, networkHosts(p2p))
+}
+
+func TestParseAnswers(t *testing.T) {
+	query := buildQuery([]string{"piaware.local"})
+	assert.Equal(t, uint16(1), binary.BigEndian.Uint16(query[4:]))
+
+	// Queries from other hosts on the group are ignored
+	hosts, err := parseAnswers(query)
+	require.NoError(t, err)
+	assert.Empty(t, hosts)
+
+	// A response echoing the question, answered with a compressed pointer to it
+	resp := append([]byte(nil), query...)
+	resp[2] = 0x84 // Response, authoritative
+	binary.BigEndian.PutUint16(resp[6:], 1)
+	resp = append(resp, 0xc0, 12) // Name at offset 12
+	resp = binary.BigEndian.AppendUint16(resp, dnsTypeA)
+	resp = binary.BigEndian.AppendUint16(resp, dnsClassIN)
+	resp = binary.BigEndian.AppendUint32(resp, 120)
+	resp = binary.BigEndian.AppendUint16(resp, 4)
+	resp = append(resp, 192, 168, 1, 42)
+
+	hosts, err = parseAnswers(resp)
+	require.NoError(t, err)
+	require.Len(t, hosts, 1)
+	assert.Equal(t, "piaware.local", hosts[0].Name)
+	assert.Equal(t, "192.168.1.42", hosts[0].IP.String())
+
+	_, err = parseAnswers(resp[:len(resp)-2])
+	assert.ErrorIs(t, err, errMalformed)
+}
@@ -0,0 +1,185 @@
+package discovery
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+// WellKnownHosts are the hostnames common ADS-B receiver images announce on mDNS
+var WellKnownHosts = []string{
+	"raspberrypi.local",
+	"piaware.local",
+	"readsb.local",
+	"adsb-feeder.local",
+	"adsbexchange.local",
+	"flightradar24.local",
+	"stratux.local",
+}
+
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+const (
+	dnsTypeA     = 1
+	dnsClassIN   = 1
+	dnsUnicastQU = 0x8000 // Asks responders to answer directly instead of to the multicast group
+)
+
+// Host is an mDNS hostname and the IPv4 address it answered with
+type Host struct {
+	Name string
+	IP   net.IP
+}
+
+// ResolveMDNS asks the local network for the IPv4 addresses of names and collects answers until timeout.
+// It speaks just enough multicast DNS to do that, since the system resolver often can't resolve .local names.
+func ResolveMDNS(ctx context.Context, names []string, timeout time.Duration) ([]Host, error) {
+	query := buildQuery(names)
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.WriteToUDP(query, mdnsGroup); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	wanted := make(map[string]bool, len(names))
+	for _, n := range names {
+		wanted[strings.ToLower(strings.TrimSuffix(n, "."))] = true
+	}
+	var hosts []Host
+	seen := make(map[string]bool)
+	readAnswers(conn, time.Until(deadline), func(h Host) {
+		key := h.Name + "/" + h.IP.String()
+		if wanted[h.Name] && !seen[key] {
+			seen[key] = true
+			hosts = append(hosts, h)
+		}
+	})
+	return hosts, nil
+}
+
+// readAnswers reads mDNS responses from conn until timeout
+func readAnswers(conn *net.UDPConn, timeout time.Duration, found func(Host)) {
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 9000)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		answers, err := parseAnswers(buf[:n])
+		if err != nil {
+			continue
+		}
+		for _, h := range answers {
+			found(h)
+		}
+	}
+}
+
+// buildQuery encodes a DNS query with an A question per name
+func buildQuery(names []string) []byte {
+	msg := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(msg[4:], uint16(len(names))) // QDCOUNT; ID and flags are zero for mDNS
+	for _, name := range names {
+		for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+			msg = append(msg, byte(len(label)))
+			msg = append(msg, label...)
+		}
+		msg = append(msg, 0)
+		msg = binary.BigEndian.AppendUint16(msg, dnsTypeA)
+		msg = binary.BigEndian.AppendUint16(msg, dnsClassIN|dnsUnicastQU)
+	}
+	return msg
+}
+
+var errMalformed = errors.New("malformed DNS message")
+
+// parseAnswers returns the A records in a DNS response's answer and additional sections
+func parseAnswers(msg []byte) ([]Host, error) {
+	if len(msg) < 12 {
+		return nil, errMalformed
+	}
+	if msg[2]&0x80 == 0 {
+		return nil, nil // A query, not a response
+	}
+	qd := int(binary.BigEndian.Uint16(msg[4:]))
+	records := int(binary.BigEndian.Uint16(msg[6:])) + int(binary.BigEndian.Uint16(msg[8:])) + int(binary.BigEndian.Uint16(msg[10:]))
+
+	off := 12
+	for i := 0; i < qd; i++ {
+		_, next, err := readName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		off = next + 4
+	}
+
+	var hosts []Host
+	for i := 0; i < records; i++ {
+		name, next, err := readName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		if next+10 > len(msg) {
+			return nil, errMalformed
+		}
+		typ := binary.BigEndian.Uint16(msg[next:])
+		length := int(binary.BigEndian.Uint16(msg[next+8:]))
+		data := next + 10
+		if data+length > len(msg) {
+			return nil, errMalformed
+		}
+		if typ == dnsTypeA && length == 4 {
+			hosts = append(hosts, Host{Name: strings.ToLower(name), IP: net.IP(append([]byte(nil), msg[data:data+4]...))})
+		}
+		off = data + length
+	}
+	return hosts, nil
+}
+
+// readName decodes a possibly compressed domain name at off, returning it and the offset just past it
+func readName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	end := -1
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errMalformed
+		}
+		l := int(msg[off])
+		switch {
+		case l == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return strings.Join(labels, "."), end, nil
+		case l&0xc0 == 0xc0:
+			if off+1 >= len(msg) || jumps > 10 {
+				return "", 0, errMalformed
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+			jumps++
+		default:
+			if off+1+l > len(msg) {
+				return "", 0, errMalformed
+			}
+			labels = append(labels, string(msg[off+1:off+1+l]))
+			off += 1 + l
+		}
+	}
+}
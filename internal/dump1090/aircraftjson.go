@@ -0,0 +1,271 @@
+package dump1090
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"flight_trmnl/internal/models"
+	"flight_trmnl/pkg/schema"
+)
+
+const (
+	// maxAircraftJSON bounds a response; a busy readsb's aircraft.json is a few hundred kilobytes
+	maxAircraftJSON = 16 << 20
+
+	// staleJSONPosition is how old a position the decoder still lists may be to be taken, as tar1090 shows them
+	staleJSONPosition = 60 * time.Second
+)
+
+// StateReporter takes the aircraft states a poller reads, e.g. the tracker
+type StateReporter interface {
+	Report(state schema.Aircraft)
+}
+
+// AircraftJSONPoller polls the aircraft.json of dump1090, readsb or tar1090 over HTTP and reports each aircraft's
+// state, for a receiver whose web interface is reachable but whose TCP outputs aren't. It gets no raw messages, so
+// nothing is stored in beast_messages; the tracker, and everything that follows it, sees the aircraft as usual.
+type AircraftJSONPoller struct {
+	url      string
+	interval time.Duration
+	client   *http.Client
+	reporter StateReporter
+
+	mu      sync.Mutex
+	lastErr error // The last poll's failure, nil once one succeeds
+}
+
+// jsonAircraft is one entry of aircraft.json, in readsb's field names and the older dump1090-fa ones
+type jsonAircraft struct {
+	Hex            string          `json:"hex"`
+	Type           string          `json:"type"` // readsb's source, e.g. adsb_icao, tisb_trackfile, or mlat
+	Flight         string          `json:"flight"`
+	AltBaro        json.RawMessage `json:"alt_baro"` // Feet, or "ground"
+	Altitude       json.RawMessage `json:"altitude"` // dump1090-fa before 3.7
+	GroundSpeed    *float64        `json:"gs"`
+	Speed          *float64        `json:"speed"`
+	Track          *float64        `json:"track"`
+	MagHeading     *float64        `json:"mag_heading"`
+	BaroRate       *float64        `json:"baro_rate"`
+	GeomRate       *float64        `json:"geom_rate"`
+	VertRate       *float64        `json:"vert_rate"`
+	Squawk         string          `json:"squawk"`
+	Emergency      string          `json:"emergency"`
+	Category       string          `json:"category"`
+	NavQNH         *float64        `json:"nav_qnh"`
+	NavAltitudeMCP *float64        `json:"nav_altitude_mcp"`
+	NavHeading     *float64        `json:"nav_heading"`
+	NavModes       []string        `json:"nav_modes"`
+	Lat            *float64        `json:"lat"`
+	Lon            *float64        `json:"lon"`
+	NIC            *int            `json:"nic"`
+	NACp           *int            `json:"nac_p"`
+	SIL            *int            `json:"sil"`
+	Seen           float64         `json:"seen"` // Seconds since the last message
+	Messages       int64           `json:"messages"`
+	RSSI           *float64        `json:"rssi"`     // dBFS
+	SeenPos        *float64        `json:"seen_pos"` // Seconds since the last position
+}
+
+// readsb's emergency names, as the decoder names them
+var jsonEmergencies = map[string]string{
+	"general":   "general",
+	"lifeguard": "lifeguard",
+	"minfuel":   "minimum_fuel",
+	"nordo":     "no_communications",
+	"unlawful":  "unlawful_interference",
+	"downed":    "downed",
+}
+
+// NewAircraftJSONPoller creates a poller of the aircraft.json at url, e.g.
+// http://raspberrypi.local/tar1090/data/aircraft.json, reporting to reporter every interval
+func NewAircraftJSONPoller(url string, interval time.Duration, reporter StateReporter) *AircraftJSONPoller {
+	return &AircraftJSONPoller{
+		url:      url,
+		interval: interval,
+		client:   &http.Client{Timeout: max(interval, dialTimeout)},
+		reporter: reporter,
+	}
+}
+
+// Start polls until the context is cancelled
+func (p *AircraftJSONPoller) Start(ctx context.Context) error {
+	slog.Info("Polling aircraft.json", "url", p.url, "interval", p.interval)
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		err := p.poll(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		p.mu.Lock()
+		failing := p.lastErr != nil
+		p.lastErr = err
+		p.mu.Unlock()
+		switch {
+		case err != nil && !failing:
+			slog.Warn("Failed to poll aircraft.json", "url", p.url, "error", err)
+		case err == nil && failing:
+			slog.Info("Polling aircraft.json again", "url", p.url)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Healthy reports why the last poll failed, nil after a successful one
+func (p *AircraftJSONPoller) Healthy() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastErr
+}
+
+// poll fetches aircraft.json once and reports its aircraft
+func (p *AircraftJSONPoller) poll(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded %s", p.url, resp.Status)
+	}
+	states, err := parseAircraftJSON(io.LimitReader(resp.Body, maxAircraftJSON), time.Now())
+	if err != nil {
+		return err
+	}
+	for _, state := range states {
+		p.reporter.Report(state)
+	}
+	return nil
+}
+
+// parseAircraftJSON returns the states of the aircraft in an aircraft.json fetched at now. Times are taken from
+// this host's clock, less how long ago the decoder last heard each aircraft, rather than from the decoder's "now".
+func parseAircraftJSON(r io.Reader, now time.Time) ([]schema.Aircraft, error) {
+	var doc struct {
+		Now      *float64       `json:"now"`
+		Aircraft []jsonAircraft `json:"aircraft"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse aircraft.json: %w", err)
+	}
+	if doc.Now == nil {
+		return nil, errors.New("not an aircraft.json, it has no \"now\"")
+	}
+	states := make([]schema.Aircraft, 0, len(doc.Aircraft))
+	for _, ac := range doc.Aircraft {
+		if state, ok := ac.state(now); ok {
+			states = append(states, state)
+		}
+	}
+	return states, nil
+}
+
+// state converts an entry, false for one that isn't an aircraft, e.g. Mode A/C only
+func (ac *jsonAircraft) state(now time.Time) (schema.Aircraft, bool) {
+	icao := strings.ToUpper(strings.TrimPrefix(ac.Hex, "~"))
+	state := schema.Aircraft{
+		ICAO:     icao,
+		LastSeen: now.Add(-time.Duration(ac.Seen * float64(time.Second))),
+		Messages: ac.Messages,
+		Callsign: strings.TrimSpace(ac.Flight),
+		Category: ac.Category,
+		Squawk:   ac.Squawk,
+		NIC:      ac.NIC,
+		NACp:     ac.NACp,
+		SIL:      ac.SIL,
+		NavModes: ac.NavModes,
+	}
+	switch {
+	case strings.HasPrefix(ac.Hex, "~") && ac.Type == "tisb_trackfile":
+		state.ICAO, state.AddressType = "~"+icao, models.AddressTrackFile
+	case strings.HasPrefix(ac.Hex, "~"):
+		state.ICAO, state.AddressType = "~"+icao, models.AddressAnonymous
+	default:
+		state.AddressType = models.CheckICAO(icao)
+	}
+	if !models.ValidAddress(state.AddressType) {
+		return schema.Aircraft{}, false
+	}
+	for _, source := range []string{"adsb", "adsr", "tisb"} {
+		if strings.HasPrefix(ac.Type, source+"_") {
+			state.Source = source
+		}
+	}
+	if ac.RSSI != nil {
+		state.SignalDBFS = *ac.RSSI
+		state.SignalLevel = signalLevel(*ac.RSSI)
+	}
+
+	altitude := ac.AltBaro
+	if altitude == nil {
+		altitude = ac.Altitude
+	}
+	var feet float64
+	if string(altitude) == `"ground"` {
+		state.OnGround = true
+	} else if altitude != nil && json.Unmarshal(altitude, &feet) == nil {
+		state.Altitude = intPtr(feet)
+	}
+	state.AltitudeBand = models.AltitudeBand(state.Altitude, state.OnGround)
+
+	if speed := firstSet(ac.GroundSpeed, ac.Speed); speed != nil {
+		state.Speed = intPtr(*speed)
+	}
+	if rate := firstSet(ac.BaroRate, ac.GeomRate, ac.VertRate); rate != nil {
+		state.VerticalRate = intPtr(*rate)
+	}
+	state.Track, state.Heading = ac.Track, ac.MagHeading
+	fresh := ac.SeenPos == nil || time.Duration(*ac.SeenPos*float64(time.Second)) < staleJSONPosition
+	if ac.Lat != nil && ac.Lon != nil && fresh {
+		state.Latitude, state.Longitude = ac.Lat, ac.Lon
+	}
+	if state.Category != "" {
+		state.CategoryName = models.CategoryName(state.Category)
+	}
+	state.Emergency = jsonEmergencies[ac.Emergency]
+	if ac.NavAltitudeMCP != nil {
+		state.SelectedAltitude = intPtr(*ac.NavAltitudeMCP)
+	}
+	state.SelectedHeading, state.BaroSetting = ac.NavHeading, ac.NavQNH
+	return state, true
+}
+
+// signalLevel is the Beast signal byte of a level in dBFS, the inverse of models.SignalDBFS
+func signalLevel(dbfs float64) uint8 {
+	if dbfs <= models.MinDBFS {
+		return 0
+	}
+	return uint8(min(math.Round(255*math.Pow(10, dbfs/20)), 255))
+}
+
+func firstSet(values ...*float64) *float64 {
+	for _, v := range values {
+		if v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+func intPtr(f float64) *int {
+	n := int(math.Round(f))
+	return &n
+}
@@ -0,0 +1,129 @@
+package dump1090
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/models"
+	"flight_trmnl/pkg/schema"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const readsbAircraftJSON = `{"now": 1709294400.0, "messages": 1234, "aircraft": [
+	{"hex": "4ca2d3", "type": "adsb_icao", "flight": "RYR1AB  ", "alt_baro": 35000, "gs": 451.3, "track": 92.1,
+	 "mag_heading": 89.5, "baro_rate": -64, "squawk": "7700", "emergency": "minfuel", "category": "A3",
+	 "nav_qnh": 1013.2, "nav_altitude_mcp": 36000, "nav_heading": 90.0, "nav_modes": ["autopilot", "lnav"],
+	 "lat": 53.1, "lon": -6.2, "nic": 8, "nac_p": 9, "sil": 3, "seen_pos": 1.2, "seen": 0.5, "messages": 420,
+	 "rssi": -6.0},
+	{"hex": "~0a1b2c", "type": "tisb_trackfile", "alt_baro": "ground", "seen": 2.0, "messages": 3, "rssi": -30.1},
+	{"hex": "3c6444", "alt_baro": 12000, "lat": 50.0, "lon": 8.0, "seen_pos": 120, "seen": 1.0, "messages": 7},
+	{"hex": "000000", "seen": 1.0, "messages": 1}
+]}`
+
+func TestParseAircraftJSON(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	states, err := parseAircraftJSON(strings.NewReader(readsbAircraftJSON), now)
+	require.NoError(t, err)
+	require.Len(t, states, 3) // The reserved address is left out
+
+	ac := states[0]
+	assert.Equal(t, "4CA2D3", ac.ICAO)
+	assert.Equal(t, models.AddressICAO, ac.AddressType)
+	assert.Equal(t, "adsb", ac.Source)
+	assert.Equal(t, now.Add(-500*time.Millisecond), ac.LastSeen)
+	assert.Equal(t, int64(420), ac.Messages)
+	assert.Equal(t, "RYR1AB", ac.Callsign)
+	assert.Equal(t, 35000, *ac.Altitude)
+	assert.Equal(t, "high", ac.AltitudeBand)
+	assert.Equal(t, 451, *ac.Speed)
+	assert.Equal(t, 92.1, *ac.Track)
+	assert.Equal(t, 89.5, *ac.Heading)
+	assert.Equal(t, -64, *ac.VerticalRate)
+	assert.Equal(t, "7700", ac.Squawk)
+	assert.Equal(t, "minimum_fuel", ac.Emergency)
+	assert.Equal(t, "A3", ac.Category)
+	assert.NotEmpty(t, ac.CategoryName)
+	assert.Equal(t, 36000, *ac.SelectedAltitude)
+	assert.Equal(t, 90.0, *ac.SelectedHeading)
+	assert.Equal(t, 1013.2, *ac.BaroSetting)
+	assert.Equal(t, []string{"autopilot", "lnav"}, ac.NavModes)
+	assert.Equal(t, 53.1, *ac.Latitude)
+	assert.Equal(t, -6.2, *ac.Longitude)
+	assert.Equal(t, 8, *ac.NIC)
+	assert.Equal(t, -6.0, ac.SignalDBFS)
+	assert.Equal(t, -6.0, models.SignalDBFS(ac.SignalLevel))
+
+	ac = states[1]
+	assert.Equal(t, "~0A1B2C", ac.ICAO)
+	assert.Equal(t, models.AddressTrackFile, ac.AddressType)
+	assert.Equal(t, "tisb", ac.Source)
+	assert.True(t, ac.OnGround)
+	assert.Nil(t, ac.Altitude)
+	assert.Equal(t, "surface", ac.AltitudeBand)
+
+	// Without a type, as dump1090 lists it; its position is two minutes old
+	ac = states[2]
+	assert.Equal(t, "3C6444", ac.ICAO)
+	assert.Empty(t, ac.Source)
+	assert.Nil(t, ac.Latitude)
+
+	_, err = parseAircraftJSON(strings.NewReader(`{"aircraft": []}`), now)
+	assert.Error(t, err)
+}
+
+type reports struct {
+	mu     sync.Mutex
+	states []schema.Aircraft
+}
+
+func (r *reports) Report(state schema.Aircraft) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states = append(r.states, state)
+}
+
+func (r *reports) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.states)
+}
+
+func TestAircraftJSONPoller(t *testing.T) {
+	var failing sync.Mutex
+	fail := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failing.Lock()
+		defer failing.Unlock()
+		if fail {
+			http.Error(w, "not yet", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(readsbAircraftJSON))
+	}))
+	defer srv.Close()
+
+	got := &reports{}
+	poller := NewAircraftJSONPoller(srv.URL+"/data/aircraft.json", 10*time.Millisecond, got)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- poller.Start(ctx) }()
+
+	require.Eventually(t, func() bool { return poller.Healthy() != nil }, time.Second, 5*time.Millisecond)
+	assert.ErrorContains(t, poller.Healthy(), "503")
+
+	failing.Lock()
+	fail = false
+	failing.Unlock()
+	require.Eventually(t, func() bool { return got.count() >= 6 }, time.Second, 5*time.Millisecond)
+	assert.NoError(t, poller.Healthy())
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
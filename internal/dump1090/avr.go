@@ -0,0 +1,103 @@
+package dump1090
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/models"
+)
+
+// Receiver output formats
+const (
+	FormatBeast = "beast" // Binary Beast frames, as dump1090 serves on port 30005
+	FormatAVR   = "avr"   // AVR hex text, as dump1090 serves on port 30002
+)
+
+// avrTicksLen is the hex digits of the 48-bit timestamp an @ frame starts with
+const avrTicksLen = 12
+
+// parseAVR parses one AVR frame: *<hex>; or, with the receiver's timestamp as dump1090's MLAT output has it,
+// @<12 hex digits of timestamp><hex>;. The frame type follows from the data's length; AVR carries no signal level.
+func parseAVR(frame string, arrived time.Time) (*models.BeastMessage, error) {
+	frame = strings.TrimSpace(frame)
+	if len(frame) < 2 || !strings.HasSuffix(frame, ";") {
+		return nil, fmt.Errorf("not an AVR frame: %q", frame)
+	}
+	body := frame[1 : len(frame)-1]
+	var ticks uint64
+	switch frame[0] {
+	case '*':
+	case '@':
+		if len(body) < avrTicksLen {
+			return nil, fmt.Errorf("AVR frame too short for a timestamp: %q", frame)
+		}
+		var err error
+		if ticks, err = strconv.ParseUint(body[:avrTicksLen], 16, 64); err != nil {
+			return nil, fmt.Errorf("invalid AVR timestamp: %q", frame)
+		}
+		body = body[avrTicksLen:]
+	default:
+		return nil, fmt.Errorf("unknown AVR frame start %q", frame[0])
+	}
+
+	data, err := hex.DecodeString(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AVR data: %w", err)
+	}
+	var typeCode byte
+	switch len(data) {
+	case models.BeastDataLenModeAC:
+		typeCode = models.BeastTypeModeAC
+	case models.BeastDataLenModeSShort:
+		typeCode = models.BeastTypeModeSShort
+	case models.BeastDataLenModeSLong:
+		typeCode = models.BeastTypeModeSLong
+	default:
+		return nil, fmt.Errorf("AVR frame of %d bytes is no message length", len(data))
+	}
+	return models.NewBeastMessage(typeCode, ticks, 0, data, arrived)
+}
+
+// maxAVRFrame bounds the text kept for one frame; the longest, an @ frame of a long message, is 42 characters
+const maxAVRFrame = 64
+
+// avrReader parses the frames of an AVR stream, which ends each with a semicolon and usually a newline
+type avrReader struct {
+	r       *bufio.Reader
+	pending []byte // The start of a frame cut short by a read timeout, completed by the next read
+}
+
+func (a *avrReader) next() (*models.BeastMessage, error) {
+	for {
+		chunk, err := a.r.ReadSlice(';')
+		a.pending = append(a.pending, chunk...)
+		if err == bufio.ErrBufferFull || len(a.pending) > maxAVRFrame {
+			n := len(a.pending)
+			a.pending = a.pending[:0]
+			return nil, fmt.Errorf("%w: no AVR frame end in %d bytes", errInvalidFrame, n)
+		}
+		if err != nil {
+			if err == io.EOF && len(bytes.TrimSpace(a.pending)) > 0 {
+				a.pending = a.pending[:0]
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err // A timeout keeps the frame's start for the next read
+		}
+		frame := string(bytes.TrimSpace(a.pending))
+		a.pending = a.pending[:0]
+		if frame == ";" {
+			continue
+		}
+		msg, err := parseAVR(frame, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidFrame, err)
+		}
+		return msg, nil
+	}
+}
@@ -0,0 +1,93 @@
+package dump1090
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAVR(t *testing.T) {
+	arrived := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	msg, err := parseAVR("*8DA05629EA21485CBF3F8CADAEEB;", arrived)
+	require.NoError(t, err)
+	assert.Equal(t, models.BeastTypeModeSLong, msg.MessageTypeCode)
+	assert.Equal(t, "A05629", msg.ICAO)
+	assert.Zero(t, msg.Ticks)
+	assert.Equal(t, arrived, msg.Timestamp)
+
+	msg, err = parseAVR("@0000001234565d4ca2d31e2b00;\n", arrived)
+	require.NoError(t, err)
+	assert.Equal(t, models.BeastTypeModeSShort, msg.MessageTypeCode)
+	assert.Equal(t, uint64(0x123456), msg.Ticks)
+	assert.Equal(t, "4CA2D3", msg.ICAO)
+
+	msg, err = parseAVR("*1234;", arrived)
+	require.NoError(t, err)
+	assert.Equal(t, models.BeastTypeModeAC, msg.MessageTypeCode)
+
+	for _, frame := range []string{"", "*8DA05629", "*8DA0562;", "*8DA056;", "#8DA056;", "@00000012;", "*zz;"} {
+		_, err := parseAVR(frame, arrived)
+		assert.Error(t, err, frame)
+	}
+}
+
+func TestAVRReader(t *testing.T) {
+	// A frame cut short by a read timeout is completed by the next read
+	r, w := io.Pipe()
+	reader := newMessageReader(bufio.NewReader(&timeoutReader{r: r}), FormatAVR)
+	go func() {
+		w.Write([]byte("*1234;\r\n;\n*8DA056"))
+		w.Write([]byte("29EA21485CBF3F8CADAEEB;\n*8DA05;\n"))
+		w.Write([]byte(strings.Repeat("0", maxAVRFrame) + ";\n@0000001234565d4ca2d31e2b00;\n*8D"))
+		w.Close()
+	}()
+
+	var got []string
+	for {
+		msg, err := reader.next()
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			got = append(got, "timeout")
+			continue
+		}
+		if err != nil {
+			got = append(got, err.Error())
+			if !framingError(err) {
+				break
+			}
+			continue
+		}
+		got = append(got, strings.TrimSpace(msg.ICAO+" "+msg.MessageType))
+	}
+	assert.Equal(t, []string{
+		"mode_ac", "timeout", "A05629 extended_squitter",
+		"invalid frame: invalid AVR data: encoding/hex: odd length hex string",
+		"timeout", "invalid frame: no AVR frame end in 66 bytes",
+		"4CA2D3 surveillance", "timeout", "unexpected EOF",
+	}, got)
+}
+
+// timeoutReader reads a pipe one write at a time, failing with a timeout between writes as a connection with a
+// read deadline does
+type timeoutReader struct {
+	r       io.Reader
+	pending bool
+}
+
+func (t *timeoutReader) Read(p []byte) (int, error) {
+	if t.pending {
+		t.pending = false
+		return 0, os.ErrDeadlineExceeded
+	}
+	t.pending = true
+	return t.r.Read(p)
+}
@@ -3,52 +3,240 @@ package dump1090
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"flight_trmnl/internal/database"
 	"flight_trmnl/internal/models"
 )
 
-// BeastClient streams Beast format messages from dump1090
+// connIDs numbers receiver connections across clients, so each one's ID is unique in the logs
+var connIDs atomic.Uint64
+
+// errReconfigured ends reading from a connection whose settings were changed by Reconfigure
+var errReconfigured = errors.New("reconfigured")
+
+// BeastClient streams messages from dump1090, reading its Beast output or, for receivers that only serve port 30002,
+// its AVR output. The connection belongs to the goroutine running StreamMessages; other goroutines only change the
+// settings and signal it over the control channel, so Close and Reconfigure are safe to call at any time.
 type BeastClient struct {
-	conn         net.Conn
-	reader       *bufio.Reader
-	addr         string
 	maxRetries   int
 	retryBackoff time.Duration
+
+	mu    sync.Mutex
+	input Input // Changed by Reconfigure
+
+	control   chan struct{} // Wakes the streaming goroutine to apply changed settings
+	done      chan struct{} // Closed by Close
+	closeOnce sync.Once
+
+	messages    atomic.Int64
+	parseErrors atomic.Int64
+	reconnects  atomic.Int64
+	connected   atomic.Bool
+
+	connections database.ConnectionRepository // Records connects and disconnects, nil records nothing
+
+	capture atomic.Pointer[Capture] // The raw byte capture in progress, if any
+
+	// Owned by the streaming goroutine
+	recorded  bool      // Whether anything was recorded since streaming started
+	changedAt time.Time // When the client last connected or lost its connection
+
+	// The current or last connection, set by the streaming goroutine
+	conn   net.Conn
+	reader messageReader
+	active Input              // The settings it was made with
+	clock  *models.BeastClock // Times the messages received over it
+	connID string             // e.g. beast-3, set on each message received over it
+	seq    uint64             // Messages received over it
+	log    *slog.Logger       // Logs with its conn ID
+}
+
+// Input is where a client connects and how it reads what the receiver sends
+type Input struct {
+	Addr       string // host:port; empty leaves the client detached
+	Format     string // FormatBeast (the default) or FormatAVR
+	Timestamps string // The receiver's timestamp format, models.ClockFreeRunning (the default) or models.ClockGPS
+	Transport  Transport
+	Receiver   string // Set on each message received, so receivers feeding one pipeline can be told apart
+}
+
+// ClientStats counts what the client has received since it was created
+type ClientStats struct {
+	Messages    int64
+	ParseErrors int64 // Unknown frame types, frames that failed to parse, and lost sync
+	Reconnects  int64 // Connections lost after having been established
+	Connected   bool
+	Detached    bool // No input is configured, so the client isn't trying to connect
 }
 
+// Stats returns the client's counters; it is safe to call while streaming
+func (c *BeastClient) Stats() ClientStats {
+	return ClientStats{
+		Messages:    c.messages.Load(),
+		ParseErrors: c.parseErrors.Load(),
+		Reconnects:  c.reconnects.Load(),
+		Connected:   c.connected.Load(),
+		Detached:    c.Addr() == "",
+	}
+}
+
+// NewBeastClient creates a client for the Beast output at addr; without one it stays detached until Reconfigure
+// gives it one
 func NewBeastClient(addr string) *BeastClient {
 	return &BeastClient{
-		addr:         addr,
+		input:        Input{Addr: addr, Format: FormatBeast, Timestamps: models.ClockFreeRunning},
 		maxRetries:   -1, // -1 means infinite retries
 		retryBackoff: 1 * time.Second,
+		control:      make(chan struct{}, 1),
+		done:         make(chan struct{}),
+		log:          slog.Default(),
 	}
 }
 
-// connect establishes a TCP connection to dump1090
-func (c *BeastClient) connect(ctx context.Context) error {
-	dialer := net.Dialer{
-		Timeout: 5 * time.Second,
+// Addr returns the address the client connects to
+func (c *BeastClient) Addr() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.input.Addr
+}
+
+// settings returns the input to connect to
+func (c *BeastClient) settings() Input {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.input
+}
+
+// RecordConnections stores each connect and disconnect, so outages can be reviewed later
+func (c *BeastClient) RecordConnections(repo database.ConnectionRepository) {
+	c.connections = repo
+}
+
+// TimestampFormat sets the format of the receiver's timestamps, models.ClockFreeRunning (the default) or
+// models.ClockGPS for a Radarcape or another receiver with GPS-synchronized timestamps
+func (c *BeastClient) TimestampFormat(format string) error {
+	in := c.settings()
+	in.Timestamps = format
+	return c.Reconfigure(in)
+}
+
+// Reconfigure switches the client to another input while it streams: the current connection is dropped, recorded
+// as shut down since the input is no longer used, and the new one made right away. An empty address detaches the
+// client from its input until it is given another.
+func (c *BeastClient) Reconfigure(in Input) error {
+	if in.Format == "" {
+		in.Format = FormatBeast
+	}
+	if in.Timestamps == "" {
+		in.Timestamps = models.ClockFreeRunning
 	}
+	if in.Format != FormatBeast && in.Format != FormatAVR {
+		return fmt.Errorf("unknown input format %q (must be %s or %s)", in.Format, FormatBeast, FormatAVR)
+	}
+	if _, err := models.NewBeastClock(in.Timestamps); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	changed := in != c.input
+	c.input = in
+	c.mu.Unlock()
+	if changed {
+		select {
+		case c.control <- struct{}{}:
+		default: // Already signalled, the goroutine reads the latest settings
+		}
+	}
+	return nil
+}
+
+// reconfigured reports whether the settings changed since the current connection was made
+func (c *BeastClient) reconfigured() bool {
+	return c.settings() != c.active
+}
+
+// recordConnection stores a connect or disconnect and how long the previous state lasted
+func (c *BeastClient) recordConnection(eventType, reason string) {
+	now := time.Now()
+	duration := now.Sub(c.changedAt).Seconds()
+	c.changedAt = now
+	if c.connections == nil {
+		return
+	}
+	c.recorded = true
+	event := &database.ConnectionEvent{Input: c.active.Addr, Type: eventType, Time: now, Reason: reason, Duration: duration}
+	if err := c.connections.Insert(event); err != nil {
+		slog.Warn("Failed to record connection event", "addr", c.active.Addr, "error", err)
+	}
+}
 
-	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+// connect establishes a connection to dump1090, through the input's tunnel if it has one
+func (c *BeastClient) connect(ctx context.Context) error {
+	in := c.settings()
+	conn, err := dial(ctx, in)
 	if err != nil {
-		return fmt.Errorf("failed to connect to %s: %w", c.addr, err)
+		return fmt.Errorf("failed to connect to %s: %w", in.Addr, err)
 	}
 
 	c.conn = conn
-	c.reader = bufio.NewReader(conn)
+	c.active = in
+	c.reader = newMessageReader(bufio.NewReader(&tapReader{conn: conn, client: c}), in.Format)
+	c.connID = fmt.Sprintf("beast-%d", connIDs.Add(1))
+	c.seq = 0
+	c.log = slog.With("conn", c.connID)
+	if in.Receiver != "" {
+		c.log = c.log.With("receiver", in.Receiver)
+	}
+	// A reconnected receiver may have restarted, so its counter is anchored anew
+	c.clock, _ = models.NewBeastClock(in.Timestamps) // Checked by Reconfigure
 	return nil
 }
 
+// StreamMessages connects and sends the messages received to messageChan, reconnecting when the connection is
+// lost, until the context is cancelled or the client closed. Only one goroutine may stream at a time.
 func (c *BeastClient) StreamMessages(ctx context.Context, messageChan chan<- *models.BeastMessage) error {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-c.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err := c.stream(ctx, messageChan)
+	if parent.Err() == nil && c.closed() {
+		return nil // Closed rather than cancelled
+	}
+	return err
+}
+
+// stream runs the connection until the context is cancelled
+func (c *BeastClient) stream(ctx context.Context, messageChan chan<- *models.BeastMessage) error {
 	retryCount := 0
 	backoff := c.retryBackoff
 
+	// The time until the next start is neither up nor down, so stopping is recorded too
+	c.changedAt, c.recorded = time.Now(), false
+	defer func() {
+		c.closeConnection()
+		if cp := c.capture.Load(); cp != nil {
+			cp.finish()
+		}
+		if c.recorded {
+			c.recordConnection(database.ConnectionDown, database.ReasonShutdown)
+		}
+	}()
+
 	for {
 		// Check if context is cancelled
 		select {
@@ -59,6 +247,15 @@ func (c *BeastClient) StreamMessages(ctx context.Context, messageChan chan<- *mo
 
 		// Connect if not connected
 		if c.conn == nil {
+			if c.Addr() == "" {
+				// Detached, wait for an input
+				select {
+				case <-c.control:
+					continue
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
 			if err := c.connect(ctx); err != nil {
 				if ctx.Err() != nil {
 					return ctx.Err()
@@ -68,8 +265,16 @@ func (c *BeastClient) StreamMessages(ctx context.Context, messageChan chan<- *mo
 				if c.maxRetries > 0 && retryCount > c.maxRetries {
 					return fmt.Errorf("max retries (%d) exceeded", c.maxRetries)
 				}
-				slog.Warn("Failed to connect to Beast server", "addr", c.addr, "retry", retryCount, "error", err)
-				time.Sleep(backoff)
+				slog.Warn("Failed to connect to Beast server", "retry", retryCount, "error", err)
+				select {
+				case <-time.After(backoff):
+				case <-c.control:
+					// Try the new settings right away
+					retryCount, backoff = 0, c.retryBackoff
+					continue
+				case <-ctx.Done():
+					return ctx.Err()
+				}
 				// Exponential backoff: 1s, 2s, 4s, 8s, max 30s
 				backoff = backoff * 2
 				if backoff > 30*time.Second {
@@ -80,15 +285,31 @@ func (c *BeastClient) StreamMessages(ctx context.Context, messageChan chan<- *mo
 			// Connection successful, reset retry state
 			retryCount = 0
 			backoff = c.retryBackoff
-			slog.Info("Connected to Beast server", "addr", c.addr)
+			c.connected.Store(true)
+			c.recordConnection(database.ConnectionUp, "")
+			c.log.Info("Connected to Beast server", "addr", c.active.Addr, "format", c.active.Format)
 		}
 
 		// Read messages in a loop
 		err := c.readMessages(ctx, messageChan)
 		if err != nil {
-			// Connection error, close and reconnect
-			slog.Warn("Connection error, reconnecting", "error", err)
 			c.closeConnection()
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err == errReconfigured {
+				if addr := c.Addr(); addr != "" {
+					c.log.Info("Reconnecting with new settings", "last_seq", c.seq, "addr", addr)
+				} else {
+					c.log.Info("Detached from the input", "last_seq", c.seq, "addr", c.active.Addr)
+				}
+				c.recordConnection(database.ConnectionDown, database.ReasonShutdown)
+				continue
+			}
+			// Connection error, reconnect
+			c.log.Warn("Connection error, reconnecting", "last_seq", c.seq, "error", err)
+			c.reconnects.Add(1)
+			c.recordConnection(database.ConnectionDown, err.Error())
 			// Don't return, just continue to reconnect
 			continue
 		}
@@ -98,38 +319,6 @@ func (c *BeastClient) StreamMessages(ctx context.Context, messageChan chan<- *mo
 	}
 }
 
-// readBytesWithEscape reads n bytes, handling BeastStartByte escape sequences
-// If BeastStartByte is encountered, it reads the next byte. If that's also BeastStartByte, it's an escaped byte.
-// If it's not BeastStartByte, we're out of sync (unexpected new message start).
-func (c *BeastClient) readBytesWithEscape(n int) ([]byte, error) {
-	data := make([]byte, 0, n)
-	for len(data) < n {
-		b, err := c.reader.ReadByte()
-		if err != nil {
-			return nil, err
-		}
-
-		if b == models.BeastStartByte {
-			// Check if this is an escape sequence
-			next, err := c.reader.Peek(1)
-			if err != nil {
-				return nil, err
-			}
-			if next[0] == models.BeastStartByte {
-				// Escaped BeastStartByte, consume it
-				c.reader.ReadByte()
-				data = append(data, models.BeastStartByte)
-			} else {
-				// Unexpected new message start - sync loss
-				return nil, fmt.Errorf("unexpected %02x in data (possible sync loss)", models.BeastStartByte)
-			}
-		} else {
-			data = append(data, b)
-		}
-	}
-	return data, nil
-}
-
 // handleReadError handles read errors, returning nil for timeouts (to retry) and errors for other cases
 func (c *BeastClient) handleReadError(err error) error {
 	if err == nil {
@@ -149,6 +338,10 @@ func (c *BeastClient) readMessages(ctx context.Context, messageChan chan<- *mode
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
+		case <-c.control:
+			if c.reconfigured() {
+				return errReconfigured
+			}
 		default:
 		}
 
@@ -157,75 +350,23 @@ func (c *BeastClient) readMessages(ctx context.Context, messageChan chan<- *mode
 			return fmt.Errorf("failed to set read deadline: %w", err)
 		}
 
-		// Read start byte
-		startByte, err := c.reader.ReadByte()
-		if processedErr := c.handleReadError(err); processedErr != nil {
-			return fmt.Errorf("failed to read start byte: %w", processedErr)
-		}
-		if err != nil {
-			continue // Timeout, retry
-		}
-
-		if startByte != models.BeastStartByte {
-			slog.Debug("Skipping byte, not a message start", "byte", startByte)
+		beastMsg, err := c.reader.next()
+		if framingError(err) {
+			c.log.Debug("Skipping frame", "after_seq", c.seq, "error", err)
+			c.parseErrors.Add(1)
 			continue
 		}
-
-		// Read type byte (should not be escaped, but handle it just in case)
-		typeByte, err := c.reader.ReadByte()
 		if processedErr := c.handleReadError(err); processedErr != nil {
-			return fmt.Errorf("failed to read type byte: %w", processedErr)
+			return fmt.Errorf("failed to read frame: %w", processedErr)
 		}
 		if err != nil {
 			continue // Timeout, retry
 		}
 
-		// Handle escape sequence for type byte (unlikely but possible)
-		if typeByte == models.BeastStartByte {
-			next, err := c.reader.Peek(1)
-			if processedErr := c.handleReadError(err); processedErr != nil {
-				return fmt.Errorf("failed to peek after %02x in type: %w", models.BeastStartByte, processedErr)
-			}
-			if err != nil {
-				continue // Timeout, retry
-			}
-			if next[0] == models.BeastStartByte {
-				// Escaped BeastStartByte, consume it
-				c.reader.ReadByte()
-				typeByte = models.BeastStartByte
-			} else {
-				// New message start, continue
-				continue
-			}
-		}
-
-		totalLen, err := models.GetBeastTotalLen(typeByte)
-		if err != nil {
-			slog.Debug("Unknown message type", "type", typeByte, "error", err)
-			continue
-		}
-
-		// Read remaining bytes (timestamp + signal + message data) all at once
-		// We've already read 2 bytes (start + type), so read totalLen - 2
-		remainingBytes, err := c.readBytesWithEscape(totalLen - models.BeastHeaderLen)
-		if processedErr := c.handleReadError(err); processedErr != nil {
-			return fmt.Errorf("failed to read message body: %w", processedErr)
-		}
-		if err != nil {
-			continue // Timeout, retry
-		}
-
-		// Assemble full message
-		fullMessage := make([]byte, 0, totalLen)
-		fullMessage = append(fullMessage, models.BeastStartByte, typeByte)
-		fullMessage = append(fullMessage, remainingBytes...)
-
-		beastMsg, err := models.ParseBeastMessage(fullMessage)
-		if err != nil {
-			// Log but continue
-			slog.Debug("Failed to parse Beast message", "error", err)
-			continue
-		}
+		c.messages.Add(1)
+		c.seq++
+		beastMsg.ConnID, beastMsg.Seq, beastMsg.Receiver = c.connID, c.seq, c.active.Receiver
+		beastMsg.Timestamp = c.clock.Time(beastMsg.Ticks, beastMsg.Timestamp)
 
 		select {
 		case messageChan <- beastMsg:
@@ -235,8 +376,9 @@ func (c *BeastClient) readMessages(ctx context.Context, messageChan chan<- *mode
 	}
 }
 
-// closeConnection closes the current connection
+// closeConnection closes the current connection; only the streaming goroutine may call it
 func (c *BeastClient) closeConnection() {
+	c.connected.Store(false)
 	if c.conn != nil {
 		c.conn.Close()
 		c.conn = nil
@@ -244,8 +386,19 @@ func (c *BeastClient) closeConnection() {
 	}
 }
 
-// Close closes the connection
+// Close stops streaming: StreamMessages closes the connection and returns within the read deadline. It is safe
+// to call from any goroutine and more than once; a closed client can't stream again.
 func (c *BeastClient) Close() error {
-	c.closeConnection()
+	c.closeOnce.Do(func() { close(c.done) })
 	return nil
 }
+
+// closed reports whether Close was called
+func (c *BeastClient) closed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
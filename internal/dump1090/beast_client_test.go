@@ -0,0 +1,235 @@
+package dump1090
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockConnections collects recorded connection events
+type mockConnections struct {
+	mu     sync.Mutex
+	events []*database.ConnectionEvent
+}
+
+func (m *mockConnections) Insert(event *database.ConnectionEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *mockConnections) List(since time.Time, limit int) ([]*database.ConnectionEvent, error) {
+	return nil, nil
+}
+
+func (m *mockConnections) Uptime(since, now time.Time) ([]*database.InputUptime, error) {
+	return nil, nil
+}
+
+func (m *mockConnections) types() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var types []string
+	for _, e := range m.events {
+		types = append(types, e.Type+" "+e.Reason)
+	}
+	return types
+}
+
+func TestBeastClient_RecordsConnections(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	// Send one frame and hang up, then keep the second connection open
+	frame := []byte{0x1a, '2', 0, 0, 0, 0, 0, 0, 0x80, 0x5d, 0x4c, 0xa2, 0xd3, 0x1e, 0x2b, 0x00}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write(frame)
+		conn.Close()
+		if conn, err = ln.Accept(); err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+
+	client := NewBeastClient(ln.Addr().String())
+	client.retryBackoff = 10 * time.Millisecond
+	connections := &mockConnections{}
+	client.RecordConnections(connections)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	messages := make(chan *models.BeastMessage, 10)
+	done := make(chan error)
+	go func() { done <- client.StreamMessages(ctx, messages) }()
+
+	msg := <-messages
+	assert.Regexp(t, `^beast-\d+$`, msg.ConnID)
+	assert.Equal(t, uint64(1), msg.Seq)
+	require.Eventually(t, func() bool { return len(connections.types()) == 3 }, 3*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Equal(t, []string{"connect ", "disconnect failed to read frame: connection closed", "connect ", "disconnect shutdown"}, connections.types())
+	stats := client.Stats()
+	assert.Equal(t, int64(1), stats.Messages)
+	assert.Equal(t, int64(1), stats.Reconnects, "stopping isn't a reconnect")
+	assert.False(t, stats.Connected)
+}
+
+func TestBeastClient_Reconfigure(t *testing.T) {
+	// Each receiver sends one frame and keeps the connection open
+	frame := []byte{0x1a, '2', 0, 0, 0, 0, 0, 0, 0x80, 0x5d, 0x4c, 0xa2, 0xd3, 0x1e, 0x2b, 0x00}
+	listen := func() net.Listener {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			conn.Write(frame)
+			time.Sleep(5 * time.Second)
+		}()
+		return ln
+	}
+	first, second := listen(), listen()
+	defer first.Close()
+	defer second.Close()
+
+	client := NewBeastClient(first.Addr().String())
+	connections := &mockConnections{}
+	client.RecordConnections(connections)
+
+	messages := make(chan *models.BeastMessage, 10)
+	done := make(chan error)
+	go func() { done <- client.StreamMessages(context.Background(), messages) }()
+
+	before := <-messages
+	require.NoError(t, client.Reconfigure(Input{Addr: second.Addr().String(), Timestamps: models.ClockFreeRunning}))
+	assert.Equal(t, second.Addr().String(), client.Addr())
+	after := <-messages
+	assert.NotEqual(t, before.ConnID, after.ConnID)
+	assert.Error(t, client.Reconfigure(Input{Addr: second.Addr().String(), Timestamps: "utc"}))
+
+	require.NoError(t, client.Close())
+	require.NoError(t, client.Close(), "closing twice is fine")
+	select {
+	case err := <-done:
+		assert.NoError(t, err, "closing isn't an error")
+	case <-time.After(3 * time.Second):
+		t.Fatal("streaming didn't stop on Close")
+	}
+
+	connections.mu.Lock()
+	defer connections.mu.Unlock()
+	var events []string
+	for _, e := range connections.events {
+		events = append(events, e.Input+" "+e.Type+" "+e.Reason)
+	}
+	assert.Equal(t, []string{
+		first.Addr().String() + " connect ",
+		first.Addr().String() + " disconnect shutdown",
+		second.Addr().String() + " connect ",
+		second.Addr().String() + " disconnect shutdown",
+	}, events)
+	stats := client.Stats()
+	assert.Equal(t, int64(0), stats.Reconnects, "switching inputs isn't a reconnect")
+	assert.False(t, stats.Connected)
+}
+
+func TestBeastClient_Detached(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write([]byte{0x1a, '2', 0, 0, 0, 0, 0, 0, 0x80, 0x5d, 0x4c, 0xa2, 0xd3, 0x1e, 0x2b, 0x00})
+				time.Sleep(5 * time.Second)
+			}()
+		}
+	}()
+
+	client := NewBeastClient("")
+	assert.True(t, client.Stats().Detached)
+	_, err = client.StartCapture(t.TempDir(), time.Second)
+	assert.ErrorIs(t, err, ErrDetached)
+
+	messages := make(chan *models.BeastMessage, 10)
+	done := make(chan error)
+	go func() { done <- client.StreamMessages(context.Background(), messages) }()
+	defer func() {
+		client.Close()
+		<-done
+	}()
+
+	// Attaching an input connects, detaching drops the connection and waits for the next one
+	require.NoError(t, client.Reconfigure(Input{Addr: ln.Addr().String()}))
+	first := <-messages
+	require.Eventually(t, func() bool { return client.Stats().Connected }, 3*time.Second, 10*time.Millisecond)
+	require.NoError(t, client.Reconfigure(Input{}))
+	require.Eventually(t, func() bool { return !client.Stats().Connected }, 3*time.Second, 10*time.Millisecond)
+	assert.True(t, client.Stats().Detached)
+
+	require.NoError(t, client.Reconfigure(Input{Addr: ln.Addr().String()}))
+	second := <-messages
+	assert.NotEqual(t, first.ConnID, second.ConnID)
+}
+
+func TestBeastClient_AVR(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("*8DA05629EA21485CBF3F8CADAEEB;\n*8DA05;\n@0000001234565d4ca2d31e2b00;\n"))
+		time.Sleep(5 * time.Second)
+	}()
+
+	client := NewBeastClient("")
+	require.NoError(t, client.Reconfigure(Input{Addr: ln.Addr().String(), Format: FormatAVR, Receiver: "roof"}))
+	assert.Error(t, client.Reconfigure(Input{Addr: ln.Addr().String(), Format: "sbs"}))
+
+	messages := make(chan *models.BeastMessage, 10)
+	done := make(chan error)
+	go func() { done <- client.StreamMessages(context.Background(), messages) }()
+	defer func() {
+		client.Close()
+		<-done
+	}()
+
+	first, second := <-messages, <-messages
+	assert.Equal(t, "A05629", first.ICAO)
+	assert.Equal(t, models.BeastTypeModeSLong, first.MessageTypeCode)
+	assert.Equal(t, "4CA2D3", second.ICAO)
+	assert.Equal(t, uint64(0x123456), second.Ticks)
+	assert.Equal(t, first.ConnID, second.ConnID)
+	assert.Equal(t, uint64(2), second.Seq)
+	assert.Equal(t, "roof", second.Receiver)
+	stats := client.Stats()
+	assert.Equal(t, int64(2), stats.Messages)
+	assert.Equal(t, int64(1), stats.ParseErrors)
+}
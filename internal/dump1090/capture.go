@@ -0,0 +1,304 @@
+package dump1090
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"flight_trmnl/internal/models"
+)
+
+// MaxCaptureDuration bounds a capture, so a forgotten request can't fill the disk
+const MaxCaptureDuration = 5 * time.Minute
+
+// ErrCaptureRunning is returned when a capture is requested while another one is in progress
+var ErrCaptureRunning = errors.New("a capture is already running")
+
+// ErrDetached is returned when a capture is requested from a client without an input
+var ErrDetached = errors.New("no input is configured")
+
+// CaptureSummary counts what the annotated log found in the captured bytes
+type CaptureSummary struct {
+	Bytes        int64 `json:"bytes"`
+	Frames       int   `json:"frames"`
+	Escapes      int   `json:"escapes"`       // Escaped 1a bytes inside frames
+	SyncLosses   int   `json:"sync_losses"`   // Frames cut short by an unescaped 1a
+	UnknownTypes int   `json:"unknown_types"` // Frame starts with an unknown type byte
+	Skipped      int   `json:"skipped_bytes"` // Bytes outside any frame
+	Truncated    bool  `json:"truncated"`     // The capture ended inside a frame
+}
+
+// CaptureResult describes a finished capture and where its files are
+type CaptureResult struct {
+	Input   string    `json:"input"`
+	File    string    `json:"file"` // The raw bytes exactly as read from the connection
+	Log     string    `json:"log"`  // One line per frame and per problem found in the raw bytes
+	Started time.Time `json:"started"`
+	Seconds float64   `json:"seconds"`
+	CaptureSummary
+}
+
+// Capture is a capture in progress; the raw bytes are annotated once it ends
+type Capture struct {
+	client *BeastClient
+	file   *os.File
+	timer  *time.Timer
+	once   sync.Once
+	done   chan struct{}
+
+	mu       sync.Mutex // Guards the file, timer, and write error
+	writeErr error
+
+	result *CaptureResult
+	err    error
+}
+
+// StartCapture writes the next d of raw bytes read from the receiver to a new file in dir.
+// Only one capture runs at a time, and stopping the stream ends it early.
+func (c *BeastClient) StartCapture(dir string, d time.Duration) (*Capture, error) {
+	if d <= 0 || d > MaxCaptureDuration {
+		return nil, fmt.Errorf("capture duration must be between 1s and %s", MaxCaptureDuration)
+	}
+	if c.Addr() == "" {
+		return nil, ErrDetached
+	}
+	if c.capture.Load() != nil {
+		return nil, ErrCaptureRunning
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create capture directory: %w", err)
+	}
+
+	started := time.Now()
+	path := filepath.Join(dir, "beast-"+started.Format("20060102-150405")+".bin")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create capture file: %w", err)
+	}
+
+	cp := &Capture{
+		client: c,
+		file:   file,
+		done:   make(chan struct{}),
+		result: &CaptureResult{Input: c.Addr(), File: path, Log: path[:len(path)-len(".bin")] + ".log", Started: started},
+	}
+	// Set before the capture is published, since the streaming goroutine may finish it at once
+	cp.mu.Lock()
+	cp.timer = time.AfterFunc(d, cp.finish)
+	cp.mu.Unlock()
+	if !c.capture.CompareAndSwap(nil, cp) {
+		cp.timer.Stop()
+		file.Close()
+		os.Remove(path)
+		return nil, ErrCaptureRunning
+	}
+	slog.Info("Capturing raw receiver bytes", "addr", c.Addr(), "file", path, "duration", d)
+	return cp, nil
+}
+
+// Wait blocks until the capture has ended and been annotated, or the context is cancelled
+func (cp *Capture) Wait(ctx context.Context) (*CaptureResult, error) {
+	select {
+	case <-cp.done:
+		return cp.result, cp.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// write appends bytes read from the connection; a failed write ends the capture early
+func (cp *Capture) write(p []byte) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if cp.writeErr != nil {
+		return
+	}
+	if _, err := cp.file.Write(p); err != nil {
+		cp.writeErr = err
+		go cp.finish()
+	}
+}
+
+// finish stops capturing, closes the file, and writes the annotated log next to it
+func (cp *Capture) finish() {
+	cp.once.Do(func() {
+		defer close(cp.done)
+		cp.client.capture.CompareAndSwap(cp, nil)
+
+		cp.mu.Lock()
+		cp.timer.Stop()
+		writeErr := cp.writeErr
+		closeErr := cp.file.Close()
+		cp.mu.Unlock()
+		cp.result.Seconds = time.Since(cp.result.Started).Seconds()
+
+		switch {
+		case writeErr != nil:
+			cp.err = fmt.Errorf("failed to write capture file: %w", writeErr)
+		case closeErr != nil:
+			cp.err = fmt.Errorf("failed to write capture file: %w", closeErr)
+		default:
+			cp.err = cp.annotate()
+		}
+		if cp.err != nil {
+			slog.Warn("Capture failed", "file", cp.result.File, "error", cp.err)
+			return
+		}
+		slog.Info("Capture finished", "file", cp.result.File, "bytes", cp.result.Bytes, "frames", cp.result.Frames)
+	})
+}
+
+func (cp *Capture) annotate() error {
+	data, err := os.ReadFile(cp.result.File)
+	if err != nil {
+		return fmt.Errorf("failed to read capture file: %w", err)
+	}
+	logFile, err := os.Create(cp.result.Log)
+	if err != nil {
+		return fmt.Errorf("failed to create capture log: %w", err)
+	}
+	defer logFile.Close()
+
+	fmt.Fprintf(logFile, "# Raw Beast bytes from %s, %s for %.0fs (%s)\n",
+		cp.result.Input, cp.result.Started.UTC().Format(time.RFC3339), cp.result.Seconds, filepath.Base(cp.result.File))
+	fmt.Fprintf(logFile, "# offset  type  timestamp    sig  message (escapes removed)\n")
+	summary, err := AnnotateBeast(data, logFile)
+	if err != nil {
+		return fmt.Errorf("failed to write capture log: %w", err)
+	}
+	cp.result.CaptureSummary = summary
+	return nil
+}
+
+// tapReader passes reads through to the connection, copying them to the running capture if any
+type tapReader struct {
+	conn   net.Conn
+	client *BeastClient
+}
+
+func (t *tapReader) Read(p []byte) (int, error) {
+	n, err := t.conn.Read(p)
+	if n > 0 {
+		if cp := t.client.capture.Load(); cp != nil {
+			cp.write(p[:n])
+		}
+	}
+	return n, err
+}
+
+// AnnotateBeast walks raw Beast bytes the way the client reads them and writes one line per frame,
+// with escapes removed and counted, and one line per problem: unknown types, lost sync, and bytes outside a frame.
+// Offsets are of the frame's start byte in the raw data.
+func AnnotateBeast(data []byte, w io.Writer) (CaptureSummary, error) {
+	summary := CaptureSummary{Bytes: int64(len(data))}
+	ew := &errWriter{w: w}
+
+	skipStart, skipped := 0, 0
+	flushSkipped := func() {
+		if skipped > 0 {
+			ew.printf("%08x  skipped %d bytes outside a frame\n", skipStart, skipped)
+			summary.Skipped += skipped
+			skipped = 0
+		}
+	}
+
+	i := 0
+	for i < len(data) {
+		if data[i] != models.BeastStartByte || (i+1 < len(data) && data[i+1] == models.BeastStartByte) {
+			// An escaped 1a outside a frame is still two bytes of noise
+			step := 1
+			if data[i] == models.BeastStartByte {
+				step = 2
+			}
+			if skipped == 0 {
+				skipStart = i
+			}
+			skipped += step
+			i += step
+			continue
+		}
+		if i+1 >= len(data) {
+			flushSkipped()
+			ew.printf("%08x  capture ended after a start byte\n", i)
+			summary.Truncated = true
+			break
+		}
+		flushSkipped()
+
+		start, typeByte := i, data[i+1]
+		totalLen, err := models.GetBeastTotalLen(typeByte)
+		if err != nil {
+			ew.printf("%08x  unknown frame type %02x\n", start, typeByte)
+			summary.UnknownTypes++
+			i += 2
+			continue
+		}
+
+		body := make([]byte, 0, totalLen-models.BeastHeaderLen)
+		escapes := 0
+		i += 2
+		for len(body) < cap(body) && i < len(data) {
+			if data[i] != models.BeastStartByte {
+				body = append(body, data[i])
+				i++
+				continue
+			}
+			if i+1 >= len(data) {
+				i = len(data) // Can't tell an escape from a new frame at the very end
+				break
+			}
+			if data[i+1] == models.BeastStartByte {
+				body = append(body, models.BeastStartByte)
+				escapes++
+				i += 2
+				continue
+			}
+			break // An unescaped start byte inside the frame
+		}
+
+		switch {
+		case len(body) == cap(body):
+			summary.Frames++
+			summary.Escapes += escapes
+			line := fmt.Sprintf("%08x  %02x    %s %s  %s", start, typeByte,
+				hex.EncodeToString(body[:models.BeastTimestampLen]),
+				hex.EncodeToString(body[models.BeastTimestampLen:models.BeastTimestampLen+models.BeastSignalLen]),
+				hex.EncodeToString(body[models.BeastTimestampLen+models.BeastSignalLen:]))
+			if escapes > 0 {
+				line += fmt.Sprintf("  (%d escaped 1a)", escapes)
+			}
+			ew.printf("%s\n", line)
+		case i >= len(data):
+			ew.printf("%08x  %02x    capture ended inside the frame after %d of %d bytes\n", start, typeByte, len(body), cap(body))
+			summary.Truncated = true
+		default:
+			ew.printf("%08x  %02x    sync lost: unescaped 1a at %08x after %d of %d bytes\n", start, typeByte, i, len(body), cap(body))
+			summary.SyncLosses++
+		}
+	}
+	flushSkipped()
+
+	ew.printf("# %d bytes, %d frames, %d escaped 1a, %d sync losses, %d unknown types, %d bytes skipped\n",
+		summary.Bytes, summary.Frames, summary.Escapes, summary.SyncLosses, summary.UnknownTypes, summary.Skipped)
+	return summary, ew.err
+}
+
+// errWriter keeps the first write error, so a long run of prints can be checked once
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) printf(format string, args ...any) {
+	if e.err == nil {
+		_, e.err = fmt.Fprintf(e.w, format, args...)
+	}
+}
@@ -0,0 +1,108 @@
+package dump1090
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotateBeast(t *testing.T) {
+	var data []byte
+	// Noise before the first frame
+	data = append(data, 0x00, 0xff)
+	// Mode A/C
+	data = append(data, 0x1a, '1', 0, 0, 0, 0, 0, 1, 0x40, 0x12, 0x34)
+	// Escaped 1a in the timestamp
+	data = append(data, 0x1a, '2', 0, 0, 0, 0, 0x1a, 0x1a, 2, 0x50, 0x5d, 0x4c, 0xa2, 0xd3, 0x1e, 0x2b, 0x00)
+	// Unknown type
+	data = append(data, 0x1a, '7', 0x00)
+	// Sync lost, then Mode A/C
+	data = append(data, 0x1a, '2', 0, 0, 0, 0x1a, '1', 0, 0, 0, 0, 0, 1, 0x40, 0x12, 0x34)
+	// Cut off by the end of the capture
+	data = append(data, 0x1a, '3', 0, 0, 0)
+
+	var out bytes.Buffer
+	summary, err := AnnotateBeast(data, &out)
+	require.NoError(t, err)
+
+	assert.Equal(t, CaptureSummary{
+		Bytes:        int64(len(data)),
+		Frames:       3,
+		Escapes:      1,
+		SyncLosses:   1,
+		UnknownTypes: 1,
+		Skipped:      3,
+		Truncated:    true,
+	}, summary)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 9)
+	assert.Equal(t, "00000000  skipped 2 bytes outside a frame", lines[0])
+	assert.Equal(t, "00000002  31    000000000001 40  1234", lines[1])
+	assert.Equal(t, "0000000d  32    000000001a02 50  5d4ca2d31e2b00  (1 escaped 1a)", lines[2])
+	assert.Equal(t, "0000001e  unknown frame type 37", lines[3])
+	assert.Equal(t, "00000020  skipped 1 bytes outside a frame", lines[4])
+	assert.Equal(t, "00000021  32    sync lost: unescaped 1a at 00000026 after 3 of 14 bytes", lines[5])
+	assert.Equal(t, "00000026  31    000000000001 40  1234", lines[6])
+	assert.Equal(t, "00000031  33    capture ended inside the frame after 3 of 21 bytes", lines[7])
+	assert.True(t, strings.HasPrefix(lines[8], "# 54 bytes, 3 frames"))
+}
+
+func TestBeastClient_Capture(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	// Send a frame every few milliseconds until the client hangs up
+	frame := []byte{0x1a, '2', 0, 0, 0, 0, 0, 0, 0x80, 0x5d, 0x4c, 0xa2, 0xd3, 0x1e, 0x2b, 0x00}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			if _, err := conn.Write(frame); err != nil {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	client := NewBeastClient(ln.Addr().String())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	messages := make(chan *models.BeastMessage, 1000)
+	go client.StreamMessages(ctx, messages)
+	<-messages
+
+	dir := t.TempDir()
+	cp, err := client.StartCapture(dir, 200*time.Millisecond)
+	require.NoError(t, err)
+	_, err = client.StartCapture(dir, time.Second)
+	assert.ErrorIs(t, err, ErrCaptureRunning)
+
+	result, err := cp.Wait(context.Background())
+	require.NoError(t, err)
+	assert.Greater(t, result.Frames, 5)
+	assert.Zero(t, result.SyncLosses)
+
+	raw, err := os.ReadFile(result.File)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(raw)), result.Bytes)
+	annotated, err := os.ReadFile(result.Log)
+	require.NoError(t, err)
+	assert.Contains(t, string(annotated), "32    000000000000 80  5d4ca2d31e2b00")
+
+	_, err = client.StartCapture(dir, MaxCaptureDuration+time.Second)
+	assert.Error(t, err)
+}
@@ -0,0 +1,157 @@
+package dump1090
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"flight_trmnl/internal/models"
+)
+
+// Framing problems; readers can go on with the next frame after any of them
+var (
+	errSyncLost     = errors.New("unescaped 1a inside a frame (sync lost)")
+	errUnknownType  = errors.New("unknown frame type")
+	errInvalidFrame = errors.New("invalid frame") // Read whole, but not a message
+)
+
+// messageReader reads the messages a receiver sends in one of its output formats. next returns errors the way
+// frameReader.next does.
+type messageReader interface {
+	next() (*models.BeastMessage, error)
+}
+
+// newMessageReader returns a reader for the format, FormatBeast or FormatAVR
+func newMessageReader(r *bufio.Reader, format string) messageReader {
+	if format == FormatAVR {
+		return &avrReader{r: r}
+	}
+	return beastReader{frames: newFrameReader(r)}
+}
+
+// Reader reads the messages of a stream this package didn't open, e.g. one a station pushes to a hub
+type Reader struct {
+	r messageReader
+}
+
+// NewReader reads messages in the format, FormatBeast or FormatAVR, from r
+func NewReader(r io.Reader, format string) *Reader {
+	return &Reader{r: newMessageReader(bufio.NewReader(r), format)}
+}
+
+// Next returns the next message, timed by its arrival. A frame that can't be read gives an error IsFrameError
+// reports, after which the stream goes on; other errors are the stream's.
+func (r *Reader) Next() (*models.BeastMessage, error) {
+	return r.r.next()
+}
+
+// IsFrameError reports whether err is a frame that couldn't be read rather than a problem with the stream
+func IsFrameError(err error) bool {
+	return framingError(err)
+}
+
+// beastReader parses the frames of a Beast stream
+type beastReader struct {
+	frames *frameReader
+}
+
+func (b beastReader) next() (*models.BeastMessage, error) {
+	frame, err := b.frames.next()
+	if err != nil {
+		return nil, err
+	}
+	msg, err := models.ParseBeastMessage(frame)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidFrame, err)
+	}
+	return msg, nil
+}
+
+// frameReader splits a Beast byte stream into frames with the escapes removed, ready for models.ParseBeastMessage.
+// The TCP client reads a connection through one, the UDP listener each datagram.
+type frameReader struct {
+	r *bufio.Reader
+	// A start byte was read without the frame it starts, e.g. the one that cut the last frame short
+	started bool
+}
+
+func newFrameReader(r *bufio.Reader) *frameReader {
+	return &frameReader{r: r}
+}
+
+// next returns the next frame, skipping bytes outside a frame. Read errors, including timeouts, are returned as
+// they are, except io.ErrUnexpectedEOF for a stream ending inside a frame; errSyncLost and errUnknownType drop a
+// frame that can't be read, and the next call goes on after it.
+func (f *frameReader) next() ([]byte, error) {
+	for {
+		if !f.started {
+			b, err := f.r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if b != models.BeastStartByte {
+				continue // Outside a frame
+			}
+		}
+		typeByte, err := f.r.ReadByte()
+		if err != nil {
+			f.started = true // Read on from the type byte once the stream continues
+			return nil, unexpectedEOF(err)
+		}
+		f.started = false
+
+		// The type shouldn't be escaped, but a doubled 1a may be one; a single one means the first was noise
+		if typeByte == models.BeastStartByte {
+			next, err := f.r.Peek(1)
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			if next[0] != models.BeastStartByte {
+				f.started = true
+				continue
+			}
+			f.r.ReadByte()
+		}
+
+		totalLen, err := models.GetBeastTotalLen(typeByte)
+		if err != nil {
+			return nil, fmt.Errorf("%w %02x", errUnknownType, typeByte)
+		}
+		frame := make([]byte, 0, totalLen)
+		frame = append(frame, models.BeastStartByte, typeByte)
+		for len(frame) < totalLen {
+			b, err := f.r.ReadByte()
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			if b == models.BeastStartByte {
+				next, err := f.r.Peek(1)
+				if err != nil {
+					return nil, unexpectedEOF(err)
+				}
+				if next[0] != models.BeastStartByte {
+					// The 1a starts the next frame, which is kept
+					f.started = true
+					return nil, fmt.Errorf("%w after %d of %d bytes", errSyncLost, len(frame), totalLen)
+				}
+				f.r.ReadByte()
+			}
+			frame = append(frame, b)
+		}
+		return frame, nil
+	}
+}
+
+// unexpectedEOF turns the end of the stream inside a frame into io.ErrUnexpectedEOF
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// framingError reports whether err is a framing problem rather than a read error
+func framingError(err error) bool {
+	return errors.Is(err, errSyncLost) || errors.Is(err, errUnknownType) || errors.Is(err, errInvalidFrame)
+}
@@ -0,0 +1,69 @@
+package dump1090
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameReader(t *testing.T) {
+	// The capture TestAnnotateBeast walks, read the way the client and the UDP listener read it
+	var data []byte
+	data = append(data, 0x00, 0xff)
+	data = append(data, 0x1a, '1', 0, 0, 0, 0, 0, 1, 0x40, 0x12, 0x34)
+	data = append(data, 0x1a, '2', 0, 0, 0, 0, 0x1a, 0x1a, 2, 0x50, 0x5d, 0x4c, 0xa2, 0xd3, 0x1e, 0x2b, 0x00)
+	data = append(data, 0x1a, '7', 0x00)
+	data = append(data, 0x1a, '2', 0, 0, 0, 0x1a, '1', 0, 0, 0, 0, 0, 1, 0x40, 0x12, 0x34)
+	data = append(data, 0x1a, '3', 0, 0, 0)
+
+	frames := newFrameReader(bufio.NewReader(bytes.NewReader(data)))
+	next := frames.next
+
+	frame, err := next()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x1a, '1', 0, 0, 0, 0, 0, 1, 0x40, 0x12, 0x34}, frame)
+
+	frame, err = next()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x1a, '2', 0, 0, 0, 0, 0x1a, 2, 0x50, 0x5d, 0x4c, 0xa2, 0xd3, 0x1e, 0x2b, 0x00}, frame, "escape removed")
+
+	_, err = next()
+	assert.ErrorIs(t, err, errUnknownType)
+	assert.True(t, framingError(err))
+
+	_, err = next()
+	assert.ErrorIs(t, err, errSyncLost)
+	assert.EqualError(t, err, "unescaped 1a inside a frame (sync lost) after 5 of 16 bytes")
+
+	frame, err = next()
+	require.NoError(t, err, "the frame that cut the last one short is kept")
+	assert.Equal(t, []byte{0x1a, '1', 0, 0, 0, 0, 0, 1, 0x40, 0x12, 0x34}, frame)
+
+	_, err = next()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF, "ended inside a frame")
+	assert.False(t, framingError(err))
+	_, err = next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestFrameReader_StartByteAsType(t *testing.T) {
+	modeAC := []byte{0x1a, '1', 0, 0, 0, 0, 0, 1, 0x40, 0x12, 0x34}
+
+	// A lone 1a before a frame's start byte is noise
+	frames := newFrameReader(bufio.NewReader(bytes.NewReader(append([]byte{0x1a}, modeAC...))))
+	frame, err := frames.next()
+	require.NoError(t, err)
+	assert.Equal(t, modeAC, frame)
+
+	// A doubled one after it is an escaped type, which no frame has
+	frames = newFrameReader(bufio.NewReader(bytes.NewReader(append([]byte{0x1a, 0x1a, 0x1a}, modeAC...))))
+	_, err = frames.next()
+	assert.ErrorIs(t, err, errUnknownType)
+	frame, err = frames.next()
+	require.NoError(t, err)
+	assert.Equal(t, modeAC, frame)
+}
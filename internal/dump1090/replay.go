@@ -0,0 +1,347 @@
+package dump1090
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"flight_trmnl/internal/models"
+)
+
+const (
+	// maxCatchUpHeld bounds the live messages kept while the backlog replays, a few minutes of a busy receiver;
+	// past it the oldest are dropped
+	maxCatchUpHeld = 100_000
+
+	replayProgressInterval = 10 * time.Second
+)
+
+// ReplayedSuffix is added to a backlog file once it has been replayed to the end, so a restart doesn't store it twice
+const ReplayedSuffix = ".done"
+
+// Replay reads a backlog of receiver output from a file, e.g. a capture or the output of nc saved while the daemon
+// was down, as fast as the pipeline takes it, or paced by its timestamps. A file holds no arrival times, so its last
+// message is taken to have arrived when the file was last written and the ones before are timed back from there by
+// their timestamps.
+type Replay struct {
+	path       string
+	format     string  // FormatBeast or FormatAVR
+	timestamps string  // models.ClockFreeRunning or models.ClockGPS
+	speed      float64 // Times the pace the messages were received at, 0 as fast as the pipeline takes them
+	keep       bool    // Leave the file in place once replayed, instead of marking it replayed
+
+	mu       sync.Mutex
+	progress ReplayProgress
+}
+
+// ReplayProgress is how far a catch-up has got
+type ReplayProgress struct {
+	File        string     `json:"file"`
+	Size        int64      `json:"size"`
+	Bytes       int64      `json:"bytes"` // Read so far
+	Percent     float64    `json:"percent"`
+	Messages    int64      `json:"messages"`
+	ParseErrors int64      `json:"parse_errors"`
+	Started     time.Time  `json:"started"`
+	Finished    *time.Time `json:"finished,omitempty"` // When the backlog was done and live messages took over
+	Held        int        `json:"held"`               // Live messages waiting for the backlog
+	Dropped     int64      `json:"dropped"`            // Live messages dropped while waiting
+}
+
+// NewReplay creates a replay of the file, holding frames in the format, FormatBeast or FormatAVR, with timestamps
+// in the format models.NewBeastClock takes
+func NewReplay(path, format, timestamps string) (*Replay, error) {
+	if format != FormatBeast && format != FormatAVR {
+		return nil, fmt.Errorf("unknown input format %q (must be %s or %s)", format, FormatBeast, FormatAVR)
+	}
+	if _, err := models.NewBeastClock(timestamps); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backlog: %w", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("backlog %s is a directory", path)
+	}
+	return &Replay{path: path, format: format, timestamps: timestamps,
+		progress: ReplayProgress{File: path, Size: info.Size()}}, nil
+}
+
+// NewPacedReplay creates a replay of the file as NewReplay does, sending its messages at speed times the pace they
+// were received at, or as fast as the pipeline takes them at 0. The file is left in place to be replayed again, e.g.
+// a recording to test decoding against.
+func NewPacedReplay(path, format, timestamps string, speed float64) (*Replay, error) {
+	if speed < 0 {
+		return nil, fmt.Errorf("invalid replay speed %g (must be 0 or more)", speed)
+	}
+	r, err := NewReplay(path, format, timestamps)
+	if err != nil {
+		return nil, err
+	}
+	r.speed, r.keep = speed, true
+	return r, nil
+}
+
+// Progress returns how far the replay has got; it is safe to call while replaying
+func (r *Replay) Progress() ReplayProgress {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p := r.progress
+	if p.Size > 0 {
+		p.Percent = float64(p.Bytes) * 100 / float64(p.Size)
+	}
+	return p
+}
+
+// CatchUp replays the backlog, then streams from live. The live stream starts at once so nothing is missed while
+// the backlog replays; its messages wait in memory and follow the backlog's last one.
+func (r *Replay) CatchUp(ctx context.Context, live func(context.Context, chan<- *models.BeastMessage) error,
+	messageChan chan<- *models.BeastMessage) error {
+	liveChan := make(chan *models.BeastMessage, cap(messageChan))
+	liveErr := make(chan error, 1)
+	go func() {
+		liveErr <- live(ctx, liveChan)
+		close(liveChan)
+	}()
+	replayErr := make(chan error, 1)
+	go func() { replayErr <- r.Run(ctx, messageChan) }()
+
+	var held []*models.BeastMessage
+	for replaying := true; replaying; {
+		select {
+		case err := <-replayErr:
+			if ctx.Err() != nil {
+				return <-liveErr
+			}
+			if err != nil {
+				slog.Error("Failed to replay backlog, streaming live", "file", r.path, "error", err)
+			}
+			replaying = false
+		case msg, ok := <-liveChan:
+			if !ok {
+				<-replayErr // The live stream ended first, e.g. the client was closed; the backlog still goes
+				return <-liveErr
+			}
+			r.mu.Lock()
+			if len(held) >= maxCatchUpHeld {
+				held[0] = nil
+				held = held[1:]
+				r.progress.Dropped++
+			}
+			held = append(held, msg)
+			r.progress.Held = len(held)
+			r.mu.Unlock()
+		}
+	}
+
+	finished := time.Now()
+	r.mu.Lock()
+	r.progress.Finished = &finished
+	dropped := r.progress.Dropped
+	r.mu.Unlock()
+	slog.Info("Caught up, streaming live", "held", len(held), "dropped", dropped)
+	for i, msg := range held {
+		select {
+		case messageChan <- msg:
+		case <-ctx.Done():
+			return <-liveErr // Stopping it too, so nothing sends once this returns
+		}
+		held[i] = nil
+		if i%1000 == 0 {
+			r.mu.Lock()
+			r.progress.Held = len(held) - i
+			r.mu.Unlock()
+		}
+	}
+	r.mu.Lock()
+	r.progress.Held = 0
+	r.mu.Unlock()
+
+	for msg := range liveChan {
+		select {
+		case messageChan <- msg:
+		case <-ctx.Done():
+			return <-liveErr // Stopping it too, so nothing sends once this returns
+		}
+	}
+	return <-liveErr
+}
+
+// Run sends the file's messages to messageChan at the replay's speed, logging progress, and marks the file replayed
+// once it reaches the end, unless it is kept
+func (r *Replay) Run(ctx context.Context, messageChan chan<- *models.BeastMessage) error {
+	file, err := os.Open(r.path)
+	if err != nil {
+		return fmt.Errorf("failed to open backlog: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read backlog: %w", err)
+	}
+
+	// A first pass finds how long the file spans, so its messages can be timed forward from its start
+	var span time.Duration
+	if r.timestamps != models.ClockGPS {
+		var clock replayClock
+		err := r.read(file, func(msg *models.BeastMessage) error {
+			clock.advance(msg.Ticks)
+			return nil
+		}, nil)
+		if err != nil {
+			return err
+		}
+		span = clock.elapsed
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to read backlog: %w", err)
+		}
+	}
+	start := info.ModTime().Add(-span)
+
+	started := time.Now()
+	r.mu.Lock()
+	r.progress.Size, r.progress.Started = info.Size(), started
+	r.mu.Unlock()
+	slog.Info("Replaying backlog", "file", r.path, "bytes", info.Size(), "format", r.format, "speed", r.speed)
+
+	connID := fmt.Sprintf("replay-%d", connIDs.Add(1))
+	gps, _ := models.NewBeastClock(models.ClockGPS)
+	var clock replayClock
+	var seq uint64
+	var first time.Time
+	pace := time.NewTimer(time.Hour)
+	pace.Stop()
+	ticker := time.NewTicker(replayProgressInterval)
+	defer ticker.Stop()
+	err = r.read(file, func(msg *models.BeastMessage) error {
+		if r.timestamps == models.ClockGPS {
+			msg.Timestamp = gps.Time(msg.Ticks, info.ModTime())
+		} else {
+			msg.Timestamp = start.Add(clock.advance(msg.Ticks))
+		}
+		seq++
+		msg.ConnID, msg.Seq = connID, seq
+
+		// Paced, each message waits until as long after the start as it came after the first, divided by the speed
+		if r.speed > 0 {
+			if first.IsZero() {
+				first = msg.Timestamp
+			}
+			due := started.Add(time.Duration(float64(msg.Timestamp.Sub(first)) / r.speed))
+			if wait := time.Until(due); wait > 0 {
+				pace.Reset(wait)
+				select {
+				case <-pace.C:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+		select {
+		case messageChan <- msg:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		r.mu.Lock()
+		r.progress.Messages++
+		r.mu.Unlock()
+
+		select {
+		case <-ticker.C:
+			p := r.Progress()
+			rate := float64(p.Messages) / time.Since(started).Seconds()
+			slog.Info("Replaying backlog", "percent", fmt.Sprintf("%.1f", p.Percent), "messages", p.Messages,
+				"per_second", int(rate), "held", p.Held)
+		default:
+		}
+		return nil
+	}, func(n int64, parseErrors int64) {
+		r.mu.Lock()
+		r.progress.Bytes, r.progress.ParseErrors = n, parseErrors
+		r.mu.Unlock()
+	})
+	if err != nil {
+		return err
+	}
+
+	p := r.Progress()
+	slog.Info("Backlog replayed", "file", r.path, "messages", p.Messages, "parse_errors", p.ParseErrors,
+		"duration", time.Since(started).Round(time.Second))
+	if r.keep {
+		return nil
+	}
+	if err := os.Rename(r.path, r.path+ReplayedSuffix); err != nil {
+		slog.Warn("Failed to mark backlog replayed, it is replayed again on the next start", "file", r.path, "error", err)
+	}
+	return nil
+}
+
+// read calls each for the messages of f until its end, and counted, when set, with the bytes read and the frames
+// that couldn't be
+func (r *Replay) read(f io.Reader, each func(*models.BeastMessage) error, counted func(n, parseErrors int64)) error {
+	counter := &countingReader{r: f}
+	reader := newMessageReader(bufio.NewReader(counter), r.format)
+	var parseErrors int64
+	for {
+		msg, err := reader.next()
+		if counted != nil {
+			counted(counter.n.Load(), parseErrors)
+		}
+		if framingError(err) {
+			parseErrors++
+			continue
+		}
+		if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil // A file cut off inside a frame, e.g. by a crash, is otherwise fine
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read backlog: %w", err)
+		}
+		if err := each(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// replayClock counts the time a file's free-running timestamps span. Without arrival times a receiver restart,
+// a step back, can't be placed, so the messages after it follow on from the ones before.
+type replayClock struct {
+	started bool
+	ticks   uint64
+	elapsed time.Duration
+}
+
+// advance returns the time from the file's first message to one with the timestamp
+func (c *replayClock) advance(ticks uint64) time.Duration {
+	if ticks == 0 {
+		return c.elapsed // No timestamp, taken to be at the message before
+	}
+	if c.started {
+		diff := (ticks - c.ticks) % (1 << 48)
+		if diff < tickHalfCounter {
+			c.elapsed += time.Duration(diff * 250 / 3) // 1e9 / 12e6 ns per tick
+		}
+	}
+	c.started, c.ticks = true, ticks
+	return c.elapsed
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,173 @@
+package dump1090
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// timedFrame is shortFrame with a timestamp of ms milliseconds, escaped
+func timedFrame(ms uint64) []byte {
+	frame := shortFrame(0)
+	ticks := ms * 12_000
+	for i := 0; i < 6; i++ {
+		frame[2+i] = byte(ticks >> (8 * (5 - i)))
+	}
+	escaped := frame[:2:2]
+	for _, b := range frame[2:] {
+		if b == models.BeastStartByte {
+			escaped = append(escaped, b)
+		}
+		escaped = append(escaped, b)
+	}
+	return escaped
+}
+
+// writeBacklog writes frames to a file last written at modTime
+func writeBacklog(t *testing.T, modTime time.Time, frames ...[]byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "backlog.bin")
+	require.NoError(t, os.WriteFile(path, bytes.Join(frames, nil), 0o644))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+	return path
+}
+
+func TestReplay(t *testing.T) {
+	written := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	// A restart after 1.5s, then half a second more
+	path := writeBacklog(t, written, timedFrame(1000), timedFrame(2500), []byte("noise"), timedFrame(100),
+		timedFrame(600))
+	replay, err := NewReplay(path, FormatBeast, models.ClockFreeRunning)
+	require.NoError(t, err)
+
+	messages := make(chan *models.BeastMessage, 10)
+	require.NoError(t, replay.Run(context.Background(), messages))
+	close(messages)
+
+	var times []time.Duration // Before the file was written
+	var connID string
+	for msg := range messages {
+		times = append(times, written.Sub(msg.Timestamp))
+		connID = msg.ConnID
+		assert.Equal(t, uint64(len(times)), msg.Seq)
+	}
+	assert.Equal(t, []time.Duration{2 * time.Second, 500 * time.Millisecond, 500 * time.Millisecond, 0}, times)
+	assert.Regexp(t, `^replay-\d+$`, connID)
+
+	p := replay.Progress()
+	assert.Equal(t, int64(4), p.Messages)
+	assert.Equal(t, p.Size, p.Bytes)
+	assert.Equal(t, float64(100), p.Percent)
+
+	// Replayed to the end, so a restart doesn't replay it again
+	assert.NoFileExists(t, path)
+	assert.FileExists(t, path+ReplayedSuffix)
+}
+
+func TestReplay_AVR(t *testing.T) {
+	written := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	path := writeBacklog(t, written, []byte("*5D4CA2D3A0B1C2;\n"), []byte("@00000BEBC2005D4CA2D3A0B1C2;\n"))
+	replay, err := NewReplay(path, FormatAVR, models.ClockFreeRunning)
+	require.NoError(t, err)
+
+	messages := make(chan *models.BeastMessage, 10)
+	require.NoError(t, replay.Run(context.Background(), messages))
+	require.Len(t, messages, 2)
+	// The untimed one is taken to be at the file's start, which its one timed message spans no time from
+	assert.True(t, written.Equal((<-messages).Timestamp))
+	assert.True(t, written.Equal((<-messages).Timestamp))
+}
+
+func TestReplay_Paced(t *testing.T) {
+	// 300ms of messages, including a restart, played back at 3x
+	path := writeBacklog(t, time.Now(), timedFrame(1000), timedFrame(1150), timedFrame(100), timedFrame(250))
+	replay, err := NewPacedReplay(path, FormatBeast, models.ClockFreeRunning, 3)
+	require.NoError(t, err)
+
+	messages := make(chan *models.BeastMessage, 10)
+	started := time.Now()
+	require.NoError(t, replay.Run(context.Background(), messages))
+	elapsed := time.Since(started)
+	assert.GreaterOrEqual(t, elapsed, 100*time.Millisecond)
+	assert.Less(t, elapsed, time.Second)
+	close(messages)
+	var times []time.Time
+	for msg := range messages {
+		times = append(times, msg.Timestamp)
+	}
+	require.Len(t, times, 4)
+	assert.Equal(t, 300*time.Millisecond, times[3].Sub(times[0]), "timed as recorded, not as sent")
+
+	// Kept to be replayed again
+	assert.FileExists(t, path)
+	assert.NoFileExists(t, path+ReplayedSuffix)
+
+	// As fast as the pipeline takes them, and stopped with the context
+	replay, err = NewPacedReplay(path, FormatBeast, models.ClockFreeRunning, 0)
+	require.NoError(t, err)
+	started = time.Now()
+	require.NoError(t, replay.Run(context.Background(), make(chan *models.BeastMessage, 10)))
+	assert.Less(t, time.Since(started), 100*time.Millisecond)
+
+	slow, err := NewPacedReplay(path, FormatBeast, models.ClockFreeRunning, 0.001)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, slow.Run(ctx, make(chan *models.BeastMessage, 10)), context.DeadlineExceeded)
+
+	_, err = NewPacedReplay(path, FormatBeast, models.ClockFreeRunning, -1)
+	assert.Error(t, err)
+}
+
+func TestReplay_CatchUp(t *testing.T) {
+	path := writeBacklog(t, time.Now(), timedFrame(1000), timedFrame(2000))
+	replay, err := NewReplay(path, FormatBeast, models.ClockFreeRunning)
+	require.NoError(t, err)
+
+	// The live stream delivers at once, before the backlog is read
+	live := func(ctx context.Context, ch chan<- *models.BeastMessage) error {
+		for i := 1; i <= 3; i++ {
+			ch <- &models.BeastMessage{ConnID: "live", Seq: uint64(i)}
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	messages := make(chan *models.BeastMessage)
+	done := make(chan error, 1)
+	go func() { done <- replay.CatchUp(ctx, live, messages) }()
+
+	var order []string
+	for len(order) < 5 {
+		select {
+		case msg := <-messages:
+			if msg.ConnID == "live" {
+				order = append(order, "live")
+			} else {
+				order = append(order, "backlog")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out after %v", order)
+		}
+	}
+	assert.Equal(t, []string{"backlog", "backlog", "live", "live", "live"}, order)
+	assert.NotNil(t, replay.Progress().Finished)
+	assert.Zero(t, replay.Progress().Held)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestNewReplay_Missing(t *testing.T) {
+	_, err := NewReplay(filepath.Join(t.TempDir(), "missing.bin"), FormatBeast, models.ClockFreeRunning)
+	assert.Error(t, err)
+}
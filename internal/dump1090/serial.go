@@ -0,0 +1,133 @@
+package dump1090
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"flight_trmnl/internal/models"
+)
+
+// DefaultSerialBaud is the Mode-S Beast's own rate
+const DefaultSerialBaud = 3000000
+
+// SerialReader reads Beast or AVR frames straight from a receiver on a serial port, e.g. a Mode-S Beast or a
+// GNS5894 on USB, without dump1090 in between. The port is opened raw at the baud rate and reopened with backoff
+// when it goes away, e.g. when the receiver is unplugged; each opening gets a conn ID and clock of its own, as a TCP
+// connection does.
+type SerialReader struct {
+	device     string
+	baud       int
+	format     string // FormatBeast or FormatAVR
+	timestamps string // The receiver's timestamp format, models.ClockFreeRunning or models.ClockGPS
+	receiver   string // Tags every message read, empty for none
+
+	mu      sync.Mutex
+	lastErr error // Why the port isn't open, nil while it is
+}
+
+// NewSerialReader creates a reader of the device, e.g. /dev/ttyUSB0, at the baud rate, for frames in the format,
+// FormatBeast or FormatAVR, whose timestamps are in the format models.NewBeastClock takes, tagging their messages
+// with the receiver unless it is empty
+func NewSerialReader(device string, baud int, format, timestamps, receiver string) (*SerialReader, error) {
+	if format != FormatBeast && format != FormatAVR {
+		return nil, fmt.Errorf("unknown input format %q (must be %s or %s)", format, FormatBeast, FormatAVR)
+	}
+	if _, err := models.NewBeastClock(timestamps); err != nil {
+		return nil, err
+	}
+	if err := CheckBaud(baud); err != nil {
+		return nil, err
+	}
+	return &SerialReader{device: device, baud: baud, format: format, timestamps: timestamps, receiver: receiver,
+		lastErr: errors.New("not opened yet")}, nil
+}
+
+// Device returns the serial device read
+func (s *SerialReader) Device() string {
+	return s.device
+}
+
+// Healthy reports why the port isn't open, nil while it is
+func (s *SerialReader) Healthy() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+func (s *SerialReader) setErr(err error) {
+	s.mu.Lock()
+	s.lastErr = err
+	s.mu.Unlock()
+}
+
+// StreamMessages opens the port and sends the messages read to messageChan, reopening it when it fails, until the
+// context is cancelled
+func (s *SerialReader) StreamMessages(ctx context.Context, messageChan chan<- *models.BeastMessage) error {
+	backoff := time.Second
+	for {
+		port, err := openSerial(s.device, s.baud)
+		if err == nil {
+			backoff = time.Second
+			s.setErr(nil)
+			err = s.read(ctx, port, messageChan)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		s.setErr(err)
+		slog.Warn("Serial input failed, reopening", "device", s.device, "retry_in", backoff, "error", err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		// Exponential backoff: 1s, 2s, 4s, 8s, max 30s
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+// read sends the messages of an open port to messageChan until it fails or the context is cancelled, closing it
+// then
+func (s *SerialReader) read(ctx context.Context, port io.ReadCloser, messageChan chan<- *models.BeastMessage) error {
+	stop := context.AfterFunc(ctx, func() { port.Close() })
+	defer stop()
+	defer port.Close()
+
+	connID := fmt.Sprintf("serial-%d", connIDs.Add(1))
+	clock, _ := models.NewBeastClock(s.timestamps) // Checked by NewSerialReader
+	log := slog.With("conn", connID)
+	log.Info("Reading serial input", "device", s.device, "baud", s.baud, "format", s.format, "receiver", s.receiver)
+
+	reader := newMessageReader(bufio.NewReader(port), s.format)
+	var seq uint64
+	for {
+		msg, err := reader.next()
+		if framingError(err) {
+			log.Debug("Skipping frame", "after_seq", seq, "error", err)
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err == io.EOF {
+				return fmt.Errorf("%s closed", s.device)
+			}
+			return fmt.Errorf("failed to read %s: %w", s.device, err)
+		}
+		seq++
+		msg.ConnID, msg.Seq, msg.Receiver = connID, seq, s.receiver
+		msg.Timestamp = clock.Time(msg.Ticks, msg.Timestamp)
+		select {
+		case messageChan <- msg:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package dump1090
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// serialBauds are the rates a port can be set to, by their termios speed
+var serialBauds = map[int]uint32{
+	9600:    unix.B9600,
+	19200:   unix.B19200,
+	38400:   unix.B38400,
+	57600:   unix.B57600,
+	115200:  unix.B115200,
+	230400:  unix.B230400,
+	460800:  unix.B460800,
+	500000:  unix.B500000,
+	921600:  unix.B921600,
+	1000000: unix.B1000000,
+	1500000: unix.B1500000,
+	2000000: unix.B2000000,
+	3000000: unix.B3000000,
+	4000000: unix.B4000000,
+}
+
+// CheckBaud reports an error for a rate a serial port can't be set to
+func CheckBaud(baud int) error {
+	if _, ok := serialBauds[baud]; ok {
+		return nil
+	}
+	rates := make([]int, 0, len(serialBauds))
+	for rate := range serialBauds {
+		rates = append(rates, rate)
+	}
+	sort.Ints(rates)
+	names := make([]string, len(rates))
+	for i, rate := range rates {
+		names[i] = fmt.Sprint(rate)
+	}
+	return fmt.Errorf("unsupported baud rate %d (must be one of %s)", baud, strings.Join(names, ", "))
+}
+
+// openSerial opens a serial device raw, 8N1 without flow control, at the baud rate. It is opened non-blocking so
+// closing it ends a read in progress.
+func openSerial(device string, baud int) (*os.File, error) {
+	port, err := os.OpenFile(device, os.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	fd := int(port.Fd())
+	tio, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		port.Close()
+		return nil, fmt.Errorf("%s is not a serial port: %w", device, err)
+	}
+	tio.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL |
+		unix.IXON | unix.IXOFF | unix.IXANY
+	tio.Oflag &^= unix.OPOST
+	tio.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	tio.Cflag &^= unix.CSIZE | unix.PARENB | unix.CSTOPB | unix.CRTSCTS | unix.CBAUD
+	tio.Cflag |= unix.CS8 | unix.CREAD | unix.CLOCAL | serialBauds[baud]
+	tio.Ispeed, tio.Ospeed = serialBauds[baud], serialBauds[baud]
+	tio.Cc[unix.VMIN], tio.Cc[unix.VTIME] = 1, 0
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, tio); err != nil {
+		port.Close()
+		return nil, fmt.Errorf("failed to set up %s: %w", device, err)
+	}
+	// Whatever the receiver sent before the port was set up is noise
+	unix.IoctlSetInt(fd, unix.TCFLSH, unix.TCIFLUSH)
+	return port, nil
+}
@@ -0,0 +1,75 @@
+package dump1090
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSerialReader_Read(t *testing.T) {
+	reader, err := NewSerialReader("/dev/ttyUSB0", DefaultSerialBaud, FormatBeast, models.ClockFreeRunning, "roof")
+	require.NoError(t, err)
+
+	port, device := io.Pipe()
+	messages := make(chan *models.BeastMessage, 10)
+	done := make(chan error, 1)
+	go func() { done <- reader.read(context.Background(), port, messages) }()
+
+	// Noise from before the port was set up, then two frames and one with an unknown type between them
+	_, err = device.Write(append(append([]byte{0x00, 0x42}, shortFrame(10)...), 0x1a, '9'))
+	require.NoError(t, err)
+	_, err = device.Write(shortFrame(20))
+	require.NoError(t, err)
+	first, second := <-messages, <-messages
+	assert.Equal(t, "4CA2D3", first.ICAO)
+	assert.Equal(t, uint64(1), first.Seq)
+	assert.Equal(t, uint64(2), second.Seq)
+	assert.Equal(t, first.ConnID, second.ConnID)
+	assert.Regexp(t, `^serial-\d+$`, first.ConnID)
+	assert.Equal(t, "roof", first.Receiver)
+
+	// Unplugged
+	require.NoError(t, device.Close())
+	select {
+	case err := <-done:
+		assert.ErrorContains(t, err, "/dev/ttyUSB0 closed")
+	case <-time.After(2 * time.Second):
+		t.Fatal("read didn't return")
+	}
+}
+
+func TestSerialReader_Cancel(t *testing.T) {
+	reader, err := NewSerialReader("/dev/ttyUSB0", DefaultSerialBaud, FormatBeast, models.ClockFreeRunning, "")
+	require.NoError(t, err)
+
+	port, _ := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- reader.read(ctx, port, make(chan *models.BeastMessage)) }()
+	cancel()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled, "closing the port ends the read in progress")
+	case <-time.After(2 * time.Second):
+		t.Fatal("read didn't return")
+	}
+}
+
+func TestNewSerialReader(t *testing.T) {
+	_, err := NewSerialReader("/dev/ttyUSB0", 12345, FormatBeast, models.ClockFreeRunning, "")
+	assert.ErrorContains(t, err, "unsupported baud rate 12345")
+	_, err = NewSerialReader("/dev/ttyUSB0", DefaultSerialBaud, "sbs", models.ClockFreeRunning, "")
+	assert.ErrorContains(t, err, `unknown input format "sbs"`)
+
+	reader, err := NewSerialReader("/dev/null", 115200, FormatAVR, models.ClockGPS, "")
+	require.NoError(t, err)
+	assert.Error(t, reader.Healthy(), "unhealthy until the port is open")
+	_, err = openSerial("/dev/null", 115200)
+	assert.ErrorContains(t, err, "/dev/null is not a serial port")
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package dump1090
+
+import (
+	"errors"
+	"os"
+)
+
+var errSerialUnsupported = errors.New("serial inputs are only supported on Linux")
+
+// CheckBaud reports that there are no serial ports to set a rate for
+func CheckBaud(baud int) error {
+	return errSerialUnsupported
+}
+
+// openSerial reports that there are no serial ports to open
+func openSerial(device string, baud int) (*os.File, error) {
+	return nil, errSerialUnsupported
+}
@@ -0,0 +1,239 @@
+package dump1090
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dialTimeout = 5 * time.Second
+
+	// sshStartWait is how long a new tunnel is given to fail, e.g. on a refused key, before it counts as up
+	sshStartWait = 2 * time.Second
+)
+
+// Transport is how a client reaches a receiver that isn't on the local network, instead of a tunnel service
+// maintained beside the daemon
+type Transport struct {
+	SSH        string // ssh://[user@]host[:port] to tunnel through; the address is then as that host sees it
+	TLS        bool   // The receiver's port is wrapped in TLS, e.g. by stunnel
+	CAFile     string // Extra CA certificate to trust for it, e.g. a self-signed one; empty uses the system roots
+	ServerName string // Name to verify the certificate against, when it isn't the address's host
+}
+
+// ParseSSH checks an SSH tunnel destination, ssh://[user@]host[:port]
+func ParseSSH(dest string) (*url.URL, error) {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "ssh" || u.Hostname() == "" || (u.Path != "" && u.Path != "/") {
+		return nil, fmt.Errorf("invalid ssh tunnel %q, must be ssh://[user@]host[:port]", dest)
+	}
+	// ssh would read a host or user starting with - as an option
+	if strings.HasPrefix(u.Hostname(), "-") || (u.User != nil && strings.HasPrefix(u.User.Username(), "-")) {
+		return nil, fmt.Errorf("invalid ssh tunnel %q, the host can't start with -", dest)
+	}
+	return u, nil
+}
+
+// dial connects to the input's address over its transport
+func dial(ctx context.Context, in Input) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	if in.Transport.SSH != "" {
+		conn, err = dialSSH(ctx, in.Transport.SSH, in.Addr)
+	} else {
+		dialer := net.Dialer{Timeout: dialTimeout}
+		conn, err = dialer.DialContext(ctx, "tcp", in.Addr)
+	}
+	if err != nil || !in.Transport.TLS {
+		return conn, err
+	}
+
+	config, err := tlsConfig(in.Transport, in.Addr)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConn := tls.Client(conn, config)
+	handshakeCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+	if err := tlsConn.HandshakeContext(handshakeCtx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("TLS handshake failed: %w", err)
+	}
+	return tlsConn, nil
+}
+
+// tlsConfig returns the TLS settings for a receiver at addr
+func tlsConfig(t Transport, addr string) (*tls.Config, error) {
+	config := &tls.Config{ServerName: t.ServerName, MinVersion: tls.VersionTLS12}
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		config.ServerName = host
+	}
+	if t.CAFile == "" {
+		return config, nil
+	}
+	pem, err := os.ReadFile(t.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", t.CAFile)
+	}
+	config.RootCAs = roots
+	return config, nil
+}
+
+// sshConn is a connection forwarded by the ssh command's -W, which relays stdin and stdout to the address
+type sshConn struct {
+	cmd    *exec.Cmd
+	stdin  *os.File
+	stdout *os.File
+	r      *bufio.Reader
+	stderr *lockedBuffer
+	addr   sshAddr
+	closed sync.Once
+}
+
+// dialSSH runs ssh to forward a connection to addr from the host at dest. Like sync, it uses the system's ssh, so
+// keys, known hosts and ~/.ssh/config apply as they do in a shell.
+func dialSSH(ctx context.Context, dest, addr string) (net.Conn, error) {
+	u, err := ParseSSH(dest)
+	if err != nil {
+		return nil, err
+	}
+	// Fail instead of prompting for a password, and notice a dead link within a minute
+	args := []string{"-o", "BatchMode=yes", "-o", "ServerAliveInterval=15", "-o", "ServerAliveCountMax=4",
+		"-o", "ConnectTimeout=" + strconv.Itoa(int(dialTimeout.Seconds())), "-W", addr}
+	if port := u.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+	host := u.Hostname()
+	if u.User != nil {
+		host = u.User.Username() + "@" + host
+	}
+	args = append(args, "--", host) // -- ends the options
+
+	// Pipes of its own rather than exec's, since only files take read deadlines
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start ssh: %w", err)
+	}
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		stdinR.Close()
+		stdinW.Close()
+		return nil, fmt.Errorf("failed to start ssh: %w", err)
+	}
+	c := &sshConn{stdin: stdinW, stdout: stdoutR, r: bufio.NewReader(stdoutR), stderr: &lockedBuffer{},
+		addr: sshAddr(host + " -W " + addr)}
+	c.cmd = exec.Command("ssh", args...)
+	c.cmd.Stdin, c.cmd.Stdout, c.cmd.Stderr = stdinR, stdoutW, c.stderr
+	c.cmd.WaitDelay = time.Second // Don't wait long on anything it started that keeps its error output open
+	err = c.cmd.Start()
+	stdinR.Close()
+	stdoutW.Close()
+	if err != nil {
+		stdinW.Close()
+		stdoutR.Close()
+		return nil, fmt.Errorf("failed to start ssh: %w", err)
+	}
+
+	// ssh reports a refused login or forward by exiting, which a quiet receiver would otherwise hide
+	stop := context.AfterFunc(ctx, func() { c.Close() })
+	defer stop()
+	stdoutR.SetReadDeadline(time.Now().Add(sshStartWait))
+	if _, err := c.r.Peek(1); err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+		c.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("failed to connect to %s through %s: %w", addr, u.Host, c.failure(err))
+	}
+	stdoutR.SetReadDeadline(time.Time{})
+	return c, nil
+}
+
+func (c *sshConn) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	switch {
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		err = os.ErrDeadlineExceeded // A net.Error, so it is taken for a timeout, also by TLS
+	case err == io.EOF:
+		c.Close() // ssh has exited, or is about to; waiting for it collects all it said
+		err = c.failure(err)
+	}
+	return n, err
+}
+
+// failure adds what ssh said to the end of the tunnel, io.EOF when it said nothing
+func (c *sshConn) failure(err error) error {
+	if msg := strings.TrimSpace(c.stderr.String()); msg != "" {
+		return fmt.Errorf("ssh: %s", msg)
+	}
+	return err
+}
+
+func (c *sshConn) Write(p []byte) (int, error) { return c.stdin.Write(p) }
+
+// Close ends the tunnel and waits for ssh to exit
+func (c *sshConn) Close() error {
+	c.closed.Do(func() {
+		c.stdin.Close()
+		c.cmd.Process.Kill()
+		c.cmd.Wait()
+		c.stdout.Close()
+	})
+	return nil
+}
+
+func (c *sshConn) LocalAddr() net.Addr                { return c.addr }
+func (c *sshConn) RemoteAddr() net.Addr               { return c.addr }
+func (c *sshConn) SetDeadline(t time.Time) error      { return c.stdout.SetReadDeadline(t) }
+func (c *sshConn) SetReadDeadline(t time.Time) error  { return c.stdout.SetReadDeadline(t) }
+func (c *sshConn) SetWriteDeadline(t time.Time) error { return c.stdin.SetWriteDeadline(t) }
+
+// sshAddr names both ends of a tunnel, which has no addresses of its own
+type sshAddr string
+
+func (a sshAddr) Network() string { return "ssh" }
+func (a sshAddr) String() string  { return string(a) }
+
+// lockedBuffer collects ssh's error output while it runs
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
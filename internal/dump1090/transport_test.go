@@ -0,0 +1,117 @@
+package dump1090
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSSH puts an ssh on PATH that runs script with the arguments it was given saved to the returned file
+func fakeSSH(t *testing.T, script string) string {
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	content := "#!/bin/sh\necho \"$@\" > " + argsFile + "\n" + script + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ssh"), []byte(content), 0o755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return argsFile
+}
+
+func TestBeastClient_SSHTunnel(t *testing.T) {
+	argsFile := fakeSSH(t, "printf '*8DA05629EA21485CBF3F8CADAEEB;\\n'\nexec sleep 5")
+
+	client := NewBeastClient("")
+	require.NoError(t, client.Reconfigure(Input{
+		Addr:      "localhost:30002",
+		Format:    FormatAVR,
+		Transport: Transport{SSH: "ssh://pi@receiver.local:2222"},
+	}))
+	messages := make(chan *models.BeastMessage, 10)
+	done := make(chan error)
+	go func() { done <- client.StreamMessages(context.Background(), messages) }()
+
+	select {
+	case msg := <-messages:
+		assert.Equal(t, "A05629", msg.ICAO)
+	case <-time.After(3 * time.Second):
+		t.Fatal("no message through the tunnel")
+	}
+	client.Close()
+	<-done
+
+	args, err := os.ReadFile(argsFile)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(strings.TrimSpace(string(args)), "-W localhost:30002 -p 2222 -- pi@receiver.local"), string(args))
+	assert.Contains(t, string(args), "BatchMode=yes")
+}
+
+func TestDialSSH_Refused(t *testing.T) {
+	fakeSSH(t, "echo 'pi@receiver.local: Permission denied (publickey).' >&2\nexit 255")
+	_, err := dial(context.Background(), Input{Addr: "localhost:30005", Transport: Transport{SSH: "ssh://pi@receiver.local"}})
+	assert.EqualError(t, err, "failed to connect to localhost:30005 through receiver.local: ssh: pi@receiver.local: Permission denied (publickey).")
+
+	for _, dest := range []string{"pi@receiver.local", "ssh://", "ssh://host/path", "https://host", "ssh://-oProxyCommand=x", "ssh://-F@host"} {
+		_, err := ParseSSH(dest)
+		assert.Error(t, err, dest)
+	}
+}
+
+func TestDial_TLS(t *testing.T) {
+	// The test server's certificate, for 127.0.0.1 and example.com, served on a plain listener
+	srv := httptest.NewTLSServer(nil)
+	defer srv.Close()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", srv.TLS)
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write([]byte{0x1a, '2', 0, 0, 0, 0, 0, 0, 0x80, 0x5d, 0x4c, 0xa2, 0xd3, 0x1e, 0x2b, 0x00})
+				time.Sleep(5 * time.Second)
+			}()
+		}
+	}()
+
+	_, err = dial(context.Background(), Input{Addr: ln.Addr().String(), Transport: Transport{TLS: true}})
+	assert.ErrorContains(t, err, "TLS handshake failed", "the certificate isn't trusted")
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o644))
+	_, err = dial(context.Background(), Input{Addr: ln.Addr().String(), Transport: Transport{TLS: true, CAFile: caFile, ServerName: "flights.example.org"}})
+	assert.ErrorContains(t, err, "flights.example.org", "the name isn't the certificate's")
+
+	client := NewBeastClient("")
+	require.NoError(t, client.Reconfigure(Input{Addr: ln.Addr().String(), Transport: Transport{TLS: true, CAFile: caFile}}))
+	messages := make(chan *models.BeastMessage, 10)
+	done := make(chan error)
+	go func() { done <- client.StreamMessages(context.Background(), messages) }()
+	defer func() {
+		client.Close()
+		<-done
+	}()
+	select {
+	case msg := <-messages:
+		assert.Equal(t, "4CA2D3", msg.ICAO)
+	case <-time.After(3 * time.Second):
+		t.Fatal("no message over TLS")
+	}
+	// Read timeouts on a quiet receiver don't break the TLS connection
+	time.Sleep(1500 * time.Millisecond)
+	assert.True(t, client.Stats().Connected)
+	assert.Zero(t, client.Stats().Reconnects)
+}
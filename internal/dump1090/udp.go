@@ -0,0 +1,209 @@
+package dump1090
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"flight_trmnl/internal/models"
+)
+
+const (
+	maxDatagram     = 64 * 1024
+	maxUDPSources   = 256             // Senders tracked per listener; past it the idlest is forgotten
+	udpSourceIdle   = 5 * time.Minute // A sender quiet this long is forgotten, and gets a new conn ID if it returns
+	tickHalfCounter = 1 << 47         // Half the 48-bit counter: a timestamp less than this behind the latest is earlier
+)
+
+// UDPListener receives Beast or AVR frames forwarded over UDP, e.g. by a feeder's UDP output or socat. Each sender
+// is a source of its own: its messages get a conn ID, sequence numbers and a clock of their own, as over a TCP
+// connection. Datagrams may be lost or arrive out of order, so each is framed on its own, a frame split across two is
+// dropped, and messages timestamped before ones already received from the sender are counted rather than taken for a
+// receiver restart.
+type UDPListener struct {
+	addr       string
+	format     string // FormatBeast or FormatAVR
+	timestamps string // The senders' timestamp format, models.ClockFreeRunning or models.ClockGPS
+	receiver   string // Tags every message received, empty for none
+
+	mu      sync.Mutex
+	sources map[string]*udpSource // By sender address
+}
+
+// udpSource is one sender heard by a listener
+type udpSource struct {
+	UDPSourceStats
+	clock *models.BeastClock
+	seq   uint64
+	ticks uint64 // The latest timestamp received
+	log   *slog.Logger
+}
+
+// UDPSourceStats counts what a listener received from one sender
+type UDPSourceStats struct {
+	Source      string    `json:"source"`  // The sender's address
+	ConnID      string    `json:"conn_id"` // e.g. udp-4, set on each message from it
+	Messages    int64     `json:"messages"`
+	ParseErrors int64     `json:"parse_errors"`
+	Reordered   int64     `json:"reordered"` // Messages timestamped before one received earlier
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// NewUDPListener creates a listener for frames in the format, FormatBeast or FormatAVR, whose timestamps are in
+// the format models.NewBeastClock takes, tagging their messages with the receiver unless it is empty
+func NewUDPListener(addr, format, timestamps, receiver string) (*UDPListener, error) {
+	if format != FormatBeast && format != FormatAVR {
+		return nil, fmt.Errorf("unknown input format %q (must be %s or %s)", format, FormatBeast, FormatAVR)
+	}
+	if _, err := models.NewBeastClock(timestamps); err != nil {
+		return nil, err
+	}
+	return &UDPListener{addr: addr, format: format, timestamps: timestamps, receiver: receiver,
+		sources: make(map[string]*udpSource)}, nil
+}
+
+// Addr returns the address the listener binds
+func (l *UDPListener) Addr() string {
+	return l.addr
+}
+
+// Stats returns the senders heard recently, the busiest first; it is safe to call while streaming
+func (l *UDPListener) Stats() []UDPSourceStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := make([]UDPSourceStats, 0, len(l.sources))
+	for _, s := range l.sources {
+		stats = append(stats, s.UDPSourceStats)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Messages != stats[j].Messages {
+			return stats[i].Messages > stats[j].Messages
+		}
+		return stats[i].Source < stats[j].Source
+	})
+	return stats
+}
+
+// StreamMessages binds the address and sends the messages received to messageChan until the context is cancelled
+func (l *UDPListener) StreamMessages(ctx context.Context, messageChan chan<- *models.BeastMessage) error {
+	conn, err := net.ListenPacket("udp", l.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", l.addr, err)
+	}
+	slog.Info("Listening for UDP frames", "addr", conn.LocalAddr(), "format", l.format, "receiver", l.receiver)
+	return l.serve(ctx, conn, messageChan)
+}
+
+// serve reads datagrams from conn until the context is cancelled, closing it then
+func (l *UDPListener) serve(ctx context.Context, conn net.PacketConn, messageChan chan<- *models.BeastMessage) error {
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	buf := make([]byte, maxDatagram)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			slog.Warn("Failed to read UDP datagram", "addr", l.addr, "error", err)
+			continue
+		}
+		for _, msg := range l.decode(buf[:n], from.String(), time.Now()) {
+			select {
+			case messageChan <- msg:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// decode frames one datagram from a sender and tags its messages
+func (l *UDPListener) decode(datagram []byte, from string, arrived time.Time) []*models.BeastMessage {
+	var msgs []*models.BeastMessage
+	var parseErrors int64
+	reader := newMessageReader(bufio.NewReader(bytes.NewReader(datagram)), l.format)
+	for {
+		msg, err := reader.next()
+		if framingError(err) {
+			parseErrors++
+			continue
+		}
+		if err != nil {
+			if err != io.EOF {
+				parseErrors++ // Cut short at the end of the datagram
+			}
+			break
+		}
+		msg.Timestamp = arrived
+		msgs = append(msgs, msg)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.source(from, arrived)
+	s.LastSeen = arrived
+	s.ParseErrors += parseErrors
+	if parseErrors > 0 {
+		s.log.Debug("Skipped unreadable UDP frames", "frames", parseErrors, "after_seq", s.seq)
+	}
+	for _, msg := range msgs {
+		if msg.Ticks != 0 {
+			if msg.Ticks < s.ticks && s.ticks-msg.Ticks < tickHalfCounter {
+				s.Reordered++
+			} else {
+				s.ticks = msg.Ticks
+			}
+		}
+		s.seq++
+		s.Messages++
+		msg.ConnID, msg.Seq, msg.Receiver = s.ConnID, s.seq, l.receiver
+		msg.Timestamp = s.clock.Time(msg.Ticks, msg.Timestamp)
+	}
+	return msgs
+}
+
+// source returns the sender's source, starting one for a new sender; the caller holds the lock
+func (l *UDPListener) source(from string, now time.Time) *udpSource {
+	if s, ok := l.sources[from]; ok && now.Sub(s.LastSeen) < udpSourceIdle {
+		return s
+	}
+	for addr, s := range l.sources {
+		if now.Sub(s.LastSeen) >= udpSourceIdle {
+			delete(l.sources, addr)
+		}
+	}
+	if len(l.sources) >= maxUDPSources {
+		var idlest *udpSource
+		for _, s := range l.sources {
+			if idlest == nil || s.LastSeen.Before(idlest.LastSeen) {
+				idlest = s
+			}
+		}
+		delete(l.sources, idlest.Source)
+	}
+
+	connID := fmt.Sprintf("udp-%d", connIDs.Add(1))
+	clock, _ := models.NewBeastClock(l.timestamps) // Checked by NewUDPListener
+	s := &udpSource{
+		UDPSourceStats: UDPSourceStats{Source: from, ConnID: connID},
+		clock:          clock,
+		log:            slog.With("conn", connID),
+	}
+	l.sources[from] = s
+	s.log.Info("Receiving UDP frames", "source", from, "addr", l.addr)
+	return s
+}
@@ -0,0 +1,109 @@
+package dump1090
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// shortFrame is a Beast DF11 frame from 4CA2D3 with the timestamp
+func shortFrame(ticks byte) []byte {
+	return []byte{0x1a, '2', 0, 0, 0, 0, 0, ticks, 0x80, 0x5d, 0x4c, 0xa2, 0xd3, 0x1e, 0x2b, 0x00}
+}
+
+func TestUDPListener(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	listener, err := NewUDPListener(conn.LocalAddr().String(), FormatBeast, models.ClockFreeRunning, "")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	messages := make(chan *models.BeastMessage, 10)
+	done := make(chan error, 1)
+	go func() { done <- listener.serve(ctx, conn, messages) }()
+
+	send := func(from net.Conn, datagram []byte) {
+		t.Helper()
+		_, err := from.Write(datagram)
+		require.NoError(t, err)
+	}
+	receive := func() *models.BeastMessage {
+		t.Helper()
+		select {
+		case msg := <-messages:
+			return msg
+		case <-time.After(2 * time.Second):
+			t.Fatal("no message received")
+			return nil
+		}
+	}
+	a, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer a.Close()
+	b, err := net.Dial("udp", conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer b.Close()
+
+	// Two frames in one datagram, then a frame split across two, which is dropped
+	send(a, append(shortFrame(10), shortFrame(20)...))
+	first, second := receive(), receive()
+	send(a, shortFrame(30)[:8])
+	send(a, shortFrame(30)[8:])
+	// Another sender's frames are kept apart, and one arriving late is counted
+	send(b, shortFrame(50))
+	third := receive()
+	send(a, shortFrame(15))
+	late := receive()
+
+	assert.Equal(t, first.ConnID, second.ConnID)
+	assert.Equal(t, []uint64{1, 2}, []uint64{first.Seq, second.Seq})
+	assert.NotEqual(t, first.ConnID, third.ConnID)
+	assert.Equal(t, uint64(1), third.Seq)
+	assert.Equal(t, first.ConnID, late.ConnID)
+	assert.Equal(t, uint64(3), late.Seq)
+	assert.False(t, late.Timestamp.Before(second.Timestamp), "times don't go backwards")
+
+	stats := listener.Stats()
+	require.Len(t, stats, 2)
+	assert.Equal(t, a.LocalAddr().String(), stats[0].Source)
+	assert.Equal(t, first.ConnID, stats[0].ConnID)
+	assert.Equal(t, int64(3), stats[0].Messages)
+	assert.Equal(t, int64(1), stats[0].ParseErrors, "the split frame's first half, the second is noise")
+	assert.Equal(t, int64(1), stats[0].Reordered)
+	assert.Equal(t, int64(1), stats[1].Messages)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestUDPListener_AVR(t *testing.T) {
+	listener, err := NewUDPListener(":0", FormatAVR, models.ClockFreeRunning, "")
+	require.NoError(t, err)
+	now := time.Now()
+	msgs := listener.decode([]byte("*8DA05629EA21485CBF3F8CADAEEB;\n*8DA05;\n@0000001234565d4ca2d31e2b00;\n"), "10.0.0.2:5000", now)
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "A05629", msgs[0].ICAO)
+	assert.Equal(t, uint64(2), msgs[1].Seq)
+	assert.Equal(t, int64(1), listener.Stats()[0].ParseErrors)
+
+	_, err = NewUDPListener(":0", "sbs", models.ClockFreeRunning, "")
+	assert.ErrorContains(t, err, `unknown input format "sbs"`)
+}
+
+func TestUDPListener_ForgetsIdleSources(t *testing.T) {
+	listener, err := NewUDPListener(":0", FormatBeast, models.ClockFreeRunning, "shed")
+	require.NoError(t, err)
+	now := time.Now()
+	first := listener.decode(shortFrame(1), "10.0.0.2:5000", now)[0]
+	again := listener.decode(shortFrame(2), "10.0.0.2:5000", now.Add(udpSourceIdle))[0]
+	assert.NotEqual(t, first.ConnID, again.ConnID, "a sender back after a while is a new source")
+	assert.Equal(t, uint64(1), again.Seq)
+	assert.Equal(t, "shed", again.Receiver)
+	assert.Len(t, listener.Stats(), 1)
+}
@@ -0,0 +1,119 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+)
+
+// Subscription receives published events until Unsubscribe is called
+type Subscription struct {
+	C   <-chan *models.Event
+	ch  chan *models.Event
+	bus *Bus
+}
+
+// Unsubscribe stops delivery and closes the subscription channel
+func (s *Subscription) Unsubscribe() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, ok := s.bus.subscribers[s]; ok {
+		delete(s.bus.subscribers, s)
+		close(s.ch)
+	}
+}
+
+// Bus fans events out from the detectors that emit them to recorders and notifiers
+type Bus struct {
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+	dropped     int64
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[*Subscription]struct{})}
+}
+
+// Publish delivers an event to every subscriber without blocking
+// Events are dropped for subscribers that fall behind rather than stalling the emitter.
+func (b *Bus) Publish(event *models.Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if event.Severity == "" {
+		event.Severity = models.SeverityInfo
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscribers {
+		e := *event // Each subscriber gets its own copy, e.g. the recorder sets the ID
+		select {
+		case sub.ch <- &e:
+		default:
+			b.dropped++
+		}
+	}
+}
+
+// Subscribe registers for events; buffer sizes the channel absorbing bursts
+func (b *Bus) Subscribe(buffer int) *Subscription {
+	ch := make(chan *models.Event, buffer)
+	sub := &Subscription{C: ch, ch: ch, bus: b}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub
+}
+
+// Dropped returns how many events were dropped because subscribers fell behind
+func (b *Bus) Dropped() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
+
+// Recorder persists every published event to the events table
+type Recorder struct {
+	repo database.EventRepository
+	sub  *Subscription
+}
+
+// NewRecorder subscribes to the bus immediately so no events are missed before Start
+func NewRecorder(bus *Bus, repo database.EventRepository) *Recorder {
+	return &Recorder{repo: repo, sub: bus.Subscribe(1000)}
+}
+
+// Start writes events until the context is cancelled
+// This method blocks; events already queued when the context is cancelled are still written.
+func (r *Recorder) Start(ctx context.Context) error {
+	defer r.sub.Unsubscribe()
+
+	for {
+		select {
+		case event := <-r.sub.C:
+			r.record(event)
+		case <-ctx.Done():
+			for {
+				select {
+				case event := <-r.sub.C:
+					r.record(event)
+				default:
+					return ctx.Err()
+				}
+			}
+		}
+	}
+}
+
+func (r *Recorder) record(event *models.Event) {
+	if err := r.repo.Insert(event); err != nil {
+		slog.Error("Failed to record event", "type", event.Type, "icao", event.ICAO, "error", err)
+	}
+}
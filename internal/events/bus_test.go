@@ -0,0 +1,188 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/tracker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockEventRepository collects inserted events
+type mockEventRepository struct {
+	mu     sync.Mutex
+	events []*models.Event
+}
+
+func (m *mockEventRepository) Insert(event *models.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	event.ID = int64(len(m.events) + 1)
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *mockEventRepository) QueryHistory(filter database.EventFilter, page database.PageRequest) ([]*models.Event, string, error) {
+	return nil, "", nil
+}
+
+func (m *mockEventRepository) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.events)
+}
+
+// mockSightings knows a fixed set of previously seen aircraft
+type mockSightings struct {
+	known map[string]bool
+}
+
+func (m *mockSightings) UpsertBatch(sightings []*models.Sighting) error { return nil }
+
+func (m *mockSightings) Get(icao string) (*models.Sighting, error) {
+	if m.known[icao] {
+		return &models.Sighting{ICAO: icao}, nil
+	}
+	return nil, nil
+}
+
+func (m *mockSightings) QueryHistory(filter database.SightingFilter, page database.PageRequest) ([]*models.Sighting, string, error) {
+	return nil, "", nil
+}
+
+func (m *mockSightings) Summary(since time.Time) (*database.SightingSummary, error) {
+	return &database.SightingSummary{}, nil
+}
+
+func TestBus_PublishDefaultsAndCopies(t *testing.T) {
+	bus := NewBus()
+	a := bus.Subscribe(1)
+	b := bus.Subscribe(1)
+	defer b.Unsubscribe()
+
+	bus.Publish(&models.Event{Type: models.EventAlert, Message: "hello"})
+
+	ea, eb := <-a.C, <-b.C
+	assert.Equal(t, models.SeverityInfo, ea.Severity)
+	assert.False(t, ea.Time.IsZero())
+	ea.ID = 42
+	assert.Zero(t, eb.ID, "subscribers must not share event values")
+
+	// Full subscribers drop instead of blocking the publisher
+	bus.Publish(&models.Event{Type: models.EventAlert})
+	bus.Publish(&models.Event{Type: models.EventAlert})
+	assert.Equal(t, int64(2), bus.Dropped())
+
+	a.Unsubscribe()
+	a.Unsubscribe()
+	for range a.C {
+		// Drain events queued before unsubscribing; the loop ends once the channel is closed
+	}
+}
+
+func TestRecorder_DrainsOnShutdown(t *testing.T) {
+	bus := NewBus()
+	repo := &mockEventRepository{}
+	recorder := NewRecorder(bus, repo)
+
+	for i := 0; i < 5; i++ {
+		bus.Publish(&models.Event{Type: models.EventAlert})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, recorder.Start(ctx), context.Canceled)
+	assert.Equal(t, 5, repo.count())
+}
+
+func TestFirstSightingDetector(t *testing.T) {
+	bus := NewBus()
+	events := bus.Subscribe(10)
+	trk := tracker.New(time.Minute)
+	detector := NewFirstSightingDetector(trk, &mockSightings{known: map[string]bool{"AAAAAA": true}}, bus)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go detector.Start(ctx)
+
+	update := func(icao string) {
+		trk.Update(&models.BeastMessage{MessageTypeCode: models.BeastTypeModeSLong, Message: []byte{0x8D, 0, 0, 0}, ICAO: icao})
+	}
+
+	// The detector subscribes asynchronously; keep introducing fresh aircraft until it reports one
+	var icao string
+	attempt := 0
+	require.Eventually(t, func() bool {
+		attempt++
+		update("AAAAAA")
+		icao = fmt.Sprintf("3C65%02X", attempt)
+		update(icao)
+		select {
+		case event := <-events.C:
+			assert.Equal(t, models.EventNewAircraft, event.Type)
+			assert.Equal(t, icao, event.ICAO)
+			assert.Equal(t, "Germany", event.Data["country"])
+			return true
+		case <-time.After(50 * time.Millisecond):
+			return false
+		}
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// Later messages from the same aircraft don't repeat the event
+	update(icao)
+	select {
+	case event := <-events.C:
+		t.Fatalf("unexpected event %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// A poller's first report of an aircraft counts, though its decoder has counted messages before
+	trk.Report(tracker.AircraftState{ICAO: "3C6444", AddressType: models.AddressICAO, LastSeen: time.Now(), Messages: 40})
+	select {
+	case event := <-events.C:
+		assert.Equal(t, "3C6444", event.ICAO)
+	case <-time.After(time.Second):
+		t.Fatal("no event for the reported aircraft")
+	}
+}
+
+type mockTags struct {
+	tags map[string][]*models.AircraftTag
+}
+
+func (m *mockTags) ReplaceSource(source string, tags []*models.AircraftTag) error { return nil }
+
+func (m *mockTags) Get(icao string) ([]*models.AircraftTag, error) { return m.tags[icao], nil }
+
+func (m *mockTags) Sources() ([]*database.TagSource, error) { return nil, nil }
+
+func (m *mockTags) SourceICAOs(source string) ([]string, error) { return nil, nil }
+
+func TestTaggedAircraftDetector(t *testing.T) {
+	bus := NewBus()
+	events := bus.Subscribe(10)
+	detector := NewTaggedAircraftDetector(tracker.New(time.Minute), &mockTags{tags: map[string][]*models.AircraftTag{
+		"AE1234": {
+			{ICAO: "AE1234", Source: "plane-alert", Registration: "12-3456", Operator: "USAF", Category: "USAF", Tags: []string{"Moose"}},
+			{ICAO: "AE1234", Source: "local", Category: "Watched"},
+		},
+	}}, bus)
+
+	detector.check(tracker.AircraftState{ICAO: "3C6544"})
+	detector.check(tracker.AircraftState{ICAO: "AE1234"})
+
+	require.Len(t, events.C, 1, "only listed aircraft raise alerts")
+	event := <-events.C
+	assert.Equal(t, models.EventAlert, event.Type)
+	assert.Equal(t, models.SeverityWarning, event.Severity)
+	assert.Equal(t, "USAF 12-3456 (AE1234) in range", event.Message)
+	assert.Equal(t, []string{"USAF", "Watched"}, event.Data["categories"])
+	assert.Equal(t, []string{"plane-alert", "local"}, event.Data["lists"])
+}
@@ -0,0 +1,324 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/decoder"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/tracker"
+)
+
+const (
+	// maxDeepDiveTrack bounds the positions kept for one aircraft's map; past it every other one is dropped
+	maxDeepDiveTrack = 512
+	// maxPendingDeepDives bounds the aircraft followed at once, e.g. on a fresh database where everything is new
+	maxPendingDeepDives = 50
+
+	deepDiveLookupTimeout = 30 * time.Second
+)
+
+// Registry resolves registry details of an aircraft, such as the metadata resolver chain
+type Registry interface {
+	Resolve(ctx context.Context, icao string) (*models.Aircraft, string, error)
+}
+
+// DeepDiveOptions configures a DeepDiveReporter
+type DeepDiveOptions struct {
+	Wait       time.Duration     // How long to follow an aircraft before reporting, unless its visit ends first
+	MaxPerHour int               // Reports published per hour at most, 0 for no limit
+	Registry   Registry          // Optional, fills in registration, type and operator
+	PhotoURL   string            // Photo page template with {icao} and {registration}, empty for none
+	Receiver   *decoder.Position // Marked on the track map when set
+}
+
+// DeepDiveReporter follows each aircraft the station hears for the first time and then publishes a deep_dive event
+// about it: registry details, a photo link, a guess at where it is going, and a map of its track as a PNG data URI
+type DeepDiveReporter struct {
+	tracker *tracker.Tracker
+	bus     *Bus
+	opts    DeepDiveOptions
+
+	pending  map[string]*deepDive
+	followed []time.Time // Aircraft followed in the last hour
+}
+
+// deepDive is an aircraft being followed for its report
+type deepDive struct {
+	started time.Time
+	state   tracker.AircraftState
+	track   []decoder.Position
+	step    int // Every step-th position is kept, doubled each time the track fills up
+	seen    int
+}
+
+func NewDeepDiveReporter(trk *tracker.Tracker, bus *Bus, opts DeepDiveOptions) *DeepDiveReporter {
+	return &DeepDiveReporter{tracker: trk, bus: bus, opts: opts, pending: make(map[string]*deepDive)}
+}
+
+// Start follows new aircraft until the context is cancelled
+// It subscribes to the bus for new_aircraft events and to the tracker for the aircraft's positions; a report is
+// published once the wait is over or the tracker forgets the aircraft, whichever comes first.
+func (r *DeepDiveReporter) Start(ctx context.Context) error {
+	events := r.bus.Subscribe(100)
+	defer events.Unsubscribe()
+	updates := r.tracker.Subscribe(1000)
+	defer updates.Unsubscribe()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-events.C:
+			if !ok {
+				return nil
+			}
+			if event.Type == models.EventNewAircraft {
+				r.follow(event.ICAO, time.Now())
+			}
+		case update, ok := <-updates.C:
+			if !ok {
+				return nil
+			}
+			dive := r.pending[update.Aircraft.ICAO]
+			if dive == nil {
+				continue
+			}
+			dive.observe(update.Aircraft)
+			if update.Type == tracker.UpdateRemove {
+				r.report(ctx, update.Aircraft.ICAO)
+			}
+		case now := <-ticker.C:
+			for icao, dive := range r.pending {
+				if now.Sub(dive.started) >= r.opts.Wait {
+					r.report(ctx, icao)
+				}
+			}
+		}
+	}
+}
+
+// follow starts following a new aircraft, unless too many are followed or reported already
+func (r *DeepDiveReporter) follow(icao string, now time.Time) {
+	if _, ok := r.pending[icao]; ok || strings.HasPrefix(icao, "~") {
+		return
+	}
+	if len(r.pending) >= maxPendingDeepDives || !r.allow(now) {
+		slog.Debug("Skipping deep dive, too many new aircraft", "icao", icao)
+		return
+	}
+	dive := &deepDive{started: now, step: 1}
+	if state, ok := r.tracker.Get(icao); ok {
+		dive.observe(state)
+	} else {
+		dive.state = tracker.AircraftState{ICAO: icao, FirstSeen: now}
+	}
+	r.pending[icao] = dive
+	r.followed = append(r.followed, now) // Counted as it starts, so a burst of new aircraft can't overrun the limit
+}
+
+// allow reports whether the hourly limit leaves room for another report
+func (r *DeepDiveReporter) allow(now time.Time) bool {
+	if r.opts.MaxPerHour <= 0 {
+		return true
+	}
+	kept := r.followed[:0]
+	for _, t := range r.followed {
+		if now.Sub(t) < time.Hour {
+			kept = append(kept, t)
+		}
+	}
+	r.followed = kept
+	return len(r.followed) < r.opts.MaxPerHour
+}
+
+// observe takes the aircraft's latest state and adds its position to the track when it has moved
+func (d *deepDive) observe(state tracker.AircraftState) {
+	d.state = state
+	if state.Latitude == nil || state.Longitude == nil {
+		return
+	}
+	pos := decoder.Position{Latitude: *state.Latitude, Longitude: *state.Longitude}
+	if n := len(d.track); n > 0 && d.track[n-1] == pos {
+		return
+	}
+	d.seen++
+	if d.seen%d.step != 0 {
+		return
+	}
+	d.track = append(d.track, pos)
+	if len(d.track) >= maxDeepDiveTrack {
+		thinned := d.track[:0]
+		for i := 0; i < len(d.track); i += 2 {
+			thinned = append(thinned, d.track[i])
+		}
+		d.track = thinned
+		d.step *= 2
+	}
+}
+
+// report stops following an aircraft and publishes its deep dive once the registry has been asked, which may take a
+// while over the network
+func (r *DeepDiveReporter) report(ctx context.Context, icao string) {
+	dive := r.pending[icao]
+	delete(r.pending, icao)
+	go r.publish(ctx, dive)
+}
+
+func (r *DeepDiveReporter) publish(ctx context.Context, dive *deepDive) {
+	icao := dive.state.ICAO
+	var registry *models.Aircraft
+	if r.opts.Registry != nil {
+		lookupCtx, cancel := context.WithTimeout(ctx, deepDiveLookupTimeout)
+		ac, _, err := r.opts.Registry.Resolve(lookupCtx, icao)
+		cancel()
+		if err != nil {
+			slog.Debug("Metadata lookup for deep dive failed", "icao", icao, "error", err)
+		}
+		registry = ac
+	}
+	event, err := buildDeepDive(dive.state, dive.track, registry, r.opts)
+	if err != nil {
+		slog.Warn("Failed to build deep dive", "icao", icao, "error", err)
+		return
+	}
+	r.bus.Publish(event)
+}
+
+// buildDeepDive assembles the deep_dive event of an aircraft from its last state, its track, and what the registry
+// knows about it (nil when nothing)
+func buildDeepDive(state tracker.AircraftState, track []decoder.Position, registry *models.Aircraft,
+	opts DeepDiveOptions) (*models.Event, error) {
+	data := map[string]any{"first_seen": state.FirstSeen, "positions": len(track)}
+	name := state.ICAO
+	if registry != nil {
+		for key, value := range map[string]string{
+			"registration": registry.Registration,
+			"type_code":    registry.TypeCode,
+			"manufacturer": registry.ManufacturerName,
+			"model":        registry.Model,
+			"operator":     registry.Operator,
+			"owner":        registry.Owner,
+			"built":        registry.Built,
+		} {
+			if value != "" {
+				data[key] = value
+			}
+		}
+		if registry.Registration != "" {
+			name = registry.Registration
+		}
+		if described := strings.TrimSpace(registry.ManufacturerName + " " + registry.Model); described != "" {
+			name += " (" + described + ")"
+		}
+	}
+	if country := models.CountryForICAO(state.ICAO); country != "" {
+		data["country"] = country
+	}
+	if photo := photoLink(opts.PhotoURL, state.ICAO, registry); photo != "" {
+		data["photo_url"] = photo
+	}
+	guess := routeGuess(state, track, registry)
+	if guess != "" {
+		data["route_guess"] = guess
+	}
+	if len(track) > 0 {
+		img, err := trackMap(track, opts.Receiver)
+		if err != nil {
+			return nil, err
+		}
+		data["track_map"] = img
+	}
+
+	message := "First look at " + name
+	if guess != "" {
+		message += ": " + guess
+	}
+	return &models.Event{
+		Type:     models.EventDeepDive,
+		Severity: models.SeverityInfo,
+		ICAO:     state.ICAO,
+		Callsign: state.Callsign,
+		Message:  message,
+		Data:     data,
+	}, nil
+}
+
+// photoLink fills in the photo page template, empty when it needs a registration that isn't known
+func photoLink(template, icao string, registry *models.Aircraft) string {
+	registration := ""
+	if registry != nil {
+		registration = registry.Registration
+	}
+	if template == "" || (registration == "" && strings.Contains(template, "{registration}")) {
+		return ""
+	}
+	return strings.NewReplacer("{icao}", strings.ToLower(icao), "{registration}", url.PathEscape(registration)).
+		Replace(template)
+}
+
+// airlineCallsign is an ICAO airline designator and flight number, e.g. RYR1AB
+var airlineCallsign = regexp.MustCompile(`^([A-Z]{3})[0-9][0-9A-Z]*$`)
+
+// routeGuess describes what the aircraft seemed to be doing, from its callsign, altitude and climb, and which way
+// its track went. There is no route database, so it names no airports.
+func routeGuess(state tracker.AircraftState, track []decoder.Position, registry *models.Aircraft) string {
+	var parts []string
+	if m := airlineCallsign.FindStringSubmatch(state.Callsign); m != nil {
+		flight := "flight " + state.Callsign
+		if registry != nil && registry.Operator != "" && registry.OperatorICAO == m[1] {
+			flight += " of " + registry.Operator
+		}
+		parts = append(parts, flight)
+	} else if state.Callsign != "" {
+		parts = append(parts, "as "+state.Callsign)
+	}
+
+	switch {
+	case state.OnGround:
+		parts = append(parts, "on the ground")
+	case state.Altitude != nil:
+		phase := "level"
+		if rate := state.VerticalRate; rate != nil && *rate >= 500 {
+			phase = "climbing"
+		} else if rate != nil && *rate <= -500 {
+			phase = "descending"
+		}
+		parts = append(parts, fmt.Sprintf("%s at %s ft", phase, thousands(*state.Altitude)))
+	}
+
+	// Which way it crossed the station's coverage, else the way it was last heading
+	if len(track) >= 2 && decoder.Distance(track[0], track[len(track)-1]) >= 5 {
+		bearing := decoder.Bearing(track[0], track[len(track)-1])
+		parts = append(parts, fmt.Sprintf("from the %s towards the %s", compass(bearing+180), compass(bearing)))
+	} else if state.Track != nil && !state.OnGround {
+		parts = append(parts, "heading "+compass(*state.Track))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// compass names the eight-point direction of a bearing in degrees
+func compass(bearing float64) string {
+	points := []string{"north", "north-east", "east", "south-east", "south", "south-west", "west", "north-west"}
+	return points[int(math.Round(math.Mod(bearing+360, 360)/45))%8]
+}
+
+// thousands formats a number with thousands separators, e.g. 35,000
+func thousands(n int) string {
+	if n < 0 {
+		return "-" + thousands(-n)
+	}
+	s := fmt.Sprint(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
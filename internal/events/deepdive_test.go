@@ -0,0 +1,106 @@
+package events
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/decoder"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/tracker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildDeepDive(t *testing.T) {
+	feet := func(n int) *int { return &n }
+	rate := 1800
+	state := tracker.AircraftState{ICAO: "4840D6", Callsign: "KLM1023", Altitude: feet(12500), VerticalRate: &rate,
+		FirstSeen: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	// Heading north-east from near the receiver
+	track := []decoder.Position{{Latitude: 52.30, Longitude: 4.76}, {Latitude: 52.40, Longitude: 4.95},
+		{Latitude: 52.50, Longitude: 5.15}}
+	registry := &models.Aircraft{Registration: "PH-BXA", ManufacturerName: "Boeing", Model: "737-8K2",
+		Operator: "KLM", OperatorICAO: "KLM", TypeCode: "B738"}
+	opts := DeepDiveOptions{PhotoURL: "https://photos.example/{registration}",
+		Receiver: &decoder.Position{Latitude: 52.31, Longitude: 4.76}}
+
+	event, err := buildDeepDive(state, track, registry, opts)
+	require.NoError(t, err)
+	assert.Equal(t, models.EventDeepDive, event.Type)
+	assert.Equal(t, "4840D6", event.ICAO)
+	assert.Equal(t, "First look at PH-BXA (Boeing 737-8K2): flight KLM1023 of KLM, climbing at 12,500 ft, "+
+		"from the south-west towards the north-east", event.Message)
+	assert.Equal(t, "PH-BXA", event.Data["registration"])
+	assert.Equal(t, "B738", event.Data["type_code"])
+	assert.Equal(t, "https://photos.example/PH-BXA", event.Data["photo_url"])
+	assert.Equal(t, 3, event.Data["positions"])
+	assert.NotContains(t, event.Data, "owner", "what the registry doesn't know is left out")
+
+	// The map is a PNG of the configured size
+	img, ok := event.Data["track_map"].(string)
+	require.True(t, ok)
+	require.True(t, strings.HasPrefix(img, "data:image/png;base64,"))
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(img, "data:image/png;base64,"))
+	require.NoError(t, err)
+	decoded, err := png.Decode(bytes.NewReader(raw))
+	require.NoError(t, err)
+	assert.Equal(t, trackMapSize, decoded.Bounds().Dx())
+}
+
+func TestBuildDeepDive_Unknown(t *testing.T) {
+	track := 270.0
+	state := tracker.AircraftState{ICAO: "A00001", Callsign: "N12345", OnGround: false, Track: &track}
+
+	event, err := buildDeepDive(state, nil, nil, DeepDiveOptions{PhotoURL: "https://photos.example/{registration}"})
+	require.NoError(t, err)
+	assert.Equal(t, "First look at A00001: as N12345, heading west", event.Message)
+	assert.NotContains(t, event.Data, "photo_url", "the template needs a registration")
+	assert.NotContains(t, event.Data, "track_map", "there is nothing to draw without positions")
+}
+
+func TestDeepDiveReporter_Follow(t *testing.T) {
+	now := time.Now()
+	r := NewDeepDiveReporter(tracker.New(time.Minute), NewBus(), DeepDiveOptions{Wait: time.Minute, MaxPerHour: 2})
+
+	r.follow("~A00001", now)
+	assert.Empty(t, r.pending, "anonymous addresses aren't airframes")
+
+	r.follow("4840D6", now)
+	r.follow("4840D6", now)
+	r.follow("A00001", now)
+	r.follow("A00002", now)
+	assert.Len(t, r.pending, 2, "the hourly limit counts aircraft as they are followed")
+
+	r.follow("A00003", now.Add(time.Hour))
+	assert.Contains(t, r.pending, "A00003", "the limit frees up an hour later")
+}
+
+func TestDeepDive_Observe(t *testing.T) {
+	dive := &deepDive{step: 1}
+	for i := 0; i < 2*maxDeepDiveTrack; i++ {
+		lat, lon := 52+float64(i)/1000, 4.76
+		dive.observe(tracker.AircraftState{ICAO: "4840D6", Latitude: &lat, Longitude: &lon})
+		dive.observe(tracker.AircraftState{ICAO: "4840D6", Latitude: &lat, Longitude: &lon})
+	}
+	assert.Less(t, len(dive.track), maxDeepDiveTrack)
+	assert.Greater(t, len(dive.track), maxDeepDiveTrack/4, "thinned, not cut off")
+	assert.Equal(t, 52.0, dive.track[0].Latitude)
+}
+
+func TestCompass(t *testing.T) {
+	assert.Equal(t, "north", compass(0))
+	assert.Equal(t, "north", compass(359))
+	assert.Equal(t, "south-east", compass(130))
+	assert.Equal(t, "west", compass(-90))
+}
+
+func TestThousands(t *testing.T) {
+	assert.Equal(t, "950", thousands(950))
+	assert.Equal(t, "35,000", thousands(35000))
+	assert.Equal(t, "-1,200", thousands(-1200))
+}
@@ -0,0 +1,155 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/tracker"
+)
+
+// FirstSightingDetector emits an event the first time the station ever hears an aircraft
+type FirstSightingDetector struct {
+	tracker   *tracker.Tracker
+	sightings database.SeenAircraftRepository
+	bus       *Bus
+}
+
+func NewFirstSightingDetector(trk *tracker.Tracker, sightings database.SeenAircraftRepository, bus *Bus) *FirstSightingDetector {
+	return &FirstSightingDetector{tracker: trk, sightings: sightings, bus: bus}
+}
+
+// Start watches the tracker until the context is cancelled
+// An aircraft is new when the tracker first updates it and seen_aircraft has no record of it.
+// The check runs as soon as the tracker updates, before the collector's next batch records the sighting.
+func (d *FirstSightingDetector) Start(ctx context.Context) error {
+	sub := d.tracker.Subscribe(1000)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case update, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			if update.Type != tracker.UpdateAircraft || !firstUpdate(update.Aircraft) {
+				continue
+			}
+			d.check(update.Aircraft)
+		}
+	}
+}
+
+func (d *FirstSightingDetector) check(state tracker.AircraftState) {
+	seen, err := d.sightings.Get(state.ICAO)
+	if err != nil {
+		slog.Warn("Failed to check sighting history", "icao", state.ICAO, "error", err)
+		return
+	}
+	if seen != nil {
+		return
+	}
+
+	event := &models.Event{
+		Time:     state.FirstSeen,
+		Type:     models.EventNewAircraft,
+		Severity: models.SeverityInfo,
+		ICAO:     state.ICAO,
+		Message:  fmt.Sprintf("First sighting of %s", state.ICAO),
+	}
+	if country := models.CountryForICAO(state.ICAO); country != "" {
+		event.Data = map[string]any{"country": country}
+	}
+	d.bus.Publish(event)
+}
+
+// firstUpdate reports whether the state is the tracker's first of the aircraft: its first message, or the first
+// report of a poller, which may have counted many already
+func firstUpdate(state tracker.AircraftState) bool {
+	return state.LastSeen.Equal(state.FirstSeen)
+}
+
+// TaggedAircraftDetector emits an alert when an aircraft on a special aircraft list comes into range
+type TaggedAircraftDetector struct {
+	tracker *tracker.Tracker
+	tags    database.TagRepository
+	bus     *Bus
+}
+
+func NewTaggedAircraftDetector(trk *tracker.Tracker, tags database.TagRepository, bus *Bus) *TaggedAircraftDetector {
+	return &TaggedAircraftDetector{tracker: trk, tags: tags, bus: bus}
+}
+
+// Start watches the tracker until the context is cancelled
+// Alerts fire once per visit: an aircraft that drops out of the tracker and returns alerts again.
+func (d *TaggedAircraftDetector) Start(ctx context.Context) error {
+	sub := d.tracker.Subscribe(1000)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case update, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			if update.Type != tracker.UpdateAircraft || !firstUpdate(update.Aircraft) {
+				continue
+			}
+			d.check(update.Aircraft)
+		}
+	}
+}
+
+func (d *TaggedAircraftDetector) check(state tracker.AircraftState) {
+	tags, err := d.tags.Get(state.ICAO)
+	if err != nil {
+		slog.Warn("Failed to look up aircraft tags", "icao", state.ICAO, "error", err)
+		return
+	}
+	if len(tags) == 0 {
+		return
+	}
+
+	// Lists usually agree on the basics, the first one describes the aircraft and all contribute categories
+	tag := tags[0]
+	var categories, sources []string
+	for _, t := range tags {
+		sources = append(sources, t.Source)
+		if t.Category != "" && !slices.Contains(categories, t.Category) {
+			categories = append(categories, t.Category)
+		}
+	}
+
+	name := state.ICAO
+	if tag.Registration != "" {
+		name = fmt.Sprintf("%s (%s)", tag.Registration, state.ICAO)
+	}
+	message := fmt.Sprintf("%s in range", name)
+	if tag.Operator != "" {
+		message = fmt.Sprintf("%s %s in range", tag.Operator, name)
+	}
+
+	d.bus.Publish(&models.Event{
+		Time:     state.FirstSeen,
+		Type:     models.EventAlert,
+		Severity: models.SeverityWarning,
+		ICAO:     state.ICAO,
+		Message:  message,
+		Data: map[string]any{
+			"registration": tag.Registration,
+			"operator":     tag.Operator,
+			"aircraft":     tag.Type,
+			"group":        tag.Group,
+			"categories":   categories,
+			"tags":         tag.Tags,
+			"lists":        sources,
+		},
+	})
+}
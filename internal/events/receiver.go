@@ -0,0 +1,248 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/dump1090"
+	"flight_trmnl/internal/models"
+)
+
+// Receiver anomalies, reported in the maintenance event's data as "anomaly"
+const (
+	AnomalySilent     = "silent"      // No messages at all
+	AnomalyRateDrop   = "rate_drop"   // Far fewer messages than the same hour yesterday
+	AnomalyParseError = "parse_error" // Many frames failing to parse
+)
+
+// parseErrorWindow is how far back parse errors are counted, and minParseErrors how many it takes,
+// so a handful of corrupt frames at a quiet hour doesn't raise an alert.
+const (
+	parseErrorWindow = 10 * time.Minute
+	minParseErrors   = 100
+)
+
+// ReceiverStats is the local receiver's running counters
+type ReceiverStats interface {
+	Stats() dump1090.ClientStats
+}
+
+// ReceiverThresholds are the limits beyond which the receiver is reported
+type ReceiverThresholds struct {
+	RateDrop       float64       // Fraction below the same hour yesterday, e.g. 0.9
+	MinBaseline    int64         // Messages yesterday's hour needs before it is compared against
+	ParseErrorRate float64       // Fraction of frames failing to parse
+	Silence        time.Duration // Time without any message
+}
+
+type receiverMinute struct {
+	time        time.Time
+	messages    int64
+	parseErrors int64
+}
+
+// ReceiverHealthDetector watches the local receiver's message and parse error rates and emits
+// maintenance events when they look like an antenna, cable, or SDR failure, and again when they recover.
+// Counts are stored per hour so today's rate can be compared with the same hour yesterday across restarts.
+type ReceiverHealthDetector struct {
+	source     ReceiverStats
+	hours      database.ReceiverStatsRepository
+	bus        *Bus
+	thresholds ReceiverThresholds
+
+	started     time.Time
+	last        dump1090.ClientStats
+	lastMessage time.Time
+	minutes     []receiverMinute // The last hour, one entry per check
+	active      map[string]bool  // Anomalies raised and not yet recovered
+}
+
+func NewReceiverHealthDetector(source ReceiverStats, hours database.ReceiverStatsRepository, bus *Bus, thresholds ReceiverThresholds) *ReceiverHealthDetector {
+	return &ReceiverHealthDetector{
+		source:     source,
+		hours:      hours,
+		bus:        bus,
+		thresholds: thresholds,
+		active:     make(map[string]bool),
+	}
+}
+
+// Start checks the receiver every minute until the context is cancelled
+func (d *ReceiverHealthDetector) Start(ctx context.Context) error {
+	d.reset(time.Now())
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			d.record(time.Now()) // Keep the last partial minute
+			return ctx.Err()
+		case now := <-ticker.C:
+			d.check(now)
+		}
+	}
+}
+
+// reset starts watching from now, with the source's current counters as the baseline
+func (d *ReceiverHealthDetector) reset(now time.Time) {
+	d.started = now
+	d.lastMessage = now
+	d.last = d.source.Stats()
+}
+
+// record stores the counts since the last call in the current hour and returns them
+func (d *ReceiverHealthDetector) record(now time.Time) receiverMinute {
+	stats := d.source.Stats()
+	minute := receiverMinute{
+		time:        now,
+		messages:    stats.Messages - d.last.Messages,
+		parseErrors: stats.ParseErrors - d.last.ParseErrors,
+	}
+	reconnects := stats.Reconnects - d.last.Reconnects
+	d.last = stats
+
+	if minute.messages > 0 || minute.parseErrors > 0 || reconnects > 0 {
+		if err := d.hours.Add(&database.ReceiverHour{Hour: now, Messages: minute.messages, ParseErrors: minute.parseErrors, Reconnects: reconnects}); err != nil {
+			slog.Warn("Failed to record receiver stats", "error", err)
+		}
+	}
+	return minute
+}
+
+// check records the last minute and raises or clears each anomaly
+func (d *ReceiverHealthDetector) check(now time.Time) {
+	if d.source.Stats().Detached {
+		// Without an input there is nothing to hear; watching starts over once one is configured
+		d.clear(AnomalySilent, "Receiver input removed")
+		d.clear(AnomalyRateDrop, "Receiver input removed")
+		d.clear(AnomalyParseError, "Receiver input removed")
+		d.reset(now)
+		d.minutes = nil
+		return
+	}
+	minute := d.record(now)
+	d.minutes = append(d.minutes, minute)
+	for len(d.minutes) > 0 && now.Sub(d.minutes[0].time) >= time.Hour {
+		d.minutes = d.minutes[1:]
+	}
+	if minute.messages > 0 {
+		d.lastMessage = now
+	}
+
+	d.checkSilence(now)
+	d.checkRateDrop(now)
+	d.checkParseErrors(now)
+}
+
+func (d *ReceiverHealthDetector) checkSilence(now time.Time) {
+	silent := now.Sub(d.lastMessage)
+	if silent < d.thresholds.Silence {
+		d.clear(AnomalySilent, "Receiver is sending messages again")
+		return
+	}
+
+	message := fmt.Sprintf("No messages from the receiver for %s", silent.Round(time.Minute))
+	if !d.last.Connected {
+		message = fmt.Sprintf("Receiver unreachable, no messages for %s", silent.Round(time.Minute))
+	}
+	d.raise(AnomalySilent, models.SeverityCritical, message, map[string]any{
+		"silent_seconds": int(silent.Seconds()),
+		"connected":      d.last.Connected,
+	})
+}
+
+// checkRateDrop compares the last hour with the clock hour around this time yesterday.
+// It waits for a full hour of counts, and skips hours yesterday that were too quiet to compare.
+func (d *ReceiverHealthDetector) checkRateDrop(now time.Time) {
+	if now.Sub(d.started) < time.Hour {
+		return
+	}
+	yesterday, err := d.hours.Get(now.Add(-24*time.Hour - 30*time.Minute))
+	if err != nil {
+		slog.Warn("Failed to get receiver stats", "error", err)
+		return
+	}
+	if yesterday == nil || yesterday.Messages < d.thresholds.MinBaseline {
+		return
+	}
+
+	var lastHour int64
+	for _, m := range d.minutes {
+		lastHour += m.messages
+	}
+	drop := 1 - float64(lastHour)/float64(yesterday.Messages)
+	if drop < d.thresholds.RateDrop {
+		d.clear(AnomalyRateDrop, "Message rate is back to normal for this hour")
+		return
+	}
+	d.raise(AnomalyRateDrop, models.SeverityWarning,
+		fmt.Sprintf("Message rate dropped %.0f%% vs same hour yesterday (%d vs %d messages an hour)", drop*100, lastHour, yesterday.Messages),
+		map[string]any{
+			"messages":          lastHour,
+			"baseline_messages": yesterday.Messages,
+			"baseline_hour":     yesterday.Hour,
+			"drop_percent":      int(drop * 100),
+			"threshold_percent": int(d.thresholds.RateDrop * 100),
+		})
+}
+
+func (d *ReceiverHealthDetector) checkParseErrors(now time.Time) {
+	var messages, parseErrors int64
+	for _, m := range d.minutes {
+		if now.Sub(m.time) < parseErrorWindow {
+			messages += m.messages
+			parseErrors += m.parseErrors
+		}
+	}
+	rate := 0.0
+	if total := messages + parseErrors; total > 0 {
+		rate = float64(parseErrors) / float64(total)
+	}
+	if parseErrors < minParseErrors || rate < d.thresholds.ParseErrorRate {
+		d.clear(AnomalyParseError, "Parse error rate is back to normal")
+		return
+	}
+	d.raise(AnomalyParseError, models.SeverityWarning,
+		fmt.Sprintf("Parse error rate spiked to %.0f%% (%d of %d frames in %s)", rate*100, parseErrors, messages+parseErrors, parseErrorWindow),
+		map[string]any{
+			"parse_errors":      parseErrors,
+			"messages":          messages,
+			"error_percent":     int(rate * 100),
+			"threshold_percent": int(d.thresholds.ParseErrorRate * 100),
+		})
+}
+
+// raise publishes an anomaly once, until it clears
+func (d *ReceiverHealthDetector) raise(anomaly, severity, message string, data map[string]any) {
+	if d.active[anomaly] {
+		return
+	}
+	d.active[anomaly] = true
+	data["anomaly"] = anomaly
+	slog.Warn("Receiver anomaly", "anomaly", anomaly, "message", message)
+	d.bus.Publish(&models.Event{
+		Type:     models.EventMaintenance,
+		Severity: severity,
+		Message:  message,
+		Data:     data,
+	})
+}
+
+// clear publishes the recovery of a raised anomaly
+func (d *ReceiverHealthDetector) clear(anomaly, message string) {
+	if !d.active[anomaly] {
+		return
+	}
+	delete(d.active, anomaly)
+	slog.Info("Receiver anomaly cleared", "anomaly", anomaly)
+	d.bus.Publish(&models.Event{
+		Type:     models.EventMaintenance,
+		Severity: models.SeverityInfo,
+		Message:  message,
+		Data:     map[string]any{"anomaly": anomaly, "recovered": true},
+	})
+}
@@ -0,0 +1,193 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/dump1090"
+	"flight_trmnl/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReceiver is a receiver whose counters the test advances
+type fakeReceiver struct {
+	stats dump1090.ClientStats
+}
+
+func (f *fakeReceiver) Stats() dump1090.ClientStats { return f.stats }
+
+// mockReceiverHours keeps hourly receiver counts in memory
+type mockReceiverHours struct {
+	hours map[int64]*database.ReceiverHour
+}
+
+func (m *mockReceiverHours) Add(h *database.ReceiverHour) error {
+	hour := h.Hour.UTC().Truncate(time.Hour)
+	existing, ok := m.hours[hour.Unix()]
+	if !ok {
+		existing = &database.ReceiverHour{Hour: hour}
+		m.hours[hour.Unix()] = existing
+	}
+	existing.Messages += h.Messages
+	existing.ParseErrors += h.ParseErrors
+	existing.Reconnects += h.Reconnects
+	return nil
+}
+
+func (m *mockReceiverHours) Get(t time.Time) (*database.ReceiverHour, error) {
+	return m.hours[t.UTC().Truncate(time.Hour).Unix()], nil
+}
+
+func (m *mockReceiverHours) List(since time.Time) ([]*database.ReceiverHour, error) { return nil, nil }
+
+func newTestHealthDetector(t *testing.T) (*ReceiverHealthDetector, *fakeReceiver, *mockReceiverHours, *Subscription) {
+	bus := NewBus()
+	sub := bus.Subscribe(100)
+	t.Cleanup(sub.Unsubscribe)
+
+	source := &fakeReceiver{stats: dump1090.ClientStats{Connected: true}}
+	hours := &mockReceiverHours{hours: make(map[int64]*database.ReceiverHour)}
+	d := NewReceiverHealthDetector(source, hours, bus, ReceiverThresholds{
+		RateDrop:       0.9,
+		MinBaseline:    1000,
+		ParseErrorRate: 0.05,
+		Silence:        10 * time.Minute,
+	})
+	return d, source, hours, sub
+}
+
+// run advances the receiver by perMinute messages and errors for n minutes, checking after each
+func run(d *ReceiverHealthDetector, source *fakeReceiver, now time.Time, n int, messages, parseErrors int64) time.Time {
+	for i := 0; i < n; i++ {
+		now = now.Add(time.Minute)
+		source.stats.Messages += messages
+		source.stats.ParseErrors += parseErrors
+		d.check(now)
+	}
+	return now
+}
+
+func drain(sub *Subscription) []*models.Event {
+	var events []*models.Event
+	for {
+		select {
+		case e := <-sub.C:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func TestReceiverHealthDetector_Silence(t *testing.T) {
+	d, source, hours, sub := newTestHealthDetector(t)
+	now := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+	d.reset(now)
+
+	now = run(d, source, now, 5, 600, 0)
+	assert.Empty(t, drain(sub))
+	stored, _ := hours.Get(now)
+	require.NotNil(t, stored)
+	assert.Equal(t, int64(3000), stored.Messages)
+
+	source.stats.Connected = false
+	now = run(d, source, now, 12, 0, 0)
+	events := drain(sub)
+	require.Len(t, events, 1, "raised once while it lasts")
+	assert.Equal(t, models.EventMaintenance, events[0].Type)
+	assert.Equal(t, models.SeverityCritical, events[0].Severity)
+	assert.Equal(t, AnomalySilent, events[0].Data["anomaly"])
+	assert.Contains(t, events[0].Message, "unreachable")
+
+	source.stats.Connected = true
+	run(d, source, now, 1, 600, 0)
+	events = drain(sub)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.SeverityInfo, events[0].Severity)
+	assert.Equal(t, true, events[0].Data["recovered"])
+}
+
+func TestReceiverHealthDetector_Detached(t *testing.T) {
+	d, source, _, sub := newTestHealthDetector(t)
+	now := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+	d.reset(now)
+
+	source.stats.Connected = false
+	now = run(d, source, now, 12, 0, 0)
+	require.Len(t, drain(sub), 1)
+
+	// Removing the input clears the alert, and silence without one isn't an outage
+	source.stats.Detached = true
+	now = run(d, source, now, 30, 0, 0)
+	events := drain(sub)
+	require.Len(t, events, 1)
+	assert.Equal(t, true, events[0].Data["recovered"])
+
+	source.stats.Detached = false
+	run(d, source, now, 5, 0, 0)
+	assert.Empty(t, drain(sub), "silence is counted from when the input was added")
+}
+
+func TestReceiverHealthDetector_RateDrop(t *testing.T) {
+	d, source, hours, sub := newTestHealthDetector(t)
+	now := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+	d.reset(now)
+
+	// The hours around this time yesterday averaged 600 messages a minute
+	for h := -26; h <= -23; h++ {
+		hours.Add(&database.ReceiverHour{Hour: now.Add(time.Duration(h) * time.Hour), Messages: 36000})
+	}
+
+	// Nothing is compared until a full hour has been counted
+	now = run(d, source, now, 59, 30, 0)
+	assert.Empty(t, drain(sub))
+	now = run(d, source, now, 1, 30, 0)
+	events := drain(sub)
+	require.Len(t, events, 1)
+	assert.Equal(t, AnomalyRateDrop, events[0].Data["anomaly"])
+	assert.Equal(t, "Message rate dropped 95% vs same hour yesterday (1800 vs 36000 messages an hour)", events[0].Message)
+
+	// Recovers once the last hour is back within the threshold
+	run(d, source, now, 10, 600, 0)
+	events = drain(sub)
+	require.Len(t, events, 1)
+	assert.Equal(t, true, events[0].Data["recovered"])
+}
+
+func TestReceiverHealthDetector_QuietBaseline(t *testing.T) {
+	d, source, hours, sub := newTestHealthDetector(t)
+	now := time.Date(2024, 5, 2, 3, 0, 0, 0, time.UTC)
+	d.reset(now)
+
+	// A quiet night yesterday isn't a baseline, a few messages tonight are normal
+	for h := -26; h <= -23; h++ {
+		hours.Add(&database.ReceiverHour{Hour: now.Add(time.Duration(h) * time.Hour), Messages: 500})
+	}
+	run(d, source, now, 90, 1, 0)
+	assert.Empty(t, drain(sub))
+}
+
+func TestReceiverHealthDetector_ParseErrors(t *testing.T) {
+	d, source, _, sub := newTestHealthDetector(t)
+	now := time.Date(2024, 5, 2, 12, 0, 0, 0, time.UTC)
+	d.reset(now)
+
+	// A few corrupt frames at a quiet time aren't a spike
+	now = run(d, source, now, 10, 20, 5)
+	assert.Empty(t, drain(sub))
+
+	now = run(d, source, now, 3, 500, 100)
+	events := drain(sub)
+	require.Len(t, events, 1)
+	assert.Equal(t, AnomalyParseError, events[0].Data["anomaly"])
+	assert.Equal(t, models.SeverityWarning, events[0].Severity)
+
+	// Errors older than the window stop counting
+	run(d, source, now, 10, 500, 0)
+	events = drain(sub)
+	require.Len(t, events, 1)
+	assert.Equal(t, true, events[0].Data["recovered"])
+}
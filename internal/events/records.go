@@ -0,0 +1,177 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/decoder"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/tracker"
+)
+
+// recordKey identifies a station record by category and kind
+type recordKey struct{ category, kind string }
+
+// RecordDetector keeps the station records (highest, fastest, slowest airborne, and farthest) across all aircraft
+// and per ADS-B emitter category, and emits one event per visit listing the records an aircraft still holds as it
+// leaves, so a climbing aircraft doesn't announce every hundred feet.
+type RecordDetector struct {
+	tracker  *tracker.Tracker
+	records  database.RecordRepository
+	bus      *Bus
+	receiver *decoder.Position // Where the receiver is, nil when not set and farthest isn't kept
+
+	best map[recordKey]*database.StationRecord // Current records
+	set  map[string][]recordKey                // Records set during the current visit, by aircraft
+}
+
+func NewRecordDetector(trk *tracker.Tracker, records database.RecordRepository, bus *Bus) *RecordDetector {
+	return &RecordDetector{
+		tracker: trk,
+		records: records,
+		bus:     bus,
+		best:    make(map[recordKey]*database.StationRecord),
+		set:     make(map[string][]recordKey),
+	}
+}
+
+// SetReceiver sets the receiver's location, which the farthest records are measured from. Call it before Start.
+func (d *RecordDetector) SetReceiver(latitude, longitude float64) {
+	d.receiver = &decoder.Position{Latitude: latitude, Longitude: longitude}
+}
+
+// Start loads the stored records and watches the tracker until the context is cancelled
+func (d *RecordDetector) Start(ctx context.Context) error {
+	records, err := d.records.List()
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		d.best[recordKey{r.Category, r.Kind}] = r
+	}
+
+	sub := d.tracker.Subscribe(1000)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case update, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			switch update.Type {
+			case tracker.UpdateAircraft:
+				d.check(update.Aircraft)
+			case tracker.UpdateRemove:
+				d.leave(update.Aircraft)
+			}
+		}
+	}
+}
+
+// check offers the aircraft's altitude, speed, and distance as records, speeds only count as slowest while airborne
+// Distances are whole kilometres, so an aircraft moving out doesn't set a record at every position.
+func (d *RecordDetector) check(state tracker.AircraftState) {
+	categories := []string{database.RecordAllCategories}
+	if state.Category != "" {
+		categories = append(categories, state.Category)
+	}
+	airborne := state.Altitude != nil
+	distance := -1
+	if d.receiver != nil && state.Latitude != nil && state.Longitude != nil {
+		distance = int(decoder.Distance(*d.receiver, decoder.Position{Latitude: *state.Latitude, Longitude: *state.Longitude}))
+	}
+
+	for _, category := range categories {
+		if airborne {
+			d.offer(state, category, database.RecordHighest, *state.Altitude)
+		}
+		if state.Speed != nil {
+			d.offer(state, category, database.RecordFastest, *state.Speed)
+			if airborne {
+				d.offer(state, category, database.RecordSlowest, *state.Speed)
+			}
+		}
+		if distance >= 0 {
+			d.offer(state, category, database.RecordFarthest, distance)
+		}
+	}
+}
+
+func (d *RecordDetector) offer(state tracker.AircraftState, category, kind string, value int) {
+	key := recordKey{category, kind}
+	if current, ok := d.best[key]; ok && !database.Beats(kind, value, current.Value) {
+		return
+	}
+	record := &database.StationRecord{
+		Category: category,
+		Kind:     kind,
+		Value:    value,
+		ICAO:     state.ICAO,
+		Callsign: state.Callsign,
+		Time:     state.LastSeen,
+	}
+	set, err := d.records.Offer(record)
+	if err != nil {
+		slog.Warn("Failed to store station record", "icao", state.ICAO, "kind", kind, "error", err)
+		return
+	}
+	if !set {
+		return
+	}
+	d.best[key] = record
+	for _, k := range d.set[state.ICAO] {
+		if k == key {
+			return
+		}
+	}
+	d.set[state.ICAO] = append(d.set[state.ICAO], key)
+}
+
+// leave announces the records an aircraft set during its visit and still holds
+func (d *RecordDetector) leave(state tracker.AircraftState) {
+	keys := d.set[state.ICAO]
+	delete(d.set, state.ICAO)
+
+	var held []*database.StationRecord
+	for _, key := range keys {
+		if r := d.best[key]; r.ICAO == state.ICAO {
+			held = append(held, r)
+		}
+	}
+	if len(held) == 0 {
+		return
+	}
+
+	descriptions := make([]string, 0, len(held))
+	data := make([]map[string]any, 0, len(held))
+	for _, r := range held {
+		descriptions = append(descriptions, describeRecord(r))
+		data = append(data, map[string]any{"category": r.Category, "kind": r.Kind, "value": r.Value, "unit": r.Unit()})
+	}
+
+	d.bus.Publish(&models.Event{
+		Time:     time.Now(),
+		Type:     models.EventRecord,
+		Severity: models.SeverityInfo,
+		ICAO:     state.ICAO,
+		Callsign: state.Callsign,
+		Message:  fmt.Sprintf("%s set station records: %s", aircraftName(state), strings.Join(descriptions, ", ")),
+		Data:     map[string]any{"records": data},
+	})
+}
+
+// describeRecord reads like "fastest A5 at 612 kt", "highest of all at 43000 ft", or "farthest A3 at 412 km"
+func describeRecord(r *database.StationRecord) string {
+	category := r.Category
+	if category == database.RecordAllCategories {
+		category = "of all"
+	}
+	return fmt.Sprintf("%s %s at %d %s", r.Kind, category, r.Value, r.Unit())
+}
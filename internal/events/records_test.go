@@ -0,0 +1,93 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/tracker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockRecords keeps the best record per category and kind like the database
+type mockRecords struct {
+	records map[recordKey]*database.StationRecord
+	offers  int
+}
+
+func (m *mockRecords) Offer(record *database.StationRecord) (bool, error) {
+	m.offers++
+	key := recordKey{record.Category, record.Kind}
+	if current, ok := m.records[key]; ok && !database.Beats(record.Kind, record.Value, current.Value) {
+		return false, nil
+	}
+	m.records[key] = record
+	return true, nil
+}
+
+func (m *mockRecords) List() ([]*database.StationRecord, error) { return nil, nil }
+
+func TestRecordDetector(t *testing.T) {
+	bus := NewBus()
+	events := bus.Subscribe(10)
+	repo := &mockRecords{records: make(map[recordKey]*database.StationRecord)}
+	detector := NewRecordDetector(tracker.New(time.Minute), repo, bus)
+	feet := func(n int) *int { return &n }
+
+	heavy := tracker.AircraftState{ICAO: "4840D6", Callsign: "KLM1023", Category: "A5", Altitude: feet(35000), Speed: feet(480)}
+	detector.check(heavy)
+	heavy.Altitude = feet(37000)
+	detector.check(heavy)
+	detector.check(tracker.AircraftState{ICAO: "A00001", Category: "A7", Altitude: feet(1500), Speed: feet(90)})
+	detector.check(tracker.AircraftState{ICAO: "A00002", OnGround: true, Speed: feet(12)})
+	assert.Empty(t, events.C, "records are announced as aircraft leave")
+
+	offers := repo.offers
+	detector.check(heavy)
+	assert.Equal(t, offers, repo.offers, "values that don't beat a record aren't offered")
+
+	detector.leave(heavy)
+	require.Len(t, events.C, 1)
+	event := <-events.C
+	assert.Equal(t, models.EventRecord, event.Type)
+	assert.Equal(t, "KLM1023", event.Callsign)
+	assert.Equal(t, "KLM1023 (4840D6) set station records: highest of all at 37000 ft, fastest of all at 480 kt, "+
+		"highest A5 at 37000 ft, fastest A5 at 480 kt, slowest A5 at 480 kt", event.Message)
+	assert.Len(t, event.Data["records"], 5, "the overall slowest went to the helicopter")
+
+	detector.leave(tracker.AircraftState{ICAO: "A00002"})
+	assert.Empty(t, events.C, "taxiing beats no record and doesn't count as slowest airborne")
+	assert.Equal(t, 90, repo.records[recordKey{database.RecordAllCategories, database.RecordSlowest}].Value)
+}
+
+func TestRecordDetector_Farthest(t *testing.T) {
+	bus := NewBus()
+	events := bus.Subscribe(10)
+	repo := &mockRecords{records: make(map[recordKey]*database.StationRecord)}
+	detector := NewRecordDetector(tracker.New(time.Minute), repo, bus)
+	degrees := func(f float64) *float64 { return &f }
+
+	// Without the receiver's location there's nothing to measure from
+	far := tracker.AircraftState{ICAO: "4840D6", Callsign: "KLM1023", Category: "A5", Latitude: degrees(53), Longitude: degrees(4)}
+	detector.check(far)
+	assert.Empty(t, repo.records)
+
+	detector.SetReceiver(52, 4)
+	detector.check(far)
+	detector.check(tracker.AircraftState{ICAO: "A00001", Category: "A3", Latitude: degrees(52.5), Longitude: degrees(4)})
+	detector.check(tracker.AircraftState{ICAO: "A00002", Category: "A3"}) // No position yet
+
+	overall := repo.records[recordKey{database.RecordAllCategories, database.RecordFarthest}]
+	require.NotNil(t, overall)
+	assert.Equal(t, 111, overall.Value)
+	assert.Equal(t, "KLM1023", overall.Callsign, "the flight that set it")
+	assert.Equal(t, "km", overall.Unit())
+	assert.Equal(t, 55, repo.records[recordKey{"A3", database.RecordFarthest}].Value)
+
+	detector.leave(far)
+	require.Len(t, events.C, 1)
+	assert.Equal(t, "KLM1023 (4840D6) set station records: farthest of all at 111 km, farthest A5 at 111 km", (<-events.C).Message)
+}
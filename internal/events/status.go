@@ -0,0 +1,173 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/tracker"
+	"flight_trmnl/pkg/schema"
+)
+
+// emergencyDescriptions reads the emergency states in event messages
+var emergencyDescriptions = map[string]string{
+	"general":               "a general emergency",
+	"lifeguard":             "a lifeguard (medical) emergency",
+	"minimum_fuel":          "minimum fuel",
+	"no_communications":     "no communications",
+	"unlawful_interference": "unlawful interference",
+	"downed":                "a downed aircraft",
+}
+
+// StatusDetector watches the emergency status and TCAS resolution advisories aircraft broadcast. It emits an
+// emergency event when an aircraft declares an emergency or changes it, and an advisory event when an encounter
+// starts; every change in an advisory is stored, so an encounter can be reviewed from start to clear of conflict.
+type StatusDetector struct {
+	tracker    *tracker.Tracker
+	advisories database.AdvisoryRepository
+	bus        *Bus
+
+	emergency map[string]string           // Emergency state last seen, by aircraft
+	advisory  map[string]*schema.Advisory // Advisory last stored, by aircraft
+}
+
+func NewStatusDetector(trk *tracker.Tracker, advisories database.AdvisoryRepository, bus *Bus) *StatusDetector {
+	return &StatusDetector{
+		tracker:    trk,
+		advisories: advisories,
+		bus:        bus,
+		emergency:  make(map[string]string),
+		advisory:   make(map[string]*schema.Advisory),
+	}
+}
+
+// Start watches the tracker until the context is cancelled
+func (d *StatusDetector) Start(ctx context.Context) error {
+	sub := d.tracker.Subscribe(1000)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case update, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			switch update.Type {
+			case tracker.UpdateAircraft:
+				d.check(update.Aircraft)
+			case tracker.UpdateRemove:
+				delete(d.emergency, update.Aircraft.ICAO)
+				delete(d.advisory, update.Aircraft.ICAO)
+			}
+		}
+	}
+}
+
+func (d *StatusDetector) check(state tracker.AircraftState) {
+	if state.Emergency != d.emergency[state.ICAO] {
+		if state.Emergency == "" {
+			delete(d.emergency, state.ICAO)
+		} else {
+			d.emergency[state.ICAO] = state.Emergency
+			d.declared(state)
+		}
+	}
+
+	previous := d.advisory[state.ICAO]
+	if state.Advisory == nil {
+		delete(d.advisory, state.ICAO)
+		return
+	}
+	if previous != nil && sameAdvisory(previous, state.Advisory) {
+		return
+	}
+	d.advisory[state.ICAO] = state.Advisory
+	d.store(state)
+	if previous == nil && !state.Advisory.Terminated {
+		d.advised(state)
+	}
+}
+
+// declared emits the event for an aircraft declaring an emergency
+func (d *StatusDetector) declared(state tracker.AircraftState) {
+	description, ok := emergencyDescriptions[state.Emergency]
+	if !ok {
+		description = strings.ReplaceAll(state.Emergency, "_", " ")
+	}
+	message := fmt.Sprintf("%s reports %s", aircraftName(state), description)
+	if state.Squawk != "" {
+		message += ", squawking " + state.Squawk
+	}
+	d.bus.Publish(&models.Event{
+		Time:     state.LastSeen,
+		Type:     models.EventEmergency,
+		Severity: models.SeverityCritical,
+		ICAO:     state.ICAO,
+		Callsign: state.Callsign,
+		Message:  message,
+		Data:     map[string]any{"emergency": state.Emergency, "squawk": state.Squawk},
+	})
+}
+
+// advised emits the event for the start of an encounter
+func (d *StatusDetector) advised(state tracker.AircraftState) {
+	ra := state.Advisory
+	data := map[string]any{"summary": ra.Summary, "ara": ra.ARA, "rac": ra.RAC, "multiple_threats": ra.MultipleThreats}
+	if ra.ThreatICAO != "" {
+		data["threat_icao"] = ra.ThreatICAO
+	}
+	if state.Altitude != nil {
+		data["altitude"] = *state.Altitude
+	}
+	d.bus.Publish(&models.Event{
+		Time:     ra.Time,
+		Type:     models.EventAdvisory,
+		Severity: models.SeverityWarning,
+		ICAO:     state.ICAO,
+		Callsign: state.Callsign,
+		Message:  fmt.Sprintf("%s TCAS resolution advisory: %s", aircraftName(state), ra.Summary),
+		Data:     data,
+	})
+}
+
+// store records an advisory, with the threat's callsign and altitude when the station is tracking it too
+func (d *StatusDetector) store(state tracker.AircraftState) {
+	ra := state.Advisory
+	advisory := &database.Advisory{
+		Time:            ra.Time,
+		ICAO:            state.ICAO,
+		Callsign:        state.Callsign,
+		Altitude:        state.Altitude,
+		ARA:             ra.ARA,
+		RAC:             ra.RAC,
+		Summary:         ra.Summary,
+		Terminated:      ra.Terminated,
+		MultipleThreats: ra.MultipleThreats,
+		ThreatICAO:      ra.ThreatICAO,
+	}
+	if threat, ok := d.tracker.Get(ra.ThreatICAO); ok && ra.ThreatICAO != "" {
+		advisory.ThreatCallsign, advisory.ThreatAltitude = threat.Callsign, threat.Altitude
+	}
+	if err := d.advisories.Insert(advisory); err != nil {
+		slog.Warn("Failed to store resolution advisory", "icao", state.ICAO, "error", err)
+	}
+}
+
+// sameAdvisory reports whether two broadcasts tell the pilot the same thing
+func sameAdvisory(a, b *schema.Advisory) bool {
+	return a.ARA == b.ARA && a.RAC == b.RAC && a.Terminated == b.Terminated &&
+		a.MultipleThreats == b.MultipleThreats && a.ThreatICAO == b.ThreatICAO
+}
+
+// aircraftName reads like "UAL123 (A05F21)", or just the address without a callsign
+func aircraftName(state tracker.AircraftState) string {
+	if state.Callsign != "" {
+		return fmt.Sprintf("%s (%s)", state.Callsign, state.ICAO)
+	}
+	return state.ICAO
+}
@@ -0,0 +1,105 @@
+package events
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/tracker"
+	"flight_trmnl/pkg/schema"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockAdvisories keeps the advisories stored
+type mockAdvisories struct {
+	stored []*database.Advisory
+}
+
+func (m *mockAdvisories) Insert(advisory *database.Advisory) error {
+	m.stored = append(m.stored, advisory)
+	return nil
+}
+
+func (m *mockAdvisories) List(string, time.Time, int) ([]*database.Advisory, error) {
+	return m.stored, nil
+}
+
+func TestStatusDetector_Emergency(t *testing.T) {
+	bus := NewBus()
+	events := bus.Subscribe(10)
+	detector := NewStatusDetector(tracker.New(time.Minute), &mockAdvisories{}, bus)
+
+	state := tracker.AircraftState{ICAO: "A05F21", Callsign: "UAL123", Squawk: "7700", Emergency: "general"}
+	detector.check(state)
+	detector.check(state)
+	require.Len(t, events.C, 1, "once per emergency")
+	event := <-events.C
+	assert.Equal(t, models.EventEmergency, event.Type)
+	assert.Equal(t, models.SeverityCritical, event.Severity)
+	assert.Equal(t, "UAL123 (A05F21) reports a general emergency, squawking 7700", event.Message)
+
+	state.Emergency = "minimum_fuel"
+	detector.check(state)
+	require.Len(t, events.C, 1, "a change is announced")
+	assert.Equal(t, "UAL123 (A05F21) reports minimum fuel, squawking 7700", (<-events.C).Message)
+
+	state.Emergency = ""
+	detector.check(state)
+	state.Emergency = "minimum_fuel"
+	detector.check(state)
+	assert.Len(t, events.C, 1, "declared again after it ended")
+}
+
+func TestStatusDetector_Advisory(t *testing.T) {
+	bus := NewBus()
+	events := bus.Subscribe(10)
+	repo := &mockAdvisories{}
+	trk := tracker.New(time.Minute)
+	detector := NewStatusDetector(trk, repo, bus)
+	feet := 12000
+
+	// The station tracks the threat too: its identification, and an airborne position at 38000 ft
+	for _, frame := range []string{"8D4840D6202CC371C32CE0576098", "8D4840D658C382D690C8AC000000"} {
+		msg, err := hex.DecodeString(frame)
+		require.NoError(t, err)
+		parity := models.ModeSCRC(msg[:11])
+		msg[11], msg[12], msg[13] = byte(parity>>16), byte(parity>>8), byte(parity)
+		trk.Update(&models.BeastMessage{Message: msg, MessageTypeCode: models.BeastTypeModeSLong, ICAO: "4840D6", MessageType: "extended_squitter"})
+	}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	state := tracker.AircraftState{ICAO: "A05F21", Altitude: &feet}
+	advise := func(seconds int, ra schema.Advisory) {
+		ra.Time = start.Add(time.Duration(seconds) * time.Second)
+		state.Advisory = &ra
+		detector.check(state)
+	}
+	advise(0, schema.Advisory{ARA: 0b10000000000000, Summary: "Limit descent, preventive", ThreatICAO: "4840D6"})
+	advise(1, schema.Advisory{ARA: 0b10000000000000, Summary: "Limit descent, preventive", ThreatICAO: "4840D6"})
+	advise(3, schema.Advisory{ARA: 0b11000010000000, Summary: "Climb, corrective", ThreatICAO: "4840D6"})
+	advise(9, schema.Advisory{Summary: "Clear of conflict", Terminated: true})
+
+	require.Len(t, events.C, 1, "one event per encounter")
+	event := <-events.C
+	assert.Equal(t, models.EventAdvisory, event.Type)
+	assert.Equal(t, "A05F21 TCAS resolution advisory: Limit descent, preventive", event.Message)
+	assert.Equal(t, "4840D6", event.Data["threat_icao"])
+	assert.Equal(t, 12000, event.Data["altitude"])
+
+	require.Len(t, repo.stored, 3, "every change is stored, repeats aren't")
+	assert.Equal(t, "Climb, corrective", repo.stored[1].Summary)
+	assert.Equal(t, "KLM1023", repo.stored[0].ThreatCallsign)
+	require.NotNil(t, repo.stored[0].ThreatAltitude)
+	assert.Equal(t, 38000, *repo.stored[0].ThreatAltitude)
+	assert.True(t, repo.stored[2].Terminated)
+	assert.Equal(t, start.Add(9*time.Second), repo.stored[2].Time)
+
+	state.Advisory = nil
+	detector.check(state)
+	advise(60, schema.Advisory{ARA: 0b11100010000000, Summary: "Descend, corrective"})
+	assert.Len(t, events.C, 1, "a new encounter")
+}
@@ -0,0 +1,151 @@
+package events
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+
+	"flight_trmnl/internal/decoder"
+)
+
+const (
+	trackMapSize   = 256 // Pixels square
+	trackMapMargin = 16
+	minTrackMapKM  = 10 // Smallest extent drawn, so a short track isn't blown up to fill the map
+)
+
+// Track map colours, by palette index
+var trackMapPalette = color.Palette{
+	color.RGBA{0xf7, 0xf7, 0xf2, 0xff}, // Background
+	color.RGBA{0xd0, 0xd0, 0xc8, 0xff}, // Range rings
+	color.RGBA{0x1f, 0x5f, 0xbf, 0xff}, // Track
+	color.RGBA{0x2e, 0x8b, 0x3e, 0xff}, // First position
+	color.RGBA{0xc8, 0x2a, 0x2a, 0xff}, // Last position
+	color.RGBA{0x20, 0x20, 0x20, 0xff}, // Receiver
+}
+
+const (
+	mapBackground = iota
+	mapRing
+	mapTrack
+	mapFirst
+	mapLast
+	mapReceiver
+)
+
+// trackMap draws a track as a small PNG data URI, north up: the track from its first position (green) to its last
+// (red), and the receiver, when known, as a cross with rings every 50 km. There are no map tiles, so it is a sketch
+// of the track's shape and where it passed the station rather than a map to navigate by.
+func trackMap(track []decoder.Position, receiver *decoder.Position) (string, error) {
+	// Equirectangular around the middle of what is drawn, fine over the few hundred kilometres a receiver hears
+	points := track
+	if receiver != nil {
+		points = append(points[:len(points):len(points)], *receiver)
+	}
+	minLat, maxLat, minLon, maxLon := points[0].Latitude, points[0].Latitude, points[0].Longitude, points[0].Longitude
+	for _, p := range points[1:] {
+		minLat, maxLat = math.Min(minLat, p.Latitude), math.Max(maxLat, p.Latitude)
+		minLon, maxLon = math.Min(minLon, p.Longitude), math.Max(maxLon, p.Longitude)
+	}
+	center := decoder.Position{Latitude: (minLat + maxLat) / 2, Longitude: (minLon + maxLon) / 2}
+	kmPerLat := 111.2
+	kmPerLon := kmPerLat * math.Cos(center.Latitude*math.Pi/180)
+	extent := math.Max(math.Max((maxLat-minLat)*kmPerLat, (maxLon-minLon)*kmPerLon), minTrackMapKM)
+	scale := float64(trackMapSize-2*trackMapMargin) / extent // Pixels per kilometre
+	project := func(p decoder.Position) (int, int) {
+		x := (p.Longitude - center.Longitude) * kmPerLon * scale
+		y := (p.Latitude - center.Latitude) * kmPerLat * scale
+		return trackMapSize/2 + int(math.Round(x)), trackMapSize/2 - int(math.Round(y))
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, trackMapSize, trackMapSize), trackMapPalette)
+	if receiver != nil {
+		x, y := project(*receiver)
+		for km := 50.0; km*scale < trackMapSize*1.5; km += 50 {
+			drawCircle(img, x, y, int(km*scale), mapRing)
+		}
+	}
+	for i := 1; i < len(track); i++ {
+		x0, y0 := project(track[i-1])
+		x1, y1 := project(track[i])
+		drawLine(img, x0, y0, x1, y1, mapTrack)
+	}
+	if receiver != nil {
+		x, y := project(*receiver)
+		drawLine(img, x-4, y, x+4, y, mapReceiver)
+		drawLine(img, x, y-4, x, y+4, mapReceiver)
+	}
+	x, y := project(track[0])
+	drawDot(img, x, y, mapFirst)
+	x, y = project(track[len(track)-1])
+	drawDot(img, x, y, mapLast)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", fmt.Errorf("failed to encode track map: %w", err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// drawLine draws a line between two points with Bresenham's algorithm, clipped to the image
+func drawLine(img *image.Paletted, x0, y0, x1, y1 int, c uint8) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	for e := dx + dy; ; {
+		if (image.Point{x0, y0}).In(img.Rect) {
+			img.SetColorIndex(x0, y0, c)
+		}
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+// drawCircle draws a circle's outline, clipped to the image
+func drawCircle(img *image.Paletted, cx, cy, r int, c uint8) {
+	steps := max(8*r, 16)
+	for i := 0; i < steps; i++ {
+		a := 2 * math.Pi * float64(i) / float64(steps)
+		x, y := cx+int(math.Round(float64(r)*math.Cos(a))), cy+int(math.Round(float64(r)*math.Sin(a)))
+		if (image.Point{x, y}).In(img.Rect) {
+			img.SetColorIndex(x, y, c)
+		}
+	}
+}
+
+// drawDot draws a filled 5x5 dot, clipped to the image
+func drawDot(img *image.Paletted, cx, cy int, c uint8) {
+	for y := cy - 2; y <= cy+2; y++ {
+		for x := cx - 2; x <= cx+2; x++ {
+			if (image.Point{x, y}).In(img.Rect) && abs(x-cx)+abs(y-cy) < 4 {
+				img.SetColorIndex(x, y, c)
+			}
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,375 @@
+// Package filter compiles the expressions outputs take to choose what they receive, such as
+// df == 17 && alt < 10000 for a hub station that only forwards low ADS-B traffic
+//
+// An expression compares fields with values using == != < <= > >=, and combines comparisons with && || ! and
+// parentheses. Values are numbers, "quoted strings", true, or false; strings compare without regard to case. A
+// boolean field can stand alone, e.g. !on_ground. A comparison with a field the record doesn't have, such as the
+// altitude of an aircraft that hasn't reported one, is false.
+package filter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Kind is the kind of record an expression is compiled for, each with its own fields
+type Kind int
+
+const (
+	KindMessage  Kind = iota // Received messages, see Message
+	KindAircraft             // Tracked aircraft, see Aircraft
+	KindEvent                // Station events, see Event
+)
+
+func (k Kind) String() string {
+	return [...]string{"messages", "aircraft", "events"}[k]
+}
+
+// fieldType is the type of a field's values
+type fieldType int
+
+const (
+	number fieldType = iota
+	text
+	boolean
+)
+
+func (t fieldType) String() string {
+	return [...]string{"number", "string", "true or false"}[t]
+}
+
+// fields are the fields of each kind of record
+var fields = map[Kind]map[string]fieldType{
+	KindMessage: {
+		"icao": text, "df": number, "tc": number, "type": text, "source": text, "signal": number, "corrupt": boolean,
+		"alt": number, "on_ground": boolean, "callsign": text, "category": text, "squawk": text, "speed": number,
+		"track": number, "vrate": number,
+	},
+	KindAircraft: {
+		"icao": text, "type": text, "source": text, "signal": number, "messages": number, "alt": number,
+		"band": text, "on_ground": boolean, "callsign": text, "category": text, "squawk": text, "emergency": text,
+		"speed": number, "track": number, "heading": number, "vrate": number, "lat": number, "lon": number,
+		"nic": number, "nacp": number, "sil": number,
+	},
+	KindEvent: {
+		"type": text, "severity": text, "icao": text, "callsign": text,
+	},
+}
+
+// Fields returns the names of the fields of a kind of record, sorted
+func Fields(kind Kind) []string {
+	names := make([]string, 0, len(fields[kind]))
+	for name := range fields[kind] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Record is what an expression is matched against. Field returns a float64, string, or bool, or nil when the
+// record doesn't have the field.
+type Record interface {
+	Field(name string) any
+}
+
+// Expr is a compiled expression; a nil Expr matches everything
+type Expr struct {
+	src  string
+	eval func(Record) bool
+}
+
+// Compile parses an expression over the fields of a kind of record. An empty expression compiles to nil, which
+// matches everything.
+func Compile(src string, kind Kind) (*Expr, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, nil
+	}
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, fields: fields[kind], kind: kind}
+	eval, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEnd {
+		return nil, p.errorf(t, "unexpected %s, expected && or ||", t)
+	}
+	return &Expr{src: src, eval: eval}, nil
+}
+
+// Match reports whether a record passes the expression
+func (e *Expr) Match(r Record) bool {
+	return e == nil || e.eval(r)
+}
+
+// String returns the expression as written
+func (e *Expr) String() string {
+	if e == nil {
+		return ""
+	}
+	return e.src
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOp    // == != < <= > >=
+	tokenAnd   // &&
+	tokenOr    // ||
+	tokenNot   // !
+	tokenOpen  // (
+	tokenClose // )
+)
+
+type token struct {
+	kind  tokenKind
+	text  string // Identifier, operator, or the string's contents
+	value float64
+	pos   int // Column, from 1
+}
+
+func (t token) String() string {
+	if t.kind == tokenEnd {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// Error is a syntax or type error in an expression
+type Error struct {
+	Column int // Where in the expression, from 1
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Column, e.Msg)
+}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '(' || c == ')':
+			kind := tokenOpen
+			if c == ')' {
+				kind = tokenClose
+			}
+			tokens = append(tokens, token{kind: kind, text: string(c), pos: start + 1})
+			i++
+		case strings.HasPrefix(src[i:], "&&"):
+			tokens = append(tokens, token{kind: tokenAnd, text: "&&", pos: start + 1})
+			i += 2
+		case strings.HasPrefix(src[i:], "||"):
+			tokens = append(tokens, token{kind: tokenOr, text: "||", pos: start + 1})
+			i += 2
+		case strings.HasPrefix(src[i:], "=="), strings.HasPrefix(src[i:], "!="),
+			strings.HasPrefix(src[i:], "<="), strings.HasPrefix(src[i:], ">="):
+			tokens = append(tokens, token{kind: tokenOp, text: src[i : i+2], pos: start + 1})
+			i += 2
+		case c == '<' || c == '>':
+			tokens = append(tokens, token{kind: tokenOp, text: string(c), pos: start + 1})
+			i++
+		case c == '!':
+			tokens = append(tokens, token{kind: tokenNot, text: "!", pos: start + 1})
+			i++
+		case c == '=':
+			return nil, &Error{Column: start + 1, Msg: "use == to compare"}
+		case c == '"':
+			end := strings.IndexByte(src[i+1:], '"')
+			if end < 0 {
+				return nil, &Error{Column: start + 1, Msg: "string without a closing quote"}
+			}
+			tokens = append(tokens, token{kind: tokenString, text: src[i+1 : i+1+end], pos: start + 1})
+			i += end + 2
+		case c >= '0' && c <= '9' || c == '-' || c == '.':
+			for i++; i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.'); i++ {
+			}
+			var value float64
+			if _, err := fmt.Sscanf(src[start:i], "%g", &value); err != nil || src[start:i] == "-" {
+				return nil, &Error{Column: start + 1, Msg: fmt.Sprintf("invalid number %q", src[start:i])}
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: src[start:i], value: value, pos: start + 1})
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			for i++; i < len(src) && (src[i] == '_' || src[i] >= 'a' && src[i] <= 'z' || src[i] >= 'A' && src[i] <= 'Z' || src[i] >= '0' && src[i] <= '9'); i++ {
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: src[start:i], pos: start + 1})
+		default:
+			return nil, &Error{Column: start + 1, Msg: fmt.Sprintf("unexpected character %q", c)}
+		}
+	}
+	return append(tokens, token{kind: tokenEnd, pos: len(src) + 1}), nil
+}
+
+type parser struct {
+	tokens []token
+	next   int
+	fields map[string]fieldType
+	kind   Kind
+}
+
+func (p *parser) peek() token { return p.tokens[p.next] }
+
+func (p *parser) take() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEnd {
+		p.next++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return &Error{Column: t.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) or() (func(Record) bool, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOr {
+		p.take()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(r Record) bool { return l(r) || right(r) }
+	}
+	return left, nil
+}
+
+func (p *parser) and() (func(Record) bool, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenAnd {
+		p.take()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(r Record) bool { return l(r) && right(r) }
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (func(Record) bool, error) {
+	switch t := p.peek(); t.kind {
+	case tokenNot:
+		p.take()
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(r Record) bool { return !operand(r) }, nil
+	case tokenOpen:
+		p.take()
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if close := p.take(); close.kind != tokenClose {
+			return nil, p.errorf(close, "unexpected %s, expected )", close)
+		}
+		return inner, nil
+	case tokenIdent:
+		return p.comparison()
+	default:
+		return nil, p.errorf(t, "unexpected %s, expected a field, ! or (", t)
+	}
+}
+
+func (p *parser) comparison() (func(Record) bool, error) {
+	field := p.take()
+	typ, ok := p.fields[field.text]
+	if !ok {
+		return nil, p.errorf(field, "unknown field %q, %s have %s", field.text, p.kind, strings.Join(Fields(p.kind), ", "))
+	}
+	name := field.text
+
+	op := p.peek()
+	if op.kind != tokenOp {
+		if typ != boolean {
+			return nil, p.errorf(op, "unexpected %s, expected a comparison such as %s == ...", op, name)
+		}
+		return func(r Record) bool { v, _ := r.Field(name).(bool); return v }, nil
+	}
+	p.take()
+
+	value := p.take()
+	switch {
+	case value.kind == tokenIdent && (value.text == "true" || value.text == "false"):
+		if typ != boolean {
+			return nil, p.errorf(value, "%s is a %s, not true or false", name, typ)
+		}
+		if op.text != "==" && op.text != "!=" {
+			return nil, p.errorf(op, "%s can only be compared with == or !=", name)
+		}
+		want := value.text == "true"
+		equal := op.text == "=="
+		return func(r Record) bool {
+			v, ok := r.Field(name).(bool)
+			return ok && (v == want) == equal
+		}, nil
+
+	case value.kind == tokenNumber:
+		if typ != number {
+			return nil, p.errorf(value, "%s is a %s, not a number", name, typ)
+		}
+		want, cmp := value.value, compare(op.text)
+		return func(r Record) bool {
+			v, ok := r.Field(name).(float64)
+			return ok && cmp(v, want)
+		}, nil
+
+	case value.kind == tokenString:
+		if typ != text {
+			return nil, p.errorf(value, "%s is a %s, not a string", name, typ)
+		}
+		if op.text != "==" && op.text != "!=" {
+			return nil, p.errorf(op, "%s can only be compared with == or !=", name)
+		}
+		want, equal := value.text, op.text == "=="
+		return func(r Record) bool {
+			v, ok := r.Field(name).(string)
+			return ok && strings.EqualFold(v, want) == equal
+		}, nil
+
+	case value.kind == tokenIdent:
+		return nil, p.errorf(value, "unexpected %s, strings need quotes, e.g. \"%s\"", value, value.text)
+	default:
+		return nil, p.errorf(value, "unexpected %s, expected a value", value)
+	}
+}
+
+// compare returns the comparison of a numeric operator
+func compare(op string) func(a, b float64) bool {
+	switch op {
+	case "==":
+		return func(a, b float64) bool { return a == b }
+	case "!=":
+		return func(a, b float64) bool { return a != b }
+	case "<":
+		return func(a, b float64) bool { return a < b }
+	case "<=":
+		return func(a, b float64) bool { return a <= b }
+	case ">":
+		return func(a, b float64) bool { return a > b }
+	default:
+		return func(a, b float64) bool { return a >= b }
+	}
+}
@@ -0,0 +1,114 @@
+package filter
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"flight_trmnl/internal/models"
+	"flight_trmnl/pkg/schema"
+)
+
+// message parses a hex DF17 frame received at signal level 100
+func message(t *testing.T, frame string) *models.BeastMessage {
+	t.Helper()
+	data, err := hex.DecodeString(frame)
+	require.NoError(t, err)
+	msg, err := models.NewBeastMessage(models.BeastTypeModeSLong, 0, 100, data, time.Now())
+	require.NoError(t, err)
+	return msg
+}
+
+func TestCompile_Empty(t *testing.T) {
+	expr, err := Compile("  ", KindMessage)
+	require.NoError(t, err)
+	assert.Nil(t, expr)
+	assert.True(t, expr.Match(nil), "a nil expression matches everything")
+}
+
+func TestMessage(t *testing.T) {
+	position := message(t, "8D40621D58C382D690C8AC2863A7")       // 38000 ft
+	identification := message(t, "8D4840D6202CC371C32CE0576098") // KLM1023
+	velocity := message(t, "8D485020994409940838175B284F")       // 159 kt, -832 ft/min
+
+	tests := []struct {
+		expr string
+		msg  *models.BeastMessage
+		want bool
+	}{
+		{"df == 17 && alt < 10000", position, false},
+		{"df == 17 && alt >= 38000", position, true},
+		{"alt < 10000", identification, false},
+		{"!(alt < 10000)", identification, true},
+		{"tc == 4 && callsign == \"klm1023\"", identification, true},
+		{"icao == \"4840D6\" || icao == \"40621D\"", position, true},
+		{"speed > 150 && vrate < 0", velocity, true},
+		{"source == \"adsb\" && !corrupt && signal >= 100", velocity, true},
+		{"on_ground", position, false},
+		{"on_ground == false", position, true},
+		{"df == 17 || df == 18 && tc == 19", position, true},
+		{"(df == 17 || df == 18) && tc == 19", position, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := Compile(tt.expr, KindMessage)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, expr.Match(Message(tt.msg)))
+		})
+	}
+}
+
+func TestAircraft(t *testing.T) {
+	feet, knots, lat, nic := 4500, 420, 51.5, 8
+	state := schema.Aircraft{ICAO: "A1B2C3", Altitude: &feet, AltitudeBand: "low", Speed: &knots, Latitude: &lat, NIC: &nic, Callsign: "UAL1"}
+
+	expr, err := Compile(`band == "LOW" && speed > 400 && lat > 51 && nic >= 7`, KindAircraft)
+	require.NoError(t, err)
+	assert.True(t, expr.Match(Aircraft(state)))
+
+	expr, err = Compile(`vrate > 0 || squawk == "7700"`, KindAircraft)
+	require.NoError(t, err)
+	assert.False(t, expr.Match(Aircraft(state)), "comparisons with missing fields are false")
+
+	expr, err = Compile(`squawk != "7700"`, KindAircraft)
+	require.NoError(t, err)
+	assert.False(t, expr.Match(Aircraft(state)), "even when they are != comparisons")
+}
+
+func TestEvent(t *testing.T) {
+	expr, err := Compile(`severity == "critical" || type == "emergency"`, KindEvent)
+	require.NoError(t, err)
+	assert.True(t, expr.Match(Event(&schema.Event{Type: "emergency", Severity: "warning"})))
+	assert.False(t, expr.Match(Event(&schema.Event{Type: "alert", Severity: "info"})))
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		expr string
+		kind Kind
+		want string
+	}{
+		{"altt < 10000", KindMessage, `column 1: unknown field "altt", messages have alt, callsign,`},
+		{"df = 17", KindMessage, "column 4: use == to compare"},
+		{"callsign == UAL1", KindMessage, `column 13: unexpected "UAL1", strings need quotes`},
+		{"alt < \"high\"", KindMessage, "column 7: alt is a number, not a string"},
+		{"callsign < \"A\"", KindAircraft, "column 10: callsign can only be compared with == or !="},
+		{"df == 17 &&", KindMessage, "column 12: unexpected end of expression, expected a field"},
+		{"(df == 17", KindMessage, "column 10: unexpected end of expression, expected )"},
+		{"df == 17 df == 18", KindMessage, `column 10: unexpected "df", expected && or ||`},
+		{"alt", KindAircraft, "column 4: unexpected end of expression, expected a comparison such as alt == ..."},
+		{"speed > 400", KindEvent, `column 1: unknown field "speed", events have`},
+		{"callsign == \"UAL", KindEvent, "column 13: string without a closing quote"},
+		{"alt > 10,000", KindAircraft, "column 9: unexpected character ','"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Compile(tt.expr, tt.kind)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package filter
+
+import (
+	"flight_trmnl/internal/decoder"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/pkg/schema"
+)
+
+// messageRecord decodes the extended squitter of a message only when a field needs it, so expressions on the
+// header alone, such as df == 17, stay cheap
+type messageRecord struct {
+	msg     *models.BeastMessage
+	decoded *decoder.Message
+	tried   bool
+}
+
+// Message is the record of a received message:
+//
+//	icao, type, source                 address, message type (e.g. airborne_position), adsb, tisb, or adsr
+//	df, tc, signal                     downlink format, ADS-B type code, Beast signal level
+//	corrupt                            the parity shows a reception error
+//	alt, on_ground                     from altitude replies and extended squitters
+//	callsign, category, squawk         from extended squitters
+//	speed, track, vrate                from extended squitter velocities
+func Message(msg *models.BeastMessage) Record {
+	return &messageRecord{msg: msg}
+}
+
+func (r *messageRecord) Field(name string) any {
+	msg := r.msg
+	switch name {
+	case "icao":
+		return msg.ICAO
+	case "type":
+		return msg.MessageType
+	case "df":
+		if df := msg.DownlinkFormat(); df >= 0 {
+			return float64(df)
+		}
+	case "tc":
+		if tc := msg.TypeCode(); tc >= 0 {
+			return float64(tc)
+		}
+	case "signal":
+		return float64(msg.SignalLevel)
+	case "corrupt":
+		return msg.CRCError()
+	case "alt":
+		if alt, ok := msg.Altitude(); ok {
+			return float64(alt)
+		}
+	case "on_ground":
+		if onGround, known := msg.OnGround(); known {
+			return onGround
+		}
+	case "source":
+		if m := r.decode(); m != nil {
+			return m.Source
+		}
+	case "callsign", "category":
+		if m := r.decode(); m != nil && m.Identification != nil {
+			if name == "callsign" {
+				return m.Identification.Callsign
+			}
+			return m.Identification.Category
+		}
+	case "squawk":
+		if m := r.decode(); m != nil && m.Status != nil {
+			return m.Status.Squawk
+		}
+	case "speed", "track", "vrate":
+		if m := r.decode(); m != nil && m.Velocity != nil {
+			switch v := m.Velocity; {
+			case name == "speed" && v.Speed != nil:
+				return float64(*v.Speed)
+			case name == "track" && v.Track != nil:
+				return *v.Track
+			case name == "vrate" && v.VerticalRate != nil:
+				return float64(*v.VerticalRate)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *messageRecord) decode() *decoder.Message {
+	if !r.tried {
+		r.tried = true
+		r.decoded, _ = decoder.Decode(r.msg.Message)
+	}
+	return r.decoded
+}
+
+type aircraftRecord schema.Aircraft
+
+// Aircraft is the record of a tracked aircraft, with the fields named after its JSON ones:
+//
+//	icao, type, source, signal, messages
+//	alt, band, on_ground               altitude in feet, altitude band (surface, low, mid, or high)
+//	callsign, category, squawk, emergency
+//	speed, track, heading, vrate       knots, degrees, feet per minute
+//	lat, lon                           the last decoded position
+//	nic, nacp, sil                     its integrity, see decoder.Integrity
+func Aircraft(state schema.Aircraft) Record {
+	r := aircraftRecord(state)
+	return &r
+}
+
+func (r *aircraftRecord) Field(name string) any {
+	switch name {
+	case "icao":
+		return r.ICAO
+	case "type":
+		return r.MessageType
+	case "source":
+		return orNil(r.Source)
+	case "signal":
+		return float64(r.SignalLevel)
+	case "messages":
+		return float64(r.Messages)
+	case "alt":
+		return intField(r.Altitude)
+	case "band":
+		return orNil(r.AltitudeBand)
+	case "on_ground":
+		return r.OnGround
+	case "callsign":
+		return orNil(r.Callsign)
+	case "category":
+		return orNil(r.Category)
+	case "squawk":
+		return orNil(r.Squawk)
+	case "emergency":
+		return orNil(r.Emergency)
+	case "speed":
+		return intField(r.Speed)
+	case "track":
+		return floatField(r.Track)
+	case "heading":
+		return floatField(r.Heading)
+	case "vrate":
+		return intField(r.VerticalRate)
+	case "lat":
+		return floatField(r.Latitude)
+	case "lon":
+		return floatField(r.Longitude)
+	case "nic":
+		return intField(r.NIC)
+	case "nacp":
+		return intField(r.NACp)
+	case "sil":
+		return intField(r.SIL)
+	}
+	return nil
+}
+
+type eventRecord schema.Event
+
+// Event is the record of a station event: type (e.g. emergency), severity (info, warning, or critical), icao, and
+// callsign
+func Event(e *schema.Event) Record {
+	return (*eventRecord)(e)
+}
+
+func (r *eventRecord) Field(name string) any {
+	switch name {
+	case "type":
+		return r.Type
+	case "severity":
+		return r.Severity
+	case "icao":
+		return orNil(r.ICAO)
+	case "callsign":
+		return orNil(r.Callsign)
+	}
+	return nil
+}
+
+// orNil makes an unset string field missing, so a comparison with it is false either way
+func orNil(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func intField(v *int) any {
+	if v == nil {
+		return nil
+	}
+	return float64(*v)
+}
+
+func floatField(v *float64) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
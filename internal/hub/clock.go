@@ -0,0 +1,210 @@
+package hub
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	ticksPerMicrosecond = 12 // Beast timestamps count a 12 MHz clock
+
+	clockSampleInterval = 1.0  // Seconds of receiver clock between samples of a station pair
+	maxClockSamples     = 600  // Samples kept per pair, ten minutes at one per second
+	minClockSamples     = 10   // Samples needed before a pair is rated
+	clockResetOffset    = 1e6  // Microseconds an offset may jump before the series is treated as a receiver restart
+	maxSystemSamples    = 100  // Batches kept per station for the system clock estimate
+	fairDriftPPM        = 10.0 // Drift beyond this rates a pair fair
+	poorDriftPPM        = 50.0 // Drift beyond this, or a restart in the window, rates a pair poor
+)
+
+// Clock quality ratings
+const (
+	ClockUnknown = "unknown" // Too few common frames yet
+	ClockGood    = "good"
+	ClockFair    = "fair"
+	ClockPoor    = "poor"
+)
+
+// ClockPair compares the receiver clocks of two stations using frames both heard
+// Receiver clocks are free-running, so the offset itself is arbitrary; what matters for multilateration is that it is
+// steady. The spread includes the aircraft's position-dependent difference in path length (up to the distance
+// between the stations divided by the speed of light), which can't be separated out without surveyed station positions.
+type ClockPair struct {
+	A        string  `json:"a"`
+	B        string  `json:"b"`
+	Samples  int     `json:"samples"`
+	OffsetUS float64 `json:"offset_us"` // B's clock minus A's, at the latest sample
+	DriftPPM float64 `json:"drift_ppm"` // Rate the offset changes, in microseconds per second
+	SpreadUS float64 `json:"spread_us"` // RMS deviation from the drift line
+	Resets   int     `json:"resets"`    // Offset jumps (receiver restarts) since the hub started
+	Quality  string  `json:"quality"`
+}
+
+// SystemClock estimates a station's system clock against the hub's from batch send times
+type SystemClock struct {
+	Station  string  `json:"station"`
+	Samples  int     `json:"samples"`
+	OffsetMS float64 `json:"offset_ms"` // Station clock minus hub clock, less the fastest batch's network delay
+}
+
+type clockSample struct {
+	at     float64 // A's receiver clock, seconds
+	offset float64 // B's clock minus A's, microseconds
+}
+
+type pairClock struct {
+	samples []clockSample
+	resets  int
+}
+
+// ClockMonitor measures receiver and system clock offsets between stations
+type ClockMonitor struct {
+	mu     sync.Mutex
+	pairs  map[[2]string]*pairClock
+	delays map[string][]time.Duration // Hub receive time minus station send time per batch, newest last
+}
+
+func NewClockMonitor() *ClockMonitor {
+	return &ClockMonitor{pairs: make(map[[2]string]*pairClock), delays: make(map[string][]time.Duration)}
+}
+
+// AddFrame records one frame heard by two stations with their receiver clocks
+func (c *ClockMonitor) AddFrame(stationA string, ticksA uint64, stationB string, ticksB uint64) {
+	if ticksA == 0 || ticksB == 0 || stationA == stationB {
+		return // Stations without receiver timestamps (e.g. Mode A/C only feeds) can't be compared
+	}
+	if stationB < stationA {
+		stationA, stationB, ticksA, ticksB = stationB, stationA, ticksB, ticksA
+	}
+	sample := clockSample{
+		at:     float64(ticksA) / (ticksPerMicrosecond * 1e6),
+		offset: float64(int64(ticksB)-int64(ticksA)) / ticksPerMicrosecond,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := [2]string{stationA, stationB}
+	pair, ok := c.pairs[key]
+	if !ok {
+		pair = &pairClock{}
+		c.pairs[key] = pair
+	}
+	if n := len(pair.samples); n > 0 {
+		last := pair.samples[n-1]
+		if sample.at < last.at || math.Abs(sample.offset-last.offset) > clockResetOffset {
+			pair.samples = pair.samples[:0] // A receiver restarted and its clock started over
+			pair.resets++
+		} else if sample.at-last.at < clockSampleInterval {
+			return
+		}
+	}
+	if len(pair.samples) == maxClockSamples {
+		pair.samples = append(pair.samples[:0], pair.samples[1:]...)
+	}
+	pair.samples = append(pair.samples, sample)
+}
+
+// AddBatch records when a station sent a batch and when the hub received it
+func (c *ClockMonitor) AddBatch(station string, sent, received time.Time) {
+	if sent.IsZero() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delays := append(c.delays[station], received.Sub(sent))
+	if len(delays) > maxSystemSamples {
+		delays = delays[len(delays)-maxSystemSamples:]
+	}
+	c.delays[station] = delays
+}
+
+// Pairs returns the receiver clock comparison of every station pair with common frames, by name
+func (c *ClockMonitor) Pairs() []ClockPair {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pairs := make([]ClockPair, 0, len(c.pairs))
+	for key, pair := range c.pairs {
+		p := ClockPair{A: key[0], B: key[1], Samples: len(pair.samples), Resets: pair.resets, Quality: ClockUnknown}
+		if len(pair.samples) > 0 {
+			p.OffsetUS, p.DriftPPM, p.SpreadUS = fitDrift(pair.samples)
+		}
+		if p.Samples >= minClockSamples {
+			switch drift := math.Abs(p.DriftPPM); {
+			case drift > poorDriftPPM || pair.resets > 0 && p.Samples < maxClockSamples:
+				p.Quality = ClockPoor
+			case drift > fairDriftPPM:
+				p.Quality = ClockFair
+			default:
+				p.Quality = ClockGood
+			}
+		}
+		pairs = append(pairs, p)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].A != pairs[j].A {
+			return pairs[i].A < pairs[j].A
+		}
+		return pairs[i].B < pairs[j].B
+	})
+	return pairs
+}
+
+// SystemClocks returns each station's system clock offset estimate, by name
+// The fastest batch had the least network delay, so its delay is the closest to the clock offset alone.
+func (c *ClockMonitor) SystemClocks() []SystemClock {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	clocks := make([]SystemClock, 0, len(c.delays))
+	for station, delays := range c.delays {
+		fastest := delays[0]
+		for _, d := range delays[1:] {
+			fastest = min(fastest, d)
+		}
+		clocks = append(clocks, SystemClock{
+			Station:  station,
+			Samples:  len(delays),
+			OffsetMS: math.Round(-float64(fastest)/float64(time.Millisecond)*10) / 10,
+		})
+	}
+	sort.Slice(clocks, func(i, j int) bool { return clocks[i].Station < clocks[j].Station })
+	return clocks
+}
+
+// fitDrift fits a line through the offsets by least squares, returning the fitted latest offset, the slope, and the RMS residual
+func fitDrift(samples []clockSample) (offset, drift, spread float64) {
+	n := float64(len(samples))
+	var sumX, sumY float64
+	for _, s := range samples {
+		sumX += s.at
+		sumY += s.offset
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var sxx, sxy float64
+	for _, s := range samples {
+		sxx += (s.at - meanX) * (s.at - meanX)
+		sxy += (s.at - meanX) * (s.offset - meanY)
+	}
+	if sxx > 0 {
+		drift = sxy / sxx // Microseconds per second is parts per million
+	}
+
+	var sse float64
+	for _, s := range samples {
+		r := s.offset - (meanY + drift*(s.at-meanX))
+		sse += r * r
+	}
+	last := samples[len(samples)-1]
+	offset = meanY + drift*(last.at-meanX)
+	return round2(offset), round2(drift), round2(math.Sqrt(sse / n))
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
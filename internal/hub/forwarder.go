@@ -0,0 +1,185 @@
+package hub
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"flight_trmnl/internal/filter"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/secrets"
+	"flight_trmnl/pkg/schema"
+)
+
+// IngestPath is where a hub accepts message batches from stations
+const IngestPath = "/ingest"
+
+const (
+	forwardQueueSize  = 10000            // Messages buffered while a batch is being sent
+	forwardBatchSize  = 500              // Messages per batch at most
+	forwardInterval   = 1 * time.Second  // Time between batches
+	maxForwardPending = 60000            // Messages held back while the hub is unreachable, oldest dropped first
+	maxForwardBackoff = 60 * time.Second // Upper bound for the wait between failed sends
+)
+
+// ForwarderStats counts messages a station forwarded to its hub
+type ForwarderStats struct {
+	Sent    int64 // Messages accepted by the hub
+	Dropped int64 // Messages lost because the queue or backlog was full
+}
+
+// Forwarder sends the messages a station receives to a hub, so several receivers share one database and tracker
+// Forwarding never blocks the local pipeline: messages queue in memory while the hub is unreachable and the oldest
+// are dropped once the backlog is full.
+type Forwarder struct {
+	url     string
+	station string
+	token   string
+	client  *http.Client
+	where   *filter.Expr // Messages forwarded, all when nil
+	queue   chan *models.BeastMessage
+	sent    atomic.Int64
+	dropped atomic.Int64
+}
+
+// NewForwarder creates a forwarder posting to the hub at hubURL, identifying as station with token
+func NewForwarder(hubURL, station, token string, client *http.Client) *Forwarder {
+	return &Forwarder{
+		url:     strings.TrimSuffix(hubURL, "/") + IngestPath,
+		station: station,
+		token:   token,
+		client:  client,
+		queue:   make(chan *models.BeastMessage, forwardQueueSize),
+	}
+}
+
+// Filter forwards only the messages matching a filter expression compiled for filter.KindMessage; call it before Tee
+func (f *Forwarder) Filter(where *filter.Expr) {
+	f.where = where
+}
+
+// Tee queues the messages from in for forwarding and passes every one on to out, closing out when in is closed
+func (f *Forwarder) Tee(in <-chan *models.BeastMessage, out chan<- *models.BeastMessage) {
+	defer close(out)
+	for msg := range in {
+		if msg == nil {
+			continue
+		}
+		if f.where != nil && !f.where.Match(filter.Message(msg)) {
+			out <- msg
+			continue
+		}
+		select {
+		case f.queue <- msg:
+		default:
+			f.dropped.Add(1)
+		}
+		out <- msg
+	}
+}
+
+// Start sends queued messages to the hub in batches until the context is cancelled
+// Failed sends are retried with exponential backoff, holding on to the messages.
+func (f *Forwarder) Start(ctx context.Context) error {
+	ticker := time.NewTicker(forwardInterval)
+	defer ticker.Stop()
+
+	var pending []*models.BeastMessage
+	var retryAt time.Time
+	backoff := forwardInterval
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg := <-f.queue:
+			pending = append(pending, msg)
+			if len(pending) > maxForwardPending {
+				f.dropped.Add(int64(len(pending) - maxForwardPending))
+				pending = pending[len(pending)-maxForwardPending:]
+			}
+			continue
+		case now := <-ticker.C:
+			if len(pending) == 0 || now.Before(retryAt) {
+				continue
+			}
+		}
+
+		for len(pending) > 0 {
+			n := min(len(pending), forwardBatchSize)
+			if err := f.send(ctx, pending[:n]); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				slog.Warn("Failed to forward messages to hub", "pending", len(pending), "first", pending[0].Ref(), "retry_in", backoff, "error", err)
+				retryAt = time.Now().Add(backoff)
+				backoff = min(backoff*2, maxForwardBackoff)
+				break
+			}
+			f.sent.Add(int64(n))
+			slog.Debug("Forwarded messages to hub", "messages", n, "first", pending[0].Ref(), "last", pending[n-1].Ref())
+			pending = pending[n:]
+			backoff = forwardInterval
+		}
+		if len(pending) == 0 {
+			pending = nil // Release the backlog's memory after an outage
+		}
+	}
+}
+
+// Stats returns how many messages were forwarded and dropped
+func (f *Forwarder) Stats() ForwarderStats {
+	return ForwarderStats{Sent: f.sent.Load(), Dropped: f.dropped.Load()}
+}
+
+// send posts one gzip-compressed batch
+func (f *Forwarder) send(ctx context.Context, msgs []*models.BeastMessage) error {
+	batch := schema.StationBatch{SchemaVersion: schema.HubVersion, Station: f.station, SentAt: time.Now(), Messages: make([]schema.StationMessage, len(msgs))}
+	for i, msg := range msgs {
+		batch.Messages[i] = schema.StationMessage{
+			Time:   msg.Timestamp,
+			Type:   msg.MessageTypeCode,
+			Ticks:  msg.Ticks,
+			Signal: msg.SignalLevel,
+			Data:   hex.EncodeToString(msg.Message),
+		}
+	}
+
+	var body bytes.Buffer
+	zw := gzip.NewWriter(&body)
+	if err := json.NewEncoder(zw).Encode(batch); err != nil {
+		return fmt.Errorf("failed to encode batch: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress batch: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.url, &body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", secrets.RedactError(err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Authorization", "Bearer "+f.token)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", secrets.RedactError(err))
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // Drain so the connection can be reused
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("hub returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package hub
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/filter"
+	"flight_trmnl/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// DF17 identification message
+const testMessage = "8d4840d6202cc371c32ce0576098"
+
+func parsedMessage(t *testing.T, received time.Time) *models.BeastMessage {
+	data, err := hex.DecodeString(testMessage)
+	require.NoError(t, err)
+	msg, err := models.NewBeastMessage(models.BeastTypeModeSLong, 0, 120, data, received)
+	require.NoError(t, err)
+	return msg
+}
+
+// mockStationRepository keeps registered stations in memory
+type mockStationRepository struct {
+	stations map[string]*database.HubStation
+}
+
+func (m *mockStationRepository) Create(station *database.HubStation) error {
+	if _, ok := m.stations[station.Name]; ok {
+		return database.ErrStationExists
+	}
+	m.stations[station.Name] = station
+	return nil
+}
+
+func (m *mockStationRepository) Get(name string) (*database.HubStation, error) {
+	return m.stations[name], nil
+}
+
+func (m *mockStationRepository) List() ([]*database.HubStation, error) {
+	var stations []*database.HubStation
+	for _, s := range m.stations {
+		stations = append(stations, s)
+	}
+	return stations, nil
+}
+
+func (m *mockStationRepository) Revoke(name string, at time.Time) (bool, error) {
+	s, ok := m.stations[name]
+	if !ok || s.RevokedAt != nil {
+		return false, nil
+	}
+	s.RevokedAt = &at
+	return true, nil
+}
+
+func TestForwarderToReceiver(t *testing.T) {
+	out := make(chan *models.BeastMessage, 10)
+	receiver := NewReceiver(NewStaticStations(map[string]string{"north": "n-key", "south": "s-key"}, nil), 0, out)
+	server := httptest.NewServer(NewServer("", "", "", receiver).httpServer.Handler)
+	defer server.Close()
+
+	received := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	north := NewForwarder(server.URL, "north", "n-key", server.Client())
+	require.NoError(t, north.send(context.Background(), []*models.BeastMessage{parsedMessage(t, received)}))
+
+	msg := <-out
+	assert.Equal(t, parsedMessage(t, received).ICAO, msg.ICAO, "parsed like a locally received message")
+	assert.Equal(t, testMessage, msg.Hex())
+	assert.Equal(t, uint8(120), msg.SignalLevel)
+	assert.True(t, msg.Timestamp.Equal(received), "the station's receive time is kept")
+	assert.Equal(t, "hub-1:1", msg.Ref(), "logged by batch and position")
+
+	// South heard the same transmission, north repeats it
+	south := NewForwarder(server.URL, "south", "s-key", server.Client())
+	require.NoError(t, south.send(context.Background(), []*models.BeastMessage{parsedMessage(t, received)}))
+	require.NoError(t, north.send(context.Background(), []*models.BeastMessage{parsedMessage(t, received)}))
+	assert.Len(t, out, 1, "only the other station's copy is dropped")
+
+	stations, err := receiver.Stations()
+	require.NoError(t, err)
+	require.Len(t, stations, 2)
+	assert.Equal(t, "north", stations[0].Name)
+	assert.Equal(t, SourceConfig, stations[0].Source)
+	assert.True(t, stations[0].Online)
+	assert.Equal(t, int64(2), stations[0].Messages)
+	assert.Equal(t, int64(2), stations[0].Batches)
+	assert.Equal(t, int64(1), stations[1].Duplicates)
+	assert.Equal(t, 1, stations[0].Aircraft)
+	assert.Zero(t, stations[0].UniqueAircraft, "both stations heard the aircraft")
+}
+
+func TestReceiver_RejectsUnknownStations(t *testing.T) {
+	receiver := NewReceiver(NewStaticStations(map[string]string{"north": "n-key"}, nil), 0, make(chan *models.BeastMessage, 10))
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	for _, f := range []*Forwarder{
+		NewForwarder(server.URL, "north", "wrong", server.Client()),
+		NewForwarder(server.URL, "west", "n-key", server.Client()),
+	} {
+		f.url = server.URL
+		err := f.send(context.Background(), []*models.BeastMessage{parsedMessage(t, time.Now())})
+		assert.EqualError(t, err, "hub returned status 401")
+	}
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func TestRegistry_IssueAndRevoke(t *testing.T) {
+	registry := NewRegistry(&mockStationRepository{stations: make(map[string]*database.HubStation)})
+	key, err := registry.Issue("east", 25)
+	require.NoError(t, err)
+	assert.Len(t, key, 64)
+	_, err = registry.Issue("east", 0)
+	assert.ErrorIs(t, err, database.ErrStationExists)
+
+	receiver := NewReceiver(Lookups{NewStaticStations(map[string]string{"north": "n-key"}, nil), registry}, 0, make(chan *models.BeastMessage, 10))
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	east := NewForwarder(server.URL, "east", key, server.Client())
+	east.url = server.URL
+
+	require.NoError(t, east.send(context.Background(), []*models.BeastMessage{parsedMessage(t, time.Now())}))
+	stations, err := receiver.Stations()
+	require.NoError(t, err)
+	require.Len(t, stations, 2, "registered and config stations are listed")
+	assert.Equal(t, SourceRegistry, stations[0].Source)
+	assert.Equal(t, 25.0, stations[0].RateLimit)
+
+	revoked, err := registry.Revoke("east")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	assert.EqualError(t, east.send(context.Background(), []*models.BeastMessage{parsedMessage(t, time.Now())}), "hub returned status 401",
+		"revoked keys stop working on the next batch")
+
+	stations, err = receiver.Stations()
+	require.NoError(t, err)
+	assert.Empty(t, stations[0].Source, "revoked stations keep their stats")
+}
+
+func TestReceiver_RateLimit(t *testing.T) {
+	receiver := NewReceiver(NewStaticStations(map[string]string{"north": "n-key"}, map[string]float64{"north": 1}), 100, nil)
+	station, err := receiver.lookup.Station("north")
+	require.NoError(t, err)
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	_, ok := receiver.allow(station, 15, now)
+	assert.True(t, ok, "a batch larger than the burst gets through while the bucket isn't empty")
+	wait, ok := receiver.allow(station, 1, now.Add(time.Second))
+	assert.False(t, ok)
+	assert.Equal(t, 5*time.Second, wait)
+	_, ok = receiver.allow(station, 1, now.Add(6*time.Second))
+	assert.True(t, ok)
+
+	stations, err := receiver.Stations()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stations[0].RateLimited)
+	assert.Equal(t, 1.0, stations[0].RateLimit, "the station's limit overrides the hub default")
+}
+
+func TestReceiver_Availability(t *testing.T) {
+	receiver := NewReceiver(NewStaticStations(map[string]string{"north": "n-key"}, nil), 0, nil)
+	start := time.Now()
+	receiver.started = start
+	for minute := 0; minute < 10; minute += 2 {
+		receiver.dedupe("north", nil, start.Add(time.Duration(minute)*time.Minute))
+	}
+
+	active, err := receiver.lookup.Stations()
+	require.NoError(t, err)
+	stats := receiver.stationStats(active, start.Add(10*time.Minute))
+	assert.Equal(t, 50.0, stats[0].Availability, "a batch in 5 of the 10 minutes since the hub started")
+	assert.False(t, stats[0].Online)
+}
+
+func TestClockMonitor_Pairs(t *testing.T) {
+	clocks := NewClockMonitor()
+	const second = ticksPerMicrosecond * 1e6
+	// South's clock started 5 ms after north's and runs 20 ppm slow
+	for i := uint64(1); i <= 30; i++ {
+		north := i * second
+		south := north - 5000*ticksPerMicrosecond - i*20*ticksPerMicrosecond
+		clocks.AddFrame("south", south, "north", north)
+		clocks.AddFrame("north", north+1, "south", south+1) // Within the sample interval, ignored
+	}
+
+	pairs := clocks.Pairs()
+	require.Len(t, pairs, 1)
+	assert.Equal(t, "north", pairs[0].A, "pairs are ordered by name")
+	assert.Equal(t, 30, pairs[0].Samples)
+	assert.InDelta(t, -20, pairs[0].DriftPPM, 0.01)
+	assert.InDelta(t, -5600, pairs[0].OffsetUS, 0.01)
+	assert.InDelta(t, 0, pairs[0].SpreadUS, 0.01)
+	assert.Equal(t, ClockFair, pairs[0].Quality)
+
+	// South restarts, its clock starts over
+	clocks.AddFrame("north", 31*second, "south", 100)
+	pairs = clocks.Pairs()
+	assert.Equal(t, 1, pairs[0].Resets)
+	assert.Equal(t, ClockUnknown, pairs[0].Quality, "too few samples since the restart")
+}
+
+func TestClockMonitor_SystemClocks(t *testing.T) {
+	clocks := NewClockMonitor()
+	received := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clocks.AddBatch("north", received.Add(1500*time.Millisecond), received) // Station clock 1.5 s ahead
+	clocks.AddBatch("north", received.Add(1200*time.Millisecond), received) // The same, with 300 ms of delay
+	clocks.AddBatch("south", time.Time{}, received)                         // Station without send times
+
+	system := clocks.SystemClocks()
+	require.Len(t, system, 1)
+	assert.Equal(t, SystemClock{Station: "north", Samples: 2, OffsetMS: 1500}, system[0])
+}
+
+func TestReceiver_ComparesClocksOfCommonFrames(t *testing.T) {
+	receiver := NewReceiver(NewStaticStations(map[string]string{"north": "n-key", "south": "s-key"}, nil), 0, nil)
+	now := time.Now()
+	north, south := parsedMessage(t, now), parsedMessage(t, now)
+	north.Ticks, south.Ticks = 12_000_000, 12_000_120
+
+	receiver.dedupe("north", []*models.BeastMessage{north}, now)
+	receiver.dedupe("south", []*models.BeastMessage{south}, now)
+
+	pairs := receiver.clocks.Pairs()
+	require.Len(t, pairs, 1)
+	assert.Equal(t, 10.0, pairs[0].OffsetUS)
+}
+
+func TestForwarder_DropsWhenQueueFull(t *testing.T) {
+	f := NewForwarder("http://hub.invalid", "north", "key", http.DefaultClient)
+	in := make(chan *models.BeastMessage, forwardQueueSize+5)
+	out := make(chan *models.BeastMessage, forwardQueueSize+5)
+	for i := 0; i < forwardQueueSize+5; i++ {
+		in <- parsedMessage(t, time.Now())
+	}
+	close(in)
+
+	f.Tee(in, out)
+	assert.Len(t, out, forwardQueueSize+5, "the local pipeline gets every message")
+	assert.Equal(t, int64(5), f.Stats().Dropped)
+}
+
+func TestForwarder_Filter(t *testing.T) {
+	f := NewForwarder("http://hub.invalid", "north", "key", http.DefaultClient)
+	where, err := filter.Compile(`callsign == "KLM1023"`, filter.KindMessage)
+	require.NoError(t, err)
+	f.Filter(where)
+
+	other := parsedMessage(t, time.Now())
+	other.Message = []byte{0x5D, 0x48, 0x40, 0xD6, 0, 0, 0} // DF11 all-call reply
+	in := make(chan *models.BeastMessage, 2)
+	out := make(chan *models.BeastMessage, 2)
+	in <- parsedMessage(t, time.Now())
+	in <- other
+	close(in)
+
+	f.Tee(in, out)
+	assert.Len(t, out, 2, "the local pipeline gets every message")
+	assert.Len(t, f.queue, 1, "only the matching message is forwarded")
+}
@@ -0,0 +1,217 @@
+package hub
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"flight_trmnl/internal/dump1090"
+	"flight_trmnl/internal/models"
+)
+
+const (
+	pushHandshakeTimeout = 10 * time.Second
+	pushBatchSize        = 500                    // Messages passed on together, like a posted batch
+	pushFlushInterval    = 500 * time.Millisecond // How long messages wait for a batch to fill
+	pushRecheck          = 30 * time.Second       // How often a pushing station's key is checked again
+)
+
+// PushServer accepts Beast streams pushed by stations that can't accept connections, e.g. behind NAT or on a
+// mobile link. A station connects, sends "AUTH <station> <key>" on a line of its own with the key it would post
+// batches with, and once answered "OK" streams Beast frames the way a receiver serves them. The messages go through
+// the receiver like posted batches: rate limited, merged with other stations' copies, and counted in the station
+// stats. The key is checked again every half minute, so a revoked station is disconnected.
+type PushServer struct {
+	addr     string
+	certFile string
+	keyFile  string
+	receiver *Receiver
+	conns    atomic.Uint64 // Numbers connections, whose messages are logged as push-<n>:<position>
+}
+
+// NewPushServer creates a server for stations to push to; with a certificate the streams are wrapped in TLS,
+// which keeps keys off the wire
+func NewPushServer(addr, certFile, keyFile string, receiver *Receiver) *PushServer {
+	return &PushServer{addr: addr, certFile: certFile, keyFile: keyFile, receiver: receiver}
+}
+
+// Start accepts pushed streams until the context is cancelled, then closes them
+func (s *PushServer) Start(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for pushed streams: %w", err)
+	}
+	if s.certFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+		if err != nil {
+			ln.Close()
+			return fmt.Errorf("failed to load hub certificate: %w", err)
+		}
+		ln = tls.NewListener(ln, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+	}
+	slog.Info("Hub listening for pushed Beast streams", "addr", ln.Addr(), "tls", s.certFile != "")
+	return s.serve(ctx, ln)
+}
+
+// serve accepts connections on ln until the context is cancelled
+func (s *PushServer) serve(ctx context.Context, ln net.Listener) error {
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("push listener failed: %w", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handle(ctx, conn)
+		}()
+	}
+}
+
+// handle authenticates a station and passes on what it pushes until it hangs up or the context is cancelled
+func (s *PushServer) handle(ctx context.Context, conn net.Conn) {
+	done := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer func() {
+		stop()
+		conn.Close()
+		close(done)
+	}()
+	connID := fmt.Sprintf("push-%d", s.conns.Add(1))
+	log := slog.With("conn", connID, "remote", conn.RemoteAddr())
+
+	r := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(pushHandshakeTimeout))
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		log.Debug("Station push handshake failed", "error", err)
+		return
+	}
+	name, key, ok := parseAuth(string(line))
+	station, err := s.receiver.authorizeKey(name, key)
+	if err != nil {
+		log.Error("Failed to look up station", "station", name, "error", err)
+		fmt.Fprint(conn, "ERR failed to look up station\n")
+		return
+	}
+	if !ok || station == nil {
+		log.Warn("Rejected station push", "station", name)
+		fmt.Fprint(conn, "ERR unknown station or invalid key\n")
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+	if _, err := fmt.Fprint(conn, "OK\n"); err != nil {
+		return
+	}
+	log = log.With("station", station.Name)
+	log.Info("Station pushing Beast stream")
+
+	// Read on a goroutine of its own, so batches are passed on while the station is quiet
+	msgs := make(chan *models.BeastMessage, pushBatchSize)
+	var readErr error
+	go func() {
+		defer close(msgs)
+		reader := dump1090.NewReader(r, dump1090.FormatBeast)
+		for {
+			msg, err := reader.Next()
+			if dump1090.IsFrameError(err) {
+				log.Debug("Skipping pushed frame", "error", err)
+				continue
+			}
+			if err != nil {
+				readErr = err
+				return
+			}
+			select {
+			case msgs <- msg:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// The station's receiver clock, anchored like a connection's to a local receiver
+	clock, _ := models.NewBeastClock(models.ClockFreeRunning)
+	var seq uint64
+	var batch []*models.BeastMessage
+	checked := time.Now()
+	ticker := time.NewTicker(pushFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				s.receiver.push(ctx, station, batch, log)
+				log.Info("Station push ended", "last_seq", seq, "error", readErr)
+				return
+			}
+			seq++
+			msg.ConnID, msg.Seq = connID, seq
+			msg.Timestamp = clock.Time(msg.Ticks, msg.Timestamp)
+			batch = append(batch, msg)
+			if len(batch) < pushBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if time.Since(checked) >= pushRecheck {
+				checked = time.Now()
+				if current, err := s.receiver.authorizeKey(name, key); err == nil && current == nil {
+					log.Warn("Station key revoked, closing its push")
+					return
+				} else if current != nil {
+					station = current // Picks up a changed rate limit
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+		if !s.receiver.push(ctx, station, batch, log) {
+			return
+		}
+		batch = nil
+	}
+}
+
+// parseAuth returns the station and key of a handshake line, AUTH <station> <key>
+func parseAuth(line string) (station, key string, ok bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 3 || fields[0] != "AUTH" {
+		return "", "", false
+	}
+	return fields[1], fields[2], true
+}
+
+// push passes a pushed batch on like a posted one; a batch over the rate limit is dropped, since a stream can't be
+// sent again. It returns false when the context was cancelled.
+func (r *Receiver) push(ctx context.Context, station *Station, msgs []*models.BeastMessage, log *slog.Logger) bool {
+	if len(msgs) == 0 {
+		return true
+	}
+	now := time.Now()
+	if _, ok := r.allow(station, len(msgs), now); !ok {
+		log.Debug("Dropped pushed messages over the rate limit", "messages", len(msgs))
+		return true
+	}
+	for _, msg := range r.dedupe(station.Name, msgs, now) {
+		select {
+		case r.out <- msg:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package hub
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushServer(t *testing.T) {
+	out := make(chan *models.BeastMessage, 10)
+	receiver := NewReceiver(NewStaticStations(map[string]string{"north": "n-key"}, nil), 0, out)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- NewPushServer("", "", "", receiver).serve(ctx, ln) }()
+
+	handshake := func(line string) (net.Conn, string) {
+		t.Helper()
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		require.NoError(t, err)
+		_, err = conn.Write([]byte(line))
+		require.NoError(t, err)
+		reply, err := bufio.NewReader(conn).ReadString('\n')
+		require.NoError(t, err)
+		return conn, reply
+	}
+
+	for _, line := range []string{"AUTH north wrong\n", "AUTH west n-key\n", "north n-key\n"} {
+		conn, reply := handshake(line)
+		assert.Equal(t, "ERR unknown station or invalid key\n", reply, line)
+		conn.Close()
+	}
+
+	conn, reply := handshake("AUTH north n-key\n")
+	defer conn.Close()
+	require.Equal(t, "OK\n", reply)
+	// A frame with an escaped 1a in its timestamp, then noise
+	frame := []byte{0x1a, '3', 0, 0, 0, 0, 0x1a, 0x1a, 1, 120, 0x8d, 0x48, 0x40, 0xd6, 0x20, 0x2c, 0xc3, 0x71, 0xc3, 0x2c, 0xe0, 0x57, 0x60, 0x98}
+	_, err = conn.Write(append(frame, 0xff))
+	require.NoError(t, err)
+
+	select {
+	case msg := <-out:
+		assert.Equal(t, testMessage, msg.Hex())
+		assert.Equal(t, uint8(120), msg.SignalLevel)
+		assert.Equal(t, uint64(0x1a01), msg.Ticks)
+		assert.Equal(t, "push-4:1", msg.Ref())
+	case <-time.After(3 * time.Second):
+		t.Fatal("pushed message not passed on")
+	}
+	stations, err := receiver.Stations()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stations[0].Messages)
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(3 * time.Second):
+		t.Fatal("server didn't stop with its pushing station connected")
+	}
+}
+
+func TestParseAuth(t *testing.T) {
+	name, key, ok := parseAuth("AUTH north n-key\r\n")
+	assert.True(t, ok)
+	assert.Equal(t, "north", name)
+	assert.Equal(t, "n-key", key)
+	for _, line := range []string{"", "AUTH north\n", "auth north n-key\n", "AUTH north n-key extra\n"} {
+		_, _, ok := parseAuth(line)
+		assert.False(t, ok, line)
+	}
+}
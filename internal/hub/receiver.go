@@ -0,0 +1,342 @@
+package hub
+
+import (
+	"compress/gzip"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"flight_trmnl/internal/models"
+	"flight_trmnl/pkg/schema"
+)
+
+const (
+	maxBatchBytes = 8 << 20         // Decompressed batch size limit
+	dedupeWindow  = 2 * time.Second // Identical messages from several stations within this window are merged
+
+	rateBurst        = 10 * time.Second // Messages a station may send ahead of its rate limit, in seconds of its rate
+	onlineWindow     = 30 * time.Second // Stations that sent a batch this recently are online
+	availabilitySpan = 24 * time.Hour   // Window of the availability percentage
+	coverageSpan     = 1 * time.Hour    // Window of the aircraft counts
+)
+
+// StationStats describes one station feeding a hub
+type StationStats struct {
+	Name        string    `json:"name"`
+	Source      string    `json:"source"` // config or registry, empty for stations no longer allowed
+	Online      bool      `json:"online"`
+	LastSeen    time.Time `json:"last_seen"`  // Zero until the first batch
+	Messages    int64     `json:"messages"`   // Messages accepted from this station
+	Duplicates  int64     `json:"duplicates"` // Messages another station already delivered
+	Batches     int64     `json:"batches"`
+	RateLimited int64     `json:"rate_limited"` // Batches rejected for exceeding the rate limit
+	RateLimit   float64   `json:"rate_limit"`   // Messages per second, 0 is unlimited
+
+	Availability   float64 `json:"availability"`    // Percent of minutes with a batch over the last 24 hours (or since the hub started)
+	Aircraft       int     `json:"aircraft"`        // Aircraft heard in the last hour
+	UniqueAircraft int     `json:"unique_aircraft"` // Aircraft only this station heard in the last hour
+}
+
+// stationState is what the receiver tracks per station
+type stationState struct {
+	stats    StationStats
+	tokens   float64 // Rate limit bucket, may go negative by one batch
+	refilled time.Time
+	minutes  map[int64]bool       // Unix minutes with a batch, for availability
+	aircraft map[string]time.Time // ICAO -> last heard, for coverage
+}
+
+// delivery records which station passed on a message, when, and the station's receiver clock
+type delivery struct {
+	station string
+	time    time.Time
+	ticks   uint64
+}
+
+// Receiver accepts message batches from stations and feeds them into the hub's pipeline
+// Stations authenticate with a bearer key matched against the station lookup on every batch, so revoked keys
+// stop working immediately. The same transmission heard by several stations is passed on once, so counts
+// aren't inflated by overlap.
+type Receiver struct {
+	lookup    StationLookup
+	rateLimit float64 // Default messages per second per station, 0 is unlimited
+	out       chan<- *models.BeastMessage
+	started   time.Time
+	clocks    *ClockMonitor
+	batches   atomic.Uint64 // Numbers accepted batches, whose messages are logged as hub-<n>:<position>
+
+	mu       sync.Mutex
+	stations map[string]*stationState
+	recent   map[string]delivery // Message type and bytes -> the station that last passed it on
+	pruned   time.Time
+}
+
+// NewReceiver creates a receiver for the stations of lookup, sending accepted messages to out
+func NewReceiver(lookup StationLookup, rateLimit float64, out chan<- *models.BeastMessage) *Receiver {
+	now := time.Now()
+	return &Receiver{
+		lookup:    lookup,
+		rateLimit: rateLimit,
+		out:       out,
+		started:   now,
+		clocks:    NewClockMonitor(),
+		stations:  make(map[string]*stationState),
+		recent:    make(map[string]delivery),
+		pruned:    now,
+	}
+}
+
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body io.Reader = req.Body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(req.Body)
+		if err != nil {
+			http.Error(w, "invalid gzip body", http.StatusBadRequest)
+			return
+		}
+		defer zr.Close()
+		body = zr
+	}
+
+	var batch schema.StationBatch
+	if err := json.NewDecoder(io.LimitReader(body, maxBatchBytes)).Decode(&batch); err != nil {
+		http.Error(w, "invalid batch: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	station, err := r.authorize(batch.Station, req.Header.Get("Authorization"))
+	if err != nil {
+		slog.Error("Failed to look up station", "station", batch.Station, "error", err)
+		http.Error(w, "failed to look up station", http.StatusInternalServerError)
+		return
+	}
+	if station == nil {
+		http.Error(w, "unknown station or invalid key", http.StatusUnauthorized)
+		return
+	}
+	if batch.SchemaVersion != schema.HubVersion {
+		http.Error(w, fmt.Sprintf("unsupported schema_version %d (hub speaks %d)", batch.SchemaVersion, schema.HubVersion), http.StatusBadRequest)
+		return
+	}
+
+	if wait, ok := r.allow(station, len(batch.Messages), time.Now()); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+
+	r.clocks.AddBatch(station.Name, batch.SentAt, time.Now())
+
+	connID := fmt.Sprintf("hub-%d", r.batches.Add(1))
+	log := slog.With("station", station.Name, "conn", connID)
+	msgs := make([]*models.BeastMessage, 0, len(batch.Messages))
+	for i, m := range batch.Messages {
+		data, err := hex.DecodeString(m.Data)
+		if err != nil {
+			log.Debug("Skipping undecodable station message", "seq", i+1, "error", err)
+			continue
+		}
+		msg, err := models.NewBeastMessage(m.Type, m.Ticks, m.Signal, data, m.Time)
+		if err != nil {
+			log.Debug("Skipping invalid station message", "seq", i+1, "error", err)
+			continue
+		}
+		msg.ConnID, msg.Seq = connID, uint64(i+1)
+		msgs = append(msgs, msg)
+	}
+
+	accepted := r.dedupe(station.Name, msgs, time.Now())
+	log.Debug("Received station batch", "messages", len(batch.Messages), "accepted", len(accepted))
+	for _, msg := range accepted {
+		select {
+		case r.out <- msg:
+		case <-req.Context().Done():
+			return // The station resends the batch, duplicates are merged
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorize returns the station a bearer key belongs to, or nil when the station is unknown or the key doesn't match
+func (r *Receiver) authorize(name, header string) (*Station, error) {
+	key, found := strings.CutPrefix(header, "Bearer ")
+	if !found {
+		return nil, nil
+	}
+	return r.authorizeKey(name, key)
+}
+
+// authorizeKey returns the station a key belongs to, or nil when the station is unknown or the key doesn't match
+func (r *Receiver) authorizeKey(name, key string) (*Station, error) {
+	if name == "" || key == "" {
+		return nil, nil
+	}
+	station, err := r.lookup.Station(name)
+	if err != nil || station == nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(HashKey(key)), []byte(station.KeyHash)) != 1 {
+		return nil, nil
+	}
+	return station, nil
+}
+
+// allow takes n messages from the station's rate limit bucket, or returns how long until it has room again
+// A batch is accepted while the bucket isn't empty, so batches larger than the burst still get through.
+func (r *Receiver) allow(station *Station, n int, now time.Time) (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state := r.state(station.Name)
+	rate := station.RateLimit
+	if rate <= 0 {
+		rate = r.rateLimit
+	}
+	if rate <= 0 {
+		return 0, true
+	}
+
+	burst := rate * rateBurst.Seconds()
+	if state.refilled.IsZero() {
+		state.tokens = burst
+	} else {
+		state.tokens = math.Min(burst, state.tokens+now.Sub(state.refilled).Seconds()*rate)
+	}
+	state.refilled = now
+
+	if state.tokens <= 0 {
+		state.stats.RateLimited++
+		return time.Duration((-state.tokens/rate + 1) * float64(time.Second)), false
+	}
+	state.tokens -= float64(n)
+	return 0, true
+}
+
+// state returns a station's state, creating it on first use; r.mu must be held
+func (r *Receiver) state(name string) *stationState {
+	state, ok := r.stations[name]
+	if !ok {
+		state = &stationState{
+			stats:    StationStats{Name: name},
+			minutes:  make(map[int64]bool),
+			aircraft: make(map[string]time.Time),
+		}
+		r.stations[name] = state
+	}
+	return state
+}
+
+// dedupe drops messages another station delivered within the dedupe window and updates the station's stats
+func (r *Receiver) dedupe(station string, msgs []*models.BeastMessage, now time.Time) []*models.BeastMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now.Sub(r.pruned) >= dedupeWindow {
+		for key, seen := range r.recent {
+			if now.Sub(seen.time) >= dedupeWindow {
+				delete(r.recent, key)
+			}
+		}
+		r.pruned = now
+	}
+
+	state := r.state(station)
+	accepted := msgs[:0]
+	for _, msg := range msgs {
+		if msg.ICAO != "" {
+			state.aircraft[msg.ICAO] = now
+		}
+
+		// A station's own repeats (e.g. all-call replies) are real messages, only other stations' copies are dropped
+		key := string(msg.MessageTypeCode) + string(msg.Message)
+		if seen, ok := r.recent[key]; ok && seen.station != station && now.Sub(seen.time) < dedupeWindow {
+			state.stats.Duplicates++
+			r.clocks.AddFrame(seen.station, seen.ticks, station, msg.Ticks)
+			continue
+		}
+		r.recent[key] = delivery{station: station, time: now, ticks: msg.Ticks}
+		accepted = append(accepted, msg)
+	}
+	state.stats.Messages += int64(len(accepted))
+	state.stats.Batches++
+	state.stats.LastSeen = now
+	state.minutes[now.Unix()/60] = true
+	return accepted
+}
+
+// Stations returns the stats of every active station and of stations that sent batches since the hub started, by name
+func (r *Receiver) Stations() ([]StationStats, error) {
+	active, err := r.lookup.Stations()
+	if err != nil {
+		return nil, err
+	}
+	return r.stationStats(active, time.Now()), nil
+}
+
+func (r *Receiver) stationStats(active []*Station, now time.Time) []StationStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sources := make(map[string]string, len(active))
+	for _, station := range active {
+		sources[station.Name] = station.Source
+		state := r.state(station.Name)
+		state.stats.RateLimit = station.RateLimit
+		if station.RateLimit <= 0 {
+			state.stats.RateLimit = r.rateLimit
+		}
+	}
+
+	// Prune old coverage and availability data, and count which stations heard each aircraft
+	heardBy := make(map[string]int)
+	span := int64(math.Min(availabilitySpan.Minutes(), math.Ceil(now.Sub(r.started).Minutes())))
+	for _, state := range r.stations {
+		for icao, heard := range state.aircraft {
+			if now.Sub(heard) >= coverageSpan {
+				delete(state.aircraft, icao)
+				continue
+			}
+			heardBy[icao]++
+		}
+		for minute := range state.minutes {
+			if minute <= now.Unix()/60-int64(availabilitySpan.Minutes()) {
+				delete(state.minutes, minute)
+			}
+		}
+	}
+
+	stations := make([]StationStats, 0, len(r.stations))
+	for name, state := range r.stations {
+		stats := state.stats
+		stats.Source = sources[name] // Empty when revoked or removed from the config
+		stats.Online = !stats.LastSeen.IsZero() && now.Sub(stats.LastSeen) < onlineWindow
+		stats.Aircraft = len(state.aircraft)
+		for icao := range state.aircraft {
+			if heardBy[icao] == 1 {
+				stats.UniqueAircraft++
+			}
+		}
+		if span > 0 {
+			stats.Availability = math.Min(100, math.Round(float64(len(state.minutes))*1000/float64(span))/10)
+		}
+		stations = append(stations, stats)
+	}
+	sort.Slice(stations, func(i, j int) bool { return stations[i].Name < stations[j].Name })
+	return stations
+}
@@ -0,0 +1,138 @@
+package hub
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"flight_trmnl/pkg/schema"
+)
+
+// Server accepts station batches on its own listener, so the ingest port can be exposed without the API
+type Server struct {
+	httpServer *http.Server
+	certFile   string
+	keyFile    string
+}
+
+// NewServer creates an ingest server for the receiver
+// Without a certificate it serves plain HTTP, for hubs behind a TLS-terminating reverse proxy.
+func NewServer(addr, certFile, keyFile string, receiver *Receiver) *Server {
+	mux := http.NewServeMux()
+	mux.Handle(IngestPath, receiver)
+	return &Server{
+		httpServer: &http.Server{
+			Addr:              addr,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+}
+
+// Start serves station batches until the context is cancelled
+// This method blocks; in-flight batches get up to 5 seconds to finish after cancellation.
+func (s *Server) Start(ctx context.Context) error {
+	errChan := make(chan error, 1)
+	go func() {
+		slog.Info("Hub listening for stations", "addr", s.httpServer.Addr, "tls", s.certFile != "")
+		if s.certFile != "" {
+			errChan <- s.httpServer.ListenAndServeTLS(s.certFile, s.keyFile)
+		} else {
+			errChan <- s.httpServer.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errChan:
+		return fmt.Errorf("hub server failed: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down hub server: %w", err)
+	}
+
+	if err := <-errChan; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return ctx.Err()
+}
+
+// NewClient creates the HTTP client a station posts to its hub with
+// caFile adds a CA to trust, e.g. for a hub with a self-signed certificate; empty uses the system roots.
+func NewClient(caFile string) (*http.Client, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	if caFile == "" {
+		return client, nil
+	}
+
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %w", err)
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	client.Transport = transport
+	return client, nil
+}
+
+// StationsHandler reports each station's message counts, for the API server
+func (r *Receiver) StationsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		stations, err := r.Stations()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, map[string]any{
+			"schema_version": schema.APIVersion,
+			"stations":       stations,
+		})
+	})
+}
+
+// ClocksHandler reports receiver clock agreement between station pairs and each station's system clock offset
+func (r *Receiver) ClocksHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, map[string]any{
+			"schema_version": schema.APIVersion,
+			"pairs":          r.clocks.Pairs(),
+			"system":         r.clocks.SystemClocks(),
+		})
+	})
+}
+
+func writeJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(schema.VersionHeader, strconv.Itoa(schema.APIVersion))
+	json.NewEncoder(w).Encode(body)
+}
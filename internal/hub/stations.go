@@ -0,0 +1,148 @@
+package hub
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"flight_trmnl/internal/database"
+)
+
+// Station sources
+const (
+	SourceConfig   = "config"   // Listed in hub.stations
+	SourceRegistry = "registry" // Issued a key with the stations command
+)
+
+// Station is a station allowed to feed the hub
+type Station struct {
+	Name      string
+	KeyHash   string  // Hex sha256 of the station's key
+	RateLimit float64 // Messages per second, 0 uses the hub default
+	Source    string
+}
+
+// StationLookup finds the stations allowed to feed the hub
+type StationLookup interface {
+	// Station returns an active station by name, or nil when it is unknown or revoked
+	Station(name string) (*Station, error)
+	// Stations returns all active stations
+	Stations() ([]*Station, error)
+}
+
+// HashKey returns the hex sha256 stations are matched by, so keys are never stored
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// StaticStations are stations with keys from the config file
+type StaticStations map[string]*Station
+
+// NewStaticStations creates stations from names and keys, each limited to rateLimit messages per second
+func NewStaticStations(keys map[string]string, rateLimits map[string]float64) StaticStations {
+	stations := make(StaticStations, len(keys))
+	for name, key := range keys {
+		stations[name] = &Station{Name: name, KeyHash: HashKey(key), RateLimit: rateLimits[name], Source: SourceConfig}
+	}
+	return stations
+}
+
+func (s StaticStations) Station(name string) (*Station, error) {
+	return s[name], nil
+}
+
+func (s StaticStations) Stations() ([]*Station, error) {
+	stations := make([]*Station, 0, len(s))
+	for _, station := range s {
+		stations = append(stations, station)
+	}
+	return stations, nil
+}
+
+// Registry issues and revokes keys for stations kept in the database
+// Revocation takes effect on the station's next batch.
+type Registry struct {
+	repo database.HubStationRepository
+}
+
+func NewRegistry(repo database.HubStationRepository) *Registry {
+	return &Registry{repo: repo}
+}
+
+// Issue registers a station and returns its key, which is only shown this once
+func (r *Registry) Issue(name string, rateLimit float64) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("station name is required")
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	key := hex.EncodeToString(buf)
+	station := &database.HubStation{Name: name, KeyHash: HashKey(key), RateLimit: rateLimit, CreatedAt: time.Now()}
+	if err := r.repo.Create(station); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// Revoke disables a station's key, reporting whether an active station was revoked
+func (r *Registry) Revoke(name string) (bool, error) {
+	return r.repo.Revoke(name, time.Now())
+}
+
+func (r *Registry) Station(name string) (*Station, error) {
+	s, err := r.repo.Get(name)
+	if err != nil || s == nil || s.RevokedAt != nil {
+		return nil, err
+	}
+	return &Station{Name: s.Name, KeyHash: s.KeyHash, RateLimit: s.RateLimit, Source: SourceRegistry}, nil
+}
+
+func (r *Registry) Stations() ([]*Station, error) {
+	registered, err := r.repo.List()
+	if err != nil {
+		return nil, err
+	}
+	var stations []*Station
+	for _, s := range registered {
+		if s.RevokedAt == nil {
+			stations = append(stations, &Station{Name: s.Name, KeyHash: s.KeyHash, RateLimit: s.RateLimit, Source: SourceRegistry})
+		}
+	}
+	return stations, nil
+}
+
+// Lookups tries each lookup in order, so config stations take precedence over registered ones
+type Lookups []StationLookup
+
+func (l Lookups) Station(name string) (*Station, error) {
+	for _, lookup := range l {
+		station, err := lookup.Station(name)
+		if err != nil || station != nil {
+			return station, err
+		}
+	}
+	return nil, nil
+}
+
+func (l Lookups) Stations() ([]*Station, error) {
+	seen := make(map[string]bool)
+	var stations []*Station
+	for _, lookup := range l {
+		found, err := lookup.Stations()
+		if err != nil {
+			return nil, err
+		}
+		for _, station := range found {
+			if !seen[station.Name] {
+				seen[station.Name] = true
+				stations = append(stations, station)
+			}
+		}
+	}
+	return stations, nil
+}
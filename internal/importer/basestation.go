@@ -0,0 +1,80 @@
+package importer
+
+import (
+	"fmt"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+)
+
+// ImportBaseStationFlights merges the Flights table of a BaseStation.sqb into the seen_aircraft table
+// Returns the number of distinct aircraft imported.
+func ImportBaseStationFlights(repo database.SeenAircraftRepository, sqbPath string) (int, error) {
+	src, err := database.OpenBaseStation(sqbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	flights, err := src.Flights()
+	if err != nil {
+		return 0, err
+	}
+
+	byICAO := make(map[string]*models.Sighting)
+	var sightings []*models.Sighting
+
+	for _, f := range flights {
+		s, ok := byICAO[f.ICAO]
+		if !ok {
+			s = &models.Sighting{
+				ICAO:      f.ICAO,
+				FirstSeen: f.Start,
+				LastSeen:  f.End,
+				Source:    "basestation",
+			}
+			byICAO[f.ICAO] = s
+			sightings = append(sightings, s)
+		}
+
+		if f.Start.Before(s.FirstSeen) {
+			s.FirstSeen = f.Start
+		}
+		if !f.End.Before(s.LastSeen) {
+			s.LastSeen = f.End
+			if f.Callsign != "" {
+				s.Callsign = f.Callsign
+			}
+		}
+		s.MessageCount += f.Messages
+	}
+
+	if err := repo.UpsertBatch(sightings); err != nil {
+		return 0, fmt.Errorf("failed to store BaseStation sightings: %w", err)
+	}
+
+	return len(sightings), nil
+}
+
+// ImportBaseStationAircraft merges the Aircraft table of a BaseStation.sqb into the aircraft table
+// BaseStation records are usually hand-maintained, so their non-empty fields are stored as curated
+// and take precedence over the bulk CSV dataset, including when the dataset is reloaded later.
+// Returns the number of aircraft imported.
+func ImportBaseStationAircraft(repo database.AircraftRepository, sqbPath string) (int, error) {
+	src, err := database.OpenBaseStation(sqbPath)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	aircraft, err := src.Aircraft()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := repo.MergeCurated(aircraft); err != nil {
+		return 0, fmt.Errorf("failed to store BaseStation aircraft: %w", err)
+	}
+
+	return len(aircraft), nil
+}
@@ -0,0 +1,109 @@
+package importer
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockAircraftRepository captures curated aircraft written by the BaseStation importer
+type mockAircraftRepository struct {
+	curated []*models.Aircraft
+}
+
+func (m *mockAircraftRepository) InsertBatch(aircraft []*models.Aircraft) error { return nil }
+
+func (m *mockAircraftRepository) MergeCurated(aircraft []*models.Aircraft) error {
+	m.curated = append(m.curated, aircraft...)
+	return nil
+}
+
+func (m *mockAircraftRepository) Get(icao24 string) (*models.Aircraft, error) { return nil, nil }
+
+func (m *mockAircraftRepository) IsTablePopulated() (bool, error) { return false, nil }
+
+func (m *mockAircraftRepository) LoadFromMultipleCSV(csvPaths []string, mapping database.ColumnMapping, batchSize int) error {
+	return nil
+}
+
+// createBaseStation builds a minimal BaseStation.sqb with the tables the importers read
+func createBaseStation(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "BaseStation.sqb")
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	stmts := []string{
+		`CREATE TABLE Aircraft (AircraftID INTEGER PRIMARY KEY, ModeS VARCHAR(6), Registration VARCHAR(20),
+			ICAOTypeCode VARCHAR(10), Manufacturer VARCHAR(60), Type VARCHAR(40), SerialNo VARCHAR(30),
+			RegisteredOwners VARCHAR(100), OperatorFlagCode VARCHAR(20), YearBuilt VARCHAR(4), ModeSCountry VARCHAR(24))`,
+		`CREATE TABLE Flights (FlightID INTEGER PRIMARY KEY, AircraftID INTEGER, StartTime DATETIME, EndTime DATETIME,
+			Callsign VARCHAR(20), NumModeSMsgRec INTEGER)`,
+		`INSERT INTO Aircraft (AircraftID, ModeS, Registration, ICAOTypeCode, Manufacturer, Type, RegisteredOwners, OperatorFlagCode)
+			VALUES (1, 'a1b2c3', 'N12345', 'B738', 'Boeing', '737-824', 'United Airlines', 'UAL'),
+			       (2, '4840D6', 'PH-BXA', '', '', '', '', '')`,
+		`INSERT INTO Flights (AircraftID, StartTime, EndTime, Callsign, NumModeSMsgRec) VALUES
+			(1, '2023-03-01 10:00:00.1230000', '2023-03-01 10:30:00', 'UAL1', 100),
+			(1, '2023-03-02 08:00:00', '2023-03-02 08:05:00', 'UAL2', 20),
+			(2, '2023-03-01 11:00:00', NULL, '', 5)`,
+	}
+	for _, stmt := range stmts {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err)
+	}
+	return path
+}
+
+func TestImportBaseStationFlights(t *testing.T) {
+	path := createBaseStation(t)
+	repo := &mockSeenRepository{}
+
+	count, err := ImportBaseStationFlights(repo, path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	ual, _ := repo.Get("A1B2C3")
+	require.NotNil(t, ual)
+	assert.Equal(t, int64(120), ual.MessageCount)
+	assert.Equal(t, "UAL2", ual.Callsign)
+	assert.Equal(t, "basestation", ual.Source)
+	assert.Equal(t, "2023-03-01 10:00:00", ual.FirstSeen.Format("2006-01-02 15:04:05"))
+	assert.Equal(t, "2023-03-02 08:05:00", ual.LastSeen.Format("2006-01-02 15:04:05"))
+
+	klm, _ := repo.Get("4840D6")
+	require.NotNil(t, klm)
+	assert.True(t, klm.LastSeen.Equal(klm.FirstSeen), "missing end time falls back to the start time")
+}
+
+func TestImportBaseStationFlights_MissingFile(t *testing.T) {
+	repo := &mockSeenRepository{}
+	_, err := ImportBaseStationFlights(repo, filepath.Join(t.TempDir(), "missing.sqb"))
+	assert.Error(t, err)
+}
+
+func TestImportBaseStationAircraft(t *testing.T) {
+	path := createBaseStation(t)
+	repo := &mockAircraftRepository{}
+
+	count, err := ImportBaseStationAircraft(repo, path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	require.Len(t, repo.curated, 2)
+
+	ac := repo.curated[0]
+	assert.Equal(t, "a1b2c3", ac.ICAO24)
+	assert.Equal(t, "N12345", ac.Registration)
+	assert.Equal(t, "B738", ac.TypeCode)
+	assert.Equal(t, "Boeing", ac.ManufacturerName)
+	assert.Equal(t, "737-824", ac.Model)
+	assert.Equal(t, "United Airlines", ac.Owner)
+	assert.Equal(t, "UAL", ac.OperatorICAO)
+
+	assert.Equal(t, "4840d6", repo.curated[1].ICAO24)
+}
@@ -0,0 +1,144 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+)
+
+// readsbSnapshot is the subset of readsb/dump1090-fa history_N.json and aircraft.json we care about
+type readsbSnapshot struct {
+	Now      float64          `json:"now"`
+	Aircraft []readsbAircraft `json:"aircraft"`
+}
+
+type readsbAircraft struct {
+	Hex      string  `json:"hex"`
+	Flight   string  `json:"flight"`
+	Category string  `json:"category"`
+	Seen     float64 `json:"seen"`
+	Messages int64   `json:"messages"`
+}
+
+// ImportReadsbHistory merges readsb/dump1090 JSON history files into the seen_aircraft table
+// Each path may be a JSON file or a directory containing history_*.json / aircraft.json files.
+// Returns the number of distinct aircraft imported.
+func ImportReadsbHistory(repo database.SeenAircraftRepository, paths []string) (int, error) {
+	files, err := expandJSONPaths(paths)
+	if err != nil {
+		return 0, err
+	}
+	if len(files) == 0 {
+		return 0, fmt.Errorf("no JSON history files found in %v", paths)
+	}
+
+	byICAO := make(map[string]*models.Sighting)
+	for _, file := range files {
+		if err := mergeReadsbFile(file, byICAO); err != nil {
+			return 0, err
+		}
+	}
+
+	sightings := make([]*models.Sighting, 0, len(byICAO))
+	for _, s := range byICAO {
+		sightings = append(sightings, s)
+	}
+
+	if err := repo.UpsertBatch(sightings); err != nil {
+		return 0, fmt.Errorf("failed to store readsb sightings: %w", err)
+	}
+
+	return len(sightings), nil
+}
+
+// mergeReadsbFile folds a single snapshot file into the per-aircraft sightings
+func mergeReadsbFile(path string, byICAO map[string]*models.Sighting) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var snapshot readsbSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if snapshot.Now == 0 {
+		return fmt.Errorf("%s is missing the 'now' field, not a readsb history file", path)
+	}
+
+	now := time.Unix(0, int64(snapshot.Now*float64(time.Second)))
+	for _, ac := range snapshot.Aircraft {
+		// readsb prefixes non-ICAO addresses (TIS-B track files, anonymous) with '~'
+		if ac.Hex == "" || strings.HasPrefix(ac.Hex, "~") {
+			continue
+		}
+
+		icao := strings.ToUpper(ac.Hex)
+		seenAt := now.Add(-time.Duration(ac.Seen * float64(time.Second)))
+
+		s, ok := byICAO[icao]
+		if !ok {
+			s = &models.Sighting{
+				ICAO:      icao,
+				FirstSeen: seenAt,
+				LastSeen:  seenAt,
+				Source:    "readsb",
+			}
+			byICAO[icao] = s
+		}
+
+		if seenAt.Before(s.FirstSeen) {
+			s.FirstSeen = seenAt
+		}
+		if !seenAt.Before(s.LastSeen) {
+			s.LastSeen = seenAt
+			if callsign := strings.TrimSpace(ac.Flight); callsign != "" {
+				s.Callsign = callsign
+			}
+			if ac.Category != "" {
+				s.Category = ac.Category
+			}
+		}
+		// readsb message counters are cumulative while the aircraft stays in memory, so keep the largest
+		if ac.Messages > s.MessageCount {
+			s.MessageCount = ac.Messages
+		}
+	}
+
+	return nil
+}
+
+// expandJSONPaths resolves directories into the snapshot files they contain, in a stable order
+func expandJSONPaths(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", p, err)
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+
+		// Only snapshot files; receiver.json and stats.json live alongside them in the same directory
+		var matches []string
+		for _, pattern := range []string{"history_*.json", "aircraft.json"} {
+			m, err := filepath.Glob(filepath.Join(p, pattern))
+			if err != nil {
+				return nil, fmt.Errorf("failed to list %s: %w", p, err)
+			}
+			matches = append(matches, m...)
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	return files, nil
+}
@@ -0,0 +1,84 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockSeenRepository captures sightings written by the importers
+type mockSeenRepository struct {
+	sightings []*models.Sighting
+}
+
+func (m *mockSeenRepository) UpsertBatch(sightings []*models.Sighting) error {
+	m.sightings = append(m.sightings, sightings...)
+	return nil
+}
+
+func (m *mockSeenRepository) Get(icao string) (*models.Sighting, error) {
+	for _, s := range m.sightings {
+		if s.ICAO == icao {
+			return s, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockSeenRepository) Summary(since time.Time) (*database.SightingSummary, error) {
+	return nil, nil
+}
+
+func (m *mockSeenRepository) QueryHistory(filter database.SightingFilter, page database.PageRequest) ([]*models.Sighting, string, error) {
+	return m.sightings, "", nil
+}
+
+func TestImportReadsbHistory(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"history_0.json": `{"now": 1700000000.0, "messages": 100, "aircraft": [
+			{"hex": "a1b2c3", "flight": "UAL123  ", "category": "A3", "seen": 1.0, "messages": 40},
+			{"hex": "~123456", "seen": 0.5, "messages": 3}
+		]}`,
+		"history_1.json": `{"now": 1700000030.0, "messages": 140, "aircraft": [
+			{"hex": "a1b2c3", "flight": "", "seen": 0.0, "messages": 55},
+			{"hex": "abcdef", "flight": "DAL9", "seen": 2.0, "messages": 7}
+		]}`,
+		// Not a snapshot; must be ignored when importing a directory
+		"receiver.json": `{"version": "readsb", "refresh": 1000}`,
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	repo := &mockSeenRepository{}
+	count, err := ImportReadsbHistory(repo, []string{dir})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	ual, _ := repo.Get("A1B2C3")
+	require.NotNil(t, ual)
+	assert.Equal(t, int64(55), ual.MessageCount)
+	assert.Equal(t, "UAL123", ual.Callsign, "empty callsign in a later snapshot keeps the known one")
+	assert.Equal(t, "A3", ual.Category)
+	assert.Equal(t, "readsb", ual.Source)
+	assert.True(t, ual.FirstSeen.Equal(time.Unix(1699999999, 0)))
+	assert.True(t, ual.LastSeen.Equal(time.Unix(1700000030, 0)))
+
+	tisb, _ := repo.Get("~123456")
+	assert.Nil(t, tisb, "non-ICAO addresses are skipped")
+}
+
+func TestImportReadsbHistory_NoFiles(t *testing.T) {
+	repo := &mockSeenRepository{}
+	_, err := ImportReadsbHistory(repo, []string{t.TempDir()})
+	assert.Error(t, err)
+}
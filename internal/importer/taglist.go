@@ -0,0 +1,134 @@
+package importer
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+)
+
+// ImportTagList downloads (or reads) a special aircraft list and replaces the stored tags for it
+// location is an http(s) URL or a local file path. Returns the number of aircraft tagged.
+func ImportTagList(ctx context.Context, repo database.TagRepository, name, location string) (int, error) {
+	r, err := openTagList(ctx, location)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	tags, err := ParsePlaneAlertCSV(r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse tag list %s: %w", name, err)
+	}
+	if len(tags) == 0 {
+		// An empty download is far more likely a broken mirror than an empty list, keep the previous import
+		return 0, fmt.Errorf("tag list %s has no aircraft", name)
+	}
+
+	if err := repo.ReplaceSource(name, tags); err != nil {
+		return 0, err
+	}
+	return len(tags), nil
+}
+
+func openTagList(ctx context.Context, location string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		f, err := os.Open(location)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open tag list: %w", err)
+		}
+		return f, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download tag list: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download tag list: status %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// ParsePlaneAlertCSV parses a list in the plane-alert-db CSV format
+// Headers look like "$ICAO,$Registration,$Operator,$Type,$ICAO Type,#CMPG,$Tag 1,$#Tag 2,$#Tag 3,Category,$#Link";
+// the $ and # prefixes are display hints and are ignored, as are unknown columns. Only ICAO is required.
+func ParsePlaneAlertCSV(r io.Reader) ([]*models.AircraftTag, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimLeft(strings.TrimSpace(name), "$#"))] = i
+	}
+	if _, ok := columns["icao"]; !ok {
+		return nil, fmt.Errorf("missing ICAO column")
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var tags []*models.AircraftTag
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read record: %w", err)
+		}
+
+		icao := strings.ToUpper(field(record, "icao"))
+		if !isHexICAO(icao) {
+			continue
+		}
+		tag := &models.AircraftTag{
+			ICAO:         icao,
+			Registration: field(record, "registration"),
+			Operator:     field(record, "operator"),
+			Type:         field(record, "type"),
+			Group:        field(record, "cmpg"),
+			Category:     field(record, "category"),
+			Link:         field(record, "link"),
+		}
+		for _, name := range []string{"tag 1", "tag 2", "tag 3"} {
+			if v := field(record, name); v != "" {
+				tag.Tags = append(tag.Tags, v)
+			}
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// isHexICAO reports whether s is a 6 hex digit ICAO address; lists include placeholder rows without one
+func isHexICAO(s string) bool {
+	if len(s) != 6 {
+		return false
+	}
+	_, err := strconv.ParseUint(s, 16, 24)
+	return err == nil
+}
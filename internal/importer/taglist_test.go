@@ -0,0 +1,79 @@
+package importer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const planeAlertCSV = `$ICAO,$Registration,$Operator,$Type,$ICAO Type,#CMPG,$Tag 1,$#Tag 2,$#Tag 3,Category,$#Link
+AE1234,12-3456,United States Air Force,Boeing C-17A Globemaster III,C17,Mil,Moose,Heavy,,USAF,https://example.com/ae1234
+a0b1c2,N1234,Celebrity Jets LLC,Gulfstream G650,GLF6,Civ,Famous,,,Celebrity,
+,N0000,Placeholder,,,Civ,,,,Other,
+`
+
+type mockTagRepository struct {
+	replaced map[string][]*models.AircraftTag
+}
+
+func (m *mockTagRepository) ReplaceSource(source string, tags []*models.AircraftTag) error {
+	if m.replaced == nil {
+		m.replaced = make(map[string][]*models.AircraftTag)
+	}
+	m.replaced[source] = tags
+	return nil
+}
+
+func (m *mockTagRepository) Get(icao string) ([]*models.AircraftTag, error) { return nil, nil }
+
+func (m *mockTagRepository) Sources() ([]*database.TagSource, error) { return nil, nil }
+
+func (m *mockTagRepository) SourceICAOs(source string) ([]string, error) { return nil, nil }
+
+func TestParsePlaneAlertCSV(t *testing.T) {
+	tags, err := ParsePlaneAlertCSV(strings.NewReader(planeAlertCSV))
+	require.NoError(t, err)
+	require.Len(t, tags, 2, "rows without a valid ICAO address are skipped")
+
+	assert.Equal(t, "AE1234", tags[0].ICAO)
+	assert.Equal(t, "12-3456", tags[0].Registration)
+	assert.Equal(t, "United States Air Force", tags[0].Operator)
+	assert.Equal(t, "Mil", tags[0].Group)
+	assert.Equal(t, "USAF", tags[0].Category)
+	assert.Equal(t, []string{"Moose", "Heavy"}, tags[0].Tags)
+	assert.Equal(t, "https://example.com/ae1234", tags[0].Link)
+
+	assert.Equal(t, "A0B1C2", tags[1].ICAO)
+	assert.Equal(t, []string{"Famous"}, tags[1].Tags)
+}
+
+func TestParsePlaneAlertCSV_MissingICAO(t *testing.T) {
+	_, err := ParsePlaneAlertCSV(strings.NewReader("Registration,Operator\nN1,Someone\n"))
+	assert.Error(t, err)
+}
+
+func TestImportTagList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plane-alert-db.csv")
+	require.NoError(t, os.WriteFile(path, []byte(planeAlertCSV), 0o644))
+
+	repo := &mockTagRepository{}
+	count, err := ImportTagList(context.Background(), repo, "plane-alert", path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Len(t, repo.replaced["plane-alert"], 2)
+
+	// An empty list must not wipe the previous import
+	empty := filepath.Join(t.TempDir(), "empty.csv")
+	require.NoError(t, os.WriteFile(empty, []byte("$ICAO,$Registration\n"), 0o644))
+	_, err = ImportTagList(context.Background(), repo, "plane-alert", empty)
+	assert.Error(t, err)
+	assert.Len(t, repo.replaced["plane-alert"], 2)
+}
@@ -0,0 +1,176 @@
+// Package links builds deep links that open the live view of an aircraft on external trackers or this station's web UI
+package links
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+
+	"flight_trmnl/internal/models"
+)
+
+// Local is the name of the link to this station's own aircraft page
+const Local = "local"
+
+// Trackers are the built-in link templates, by name
+// {icao} is the lowercase address, {registration} and {callsign} are filled in when known;
+// a link whose placeholders can't all be filled in is left out.
+var Trackers = map[string]string{
+	"adsbexchange": "https://globe.adsbexchange.com/?icao={icao}",
+	"fr24":         "https://www.flightradar24.com/data/aircraft/{registration}",
+	"opensky":      "https://map.opensky-network.org/?icao={icao}",
+	"planefinder":  "https://planefinder.net/flight/{callsign}",
+}
+
+// Registry looks up registrations for templates that need one, such as the metadata resolver chain
+type Registry interface {
+	Resolve(ctx context.Context, icao string) (*models.Aircraft, string, error)
+}
+
+// Options configures a Generator
+type Options struct {
+	Trackers []string          // Built-in trackers to link to
+	Custom   map[string]string // Additional templates by name
+	BaseURL  string            // This station's web UI as reached from phones, adds the local link; empty for none
+	QR       string            // Link preferred for QR codes, empty for local or else the first tracker
+	Registry Registry          // Optional, fills in {registration}
+}
+
+// Aircraft is what link templates are filled in from
+type Aircraft struct {
+	ICAO         string
+	Registration string
+	Callsign     string
+}
+
+// Generator fills in the configured link templates
+type Generator struct {
+	names     []string // Link order: local, the trackers as configured, then custom links by name
+	templates map[string]string
+	qr        string
+	registry  Registry
+}
+
+// New creates a generator; unknown trackers and templates that aren't http(s) URLs are rejected
+func New(opts Options) (*Generator, error) {
+	g := &Generator{templates: make(map[string]string), qr: opts.QR, registry: opts.Registry}
+	add := func(name, template string) {
+		if _, ok := g.templates[name]; !ok {
+			g.names = append(g.names, name)
+		}
+		g.templates[name] = template
+	}
+
+	if opts.BaseURL != "" {
+		add(Local, strings.TrimSuffix(opts.BaseURL, "/")+"/aircraft.html?icao={icao}")
+	}
+	for _, name := range opts.Trackers {
+		template, ok := Trackers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown tracker %s", name)
+		}
+		add(name, template)
+	}
+	custom := make([]string, 0, len(opts.Custom))
+	for name := range opts.Custom {
+		custom = append(custom, name)
+	}
+	sort.Strings(custom)
+	for _, name := range custom {
+		template := opts.Custom[name]
+		if !strings.HasPrefix(template, "http://") && !strings.HasPrefix(template, "https://") {
+			return nil, fmt.Errorf("link %s must start with http:// or https://", name)
+		}
+		add(name, template)
+	}
+
+	if g.qr == "" && len(g.names) > 0 {
+		g.qr = g.names[0]
+	}
+	if _, ok := g.templates[g.qr]; g.qr != "" && !ok {
+		return nil, fmt.Errorf("QR link %s is not configured", g.qr)
+	}
+	return g, nil
+}
+
+// For returns the links to an aircraft by name, nil for pseudonyms of blocked aircraft and when none apply
+func (g *Generator) For(ac Aircraft) map[string]string {
+	if g == nil || ac.ICAO == "" || strings.HasPrefix(ac.ICAO, "~") {
+		return nil
+	}
+	var links map[string]string
+	for _, name := range g.names {
+		if link, ok := fill(g.templates[name], ac); ok {
+			if links == nil {
+				links = make(map[string]string)
+			}
+			links[name] = link
+		}
+	}
+	return links
+}
+
+// QR returns the link to encode in a QR code for an aircraft, falling back to any other link; empty when none apply
+func (g *Generator) QR(ac Aircraft) string {
+	if g == nil {
+		return ""
+	}
+	links := g.For(ac)
+	if link, ok := links[g.qr]; ok {
+		return link
+	}
+	for _, name := range g.names {
+		if link, ok := links[name]; ok {
+			return link
+		}
+	}
+	return ""
+}
+
+// Lookup returns the links to an aircraft, resolving its registration when a template needs one
+func (g *Generator) Lookup(ctx context.Context, ac Aircraft) map[string]string {
+	if g == nil {
+		return nil
+	}
+	if ac.Registration == "" && g.registry != nil && g.needsRegistration() && !strings.HasPrefix(ac.ICAO, "~") {
+		resolved, _, err := g.registry.Resolve(ctx, ac.ICAO)
+		if err != nil {
+			slog.Debug("Registration lookup failed", "icao", ac.ICAO, "error", err)
+		} else if resolved != nil {
+			ac.Registration = resolved.Registration
+		}
+	}
+	return g.For(ac)
+}
+
+func (g *Generator) needsRegistration() bool {
+	for _, template := range g.templates {
+		if strings.Contains(template, "{registration}") {
+			return true
+		}
+	}
+	return false
+}
+
+// fill replaces a template's placeholders, false when one of them is unknown
+func fill(template string, ac Aircraft) (string, bool) {
+	values := map[string]string{
+		"{icao}":         strings.ToLower(ac.ICAO),
+		"{registration}": ac.Registration,
+		"{callsign}":     strings.TrimSpace(ac.Callsign),
+	}
+	var pairs []string
+	for placeholder, value := range values {
+		if !strings.Contains(template, placeholder) {
+			continue
+		}
+		if value == "" {
+			return "", false
+		}
+		pairs = append(pairs, placeholder, url.PathEscape(value))
+	}
+	return strings.NewReplacer(pairs...).Replace(template), true
+}
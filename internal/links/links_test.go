@@ -0,0 +1,76 @@
+package links
+
+import (
+	"context"
+	"testing"
+
+	"flight_trmnl/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockRegistry knows one registration and counts lookups
+type mockRegistry struct{ lookups int }
+
+func (m *mockRegistry) Resolve(ctx context.Context, icao string) (*models.Aircraft, string, error) {
+	m.lookups++
+	if icao == "4840D6" {
+		return &models.Aircraft{ICAO24: icao, Registration: "PH-BXA"}, "database", nil
+	}
+	return nil, "", nil
+}
+
+func TestGenerator_For(t *testing.T) {
+	g, err := New(Options{
+		Trackers: []string{"adsbexchange", "planefinder"},
+		Custom:   map[string]string{"radarbox": "https://www.radarbox.com/data/mode-s/{icao}"},
+		BaseURL:  "http://pi.local:8080/",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"local":        "http://pi.local:8080/aircraft.html?icao=4840d6",
+		"adsbexchange": "https://globe.adsbexchange.com/?icao=4840d6",
+		"planefinder":  "https://planefinder.net/flight/KLM1023",
+		"radarbox":     "https://www.radarbox.com/data/mode-s/4840d6",
+	}, g.For(Aircraft{ICAO: "4840D6", Callsign: "KLM1023 "}))
+
+	assert.NotContains(t, g.For(Aircraft{ICAO: "4840D6"}), "planefinder", "links missing a placeholder are left out")
+	assert.Nil(t, g.For(Aircraft{ICAO: "~A1B2C3"}))
+	assert.Equal(t, "http://pi.local:8080/aircraft.html?icao=4840d6", g.QR(Aircraft{ICAO: "4840D6"}))
+}
+
+func TestGenerator_QR(t *testing.T) {
+	g, err := New(Options{Trackers: []string{"fr24", "adsbexchange"}, QR: "fr24"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://www.flightradar24.com/data/aircraft/PH-BXA", g.QR(Aircraft{ICAO: "4840D6", Registration: "PH-BXA"}))
+	assert.Equal(t, "https://globe.adsbexchange.com/?icao=4840d6", g.QR(Aircraft{ICAO: "4840D6"}), "falls back to a link that applies")
+
+	var none *Generator
+	assert.Empty(t, none.QR(Aircraft{ICAO: "4840D6"}))
+}
+
+func TestGenerator_Lookup(t *testing.T) {
+	registry := &mockRegistry{}
+	g, err := New(Options{Trackers: []string{"fr24"}, Registry: registry})
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://www.flightradar24.com/data/aircraft/PH-BXA", g.Lookup(context.Background(), Aircraft{ICAO: "4840D6"})["fr24"])
+	assert.Nil(t, g.Lookup(context.Background(), Aircraft{ICAO: "ABCDEF"}))
+	assert.Equal(t, 2, registry.lookups)
+
+	icaoOnly, err := New(Options{Trackers: []string{"adsbexchange"}, Registry: registry})
+	require.NoError(t, err)
+	icaoOnly.Lookup(context.Background(), Aircraft{ICAO: "4840D6"})
+	assert.Equal(t, 2, registry.lookups, "registrations are only looked up when a link needs one")
+}
+
+func TestNew_Invalid(t *testing.T) {
+	_, err := New(Options{Trackers: []string{"nope"}})
+	assert.Error(t, err)
+	_, err = New(Options{Custom: map[string]string{"x": "ftp://example.com/{icao}"}})
+	assert.Error(t, err)
+	_, err = New(Options{Trackers: []string{"adsbexchange"}, QR: "local"})
+	assert.Error(t, err, "local needs a base URL")
+}
@@ -0,0 +1,175 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"flight_trmnl/internal/models"
+)
+
+// Resolver looks up aircraft metadata by ICAO address
+// Resolve returns nil, nil when the resolver has no record for the address.
+type Resolver interface {
+	Name() string
+	Resolve(ctx context.Context, icao string) (*models.Aircraft, error)
+}
+
+// ResolverStats counts lookups handled by one resolver in the chain
+type ResolverStats struct {
+	Name      string  `json:"name"`
+	Lookups   int64   `json:"lookups"`    // Lookups that reached this resolver
+	Hits      int64   `json:"hits"`       // Lookups answered with a record
+	Misses    int64   `json:"misses"`     // Lookups with no record
+	Errors    int64   `json:"errors"`     // Lookups that failed
+	CacheHits int64   `json:"cache_hits"` // Lookups answered from the cache (hit or miss)
+	HitRate   float64 `json:"hit_rate"`   // Hits / Lookups
+}
+
+// maxCacheEntries triggers a sweep of expired entries so the daemon's caches don't grow without bound
+const maxCacheEntries = 10000
+
+// cacheEntry holds a cached result; a nil aircraft caches a miss so unknown hexes don't hammer slow resolvers
+type cacheEntry struct {
+	aircraft *models.Aircraft
+	expires  time.Time
+}
+
+// cachedResolver wraps a resolver with a TTL cache and hit-rate counters
+type cachedResolver struct {
+	resolver Resolver
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+	stats ResolverStats
+}
+
+func (c *cachedResolver) resolve(ctx context.Context, icao string) (*models.Aircraft, error) {
+	c.mu.Lock()
+	c.stats.Lookups++
+	if entry, ok := c.cache[icao]; ok && time.Now().Before(entry.expires) {
+		c.stats.CacheHits++
+		c.countResult(entry.aircraft)
+		c.mu.Unlock()
+		return entry.aircraft, nil
+	}
+	c.mu.Unlock()
+
+	// Resolve without holding the lock, remote resolvers can take seconds
+	ac, err := c.resolver.Resolve(ctx, icao)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.stats.Errors++
+		return nil, err
+	}
+	c.countResult(ac)
+	if c.ttl > 0 {
+		if len(c.cache) >= maxCacheEntries {
+			c.purgeExpired()
+		}
+		c.cache[icao] = cacheEntry{aircraft: ac, expires: time.Now().Add(c.ttl)}
+	}
+	return ac, nil
+}
+
+// countResult records a hit or miss; must be called with mu held
+func (c *cachedResolver) countResult(ac *models.Aircraft) {
+	if ac != nil {
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+	}
+}
+
+// purgeExpired drops expired cache entries; must be called with mu held
+func (c *cachedResolver) purgeExpired() {
+	now := time.Now()
+	for icao, entry := range c.cache {
+		if now.After(entry.expires) {
+			delete(c.cache, icao)
+		}
+	}
+}
+
+// Chain resolves metadata by asking each resolver in order until one has a record
+type Chain struct {
+	resolvers []*cachedResolver
+}
+
+// NewChain creates a resolver chain; results of every resolver are cached for cacheTTL (0 disables caching)
+func NewChain(cacheTTL time.Duration, resolvers ...Resolver) *Chain {
+	chain := &Chain{}
+	for _, r := range resolvers {
+		chain.resolvers = append(chain.resolvers, &cachedResolver{
+			resolver: r,
+			ttl:      cacheTTL,
+			cache:    make(map[string]cacheEntry),
+			stats:    ResolverStats{Name: r.Name()},
+		})
+	}
+	return chain
+}
+
+// Resolve returns the first record found for the ICAO address and the name of the resolver that found it
+// A failing resolver is logged and skipped so a flaky remote API doesn't hide local data further down the chain.
+// Returns nil and an empty name when no resolver knows the address.
+func (c *Chain) Resolve(ctx context.Context, icao string) (*models.Aircraft, string, error) {
+	ac, name, _, err := c.resolve(ctx, icao)
+	return ac, name, err
+}
+
+// ResolveStrict is Resolve, but returns the resolver failures when no resolver found a record
+// Lets callers that remember misses (e.g. the enrichment backfill) tell an unknown aircraft from an outage.
+func (c *Chain) ResolveStrict(ctx context.Context, icao string) (*models.Aircraft, string, error) {
+	ac, name, failures, err := c.resolve(ctx, icao)
+	if err == nil && ac == nil && failures != nil {
+		err = failures
+	}
+	return ac, name, err
+}
+
+func (c *Chain) resolve(ctx context.Context, icao string) (*models.Aircraft, string, error, error) {
+	icao = strings.ToUpper(strings.TrimSpace(icao))
+
+	var failures error
+	for _, r := range c.resolvers {
+		if err := ctx.Err(); err != nil {
+			return nil, "", failures, err
+		}
+
+		ac, err := r.resolve(ctx, icao)
+		if err != nil {
+			slog.Warn("Metadata resolver failed", "resolver", r.resolver.Name(), "icao", icao, "error", err)
+			failures = errors.Join(failures, fmt.Errorf("%s: %w", r.resolver.Name(), err))
+			continue
+		}
+		if ac != nil {
+			return ac, r.resolver.Name(), failures, nil
+		}
+	}
+
+	return nil, "", failures, nil
+}
+
+// Stats returns per-resolver lookup counters in chain order
+func (c *Chain) Stats() []ResolverStats {
+	stats := make([]ResolverStats, 0, len(c.resolvers))
+	for _, r := range c.resolvers {
+		r.mu.Lock()
+		s := r.stats
+		r.mu.Unlock()
+
+		if s.Lookups > 0 {
+			s.HitRate = float64(s.Hits) / float64(s.Lookups)
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
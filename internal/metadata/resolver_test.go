@@ -0,0 +1,171 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockResolver answers from a fixed map and counts calls
+type mockResolver struct {
+	name     string
+	aircraft map[string]*models.Aircraft
+	err      error
+	calls    int
+}
+
+func (m *mockResolver) Name() string { return m.name }
+
+func (m *mockResolver) Resolve(ctx context.Context, icao string) (*models.Aircraft, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.aircraft[icao], nil
+}
+
+func TestChain_ResolvesInOrder(t *testing.T) {
+	local := &mockResolver{name: "local", aircraft: map[string]*models.Aircraft{
+		"A1B2C3": {ICAO24: "a1b2c3", Registration: "N12345"},
+	}}
+	remote := &mockResolver{name: "remote", aircraft: map[string]*models.Aircraft{
+		"A1B2C3": {ICAO24: "a1b2c3", Registration: "WRONG"},
+		"ABCDEF": {ICAO24: "abcdef", Registration: "N54321"},
+	}}
+
+	chain := NewChain(time.Minute, local, remote)
+
+	ac, source, err := chain.Resolve(context.Background(), "a1b2c3")
+	require.NoError(t, err)
+	assert.Equal(t, "local", source)
+	assert.Equal(t, "N12345", ac.Registration)
+	assert.Equal(t, 0, remote.calls, "later resolvers are not consulted after a hit")
+
+	ac, source, err = chain.Resolve(context.Background(), "ABCDEF")
+	require.NoError(t, err)
+	assert.Equal(t, "remote", source)
+	assert.Equal(t, "N54321", ac.Registration)
+
+	ac, source, err = chain.Resolve(context.Background(), "000001")
+	require.NoError(t, err)
+	assert.Nil(t, ac)
+	assert.Empty(t, source)
+}
+
+func TestChain_CachesHitsAndMisses(t *testing.T) {
+	remote := &mockResolver{name: "remote", aircraft: map[string]*models.Aircraft{
+		"ABCDEF": {ICAO24: "abcdef"},
+	}}
+	chain := NewChain(time.Minute, remote)
+
+	for i := 0; i < 3; i++ {
+		_, _, err := chain.Resolve(context.Background(), "ABCDEF")
+		require.NoError(t, err)
+		_, _, err = chain.Resolve(context.Background(), "000001")
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 2, remote.calls, "one upstream call per address, misses included")
+
+	stats := chain.Stats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, "remote", stats[0].Name)
+	assert.Equal(t, int64(6), stats[0].Lookups)
+	assert.Equal(t, int64(3), stats[0].Hits)
+	assert.Equal(t, int64(3), stats[0].Misses)
+	assert.Equal(t, int64(4), stats[0].CacheHits)
+	assert.InDelta(t, 0.5, stats[0].HitRate, 0.001)
+}
+
+func TestChain_SkipsFailingResolver(t *testing.T) {
+	broken := &mockResolver{name: "broken", err: assert.AnError}
+	chain := NewChain(time.Minute, broken, NewCountryResolver())
+
+	ac, source, err := chain.Resolve(context.Background(), "A00001")
+	require.NoError(t, err)
+	assert.Equal(t, ResolverCountry, source)
+	assert.Equal(t, "United States", ac.Country)
+	assert.Equal(t, int64(1), chain.Stats()[0].Errors)
+}
+
+func TestChain_ResolveStrict(t *testing.T) {
+	failing := &mockResolver{name: "remote", err: errors.New("service unavailable")}
+	local := &mockResolver{name: "local", aircraft: map[string]*models.Aircraft{
+		"A1B2C3": {ICAO24: "a1b2c3", Registration: "N12345"},
+	}}
+	chain := NewChain(0, failing, local)
+
+	ac, source, err := chain.ResolveStrict(context.Background(), "A1B2C3")
+	require.NoError(t, err, "a hit outweighs earlier failures")
+	assert.Equal(t, "local", source)
+	assert.Equal(t, "N12345", ac.Registration)
+
+	ac, _, err = chain.ResolveStrict(context.Background(), "000001")
+	assert.ErrorContains(t, err, "remote: service unavailable")
+	assert.Nil(t, ac)
+
+	ac, _, err = NewChain(0, local).ResolveStrict(context.Background(), "000001")
+	require.NoError(t, err)
+	assert.Nil(t, ac)
+}
+
+func TestCountryResolver(t *testing.T) {
+	r := NewCountryResolver()
+
+	tests := map[string]string{
+		"A00001": "United States",
+		"4840D6": "Netherlands",
+		"3C6444": "Germany",
+		"7C0000": "Australia",
+		"000001": "", // unallocated
+		"ZZZZZZ": "", // not hex
+	}
+	for icao, country := range tests {
+		ac, err := r.Resolve(context.Background(), icao)
+		require.NoError(t, err)
+		if country == "" {
+			assert.Nil(t, ac, icao)
+			continue
+		}
+		require.NotNil(t, ac, icao)
+		assert.Equal(t, country, ac.Country, icao)
+	}
+}
+
+func TestOpenSkyResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/a1b2c3" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"icao24":"a1b2c3","registration":"N12345","manufacturerName":"Boeing","model":"737-824","typecode":"B738","operatorIcao":"UAL"}`))
+	}))
+	defer server.Close()
+
+	r := NewOpenSkyResolver(server.URL + "/")
+
+	ac, err := r.Resolve(context.Background(), "A1B2C3")
+	require.NoError(t, err)
+	require.NotNil(t, ac)
+	assert.Equal(t, "a1b2c3", ac.ICAO24)
+	assert.Equal(t, "N12345", ac.Registration)
+	assert.Equal(t, "B738", ac.TypeCode)
+	assert.Equal(t, "UAL", ac.OperatorICAO)
+
+	ac, err = r.Resolve(context.Background(), "000001")
+	require.NoError(t, err)
+	assert.Nil(t, ac)
+}
+
+func TestNew_UnknownResolver(t *testing.T) {
+	_, _, err := New([]string{"country", "bogus"}, Options{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,209 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+)
+
+// Resolver names used in configuration
+const (
+	ResolverDatabase    = "database"
+	ResolverBaseStation = "basestation"
+	ResolverOpenSky     = "opensky"
+	ResolverCountry     = "country"
+)
+
+// DefaultOpenSkyURL is the OpenSky Network aircraft metadata endpoint; the ICAO address is appended
+const DefaultOpenSkyURL = "https://opensky-network.org/api/metadata/aircraft/icao/"
+
+// DatabaseResolver resolves metadata from the local aircraft table
+type DatabaseResolver struct {
+	repo database.AircraftRepository
+}
+
+func NewDatabaseResolver(repo database.AircraftRepository) *DatabaseResolver {
+	return &DatabaseResolver{repo: repo}
+}
+
+func (r *DatabaseResolver) Name() string { return ResolverDatabase }
+
+func (r *DatabaseResolver) Resolve(ctx context.Context, icao string) (*models.Aircraft, error) {
+	return r.repo.Get(icao)
+}
+
+// BaseStationResolver resolves metadata from a BaseStation.sqb without importing it
+type BaseStationResolver struct {
+	db *database.BaseStation
+}
+
+func NewBaseStationResolver(db *database.BaseStation) *BaseStationResolver {
+	return &BaseStationResolver{db: db}
+}
+
+func (r *BaseStationResolver) Name() string { return ResolverBaseStation }
+
+func (r *BaseStationResolver) Resolve(ctx context.Context, icao string) (*models.Aircraft, error) {
+	return r.db.LookupAircraft(icao)
+}
+
+// OpenSkyResolver resolves metadata from the OpenSky Network REST API
+type OpenSkyResolver struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewOpenSkyResolver(baseURL string) *OpenSkyResolver {
+	if baseURL == "" {
+		baseURL = DefaultOpenSkyURL
+	}
+	return &OpenSkyResolver{
+		baseURL: baseURL,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (r *OpenSkyResolver) Name() string { return ResolverOpenSky }
+
+// openSkyAircraft is the subset of the OpenSky metadata response mapped onto the aircraft model
+type openSkyAircraft struct {
+	ICAO24              string `json:"icao24"`
+	Registration        string `json:"registration"`
+	ManufacturerICAO    string `json:"manufacturerIcao"`
+	ManufacturerName    string `json:"manufacturerName"`
+	Model               string `json:"model"`
+	TypeCode            string `json:"typecode"`
+	SerialNumber        string `json:"serialNumber"`
+	LineNumber          string `json:"lineNumber"`
+	ICAOAircraftClass   string `json:"icaoAircraftClass"`
+	SelCal              string `json:"selCal"`
+	Operator            string `json:"operator"`
+	OperatorCallsign    string `json:"operatorCallsign"`
+	OperatorICAO        string `json:"operatorIcao"`
+	OperatorIATA        string `json:"operatorIata"`
+	Owner               string `json:"owner"`
+	CategoryDescription string `json:"categoryDescription"`
+	Country             string `json:"country"`
+	Engines             string `json:"engines"`
+	Notes               string `json:"notes"`
+}
+
+func (r *OpenSkyResolver) Resolve(ctx context.Context, icao string) (*models.Aircraft, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.baseURL+strings.ToLower(icao), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OpenSky request: %w", err)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("OpenSky request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OpenSky returned status %d", resp.StatusCode)
+	}
+
+	var body openSkyAircraft
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode OpenSky response: %w", err)
+	}
+
+	return &models.Aircraft{
+		ICAO24:              strings.ToLower(icao),
+		Registration:        body.Registration,
+		ManufacturerICAO:    body.ManufacturerICAO,
+		ManufacturerName:    body.ManufacturerName,
+		Model:               body.Model,
+		TypeCode:            body.TypeCode,
+		SerialNumber:        body.SerialNumber,
+		LineNumber:          body.LineNumber,
+		ICAOAircraftClass:   body.ICAOAircraftClass,
+		SelCal:              body.SelCal,
+		Operator:            body.Operator,
+		OperatorCallsign:    body.OperatorCallsign,
+		OperatorICAO:        body.OperatorICAO,
+		OperatorIATA:        body.OperatorIATA,
+		Owner:               body.Owner,
+		CategoryDescription: body.CategoryDescription,
+		Country:             body.Country,
+		Engines:             body.Engines,
+		Notes:               body.Notes,
+	}, nil
+}
+
+// CountryResolver derives the state of registry from the ICAO address allocation blocks
+// It always answers for allocated addresses, so it belongs at the end of a chain.
+type CountryResolver struct{}
+
+func NewCountryResolver() *CountryResolver {
+	return &CountryResolver{}
+}
+
+func (r *CountryResolver) Name() string { return ResolverCountry }
+
+func (r *CountryResolver) Resolve(ctx context.Context, icao string) (*models.Aircraft, error) {
+	country := models.CountryForICAO(icao)
+	if country == "" {
+		return nil, nil
+	}
+	return &models.Aircraft{ICAO24: strings.ToLower(icao), Country: country}, nil
+}
+
+// Options holds the settings needed to build resolvers by name
+type Options struct {
+	AircraftRepo    database.AircraftRepository
+	BaseStationPath string
+	OpenSkyURL      string
+	CacheTTL        time.Duration
+}
+
+// New builds a resolver chain from resolver names in lookup order
+// The returned close function releases resources held by resolvers (e.g. the BaseStation database).
+func New(names []string, opts Options) (*Chain, func() error, error) {
+	var resolvers []Resolver
+	var closers []func() error
+
+	closeAll := func() error {
+		var firstErr error
+		for _, c := range closers {
+			if err := c(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}
+
+	for _, name := range names {
+		switch name {
+		case ResolverDatabase:
+			resolvers = append(resolvers, NewDatabaseResolver(opts.AircraftRepo))
+		case ResolverBaseStation:
+			bs, err := database.OpenBaseStation(opts.BaseStationPath)
+			if err != nil {
+				closeAll()
+				return nil, nil, err
+			}
+			closers = append(closers, bs.Close)
+			resolvers = append(resolvers, NewBaseStationResolver(bs))
+		case ResolverOpenSky:
+			resolvers = append(resolvers, NewOpenSkyResolver(opts.OpenSkyURL))
+		case ResolverCountry:
+			resolvers = append(resolvers, NewCountryResolver())
+		default:
+			closeAll()
+			return nil, nil, fmt.Errorf("unknown metadata resolver: %s", name)
+		}
+	}
+
+	return NewChain(opts.CacheTTL, resolvers...), closeAll, nil
+}
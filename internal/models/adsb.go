@@ -0,0 +1,82 @@
+package models
+
+import (
+	"flight_trmnl/internal/decoder"
+	"flight_trmnl/pkg/schema"
+)
+
+// ResolutionAdvisory is a TCAS resolution advisory decoded from an aircraft status message
+type ResolutionAdvisory = schema.Advisory
+
+// Velocity returns the ground speed and track, or airspeed and heading, and the vertical rate of an extended
+// squitter airborne velocity message, TC19
+func (b *BeastMessage) Velocity() (*decoder.Velocity, bool) {
+	m, err := decoder.Decode(b.Message)
+	if err != nil || m.Velocity == nil {
+		return nil, false
+	}
+	return m.Velocity, true
+}
+
+// Speed returns the speed in knots of an extended squitter airborne velocity message: the ground speed,
+// or the airspeed when the aircraft reports only that
+func (b *BeastMessage) Speed() (int, bool) {
+	v, ok := b.Velocity()
+	if !ok || v.Speed == nil {
+		return 0, false
+	}
+	return *v.Speed, true
+}
+
+// Identification returns the emitter category (e.g. A3, empty when not set) and callsign of an extended squitter
+// identification message
+func (b *BeastMessage) Identification() (category, callsign string, ok bool) {
+	m, err := decoder.Decode(b.Message)
+	if err != nil || m.Identification == nil {
+		return "", "", false
+	}
+	return m.Identification.Category, m.Identification.Callsign, true
+}
+
+// Status returns the emergency state (e.g. general or minimum_fuel, empty when none) and Mode A code of an
+// extended squitter emergency/priority status message, TC28 subtype 1
+func (b *BeastMessage) Status() (emergency, squawk string, ok bool) {
+	m, err := decoder.Decode(b.Message)
+	if err != nil || m.Status == nil {
+		return "", "", false
+	}
+	return m.Status.Emergency, m.Status.Squawk, true
+}
+
+// ResolutionAdvisory returns the advisory of an extended squitter TCAS RA broadcast, TC28 subtype 2. Its Time
+// isn't set.
+func (b *BeastMessage) ResolutionAdvisory() (*ResolutionAdvisory, bool) {
+	m, err := decoder.Decode(b.Message)
+	if err != nil || m.Advisory == nil {
+		return nil, false
+	}
+	return m.Advisory, true
+}
+
+// CategoryName describes an emitter category, e.g. "Heavy" for A5; empty when unknown
+func CategoryName(category string) string {
+	return emitterCategoryNames[category]
+}
+
+// extendedSquitterType returns the ADS-B type code of a DF17 or DF18 frame laid out like one
+func extendedSquitterType(msg []byte) (uint64, bool) {
+	if !decoder.IsExtendedSquitter(msg) {
+		return 0, false
+	}
+	return frameBits(msg, 33, 37), true
+}
+
+// frameBits returns bits first to last of a Mode S frame, numbered from 1 like the Mode S specification
+func frameBits(msg []byte, first, last int) uint64 {
+	var v uint64
+	for i := first; i <= last; i++ {
+		bit := (msg[(i-1)/8] >> (7 - uint((i-1)%8))) & 1
+		v = v<<1 | uint64(bit)
+	}
+	return v
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBeastMessage_Speed(t *testing.T) {
+	knots, ok := frame(t, "8D485020994409940838175B284F").Speed()
+	require.True(t, ok)
+	assert.Equal(t, 159, knots, "ground speed")
+
+	knots, ok = frame(t, "8DA05F219B06B6AF189400CBC33F").Speed()
+	require.True(t, ok)
+	assert.Equal(t, 375, knots, "true airspeed")
+
+	_, ok = frame(t, "8D40621D58C382D690C8AC2863A7").Speed()
+	assert.False(t, ok, "airborne position")
+}
+
+func TestBeastMessage_Velocity(t *testing.T) {
+	v, ok := frame(t, "8D485020994409940838175B284F").Velocity()
+	require.True(t, ok)
+	require.NotNil(t, v.Track)
+	assert.InDelta(t, 182.88, *v.Track, 0.01)
+	assert.Nil(t, v.Heading, "ground speed comes with a track")
+	require.NotNil(t, v.VerticalRate)
+	assert.Equal(t, -832, *v.VerticalRate)
+
+	v, ok = frame(t, "8DA05F219B06B6AF189400CBC33F").Velocity()
+	require.True(t, ok)
+	assert.Nil(t, v.Track)
+	require.NotNil(t, v.Heading)
+	assert.InDelta(t, 243.98, *v.Heading, 0.01)
+	require.NotNil(t, v.VerticalRate)
+	assert.Equal(t, -2304, *v.VerticalRate)
+
+	_, ok = frame(t, "8D40621D58C382D690C8AC2863A7").Velocity()
+	assert.False(t, ok, "airborne position")
+}
+
+func TestBeastMessage_Identification(t *testing.T) {
+	category, callsign, ok := frame(t, "8D4840D6202CC371C32CE0576098").Identification()
+	require.True(t, ok)
+	assert.Equal(t, "", category, "category set A without details")
+	assert.Equal(t, "KLM1023", callsign)
+
+	_, _, ok = frame(t, "8D485020994409940838175B284F").Identification()
+	assert.False(t, ok)
+}
+
+// squitter builds a DF17 frame from aircraft icao with the 56-bit ME field me
+func squitter(icao uint32, me uint64) string {
+	msg := make([]byte, 14)
+	msg[0], msg[1], msg[2], msg[3] = 17<<3|5, byte(icao>>16), byte(icao>>8), byte(icao)
+	for i := 0; i < 7; i++ {
+		msg[4+i] = byte(me >> (48 - 8*uint(i)))
+	}
+	crc := ModeSCRC(msg[:11])
+	msg[11], msg[12], msg[13] = byte(crc>>16), byte(crc>>8), byte(crc)
+	return hex.EncodeToString(msg)
+}
+
+// statusME builds the ME field of a TC28 message with the subtype and the 48 bits after it
+func statusME(subtype, rest uint64) uint64 {
+	return 28<<51 | subtype<<48 | rest
+}
+
+func TestBeastMessage_Status(t *testing.T) {
+	// 7700 in ID13 order is A1 A2 A4 B1 B2 B4: bits 11, 9, 7, 5, 3, 1
+	id := uint64(1<<11 | 1<<9 | 1<<7 | 1<<5 | 1<<3 | 1<<1)
+	emergency, squawk, ok := frame(t, squitter(0x4840D6, statusME(1, 1<<45|id<<32))).Status()
+	require.True(t, ok)
+	assert.Equal(t, "general", emergency)
+	assert.Equal(t, "7700", squawk)
+
+	emergency, squawk, ok = frame(t, squitter(0x4840D6, statusME(1, 0))).Status()
+	require.True(t, ok)
+	assert.Equal(t, "", emergency, "no emergency")
+	assert.Equal(t, "0000", squawk)
+
+	_, _, ok = frame(t, squitter(0x4840D6, statusME(2, 0))).Status()
+	assert.False(t, ok, "an RA broadcast")
+	_, _, ok = frame(t, "8D485020994409940838175B284F").Status()
+	assert.False(t, ok)
+}
+
+// raME builds the ME field of a TCAS RA broadcast; ARA is ME bits 9-22, RAC 23-26, RAT 27, MTE 28, TTI 29-30,
+// and the threat address 31-54
+func raME(ara, rac, rat, mte, tti, threat uint64) uint64 {
+	return statusME(2, ara<<34|rac<<30|rat<<29|mte<<28|tti<<26|threat<<2)
+}
+
+func TestBeastMessage_ResolutionAdvisory(t *testing.T) {
+	tests := []struct {
+		name    string
+		ara     uint64
+		rat     uint64
+		mte     uint64
+		summary string
+	}{
+		{"climb", 0b11000010000000, 0, 0, "Climb, corrective"},
+		{"descend crossing", 0b11100110000000, 0, 0, "Descend, corrective, crossing"},
+		{"limit climb", 0b10100000000000, 0, 0, "Limit climb, preventive"},
+		{"increase descent", 0b11110010000000, 0, 0, "Descend, corrective, increase rate"},
+		{"different senses", 0b01010000000000, 0, 1, "Limit descent, Limit climb"},
+		{"terminated", 0b11000010000000, 1, 0, "Climb, corrective, terminated"},
+		{"clear of conflict", 0, 1, 0, "Clear of conflict"},
+		{"none", 0, 0, 0, "No advisory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ra, ok := frame(t, squitter(0xA05F21, raME(tt.ara, 0, tt.rat, tt.mte, 0, 0))).ResolutionAdvisory()
+			require.True(t, ok)
+			assert.Equal(t, tt.summary, ra.Summary)
+			assert.Equal(t, int(tt.ara), ra.ARA)
+			assert.Equal(t, tt.rat == 1, ra.Terminated)
+			assert.Equal(t, tt.mte == 1, ra.MultipleThreats)
+			assert.Empty(t, ra.ThreatICAO)
+		})
+	}
+
+	ra, ok := frame(t, squitter(0xA05F21, raME(0b11000010000000, 0b1000, 0, 0, 1, 0x4840D6))).ResolutionAdvisory()
+	require.True(t, ok)
+	assert.Equal(t, "4840D6", ra.ThreatICAO)
+	assert.Equal(t, 0b1000, ra.RAC)
+
+	_, ok = frame(t, squitter(0xA05F21, statusME(1, 0))).ResolutionAdvisory()
+	assert.False(t, ok, "an emergency status")
+}
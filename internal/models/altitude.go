@@ -0,0 +1,90 @@
+package models
+
+import "flight_trmnl/internal/decoder"
+
+// Altitude bands, from the ground up, for telling airport traffic from enroute corridors
+const (
+	BandSurface = "surface" // On the ground
+	BandLow     = "low"     // Airborne below 10,000 ft
+	BandMid     = "mid"     // 10,000 ft up to 30,000 ft
+	BandHigh    = "high"    // 30,000 ft and above
+)
+
+// AltitudeBands lists the bands from the ground up
+var AltitudeBands = []string{BandSurface, BandLow, BandMid, BandHigh}
+
+// IsAltitudeBand reports whether name is one of AltitudeBands
+func IsAltitudeBand(name string) bool {
+	for _, band := range AltitudeBands {
+		if band == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AltitudeBand returns the band of an aircraft, empty when it's not known to be on the ground and reported no altitude
+func AltitudeBand(altitude *int, onGround bool) string {
+	switch {
+	case onGround:
+		return BandSurface
+	case altitude == nil:
+		return ""
+	case *altitude < 10000:
+		return BandLow
+	case *altitude < 30000:
+		return BandMid
+	default:
+		return BandHigh
+	}
+}
+
+// Altitude returns the altitude in feet reported by a message: the AC field of surveillance and ACAS replies,
+// or an extended squitter airborne position, barometric or else GNSS height converted from metres
+func (b *BeastMessage) Altitude() (int, bool) {
+	msg := b.Message
+	if len(msg) != BeastDataLenModeSShort && len(msg) != BeastDataLenModeSLong {
+		return 0, false
+	}
+	switch df := frameBits(msg, 1, 5); {
+	case df == 0 || df == 4 || df == 16 || df == 20:
+		return decoder.DecodeAC13(frameBits(msg, 20, 32))
+	case decoder.IsExtendedSquitter(msg):
+		if m, err := decoder.Decode(msg); err == nil && m.AirbornePosition != nil && m.AirbornePosition.Altitude != nil {
+			return *m.AirbornePosition.Altitude, true
+		}
+	}
+	return 0, false
+}
+
+// OnGround returns whether a DF11, DF17, or DF18 message says the aircraft is on the ground, from surface and
+// airborne positions or the transponder capability; known is false when the message doesn't say
+func (b *BeastMessage) OnGround() (onGround, known bool) {
+	msg := b.Message
+	if len(msg) != BeastDataLenModeSShort && len(msg) != BeastDataLenModeSLong {
+		return false, false
+	}
+	df := frameBits(msg, 1, 5)
+	if df != 11 && df != 17 && df != 18 {
+		return false, false
+	}
+	if decoder.IsExtendedSquitter(msg) {
+		switch tc := frameBits(msg, 33, 37); {
+		case tc >= 5 && tc <= 8:
+			return true, true
+		case tc >= 9 && tc <= 18, tc >= 20 && tc <= 22:
+			return false, true
+		}
+	}
+	if df == 18 {
+		// Its control field takes the place of the capability
+		return false, false
+	}
+	switch frameBits(msg, 6, 8) {
+	case 4:
+		return true, true
+	case 5:
+		return false, true
+	}
+	return false, false
+}
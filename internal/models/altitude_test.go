@@ -0,0 +1,69 @@
+package models
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func frame(t *testing.T, s string) *BeastMessage {
+	t.Helper()
+	msg, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return &BeastMessage{Message: msg}
+}
+
+func TestBeastMessage_Altitude(t *testing.T) {
+	feet, ok := frame(t, "8D40621D58C382D690C8AC2863A7").Altitude()
+	require.True(t, ok)
+	assert.Equal(t, 38000, feet, "airborne position")
+
+	feet, ok = frame(t, "8D40621DA0C382D690C8AC2863A7").Altitude()
+	require.True(t, ok)
+	assert.Equal(t, 10262, feet, "GNSS height of 3128 m")
+
+	_, ok = frame(t, "8D4840D6202CC371C32CE0576098").Altitude()
+	assert.False(t, ok, "identification has no altitude")
+
+	n := uint32(5000+1000) / 25
+	ac := (n&0x7E0)<<2 | (n&0x10)<<1 | 0x10 | n&0x0F
+	feet, ok = frame(t, surveillanceReply(4, 0, ac, 0x4840D6)).Altitude()
+	require.True(t, ok)
+	assert.Equal(t, 5000, feet, "altitude reply")
+
+	_, ok = (&BeastMessage{Message: []byte{0x01, 0x02}}).Altitude()
+	assert.False(t, ok, "Mode A/C")
+}
+
+func TestBeastMessage_OnGround(t *testing.T) {
+	tests := []struct {
+		frame           string
+		onGround, known bool
+		description     string
+	}{
+		{"8C4841753A9A153237AEF0F275BE", true, true, "surface position"},
+		{"8D40621D58C382D690C8AC2863A7", false, true, "airborne position"},
+		{"8D4840D6202CC371C32CE0576098", false, true, "identification from an airborne transponder"},
+		{"8C4840D6202CC371C32CE0576098", true, true, "identification from a transponder on the ground"},
+		{"5D4840D6000000", false, true, "all-call reply, airborne"},
+		{"5E4840D6000000", false, false, "all-call reply, either"},
+	}
+	for _, tt := range tests {
+		onGround, known := frame(t, tt.frame).OnGround()
+		assert.Equal(t, tt.known, known, tt.description)
+		assert.Equal(t, tt.onGround, onGround, tt.description)
+	}
+}
+
+func TestAltitudeBand(t *testing.T) {
+	alt := func(feet int) *int { return &feet }
+	assert.Equal(t, BandSurface, AltitudeBand(alt(38000), true), "on the ground whatever the last altitude")
+	assert.Equal(t, "", AltitudeBand(nil, false))
+	assert.Equal(t, BandLow, AltitudeBand(alt(9975), false))
+	assert.Equal(t, BandMid, AltitudeBand(alt(10000), false))
+	assert.Equal(t, BandHigh, AltitudeBand(alt(30000), false))
+	assert.True(t, IsAltitudeBand("mid"))
+	assert.False(t, IsAltitudeBand("cruise"))
+}
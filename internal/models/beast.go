@@ -4,7 +4,10 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"strconv"
 	"time"
+
+	"flight_trmnl/internal/decoder"
 )
 
 // BeastMessage represents a Mode S message in Beast format
@@ -15,6 +18,23 @@ type BeastMessage struct {
 	MessageTypeCode byte   // Beast message type: BeastTypeModeAC, BeastTypeModeSShort, or BeastTypeModeSLong
 	ICAO            string // Extracted ICAO address (first 3 bytes of message, for Mode S only)
 	MessageType     string // Type of message (position, identity, etc.)
+	Ticks           uint64 // Raw 48-bit receiver timestamp, 12 MHz ticks free-running per receiver or GPS time of day
+	ConnID          string // Connection or hub batch the message arrived on, e.g. beast-2 or hub-17, for logs
+	Seq             uint64 // Position of the message on its connection, from 1
+	Receiver        string // ID of the configured receiver that heard the message, empty when its input has none
+	// Position is the decoded position of an ADS-B position message, set by the tracker; nil when the message
+	// has none or there wasn't enough to decode it yet
+	Position *decoder.Position
+	// Integrity is how far the Position can be trusted, set with it
+	Integrity *decoder.Integrity
+}
+
+// Ref identifies the message in logs as conn:seq, e.g. beast-2:1041, so it can be followed across subsystems
+func (m *BeastMessage) Ref() string {
+	if m.ConnID == "" {
+		return ""
+	}
+	return m.ConnID + ":" + strconv.FormatUint(m.Seq, 10)
 }
 
 // ParseBeastMessage parses a Beast format message
@@ -48,26 +68,12 @@ func ParseBeastMessage(data []byte) (*BeastMessage, error) {
 		return nil, err
 	}
 
-	// FIXME This time stamp seems to be incorrect. Will need to go research the format it is provided in.
-	// Extract timestamp (BeastTimestampLen bytes, big-endian, 12 MHz clock ticks)
-	// The timestamp is a 48-bit value stored in 6 bytes as big-endian
-	// It represents time in 12 MHz clock ticks relative to the start of the sample block
-	timestampOffset := BeastHeaderLen
-	timestampBytes := data[timestampOffset : timestampOffset+BeastTimestampLen]
-
-	// Convert 48-bit big-endian to int64 by padding with zeros and using binary.BigEndian
-	// We create an 8-byte buffer with 2 leading zeros, then read as uint64
+	// Extract the timestamp (BeastTimestampLen bytes, big-endian), whose meaning depends on the receiver; the
+	// message is stamped with its arrival until a BeastClock makes a time of it
 	var timestampBuf [8]byte
-	copy(timestampBuf[2:], timestampBytes)
-	timestampTicks := int64(binary.BigEndian.Uint64(timestampBuf[:]))
-
-	// Convert from 12 MHz clock ticks to seconds
-	// Note: The timestamp is relative to the start of the sample block, not absolute Unix time
-	// We approximate by subtracting the relative time from the current time
-	// In practice, you might want to track the first timestamp and use it as a base
-	const ticksPerSecond = 12_000_000 // 12 MHz
-	timestampSeconds := float64(timestampTicks) / float64(ticksPerSecond)
-	timestamp := time.Now().Add(-time.Duration(timestampSeconds) * time.Second)
+	copy(timestampBuf[2:], data[BeastHeaderLen:BeastHeaderLen+BeastTimestampLen])
+	timestampTicks := binary.BigEndian.Uint64(timestampBuf[:])
+	timestamp := time.Now()
 
 	// Extract signal level (BeastSignalLen byte)
 	signalOffset := BeastHeaderLen + BeastTimestampLen
@@ -99,20 +105,59 @@ func ParseBeastMessage(data []byte) (*BeastMessage, error) {
 		MessageTypeCode: typeByte,
 		ICAO:            icao,
 		MessageType:     messageType,
+		Ticks:           timestampTicks,
 	}, nil
 }
 
+// NewBeastMessage builds a message from its parts, e.g. as forwarded by another receiver
+// The parts are assembled into a Beast frame and parsed, so the result is the same as for a locally received message.
+func NewBeastMessage(typeCode byte, ticks uint64, signal uint8, message []byte, t time.Time) (*BeastMessage, error) {
+	var ticksBuf [8]byte
+	binary.BigEndian.PutUint64(ticksBuf[:], ticks)
+	frame := make([]byte, 0, BeastHeaderLen+BeastTimestampLen+BeastSignalLen+len(message))
+	frame = append(frame, BeastStartByte, typeCode)
+	frame = append(frame, ticksBuf[8-BeastTimestampLen:]...)
+	frame = append(frame, signal)
+	frame = append(frame, message...)
+
+	msg, err := ParseBeastMessage(frame)
+	if err != nil {
+		return nil, err
+	}
+	msg.Timestamp = t
+	return msg, nil
+}
+
 // extractICAO extracts the ICAO address from a Mode S message
-// ICAO is 24 bits, typically in the first 8 bits of byte 0 and all of bytes 1-2
+// DF11 all-calls carry the 24-bit address field after the 5-bit DF and 3-bit CA fields, in bytes 1-3. A DF18
+// squitter's address depends on its control field and is prefixed with ~ when it isn't an ICAO address; management
+// messages have none. Surveillance, ACAS, Comm-B, and Comm-D replies overlay their parity with the address, so it's
+// recovered from the CRC residual, unchecked since a corrupted reply gives a wrong one. Other formats carry none.
 func extractICAO(message []byte) string {
-	if len(message) < 3 {
+	if len(message) < 4 {
 		return ""
 	}
-	// Mode S ICAO is in the first 3 bytes
-	// Format: [DF(5) + CA(3)] [ICAO(8)] [ICAO(8)]
-	// We extract the lower 24 bits which contain the ICAO
-	icao24 := (uint32(message[0])&0x07)<<16 | uint32(message[1])<<8 | uint32(message[2])
-	return fmt.Sprintf("%06X", icao24)
+	switch df := message[0] >> 3; {
+	case df == 17 || df == 18:
+		address, _, _ := decoder.Address(message)
+		return address
+	case df == 11:
+		// Format: [DF(5) + CA(3)] [ICAO(8)] [ICAO(8)] [ICAO(8)]
+		icao24 := uint32(message[1])<<16 | uint32(message[2])<<8 | uint32(message[3])
+		return fmt.Sprintf("%06X", icao24)
+	case parityAddressed(int(df)):
+		return fmt.Sprintf("%06X", ModeSResidual(message))
+	}
+	return ""
+}
+
+// parityAddressed reports whether replies of a downlink format overlay their parity with the aircraft address
+func parityAddressed(df int) bool {
+	switch {
+	case df == 0, df == 4, df == 5, df == 16, df == 20, df == 21, df >= 24:
+		return true
+	}
+	return false
 }
 
 // determineMessageType determines the type of Mode S message
@@ -125,7 +170,16 @@ func determineMessageType(message []byte) string {
 	switch df {
 	case 0, 4, 5, 11:
 		return "surveillance"
-	case 16, 17, 18, 19:
+	case 18:
+		// The control field tells TIS-B and ADS-R rebroadcasts from ADS-B sent by the device itself
+		switch cf := message[0] & 0x07; {
+		case cf == 2 || cf == 3 || cf == 4 || cf == 5:
+			return "tis_b"
+		case cf == 6:
+			return "ads_r"
+		}
+		return "extended_squitter"
+	case 16, 17, 19:
 		return "extended_squitter"
 	case 20, 21:
 		return "comm_b"
@@ -134,6 +188,14 @@ func determineMessageType(message []byte) string {
 	}
 }
 
+// DownlinkFormat returns the Mode S downlink format (DF) of the message, or -1 for Mode A/C messages
+func (b *BeastMessage) DownlinkFormat() int {
+	if !IsModeS(b.MessageTypeCode) || len(b.Message) == 0 {
+		return -1
+	}
+	return int(b.Message[0]>>3) & 0x1F
+}
+
 // Hex returns the message as a hex string
 func (b *BeastMessage) Hex() string {
 	return hex.EncodeToString(b.Message)
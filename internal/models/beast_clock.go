@@ -0,0 +1,100 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Beast timestamp formats, what a receiver's 48-bit timestamps count
+const (
+	ClockFreeRunning = "12mhz" // 12 MHz ticks of a free-running counter with an arbitrary start, as dump1090 sends
+	ClockGPS         = "gps"   // GPS time of day, as a Radarcape sends: seconds since UTC midnight and nanoseconds
+)
+
+const (
+	ticksPerSecond = 12_000_000
+	tickWrap       = 1 << 48 // The counter is 48 bits, wrapping after about 271 days
+
+	// maxClockSkew is how far a free-running clock may stray from the arrival times before it is anchored anew,
+	// e.g. after the receiver restarted behind a relay and its counter started over
+	maxClockSkew = 2 * time.Second
+)
+
+// BeastClock turns the timestamps of one connection's messages into times. A free-running counter is anchored to
+// the arrival time of the first message and counts on from there, so the gaps between messages are the receiver's
+// rather than the network's; GPS timestamps are taken as they are. The times never go backwards.
+type BeastClock struct {
+	gps      bool
+	anchored bool
+	base     time.Time // Arrival time of the message the free-running counter is anchored to
+	elapsed  int64     // Ticks since the anchor, unwrapped
+	ticks    uint64    // The last free-running timestamp
+	last     time.Time
+}
+
+// NewBeastClock returns a clock for a connection sending timestamps in the format, ClockFreeRunning or ClockGPS
+func NewBeastClock(format string) (*BeastClock, error) {
+	switch format {
+	case ClockFreeRunning, "":
+		return &BeastClock{}, nil
+	case ClockGPS:
+		return &BeastClock{gps: true}, nil
+	}
+	return nil, fmt.Errorf("unknown beast timestamp format %q (must be %s or %s)", format, ClockFreeRunning, ClockGPS)
+}
+
+// Time returns the time of a message with the timestamp that arrived at the given time. A timestamp of 0, which
+// receivers send when they have none, is taken to be the arrival time.
+func (c *BeastClock) Time(ticks uint64, arrived time.Time) time.Time {
+	var t time.Time
+	switch {
+	case ticks == 0:
+		t = arrived
+	case c.gps:
+		t = gpsTime(ticks, arrived)
+	default:
+		t = c.freeRunning(ticks, arrived)
+	}
+	if t.Before(c.last) {
+		t = c.last // The arrival time or a new anchor fell behind the messages before
+	}
+	c.last = t
+	return t
+}
+
+// freeRunning returns the time of a free-running timestamp, anchoring the counter anew when it has none or strays
+func (c *BeastClock) freeRunning(ticks uint64, arrived time.Time) time.Time {
+	if c.anchored {
+		// Modulo the counter's width, so a wrap counts on; a difference past half of it is a step back
+		diff := int64((ticks - c.ticks) % tickWrap)
+		if diff >= tickWrap/2 {
+			diff -= tickWrap
+		}
+		c.elapsed += diff
+		c.ticks = ticks
+		t := c.base.Add(time.Duration(c.elapsed * 250 / 3)) // 1e9 / 12e6 ns per tick
+		if skew := t.Sub(arrived); skew <= maxClockSkew && skew >= -maxClockSkew {
+			return t
+		}
+	}
+	c.anchored, c.base, c.elapsed, c.ticks = true, arrived, 0, ticks
+	return arrived
+}
+
+// gpsTime returns the time of a GPS timestamp on the UTC day closest to its arrival, the arrival time if it isn't one
+func gpsTime(ticks uint64, arrived time.Time) time.Time {
+	seconds, nanos := ticks>>30, ticks&(1<<30-1)
+	if seconds >= 24*60*60 || nanos >= 1e9 {
+		return arrived
+	}
+	day := arrived.UTC().Truncate(24 * time.Hour)
+	t := day.Add(time.Duration(seconds)*time.Second + time.Duration(nanos))
+	// Around midnight the receiver and this host may be on different days
+	switch d := t.Sub(arrived); {
+	case d > 12*time.Hour:
+		t = t.Add(-24 * time.Hour)
+	case d < -12*time.Hour:
+		t = t.Add(24 * time.Hour)
+	}
+	return t
+}
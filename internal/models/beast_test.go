@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/hex"
 	"testing"
 	"time"
 
@@ -66,6 +67,14 @@ func TestParseBeastMessage(t *testing.T) {
 	}
 }
 
+// withParityAddress sets a reply's parity to its CRC overlaid with the address
+func withParityAddress(msg []byte, icao uint32) []byte {
+	n := len(msg) - 3
+	parity := ModeSCRC(msg[:n]) ^ icao
+	msg[n], msg[n+1], msg[n+2] = byte(parity>>16), byte(parity>>8), byte(parity)
+	return msg
+}
+
 func TestExtractICAO(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -75,7 +84,37 @@ func TestExtractICAO(t *testing.T) {
 		{
 			name:     "valid ICAO extraction",
 			message:  []byte{0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71, 0xC2, 0xD7, 0x20, 0x00, 0x00, 0x00},
-			expected: "054840", // First 3 bytes: (0x8D & 0x07) << 16 | 0x48 << 8 | 0x40 = 0x05 << 16 | 0x48 << 8 | 0x40
+			expected: "4840D6", // Bytes 1-3, after the DF and CA fields
+		},
+		{
+			name:     "all-call reply",
+			message:  []byte{0x5D, 0x48, 0x40, 0xD6, 0x00, 0x00, 0x00},
+			expected: "4840D6",
+		},
+		{
+			name:     "altitude reply",
+			message:  withParityAddress([]byte{0x20, 0x00, 0x0C, 0x38, 0, 0, 0}, 0x4840D6),
+			expected: "4840D6", // Recovered from the parity it's overlaid on
+		},
+		{
+			name:     "Comm-B reply",
+			message:  withParityAddress([]byte{0xA0, 0x00, 0x18, 0x38, 0xCA, 0x3E, 0x51, 0xF0, 0xA8, 0x00, 0x00, 0, 0, 0}, 0x4840D6),
+			expected: "4840D6",
+		},
+		{
+			name:     "military extended squitter",
+			message:  []byte{0x9D, 0x48, 0x40, 0xD6, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			expected: "",
+		},
+		{
+			name:     "TIS-B with a non-ICAO address",
+			message:  []byte{0x95, 0xAB, 0xCD, 0xEF, 0x58, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			expected: "~ABCDEF",
+		},
+		{
+			name:     "TIS-B and ADS-R management",
+			message:  []byte{0x94, 0xAB, 0xCD, 0xEF, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			expected: "",
 		},
 		{
 			name:     "short message",
@@ -108,6 +147,21 @@ func TestDetermineMessageType(t *testing.T) {
 			message:  []byte{0x88, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
 			expected: "extended_squitter",
 		},
+		{
+			name:     "non-transponder device (DF 18, CF 0)",
+			message:  []byte{0x90, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			expected: "extended_squitter",
+		},
+		{
+			name:     "fine TIS-B (DF 18, CF 2)",
+			message:  []byte{0x92, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			expected: "tis_b",
+		},
+		{
+			name:     "ADS-R (DF 18, CF 6)",
+			message:  []byte{0x96, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			expected: "ads_r",
+		},
 		{
 			name:     "empty message",
 			message:  []byte{},
@@ -132,10 +186,12 @@ func TestBeastMessage_Hex(t *testing.T) {
 	assert.Equal(t, "8d4840d6202cc371c2d720000000", hex)
 }
 
+func TestBeastMessage_Ref(t *testing.T) {
+	assert.Equal(t, "beast-2:1041", (&BeastMessage{ConnID: "beast-2", Seq: 1041}).Ref())
+	assert.Empty(t, (&BeastMessage{Seq: 7}).Ref(), "not received over a connection, e.g. imported")
+}
+
 func TestParseBeastMessage_Timestamp(t *testing.T) {
-	// Create a message with a specific timestamp
-	// Timestamp: 12 MHz clock ticks (relative to sample block start)
-	// Using 12000000 ticks = 1 second
 	timestampTicks := int64(12000000) // 1 second in 12 MHz ticks
 
 	data := []byte{
@@ -150,7 +206,78 @@ func TestParseBeastMessage_Timestamp(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, msg)
 
-	// Check that timestamp is parsed (it will be relative, so we just verify it's set)
-	// The timestamp should be approximately 1 second before "now" since we used 1 second worth of ticks
-	assert.WithinDuration(t, time.Now().Add(-1*time.Second), msg.Timestamp, 2*time.Second)
+	assert.Equal(t, uint64(timestampTicks), msg.Ticks)
+	assert.WithinDuration(t, time.Now(), msg.Timestamp, time.Second, "stamped with its arrival until a clock makes a time of the ticks")
+}
+
+func TestBeastClock_FreeRunning(t *testing.T) {
+	clock, err := NewBeastClock(ClockFreeRunning)
+	require.NoError(t, err)
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, start, clock.Time(5_000_000, start), "anchored to the first arrival")
+	// Network jitter doesn't move the times, the receiver's ticks do
+	assert.Equal(t, start.Add(500*time.Millisecond), clock.Time(11_000_000, start.Add(900*time.Millisecond)))
+	assert.Equal(t, start.Add(500*time.Millisecond), clock.Time(11_000_000-12, start.Add(time.Second)), "never backwards")
+	assert.Equal(t, start.Add(time.Second), clock.Time(0, start.Add(time.Second)), "no timestamp, the arrival")
+
+	// The counter wraps at 48 bits
+	clock, _ = NewBeastClock(ClockFreeRunning)
+	clock.Time(1<<48-6_000_000, start)
+	assert.Equal(t, start.Add(time.Second), clock.Time(6_000_000, start.Add(time.Second)))
+
+	// A counter that started over is anchored anew
+	assert.Equal(t, start.Add(time.Minute), clock.Time(42, start.Add(time.Minute)))
+	assert.Equal(t, start.Add(time.Minute+time.Second), clock.Time(42+12_000_000, start.Add(time.Minute+time.Second)))
+}
+
+func TestBeastClock_GPS(t *testing.T) {
+	clock, err := NewBeastClock(ClockGPS)
+	require.NoError(t, err)
+	gps := func(seconds, nanos uint64) uint64 { return seconds<<30 | nanos }
+
+	arrived := time.Date(2026, 3, 1, 12, 0, 1, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 3, 1, 12, 0, 0, 250_000_000, time.UTC), clock.Time(gps(12*3600, 250_000_000), arrived))
+	// Sent just before midnight, arrived just after
+	arrived = time.Date(2026, 3, 2, 0, 0, 0, 100_000_000, time.UTC)
+	assert.Equal(t, time.Date(2026, 3, 1, 23, 59, 59, 900_000_000, time.UTC), clock.Time(gps(86399, 900_000_000), arrived))
+	assert.Equal(t, arrived, clock.Time(gps(90000, 0), arrived), "not a time of day")
+
+	_, err = NewBeastClock("10mhz")
+	assert.Error(t, err)
+}
+
+func TestSignalDBFS(t *testing.T) {
+	assert.Equal(t, 0.0, SignalDBFS(255), "full scale")
+	assert.Equal(t, -6.0, SignalDBFS(128))
+	assert.Equal(t, -48.1, SignalDBFS(1))
+	assert.Equal(t, MinDBFS, SignalDBFS(0))
+	assert.Equal(t, -12.6, (&BeastMessage{SignalLevel: 60}).SignalDBFS())
+}
+
+func TestCheckICAO(t *testing.T) {
+	assert.Equal(t, AddressICAO, CheckICAO("4840D6"))
+	assert.Equal(t, AddressICAO, CheckICAO("F00001"), "ICAO's own temporary block")
+	assert.Equal(t, AddressReserved, CheckICAO("000000"))
+	assert.Equal(t, AddressReserved, CheckICAO("FFFFFF"))
+	assert.Equal(t, AddressUnallocated, CheckICAO("EEEEEE"))
+	assert.Empty(t, CheckICAO("~4840D6"))
+	assert.Empty(t, CheckICAO("4840D"))
+}
+
+func TestBeastMessage_AddressType(t *testing.T) {
+	frame := func(s string) *BeastMessage {
+		msg, err := hex.DecodeString(s)
+		require.NoError(t, err)
+		m, err := NewBeastMessage(BeastTypeModeSLong, 0, 0, msg, time.Now())
+		require.NoError(t, err)
+		return m
+	}
+	assert.Equal(t, AddressICAO, frame("8D4840D6202CC371C32CE0576098").AddressType())
+	assert.Equal(t, AddressAnonymous, frame("91ABCDEF58C382D690C8AC2863A7").AddressType(), "DF18 CF1")
+	assert.Equal(t, AddressTrackFile, frame("95ABCDEF58C382D690C8AC2863A7").AddressType(), "DF18 CF5, TIS-B")
+	assert.Equal(t, AddressUnallocated, frame("8DEEEEEE202CC371C32CE0576098").AddressType())
+	assert.Empty(t, frame("A0001838CA3E51F0A8000047A086").AddressType(), "DF20, address overlaid with parity")
+	assert.True(t, ValidAddress(AddressTrackFile))
+	assert.False(t, ValidAddress(AddressUnallocated))
 }
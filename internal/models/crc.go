@@ -0,0 +1,46 @@
+package models
+
+// modeSGenerator is the Mode S CRC-24 generator polynomial, x^24 + x^23 + ... + x^10 + x^3 + 1
+const modeSGenerator = 0x1FFF409
+
+// ModeSCRC computes the Mode S CRC-24 of data
+func ModeSCRC(data []byte) uint32 {
+	var crc uint32
+	for _, b := range data {
+		crc ^= uint32(b) << 16
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= modeSGenerator
+			}
+		}
+	}
+	return crc & 0xFFFFFF
+}
+
+// ModeSResidual is a Mode S message's CRC xor its last 24 bits, the parity field.
+// It is 0 for an intact DF17/DF18 squitter, the interrogator code for a DF11 all-call reply,
+// and the aircraft address for replies whose parity is overlaid with it (DF0/4/5/16/20/21).
+func ModeSResidual(msg []byte) uint32 {
+	if len(msg) < 4 {
+		return 0
+	}
+	n := len(msg) - 3
+	parity := uint32(msg[n])<<16 | uint32(msg[n+1])<<8 | uint32(msg[n+2])
+	return ModeSCRC(msg[:n]) ^ parity
+}
+
+// CRCError reports whether the message's parity shows it was corrupted in reception
+// Only DF11 all-call replies and DF17/DF18 squitters can be checked on their own; replies whose parity is overlaid
+// with the address and Mode A/C replies never report an error.
+func (b *BeastMessage) CRCError() bool {
+	residual := ModeSResidual(b.Message)
+	switch b.DownlinkFormat() {
+	case 11:
+		_, ok := InterrogatorFromResidual(residual) // The residual is the interrogator's code
+		return !ok
+	case 17, 18:
+		return residual != 0
+	}
+	return false
+}
@@ -0,0 +1,570 @@
+package models
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+
+	"flight_trmnl/internal/decoder"
+)
+
+// FrameField is one field of a Mode S frame, as shown by the decode command
+type FrameField struct {
+	Name  string
+	Value string
+	Note  string // What the value means, e.g. the name of a downlink format
+}
+
+// capabilityNames describes the CA field of DF11 and DF17
+var capabilityNames = map[uint64]string{
+	0: "Level 1 transponder",
+	4: "Level 2+ transponder, on ground",
+	5: "Level 2+ transponder, airborne",
+	6: "Level 2+ transponder, on ground or airborne",
+	7: "Downlink request or flight status set",
+}
+
+// nacpNames gives the 95% accuracy bound of each navigation accuracy category for position
+var nacpNames = map[int]string{
+	0: "Unknown accuracy", 1: "Within 10 NM", 2: "Within 4 NM", 3: "Within 2 NM", 4: "Within 1 NM", 5: "Within 0.5 NM",
+	6: "Within 0.3 NM", 7: "Within 0.1 NM", 8: "Within 0.05 NM", 9: "Within 30 m", 10: "Within 10 m", 11: "Within 3 m",
+}
+
+// flightStatusNames describes the FS field of surveillance and Comm-B replies
+var flightStatusNames = map[uint64]string{
+	0: "Airborne",
+	1: "On ground",
+	2: "Alert, airborne",
+	3: "Alert, on ground",
+	4: "Alert and SPI",
+	5: "SPI, airborne or on ground",
+}
+
+// DescribeModeS breaks a 56 or 112 bit Mode S frame down into its fields, for people debugging or learning the format.
+// Positions are shown as the raw CPR values, since decoding them needs a second frame or a reference position.
+func DescribeModeS(msg []byte) ([]FrameField, error) {
+	if len(msg) != BeastDataLenModeSShort && len(msg) != BeastDataLenModeSLong {
+		return nil, fmt.Errorf("a Mode S frame is %d or %d bytes, got %d", BeastDataLenModeSShort, BeastDataLenModeSLong, len(msg))
+	}
+
+	d := &frameDescriber{msg: msg}
+	df := d.bits(1, 5)
+	if df >= 24 {
+		df = 24 // Comm-D uses only the first two bits
+	}
+	d.add("Frame", hex.EncodeToString(msg), fmt.Sprintf("%d bits", len(msg)*8))
+	d.add("DF", fmt.Sprint(df), DownlinkFormatName(int(df)))
+
+	wantLen := BeastDataLenModeSShort
+	if df >= 16 {
+		wantLen = BeastDataLenModeSLong
+	}
+	if len(msg) != wantLen {
+		d.add("Length", fmt.Sprintf("%d bits", len(msg)*8), fmt.Sprintf("DF%d frames are %d bits, this is probably corrupt", df, wantLen*8))
+	}
+
+	residual := ModeSResidual(msg)
+	switch df {
+	case 11:
+		d.add("CA", fmt.Sprint(d.bits(6, 8)), capabilityNames[d.bits(6, 8)])
+		d.addAddress(d.bits(9, 32), "")
+		switch in, ok := InterrogatorFromResidual(residual); {
+		case !ok:
+			d.add("CRC", "FAILED", fmt.Sprintf("residual %06X", residual))
+		case in.Squitter():
+			d.add("CRC", "OK", "")
+			d.add("Interrogator", in.String(), "acquisition squitter or reply to a Mode A/C/S all-call")
+		default:
+			d.add("CRC", "OK", "")
+			d.add("Interrogator", in.String(), "reply to this radar's all-call")
+		}
+	case 17, 18:
+		address, _, ok := decoder.Address(msg)
+		switch {
+		case df == 17:
+			d.add("CA", fmt.Sprint(d.bits(6, 8)), capabilityNames[d.bits(6, 8)])
+			d.addAddress(d.bits(9, 32), "")
+		case strings.HasPrefix(address, "~"):
+			d.add("CF", fmt.Sprint(d.bits(6, 8)), describeCF(d.bits(6, 8)))
+			d.add("Address", address[1:], "non-ICAO address, e.g. anonymous or a TIS-B track number")
+		default:
+			d.add("CF", fmt.Sprint(d.bits(6, 8)), describeCF(d.bits(6, 8)))
+			if ok || len(msg) != BeastDataLenModeSLong {
+				d.addAddress(d.bits(9, 32), "")
+			}
+		}
+		if residual == 0 {
+			d.add("CRC", "OK", "")
+		} else {
+			d.add("CRC", "FAILED", fmt.Sprintf("residual %06X, the fields below are unreliable", residual))
+		}
+		switch {
+		case decoder.IsExtendedSquitter(msg):
+			d.describeES()
+		case len(msg) == BeastDataLenModeSLong:
+			d.add("ME", hex.EncodeToString(msg[4:11]), "not laid out like ADS-B, not decoded")
+		}
+	case 0, 16:
+		d.add("VS", fmt.Sprint(d.bits(6, 6)), map[uint64]string{0: "Airborne", 1: "On ground"}[d.bits(6, 6)])
+		d.add("SL", fmt.Sprint(d.bits(9, 11)), "ACAS sensitivity level")
+		d.add("RI", fmt.Sprint(d.bits(14, 17)), "ACAS reply information")
+		d.addAltitude(d.bits(20, 32))
+		if df == 16 && len(msg) == BeastDataLenModeSLong {
+			d.add("MV", hex.EncodeToString(msg[4:11]), "ACAS coordination message")
+		}
+		d.addParityAddress(residual)
+	case 4, 5, 20, 21:
+		d.add("FS", fmt.Sprint(d.bits(6, 8)), flightStatusNames[d.bits(6, 8)])
+		d.add("DR", fmt.Sprint(d.bits(9, 13)), "Downlink request")
+		d.add("UM", fmt.Sprint(d.bits(14, 19)), "Utility message")
+		if df == 4 || df == 20 {
+			d.addAltitude(d.bits(20, 32))
+		} else {
+			d.add("Squawk", fmt.Sprintf("%04o", decoder.DecodeID13(d.bits(20, 32))), describeSquawk(decoder.DecodeID13(d.bits(20, 32))))
+		}
+		if (df == 20 || df == 21) && len(msg) == BeastDataLenModeSLong {
+			d.describeCommB()
+		}
+		d.addParityAddress(residual)
+	default:
+		d.add("CRC", fmt.Sprintf("%06X", residual), "residual, this format isn't decoded")
+	}
+	return d.fields, nil
+}
+
+// DescribeModeAC breaks a 2-byte Mode A/C reply down into the squawk and altitude it can be read as
+func DescribeModeAC(msg []byte) ([]FrameField, error) {
+	m, err := decoder.DecodeModeAC(msg)
+	if err != nil {
+		return nil, err
+	}
+	d := &frameDescriber{msg: msg}
+	d.add("Frame", hex.EncodeToString(msg), "Mode A/C reply")
+	d.add("Squawk", fmt.Sprintf("%04o", m.Squawk), describeSquawk(m.Squawk))
+	if m.SPI {
+		d.add("SPI", "yes", "the pilot pressed ident")
+	}
+	if m.Altitude != nil {
+		d.add("Altitude", fmt.Sprintf("%d ft", *m.Altitude), "if the reply answered a Mode C interrogation")
+	} else {
+		d.add("Altitude", "n/a", "not a valid Gillham code, so a Mode A reply")
+	}
+	return d.fields, nil
+}
+
+type frameDescriber struct {
+	msg    []byte
+	fields []FrameField
+}
+
+func (d *frameDescriber) add(name, value, note string) {
+	d.fields = append(d.fields, FrameField{Name: name, Value: value, Note: note})
+}
+
+// bits returns bits first to last of the frame, numbered from 1 like the Mode S specification
+func (d *frameDescriber) bits(first, last int) uint64 {
+	return frameBits(d.msg, first, last)
+}
+
+// me returns bits of the 56-bit ME field of an extended squitter, numbered from 1 within the field
+func (d *frameDescriber) me(first, last int) uint64 {
+	return d.bits(32+first, 32+last)
+}
+
+func (d *frameDescriber) addAddress(icao uint64, note string) {
+	address := fmt.Sprintf("%06X", icao)
+	if country := CountryForICAO(address); country != "" && note != "" {
+		note += ", " + country
+	} else if country != "" {
+		note = country
+	}
+	d.add("ICAO", address, note)
+}
+
+// addParityAddress shows the address recovered from the parity field of replies that overlay it
+func (d *frameDescriber) addParityAddress(residual uint32) {
+	d.addAddress(uint64(residual), "recovered from parity")
+	d.add("CRC", "n/a", "the parity is overlaid with the address, so it only checks against a known aircraft")
+}
+
+func (d *frameDescriber) addAltitude(ac uint64) {
+	if feet, ok := decoder.DecodeAC13(ac); ok {
+		d.add("Altitude", fmt.Sprintf("%d ft", feet), "")
+	} else {
+		d.add("Altitude", "n/a", fmt.Sprintf("AC %04X not available or in metres", ac))
+	}
+}
+
+// describeES adds the fields of a DF17/DF18 ME field by its type code
+func (d *frameDescriber) describeES() {
+	tc := d.me(1, 5)
+	d.add("TC", fmt.Sprint(tc), TypeCodeName(int(tc)))
+	m, _ := decoder.Decode(d.msg) // Decodes the identification and position type codes of any extended squitter
+
+	switch {
+	case tc >= 1 && tc <= 4:
+		category := string(rune('A'+4-tc)) + fmt.Sprint(d.me(6, 8))
+		d.add("Category", category, emitterCategoryNames[category])
+		if m != nil && m.Identification != nil {
+			d.add("Callsign", m.Identification.Callsign, "")
+		}
+
+	case tc >= 5 && tc <= 8:
+		d.add("Movement", fmt.Sprint(d.me(6, 12)), describeMovement(d.me(6, 12)))
+		if d.me(13, 13) == 1 {
+			d.add("Track", fmt.Sprintf("%.1f°", float64(d.me(14, 20))*360/128), "")
+		} else {
+			d.add("Track", "n/a", "track status not set")
+		}
+		d.addCPR()
+
+	case tc >= 9 && tc <= 18, tc >= 20 && tc <= 22:
+		d.add("SS", fmt.Sprint(d.me(6, 7)), map[uint64]string{0: "No condition", 1: "Permanent alert", 2: "Temporary alert", 3: "SPI"}[d.me(6, 7)])
+		switch {
+		case m == nil || m.AirbornePosition == nil || m.AirbornePosition.Altitude == nil:
+			d.add("Altitude", "n/a", "not available")
+		case m.AirbornePosition.GNSS:
+			d.add("Altitude", fmt.Sprintf("%d ft", *m.AirbornePosition.Altitude), fmt.Sprintf("GNSS height, %d m", d.me(9, 20)))
+		default:
+			d.add("Altitude", fmt.Sprintf("%d ft", *m.AirbornePosition.Altitude), "barometric")
+		}
+		d.add("T", fmt.Sprint(d.me(21, 21)), map[uint64]string{0: "Not UTC synchronized", 1: "UTC synchronized"}[d.me(21, 21)])
+		d.addCPR()
+
+	case tc == 19:
+		d.describeVelocity()
+
+	case tc == 28:
+		d.describeStatus()
+
+	case tc == 31:
+		d.describeOperationalStatus()
+
+	case tc == 29:
+		d.describeTargetState()
+	}
+}
+
+// describeTargetState adds what the crew set the autopilot to from a TC29 target state and status message
+func (d *frameDescriber) describeTargetState() {
+	m, err := decoder.Decode(d.msg)
+	if err != nil || m.TargetState == nil {
+		d.add("Subtype", fmt.Sprint(d.me(6, 7)), "version 1 layout, not decoded")
+		return
+	}
+	s := m.TargetState
+	d.add("Subtype", fmt.Sprint(d.me(6, 7)), "DO-260B")
+	switch {
+	case s.SelectedAltitude == nil:
+		d.add("Selected altitude", "n/a", "not available")
+	case s.FMSAltitude:
+		d.add("Selected altitude", fmt.Sprintf("%d ft", *s.SelectedAltitude), "selected in the FMS")
+	default:
+		d.add("Selected altitude", fmt.Sprintf("%d ft", *s.SelectedAltitude), "selected on the MCP/FCU")
+	}
+	if s.BaroSetting != nil {
+		d.add("Baro setting", fmt.Sprintf("%.1f mb", *s.BaroSetting), "")
+	} else {
+		d.add("Baro setting", "n/a", "not available")
+	}
+	if s.SelectedHeading != nil {
+		d.add("Selected heading", fmt.Sprintf("%.1f°", *s.SelectedHeading), "")
+	} else {
+		d.add("Selected heading", "n/a", "not available")
+	}
+	switch {
+	case len(s.Modes) > 0:
+		d.add("Modes", strings.Join(s.Modes, " "), "engaged")
+	case d.me(47, 47) == 1:
+		d.add("Modes", "none", "engaged")
+	default:
+		d.add("Modes", "n/a", "mode bits not valid")
+	}
+}
+
+// describeOperationalStatus adds the version and integrity fields of a TC31 aircraft operational status message
+func (d *frameDescriber) describeOperationalStatus() {
+	m, err := decoder.Decode(d.msg)
+	if err != nil || m.OperationalStatus == nil {
+		d.add("Subtype", fmt.Sprint(d.me(6, 8)), "Reserved")
+		return
+	}
+	s := m.OperationalStatus
+	d.add("Subtype", fmt.Sprint(d.me(6, 8)), map[bool]string{false: "Airborne", true: "Surface"}[s.Surface])
+	d.add("Version", fmt.Sprint(s.Version), map[int]string{decoder.Version0: "DO-260", decoder.Version1: "DO-260A", decoder.Version2: "DO-260B"}[s.Version])
+	if s.Version == decoder.Version0 {
+		return
+	}
+	d.add("NIC-A", fmt.Sprint(d.me(44, 44)), "NIC supplement, with the position type code gives the NIC")
+	if s.Surface && s.Version >= decoder.Version2 {
+		d.add("NIC-C", fmt.Sprint(d.me(20, 20)), "NIC supplement for surface positions")
+	}
+	d.add("NACp", fmt.Sprint(s.NACp), nacpNames[s.NACp])
+	note := "Unknown integrity"
+	if s.SIL > 0 {
+		per := "flight hour"
+		if s.SILPerSample {
+			per = "sample"
+		}
+		note = fmt.Sprintf("At most a 1e-%d chance of leaving the containment radius per %s", 2*s.SIL+1, per)
+	}
+	d.add("SIL", fmt.Sprint(s.SIL), note)
+}
+
+// describeCommB adds the fields of a DF20/DF21 MB field when its register can be inferred
+func (d *frameDescriber) describeCommB() {
+	mb := hex.EncodeToString(d.msg[4:11])
+	commB, err := decoder.DecodeCommB(d.msg)
+	switch err {
+	case decoder.ErrAmbiguousBDS:
+		d.add("MB", mb, "Comm-B message, fits more than one register")
+		return
+	case decoder.ErrUnknownRegister:
+		d.add("MB", mb, "Comm-B message, register not identified")
+		return
+	}
+	d.add("MB", mb, "Comm-B message")
+	d.add("BDS", commB.BDS, map[string]string{decoder.BDS40: "Selected vertical intention",
+		decoder.BDS50: "Track and turn report", decoder.BDS60: "Heading and speed report"}[commB.BDS]+", inferred")
+
+	if v := commB.SelectedVertical; v != nil {
+		if v.MCPAltitude != nil {
+			d.add("MCP altitude", fmt.Sprintf("%d ft", *v.MCPAltitude), "selected on the MCP/FCU")
+		}
+		if v.FMSAltitude != nil {
+			d.add("FMS altitude", fmt.Sprintf("%d ft", *v.FMSAltitude), "selected in the FMS")
+		}
+		if v.BaroSetting != nil {
+			d.add("Baro setting", fmt.Sprintf("%.1f mb", *v.BaroSetting), "")
+		}
+	}
+	if v := commB.TrackAndTurn; v != nil {
+		if v.Roll != nil {
+			d.add("Roll", fmt.Sprintf("%.1f°", *v.Roll), "negative when left wing down")
+		}
+		if v.Track != nil {
+			d.add("Track", fmt.Sprintf("%.1f°", *v.Track), "true")
+		}
+		if v.GroundSpeed != nil {
+			d.add("Speed", fmt.Sprintf("%d kt", *v.GroundSpeed), "ground speed")
+		}
+		if v.TrackRate != nil {
+			d.add("Track rate", fmt.Sprintf("%.2f°/s", *v.TrackRate), "")
+		}
+		if v.TrueAirspeed != nil {
+			d.add("Airspeed", fmt.Sprintf("%d kt", *v.TrueAirspeed), "true airspeed")
+		}
+	}
+	if v := commB.HeadingAndSpeed; v != nil {
+		if v.Heading != nil {
+			d.add("Heading", fmt.Sprintf("%.1f°", *v.Heading), "magnetic")
+		}
+		if v.IndicatedAirspeed != nil {
+			d.add("Speed", fmt.Sprintf("%d kt", *v.IndicatedAirspeed), "indicated airspeed")
+		}
+		if v.Mach != nil {
+			d.add("Mach", fmt.Sprintf("%.3f", *v.Mach), "")
+		}
+		if v.BaroVerticalRate != nil {
+			d.add("Vertical rate", fmt.Sprintf("%d ft/min", *v.BaroVerticalRate), "barometric")
+		}
+		if v.InertialRate != nil {
+			d.add("Inertial rate", fmt.Sprintf("%d ft/min", *v.InertialRate), "vertical rate from inertial or GNSS sources")
+		}
+	}
+}
+
+// describeStatus adds the fields of a TC28 aircraft status message
+func (d *frameDescriber) describeStatus() {
+	subtype := d.me(6, 8)
+	switch subtype {
+	case 1:
+		d.add("Subtype", "1", "Emergency/priority status")
+		state := d.me(9, 11)
+		note := map[uint64]string{0: "No emergency", 1: "General emergency", 2: "Lifeguard/medical", 3: "Minimum fuel",
+			4: "No communications", 5: "Unlawful interference", 6: "Downed aircraft", 7: "Reserved"}[state]
+		d.add("Emergency", fmt.Sprint(state), note)
+		squawk := decoder.DecodeID13(d.me(12, 24))
+		d.add("Squawk", fmt.Sprintf("%04o", squawk), describeSquawk(squawk))
+	case 2:
+		d.add("Subtype", "2", "TCAS resolution advisory")
+		rat, mte := d.me(27, 27) == 1, d.me(28, 28) == 1
+		d.add("ARA", fmt.Sprintf("%014b", d.me(9, 22)), decoder.AdvisorySummary(d.me(9, 22), rat, mte))
+		var complements []string
+		for i, name := range []string{"do not pass below", "do not pass above", "do not turn left", "do not turn right"} {
+			if d.me(23+i, 23+i) == 1 {
+				complements = append(complements, name)
+			}
+		}
+		d.add("RAC", fmt.Sprintf("%04b", d.me(23, 26)), strings.Join(complements, ", "))
+		d.add("RAT", fmt.Sprint(d.me(27, 27)), map[bool]string{false: "Active", true: "Terminated"}[rat])
+		d.add("MTE", fmt.Sprint(d.me(28, 28)), map[bool]string{false: "One threat", true: "Multiple threats"}[mte])
+		switch d.me(29, 30) {
+		case 1:
+			d.add("Threat", fmt.Sprintf("%06X", d.me(31, 54)), "ICAO address")
+		case 2:
+			d.add("Threat", fmt.Sprint(d.me(31, 56)), "altitude, range, and bearing, not decoded")
+		}
+	default:
+		d.add("Subtype", fmt.Sprint(subtype), "Reserved")
+	}
+}
+
+func (d *frameDescriber) addCPR() {
+	format := "even"
+	if d.me(22, 22) == 1 {
+		format = "odd"
+	}
+	d.add("CPR format", format, "")
+	d.add("CPR lat", fmt.Sprint(d.me(23, 39)), fmt.Sprintf("%.6f of a zone", float64(d.me(23, 39))/131072))
+	d.add("CPR lon", fmt.Sprint(d.me(40, 56)), "a position needs the other format's frame or a reference position")
+}
+
+// describeVelocity adds the fields of a TC19 airborne velocity message
+func (d *frameDescriber) describeVelocity() {
+	subtype := d.me(6, 8)
+	d.add("Subtype", fmt.Sprint(subtype), map[uint64]string{1: "Ground speed", 2: "Ground speed, supersonic", 3: "Airspeed", 4: "Airspeed, supersonic"}[subtype])
+	scale := 1.0
+	if subtype == 2 || subtype == 4 {
+		scale = 4
+	}
+
+	switch subtype {
+	case 1, 2:
+		vew, vns := d.me(15, 24), d.me(26, 35)
+		if vew == 0 || vns == 0 {
+			d.add("Speed", "n/a", "not available")
+			break
+		}
+		vx, vy := (float64(vew)-1)*scale, (float64(vns)-1)*scale
+		if d.me(14, 14) == 1 {
+			vx = -vx
+		}
+		if d.me(25, 25) == 1 {
+			vy = -vy
+		}
+		track := math.Atan2(vx, vy) * 180 / math.Pi
+		if track < 0 {
+			track += 360
+		}
+		d.add("Speed", fmt.Sprintf("%.0f kt", math.Hypot(vx, vy)), "ground speed")
+		d.add("Track", fmt.Sprintf("%.1f°", track), "")
+	case 3, 4:
+		if d.me(14, 14) == 1 {
+			d.add("Heading", fmt.Sprintf("%.1f°", float64(d.me(15, 24))*360/1024), "magnetic")
+		} else {
+			d.add("Heading", "n/a", "not available")
+		}
+		if as := d.me(26, 35); as != 0 {
+			kind := map[uint64]string{0: "indicated airspeed", 1: "true airspeed"}[d.me(25, 25)]
+			d.add("Speed", fmt.Sprintf("%.0f kt", (float64(as)-1)*scale), kind)
+		} else {
+			d.add("Speed", "n/a", "not available")
+		}
+	default:
+		return
+	}
+
+	if vr := d.me(38, 46); vr != 0 {
+		rate := (int(vr) - 1) * 64
+		if d.me(37, 37) == 1 {
+			rate = -rate
+		}
+		d.add("Vertical rate", fmt.Sprintf("%d ft/min", rate), map[uint64]string{0: "GNSS", 1: "barometric"}[d.me(36, 36)])
+	} else {
+		d.add("Vertical rate", "n/a", "not available")
+	}
+	if diff := d.me(50, 56); diff != 0 {
+		delta := (int(diff) - 1) * 25
+		if d.me(49, 49) == 1 {
+			delta = -delta
+		}
+		d.add("GNSS - baro", fmt.Sprintf("%d ft", delta), "")
+	}
+}
+
+// emitterCategoryNames describes the emitter categories of identification messages
+var emitterCategoryNames = map[string]string{
+	"A0": "No category information",
+	"A1": "Light (< 15500 lbs)",
+	"A2": "Small (15500 to 75000 lbs)",
+	"A3": "Large (75000 to 300000 lbs)",
+	"A4": "High vortex large",
+	"A5": "Heavy (> 300000 lbs)",
+	"A6": "High performance",
+	"A7": "Rotorcraft",
+	"B0": "No category information",
+	"B1": "Glider or sailplane",
+	"B2": "Lighter than air",
+	"B3": "Parachutist or skydiver",
+	"B4": "Ultralight or paraglider",
+	"B6": "Unmanned aerial vehicle",
+	"B7": "Space vehicle",
+	"C0": "No category information",
+	"C1": "Surface emergency vehicle",
+	"C2": "Surface service vehicle",
+	"C3": "Point obstacle",
+	"C4": "Cluster obstacle",
+	"C5": "Line obstacle",
+}
+
+func describeCF(cf uint64) string {
+	switch cf {
+	case 0:
+		return "ADS-B from a non-transponder device, ICAO address"
+	case 1:
+		return "ADS-B from a non-transponder device, non-ICAO address"
+	case 2:
+		return "Fine TIS-B"
+	case 3:
+		return "Coarse TIS-B"
+	case 4:
+		return "TIS-B and ADS-R management"
+	case 5:
+		return "TIS-B or ADS-R, non-ICAO address"
+	case 6:
+		return "ADS-B rebroadcast (ADS-R)"
+	default:
+		return "Reserved"
+	}
+}
+
+func describeSquawk(squawk int) string {
+	switch squawk {
+	case 07500:
+		return "Hijack"
+	case 07600:
+		return "Radio failure"
+	case 07700:
+		return "Emergency"
+	}
+	return ""
+}
+
+// describeMovement converts the surface movement code to a ground speed
+func describeMovement(m uint64) string {
+	v := float64(m)
+	switch {
+	case m == 0:
+		return "not available"
+	case m == 1:
+		return "stopped"
+	case m <= 8:
+		return fmt.Sprintf("%.3f kt", 0.125+(v-2)*0.125)
+	case m <= 12:
+		return fmt.Sprintf("%.2f kt", 1+(v-9)*0.25)
+	case m <= 38:
+		return fmt.Sprintf("%.1f kt", 2+(v-13)*0.5)
+	case m <= 93:
+		return fmt.Sprintf("%.0f kt", 15+(v-39))
+	case m <= 108:
+		return fmt.Sprintf("%.0f kt", 70+(v-94)*2)
+	case m <= 123:
+		return fmt.Sprintf("%.0f kt", 100+(v-109)*5)
+	case m == 124:
+		return "175 kt or more"
+	default:
+		return "reserved"
+	}
+}
@@ -0,0 +1,253 @@
+package models
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// describe decodes a hex frame and returns its fields by name, with the value and note joined
+func describe(t *testing.T, frame string) map[string]string {
+	t.Helper()
+	msg, err := hex.DecodeString(frame)
+	require.NoError(t, err)
+	fields, err := DescribeModeS(msg)
+	require.NoError(t, err)
+
+	byName := make(map[string]string)
+	for _, f := range fields {
+		byName[f.Name] = f.Value
+		if f.Note != "" {
+			byName[f.Name] += " | " + f.Note
+		}
+	}
+	return byName
+}
+
+// allCallReply builds a DF11 all-call reply from aircraft icao, with the parity overlaid with an interrogator code
+func allCallReply(icao, code uint32) []byte {
+	msg := make([]byte, 7)
+	binary.BigEndian.PutUint32(msg, 11<<27|5<<24|icao)
+	parity := ModeSCRC(msg[:4]) ^ code
+	msg[4], msg[5], msg[6] = byte(parity>>16), byte(parity>>8), byte(parity)
+	return msg
+}
+
+// surveillanceReply builds a DF4/DF5 reply from aircraft icao, with the address overlaid on its parity
+func surveillanceReply(df, fs, code uint32, icao uint32) string {
+	msg := make([]byte, 7)
+	binary.BigEndian.PutUint32(msg, df<<27|fs<<24|code)
+	parity := ModeSCRC(msg[:4]) ^ icao
+	msg[4], msg[5], msg[6] = byte(parity>>16), byte(parity>>8), byte(parity)
+	return hex.EncodeToString(msg)
+}
+
+func TestModeSResidual(t *testing.T) {
+	msg, _ := hex.DecodeString("8D4840D6202CC371C32CE0576098")
+	assert.Zero(t, ModeSResidual(msg))
+
+	msg[5] ^= 0x01
+	assert.NotZero(t, ModeSResidual(msg), "a flipped bit fails the check")
+}
+
+func TestBeastMessageCRCError(t *testing.T) {
+	frame := func(s string) []byte {
+		msg, _ := hex.DecodeString(s)
+		return msg
+	}
+	squitter := frame("8D4840D6202CC371C32CE0576098")
+	corrupt := frame("8D4840D6202CC371C32CE0576098")
+	corrupt[5] ^= 0x01
+	allCall := allCallReply(0x4840D6, 3) // Reply to interrogator code 3
+	corruptAllCall := append([]byte(nil), allCall...)
+	corruptAllCall[2] ^= 0x10
+
+	tests := []struct {
+		name    string
+		typ     byte
+		message []byte
+		want    bool
+	}{
+		{"intact squitter", BeastTypeModeSLong, squitter, false},
+		{"corrupt squitter", BeastTypeModeSLong, corrupt, true},
+		{"all-call reply", BeastTypeModeSShort, allCall, false},
+		{"corrupt all-call reply", BeastTypeModeSShort, corruptAllCall, true},
+		{"all-call reply to SI 63", BeastTypeModeSShort, allCallReply(0x4840D6, 0x4F), false},
+		{"all-call reply with an unused code label", BeastTypeModeSShort, allCallReply(0x4840D6, 0x50), true},
+		{"address overlaid on parity", BeastTypeModeSShort, frame(surveillanceReply(4, 0, 0x1234, 0x4840D6)), false},
+		{"Mode A/C", BeastTypeModeAC, []byte{0x12, 0x34}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &BeastMessage{MessageTypeCode: tt.typ, Message: tt.message}
+			assert.Equal(t, tt.want, msg.CRCError())
+		})
+	}
+}
+
+func TestDescribeModeAC(t *testing.T) {
+	fields, err := DescribeModeAC([]byte{0x77, 0x00})
+	require.NoError(t, err)
+	byName := make(map[string]string)
+	for _, f := range fields {
+		byName[f.Name] = f.Value + " | " + f.Note
+	}
+	assert.Equal(t, "7700 | Emergency", byName["Squawk"])
+	assert.Contains(t, byName["Altitude"], "n/a")
+
+	fields, err = DescribeModeAC([]byte{0x03, 0x20})
+	require.NoError(t, err)
+	assert.Equal(t, FrameField{Name: "Altitude", Value: "1000 ft", Note: "if the reply answered a Mode C interrogation"}, fields[len(fields)-1])
+}
+
+func TestDescribeModeS(t *testing.T) {
+	t.Run("identification", func(t *testing.T) {
+		f := describe(t, "8D4840D6202CC371C32CE0576098")
+		assert.Equal(t, "17 | Extended squitter (ADS-B)", f["DF"])
+		assert.Equal(t, "4840D6 | Netherlands", f["ICAO"])
+		assert.Equal(t, "OK", f["CRC"])
+		assert.Equal(t, "4 | Aircraft identification", f["TC"])
+		assert.Equal(t, "KLM1023", f["Callsign"])
+
+		// Unused characters are dropped, as the decoder does for stored callsigns
+		f = describe(t, "8D4840D6202CC340C32CE0576098")
+		assert.Equal(t, "KLM023", f["Callsign"])
+	})
+
+	t.Run("airborne position", func(t *testing.T) {
+		f := describe(t, "8D40621D58C382D690C8AC2863A7")
+		assert.Equal(t, "38000 ft | barometric", f["Altitude"])
+		assert.Equal(t, "even", f["CPR format"])
+
+		f = describe(t, "8D40621DA0C382D690C8AC2863A7")
+		assert.Equal(t, "10262 ft | GNSS height, 3128 m", f["Altitude"])
+		assert.Contains(t, f["CPR lat"], "93000")
+		assert.Contains(t, f["CPR lon"], "51372")
+	})
+
+	t.Run("ground speed", func(t *testing.T) {
+		f := describe(t, "8D485020994409940838175B284F")
+		assert.Equal(t, "159 kt | ground speed", f["Speed"])
+		assert.Equal(t, "182.9°", f["Track"])
+		assert.Equal(t, "-832 ft/min | GNSS", f["Vertical rate"])
+	})
+
+	t.Run("airspeed", func(t *testing.T) {
+		f := describe(t, "8DA05F219B06B6AF189400CBC33F")
+		assert.Equal(t, "244.0° | magnetic", f["Heading"])
+		assert.Equal(t, "375 kt | true airspeed", f["Speed"])
+		assert.Equal(t, "-2304 ft/min | barometric", f["Vertical rate"])
+	})
+
+	t.Run("target state and status", func(t *testing.T) {
+		f := describe(t, "8DA05629EA21485CBF3F8CADAEEB")
+		assert.Equal(t, "29 | Target state and status", f["TC"])
+		assert.Equal(t, "16992 ft | selected on the MCP/FCU", f["Selected altitude"])
+		assert.Equal(t, "1012.8 mb", f["Baro setting"])
+		assert.Equal(t, "66.8°", f["Selected heading"])
+		assert.Equal(t, "autopilot vnav lnav tcas | engaged", f["Modes"])
+	})
+
+	t.Run("corrupt squitter", func(t *testing.T) {
+		f := describe(t, "8D4840D6202CC371C32CE0576099")
+		assert.Contains(t, f["CRC"], "FAILED")
+	})
+
+	t.Run("all-call reply", func(t *testing.T) {
+		f := describe(t, hex.EncodeToString(allCallReply(0x4840D6, 0x2A)))
+		assert.Equal(t, "11 | All-call reply", f["DF"])
+		assert.Equal(t, "OK", f["CRC"])
+		assert.Equal(t, "SI26 | reply to this radar's all-call", f["Interrogator"])
+
+		f = describe(t, hex.EncodeToString(allCallReply(0x4840D6, 0)))
+		assert.Contains(t, f["Interrogator"], "II0 | acquisition squitter")
+	})
+
+	t.Run("altitude reply", func(t *testing.T) {
+		// 38000 ft in 25 ft steps: the Q bit set and the M bit clear
+		n := uint32(38000+1000) / 25
+		ac := (n&0x7E0)<<2 | (n&0x10)<<1 | 0x10 | n&0x0F
+		f := describe(t, surveillanceReply(4, 0, ac, 0x4840D6))
+		assert.Equal(t, "0 | Airborne", f["FS"])
+		assert.Equal(t, "38000 ft", f["Altitude"])
+		assert.Equal(t, "4840D6 | recovered from parity, Netherlands", f["ICAO"])
+	})
+
+	t.Run("identity reply", func(t *testing.T) {
+		// 7700 is A=7 (A1 A2 A4) and B=7 (B1 B2 B4)
+		id := uint32(1<<11 | 1<<9 | 1<<7 | 1<<5 | 1<<3 | 1<<1)
+		f := describe(t, surveillanceReply(5, 1, id, 0xA05F21))
+		assert.Equal(t, "7700 | Emergency", f["Squawk"])
+		assert.Equal(t, "1 | On ground", f["FS"])
+	})
+
+	t.Run("emergency status", func(t *testing.T) {
+		f := describe(t, squitter(0x4840D6, statusME(1, 3<<45)))
+		assert.Equal(t, "28 | Aircraft status", f["TC"])
+		assert.Equal(t, "3 | Minimum fuel", f["Emergency"])
+		assert.Equal(t, "0000", f["Squawk"])
+	})
+
+	t.Run("resolution advisory", func(t *testing.T) {
+		f := describe(t, squitter(0xA05F21, raME(0b11000010000000, 0b0100, 0, 0, 1, 0x4840D6)))
+		assert.Equal(t, "2 | TCAS resolution advisory", f["Subtype"])
+		assert.Equal(t, "11000010000000 | Climb, corrective", f["ARA"])
+		assert.Equal(t, "0100 | do not pass above", f["RAC"])
+		assert.Equal(t, "0 | Active", f["RAT"])
+		assert.Equal(t, "4840D6 | ICAO address", f["Threat"])
+	})
+
+	t.Run("operational status", func(t *testing.T) {
+		f := describe(t, squitter(0x4840D6, 31<<51|2<<13|1<<12|10<<8|3<<4))
+		assert.Equal(t, "31 | Aircraft operational status", f["TC"])
+		assert.Equal(t, "0 | Airborne", f["Subtype"])
+		assert.Equal(t, "2 | DO-260B", f["Version"])
+		assert.Contains(t, f["NIC-A"], "1 |")
+		assert.Equal(t, "10 | Within 10 m", f["NACp"])
+		assert.Equal(t, "3 | At most a 1e-7 chance of leaving the containment radius per flight hour", f["SIL"])
+	})
+
+	t.Run("TIS-B", func(t *testing.T) {
+		// A fine TIS-B airborne position of a track number: DF17's position with CF 2 and the IMF bit set
+		msg, _ := hex.DecodeString("92ABCDEF59C382D690C8AC000000")
+		crc := ModeSCRC(msg[:11])
+		msg[11], msg[12], msg[13] = byte(crc>>16), byte(crc>>8), byte(crc)
+		f := describe(t, hex.EncodeToString(msg))
+		assert.Equal(t, "2 | Fine TIS-B", f["CF"])
+		assert.Equal(t, "ABCDEF | non-ICAO address, e.g. anonymous or a TIS-B track number", f["Address"])
+		assert.Empty(t, f["ICAO"])
+		assert.Equal(t, "38000 ft | barometric", f["Altitude"])
+
+		f = describe(t, "94ABCDEF00000000000000000000")
+		assert.Equal(t, "4 | TIS-B and ADS-R management", f["CF"])
+		assert.Contains(t, f["ME"], "not decoded")
+	})
+
+	t.Run("comm-b selected altitude", func(t *testing.T) {
+		f := describe(t, "A000029C85E42F313000007047D3")
+		assert.Equal(t, "4,0 | Selected vertical intention, inferred", f["BDS"])
+		assert.Equal(t, "3008 ft | selected on the MCP/FCU", f["MCP altitude"])
+		assert.Equal(t, "1020.0 mb", f["Baro setting"])
+	})
+
+	t.Run("comm-b heading and speed", func(t *testing.T) {
+		f := describe(t, "A00004128F39F91A7E27C46ADC21")
+		assert.Equal(t, "6,0 | Heading and speed report, inferred", f["BDS"])
+		assert.Contains(t, f["Heading"], "magnetic")
+		assert.Contains(t, f["Speed"], "indicated airspeed")
+	})
+
+	t.Run("comm-b ambiguous", func(t *testing.T) {
+		f := describe(t, "A000183880200000000000000000")
+		assert.Equal(t, "80200000000000 | Comm-B message, fits more than one register", f["MB"])
+		assert.Empty(t, f["BDS"])
+	})
+
+	t.Run("wrong length", func(t *testing.T) {
+		_, err := DescribeModeS([]byte{0x8D, 0x48})
+		assert.Error(t, err)
+	})
+}
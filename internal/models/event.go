@@ -0,0 +1,25 @@
+package models
+
+import "flight_trmnl/pkg/schema"
+
+// Event types emitted by the station
+const (
+	EventNewAircraft = "new_aircraft" // Aircraft the station has never heard before
+	EventAlert       = "alert"        // Watched aircraft or other user-configured alert
+	EventGeofence    = "geofence"     // Aircraft crossed a configured area boundary
+	EventEmergency   = "emergency"    // Emergency squawk or emergency status broadcast
+	EventAdvisory    = "advisory"     // An aircraft broadcast a TCAS resolution advisory
+	EventMaintenance = "maintenance"  // The receiver looks broken (silent, rate drop, parse errors) or recovered
+	EventRecord      = "record"       // An aircraft set station records (highest, fastest, slowest airborne, farthest)
+	EventDeepDive    = "deep_dive"    // A report on an aircraft heard for the first time, once it has been followed a while
+)
+
+// Event severities, lowest to highest
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// Event is something noteworthy the station observed, persisted so it can be reviewed later
+type Event = schema.Event
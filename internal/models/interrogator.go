@@ -0,0 +1,120 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Interrogator identifies the secondary surveillance radar a DF11 all-call reply answers. The reply's parity is
+// overlaid with the interrogator's code: a 3-bit code label, 0 for an interrogator identifier (II) or 1 to 4 for a
+// surveillance identifier (SI) in that block of 16, and the 4-bit code.
+type Interrogator struct {
+	Code int  `json:"code"` // II 0-15 or SI 0-63
+	SI   bool `json:"si"`   // The code is a surveillance identifier rather than an interrogator identifier
+}
+
+// String returns the code as radar people write it, e.g. II3 or SI42
+func (i Interrogator) String() string {
+	if i.SI {
+		return fmt.Sprintf("SI%d", i.Code)
+	}
+	return fmt.Sprintf("II%d", i.Code)
+}
+
+// Squitter reports whether the code is II 0, which acquisition squitters carry as they answer no interrogator;
+// replies to Mode A/C/S all-calls, which have no II field, carry it too
+func (i Interrogator) Squitter() bool {
+	return !i.SI && i.Code == 0
+}
+
+// InterrogatorFromResidual returns the interrogator a DF11 reply's parity residual, see ModeSResidual, names;
+// ok is false when the residual can't be a code, so the reply was corrupted in reception
+func InterrogatorFromResidual(residual uint32) (Interrogator, bool) {
+	label, code := int(residual>>4), int(residual&0xF)
+	switch {
+	case label == 0:
+		return Interrogator{Code: code}, true
+	case label <= 4:
+		return Interrogator{Code: (label-1)*16 + code, SI: true}, true
+	}
+	return Interrogator{}, false
+}
+
+// Interrogator returns the interrogator a DF11 all-call reply answers; ok is false for other messages and for
+// corrupted replies
+func (b *BeastMessage) Interrogator() (Interrogator, bool) {
+	if b.DownlinkFormat() != 11 || len(b.Message) != BeastDataLenModeSShort {
+		return Interrogator{}, false
+	}
+	return InterrogatorFromResidual(ModeSResidual(b.Message))
+}
+
+// InterrogatorActivity is how much one interrogator was heard through the replies it elicited
+type InterrogatorActivity struct {
+	Interrogator
+	Name      string    `json:"name"` // e.g. II3
+	Replies   int64     `json:"replies"`
+	Aircraft  int       `json:"aircraft"` // Distinct aircraft replying to it
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// interrogatorCount accumulates one interrogator's activity
+type interrogatorCount struct {
+	InterrogatorActivity
+	aircraft map[string]struct{}
+}
+
+// maxInterrogatorAircraft bounds the addresses kept per interrogator; past it the count stops growing
+const maxInterrogatorAircraft = 10000
+
+// InterrogatorCounter tallies the interrogators heard in DF11 all-call replies, to show which radars cover the
+// area. Corrupted replies aren't counted: their code is as unreliable as their address.
+// It is not safe for concurrent use.
+type InterrogatorCounter struct {
+	counts map[Interrogator]*interrogatorCount
+}
+
+// NewInterrogatorCounter creates an empty counter
+func NewInterrogatorCounter() *InterrogatorCounter {
+	return &InterrogatorCounter{counts: make(map[Interrogator]*interrogatorCount)}
+}
+
+// Add counts a message received at the given time if it is an intact all-call reply
+func (c *InterrogatorCounter) Add(msg *BeastMessage, at time.Time) {
+	in, ok := msg.Interrogator()
+	if !ok {
+		return
+	}
+	count, ok := c.counts[in]
+	if !ok {
+		count = &interrogatorCount{
+			InterrogatorActivity: InterrogatorActivity{Interrogator: in, Name: in.String(), FirstSeen: at},
+			aircraft:             make(map[string]struct{}),
+		}
+		c.counts[in] = count
+	}
+	count.Replies++
+	count.LastSeen = at
+	if len(count.aircraft) < maxInterrogatorAircraft {
+		count.aircraft[msg.ICAO] = struct{}{}
+	}
+}
+
+// Activity returns each interrogator heard, the busiest first
+func (c *InterrogatorCounter) Activity() []InterrogatorActivity {
+	activity := make([]InterrogatorActivity, 0, len(c.counts))
+	for _, count := range c.counts {
+		a := count.InterrogatorActivity
+		a.Aircraft = len(count.aircraft)
+		activity = append(activity, a)
+	}
+	sort.Slice(activity, func(i, j int) bool {
+		if activity[i].Replies != activity[j].Replies {
+			return activity[i].Replies > activity[j].Replies
+		}
+		return activity[i].Name < activity[j].Name
+	})
+	return activity
+}
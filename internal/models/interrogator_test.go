@@ -0,0 +1,62 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterrogatorFromResidual(t *testing.T) {
+	tests := []struct {
+		residual uint32
+		want     string
+		ok       bool
+	}{
+		{0x00, "II0", true},
+		{0x0F, "II15", true},
+		{0x10, "SI0", true},
+		{0x2A, "SI26", true},
+		{0x4F, "SI63", true},
+		{0x50, "", false}, // Code labels 5 to 7 are unused
+		{0x7F, "", false},
+		{0x80, "", false},
+		{0x4840D6, "", false},
+	}
+	for _, tt := range tests {
+		in, ok := InterrogatorFromResidual(tt.residual)
+		require.Equal(t, tt.ok, ok, "residual %X", tt.residual)
+		if ok {
+			assert.Equal(t, tt.want, in.String(), "residual %X", tt.residual)
+		}
+	}
+}
+
+func TestInterrogatorCounter(t *testing.T) {
+	reply := func(icao string, address, code uint32) *BeastMessage {
+		return &BeastMessage{MessageTypeCode: BeastTypeModeSShort, Message: allCallReply(address, code), ICAO: icao}
+	}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	counter := NewInterrogatorCounter()
+	counter.Add(reply("4840D6", 0x4840D6, 3), start)
+	counter.Add(reply("4840D7", 0x4840D7, 3), start.Add(time.Second))
+	counter.Add(reply("4840D6", 0x4840D6, 3), start.Add(2*time.Second))
+	counter.Add(reply("4840D6", 0x4840D6, 0x12), start)
+	counter.Add(reply("4840D6", 0x4840D6, 0x60), start) // Corrupted
+	counter.Add(&BeastMessage{MessageTypeCode: BeastTypeModeSLong, Message: []byte{0x8D, 0x48, 0x40, 0xD6}}, start)
+
+	activity := counter.Activity()
+	require.Len(t, activity, 2)
+	assert.Equal(t, InterrogatorActivity{
+		Interrogator: Interrogator{Code: 3},
+		Name:         "II3",
+		Replies:      3,
+		Aircraft:     2,
+		FirstSeen:    start,
+		LastSeen:     start.Add(2 * time.Second),
+	}, activity[0])
+	assert.Equal(t, "SI2", activity[1].Name)
+	assert.True(t, activity[1].SI)
+	assert.Equal(t, int64(1), activity[1].Replies)
+}
@@ -0,0 +1,166 @@
+package models
+
+import (
+	"math"
+	"sort"
+
+	"flight_trmnl/internal/decoder"
+)
+
+// DFModeAC is the pseudo downlink format Mode A/C replies are counted under, they have no DF field
+const DFModeAC = -1
+
+// downlinkFormatNames describes the Mode S downlink formats a receiver hears
+var downlinkFormatNames = map[int]string{
+	DFModeAC: "Mode A/C reply",
+	0:        "Short air-air surveillance (ACAS)",
+	4:        "Surveillance, altitude reply",
+	5:        "Surveillance, identity reply",
+	11:       "All-call reply",
+	16:       "Long air-air surveillance (ACAS)",
+	17:       "Extended squitter (ADS-B)",
+	18:       "Extended squitter, non-transponder (TIS-B/ADS-R)",
+	19:       "Military extended squitter",
+	20:       "Comm-B, altitude reply",
+	21:       "Comm-B, identity reply",
+	24:       "Comm-D extended length message",
+}
+
+// DownlinkFormatName describes a downlink format, e.g. "All-call reply" for DF11
+func DownlinkFormatName(df int) string {
+	if name, ok := downlinkFormatNames[df]; ok {
+		return name
+	}
+	return "Unassigned"
+}
+
+// TypeCodeName describes an ADS-B type code carried by DF17/DF18 extended squitters
+func TypeCodeName(tc int) string {
+	switch {
+	case tc == 0:
+		return "No position information"
+	case tc >= 1 && tc <= 4:
+		return "Aircraft identification"
+	case tc >= 5 && tc <= 8:
+		return "Surface position"
+	case tc >= 9 && tc <= 18:
+		return "Airborne position (barometric altitude)"
+	case tc == 19:
+		return "Airborne velocity"
+	case tc >= 20 && tc <= 22:
+		return "Airborne position (GNSS height)"
+	case tc == 23:
+		return "Test message"
+	case tc == 24:
+		return "Surface system status"
+	case tc == 28:
+		return "Aircraft status"
+	case tc == 29:
+		return "Target state and status"
+	case tc == 31:
+		return "Aircraft operational status"
+	default:
+		return "Reserved"
+	}
+}
+
+// TypeCode returns the ADS-B type code of a DF17/DF18 extended squitter, or -1 for other messages and DF18 coarse
+// TIS-B and management messages, whose ME field has no type code
+// The type code is the first 5 bits of the 56-bit ME field, which starts at byte 4.
+func (b *BeastMessage) TypeCode() int {
+	if b.DownlinkFormat() < 0 || !decoder.IsExtendedSquitter(b.Message) {
+		return -1
+	}
+	return int(b.Message[4] >> 3)
+}
+
+// MessageTypeCount is the number of messages of one downlink format or type code
+type MessageTypeCount struct {
+	Code    int     `json:"code"`
+	Name    string  `json:"name"`
+	Count   int64   `json:"count"`
+	Percent float64 `json:"percent"` // Share of the parent total (all messages for formats, the format for type codes)
+}
+
+// DownlinkFormatCount is the number of messages of one downlink format, by type code for extended squitters
+type DownlinkFormatCount struct {
+	MessageTypeCount
+	TypeCodes []MessageTypeCount `json:"type_codes,omitempty"`
+}
+
+// MessageTypeBreakdown is the share of each downlink format and type code in a set of messages
+type MessageTypeBreakdown struct {
+	Total   int64                 `json:"total"`
+	Formats []DownlinkFormatCount `json:"formats"`
+}
+
+type messageTypeKey struct {
+	df, tc int
+}
+
+// MessageTypeCounter counts messages by downlink format and ADS-B type code
+// It is not safe for concurrent use.
+type MessageTypeCounter struct {
+	counts map[messageTypeKey]int64
+}
+
+// NewMessageTypeCounter creates an empty counter
+func NewMessageTypeCounter() *MessageTypeCounter {
+	return &MessageTypeCounter{counts: make(map[messageTypeKey]int64)}
+}
+
+// Add counts one message
+func (c *MessageTypeCounter) Add(msg *BeastMessage) {
+	c.AddCount(msg.DownlinkFormat(), msg.TypeCode(), 1)
+}
+
+// AddCount counts n messages of a downlink format and type code (-1 when the format has none)
+// DF24 to DF31 are all Comm-D, only the first two bits identify the format.
+func (c *MessageTypeCounter) AddCount(df, tc int, n int64) {
+	if df > 24 {
+		df = 24
+	}
+	c.counts[messageTypeKey{df: df, tc: tc}] += n
+}
+
+// Breakdown returns the counts by downlink format, each with its type codes, in code order
+func (c *MessageTypeCounter) Breakdown() MessageTypeBreakdown {
+	formats := make(map[int]*DownlinkFormatCount)
+	var total int64
+	for key, count := range c.counts {
+		total += count
+		format, ok := formats[key.df]
+		if !ok {
+			format = &DownlinkFormatCount{MessageTypeCount: MessageTypeCount{Code: key.df, Name: DownlinkFormatName(key.df)}}
+			formats[key.df] = format
+		}
+		format.Count += count
+		if key.tc >= 0 {
+			format.TypeCodes = append(format.TypeCodes, MessageTypeCount{Code: key.tc, Name: TypeCodeName(key.tc), Count: count})
+		}
+	}
+
+	breakdown := MessageTypeBreakdown{Total: total, Formats: make([]DownlinkFormatCount, 0, len(formats))}
+	for _, format := range formats {
+		format.Percent = percentOf(format.Count, total)
+		for i := range format.TypeCodes {
+			format.TypeCodes[i].Percent = percentOf(format.TypeCodes[i].Count, format.Count)
+		}
+		sort.Slice(format.TypeCodes, func(i, j int) bool {
+			return format.TypeCodes[i].Code < format.TypeCodes[j].Code
+		})
+		breakdown.Formats = append(breakdown.Formats, *format)
+	}
+	sort.Slice(breakdown.Formats, func(i, j int) bool {
+		return breakdown.Formats[i].Code < breakdown.Formats[j].Code
+	})
+	return breakdown
+}
+
+// percentOf returns part as a percentage of whole, rounded to one decimal
+func percentOf(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return math.Round(float64(part)*1000/float64(whole)) / 10
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBeastMessage_TypeCode(t *testing.T) {
+	identification := &BeastMessage{
+		MessageTypeCode: BeastTypeModeSLong,
+		Message:         []byte{0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71, 0xC2, 0xD7, 0x20, 0x00, 0x00, 0x00},
+	}
+	assert.Equal(t, 17, identification.DownlinkFormat())
+	assert.Equal(t, 4, identification.TypeCode())
+
+	allCall := &BeastMessage{
+		MessageTypeCode: BeastTypeModeSShort,
+		Message:         []byte{0x5D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3},
+	}
+	assert.Equal(t, -1, allCall.TypeCode())
+
+	modeAC := &BeastMessage{MessageTypeCode: BeastTypeModeAC, Message: []byte{0x8D, 0x48}}
+	assert.Equal(t, -1, modeAC.TypeCode())
+}
+
+func TestMessageTypeCounter(t *testing.T) {
+	counter := NewMessageTypeCounter()
+	counter.Add(&BeastMessage{
+		MessageTypeCode: BeastTypeModeSLong,
+		Message:         []byte{0x8D, 0x48, 0x40, 0xD6, 0x20, 0x2C, 0xC3, 0x71, 0xC2, 0xD7, 0x20, 0x00, 0x00, 0x00},
+	})
+	counter.Add(&BeastMessage{MessageTypeCode: BeastTypeModeAC, Message: []byte{0x12, 0x34}})
+	counter.AddCount(17, 11, 2)
+	counter.AddCount(11, -1, 4)
+	counter.AddCount(28, -1, 2) // Comm-D, counted as DF24
+
+	breakdown := counter.Breakdown()
+	assert.Equal(t, int64(10), breakdown.Total)
+	require.Len(t, breakdown.Formats, 4)
+
+	assert.Equal(t, DFModeAC, breakdown.Formats[0].Code)
+	assert.Equal(t, "Mode A/C reply", breakdown.Formats[0].Name)
+	assert.Equal(t, 11, breakdown.Formats[1].Code)
+	assert.Equal(t, 40.0, breakdown.Formats[1].Percent)
+	assert.Empty(t, breakdown.Formats[1].TypeCodes)
+
+	es := breakdown.Formats[2]
+	assert.Equal(t, 17, es.Code)
+	assert.Equal(t, int64(3), es.Count)
+	require.Len(t, es.TypeCodes, 2)
+	assert.Equal(t, 4, es.TypeCodes[0].Code)
+	assert.Equal(t, "Aircraft identification", es.TypeCodes[0].Name)
+	assert.Equal(t, 33.3, es.TypeCodes[0].Percent)
+	assert.Equal(t, 11, es.TypeCodes[1].Code)
+	assert.Equal(t, "Airborne position (barometric altitude)", es.TypeCodes[1].Name)
+
+	assert.Equal(t, 24, breakdown.Formats[3].Code)
+}
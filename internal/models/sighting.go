@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Sighting summarizes when an aircraft was seen by the station
+// Rows are merged by ICAO, so the same aircraft seen live and in imported history collapses into one record
+type Sighting struct {
+	ICAO         string    `json:"icao"`          // 6 hex digit ICAO address
+	FirstSeen    time.Time `json:"first_seen"`    // Earliest time the aircraft was seen
+	LastSeen     time.Time `json:"last_seen"`     // Latest time the aircraft was seen
+	MessageCount int64     `json:"message_count"` // Number of messages received from the aircraft
+	Callsign     string    `json:"callsign"`      // Last known callsign, if any
+	Category     string    `json:"category"`      // Last reported ADS-B emitter category, e.g. A3; empty when unknown
+	Source       string    `json:"source"`        // Where the sighting came from (live, readsb, basestation)
+}
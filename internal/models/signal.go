@@ -0,0 +1,21 @@
+package models
+
+import "math"
+
+// MinDBFS is the signal level reported for a signal byte of 0, the floor dump1090 reports too
+const MinDBFS = -49.5
+
+// SignalDBFS converts a Beast signal byte to dBFS, decibels below the receiver's full scale, as dump1090 does:
+// the byte is the signal's amplitude with 255 at full scale, so 255 is 0 dBFS and halving it takes off about 6 dB.
+// The result is rounded to 0.1 dB.
+func SignalDBFS(level uint8) float64 {
+	if level == 0 {
+		return MinDBFS
+	}
+	return math.Round(200*math.Log10(float64(level)/255)) / 10
+}
+
+// SignalDBFS returns the message's signal level in dBFS, see SignalDBFS
+func (m *BeastMessage) SignalDBFS() float64 {
+	return SignalDBFS(m.SignalLevel)
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// AircraftTag marks an aircraft listed in a special aircraft dataset (e.g. plane-alert-db)
+// An aircraft may carry one tag per list it appears in.
+type AircraftTag struct {
+	ICAO         string    `json:"icao"`         // 6 hex digit ICAO address, uppercase
+	Source       string    `json:"source"`       // Name of the list the tag was imported from
+	Registration string    `json:"registration"` // Registration as given by the list
+	Operator     string    `json:"operator"`
+	Type         string    `json:"type"`     // Type description as given by the list
+	Group        string    `json:"group"`    // Civ, Mil, Pol, or Gov in plane-alert-db
+	Category     string    `json:"category"` // e.g. "Dictator Alert", "Hired Gun"
+	Tags         []string  `json:"tags"`     // Free-form tags given by the list
+	Link         string    `json:"link,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"` // When the list was last imported
+}
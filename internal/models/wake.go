@@ -0,0 +1,50 @@
+package models
+
+// Wake turbulence categories, from the ADS-B emitter category. The weight classes follow FAA approach
+// separation: A1 and A2 are small, A3 large, A4 the Boeing 757 class, and A5 heavy.
+const (
+	WakeSmall = "small"
+	WakeLarge = "large"
+	WakeB757  = "b757"
+	WakeHeavy = "heavy"
+)
+
+var wakeCategories = map[string]string{
+	"A1": WakeSmall,
+	"A2": WakeSmall,
+	"A3": WakeLarge,
+	"A4": WakeB757,
+	"A5": WakeHeavy,
+}
+
+// WakeCategory returns the wake turbulence category of an emitter category, empty for those without one such as
+// rotorcraft, gliders, and unknown categories
+func WakeCategory(category string) string {
+	return wakeCategories[category]
+}
+
+// MinimumSpacing is the radar separation in nautical miles between two aircraft on final approach: 3 NM, or
+// more behind a heavy or 757 and for a small aircraft behind a large one (FAA JO 7110.65, 5-5-4).
+// Unknown categories get the standard 3 NM.
+func MinimumSpacing(leader, follower string) float64 {
+	switch leader {
+	case WakeHeavy:
+		switch follower {
+		case WakeHeavy:
+			return 4
+		case WakeSmall:
+			return 6
+		case WakeLarge, WakeB757:
+			return 5
+		}
+	case WakeB757:
+		if follower == WakeSmall {
+			return 5
+		}
+	case WakeLarge:
+		if follower == WakeSmall {
+			return 4
+		}
+	}
+	return 3
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMinimumSpacing(t *testing.T) {
+	tests := []struct {
+		leader, follower string
+		want             float64
+	}{
+		{"A5", "A5", 4},
+		{"A5", "A3", 5},
+		{"A5", "A4", 5},
+		{"A5", "A1", 6},
+		{"A4", "A2", 5},
+		{"A4", "A3", 3},
+		{"A3", "A1", 4},
+		{"A3", "A3", 3},
+		{"A1", "A5", 3},
+		{"A7", "A1", 3}, // Rotorcraft have no wake category
+		{"", "", 3},
+	}
+
+	for _, tt := range tests {
+		got := MinimumSpacing(WakeCategory(tt.leader), WakeCategory(tt.follower))
+		assert.Equal(t, tt.want, got, "%s behind %s", tt.follower, tt.leader)
+	}
+}
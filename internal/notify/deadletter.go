@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// DeadLetter is one undeliverable payload as written to the dead-letter log
+type DeadLetter struct {
+	Time     time.Time       `json:"time"`
+	Target   string          `json:"target"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+// DeadLetterLog appends undeliverable payloads to a JSON lines file for inspection or manual replay
+type DeadLetterLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewDeadLetterLog(path string) *DeadLetterLog {
+	return &DeadLetterLog{path: path}
+}
+
+// Write appends one dead letter; the file is opened per write since failures should be rare
+func (l *DeadLetterLog) Write(target string, attempts int, cause error, payload []byte) error {
+	line, err := json.Marshal(DeadLetter{
+		Time:     time.Now().UTC(),
+		Target:   target,
+		Attempts: attempts,
+		Error:    cause.Error(),
+		Payload:  payload,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode dead letter: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open dead-letter log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write dead-letter log: %w", err)
+	}
+	return nil
+}
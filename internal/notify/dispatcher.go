@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"flight_trmnl/internal/events"
+	"flight_trmnl/internal/models"
+)
+
+// severityRank orders severities for minimum severity filters
+var severityRank = map[string]int{
+	models.SeverityInfo:     0,
+	models.SeverityWarning:  1,
+	models.SeverityCritical: 2,
+}
+
+// Target is one notification destination and the events it wants
+type Target struct {
+	Name        string
+	Types       map[string]bool // Event types to send, nil for all
+	MinSeverity string          // Lowest severity to send, empty for all
+	Sender      Sender
+	Webhook     *Webhook // Delivery stats source when the sender is (or wraps) a webhook
+}
+
+// Match reports whether the target wants an event
+func (t *Target) Match(event *models.Event) bool {
+	if t.Types != nil && !t.Types[event.Type] {
+		return false
+	}
+	return severityRank[event.Severity] >= severityRank[t.MinSeverity]
+}
+
+// Dispatcher sends events from the bus to every matching target
+// Each target has its own queue and worker so a receiver stuck in retries doesn't delay the others.
+type Dispatcher struct {
+	targets []*Target
+	sub     *events.Subscription
+}
+
+// NewDispatcher subscribes to the bus immediately so no events are missed before Start
+func NewDispatcher(bus *events.Bus, targets []*Target) *Dispatcher {
+	return &Dispatcher{targets: targets, sub: bus.Subscribe(1000)}
+}
+
+// Start dispatches events until the context is cancelled
+// This method blocks until every target worker has stopped.
+func (d *Dispatcher) Start(ctx context.Context) error {
+	defer d.sub.Unsubscribe()
+
+	queues := make([]chan *models.Event, len(d.targets))
+	var wg sync.WaitGroup
+	for i, target := range d.targets {
+		queues[i] = make(chan *models.Event, 100)
+		wg.Add(1)
+		go func(target *Target, queue <-chan *models.Event) {
+			defer wg.Done()
+			for event := range queue {
+				if err := target.Sender.Send(ctx, []*models.Event{event}); err != nil {
+					slog.Warn("Notification failed", "target", target.Name, "type", event.Type, "error", err)
+				}
+			}
+		}(target, queues[i])
+	}
+	defer func() {
+		for _, queue := range queues {
+			close(queue)
+		}
+		wg.Wait()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event := <-d.sub.C:
+			for i, target := range d.targets {
+				if !target.Match(event) {
+					continue
+				}
+				select {
+				case queues[i] <- event:
+				default:
+					slog.Warn("Notification queue full, dropping event", "target", target.Name, "type", event.Type)
+				}
+			}
+		}
+	}
+}
+
+// Stats returns delivery stats for every webhook target
+func (d *Dispatcher) Stats() []DeliveryStats {
+	stats := make([]DeliveryStats, 0, len(d.targets))
+	for _, target := range d.targets {
+		if target.Webhook != nil {
+			stats = append(stats, target.Webhook.Stats())
+		}
+	}
+	return stats
+}
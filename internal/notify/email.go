@@ -0,0 +1,227 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/models"
+)
+
+// emailTimeout bounds one delivery, from connecting to the server's reply to the message
+const emailTimeout = 30 * time.Second
+
+// EmailOptions configures an Email notifier
+type EmailOptions struct {
+	Addr     string // SMTP server, host:port
+	TLS      bool   // Connect over TLS, e.g. to port 465; otherwise STARTTLS is used when the server offers it
+	Username string // Empty sends without logging in
+	Password string
+	From     string
+	To       []string
+}
+
+// Message is one email; Text is shown by clients that don't show HTML, and HTML may be empty
+type Message struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Email sends events, and reports such as the weekly one, as email over SMTP
+type Email struct {
+	opts EmailOptions
+}
+
+func NewEmail(opts EmailOptions) *Email {
+	return &Email{opts: opts}
+}
+
+// Send emails a batch of events as one message
+func (e *Email) Send(ctx context.Context, events []*models.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	subject := fmt.Sprintf("%d flight_trmnl events", len(events))
+	if len(events) == 1 {
+		subject = events[0].Message
+	}
+	var b strings.Builder
+	for _, event := range events {
+		fmt.Fprintf(&b, "%s  %s  %s", event.Time.Local().Format("2006-01-02 15:04:05"), event.Severity, event.Type)
+		for _, id := range []string{event.ICAO, event.Callsign} {
+			if id != "" {
+				b.WriteString("  " + id)
+			}
+		}
+		b.WriteString("\n" + event.Message + "\n")
+		names := make([]string, 0, len(event.Links))
+		for name := range event.Links {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(&b, "%s: %s\n", name, event.Links[name])
+		}
+		b.WriteString("\n")
+	}
+	return e.SendMessage(ctx, Message{Subject: subject, Text: b.String()})
+}
+
+// SendMessage delivers a message to every recipient
+func (e *Email) SendMessage(ctx context.Context, msg Message) error {
+	body, err := buildEmail(e.opts.From, e.opts.To, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	if err := e.deliver(ctx, body); err != nil {
+		return fmt.Errorf("failed to send email through %s: %w", e.opts.Addr, err)
+	}
+	return nil
+}
+
+// deliver runs one SMTP session, logging in only over TLS
+func (e *Email) deliver(ctx context.Context, body []byte) error {
+	host, _, err := net.SplitHostPort(e.opts.Addr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, emailTimeout)
+	defer cancel()
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	if e.opts.TLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp",
+			e.opts.Addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", e.opts.Addr)
+	}
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok && !e.opts.TLS {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if e.opts.Username != "" {
+		// PlainAuth refuses to send the password unencrypted, except to localhost
+		if err := c.Auth(smtp.PlainAuth("", e.opts.Username, e.opts.Password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(address(e.opts.From)); err != nil {
+		return err
+	}
+	for _, to := range e.opts.To {
+		if err := c.Rcpt(address(to)); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// address is the bare address of a mailbox that may have a name, e.g. "Station <pi@example.com>"
+func address(mailbox string) string {
+	if a, err := mail.ParseAddress(mailbox); err == nil {
+		return a.Address
+	}
+	return mailbox
+}
+
+// buildEmail renders a message with its headers, as multipart/alternative when it has HTML
+func buildEmail(from string, to []string, msg Message, now time.Time) ([]byte, error) {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	domain := "flight_trmnl"
+	if at := strings.LastIndex(address(from), "@"); at >= 0 {
+		domain = address(from)[at+1:]
+	}
+
+	var b bytes.Buffer
+	header := func(name, value string) { fmt.Fprintf(&b, "%s: %s\r\n", name, value) }
+	header("From", from)
+	header("To", strings.Join(to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", "<"+hex.EncodeToString(id)+"@"+domain+">")
+	header("MIME-Version", "1.0")
+
+	if msg.HTML == "" {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		b.WriteString("\r\n")
+		if err := writeQuotedPrintable(&b, msg.Text); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	}
+	parts := multipart.NewWriter(&b)
+	header("Content-Type", "multipart/alternative; boundary="+parts.Boundary())
+	b.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeQuotedPrintable(w, part.body); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// writeQuotedPrintable writes text with CRLF line endings, quoted-printable encoded
+func writeQuotedPrintable(w io.Writer, text string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(text, "\n", "\r\n"))); err != nil {
+		return err
+	}
+	return qp.Close()
+}
@@ -0,0 +1,178 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// smtpSession is what a fake SMTP server was sent in one session
+type smtpSession struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTP accepts one session on a local port and sends what it got on the channel
+func fakeSMTP(t *testing.T) (string, <-chan smtpSession) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	sessions := make(chan smtpSession, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		var session smtpSession
+		reply("220 localhost ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+			case "EHLO":
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case "AUTH":
+				session.auth = strings.TrimPrefix(line, "AUTH PLAIN ")
+				reply("235 Authenticated")
+			case "MAIL":
+				session.from = line
+				reply("250 OK")
+			case "RCPT":
+				session.to = append(session.to, line)
+				reply("250 OK")
+			case "DATA":
+				reply("354 Go ahead")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				session.data = data.String()
+				reply("250 Queued")
+			case "QUIT":
+				reply("221 Bye")
+				sessions <- session
+				return
+			default:
+				reply("502 Unknown " + cmd)
+			}
+		}
+	}()
+	return ln.Addr().String(), sessions
+}
+
+func TestEmail_SendMessage(t *testing.T) {
+	addr, sessions := fakeSMTP(t)
+	email := NewEmail(EmailOptions{
+		Addr:     addr,
+		Username: "pi",
+		Password: "s3cret",
+		From:     "flight_trmnl <pi@example.com>",
+		To:       []string{"ops@example.com", "Jo <jo@example.com>"},
+	})
+	err := email.SendMessage(context.Background(), Message{Subject: "Traffic this week", Text: "Busy week\n", HTML: "<p>Busy week</p>"})
+	require.NoError(t, err)
+
+	var session smtpSession
+	select {
+	case session = <-sessions:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no session")
+	}
+	auth, err := base64.StdEncoding.DecodeString(session.auth)
+	require.NoError(t, err)
+	assert.Equal(t, "\x00pi\x00s3cret", string(auth))
+	assert.Equal(t, "MAIL FROM:<pi@example.com>", strings.SplitN(session.from, " BODY", 2)[0])
+	assert.Equal(t, []string{"RCPT TO:<ops@example.com>", "RCPT TO:<jo@example.com>"}, session.to)
+
+	msg, err := mail.ReadMessage(strings.NewReader(session.data))
+	require.NoError(t, err)
+	assert.Equal(t, "Traffic this week", msg.Header.Get("Subject"))
+	assert.Equal(t, "ops@example.com, Jo <jo@example.com>", msg.Header.Get("To"))
+	assert.Contains(t, msg.Header.Get("Message-ID"), "@example.com>")
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	var types, bodies []string
+	for {
+		part, err := parts.NextPart() // Decodes quoted-printable
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		body, err := io.ReadAll(part)
+		require.NoError(t, err)
+		types = append(types, part.Header.Get("Content-Type"))
+		bodies = append(bodies, string(body))
+	}
+	assert.Equal(t, []string{"text/plain; charset=utf-8", "text/html; charset=utf-8"}, types)
+	assert.Equal(t, []string{"Busy week\r\n", "<p>Busy week</p>"}, bodies)
+}
+
+func TestEmail_Send(t *testing.T) {
+	addr, sessions := fakeSMTP(t)
+	email := NewEmail(EmailOptions{Addr: addr, From: "pi@example.com", To: []string{"ops@example.com"}})
+	events := []*models.Event{{
+		Time:     time.Date(2024, 5, 20, 8, 0, 0, 0, time.UTC),
+		Type:     models.EventEmergency,
+		Severity: models.SeverityCritical,
+		ICAO:     "4840D6",
+		Callsign: "KLM1023",
+		Message:  "KLM1023 squawking 7700",
+		Links:    map[string]string{"fr24": "https://www.flightradar24.com/KLM1023"},
+	}}
+	require.NoError(t, email.Send(context.Background(), events))
+
+	session := <-sessions
+	assert.Empty(t, session.auth, "no login without a username")
+	msg, err := mail.ReadMessage(strings.NewReader(session.data))
+	require.NoError(t, err)
+	assert.Equal(t, "KLM1023 squawking 7700", msg.Header.Get("Subject"), "a single event's message is the subject")
+	assert.Equal(t, "text/plain; charset=utf-8", msg.Header.Get("Content-Type"))
+	body, err := io.ReadAll(msg.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "critical  emergency  4840D6  KLM1023")
+	assert.Contains(t, string(body), "fr24: https://www.flightradar24.com/KLM1023")
+}
+
+func TestEmail_SendFails(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	email := NewEmail(EmailOptions{Addr: addr, From: "pi@example.com", To: []string{"ops@example.com"}})
+	err = email.SendMessage(context.Background(), Message{Subject: "Traffic", Text: "Quiet"})
+	assert.ErrorContains(t, err, "failed to send email through "+addr)
+}
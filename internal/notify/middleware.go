@@ -0,0 +1,176 @@
+package notify
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"flight_trmnl/internal/links"
+	"flight_trmnl/internal/models"
+)
+
+// Middleware wraps a sender to change what reaches it and when
+type Middleware func(next Sender) Sender
+
+// SenderFunc adapts a function to the Sender interface
+type SenderFunc func(ctx context.Context, events []*models.Event) error
+
+func (f SenderFunc) Send(ctx context.Context, events []*models.Event) error {
+	return f(ctx, events)
+}
+
+// Chain wraps sender with middleware; the first middleware sees events first
+func Chain(sender Sender, middleware ...Middleware) Sender {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		sender = middleware[i](sender)
+	}
+	return sender
+}
+
+// Filter passes each event through fn, which may rewrite it or return false to drop it
+// Used to apply the privacy policy before anything else sees the events.
+func Filter(fn func(*models.Event) (*models.Event, bool)) Middleware {
+	return func(next Sender) Sender {
+		return SenderFunc(func(ctx context.Context, events []*models.Event) error {
+			var kept []*models.Event
+			for _, event := range events {
+				if out, ok := fn(event); ok {
+					kept = append(kept, out)
+				}
+			}
+			if len(kept) == 0 {
+				return nil
+			}
+			return next.Send(ctx, kept)
+		})
+	}
+}
+
+// Links adds deep links to the aircraft of each event, last so only events that are sent are looked up
+// Events are copied before links are added, other webhooks share them.
+func Links(generator *links.Generator) Middleware {
+	return func(next Sender) Sender {
+		return SenderFunc(func(ctx context.Context, events []*models.Event) error {
+			out := make([]*models.Event, len(events))
+			for i, event := range events {
+				out[i] = event
+				if event.ICAO == "" {
+					continue
+				}
+				registration, _ := event.Data["registration"].(string)
+				if l := generator.Lookup(ctx, links.Aircraft{ICAO: event.ICAO, Registration: registration, Callsign: event.Callsign}); l != nil {
+					linked := *event
+					linked.Links = l
+					out[i] = &linked
+				}
+			}
+			return next.Send(ctx, out)
+		})
+	}
+}
+
+// Dedupe drops events repeating the type and flight (ICAO and callsign) of one sent within window
+// Prevents an aircraft lingering at the edge of coverage from re-triggering the same alert all afternoon.
+func Dedupe(window time.Duration) Middleware {
+	return func(next Sender) Sender {
+		var mu sync.Mutex
+		lastSent := make(map[string]time.Time)
+
+		return SenderFunc(func(ctx context.Context, events []*models.Event) error {
+			now := time.Now()
+
+			mu.Lock()
+			for key, sent := range lastSent {
+				if now.Sub(sent) >= window {
+					delete(lastSent, key)
+				}
+			}
+			var fresh []*models.Event
+			for _, event := range events {
+				key := event.Type + "|" + event.ICAO + "|" + event.Callsign
+				if _, seen := lastSent[key]; seen {
+					continue
+				}
+				lastSent[key] = now
+				fresh = append(fresh, event)
+			}
+			mu.Unlock()
+
+			if len(fresh) == 0 {
+				return nil
+			}
+			return next.Send(ctx, fresh)
+		})
+	}
+}
+
+// Aggregate collects events for window after the first one arrives and sends them as one batch
+// A burst (e.g. a formation flight) then produces one notification instead of one per aircraft.
+func Aggregate(window time.Duration) Middleware {
+	return func(next Sender) Sender {
+		b := &batcher{next: next, window: window}
+		return SenderFunc(func(ctx context.Context, events []*models.Event) error {
+			b.add(ctx, events)
+			return nil
+		})
+	}
+}
+
+// Digest holds events at or below maxSeverity and sends them together every interval
+// More severe events pass straight through so emergencies are never delayed.
+func Digest(interval time.Duration, maxSeverity string) Middleware {
+	return func(next Sender) Sender {
+		b := &batcher{next: next, window: interval}
+		return SenderFunc(func(ctx context.Context, events []*models.Event) error {
+			var urgent []*models.Event
+			for _, event := range events {
+				if severityRank[event.Severity] <= severityRank[maxSeverity] {
+					b.add(ctx, []*models.Event{event})
+				} else {
+					urgent = append(urgent, event)
+				}
+			}
+			if len(urgent) == 0 {
+				return nil
+			}
+			return next.Send(ctx, urgent)
+		})
+	}
+}
+
+// batcher accumulates events and sends them once window has passed since the first pending event
+// Events still pending at shutdown are dropped, the context they would be sent with is already cancelled.
+type batcher struct {
+	next   Sender
+	window time.Duration
+
+	mu      sync.Mutex
+	pending []*models.Event
+	timer   *time.Timer
+}
+
+func (b *batcher) add(ctx context.Context, events []*models.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pending = append(b.pending, events...)
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.window, func() { b.flush(ctx) })
+	}
+}
+
+func (b *batcher) flush(ctx context.Context) {
+	b.mu.Lock()
+	events := b.pending
+	b.pending = nil
+	b.timer = nil
+	b.mu.Unlock()
+
+	if len(events) == 0 || ctx.Err() != nil {
+		return
+	}
+	if err := b.next.Send(ctx, events); err != nil {
+		slog.Warn("Failed to send batched notification", "events", len(events), "error", err)
+	}
+}
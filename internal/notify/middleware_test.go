@@ -0,0 +1,139 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/links"
+	"flight_trmnl/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchRecorder records each batch a sender receives
+type batchRecorder struct {
+	mu      sync.Mutex
+	batches [][]*models.Event
+}
+
+func (r *batchRecorder) Send(ctx context.Context, events []*models.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, events)
+	return nil
+}
+
+func (r *batchRecorder) sizes() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sizes []int
+	for _, b := range r.batches {
+		sizes = append(sizes, len(b))
+	}
+	return sizes
+}
+
+func send(t *testing.T, s Sender, events ...*models.Event) {
+	t.Helper()
+	require.NoError(t, s.Send(context.Background(), events))
+}
+
+func TestDedupe(t *testing.T) {
+	rec := &batchRecorder{}
+	sender := Dedupe(50 * time.Millisecond)(rec)
+
+	alert := &models.Event{Type: models.EventAlert, ICAO: "A1B2C3", Callsign: "UAL1"}
+	send(t, sender, alert)
+	send(t, sender, alert)
+	send(t, sender, &models.Event{Type: models.EventAlert, ICAO: "A1B2C3", Callsign: "UAL2"})
+	send(t, sender, &models.Event{Type: models.EventEmergency, ICAO: "A1B2C3", Callsign: "UAL1"})
+	assert.Equal(t, []int{1, 1, 1}, rec.sizes(), "only exact repeats are dropped")
+
+	time.Sleep(60 * time.Millisecond)
+	send(t, sender, alert)
+	assert.Equal(t, []int{1, 1, 1, 1}, rec.sizes(), "repeats are sent again after the window")
+}
+
+func TestAggregate(t *testing.T) {
+	rec := &batchRecorder{}
+	sender := Aggregate(30 * time.Millisecond)(rec)
+
+	for i := 0; i < 4; i++ {
+		send(t, sender, &models.Event{Type: models.EventNewAircraft})
+	}
+	assert.Empty(t, rec.sizes())
+
+	require.Eventually(t, func() bool { return len(rec.sizes()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []int{4}, rec.sizes())
+
+	send(t, sender, &models.Event{Type: models.EventNewAircraft})
+	require.Eventually(t, func() bool { return len(rec.sizes()) == 2 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []int{4, 1}, rec.sizes())
+}
+
+func TestDigest_UrgentEventsPassThrough(t *testing.T) {
+	rec := &batchRecorder{}
+	sender := Digest(30*time.Millisecond, models.SeverityInfo)(rec)
+
+	send(t, sender,
+		&models.Event{Type: models.EventNewAircraft, Severity: models.SeverityInfo},
+		&models.Event{Type: models.EventEmergency, Severity: models.SeverityCritical},
+	)
+	send(t, sender, &models.Event{Type: models.EventNewAircraft, Severity: models.SeverityInfo})
+
+	require.Len(t, rec.sizes(), 1, "the emergency is sent immediately")
+	assert.Equal(t, models.EventEmergency, rec.batches[0][0].Type)
+
+	require.Eventually(t, func() bool { return len(rec.sizes()) == 2 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []int{1, 2}, rec.sizes())
+}
+
+func TestChain_Order(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next Sender) Sender {
+			return SenderFunc(func(ctx context.Context, events []*models.Event) error {
+				order = append(order, name)
+				return next.Send(ctx, events)
+			})
+		}
+	}
+
+	sender := Chain(&batchRecorder{}, mark("first"), mark("second"))
+	send(t, sender, &models.Event{})
+	assert.Equal(t, []string{"first", "second"}, order)
+}
+
+func TestFilter(t *testing.T) {
+	rec := &batchRecorder{}
+	sender := Filter(func(e *models.Event) (*models.Event, bool) {
+		return e, e.ICAO != "A00001"
+	})(rec)
+
+	send(t, sender, &models.Event{ICAO: "A00001"})
+	send(t, sender, &models.Event{ICAO: "A00001"}, &models.Event{ICAO: "A00002"})
+	assert.Equal(t, []int{1}, rec.sizes(), "batches left empty are not sent")
+	assert.Equal(t, "A00002", rec.batches[0][0].ICAO)
+}
+
+func TestLinks(t *testing.T) {
+	generator, err := links.New(links.Options{Trackers: []string{"adsbexchange", "fr24"}, BaseURL: "http://pi.local:8080"})
+	require.NoError(t, err)
+	rec := &batchRecorder{}
+	sender := Links(generator)(rec)
+
+	alert := &models.Event{ICAO: "4840D6", Data: map[string]any{"registration": "PH-BXA"}}
+	send(t, sender, alert, &models.Event{ICAO: "~A1B2C3"}, &models.Event{Type: models.EventMaintenance})
+	require.Len(t, rec.batches, 1)
+	assert.Equal(t, map[string]string{
+		"local":        "http://pi.local:8080/aircraft.html?icao=4840d6",
+		"adsbexchange": "https://globe.adsbexchange.com/?icao=4840d6",
+		"fr24":         "https://www.flightradar24.com/data/aircraft/PH-BXA",
+	}, rec.batches[0][0].Links)
+	assert.Nil(t, alert.Links, "the shared event is left alone")
+	assert.Nil(t, rec.batches[0][1].Links, "pseudonyms of blocked aircraft aren't linked")
+	assert.Nil(t, rec.batches[0][2].Links)
+}
@@ -0,0 +1,220 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/secrets"
+	"flight_trmnl/pkg/schema"
+)
+
+// Headers sent with every webhook delivery
+const (
+	HeaderDelivery  = "X-Flight-Trmnl-Delivery"  // Unique per delivery, identical across retries so receivers can dedupe
+	HeaderTimestamp = "X-Flight-Trmnl-Timestamp" // Unix seconds when the attempt was signed
+	HeaderSignature = "X-Flight-Trmnl-Signature" // sha256=<hex HMAC of "timestamp.body">, only when a secret is set
+)
+
+// Sender delivers a batch of events somewhere
+type Sender interface {
+	Send(ctx context.Context, events []*models.Event) error
+}
+
+// Payload is the JSON body posted to webhooks
+type Payload = schema.WebhookPayload
+
+// RetryPolicy controls redelivery of failed webhook requests with exponential backoff
+type RetryPolicy struct {
+	MaxAttempts    int // Total attempts including the first
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// backoff returns the wait before the given retry (1 for the first retry)
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < retry && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxBackoff)
+}
+
+// DeliveryStats counts deliveries to one webhook
+type DeliveryStats struct {
+	Name         string    `json:"name"`
+	Delivered    int64     `json:"delivered"`     // Payloads accepted by the receiver
+	Retries      int64     `json:"retries"`       // Attempts after the first
+	DeadLettered int64     `json:"dead_lettered"` // Payloads given up on and written to the dead-letter log
+	SuccessRate  float64   `json:"success_rate"`  // Delivered / (Delivered + DeadLettered)
+	LastError    string    `json:"last_error,omitempty"`
+	LastSuccess  time.Time `json:"last_success,omitempty"`
+}
+
+// Webhook posts signed event payloads to an HTTP endpoint, retrying failures
+type Webhook struct {
+	name       string
+	url        string
+	secret     []byte
+	retry      RetryPolicy
+	deadLetter *DeadLetterLog
+	client     *http.Client
+
+	mu    sync.Mutex
+	stats DeliveryStats
+}
+
+// NewWebhook creates a webhook sender; secret and deadLetter are optional
+func NewWebhook(name, url, secret string, retry RetryPolicy, deadLetter *DeadLetterLog) *Webhook {
+	if retry.MaxAttempts < 1 {
+		retry.MaxAttempts = 1
+	}
+	return &Webhook{
+		name:       name,
+		url:        url,
+		secret:     []byte(secret),
+		retry:      retry,
+		deadLetter: deadLetter,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		stats:      DeliveryStats{Name: name},
+	}
+}
+
+// Name returns the configured webhook name
+func (w *Webhook) Name() string { return w.name }
+
+// Send posts the events, retrying with backoff; payloads that still fail are dead-lettered
+// This method blocks through all retries, callers should run it off the hot path.
+func (w *Webhook) Send(ctx context.Context, events []*models.Event) error {
+	payload := Payload{SchemaVersion: schema.WebhookVersion, Delivery: newDeliveryID(), Events: events}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	var lastErr error
+	attempts := 0
+	for attempts < w.retry.MaxAttempts {
+		if attempts > 0 {
+			select {
+			case <-ctx.Done():
+				lastErr = errors.Join(lastErr, ctx.Err())
+			case <-time.After(w.retry.backoff(attempts)):
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			w.count(func(s *DeliveryStats) { s.Retries++ })
+		}
+
+		attempts++
+		retryable, err := w.post(ctx, payload.Delivery, body)
+		if err == nil {
+			w.count(func(s *DeliveryStats) {
+				s.Delivered++
+				s.LastSuccess = time.Now()
+			})
+			return nil
+		}
+		lastErr = err
+		slog.Debug("Webhook attempt failed", "webhook", w.name, "attempt", attempts, "error", err)
+		if !retryable {
+			break
+		}
+	}
+
+	w.count(func(s *DeliveryStats) {
+		s.DeadLettered++
+		s.LastError = lastErr.Error()
+	})
+	if w.deadLetter != nil {
+		if err := w.deadLetter.Write(w.name, attempts, lastErr, body); err != nil {
+			slog.Error("Failed to write dead letter", "webhook", w.name, "error", err)
+		}
+	}
+	return fmt.Errorf("webhook %s failed after %d attempt(s): %w", w.name, attempts, lastErr)
+}
+
+// post makes one delivery attempt; retryable reports whether the failure may succeed later
+func (w *Webhook) post(ctx context.Context, delivery string, body []byte) (retryable bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", secrets.RedactError(err))
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDelivery, delivery)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if len(w.secret) > 0 {
+		req.Header.Set(HeaderSignature, Sign(w.secret, timestamp, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("request failed: %w", secrets.RedactError(err))
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // Drain so the connection can be reused
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("receiver returned status %d", resp.StatusCode)
+	default:
+		// Other client errors won't fix themselves by retrying
+		return false, fmt.Errorf("receiver returned status %d", resp.StatusCode)
+	}
+}
+
+// Stats returns the delivery counters
+func (w *Webhook) Stats() DeliveryStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	stats := w.stats
+	if total := stats.Delivered + stats.DeadLettered; total > 0 {
+		stats.SuccessRate = float64(stats.Delivered) / float64(total)
+	}
+	return stats
+}
+
+func (w *Webhook) count(update func(*DeliveryStats)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	update(&w.stats)
+}
+
+// Sign computes the signature header value for a payload
+// Receivers recompute it over the raw body and compare with hmac.Equal, and should reject stale timestamps.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature header value against a payload
+func Verify(secret []byte, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+func newDeliveryID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
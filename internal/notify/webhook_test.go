@@ -0,0 +1,164 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/events"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/pkg/schema"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fastRetry = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 10 * time.Second}
+	assert.Equal(t, time.Second, p.backoff(1))
+	assert.Equal(t, 2*time.Second, p.backoff(2))
+	assert.Equal(t, 8*time.Second, p.backoff(4))
+	assert.Equal(t, 10*time.Second, p.backoff(5))
+	assert.Equal(t, 10*time.Second, p.backoff(50))
+}
+
+func TestWebhook_SignsAndRetries(t *testing.T) {
+	secret := "s3cret"
+	var attempts atomic.Int32
+	var deliveries sync.Map
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.True(t, Verify([]byte(secret), r.Header.Get(HeaderTimestamp), body, r.Header.Get(HeaderSignature)))
+		deliveries.Store(r.Header.Get(HeaderDelivery), true)
+
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var payload Payload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		assert.Equal(t, schema.WebhookVersion, payload.SchemaVersion)
+		assert.Equal(t, "A1B2C3", payload.Events[0].ICAO)
+	}))
+	defer server.Close()
+
+	webhook := NewWebhook("test", server.URL, secret, fastRetry, nil)
+	err := webhook.Send(context.Background(), []*models.Event{{Type: models.EventAlert, ICAO: "A1B2C3"}})
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(3), attempts.Load())
+	count := 0
+	deliveries.Range(func(_, _ any) bool { count++; return true })
+	assert.Equal(t, 1, count, "retries reuse the delivery ID")
+
+	stats := webhook.Stats()
+	assert.Equal(t, int64(1), stats.Delivered)
+	assert.Equal(t, int64(2), stats.Retries)
+	assert.Equal(t, 1.0, stats.SuccessRate)
+}
+
+func TestWebhook_DeadLetters(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "dead.jsonl")
+	deadLetter := NewDeadLetterLog(path)
+
+	webhook := NewWebhook("flaky", server.URL, "", fastRetry, deadLetter)
+	assert.Error(t, webhook.Send(context.Background(), []*models.Event{{Type: models.EventAlert}}))
+	assert.Equal(t, int32(3), attempts.Load())
+
+	// Client errors are not retried
+	gone := NewWebhook("gone", server.URL+"/gone", "", fastRetry, deadLetter)
+	assert.Error(t, gone.Send(context.Background(), []*models.Event{{Type: models.EventAlert}}))
+	assert.Equal(t, int32(4), attempts.Load())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var letters []DeadLetter
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var letter DeadLetter
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &letter))
+		letters = append(letters, letter)
+	}
+	require.Len(t, letters, 2)
+	assert.Equal(t, "flaky", letters[0].Target)
+	assert.Equal(t, 3, letters[0].Attempts)
+	assert.Contains(t, letters[0].Error, "500")
+	assert.Equal(t, 1, letters[1].Attempts)
+
+	stats := webhook.Stats()
+	assert.Equal(t, int64(1), stats.DeadLettered)
+	assert.Equal(t, 0.0, stats.SuccessRate)
+	assert.Contains(t, stats.LastError, "500")
+}
+
+// recordingSender collects sent events
+type recordingSender struct {
+	mu     sync.Mutex
+	events []*models.Event
+}
+
+func (s *recordingSender) Send(ctx context.Context, events []*models.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *recordingSender) types() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var types []string
+	for _, e := range s.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestDispatcher_RoutesByTypeAndSeverity(t *testing.T) {
+	bus := events.NewBus()
+	all := &recordingSender{}
+	critical := &recordingSender{}
+	dispatcher := NewDispatcher(bus, []*Target{
+		{Name: "all", Sender: all},
+		{Name: "critical", Sender: critical, MinSeverity: models.SeverityCritical},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dispatcher.Start(ctx)
+
+	bus.Publish(&models.Event{Type: models.EventNewAircraft})
+	bus.Publish(&models.Event{Type: models.EventEmergency, Severity: models.SeverityCritical})
+
+	require.Eventually(t, func() bool { return len(all.types()) == 2 }, time.Second, 5*time.Millisecond)
+	require.Eventually(t, func() bool { return len(critical.types()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{models.EventEmergency}, critical.types())
+
+	target := &Target{Types: map[string]bool{models.EventGeofence: true}}
+	assert.False(t, target.Match(&models.Event{Type: models.EventAlert}))
+	assert.True(t, target.Match(&models.Event{Type: models.EventGeofence, Severity: models.SeverityInfo}))
+}
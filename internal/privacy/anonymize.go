@@ -0,0 +1,54 @@
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+)
+
+// Anonymizer strips what identifies an airframe from exported records while keeping the traffic statistics
+// Addresses become salted hashes, so the same aircraft keeps the same hash across exports made with one salt, and
+// callsigns, which often are the registration, are dropped.
+type Anonymizer struct {
+	salt []byte
+}
+
+// NewAnonymizer creates an anonymizer keyed with a salt, or with a random one when the salt is empty
+func NewAnonymizer(salt string) *Anonymizer {
+	if salt == "" {
+		random := make([]byte, 16)
+		rand.Read(random)
+		return &Anonymizer{salt: random}
+	}
+	return &Anonymizer{salt: []byte(salt)}
+}
+
+// Alias returns the hash of an address, prefixed with ~ like readsb's non-ICAO addresses
+// It's longer than the live pseudonyms so aircraft in large exports don't collide.
+func (a *Anonymizer) Alias(icao string) string {
+	mac := hmac.New(sha256.New, a.salt)
+	mac.Write([]byte(strings.ToUpper(icao)))
+	return "~" + strings.ToUpper(hex.EncodeToString(mac.Sum(nil))[:12])
+}
+
+// Flight returns an anonymized copy of a flight
+func (a *Anonymizer) Flight(flight *database.Flight) *database.Flight {
+	anonymized := *flight
+	anonymized.ID = 0
+	anonymized.ICAO = a.Alias(flight.ICAO)
+	anonymized.Callsign = ""
+	return &anonymized
+}
+
+// Sighting returns an anonymized copy of a seen aircraft summary
+func (a *Anonymizer) Sighting(sighting *models.Sighting) *models.Sighting {
+	anonymized := *sighting
+	anonymized.ICAO = a.Alias(sighting.ICAO)
+	anonymized.Callsign = ""
+	return &anonymized
+}
@@ -0,0 +1,262 @@
+package privacy
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/tracker"
+)
+
+// Policies for blocked aircraft on one output
+const (
+	PolicyShow      = "show"      // Output blocked aircraft like any other
+	PolicyAnonymize = "anonymize" // Replace the address with a pseudonym and drop identifying fields
+	PolicyExclude   = "exclude"   // Leave blocked aircraft out entirely
+)
+
+// reloadInterval is how often tag-list backed blocklists pick up re-imported lists
+// Kept short so a list imported for the first time after startup takes effect quickly.
+const reloadInterval = time.Minute
+
+// Blocklist holds the aircraft whose owners asked not to be tracked publicly (e.g. FAA LADD)
+// Blocking only affects public-facing outputs, the station still stores everything it hears.
+type Blocklist struct {
+	static map[string]bool
+	lists  []string
+	tags   database.TagRepository
+	salt   []byte
+
+	mu     sync.RWMutex
+	listed map[string]bool
+}
+
+// NewBlocklist creates a blocklist of ICAO addresses plus every aircraft on the named tag lists
+// Pseudonyms are keyed with a random salt per process so they can't be reversed by hashing all 2^24 addresses.
+func NewBlocklist(icaos []string, lists []string, tags database.TagRepository) *Blocklist {
+	static := make(map[string]bool, len(icaos))
+	for _, icao := range icaos {
+		static[strings.ToUpper(icao)] = true
+	}
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	return &Blocklist{static: static, lists: lists, tags: tags, salt: salt, listed: make(map[string]bool)}
+}
+
+// Reload reads the aircraft on the blocking tag lists again
+func (b *Blocklist) Reload() error {
+	if len(b.lists) == 0 {
+		return nil
+	}
+	listed := make(map[string]bool)
+	for _, list := range b.lists {
+		icaos, err := b.tags.SourceICAOs(list)
+		if err != nil {
+			return err
+		}
+		for _, icao := range icaos {
+			listed[icao] = true
+		}
+	}
+
+	b.mu.Lock()
+	b.listed = listed
+	b.mu.Unlock()
+	return nil
+}
+
+// Watch reloads the tag lists periodically until the context is cancelled, picking up scheduled re-imports
+func (b *Blocklist) Watch(ctx context.Context) {
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.Reload(); err != nil {
+				slog.Warn("Failed to reload privacy blocklist", "error", err)
+			}
+		}
+	}
+}
+
+// Contains reports whether an aircraft is blocked
+func (b *Blocklist) Contains(icao string) bool {
+	icao = strings.ToUpper(icao)
+	if b.static[icao] {
+		return true
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.listed[icao]
+}
+
+// Alias returns a stable pseudonym for an address, prefixed with ~ like readsb's non-ICAO addresses
+func (b *Blocklist) Alias(icao string) string {
+	mac := hmac.New(sha256.New, b.salt)
+	mac.Write([]byte(strings.ToUpper(icao)))
+	return "~" + strings.ToUpper(hex.EncodeToString(mac.Sum(nil))[:6])
+}
+
+// Output applies a policy to the records one output publishes
+// A nil Output, or one with PolicyShow, passes every record through unchanged.
+type Output struct {
+	list   *Blocklist
+	policy string
+}
+
+// Output returns the view of the blocklist for one output's policy
+func (b *Blocklist) Output(policy string) *Output {
+	return &Output{list: b, policy: policy}
+}
+
+// Blocked reports whether the output must hide or anonymize an aircraft
+func (o *Output) Blocked(icao string) bool {
+	return o != nil && o.policy != PolicyShow && o.list.Contains(icao)
+}
+
+// State returns the live state to publish, false when the aircraft is excluded
+func (o *Output) State(state tracker.AircraftState) (tracker.AircraftState, bool) {
+	if !o.Blocked(state.ICAO) {
+		return state, true
+	}
+	if o.policy == PolicyExclude {
+		return state, false
+	}
+	state.ICAO = o.list.Alias(state.ICAO)
+	state.Callsign = ""
+	return state, true
+}
+
+// Message returns the stored message to publish, false when excluded
+// Anonymized messages lose their raw bytes, which contain the address.
+func (o *Output) Message(record *database.MessageRecord) (*database.MessageRecord, bool) {
+	if !o.Blocked(record.ICAO) {
+		return record, true
+	}
+	if o.policy == PolicyExclude {
+		return nil, false
+	}
+	anonymized := *record
+	anonymized.ICAO = o.list.Alias(record.ICAO)
+	anonymized.MessageHex = ""
+	return &anonymized, true
+}
+
+// Sighting returns the sighting to publish, false when excluded
+func (o *Output) Sighting(sighting *models.Sighting) (*models.Sighting, bool) {
+	if !o.Blocked(sighting.ICAO) {
+		return sighting, true
+	}
+	if o.policy == PolicyExclude {
+		return nil, false
+	}
+	anonymized := *sighting
+	anonymized.ICAO = o.list.Alias(sighting.ICAO)
+	anonymized.Callsign = ""
+	return &anonymized, true
+}
+
+// Event returns the event to publish, false when excluded
+// Anonymized events keep their type and severity but lose the message and details, which name the aircraft.
+func (o *Output) Event(event *models.Event) (*models.Event, bool) {
+	if event.ICAO == "" || !o.Blocked(event.ICAO) {
+		return event, true
+	}
+	if o.policy == PolicyExclude {
+		return nil, false
+	}
+	alias := o.list.Alias(event.ICAO)
+	anonymized := *event
+	anonymized.ICAO = alias
+	anonymized.Callsign = ""
+	anonymized.Message = event.Type + " for " + alias
+	anonymized.Data = nil
+	return &anonymized, true
+}
+
+// Record returns the station record to publish, false when excluded
+func (o *Output) Record(record *database.StationRecord) (*database.StationRecord, bool) {
+	if !o.Blocked(record.ICAO) {
+		return record, true
+	}
+	if o.policy == PolicyExclude {
+		return nil, false
+	}
+	anonymized := *record
+	anonymized.ICAO = o.list.Alias(record.ICAO)
+	anonymized.Callsign = ""
+	return &anonymized, true
+}
+
+// Flight returns the flight to publish, false when excluded
+func (o *Output) Flight(flight *database.Flight) (*database.Flight, bool) {
+	if !o.Blocked(flight.ICAO) {
+		return flight, true
+	}
+	if o.policy == PolicyExclude {
+		return nil, false
+	}
+	anonymized := *flight
+	anonymized.ICAO = o.list.Alias(flight.ICAO)
+	anonymized.Callsign = ""
+	return &anonymized, true
+}
+
+// LogEntry returns the sighting log entry to publish, false when excluded
+// Anonymized entries keep the aircraft type but lose the registration and operator, which name the airframe.
+func (o *Output) LogEntry(entry *database.LogEntry) (*database.LogEntry, bool) {
+	if !o.Blocked(entry.ICAO) {
+		return entry, true
+	}
+	if o.policy == PolicyExclude {
+		return nil, false
+	}
+	anonymized := *entry
+	anonymized.ICAO = o.list.Alias(entry.ICAO)
+	anonymized.Callsign, anonymized.Registration, anonymized.Operator = "", "", ""
+	return &anonymized, true
+}
+
+// Advisory returns the resolution advisory to publish, false when either aircraft in it is excluded. Anonymizing
+// applies to the aircraft and the threat separately.
+func (o *Output) Advisory(advisory *database.Advisory) (*database.Advisory, bool) {
+	blocked, threatBlocked := o.Blocked(advisory.ICAO), advisory.ThreatICAO != "" && o.Blocked(advisory.ThreatICAO)
+	if !blocked && !threatBlocked {
+		return advisory, true
+	}
+	if o.policy == PolicyExclude {
+		return nil, false
+	}
+	anonymized := *advisory
+	if blocked {
+		anonymized.ICAO = o.list.Alias(advisory.ICAO)
+		anonymized.Callsign = ""
+	}
+	if threatBlocked {
+		anonymized.ThreatICAO = o.list.Alias(advisory.ThreatICAO)
+		anonymized.ThreatCallsign = ""
+	}
+	return &anonymized, true
+}
+
+// Filter applies fn to each record, dropping those it excludes
+func Filter[T any](records []T, fn func(T) (T, bool)) []T {
+	kept := records[:0:0]
+	for _, record := range records {
+		if out, ok := fn(record); ok {
+			kept = append(kept, out)
+		}
+	}
+	return kept
+}
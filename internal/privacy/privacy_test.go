@@ -0,0 +1,129 @@
+package privacy
+
+import (
+	"testing"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/tracker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockTags struct {
+	lists map[string][]string
+}
+
+func (m *mockTags) ReplaceSource(source string, tags []*models.AircraftTag) error { return nil }
+
+func (m *mockTags) Get(icao string) ([]*models.AircraftTag, error) { return nil, nil }
+
+func (m *mockTags) Sources() ([]*database.TagSource, error) { return nil, nil }
+
+func (m *mockTags) SourceICAOs(source string) ([]string, error) { return m.lists[source], nil }
+
+func TestBlocklist(t *testing.T) {
+	tags := &mockTags{lists: map[string][]string{"ladd": {"A11111"}, "other": {"A22222"}}}
+	list := NewBlocklist([]string{"a00001"}, []string{"ladd"}, tags)
+
+	assert.True(t, list.Contains("A00001"), "static entries are case insensitive")
+	assert.False(t, list.Contains("A11111"), "tag lists are loaded by Reload")
+
+	require.NoError(t, list.Reload())
+	assert.True(t, list.Contains("a11111"))
+	assert.False(t, list.Contains("A22222"), "only the configured lists block")
+
+	alias := list.Alias("A00001")
+	assert.Equal(t, alias, list.Alias("a00001"), "aliases are stable")
+	assert.NotEqual(t, alias, list.Alias("A11111"))
+	assert.Len(t, alias, 7)
+	assert.Equal(t, "~", alias[:1])
+}
+
+func TestOutput_Policies(t *testing.T) {
+	list := NewBlocklist([]string{"A00001"}, nil, nil)
+	blocked := tracker.AircraftState{ICAO: "A00001", Messages: 3}
+	open := tracker.AircraftState{ICAO: "A00002"}
+
+	var none *Output
+	state, ok := none.State(blocked)
+	assert.True(t, ok, "a nil output shows everything")
+	assert.Equal(t, "A00001", state.ICAO)
+
+	_, ok = list.Output(PolicyShow).State(blocked)
+	assert.True(t, ok)
+
+	exclude := list.Output(PolicyExclude)
+	_, ok = exclude.State(blocked)
+	assert.False(t, ok)
+	state, ok = exclude.State(open)
+	assert.True(t, ok)
+	assert.Equal(t, "A00002", state.ICAO)
+
+	anonymize := list.Output(PolicyAnonymize)
+	state, ok = anonymize.State(blocked)
+	assert.True(t, ok)
+	assert.Equal(t, list.Alias("A00001"), state.ICAO)
+	assert.Equal(t, int64(3), state.Messages)
+}
+
+func TestOutput_AnonymizeRecords(t *testing.T) {
+	list := NewBlocklist([]string{"A00001"}, nil, nil)
+	out := list.Output(PolicyAnonymize)
+	alias := list.Alias("A00001")
+
+	record, ok := out.Message(&database.MessageRecord{ICAO: "A00001", MessageHex: "8DA00001"})
+	require.True(t, ok)
+	assert.Equal(t, alias, record.ICAO)
+	assert.Empty(t, record.MessageHex, "raw messages contain the address")
+
+	advisory, ok := out.Advisory(&database.Advisory{ICAO: "A00002", Callsign: "UAL1", ThreatICAO: "A00001", ThreatCallsign: "N1"})
+	require.True(t, ok)
+	assert.Equal(t, "A00002", advisory.ICAO)
+	assert.Equal(t, "UAL1", advisory.Callsign)
+	assert.Equal(t, alias, advisory.ThreatICAO, "the threat is anonymized on its own")
+	assert.Empty(t, advisory.ThreatCallsign)
+	_, ok = list.Output(PolicyExclude).Advisory(&database.Advisory{ICAO: "A00002", ThreatICAO: "A00001"})
+	assert.False(t, ok)
+
+	sighting, ok := out.Sighting(&models.Sighting{ICAO: "A00001", Callsign: "N1"})
+	require.True(t, ok)
+	assert.Equal(t, alias, sighting.ICAO)
+	assert.Empty(t, sighting.Callsign)
+
+	original := &models.Event{Type: models.EventAlert, ICAO: "A00001", Message: "N1 in range", Data: map[string]any{"registration": "N1"}}
+	event, ok := out.Event(original)
+	require.True(t, ok)
+	assert.Equal(t, alias, event.ICAO)
+	assert.Equal(t, "alert for "+alias, event.Message)
+	assert.Nil(t, event.Data)
+	assert.Equal(t, "A00001", original.ICAO, "the stored event is not modified")
+
+	events := Filter([]*models.Event{original, {Type: models.EventAlert}}, list.Output(PolicyExclude).Event)
+	require.Len(t, events, 1, "events without an aircraft always pass")
+	assert.Empty(t, events[0].ICAO)
+}
+
+func TestAnonymizer(t *testing.T) {
+	a := NewAnonymizer("shared salt")
+	alias := a.Alias("4840d6")
+	assert.Equal(t, alias, NewAnonymizer("shared salt").Alias("4840D6"), "one salt gives the same hashes across exports")
+	assert.NotEqual(t, alias, NewAnonymizer("other salt").Alias("4840D6"))
+	assert.NotEqual(t, alias, NewAnonymizer("").Alias("4840D6"))
+	assert.Len(t, alias, 13)
+
+	flight := &database.Flight{ID: 7, ICAO: "4840D6", Callsign: "PHBVA", Category: "A3", Messages: 900, Quality: 80}
+	anonymized := a.Flight(flight)
+	assert.Equal(t, alias, anonymized.ICAO)
+	assert.Empty(t, anonymized.Callsign)
+	assert.Zero(t, anonymized.ID)
+	assert.Equal(t, "A3", anonymized.Category, "statistics are kept")
+	assert.Equal(t, int64(900), anonymized.Messages)
+	assert.Equal(t, "PHBVA", flight.Callsign, "the original is unchanged")
+
+	sighting := a.Sighting(&models.Sighting{ICAO: "4840D6", Callsign: "KLM1023", MessageCount: 12})
+	assert.Equal(t, alias, sighting.ICAO)
+	assert.Empty(t, sighting.Callsign)
+	assert.Equal(t, int64(12), sighting.MessageCount)
+}
@@ -0,0 +1,433 @@
+// Package qr encodes short text, such as links, as QR codes
+//
+// Only what the station needs is implemented: byte mode at error correction level M, versions 1 to 10
+// (up to 213 bytes), with the mask chosen by the standard penalty rules.
+package qr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// MaxLength is the longest text that fits, in bytes
+const MaxLength = 213
+
+// quietZone is the light border in modules scanners need around the code
+const quietZone = 4
+
+// versionBlocks describes the error correction blocks of a version at level M
+type versionBlocks struct {
+	ecPerBlock int
+	groups     [][2]int // Block count and data codewords per block of each group
+}
+
+var levelM = [...]versionBlocks{
+	1:  {10, [][2]int{{1, 16}}},
+	2:  {16, [][2]int{{1, 28}}},
+	3:  {26, [][2]int{{1, 44}}},
+	4:  {18, [][2]int{{2, 32}}},
+	5:  {24, [][2]int{{2, 43}}},
+	6:  {16, [][2]int{{4, 27}}},
+	7:  {18, [][2]int{{4, 31}}},
+	8:  {22, [][2]int{{2, 38}, {2, 39}}},
+	9:  {22, [][2]int{{3, 36}, {2, 37}}},
+	10: {26, [][2]int{{4, 43}, {1, 44}}},
+}
+
+// alignmentCenters are the row and column centers of the alignment patterns of each version
+var alignmentCenters = [...][]int{
+	1: nil, 2: {6, 18}, 3: {6, 22}, 4: {6, 26}, 5: {6, 30}, 6: {6, 34},
+	7: {6, 22, 38}, 8: {6, 24, 42}, 9: {6, 26, 46}, 10: {6, 28, 50},
+}
+
+func (v versionBlocks) dataCodewords() int {
+	n := 0
+	for _, g := range v.groups {
+		n += g[0] * g[1]
+	}
+	return n
+}
+
+// Code is an encoded QR code, a square of dark (true) and light modules
+type Code struct {
+	Version  int
+	Size     int
+	modules  [][]bool
+	reserved [][]bool // Function patterns and format areas, which data and masks leave alone
+}
+
+// Dark reports whether the module at column x and row y is dark
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y][x]
+}
+
+// Encode encodes text as the smallest QR code that holds it
+func Encode(text string) (*Code, error) {
+	data := []byte(text)
+	version := 0
+	for v := 1; v < len(levelM); v++ {
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= 8*levelM[v].dataCodewords() {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("text of %d bytes is too long for a QR code (at most %d)", len(data), MaxLength)
+	}
+
+	size := 17 + 4*version
+	c := &Code{Version: version, Size: size, modules: grid(size), reserved: grid(size)}
+	c.drawFunctionPatterns()
+	c.drawCodewords(interleave(levelM[version], encodeData(data, version)))
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormat(mask)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		c.applyMask(mask) // Masks are their own inverse
+	}
+	c.applyMask(best)
+	c.drawFormat(best)
+	return c, nil
+}
+
+func grid(size int) [][]bool {
+	g := make([][]bool, size)
+	for i := range g {
+		g[i] = make([]bool, size)
+	}
+	return g
+}
+
+// set draws a function module, which data and masks skip
+func (c *Code) set(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.reserved[y][x] = true
+}
+
+func (c *Code) drawFunctionPatterns() {
+	for i := 0; i < c.Size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+
+	// Finder patterns with their light separators
+	for _, center := range [][2]int{{3, 3}, {c.Size - 4, 3}, {3, c.Size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := center[0]+dx, center[1]+dy
+				if x < 0 || x >= c.Size || y < 0 || y >= c.Size {
+					continue
+				}
+				d := max(abs(dx), abs(dy))
+				c.set(x, y, d != 2 && d != 4)
+			}
+		}
+	}
+
+	// Alignment patterns, except where they would overlap a finder
+	centers := alignmentCenters[c.Version]
+	last := len(centers) - 1
+	for i, cy := range centers {
+		for j, cx := range centers {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.set(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	c.drawFormat(0) // Reserves the format areas until the mask is chosen
+	c.drawVersion()
+}
+
+// formatBits returns the 15 format bits for level M and a mask, BCH protected and xored with the fixed pattern
+func formatBits(mask int) int {
+	data := 0<<3 | mask // Level M is 00
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// drawFormat draws both copies of the format bits and the dark module
+func (c *Code) drawFormat(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return bits>>i&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.set(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.Size-15+i, bit(i))
+	}
+	c.set(8, c.Size-8, true)
+}
+
+// drawVersion draws both copies of the version bits, which versions 7 and up carry
+func (c *Code) drawVersion() {
+	if c.Version < 7 {
+		return
+	}
+	rem := c.Version
+	for i := 0; i < 12; i++ {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+	bits := c.Version<<12 | rem
+	for i := 0; i < 18; i++ {
+		dark := bits>>i&1 != 0
+		a, b := c.Size-11+i%3, i/3
+		c.set(a, b, dark)
+		c.set(b, a, dark)
+	}
+}
+
+// encodeData builds the data codewords: byte mode header, the text, a terminator, and padding
+func encodeData(data []byte, version int) []byte {
+	var w bitWriter
+	w.write(0b0100, 4)
+	if version >= 10 {
+		w.write(len(data), 16)
+	} else {
+		w.write(len(data), 8)
+	}
+	for _, b := range data {
+		w.write(int(b), 8)
+	}
+
+	capacity := levelM[version].dataCodewords() * 8
+	w.write(0, min(4, capacity-w.n))
+	w.write(0, (8-w.n%8)%8)
+	for pad := 0xEC; w.n < capacity; pad ^= 0xEC ^ 0x11 {
+		w.write(pad, 8)
+	}
+	return w.bytes
+}
+
+type bitWriter struct {
+	bytes []byte
+	n     int // Bits written
+}
+
+func (w *bitWriter) write(value, bits int) {
+	for i := bits - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.bytes = append(w.bytes, 0)
+		}
+		if value>>i&1 != 0 {
+			w.bytes[w.n/8] |= 0x80 >> (w.n % 8)
+		}
+		w.n++
+	}
+}
+
+// interleave splits the data into blocks, adds each block's error correction, and interleaves the blocks
+func interleave(v versionBlocks, data []byte) []byte {
+	var blocks, ecBlocks [][]byte
+	for _, g := range v.groups {
+		for i := 0; i < g[0]; i++ {
+			block := data[:g[1]]
+			data = data[g[1]:]
+			blocks = append(blocks, block)
+			ecBlocks = append(ecBlocks, reedSolomon(block, v.ecPerBlock))
+		}
+	}
+
+	var out []byte
+	longest := len(blocks[len(blocks)-1])
+	for i := 0; i < longest; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				out = append(out, block[i])
+			}
+		}
+	}
+	for i := 0; i < v.ecPerBlock; i++ {
+		for _, ec := range ecBlocks {
+			out = append(out, ec[i])
+		}
+	}
+	return out
+}
+
+// drawCodewords places the codewords in the zigzag order, two columns at a time from the bottom right
+// Modules left over after the last codeword are the remainder bits, which stay light before masking.
+func (c *Code) drawCodewords(codewords []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // Skip the vertical timing pattern
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.Size; vert++ {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if c.reserved[y][x] || i >= len(codewords)*8 {
+					continue
+				}
+				c.modules[y][x] = codewords[i/8]>>(7-i%8)&1 != 0
+				i++
+			}
+		}
+	}
+}
+
+// maskFuncs report whether each mask pattern inverts the module at column x and row y
+var maskFuncs = [8]func(x, y int) bool{
+	func(x, y int) bool { return (x+y)%2 == 0 },
+	func(x, y int) bool { return y%2 == 0 },
+	func(x, y int) bool { return x%3 == 0 },
+	func(x, y int) bool { return (x+y)%3 == 0 },
+	func(x, y int) bool { return (x/3+y/2)%2 == 0 },
+	func(x, y int) bool { return x*y%2+x*y%3 == 0 },
+	func(x, y int) bool { return (x*y%2+x*y%3)%2 == 0 },
+	func(x, y int) bool { return ((x+y)%2+x*y%3)%2 == 0 },
+}
+
+func (c *Code) applyMask(mask int) {
+	invert := maskFuncs[mask]
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.reserved[y][x] && invert(x, y) {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the code is to scan, lower is better
+func (c *Code) penalty() int {
+	p := 0
+	line := make([]bool, c.Size)
+	for _, vertical := range []bool{false, true} {
+		for i := 0; i < c.Size; i++ {
+			for j := 0; j < c.Size; j++ {
+				if vertical {
+					line[j] = c.modules[j][i]
+				} else {
+					line[j] = c.modules[i][j]
+				}
+			}
+			p += linePenalty(line)
+		}
+	}
+
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+			if x > 0 && y > 0 {
+				m := c.modules[y][x]
+				if c.modules[y-1][x] == m && c.modules[y][x-1] == m && c.modules[y-1][x-1] == m {
+					p += 3
+				}
+			}
+		}
+	}
+	total := c.Size * c.Size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return p + max(k, 0)*10
+}
+
+var finderLike = [2][]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+// linePenalty scores runs of five or more modules of one color and finder-like patterns in a row or column
+func linePenalty(line []bool) int {
+	p := 0
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			p += 3 + run - 5
+		}
+		run = 1
+	}
+	for i := 0; i+11 <= len(line); i++ {
+		for _, pattern := range finderLike {
+			match := true
+			for j, dark := range pattern {
+				if line[i+j] != dark {
+					match = false
+					break
+				}
+			}
+			if match {
+				p += 40
+			}
+		}
+	}
+	return p
+}
+
+// PNG renders the code as a 1-bit PNG image, one pixel per module with the quiet zone
+// Scale it up without smoothing (CSS image-rendering: pixelated) to keep the modules sharp.
+func (c *Code) PNG() ([]byte, error) {
+	n := c.Size + 2*quietZone
+	img := image.NewPaletted(image.Rect(0, 0, n, n), color.Palette{color.White, color.Black})
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				img.SetColorIndex(x+quietZone, y+quietZone, 1)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// DataURI renders the code as a PNG data URI, small enough for webhook payloads and usable as an img src
+func (c *Code) DataURI() (string, error) {
+	data, err := c.PNG()
+	if err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
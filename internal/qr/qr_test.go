@@ -0,0 +1,226 @@
+package qr
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// "HELLO WORLD" as version 1-M, the worked example of the standard's annex
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	assert.Equal(t, want, reedSolomon(data, 10))
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	// Level M format bits from the standard's table, by mask
+	want := []int{
+		0b101010000010010, 0b101000100100101, 0b101111001111100, 0b101101101001011,
+		0b100010111111001, 0b100000011001110, 0b100111110010111, 0b100101010100000,
+	}
+	for mask, bits := range want {
+		assert.Equal(t, bits, formatBits(mask), "mask %d", mask)
+	}
+
+	c := &Code{Version: 7, Size: 45, modules: grid(45), reserved: grid(45)}
+	c.drawVersion()
+	got := 0
+	for i := 17; i >= 0; i-- {
+		got <<= 1
+		if c.Dark(c.Size-11+i%3, i/3) {
+			got |= 1
+		}
+	}
+	assert.Equal(t, 0b000111110010010100, got)
+}
+
+func TestEncode_RoundTrip(t *testing.T) {
+	tests := []struct {
+		text    string
+		version int
+	}{
+		{"", 1},
+		{"HTTPS://FR24.COM/PH-BXA", 2},
+		{"https://globe.adsbexchange.com/?icao=4840d6", 4},
+		{"http://pi.local:8080/aircraft.html?icao=4840D6", 4},
+		{strings.Repeat("x", 120), 7},
+		{strings.Repeat("é", 80), 9},
+		{strings.Repeat("y", MaxLength), 10},
+	}
+	for _, tt := range tests {
+		c, err := Encode(tt.text)
+		require.NoError(t, err)
+		assert.Equal(t, tt.version, c.Version, "%d bytes", len(tt.text))
+		assert.Equal(t, tt.text, decode(t, c))
+	}
+
+	_, err := Encode(strings.Repeat("z", MaxLength+1))
+	assert.Error(t, err)
+}
+
+func TestPNG(t *testing.T) {
+	c, err := Encode("https://globe.adsbexchange.com/?icao=4840d6")
+	require.NoError(t, err)
+	data, err := c.PNG()
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	n := c.Size + 2*quietZone
+	require.Equal(t, image.Rect(0, 0, n, n), img.Bounds())
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			r, _, _, _ := img.At(x, y).RGBA()
+			dark := x >= quietZone && y >= quietZone && x < n-quietZone && y < n-quietZone && c.Dark(x-quietZone, y-quietZone)
+			require.Equal(t, dark, r == 0, "pixel %d,%d", x, y)
+		}
+	}
+
+	uri, err := c.DataURI()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(uri, "data:image/png;base64,"), uri)
+	assert.Less(t, len(uri), 600, "1 bit per module keeps it small enough for webhook payloads")
+}
+
+// decode reads a code back the way a scanner would, checking every block's error correction
+func decode(t *testing.T, c *Code) string {
+	t.Helper()
+
+	// Both copies of the format bits must agree
+	var first, second int
+	for i := 14; i >= 0; i-- {
+		var x1, y1, x2, y2 int
+		switch {
+		case i <= 5:
+			x1, y1 = 8, i
+		case i == 6:
+			x1, y1 = 8, 7
+		case i == 7:
+			x1, y1 = 8, 8
+		case i == 8:
+			x1, y1 = 7, 8
+		default:
+			x1, y1 = 14-i, 8
+		}
+		if i < 8 {
+			x2, y2 = c.Size-1-i, 8
+		} else {
+			x2, y2 = 8, c.Size-15+i
+		}
+		first, second = first<<1|bit(c.Dark(x1, y1)), second<<1|bit(c.Dark(x2, y2))
+	}
+	require.Equal(t, first, second)
+	mask := -1
+	for m := 0; m < 8; m++ {
+		if formatBits(m) == first {
+			mask = m
+		}
+	}
+	require.GreaterOrEqual(t, mask, 0, "format bits %015b", first)
+
+	// Read the data modules column pair by column pair, alternating up and down
+	var raw []byte
+	n := 0
+	up := true
+	for right := c.Size - 1; right > 0; right -= 2 {
+		if right == 6 {
+			right--
+		}
+		for k := 0; k < c.Size; k++ {
+			y := k
+			if up {
+				y = c.Size - 1 - k
+			}
+			for _, x := range []int{right, right - 1} {
+				if c.reserved[y][x] {
+					continue
+				}
+				if n%8 == 0 {
+					raw = append(raw, 0)
+				}
+				if c.Dark(x, y) != maskFuncs[mask](x, y) {
+					raw[n/8] |= 0x80 >> (n % 8)
+				}
+				n++
+			}
+		}
+		up = !up
+	}
+
+	// Undo the interleaving and check that every block is a codeword: it vanishes at the generator's roots
+	v := levelM[c.Version]
+	var sizes []int
+	for _, g := range v.groups {
+		for i := 0; i < g[0]; i++ {
+			sizes = append(sizes, g[1])
+		}
+	}
+	blocks := make([][]byte, len(sizes))
+	pos := 0
+	for i := 0; i < sizes[len(sizes)-1]; i++ {
+		for b, size := range sizes {
+			if i < size {
+				blocks[b] = append(blocks[b], raw[pos])
+				pos++
+			}
+		}
+	}
+	for i := 0; i < v.ecPerBlock; i++ {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], raw[pos])
+			pos++
+		}
+	}
+	var data []byte
+	for b, block := range blocks {
+		for root := 0; root < v.ecPerBlock; root++ {
+			var sum byte
+			for _, coef := range block {
+				sum = gfMul(sum, gfExp[root]) ^ coef
+			}
+			require.Zero(t, sum, "block %d syndrome %d", b, root)
+		}
+		data = append(data, block[:sizes[b]]...)
+	}
+
+	// Byte mode header and text
+	require.Equal(t, byte(0b0100), data[0]>>4)
+	r := bitReader{data: data, n: 4}
+	countBits := 8
+	if c.Version >= 10 {
+		countBits = 16
+	}
+	length := r.read(countBits)
+	text := make([]byte, length)
+	for i := range text {
+		text[i] = byte(r.read(8))
+	}
+	return string(text)
+}
+
+func bit(dark bool) int {
+	if dark {
+		return 1
+	}
+	return 0
+}
+
+type bitReader struct {
+	data []byte
+	n    int
+}
+
+func (r *bitReader) read(bits int) int {
+	v := 0
+	for i := 0; i < bits; i++ {
+		v = v<<1 | int(r.data[r.n/8]>>(7-r.n%8)&1)
+		r.n++
+	}
+	return v
+}
@@ -0,0 +1,56 @@
+package qr
+
+// gfExp and gfLog are the powers of the generator 2 in GF(256) with the QR polynomial x^8+x^4+x^3+x^2+1, and their inverse
+var gfExp, gfLog = func() ([512]byte, [256]byte) {
+	var exp [512]byte
+	var log [256]byte
+	x := 1
+	for i := 0; i < 255; i++ {
+		exp[i] = byte(x)
+		log[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+	for i := 255; i < 512; i++ {
+		exp[i] = exp[i-255]
+	}
+	return exp, log
+}()
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+// rsGenerator returns the generator polynomial (x-2^0)(x-2^1)...(x-2^(degree-1)), highest power first
+func rsGenerator(degree int) []byte {
+	g := []byte{1}
+	for i := 0; i < degree; i++ {
+		next := make([]byte, len(g)+1)
+		for j, coef := range g {
+			next[j] ^= coef
+			next[j+1] ^= gfMul(coef, gfExp[i])
+		}
+		g = next
+	}
+	return g
+}
+
+// reedSolomon returns the ecLen error correction codewords of a block: the remainder of data*x^ecLen divided by the generator
+func reedSolomon(data []byte, ecLen int) []byte {
+	g := rsGenerator(ecLen)
+	rem := make([]byte, ecLen)
+	for _, b := range data {
+		factor := b ^ rem[0]
+		copy(rem, rem[1:])
+		rem[ecLen-1] = 0
+		for i := range rem {
+			rem[i] ^= gfMul(g[i+1], factor)
+		}
+	}
+	return rem
+}
@@ -0,0 +1,70 @@
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/pkg/schema"
+)
+
+// LogColumns are the columns of a sighting log in CSV, in the order spotters' spreadsheets and logbooks list them
+var LogColumns = []string{"date", "time", "registration", "type", "type_code", "operator", "callsign", "icao", "location"}
+
+// SightingLog is the flights of a date range as spotters log them
+type SightingLog struct {
+	SchemaVersion int                  `json:"schema_version"`
+	From          time.Time            `json:"from"`
+	To            time.Time            `json:"to"`
+	Location      string               `json:"location,omitempty"` // Where the station is, see Location
+	Entries       []*database.LogEntry `json:"entries"`
+}
+
+// Log returns the sighting log of the flights that started from from until to, seen from location
+func Log(flights database.FlightRepository, from, to time.Time, location string) (*SightingLog, error) {
+	if !to.After(from) {
+		return nil, fmt.Errorf("invalid range %s to %s: must end after it starts", from.Format(time.RFC3339),
+			to.Format(time.RFC3339))
+	}
+	entries, err := flights.SightingLog(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &SightingLog{SchemaVersion: schema.APIVersion, From: from, To: to, Location: location, Entries: entries}, nil
+}
+
+// WriteCSV writes the log as CSV with a header of LogColumns, dated and timed in the time zone
+func (l *SightingLog) WriteCSV(w io.Writer, tz *time.Location) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(LogColumns); err != nil {
+		return err
+	}
+	for _, e := range l.Entries {
+		if err := cw.Write(l.Row(e, tz)); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Row is an entry's CSV row, in the order of LogColumns
+func (l *SightingLog) Row(e *database.LogEntry, tz *time.Location) []string {
+	seen := e.FirstSeen.In(tz)
+	return []string{seen.Format("2006-01-02"), seen.Format("15:04"), e.Registration, e.Type, e.TypeCode, e.Operator,
+		e.Callsign, e.ICAO, l.Location}
+}
+
+// Location names where the station is in a log: its name, else its coordinates, empty when neither is set
+func Location(name string, latitude, longitude float64) string {
+	switch {
+	case name != "":
+		return name
+	case latitude != 0 || longitude != 0:
+		return fmt.Sprintf("%.4f, %.4f", latitude, longitude)
+	default:
+		return ""
+	}
+}
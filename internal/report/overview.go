@@ -0,0 +1,287 @@
+package report
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/database"
+)
+
+// topOperators is how many operators an overview lists
+const topOperators = 10
+
+//go:embed overview.html
+var overviewFS embed.FS
+
+var overviewTemplate = template.Must(template.New("overview.html").Funcs(template.FuncMap{
+	"width":   barWidth,
+	"change":  formatChange,
+	"number":  formatNumber,
+	"percent": formatPercent,
+}).ParseFS(overviewFS, "overview.html"))
+
+// Overview is a period's traffic at a glance, as the weekly email report shows it: the comparison with the period
+// before, messages per day, the operators flown most, and how coverage changed
+type Overview struct {
+	Station    string // Location of the station, see Location
+	Comparison *Comparison
+	Days       []DayCount
+	Operators  []OperatorCount
+	Unknown    int           // Flights whose operator isn't known
+	Coverage   []SectorCount // By bearing, empty without coverage checks in either period
+	Completion [2]*float64   // Percent heard of the aircraft an aggregator knew of, this period and the one before
+}
+
+// DayCount is the messages of one local day
+type DayCount struct {
+	Day      time.Time
+	Messages int64
+}
+
+// OperatorCount is the flights of one operator
+type OperatorCount struct {
+	Operator string
+	Flights  int
+}
+
+// SectorCount is the share of the aircraft an aggregator knew of in one direction that were heard, this period and
+// the one before, nil without any there
+type SectorCount struct {
+	Bearing  float64
+	Current  *float64
+	Previous *float64
+}
+
+// NewOverview reports the period of length ending at to, with its days in the time zone; src.Coverage is optional
+func NewOverview(src Sources, to time.Time, length time.Duration, tz *time.Location, station string) (*Overview, error) {
+	c, err := Compare(src, to, length)
+	if err != nil {
+		return nil, err
+	}
+	from := to.Add(-length)
+	o := &Overview{Station: station, Comparison: c}
+
+	if src.Receiver != nil {
+		hours, err := src.Receiver.List(from)
+		if err != nil {
+			return nil, err
+		}
+		byDay := make(map[time.Time]int64)
+		for _, h := range hours {
+			if h.Hour.Before(to) {
+				byDay[day(h.Hour, tz)] += h.Messages
+			}
+		}
+		for d := day(from, tz); d.Before(to); d = d.AddDate(0, 0, 1) {
+			o.Days = append(o.Days, DayCount{Day: d, Messages: byDay[d]})
+		}
+	}
+
+	if src.Flights != nil {
+		entries, err := src.Flights.SightingLog(from, to)
+		if err != nil {
+			return nil, err
+		}
+		flights := make(map[string]int)
+		for _, e := range entries {
+			if e.Operator == "" {
+				o.Unknown++
+			} else {
+				flights[e.Operator]++
+			}
+		}
+		for operator, n := range flights {
+			o.Operators = append(o.Operators, OperatorCount{Operator: operator, Flights: n})
+		}
+		sort.Slice(o.Operators, func(i, j int) bool {
+			if o.Operators[i].Flights != o.Operators[j].Flights {
+				return o.Operators[i].Flights > o.Operators[j].Flights
+			}
+			return o.Operators[i].Operator < o.Operators[j].Operator
+		})
+		if len(o.Operators) > topOperators {
+			o.Operators = o.Operators[:topOperators]
+		}
+	}
+
+	if src.Coverage != nil {
+		checks, err := src.Coverage.List(from.Add(-length))
+		if err != nil {
+			return nil, err
+		}
+		o.coverage(checks, from, to)
+	}
+	return o, nil
+}
+
+// coverage totals the checks of the period and the one before, by sector and overall
+func (o *Overview) coverage(checks []*database.CoverageCheck, from, to time.Time) {
+	type tally struct{ expected, seen int }
+	var totals [2]tally
+	var sectors [2]map[float64]*tally
+	for _, check := range checks {
+		i := 0
+		if check.Time.Before(from) {
+			i = 1
+		} else if !check.Time.Before(to) {
+			continue
+		}
+		totals[i].expected += check.Expected
+		totals[i].seen += check.Seen
+		if sectors[i] == nil {
+			sectors[i] = make(map[float64]*tally)
+		}
+		for _, s := range check.Sectors {
+			t := sectors[i][s.Bearing]
+			if t == nil {
+				t = &tally{}
+				sectors[i][s.Bearing] = t
+			}
+			t.expected += s.Expected
+			t.seen += s.Seen
+		}
+	}
+	percent := func(t *tally) *float64 {
+		if t == nil || t.expected == 0 {
+			return nil
+		}
+		p := math.Round(float64(t.seen)*1000/float64(t.expected)) / 10
+		return &p
+	}
+	o.Completion = [2]*float64{percent(&totals[0]), percent(&totals[1])}
+
+	bearings := make(map[float64]bool)
+	for _, s := range sectors {
+		for b := range s {
+			bearings[b] = true
+		}
+	}
+	for b := range bearings {
+		o.Coverage = append(o.Coverage, SectorCount{Bearing: b, Current: percent(sectors[0][b]),
+			Previous: percent(sectors[1][b])})
+	}
+	sort.Slice(o.Coverage, func(i, j int) bool { return o.Coverage[i].Bearing < o.Coverage[j].Bearing })
+}
+
+// day is the start of the local day of t
+func day(t time.Time, tz *time.Location) time.Time {
+	y, m, d := t.In(tz).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, tz)
+}
+
+// Subject titles the overview's email
+func (o *Overview) Subject() string {
+	title := "Traffic " + periodLabel(o.Comparison.Current)
+	if o.Station != "" {
+		title += " at " + o.Station
+	}
+	return title
+}
+
+// MaxMessages is the busiest day's messages, which the chart's bars are scaled to
+func (o *Overview) MaxMessages() int64 {
+	var most int64
+	for _, d := range o.Days {
+		most = max(most, d.Messages)
+	}
+	return most
+}
+
+// MaxFlights is the most flights of an operator, which the chart's bars are scaled to
+func (o *Overview) MaxFlights() int {
+	if len(o.Operators) == 0 {
+		return 0
+	}
+	return o.Operators[0].Flights
+}
+
+// HTML renders the overview as an email body, its charts drawn as table cells so they show in mail clients that
+// block images
+func (o *Overview) HTML() (string, error) {
+	var b bytes.Buffer
+	if err := overviewTemplate.Execute(&b, o); err != nil {
+		return "", fmt.Errorf("failed to render overview: %w", err)
+	}
+	return b.String(), nil
+}
+
+// Text renders the overview for mail clients that don't show HTML
+func (o *Overview) Text() string {
+	var b strings.Builder
+	b.WriteString(o.Comparison.Markdown())
+	if len(o.Days) > 0 {
+		b.WriteString("\n## Messages per day\n\n")
+		for _, d := range o.Days {
+			fmt.Fprintf(&b, "- %s: %s\n", d.Day.Format("Mon Jan 2"), formatNumber(d.Messages))
+		}
+	}
+	if len(o.Operators) > 0 {
+		b.WriteString("\n## Top operators\n\n")
+		for _, op := range o.Operators {
+			fmt.Fprintf(&b, "- %s: %d flights\n", op.Operator, op.Flights)
+		}
+	}
+	if o.Completion[0] != nil || o.Completion[1] != nil {
+		fmt.Fprintf(&b, "\n## Coverage\n\n%s of the aircraft the aggregator knew of were heard, against %s before\n",
+			formatPercent(o.Completion[0]), formatPercent(o.Completion[1]))
+	}
+	return b.String()
+}
+
+// barWidth is the percent of a chart's width a value's bar takes, at least 1 so every bar shows
+func barWidth(value, most any) int {
+	v, m := toFloat(value), toFloat(most)
+	if m <= 0 || v <= 0 {
+		return 0
+	}
+	return max(int(math.Round(v*100/m)), 1)
+}
+
+func toFloat(v any) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	case *float64:
+		if n != nil {
+			return *n
+		}
+	}
+	return 0
+}
+
+// formatChange formats a percent change with its sign, n/a when there was nothing to compare with
+func formatChange(change *float64) string {
+	if change == nil {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.1f%%", *change)
+}
+
+func formatPercent(p *float64) string {
+	if p == nil {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.1f%%", *p)
+}
+
+// formatNumber formats a count with thousands separators, e.g. 1,234,567
+func formatNumber(n int64) string {
+	if n < 0 {
+		return "-" + formatNumber(-n)
+	}
+	s := fmt.Sprint(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
@@ -0,0 +1,132 @@
+// Package report compares the station's traffic across periods, e.g. this week against last week
+package report
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/pkg/schema"
+)
+
+// Sources are the tables a comparison reads
+type Sources struct {
+	Flights  database.FlightRepository
+	Receiver database.ReceiverStatsRepository
+	Coverage database.CoverageRepository // Only read by overviews, optional
+}
+
+// Period is the traffic of one period
+type Period struct {
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Messages int64     `json:"messages"` // Received by the local receiver, counted by the hour
+	database.FlightSummary
+}
+
+// Changes are the percent changes from the previous period to the current one, nil when the previous had none
+type Changes struct {
+	Messages *float64 `json:"messages"`
+	Flights  *float64 `json:"flights"`
+	Aircraft *float64 `json:"aircraft"`
+	MaxRange *float64 `json:"max_range"`
+}
+
+// Comparison is a period's traffic against the period before it
+type Comparison struct {
+	SchemaVersion int     `json:"schema_version"`
+	Current       Period  `json:"current"`
+	Previous      Period  `json:"previous"`
+	Changes       Changes `json:"changes"`
+}
+
+// Compare reports the period of length ending at to against the period of the same length before it
+func Compare(src Sources, to time.Time, length time.Duration) (*Comparison, error) {
+	if length <= 0 {
+		return nil, fmt.Errorf("invalid period %s: must be positive", length)
+	}
+	c := &Comparison{SchemaVersion: schema.APIVersion}
+	var err error
+	if c.Current, err = summarize(src, to.Add(-length), to); err != nil {
+		return nil, err
+	}
+	if c.Previous, err = summarize(src, to.Add(-2*length), to.Add(-length)); err != nil {
+		return nil, err
+	}
+	c.Changes = Changes{
+		Messages: change(float64(c.Previous.Messages), float64(c.Current.Messages)),
+		Flights:  change(float64(c.Previous.Flights), float64(c.Current.Flights)),
+		Aircraft: change(float64(c.Previous.Aircraft), float64(c.Current.Aircraft)),
+		MaxRange: change(c.Previous.MaxRange, c.Current.MaxRange),
+	}
+	return c, nil
+}
+
+// summarize totals one period
+func summarize(src Sources, from, to time.Time) (Period, error) {
+	p := Period{From: from, To: to}
+	if src.Flights != nil {
+		summary, err := src.Flights.Summary(from, to)
+		if err != nil {
+			return p, err
+		}
+		p.FlightSummary = *summary
+	}
+	if src.Receiver != nil {
+		hours, err := src.Receiver.List(from)
+		if err != nil {
+			return p, err
+		}
+		for _, h := range hours {
+			if h.Hour.Before(to) {
+				p.Messages += h.Messages
+			}
+		}
+	}
+	return p, nil
+}
+
+// change is the percent change from before to after, rounded to a tenth
+func change(before, after float64) *float64 {
+	if before == 0 {
+		return nil
+	}
+	percent := math.Round((after-before)/before*1000) / 10
+	return &percent
+}
+
+// Markdown renders the comparison as a table
+func (c *Comparison) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Traffic %s vs %s\n\n", periodLabel(c.Current), periodLabel(c.Previous))
+	b.WriteString("| | This period | Previous | Change |\n|---|---:|---:|---:|\n")
+	rows := []struct {
+		name              string
+		current, previous string
+		change            *float64
+	}{
+		{"Messages", fmt.Sprint(c.Current.Messages), fmt.Sprint(c.Previous.Messages), c.Changes.Messages},
+		{"Flights", fmt.Sprint(c.Current.Flights), fmt.Sprint(c.Previous.Flights), c.Changes.Flights},
+		{"Unique aircraft", fmt.Sprint(c.Current.Aircraft), fmt.Sprint(c.Previous.Aircraft), c.Changes.Aircraft},
+		{"Max range (km)", fmt.Sprintf("%.1f", c.Current.MaxRange), fmt.Sprintf("%.1f", c.Previous.MaxRange), c.Changes.MaxRange},
+	}
+	for _, row := range rows {
+		delta := "n/a"
+		if row.change != nil {
+			delta = fmt.Sprintf("%+.1f%%", *row.change)
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", row.name, row.current, row.previous, delta)
+	}
+	return b.String()
+}
+
+// periodLabel names a period by its dates, with times when it's shorter than a day
+func periodLabel(p Period) string {
+	layout := "Jan 2"
+	if p.To.Sub(p.From) < 24*time.Hour {
+		layout = "Jan 2 15:04"
+	}
+	return p.From.Local().Format(layout) + " – " + p.To.Local().Format(layout)
+}
@@ -0,0 +1,200 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockFlights summarizes flights by the start of the period asked for, and logs fixed sightings
+type mockFlights struct {
+	summaries map[time.Time]*database.FlightSummary
+	log       []*database.LogEntry
+}
+
+func (m *mockFlights) Insert(flight *database.Flight) error { return nil }
+
+func (m *mockFlights) List(filter database.FlightFilter, limit int) ([]*database.Flight, error) {
+	return nil, nil
+}
+
+func (m *mockFlights) Summary(from, to time.Time) (*database.FlightSummary, error) {
+	if s, ok := m.summaries[from]; ok {
+		return s, nil
+	}
+	return &database.FlightSummary{}, nil
+}
+
+func (m *mockFlights) SightingLog(from, to time.Time) ([]*database.LogEntry, error) {
+	return m.log, nil
+}
+
+// mockReceiver returns fixed hours from since on
+type mockReceiver struct {
+	hours []*database.ReceiverHour
+}
+
+func (m *mockReceiver) Add(hour *database.ReceiverHour) error { return nil }
+
+func (m *mockReceiver) Get(hour time.Time) (*database.ReceiverHour, error) { return nil, nil }
+
+func (m *mockReceiver) List(since time.Time) ([]*database.ReceiverHour, error) {
+	var hours []*database.ReceiverHour
+	for _, h := range m.hours {
+		if !h.Hour.Before(since) {
+			hours = append(hours, h)
+		}
+	}
+	return hours, nil
+}
+
+// mockCoverage returns fixed checks from since on
+type mockCoverage struct {
+	checks []*database.CoverageCheck
+}
+
+func (m *mockCoverage) Insert(check *database.CoverageCheck) error { return nil }
+
+func (m *mockCoverage) List(since time.Time) ([]*database.CoverageCheck, error) {
+	var checks []*database.CoverageCheck
+	for _, c := range m.checks {
+		if !c.Time.Before(since) {
+			checks = append(checks, c)
+		}
+	}
+	return checks, nil
+}
+
+func TestCompare(t *testing.T) {
+	now := time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)
+	week := 7 * 24 * time.Hour
+	src := Sources{
+		Flights: &mockFlights{summaries: map[time.Time]*database.FlightSummary{
+			now.Add(-week):     {Flights: 330, Aircraft: 220, MaxRange: 310.2, MeanQuality: 70},
+			now.Add(-2 * week): {Flights: 300, Aircraft: 200, MaxRange: 0},
+		}},
+		Receiver: &mockReceiver{hours: []*database.ReceiverHour{
+			{Hour: now.Add(-2*week - time.Hour), Messages: 999}, // Before both periods
+			{Hour: now.Add(-2 * week), Messages: 1000},
+			{Hour: now.Add(-week - time.Hour), Messages: 1000},
+			{Hour: now.Add(-week), Messages: 1500},
+		}},
+	}
+
+	c, err := Compare(src, now, week)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(-week), c.Current.From)
+	assert.Equal(t, int64(1500), c.Current.Messages)
+	assert.Equal(t, int64(2000), c.Previous.Messages)
+	assert.Equal(t, 220, c.Current.Aircraft)
+	require.NotNil(t, c.Changes.Messages)
+	assert.Equal(t, -25.0, *c.Changes.Messages)
+	require.NotNil(t, c.Changes.Flights)
+	assert.Equal(t, 10.0, *c.Changes.Flights)
+	assert.Nil(t, c.Changes.MaxRange, "no range last week")
+
+	markdown := c.Markdown()
+	assert.Contains(t, markdown, "| Messages | 1500 | 2000 | -25.0% |")
+	assert.Contains(t, markdown, "| Unique aircraft | 220 | 200 | +10.0% |")
+	assert.Contains(t, markdown, "| Max range (km) | 310.2 | 0.0 | n/a |")
+
+	data, err := json.Marshal(c)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"max_range":null`)
+
+	_, err = Compare(src, now, 0)
+	assert.Error(t, err)
+}
+
+func TestLog(t *testing.T) {
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	flights := &mockFlights{log: []*database.LogEntry{
+		{ICAO: "4840D6", Callsign: "KLM1023", FirstSeen: from.Add(12*time.Hour + 5*time.Minute), Registration: "PH-BXA",
+			TypeCode: "B738", Type: "Boeing 737-8K2", Operator: "KLM, Royal Dutch Airlines"},
+		{ICAO: "A05F21", FirstSeen: from.Add(23*time.Hour + 30*time.Minute)},
+	}}
+
+	log, err := Log(flights, from, from.Add(24*time.Hour), Location("", 52.3086, 4.7639))
+	require.NoError(t, err)
+	assert.Equal(t, "52.3086, 4.7639", log.Location)
+
+	var buf bytes.Buffer
+	require.NoError(t, log.WriteCSV(&buf, time.FixedZone("CEST", 2*60*60)))
+	assert.Equal(t, "date,time,registration,type,type_code,operator,callsign,icao,location\n"+
+		`2024-05-01,14:05,PH-BXA,Boeing 737-8K2,B738,"KLM, Royal Dutch Airlines",KLM1023,4840D6,"52.3086, 4.7639"`+"\n"+
+		`2024-05-02,01:30,,,,,,A05F21,"52.3086, 4.7639"`+"\n", buf.String(), "dated in the time zone asked for")
+
+	_, err = Log(flights, from, from, "")
+	assert.Error(t, err)
+}
+
+func TestLocation(t *testing.T) {
+	assert.Equal(t, "Schiphol", Location("Schiphol", 52.3086, 4.7639))
+	assert.Equal(t, "-33.9461, 151.1772", Location("", -33.9461, 151.1772))
+	assert.Empty(t, Location("", 0, 0))
+}
+
+func TestNewOverview(t *testing.T) {
+	now := time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC)
+	week := 7 * 24 * time.Hour
+	src := Sources{
+		Flights: &mockFlights{log: []*database.LogEntry{
+			{ICAO: "4840D6", Operator: "KLM"},
+			{ICAO: "484506", Operator: "KLM"},
+			{ICAO: "406A3A", Operator: "British Airways"},
+			{ICAO: "A05F21"},
+		}},
+		Receiver: &mockReceiver{hours: []*database.ReceiverHour{
+			{Hour: now.Add(-week - time.Hour), Messages: 999}, // The week before
+			{Hour: now.Add(-week), Messages: 1000},
+			{Hour: now.Add(-week + time.Hour), Messages: 500},
+			{Hour: now.Add(-time.Hour), Messages: 4000},
+		}},
+		Coverage: &mockCoverage{checks: []*database.CoverageCheck{
+			{Time: now.Add(-week - time.Hour), Expected: 10, Seen: 5, Sectors: []database.CoverageSector{
+				{Bearing: 0, Expected: 10, Seen: 5},
+			}},
+			{Time: now.Add(-time.Hour), Expected: 20, Seen: 15, Sectors: []database.CoverageSector{
+				{Bearing: 0, Expected: 8, Seen: 8},
+				{Bearing: 180, Expected: 12, Seen: 7},
+			}},
+		}},
+	}
+
+	o, err := NewOverview(src, now, week, time.UTC, "Schiphol")
+	require.NoError(t, err)
+	require.Len(t, o.Days, 7)
+	assert.Equal(t, int64(1500), o.Days[0].Messages)
+	assert.Equal(t, int64(4000), o.Days[6].Messages)
+	assert.Equal(t, int64(4000), o.MaxMessages())
+	assert.Equal(t, []OperatorCount{{"KLM", 2}, {"British Airways", 1}}, o.Operators)
+	assert.Equal(t, 1, o.Unknown)
+
+	require.NotNil(t, o.Completion[0])
+	assert.Equal(t, 75.0, *o.Completion[0])
+	require.NotNil(t, o.Completion[1])
+	assert.Equal(t, 50.0, *o.Completion[1])
+	require.Len(t, o.Coverage, 2)
+	assert.Equal(t, 100.0, *o.Coverage[0].Current)
+	assert.Equal(t, 50.0, *o.Coverage[0].Previous)
+	assert.Nil(t, o.Coverage[1].Previous, "no aircraft to the south the week before")
+
+	html, err := o.HTML()
+	require.NoError(t, err)
+	assert.Contains(t, html, "Traffic ")
+	assert.Contains(t, html, "at Schiphol")
+	assert.Contains(t, html, `width="38%"`, "the first day's bar, scaled to the busiest")
+	assert.Contains(t, html, "4,000")
+	assert.Contains(t, html, "British Airways")
+	assert.Contains(t, html, "1 more flights")
+
+	text := o.Text()
+	assert.Contains(t, text, "- KLM: 2 flights")
+	assert.Contains(t, text, "75.0% of the aircraft")
+}
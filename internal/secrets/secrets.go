@@ -0,0 +1,67 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces secret values in config dumps and logs
+const Redacted = "[redacted]"
+
+// envReference matches ${NAME} references to environment variables
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Resolve returns a secret configured inline or in a file
+// With file set, the secret is the file's content without surrounding whitespace (e.g. a Docker or systemd secret).
+// Otherwise ${NAME} references in value are replaced by environment variables, so YAML can stay free of secrets.
+func Resolve(value, file string) (string, error) {
+	if file != "" {
+		if value != "" {
+			return "", fmt.Errorf("set either the value or the file, not both")
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+
+	var missing []string
+	resolved := envReference.ReplaceAllStringFunc(value, func(ref string) string {
+		name := envReference.FindStringSubmatch(ref)[1]
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return resolved, nil
+}
+
+// RedactURL keeps only the scheme and host of a URL; webhook paths and queries usually carry tokens
+func RedactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return Redacted
+	}
+	return u.Scheme + "://" + u.Host + "/" + Redacted
+}
+
+// RedactError hides the URL in a *url.Error, which net/http returns with the full request URL
+func RedactError(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	return &url.Error{Op: urlErr.Op, URL: RedactURL(urlErr.URL), Err: urlErr.Err}
+}
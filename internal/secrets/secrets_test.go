@@ -0,0 +1,54 @@
+package secrets
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	t.Setenv("FT_TEST_TOKEN", "s3cret")
+
+	v, err := Resolve("plain", "")
+	require.NoError(t, err)
+	assert.Equal(t, "plain", v)
+
+	v, err = Resolve("https://example.com/hook/${FT_TEST_TOKEN}", "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/hook/s3cret", v)
+
+	_, err = Resolve("${FT_TEST_MISSING}", "")
+	assert.EqualError(t, err, "environment variable FT_TEST_MISSING is not set")
+
+	path := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(path, []byte("from-file\n"), 0o600))
+	v, err = Resolve("", path)
+	require.NoError(t, err)
+	assert.Equal(t, "from-file", v, "trailing newline is trimmed")
+
+	_, err = Resolve("inline", path)
+	assert.Error(t, err)
+	_, err = Resolve("", filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestRedactURL(t *testing.T) {
+	assert.Equal(t, "https://usetrmnl.com/[redacted]", RedactURL("https://usetrmnl.com/api/custom_plugins/abc-123?x=1"))
+	assert.Equal(t, "", RedactURL(""))
+	assert.Equal(t, Redacted, RedactURL("not a url"))
+}
+
+func TestRedactError(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := RedactError(&url.Error{Op: "Post", URL: "https://hooks.example.com/T000/secret-token", Err: cause})
+	assert.Equal(t, `Post "https://hooks.example.com/[redacted]": connection refused`, err.Error())
+	assert.ErrorIs(t, err, cause)
+
+	other := errors.New("other")
+	assert.Equal(t, other, RedactError(other))
+}
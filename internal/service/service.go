@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"flight_trmnl/internal/crash"
+)
+
+// Service states reported by Health
+const (
+	StateRunning = "running"
+	StateStopped = "stopped" // Stopped by the group, or Start returned without an error
+	StateFailed  = "failed"  // Start returned an error before the service was stopped
+)
+
+// Service is a long-running subsystem such as ingest, the collector, the API, or an output
+// Start blocks until its context is cancelled, like the Start methods throughout this repo; a Group stops a
+// service by cancelling that context and waiting for Start to return.
+type Service interface {
+	Name() string
+	Start(ctx context.Context) error
+	Healthy() error // Why the service isn't working, or nil
+}
+
+type funcService struct {
+	name    string
+	start   func(ctx context.Context) error
+	healthy func() error
+}
+
+// New wraps a blocking start function as a service; healthy may be nil for services that are healthy while
+// running
+func New(name string, start func(ctx context.Context) error, healthy func() error) Service {
+	return &funcService{name: name, start: start, healthy: healthy}
+}
+
+func (s *funcService) Name() string { return s.name }
+
+func (s *funcService) Start(ctx context.Context) error { return s.start(ctx) }
+
+func (s *funcService) Healthy() error {
+	if s.healthy == nil {
+		return nil
+	}
+	return s.healthy()
+}
+
+// Health is the state of a service as served by /api/health
+type Health struct {
+	Name    string `json:"name"`
+	State   string `json:"state"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"` // Why it failed or isn't healthy
+}
+
+type member struct {
+	svc    Service
+	cancel context.CancelFunc
+	done   chan struct{} // Closed when Start has returned
+
+	// Set under the group's lock
+	stopping bool
+	err      error
+}
+
+// Group starts services in order and stops them in reverse, so a service is stopped before the ones it was
+// started after, e.g. the API before the collector it reads from and the collector last, flushing its batch
+// before the database closes
+type Group struct {
+	stopTimeout time.Duration
+
+	mu      sync.Mutex
+	members []*member
+	stopped bool // Stop was called, so services started since don't run
+}
+
+// NewGroup creates a group that waits up to stopTimeout for each service to stop
+func NewGroup(stopTimeout time.Duration) *Group {
+	return &Group{stopTimeout: stopTimeout}
+}
+
+// Start runs a service in its own goroutine until Stop, panicking on a duplicate name like http.ServeMux.Handle
+// A service whose Start returns an error before then is logged and reported as failed, one that panics writes a
+// crash report. A service started once Stop was called isn't run.
+func (g *Group) Start(ctx context.Context, svc Service) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stopped {
+		slog.Debug("Not starting service, the group is stopping", "service", svc.Name())
+		return
+	}
+	for _, m := range g.members {
+		if m.svc.Name() == svc.Name() {
+			panic("service: duplicate service name " + svc.Name())
+		}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	m := &member{svc: svc, cancel: cancel, done: make(chan struct{})}
+	g.members = append(g.members, m)
+
+	go func() {
+		defer close(m.done)
+		defer crash.Recover()
+		err := svc.Start(ctx)
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if m.stopping || errors.Is(err, context.Canceled) {
+			return
+		}
+		m.err = err
+		if err != nil {
+			slog.Error("Service stopped", "service", svc.Name(), "error", err)
+		}
+	}()
+}
+
+// Stop stops every service in the reverse order they were started, waiting up to the stop timeout for each
+func (g *Group) Stop() {
+	g.mu.Lock()
+	g.stopped = true
+	members := append([]*member(nil), g.members...)
+	for _, m := range members {
+		m.stopping = true
+	}
+	g.mu.Unlock()
+
+	for i := len(members) - 1; i >= 0; i-- {
+		g.wait(members[i])
+	}
+}
+
+// Remove stops the named service, waiting up to the stop timeout, and drops it from the group so another can be
+// started under its name, e.g. an input whose settings were reloaded. It reports whether the service was found.
+func (g *Group) Remove(name string) bool {
+	g.mu.Lock()
+	var m *member
+	for _, candidate := range g.members {
+		if candidate.svc.Name() == name {
+			m = candidate
+		}
+	}
+	if m == nil {
+		g.mu.Unlock()
+		return false
+	}
+	m.stopping = true
+	g.mu.Unlock()
+
+	// Dropped only once it has stopped, so a Stop meanwhile still waits for it in its place
+	g.wait(m)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i, candidate := range g.members {
+		if candidate == m {
+			g.members = append(g.members[:i], g.members[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// wait cancels a service and waits up to the stop timeout for it to return
+func (g *Group) wait(m *member) {
+	m.cancel()
+	select {
+	case <-m.done:
+		slog.Debug("Service stopped", "service", m.svc.Name())
+	case <-time.After(g.stopTimeout):
+		slog.Warn("Timed out waiting for service to stop", "service", m.svc.Name(), "timeout", g.stopTimeout)
+	}
+}
+
+// Health returns the health of every service in the order they were started
+func (g *Group) Health() []Health {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	health := make([]Health, 0, len(g.members))
+	for _, m := range g.members {
+		h := Health{Name: m.svc.Name(), State: StateRunning}
+		select {
+		case <-m.done:
+			h.State = StateStopped
+			if m.err != nil {
+				h.State = StateFailed
+				h.Error = m.err.Error()
+			}
+		default:
+			if err := m.svc.Healthy(); err != nil {
+				h.Error = err.Error()
+			} else {
+				h.Healthy = true
+			}
+		}
+		health = append(health, h)
+	}
+	return health
+}
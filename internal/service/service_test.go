@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroup_StopsInReverseOrder(t *testing.T) {
+	var mu sync.Mutex
+	var stopped []string
+	blocking := func(name string) Service {
+		return New(name, func(ctx context.Context) error {
+			<-ctx.Done()
+			mu.Lock()
+			stopped = append(stopped, name)
+			mu.Unlock()
+			return ctx.Err()
+		}, nil)
+	}
+
+	group := NewGroup(time.Second)
+	for _, name := range []string{"collector", "beast", "api"} {
+		group.Start(context.Background(), blocking(name))
+	}
+	for _, h := range group.Health() {
+		assert.Equal(t, StateRunning, h.State, h.Name)
+		assert.True(t, h.Healthy, h.Name)
+	}
+
+	group.Stop()
+	assert.Equal(t, []string{"api", "beast", "collector"}, stopped)
+	for _, h := range group.Health() {
+		assert.Equal(t, StateStopped, h.State, h.Name)
+		assert.False(t, h.Healthy, h.Name)
+		assert.Empty(t, h.Error, h.Name)
+	}
+}
+
+func TestGroup_Health(t *testing.T) {
+	var mu sync.Mutex
+	connected := false
+	group := NewGroup(time.Second)
+	group.Start(context.Background(), New("beast", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		if !connected {
+			return errors.New("not connected")
+		}
+		return nil
+	}))
+	group.Start(context.Background(), New("hub", func(ctx context.Context) error {
+		return errors.New("address already in use")
+	}, nil))
+	defer group.Stop()
+
+	require.Eventually(t, func() bool {
+		return group.Health()[1].State == StateFailed
+	}, time.Second, 5*time.Millisecond)
+	health := group.Health()
+	assert.Equal(t, Health{Name: "beast", State: StateRunning, Error: "not connected"}, health[0])
+	assert.Equal(t, Health{Name: "hub", State: StateFailed, Error: "address already in use"}, health[1])
+
+	mu.Lock()
+	connected = true
+	mu.Unlock()
+	assert.True(t, group.Health()[0].Healthy)
+}
+
+func TestGroup_StopTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	group := NewGroup(20 * time.Millisecond)
+	group.Start(context.Background(), New("stuck", func(ctx context.Context) error {
+		<-release
+		return nil
+	}, nil))
+
+	start := time.Now()
+	group.Stop()
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, StateRunning, group.Health()[0].State)
+}
+
+func TestGroup_DuplicateName(t *testing.T) {
+	group := NewGroup(time.Second)
+	defer group.Stop()
+	start := func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	}
+	group.Start(context.Background(), New("api", start, nil))
+	assert.Panics(t, func() { group.Start(context.Background(), New("api", start, nil)) })
+}
+
+func TestGroup_Remove(t *testing.T) {
+	group := NewGroup(time.Second)
+	defer group.Stop()
+	stopped := make(chan string, 2)
+	start := func(name string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			<-ctx.Done()
+			stopped <- name
+			return nil
+		}
+	}
+	group.Start(context.Background(), New("beast", start("beast"), nil))
+	group.Start(context.Background(), New("udp :30005", start("old"), nil))
+
+	assert.True(t, group.Remove("udp :30005"))
+	assert.Equal(t, "old", <-stopped, "stopped before Remove returns")
+	assert.False(t, group.Remove("udp :30005"))
+	require.Len(t, group.Health(), 1)
+
+	// Its name is free again
+	group.Start(context.Background(), New("udp :30005", start("new"), nil))
+	health := group.Health()
+	require.Len(t, health, 2)
+	assert.Equal(t, "udp :30005", health[1].Name)
+	assert.Equal(t, StateRunning, health[1].State)
+}
+
+func TestGroup_StartAfterStop(t *testing.T) {
+	group := NewGroup(time.Second)
+	group.Stop()
+	ran := make(chan struct{}, 1)
+	group.Start(context.Background(), New("late", func(ctx context.Context) error {
+		ran <- struct{}{}
+		return nil
+	}, nil))
+	assert.Empty(t, group.Health())
+	select {
+	case <-ran:
+		t.Fatal("a service started after Stop ran")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
@@ -42,19 +42,20 @@ func NewBeastCollectorWithConfig(repo database.BeastMessageRepository, messageCh
 // Batches are flushed when they reach batchSize (100) or 1 second has passed since the last transaction
 func (c *BeastCollector) Start(ctx context.Context) error {
 	batch := make([]*models.BeastMessage, 0, c.batchSize)
+	batchID := uint64(1) // Numbers batches since startup; first and last tell which messages a batch held
 	var lastFlushTime time.Time
 
 	flushBatch := func() {
 		if len(batch) > 0 {
+			log := slog.With("batch", batchID, "batch_size", len(batch), "first", batch[0].Ref(), "last", batch[len(batch)-1].Ref())
 			if err := c.repo.InsertBatch(batch); err != nil {
-				slog.Error("Error inserting batch of messages", "batch_size", len(batch), "error", err)
+				log.Error("Error inserting batch of messages", "error", err)
 			} else {
 				lastFlushTime = time.Now()
-				slog.Info("Inserted batch of Beast messages",
-					"batch_size", len(batch),
-				)
+				log.Info("Inserted batch of Beast messages")
 			}
 			batch = batch[:0] // Reset slice but keep capacity
+			batchID++
 		}
 	}
 
@@ -83,9 +84,11 @@ func (c *BeastCollector) Start(ctx context.Context) error {
 
 			// Log debug information about the message and batch
 			slog.Debug("Added message to batch",
+				"ref", msg.Ref(),
+				"batch", batchID,
 				"icao", msg.ICAO,
 				"message_type", msg.MessageType,
-				"signal_level", msg.SignalLevel,
+				"signal_dbfs", msg.SignalDBFS(),
 				"timestamp", msg.Timestamp.Format(time.RFC3339Nano),
 				"current_batch_size", len(batch),
 				"max_batch_size", c.batchSize,
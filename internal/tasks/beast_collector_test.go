@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"flight_trmnl/internal/database"
 	"flight_trmnl/internal/models"
 
 	"github.com/stretchr/testify/assert"
@@ -27,6 +28,22 @@ func (m *mockRepository) InsertBatch(msgs []*models.BeastMessage) error {
 	return nil
 }
 
+func (m *mockRepository) QueryHistory(filter database.MessageFilter, page database.PageRequest) ([]*database.MessageRecord, string, error) {
+	return nil, "", nil
+}
+
+func (m *mockRepository) MessageTypes(filter database.MessageFilter) (models.MessageTypeBreakdown, error) {
+	return models.MessageTypeBreakdown{}, nil
+}
+
+func (m *mockRepository) Tracks(icao string, gap time.Duration, limit int) (*database.TrackHistory, error) {
+	return &database.TrackHistory{}, nil
+}
+
+func (m *mockRepository) Receivers(filter database.MessageFilter) ([]*database.ReceiverCoverage, error) {
+	return nil, nil
+}
+
 func TestNewBeastCollector(t *testing.T) {
 	repo := &mockRepository{}
 	messageChan := make(chan *models.BeastMessage, 10)
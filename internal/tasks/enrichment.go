@@ -0,0 +1,121 @@
+package tasks
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/metadata"
+	"flight_trmnl/internal/models"
+)
+
+// enrichmentPageSize is how many queued aircraft are read from the queue at once
+const enrichmentPageSize = 100
+
+// EnrichmentResult counts the outcome of one backfill pass
+type EnrichmentResult struct {
+	Queued   int // Aircraft newly queued by this pass
+	Resolved int
+	NotFound int
+	Errors   int
+}
+
+// EnrichmentBackfill looks up metadata for seen aircraft missing from the aircraft table and stores it
+// Fills in historical data after a new resolver is configured, at a pace remote APIs tolerate.
+type EnrichmentBackfill struct {
+	queue       database.EnrichmentRepository
+	aircraft    database.AircraftRepository
+	chain       *metadata.Chain
+	resolvers   string        // Configured resolver names, entries not found with another set are retried
+	delay       time.Duration // Time between lookups
+	maxAttempts int
+}
+
+func NewEnrichmentBackfill(queue database.EnrichmentRepository, aircraft database.AircraftRepository, chain *metadata.Chain,
+	resolvers []string, delay time.Duration, maxAttempts int) *EnrichmentBackfill {
+	return &EnrichmentBackfill{
+		queue:       queue,
+		aircraft:    aircraft,
+		chain:       chain,
+		resolvers:   strings.Join(resolvers, ","),
+		delay:       delay,
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Pass runs a backfill pass and logs its outcome, as the scheduled enrichment task
+func (b *EnrichmentBackfill) Pass(ctx context.Context) error {
+	result, err := b.RunOnce(ctx)
+	if err != nil {
+		return err
+	}
+	if result.Resolved+result.NotFound+result.Errors > 0 {
+		slog.Info("Enrichment backfill pass complete",
+			"queued", result.Queued, "resolved", result.Resolved, "not_found", result.NotFound, "errors", result.Errors)
+	}
+	return nil
+}
+
+// RunOnce queues missing aircraft and works through the queue until it is empty or the context is cancelled
+func (b *EnrichmentBackfill) RunOnce(ctx context.Context) (EnrichmentResult, error) {
+	var result EnrichmentResult
+
+	queued, err := b.queue.QueueMissing(b.resolvers)
+	if err != nil {
+		return result, err
+	}
+	result.Queued = queued
+
+	started := time.Now()
+	for first := true; ; {
+		icaos, err := b.queue.Pending(started, enrichmentPageSize)
+		if err != nil {
+			return result, err
+		}
+		if len(icaos) == 0 {
+			return result, nil
+		}
+
+		for _, icao := range icaos {
+			if !first {
+				select {
+				case <-ctx.Done():
+					return result, ctx.Err()
+				case <-time.After(b.delay):
+				}
+			}
+			first = false
+
+			if err := b.enrich(ctx, icao, &result); err != nil {
+				return result, err
+			}
+		}
+	}
+}
+
+// enrich looks up one aircraft and records the outcome; only database errors are returned
+func (b *EnrichmentBackfill) enrich(ctx context.Context, icao string, result *EnrichmentResult) error {
+	ac, resolver, err := b.chain.ResolveStrict(ctx, icao)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		result.Errors++
+		return b.queue.MarkError(icao, err, b.maxAttempts)
+	}
+
+	if ac == nil {
+		result.NotFound++
+		return b.queue.MarkDone(icao, database.EnrichmentNotFound, "")
+	}
+
+	stored := *ac
+	stored.ICAO24 = strings.ToLower(icao)
+	if err := b.aircraft.InsertBatch([]*models.Aircraft{&stored}); err != nil {
+		return err
+	}
+	result.Resolved++
+	return b.queue.MarkDone(icao, database.EnrichmentResolved, resolver)
+}
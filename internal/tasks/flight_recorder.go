@@ -0,0 +1,63 @@
+package tasks
+
+import (
+	"context"
+	"log/slog"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/tracker"
+)
+
+// FlightRecorder stores a row in flights for every visit that ends, with its reception quality, so coverage
+// analysis can weight flights by how well they were received
+type FlightRecorder struct {
+	tracker *tracker.Tracker
+	repo    database.FlightRepository
+}
+
+func NewFlightRecorder(t *tracker.Tracker, repo database.FlightRepository) *FlightRecorder {
+	return &FlightRecorder{tracker: t, repo: repo}
+}
+
+// Start records flights as the tracker expires aircraft
+// This method blocks until the context is cancelled. Aircraft still in range at shutdown aren't recorded.
+func (f *FlightRecorder) Start(ctx context.Context) error {
+	sub := f.tracker.Subscribe(1000)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case update, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			if update.Type == tracker.UpdateRemove && update.Quality != nil {
+				f.record(update.Aircraft, *update.Quality)
+			}
+		}
+	}
+}
+
+func (f *FlightRecorder) record(state tracker.AircraftState, quality tracker.Quality) {
+	err := f.repo.Insert(&database.Flight{
+		ICAO:         state.ICAO,
+		Callsign:     state.Callsign,
+		Category:     state.Category,
+		FirstSeen:    state.FirstSeen,
+		LastSeen:     state.LastSeen,
+		Messages:     state.Messages,
+		Quality:      quality.Score,
+		Gaps:         quality.Gaps,
+		LongestGap:   quality.LongestGap,
+		Positions:    quality.Positions,
+		PositionRate: quality.PositionRate,
+		SignalMean:   quality.SignalMean,
+		SignalStdDev: quality.SignalStdDev,
+		MaxRange:     quality.Range,
+	})
+	if err != nil {
+		slog.Warn("Failed to record flight", "icao", state.ICAO, "error", err)
+	}
+}
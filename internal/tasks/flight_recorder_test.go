@@ -0,0 +1,76 @@
+package tasks
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/tracker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockFlightRepository keeps inserted flights in memory
+type mockFlightRepository struct {
+	mu      sync.Mutex
+	flights []*database.Flight
+}
+
+func (m *mockFlightRepository) Insert(flight *database.Flight) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flights = append(m.flights, flight)
+	return nil
+}
+
+func (m *mockFlightRepository) List(filter database.FlightFilter, limit int) ([]*database.Flight, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.flights, nil
+}
+
+func (m *mockFlightRepository) Summary(from, to time.Time) (*database.FlightSummary, error) {
+	return &database.FlightSummary{}, nil
+}
+
+func (m *mockFlightRepository) SightingLog(from, to time.Time) ([]*database.LogEntry, error) {
+	return nil, nil
+}
+
+func TestFlightRecorder(t *testing.T) {
+	tr := tracker.New(time.Minute)
+	repo := &mockFlightRepository{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	recorder := NewFlightRecorder(tr, repo)
+	done := make(chan struct{})
+	go func() {
+		recorder.Start(ctx)
+		close(done)
+	}()
+	// Start subscribes asynchronously; wait until it does so the removal isn't missed
+	require.Eventually(t, func() bool {
+		tr.Update(&models.BeastMessage{
+			MessageTypeCode: models.BeastTypeModeSLong,
+			Message:         []byte{0x8D, 0x48, 0x40, 0xD6},
+			ICAO:            "4840D6",
+			SignalLevel:     120,
+		})
+		tr.Expire(time.Now().Add(2 * time.Minute))
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+		return len(repo.flights) > 0
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+	flight := repo.flights[0]
+	assert.Equal(t, "4840D6", flight.ICAO)
+	assert.Equal(t, int64(1), flight.Messages)
+	assert.Equal(t, -6.5, flight.SignalMean, "dBFS")
+}
@@ -0,0 +1,360 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+
+	"flight_trmnl/internal/crash"
+	"flight_trmnl/internal/database"
+)
+
+// Task schedules, every task can also be run on demand
+const (
+	ScheduleInterval = "interval"  // Every interval, starting at startup when Startup is set
+	ScheduleStartup  = "startup"   // Once as the scheduler starts, e.g. an import
+	ScheduleAfter    = "after"     // Whenever a task it depends on finishes
+	ScheduleCalendar = "calendar"  // At the times Next gives, e.g. weekly on Monday at 08:00
+	ScheduleOnDemand = "on_demand" // Only when triggered from the API or CLI
+)
+
+var (
+	ErrUnknownTask      = errors.New("unknown task")
+	ErrTaskRunning      = errors.New("task is already running")
+	ErrSchedulerStopped = errors.New("scheduler is not running")
+)
+
+// randomDelay picks the spread of a scheduled task's first run, below max
+var randomDelay = func(max time.Duration) time.Duration {
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+type triggerKey struct{}
+
+// Trigger returns why a task is running, its schedule (interval, startup, after, calendar) or on_demand
+func Trigger(ctx context.Context) string {
+	trigger, _ := ctx.Value(triggerKey{}).(string)
+	return trigger
+}
+
+// Task is a job the Scheduler runs
+type Task struct {
+	Name     string
+	Interval time.Duration // Time between runs, 0 runs the task only at startup, after its dependencies, or on demand
+	Startup  bool          // Run once as the scheduler starts
+	After    []string      // Tasks this one depends on: it runs after each of them succeeds, and waits for them to finish
+	Run      func(ctx context.Context) error
+	Next     func(now time.Time) time.Time // The next time to run after now, see Weekly; takes the place of Interval
+}
+
+// Schedule is calendar, interval, startup, after, or on_demand
+func (t Task) Schedule() string {
+	switch {
+	case t.Next != nil:
+		return ScheduleCalendar
+	case t.Interval > 0:
+		return ScheduleInterval
+	case t.Startup:
+		return ScheduleStartup
+	case len(t.After) > 0:
+		return ScheduleAfter
+	default:
+		return ScheduleOnDemand
+	}
+}
+
+// TaskStatus is the state of a task as served by /api/tasks
+type TaskStatus struct {
+	Name         string     `json:"name"`
+	Schedule     string     `json:"schedule"`
+	Interval     int64      `json:"interval,omitempty"` // Seconds between runs
+	After        []string   `json:"after,omitempty"`    // Tasks it depends on
+	Running      bool       `json:"running"`
+	Pending      bool       `json:"pending,omitempty"`       // Due, waiting for the tasks it depends on to finish
+	Runs         int        `json:"runs"`                    // Finished runs since startup
+	LastStarted  *time.Time `json:"last_started,omitempty"`  // Start of the current or last run
+	LastDuration float64    `json:"last_duration,omitempty"` // Seconds the last finished run took
+	LastError    string     `json:"last_error,omitempty"`    // Error of the last finished run
+}
+
+type scheduledTask struct {
+	task   Task
+	status TaskStatus
+}
+
+// Scheduler runs tasks every interval, once at startup, after the tasks they depend on, or on demand,
+// never running a task twice at once
+type Scheduler struct {
+	jitter  time.Duration // Most a startup run or the first interval run is delayed
+	history database.TaskRunRepository
+
+	mu    sync.Mutex
+	tasks []*scheduledTask
+	ctx   context.Context // Set while started, runs are cancelled with it
+	runs  sync.WaitGroup  // Running tasks, added to under mu while ctx is set
+}
+
+// NewScheduler creates a scheduler that spreads scheduled tasks over up to jitter after startup, so heavy tasks
+// such as downloads don't all start at once and compete for I/O, and records every run in history unless it's nil
+func NewScheduler(jitter time.Duration, history database.TaskRunRepository) *Scheduler {
+	return &Scheduler{jitter: jitter, history: history}
+}
+
+// Add registers a task before Start, panicking on a duplicate name like http.ServeMux.Handle
+// The tasks it depends on must be added first, so dependencies can't form a cycle.
+func (s *Scheduler) Add(task Task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.find(task.Name) != nil {
+		panic("tasks: duplicate task name " + task.Name)
+	}
+	for _, name := range task.After {
+		if s.find(name) == nil {
+			panic("tasks: " + task.Name + " depends on " + name + ", which must be added first")
+		}
+	}
+	s.tasks = append(s.tasks, &scheduledTask{task: task, status: TaskStatus{
+		Name:     task.Name,
+		Schedule: task.Schedule(),
+		Interval: int64(task.Interval / time.Second),
+		After:    task.After,
+	}})
+}
+
+// find returns the task called name, or nil; the caller holds the lock
+func (s *Scheduler) find(name string) *scheduledTask {
+	for _, t := range s.tasks {
+		if t.task.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// waiting reports whether a task it depends on is running; the caller holds the lock
+func (s *Scheduler) waiting(t *scheduledTask) bool {
+	for _, name := range t.task.After {
+		if s.find(name).status.Running {
+			return true
+		}
+	}
+	return false
+}
+
+// Start runs the startup tasks and then every interval task on its schedule
+// This method blocks until the context is cancelled and running tasks have returned; tasks can be run on demand
+// meanwhile.
+func (s *Scheduler) Start(ctx context.Context) error {
+	s.mu.Lock()
+	s.ctx = ctx
+	tasks := append([]*scheduledTask(nil), s.tasks...)
+	s.mu.Unlock()
+	var wg sync.WaitGroup
+	for _, t := range tasks {
+		if !t.task.Startup && t.task.Interval <= 0 && t.task.Next == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(t *scheduledTask) {
+			defer wg.Done()
+			s.schedule(ctx, t)
+		}(t)
+	}
+
+	<-ctx.Done()
+	wg.Wait()
+	s.mu.Lock()
+	s.ctx = nil
+	s.mu.Unlock()
+	s.runs.Wait()
+	return ctx.Err()
+}
+
+// schedule runs a startup task once and an interval task every interval, after a random delay of up to the
+// jitter (or the interval, when shorter) that sets the task's phase
+func (s *Scheduler) schedule(ctx context.Context, t *scheduledTask) {
+	if t.task.Next != nil {
+		s.calendar(ctx, t)
+		return
+	}
+	spread := s.jitter
+	if t.task.Interval > 0 && spread > t.task.Interval {
+		spread = t.task.Interval
+	}
+	if spread > 0 {
+		delay := randomDelay(spread)
+		slog.Debug("Delaying task", "task", t.task.Name, "delay", delay)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+
+	if t.task.Startup {
+		s.start(t, ScheduleStartup)
+	}
+	if t.task.Interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(t.task.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.start(t, ScheduleInterval); errors.Is(err, ErrTaskRunning) {
+				slog.Debug("Skipping task, previous run still going", "task", t.task.Name)
+			}
+		}
+	}
+}
+
+// calendarCheck bounds a calendar task's wait, so a clock set late, e.g. by NTP after a Pi without a real-time clock
+// boots, delays a run by at most this much
+const calendarCheck = time.Minute
+
+// calendar runs a task at each time its Next gives, by the wall clock
+func (s *Scheduler) calendar(ctx context.Context, t *scheduledTask) {
+	next := t.task.Next(time.Now())
+	slog.Debug("Scheduling task", "task", t.task.Name, "next", next)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(min(time.Until(next), calendarCheck)):
+		}
+		if now := time.Now(); !now.Before(next) {
+			if err := s.start(t, ScheduleCalendar); errors.Is(err, ErrTaskRunning) {
+				slog.Debug("Skipping task, previous run still going", "task", t.task.Name)
+			}
+			next = t.task.Next(now)
+		}
+	}
+}
+
+// Weekly is a Task's Next for once a week at the local hour of the day
+func Weekly(day time.Weekday, hour int) func(now time.Time) time.Time {
+	return func(now time.Time) time.Time {
+		now = now.Local()
+		y, m, d := now.Date()
+		next := time.Date(y, m, d+int(day-now.Weekday()+7)%7, hour, 0, 0, 0, time.Local)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 7)
+		}
+		return next
+	}
+}
+
+// Run starts a task now in the background, or once the tasks it depends on finish, failing when it's unknown or
+// already running
+func (s *Scheduler) Run(name string) (TaskStatus, error) {
+	s.mu.Lock()
+	found := s.find(name)
+	s.mu.Unlock()
+	if found == nil {
+		return TaskStatus{}, fmt.Errorf("%w: %s", ErrUnknownTask, name)
+	}
+	err := s.start(found, ScheduleOnDemand)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return found.status, err
+}
+
+// Tasks returns the status of every task in the order they were added
+func (s *Scheduler) Tasks() []TaskStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]TaskStatus, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		statuses = append(statuses, t.status)
+	}
+	return statuses
+}
+
+// start runs a task in its own goroutine unless it is already running, or marks it pending while a task it
+// depends on is running
+func (s *Scheduler) start(t *scheduledTask, trigger string) error {
+	s.mu.Lock()
+	ctx := s.ctx
+	switch {
+	case ctx == nil:
+		s.mu.Unlock()
+		return ErrSchedulerStopped
+	case t.status.Running:
+		s.mu.Unlock()
+		return ErrTaskRunning
+	case s.waiting(t):
+		t.status.Pending = true
+		s.mu.Unlock()
+		slog.Debug("Task waiting for the tasks it depends on", "task", t.task.Name, "after", t.task.After)
+		return nil
+	}
+	started := time.Now()
+	t.status.Running = true
+	t.status.Pending = false
+	t.status.LastStarted = &started
+	s.runs.Add(1)
+	s.mu.Unlock()
+
+	slog.Debug("Running task", "task", t.task.Name, "trigger", trigger)
+	go func() {
+		defer s.runs.Done()
+		defer crash.Recover()
+		err := t.task.Run(context.WithValue(ctx, triggerKey{}, trigger))
+		finished := time.Now()
+		s.record(t.task.Name, trigger, started, finished, err)
+
+		s.mu.Lock()
+		t.status.Running = false
+		t.status.Runs++
+		t.status.LastDuration = finished.Sub(started).Seconds()
+		t.status.LastError = ""
+		if err != nil {
+			t.status.LastError = err.Error()
+		}
+		// Dependents run after a success; those already due run either way, they were only waiting
+		var next []*scheduledTask
+		for _, d := range s.tasks {
+			for _, name := range d.task.After {
+				if name == t.task.Name && (err == nil || d.status.Pending) {
+					next = append(next, d)
+				}
+			}
+		}
+		s.mu.Unlock()
+
+		if err != nil && ctx.Err() == nil {
+			slog.Error("Task failed", "task", t.task.Name, "error", err)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		for _, d := range next {
+			if err := s.start(d, ScheduleAfter); errors.Is(err, ErrTaskRunning) {
+				slog.Debug("Skipping task, previous run still going", "task", d.task.Name)
+			}
+		}
+	}()
+	return nil
+}
+
+// record stores a finished run in the history
+func (s *Scheduler) record(name, trigger string, started, finished time.Time, err error) {
+	if s.history == nil {
+		return
+	}
+	run := &database.TaskRun{Task: name, Trigger: trigger, Started: started, Finished: finished}
+	if err != nil {
+		run.Error = err.Error()
+	}
+	if err := s.history.Insert(run); err != nil {
+		slog.Warn("Failed to record task run", "task", name, "error", err)
+	}
+}
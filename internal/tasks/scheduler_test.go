@@ -0,0 +1,269 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitRuns waits for a task to finish at least n runs
+func waitRuns(t *testing.T, s *Scheduler, name string, n int) TaskStatus {
+	t.Helper()
+	var status TaskStatus
+	require.Eventually(t, func() bool {
+		for _, st := range s.Tasks() {
+			if st.Name == name {
+				status = st
+			}
+		}
+		return status.Runs >= n && !status.Running
+	}, 2*time.Second, 5*time.Millisecond)
+	return status
+}
+
+func TestScheduler(t *testing.T) {
+	s := NewScheduler(0, nil)
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	s.Add(Task{Name: "import", Startup: true, Run: func(ctx context.Context) error { return nil }})
+	s.Add(Task{Name: "sync", Run: func(ctx context.Context) error {
+		started <- struct{}{}
+		<-release
+		return errors.New("list unavailable")
+	}})
+	s.Add(Task{Name: "poll", Interval: 10 * time.Millisecond, Run: func(ctx context.Context) error { return nil }})
+	assert.Panics(t, func() { s.Add(Task{Name: "sync"}) })
+
+	_, err := s.Run("sync")
+	assert.ErrorIs(t, err, ErrSchedulerStopped, "runs need the scheduler's context")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Start(ctx) }()
+
+	status := waitRuns(t, s, "import", 1)
+	assert.Equal(t, ScheduleStartup, status.Schedule)
+	status = waitRuns(t, s, "poll", 2)
+	assert.Equal(t, ScheduleInterval, status.Schedule)
+
+	require.Eventually(t, func() bool {
+		_, err := s.Run("sync")
+		return err == nil
+	}, time.Second, 5*time.Millisecond)
+	<-started
+	status, err = s.Run("sync")
+	assert.ErrorIs(t, err, ErrTaskRunning)
+	assert.True(t, status.Running)
+	close(release)
+	status = waitRuns(t, s, "sync", 1)
+	assert.Equal(t, ScheduleOnDemand, status.Schedule)
+	assert.Equal(t, "list unavailable", status.LastError)
+	assert.Len(t, started, 0, "on demand tasks only run when asked")
+
+	_, err = s.Run("purge")
+	assert.ErrorIs(t, err, ErrUnknownTask)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestScheduler_After(t *testing.T) {
+	s := NewScheduler(0, nil)
+	release := make(chan struct{})
+	var fail bool
+	s.Add(Task{Name: "prune", Run: func(ctx context.Context) error {
+		<-release
+		if fail {
+			return errors.New("database locked")
+		}
+		return nil
+	}})
+	s.Add(Task{Name: "rollup", After: []string{"prune"}, Run: func(ctx context.Context) error { return nil }})
+	s.Add(Task{Name: "digest", After: []string{"rollup"}, Run: func(ctx context.Context) error { return nil }})
+	assert.Panics(t, func() { s.Add(Task{Name: "report", After: []string{"export"}}) }, "dependencies are added first")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Start(ctx)
+	require.Eventually(t, func() bool {
+		_, err := s.Run("prune")
+		return err == nil
+	}, time.Second, 5*time.Millisecond)
+
+	status, err := s.Run("rollup")
+	require.NoError(t, err)
+	assert.True(t, status.Pending, "waits for prune instead of running alongside it")
+	assert.False(t, status.Running)
+	assert.Equal(t, ScheduleAfter, status.Schedule)
+	assert.Equal(t, []string{"prune"}, status.After)
+
+	release <- struct{}{}
+	waitRuns(t, s, "prune", 1)
+	status = waitRuns(t, s, "rollup", 1)
+	assert.False(t, status.Pending)
+	assert.Equal(t, 1, status.Runs, "the pending run and the run after prune are one")
+	waitRuns(t, s, "digest", 1)
+
+	fail = true
+	_, err = s.Run("prune")
+	require.NoError(t, err)
+	release <- struct{}{}
+	waitRuns(t, s, "prune", 2)
+	time.Sleep(20 * time.Millisecond)
+	for _, st := range s.Tasks() {
+		if st.Name == "rollup" {
+			assert.Equal(t, 1, st.Runs, "nothing runs after a failure")
+		}
+	}
+}
+
+func TestScheduler_Jitter(t *testing.T) {
+	defer func(d func(time.Duration) time.Duration) { randomDelay = d }(randomDelay)
+	var mu sync.Mutex
+	var spreads []time.Duration
+	randomDelay = func(max time.Duration) time.Duration {
+		mu.Lock()
+		defer mu.Unlock()
+		spreads = append(spreads, max)
+		return max
+	}
+
+	s := NewScheduler(100*time.Millisecond, nil)
+	triggers := make(chan string, 10)
+	record := func(ctx context.Context) error {
+		triggers <- Trigger(ctx)
+		return nil
+	}
+	s.Add(Task{Name: "download", Startup: true, Run: record})
+	s.Add(Task{Name: "poll", Interval: 20 * time.Millisecond, Run: func(ctx context.Context) error { return nil }})
+	s.Add(Task{Name: "vacuum", Run: record})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	started := time.Now()
+	go s.Start(ctx)
+
+	require.Eventually(t, func() bool {
+		_, err := s.Run("vacuum")
+		return err == nil
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, ScheduleOnDemand, <-triggers, "on demand runs aren't delayed")
+	assert.Equal(t, ScheduleStartup, <-triggers)
+	assert.GreaterOrEqual(t, time.Since(started), 100*time.Millisecond, "startup runs are spread over the jitter")
+	waitRuns(t, s, "poll", 1)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.ElementsMatch(t, []time.Duration{100 * time.Millisecond, 20 * time.Millisecond}, spreads,
+		"interval tasks are spread over at most their interval")
+}
+
+// mockTaskRuns keeps recorded runs in memory
+type mockTaskRuns struct {
+	mu   sync.Mutex
+	runs []*database.TaskRun
+}
+
+func (m *mockTaskRuns) Insert(run *database.TaskRun) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runs = append(m.runs, run)
+	return nil
+}
+
+func (m *mockTaskRuns) List(task string, since time.Time, limit int) ([]*database.TaskRun, error) {
+	return nil, nil
+}
+
+func (m *mockTaskRuns) Summary(since time.Time) ([]*database.TaskRunSummary, error) {
+	return nil, nil
+}
+
+func TestScheduler_History(t *testing.T) {
+	history := &mockTaskRuns{}
+	s := NewScheduler(0, history)
+	s.Add(Task{Name: "vacuum", Startup: true, Run: func(ctx context.Context) error { return errors.New("database is locked") }})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Start(ctx)
+	waitRuns(t, s, "vacuum", 1)
+
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	require.Len(t, history.runs, 1)
+	run := history.runs[0]
+	assert.Equal(t, "vacuum", run.Task)
+	assert.Equal(t, ScheduleStartup, run.Trigger)
+	assert.Equal(t, "database is locked", run.Error)
+	assert.False(t, run.Finished.Before(run.Started))
+}
+
+func TestScheduler_StopWaitsForRuns(t *testing.T) {
+	s := NewScheduler(0, nil)
+	running := make(chan struct{})
+	var finished atomic.Bool
+	s.Add(Task{Name: "vacuum", Startup: true, Run: func(ctx context.Context) error {
+		close(running)
+		<-ctx.Done()
+		time.Sleep(20 * time.Millisecond) // Wrapping up after cancellation
+		finished.Store(true)
+		return ctx.Err()
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Start(ctx) }()
+	<-running
+	cancel()
+	<-done
+	assert.True(t, finished.Load(), "Start returns once running tasks have")
+	_, err := s.Run("vacuum")
+	assert.ErrorIs(t, err, ErrSchedulerStopped)
+}
+
+func TestScheduler_Calendar(t *testing.T) {
+	s := NewScheduler(time.Hour, nil)
+	var times atomic.Int32
+	s.Add(Task{Name: "weekly_report", Next: func(now time.Time) time.Time {
+		if times.Add(1) > 2 {
+			return now.Add(time.Hour)
+		}
+		return now.Add(20 * time.Millisecond)
+	}, Run: func(ctx context.Context) error {
+		assert.Equal(t, ScheduleCalendar, Trigger(ctx))
+		return nil
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Start(ctx)
+	status := waitRuns(t, s, "weekly_report", 2)
+	assert.Equal(t, ScheduleCalendar, status.Schedule, "calendar tasks aren't delayed by the jitter")
+}
+
+func TestWeekly(t *testing.T) {
+	defer func(tz *time.Location) { time.Local = tz }(time.Local)
+	time.Local = time.FixedZone("CET", 3600)
+	next := Weekly(time.Monday, 8)
+
+	tests := []struct {
+		now  time.Time
+		want time.Time
+	}{
+		{time.Date(2024, 5, 15, 12, 0, 0, 0, time.Local), time.Date(2024, 5, 20, 8, 0, 0, 0, time.Local)}, // Wednesday
+		{time.Date(2024, 5, 20, 7, 59, 0, 0, time.Local), time.Date(2024, 5, 20, 8, 0, 0, 0, time.Local)}, // Monday, before
+		{time.Date(2024, 5, 20, 8, 0, 0, 0, time.Local), time.Date(2024, 5, 27, 8, 0, 0, 0, time.Local)},  // Monday, on the hour
+		{time.Date(2024, 5, 26, 23, 30, 0, 0, time.UTC), time.Date(2024, 5, 27, 8, 0, 0, 0, time.Local)},  // Monday already in CET
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, next(tt.now), tt.now)
+	}
+}
@@ -0,0 +1,86 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/tracker"
+)
+
+// StateSnapshotter periodically stores the full tracker state, so the sky can be replayed without raw messages
+type StateSnapshotter struct {
+	tracker   *tracker.Tracker
+	repo      database.StateSnapshotRepository
+	interval  time.Duration
+	retention time.Duration // Snapshots older than this are deleted, 0 keeps them forever
+	stored    func()        // Called after each stored snapshot, nil for nothing
+}
+
+func NewStateSnapshotter(t *tracker.Tracker, repo database.StateSnapshotRepository, interval, retention time.Duration) *StateSnapshotter {
+	return &StateSnapshotter{tracker: t, repo: repo, interval: interval, retention: retention}
+}
+
+// OnStored registers a function called after each snapshot is stored, e.g. to drop statistics cached from the
+// snapshots before it
+func (s *StateSnapshotter) OnStored(fn func()) {
+	s.stored = fn
+}
+
+// Start stores a snapshot every interval and prunes expired snapshots hourly
+// This method blocks until the context is cancelled.
+func (s *StateSnapshotter) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.prune()
+	pruned := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now := <-ticker.C:
+			if err := s.Snapshot(now); err != nil {
+				slog.Error("Failed to store state snapshot", "error", err)
+			}
+			if now.Sub(pruned) >= time.Hour {
+				s.prune()
+				pruned = now
+			}
+		}
+	}
+}
+
+// Snapshot stores the current tracker state as taken at now
+func (s *StateSnapshotter) Snapshot(now time.Time) error {
+	states := s.tracker.Snapshot()
+	aircraft, err := json.Marshal(states)
+	if err != nil {
+		return fmt.Errorf("failed to encode tracker state: %w", err)
+	}
+	if err := s.repo.Insert(&database.StateSnapshot{Time: now, Count: len(states), Aircraft: aircraft}); err != nil {
+		return err
+	}
+	if s.stored != nil {
+		s.stored()
+	}
+	return nil
+}
+
+func (s *StateSnapshotter) prune() {
+	if s.retention <= 0 {
+		return
+	}
+	deleted, err := s.repo.DeleteBefore(time.Now().Add(-s.retention))
+	if err != nil {
+		slog.Error("Failed to prune state snapshots", "error", err)
+		return
+	}
+	if deleted > 0 {
+		slog.Debug("Pruned state snapshots", "deleted", deleted)
+	}
+}
@@ -0,0 +1,64 @@
+package tasks
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/tracker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockSnapshotRepository keeps inserted snapshots in memory
+type mockSnapshotRepository struct {
+	snapshots []*database.StateSnapshot
+}
+
+func (m *mockSnapshotRepository) Insert(snapshot *database.StateSnapshot) error {
+	m.snapshots = append(m.snapshots, snapshot)
+	return nil
+}
+
+func (m *mockSnapshotRepository) Range(from, to time.Time, limit int) ([]*database.StateSnapshot, error) {
+	return m.snapshots, nil
+}
+
+func (m *mockSnapshotRepository) DeleteBefore(t time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockSnapshotRepository) BandCounts(from, to time.Time) ([]*database.BandCount, int, error) {
+	return nil, 0, nil
+}
+
+func TestStateSnapshotter_Snapshot(t *testing.T) {
+	tr := tracker.New(time.Minute)
+	tr.Update(&models.BeastMessage{
+		MessageTypeCode: models.BeastTypeModeSLong,
+		Message:         []byte{0x8D, 0x48, 0x40, 0xD6},
+		ICAO:            "4840D6",
+		SignalLevel:     120,
+	})
+
+	repo := &mockSnapshotRepository{}
+	snapshotter := NewStateSnapshotter(tr, repo, 10*time.Second, 0)
+	var stored int
+	snapshotter.OnStored(func() { stored++ })
+
+	now := time.Now()
+	require.NoError(t, snapshotter.Snapshot(now))
+	require.Len(t, repo.snapshots, 1)
+	assert.Equal(t, 1, stored)
+	assert.Equal(t, now, repo.snapshots[0].Time)
+	assert.Equal(t, 1, repo.snapshots[0].Count)
+
+	var states []tracker.AircraftState
+	require.NoError(t, json.Unmarshal(repo.snapshots[0].Aircraft, &states))
+	require.Len(t, states, 1)
+	assert.Equal(t, "4840D6", states[0].ICAO)
+	assert.Equal(t, uint8(120), states[0].SignalLevel)
+}
@@ -0,0 +1,86 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/importer"
+)
+
+// TagList is a special aircraft list imported on a schedule
+type TagList struct {
+	Name     string
+	Location string // http(s) URL or local file path
+}
+
+// TagSync keeps the aircraft_tags table up to date with the configured lists
+type TagSync struct {
+	repo     database.TagRepository
+	lists    []TagList
+	interval time.Duration
+}
+
+func NewTagSync(repo database.TagRepository, lists []TagList, interval time.Duration) *TagSync {
+	return &TagSync{repo: repo, lists: lists, interval: interval}
+}
+
+// Run is the tags task: at startup it imports the lists that are missing or older than the interval, so
+// restarts don't re-download everything, and otherwise every list. A failed import keeps the previous copy.
+func (s *TagSync) Run(ctx context.Context) error {
+	if Trigger(ctx) == ScheduleStartup {
+		s.syncStale(ctx)
+		return nil
+	}
+	return s.Sync(ctx)
+}
+
+// SyncAll imports every configured list now, returning the number of lists that failed
+func (s *TagSync) SyncAll(ctx context.Context) int {
+	failed := 0
+	for _, list := range s.lists {
+		if !s.sync(ctx, list) {
+			failed++
+		}
+	}
+	return failed
+}
+
+// syncStale imports only the lists not refreshed within the interval, so restarts don't re-download everything
+func (s *TagSync) syncStale(ctx context.Context) {
+	sources, err := s.repo.Sources()
+	if err != nil {
+		slog.Warn("Failed to read tag list status, importing all lists", "error", err)
+	}
+	updated := make(map[string]time.Time)
+	for _, source := range sources {
+		updated[source.Name] = source.UpdatedAt
+	}
+
+	for _, list := range s.lists {
+		if time.Since(updated[list.Name]) < s.interval {
+			continue
+		}
+		s.sync(ctx, list)
+	}
+}
+
+// Sync imports every configured list now
+func (s *TagSync) Sync(ctx context.Context) error {
+	if failed := s.SyncAll(ctx); failed > 0 {
+		return fmt.Errorf("%d of %d tag lists failed to import", failed, len(s.lists))
+	}
+	return nil
+}
+
+func (s *TagSync) sync(ctx context.Context, list TagList) bool {
+	count, err := importer.ImportTagList(ctx, s.repo, list.Name, list.Location)
+	if err != nil {
+		slog.Error("Failed to import tag list", "list", list.Name, "error", err)
+		return false
+	}
+	slog.Info("Imported tag list", "list", list.Name, "aircraft", count)
+	return true
+}
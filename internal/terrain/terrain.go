@@ -0,0 +1,178 @@
+// Package terrain models how far the receiver can see: the line-of-sight range to aircraft at a given altitude in
+// each direction, over SRTM elevation data and the curvature of the earth
+package terrain
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"flight_trmnl/internal/decoder"
+)
+
+// effectiveRadius is the earth's radius in metres scaled by 4/3, the usual allowance for radio waves bending with
+// the atmosphere, so they reach a little beyond the optical horizon
+const effectiveRadius = 6371000 * 4.0 / 3
+
+// step is how far apart in metres terrain is sampled along each bearing, a couple of SRTM3 cells
+const step = 200
+
+// maxRange is the furthest a range is followed in metres, as far as positions are believed
+const maxRange = 700000
+
+// void marks an SRTM sample without data
+const void = -32768
+
+// Elevations looks up the ground elevation in metres above sea level
+type Elevations interface {
+	Elevation(lat, lon float64) (float64, error)
+}
+
+// Tiles reads elevations from a directory of SRTM .hgt tiles, such as N51W001.hgt, each a square grid of
+// big-endian 16-bit samples covering one degree. 1 and 3 arc-second tiles both work. Tiles are read from disk as
+// needed rather than held in memory; a missing tile is sea level, as SRTM has none over the ocean.
+type Tiles struct {
+	dir   string
+	mu    sync.Mutex
+	tiles map[string]*tile
+}
+
+type tile struct {
+	file *os.File // nil when the tile doesn't exist
+	side int      // Samples along each edge
+}
+
+// OpenTiles opens a directory of SRTM tiles
+func OpenTiles(dir string) (*Tiles, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open terrain directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("terrain path %s is not a directory", dir)
+	}
+	return &Tiles{dir: dir, tiles: make(map[string]*tile)}, nil
+}
+
+// Elevation returns the elevation of the sample nearest to a position
+func (t *Tiles) Elevation(lat, lon float64) (float64, error) {
+	south, west := math.Floor(lat), math.Floor(lon)
+	tl, err := t.tile(tileName(south, west))
+	if err != nil || tl.file == nil {
+		return 0, err
+	}
+
+	// Rows run from the north edge, columns from the west edge, and neighbouring tiles share their edges
+	row := int(math.Round((south + 1 - lat) * float64(tl.side-1)))
+	col := int(math.Round((lon - west) * float64(tl.side-1)))
+	var sample [2]byte
+	if _, err := tl.file.ReadAt(sample[:], int64(row*tl.side+col)*2); err != nil {
+		return 0, fmt.Errorf("failed to read terrain tile: %w", err)
+	}
+	if elevation := int16(binary.BigEndian.Uint16(sample[:])); elevation != void {
+		return float64(elevation), nil
+	}
+	return 0, nil
+}
+
+// Close closes the tiles read so far
+func (t *Tiles) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var errs []error
+	for _, tl := range t.tiles {
+		if tl.file != nil {
+			errs = append(errs, tl.file.Close())
+		}
+	}
+	t.tiles = make(map[string]*tile)
+	return errors.Join(errs...)
+}
+
+func (t *Tiles) tile(name string) (*tile, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if tl, ok := t.tiles[name]; ok {
+		return tl, nil
+	}
+
+	tl := &tile{}
+	file, err := os.Open(filepath.Join(t.dir, name))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to open terrain tile: %w", err)
+	default:
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to open terrain tile: %w", err)
+		}
+		side := int(math.Sqrt(float64(info.Size() / 2)))
+		if side < 2 || int64(side*side*2) != info.Size() {
+			file.Close()
+			return nil, fmt.Errorf("terrain tile %s is not a square grid of 16-bit samples", name)
+		}
+		tl.file, tl.side = file, side
+	}
+	t.tiles[name] = tl
+	return tl, nil
+}
+
+// tileName is the name of the tile whose south-west corner is at a whole degree, e.g. N51W001.hgt
+func tileName(south, west float64) string {
+	ns, ew := 'N', 'E'
+	if south < 0 {
+		ns, south = 'S', -south
+	}
+	if west < 0 {
+		ew, west = 'W', -west
+	}
+	return fmt.Sprintf("%c%02d%c%03d.hgt", ns, int(south), ew, int(west))
+}
+
+// Ranges returns the line-of-sight range in kilometres from an antenna antennaHeight metres above the ground at
+// receiver to aircraft altitude metres above sea level, for each whole degree of bearing from north. Along each
+// bearing, the range ends where the aircraft would drop below the highest angle terrain rises to before it.
+func Ranges(elevations Elevations, receiver decoder.Position, antennaHeight, altitude float64) ([]float64, error) {
+	ground, err := elevations.Elevation(receiver.Latitude, receiver.Longitude)
+	if err != nil {
+		return nil, err
+	}
+	antenna := ground + antennaHeight
+
+	ranges := make([]float64, 360)
+	for bearing := range ranges {
+		horizon := math.Inf(-1) // Highest elevation angle of the terrain so far, in radians
+		distance := float64(step)
+		for ; distance <= maxRange; distance += step {
+			// Elevation angles from the antenna, small-angle, with the earth falling away below them
+			if target := (altitude-antenna)/distance - distance/(2*effectiveRadius); target < horizon {
+				break
+			}
+			at := destination(receiver, float64(bearing), distance)
+			elevation, err := elevations.Elevation(at.Latitude, at.Longitude)
+			if err != nil {
+				return nil, err
+			}
+			horizon = max(horizon, (elevation-antenna)/distance-distance/(2*effectiveRadius))
+		}
+		ranges[bearing] = (distance - step) / 1000
+	}
+	return ranges, nil
+}
+
+// destination returns the position distance metres from start along a great circle with an initial bearing
+func destination(start decoder.Position, bearing, distance float64) decoder.Position {
+	const earthRadius = 6371000.0
+	lat1, lon1 := start.Latitude*math.Pi/180, start.Longitude*math.Pi/180
+	theta, delta := bearing*math.Pi/180, distance/earthRadius
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(delta) + math.Cos(lat1)*math.Sin(delta)*math.Cos(theta))
+	lon2 := lon1 + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(lat1), math.Cos(delta)-math.Sin(lat1)*math.Sin(lat2))
+	return decoder.Position{Latitude: lat2 * 180 / math.Pi, Longitude: math.Mod(lon2*180/math.Pi+540, 360) - 180}
+}
@@ -0,0 +1,81 @@
+package terrain
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"flight_trmnl/internal/decoder"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// elevationFunc adapts a function to Elevations
+type elevationFunc func(lat, lon float64) float64
+
+func (f elevationFunc) Elevation(lat, lon float64) (float64, error) { return f(lat, lon), nil }
+
+var receiver = decoder.Position{Latitude: 52.0, Longitude: 4.0}
+
+func TestTiles(t *testing.T) {
+	// A 3x3 tile: rows from the north edge, with a void in the middle
+	samples := []int16{100, 110, 120, 200, void, 220, 300, 310, 320}
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, "N51W001.hgt"))
+	require.NoError(t, err)
+	require.NoError(t, binary.Write(f, binary.BigEndian, samples))
+	require.NoError(t, f.Close())
+
+	tiles, err := OpenTiles(dir)
+	require.NoError(t, err)
+	defer tiles.Close()
+
+	for _, tc := range []struct {
+		lat, lon, want float64
+	}{
+		{51.99, -0.99, 100}, // Nearest the north-west corner
+		{51.01, -0.01, 320}, // Nearest the south-east corner
+		{51.5, -0.99, 200},
+		{51.5, -0.5, 0}, // Void
+		{45.0, 10.0, 0}, // No tile, sea level
+	} {
+		elevation, err := tiles.Elevation(tc.lat, tc.lon)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, elevation, "%.2f, %.2f", tc.lat, tc.lon)
+	}
+
+	_, err = OpenTiles(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestTileName(t *testing.T) {
+	assert.Equal(t, "N51W001.hgt", tileName(51, -1))
+	assert.Equal(t, "S34E151.hgt", tileName(-34, 151))
+}
+
+func TestRanges(t *testing.T) {
+	altitude := 10000.0
+	flat, err := Ranges(elevationFunc(func(lat, lon float64) float64 { return 0 }), receiver, 10, altitude)
+	require.NoError(t, err)
+	require.Len(t, flat, 360)
+	// Over a smooth earth the range is the antenna's radio horizon plus the aircraft's
+	horizon := (math.Sqrt(2*effectiveRadius*10) + math.Sqrt(2*effectiveRadius*altitude)) / 1000
+	assert.InDelta(t, horizon, flat[0], 1)
+	assert.InDelta(t, flat[0], flat[180], 0.5)
+
+	// A 1000 m ridge 20 km east of the receiver
+	ridge := elevationFunc(func(lat, lon float64) float64 {
+		at := decoder.Position{Latitude: lat, Longitude: lon}
+		if d := decoder.Distance(receiver, at); d > 19.9 && d < 20.5 && decoder.Bearing(receiver, at) > 60 && decoder.Bearing(receiver, at) < 120 {
+			return 1000
+		}
+		return 0
+	})
+	ranges, err := Ranges(ridge, receiver, 10, altitude)
+	require.NoError(t, err)
+	assert.Less(t, ranges[90], 250.0, "the ridge hides low-angle aircraft behind it")
+	assert.InDelta(t, flat[0], ranges[0], 0.5)
+}
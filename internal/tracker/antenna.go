@@ -0,0 +1,55 @@
+package tracker
+
+import (
+	"math"
+	"time"
+
+	"flight_trmnl/internal/decoder"
+	"flight_trmnl/internal/models"
+)
+
+// AntennaSector is what the receiver heard from one direction between two snapshots of the tracker
+type AntennaSector struct {
+	Bearing  float64 `json:"bearing"`  // Centre of the sector, degrees true from the receiver
+	Aircraft int     `json:"aircraft"` // Aircraft heard from
+	Rate     float64 `json:"rate"`     // Messages per second
+	Signal   float64 `json:"signal"`   // Mean signal level in dBFS of the aircraft heard from, 0 when none
+}
+
+// AntennaSectors splits the messages received between two snapshots into count equal sectors by the bearing of
+// the aircraft from the receiver, the first centred on north. Aircraft without a position in the later snapshot
+// are left out, and so are those without new messages.
+func AntennaSectors(receiver decoder.Position, before, after []AircraftState, elapsed time.Duration, count int) []AntennaSector {
+	width := 360 / float64(count)
+	sectors := make([]AntennaSector, count)
+	for i := range sectors {
+		sectors[i].Bearing = float64(i) * width
+	}
+
+	previous := make(map[string]int64, len(before))
+	for _, state := range before {
+		previous[state.ICAO] = state.Messages
+	}
+	signals := make([]float64, count)
+	for _, state := range after {
+		messages := state.Messages - previous[state.ICAO]
+		if messages <= 0 || state.Latitude == nil || state.Longitude == nil {
+			continue
+		}
+		bearing := decoder.Bearing(receiver, decoder.Position{Latitude: *state.Latitude, Longitude: *state.Longitude})
+		i := int(math.Floor(math.Mod(bearing+width/2, 360)/width)) % count
+		sectors[i].Aircraft++
+		sectors[i].Rate += float64(messages)
+		signals[i] += models.SignalDBFS(state.SignalLevel)
+	}
+
+	for i := range sectors {
+		if sectors[i].Aircraft > 0 {
+			sectors[i].Signal = signals[i] / float64(sectors[i].Aircraft)
+		}
+		if elapsed > 0 {
+			sectors[i].Rate /= elapsed.Seconds()
+		}
+	}
+	return sectors
+}
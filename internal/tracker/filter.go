@@ -0,0 +1,128 @@
+package tracker
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"flight_trmnl/internal/filter"
+	"flight_trmnl/internal/models"
+)
+
+// Filter selects which aircraft a client receives
+// Filters are parsed from query parameters so every streaming and snapshot endpoint accepts the same ones:
+//
+//	icao=A1B2C3,ABCDEF       only these aircraft
+//	type=extended_squitter   only aircraft whose last message had one of these types
+//	min_signal=40            only aircraft received at or above this raw signal level
+//	band=surface,low         only aircraft in these altitude bands: surface, low, mid, or high
+//	min_altitude=5000        only aircraft reporting at least this altitude in feet, likewise max_altitude
+//	where=speed>400          only aircraft matching a filter expression over their fields, see filter.Aircraft
+type Filter struct {
+	ICAOs        map[string]bool
+	MessageTypes map[string]bool
+	MinSignal    uint8
+	Bands        map[string]bool
+	MinAltitude  *int
+	MaxAltitude  *int
+	Where        *filter.Expr
+}
+
+// ParseFilter builds a filter from URL query parameters
+func ParseFilter(query url.Values) (Filter, error) {
+	f := Filter{
+		ICAOs:        parseList(query.Get("icao"), strings.ToUpper),
+		MessageTypes: parseList(query.Get("type"), strings.ToLower),
+		Bands:        parseList(query.Get("band"), strings.ToLower),
+	}
+
+	if v := query.Get("min_signal"); v != "" {
+		signal, err := strconv.ParseUint(v, 10, 8)
+		if err != nil {
+			return Filter{}, fmt.Errorf("invalid min_signal %q: must be 0-255", v)
+		}
+		f.MinSignal = uint8(signal)
+	}
+	if err := checkBands(f.Bands); err != nil {
+		return Filter{}, err
+	}
+	for _, param := range []struct {
+		name  string
+		value **int
+	}{{"min_altitude", &f.MinAltitude}, {"max_altitude", &f.MaxAltitude}} {
+		if v := query.Get(param.name); v != "" {
+			feet, err := strconv.Atoi(v)
+			if err != nil {
+				return Filter{}, fmt.Errorf("invalid %s %q: must be feet", param.name, v)
+			}
+			*param.value = &feet
+		}
+	}
+
+	where, err := filter.Compile(query.Get("where"), filter.KindAircraft)
+	if err != nil {
+		return Filter{}, fmt.Errorf("invalid where: %w", err)
+	}
+	f.Where = where
+
+	return f, nil
+}
+
+// checkBands rejects unknown altitude band names
+func checkBands(bands map[string]bool) error {
+	for band := range bands {
+		if !models.IsAltitudeBand(band) {
+			return fmt.Errorf("invalid band %q: must be one of %s", band, strings.Join(models.AltitudeBands, ", "))
+		}
+	}
+	return nil
+}
+
+// NewFilter builds a filter from lists, e.g. from configuration, which checks the band names
+func NewFilter(icaos, messageTypes, bands []string, minSignal uint8) Filter {
+	return Filter{
+		ICAOs:        parseList(strings.Join(icaos, ","), strings.ToUpper),
+		MessageTypes: parseList(strings.Join(messageTypes, ","), strings.ToLower),
+		Bands:        parseList(strings.Join(bands, ","), strings.ToLower),
+		MinSignal:    minSignal,
+	}
+}
+
+// parseList splits a comma separated parameter into a set, nil when empty
+func parseList(value string, normalize func(string) string) map[string]bool {
+	if value == "" {
+		return nil
+	}
+	set := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			set[normalize(item)] = true
+		}
+	}
+	return set
+}
+
+// Match reports whether an aircraft passes the filter
+func (f Filter) Match(state AircraftState) bool {
+	if f.ICAOs != nil && !f.ICAOs[state.ICAO] {
+		return false
+	}
+	if f.MessageTypes != nil && !f.MessageTypes[state.MessageType] {
+		return false
+	}
+	if f.Bands != nil && !f.Bands[state.AltitudeBand] {
+		return false
+	}
+	// Aircraft without a reported altitude, including those on the ground, fail altitude limits
+	if f.MinAltitude != nil && (state.Altitude == nil || *state.Altitude < *f.MinAltitude) {
+		return false
+	}
+	if f.MaxAltitude != nil && (state.Altitude == nil || *state.Altitude > *f.MaxAltitude) {
+		return false
+	}
+	if state.SignalLevel < f.MinSignal {
+		return false
+	}
+	return f.Where == nil || f.Where.Match(filter.Aircraft(state))
+}
@@ -0,0 +1,82 @@
+package tracker
+
+import (
+	"math"
+	"time"
+)
+
+// qualityGap is the longest silence that still counts as continuous reception. Aircraft send several messages a
+// second, so a longer gap means messages were lost.
+const qualityGap = 5 * time.Second
+
+// Quality scores how well the station received one aircraft over a visit
+// Score is 0 to 100: 40% continuity (the share of the visit without gaps), 40% position rate (one decoded position
+// a second scores full marks), and 20% signal stability. Signal levels are in dBFS.
+type Quality struct {
+	Score        int     `json:"score"`
+	Gaps         int     `json:"gaps"`            // Silences longer than 5 seconds
+	LongestGap   float64 `json:"longest_gap"`     // Seconds
+	Positions    int64   `json:"positions"`       // Decoded positions
+	PositionRate float64 `json:"position_rate"`   // Decoded positions per second
+	SignalMean   float64 `json:"signal_mean"`     // dBFS
+	SignalStdDev float64 `json:"signal_stddev"`   // dB
+	Range        float64 `json:"range,omitempty"` // Kilometres to the farthest decoded position, 0 without the receiver's location
+}
+
+// reception accumulates what a visit's Quality is worked out from
+type reception struct {
+	first, last   time.Time
+	messages      int64
+	positions     int64
+	gaps          int
+	gapTime       time.Duration
+	longestGap    time.Duration
+	signalSum     float64
+	signalSquares float64
+	farthest      float64
+}
+
+// observe adds one message received at now with a signal level in dBFS
+func (r *reception) observe(now time.Time, signal float64, positioned bool) {
+	if r.messages == 0 {
+		r.first = now
+	} else if gap := now.Sub(r.last); gap > qualityGap {
+		r.gaps++
+		r.gapTime += gap
+		r.longestGap = max(r.longestGap, gap)
+	}
+	r.last = now
+	r.messages++
+	if positioned {
+		r.positions++
+	}
+	r.signalSum += signal
+	r.signalSquares += signal * signal
+}
+
+// reach records a decoded position's distance from the receiver in kilometres
+func (r *reception) reach(distance float64) {
+	r.farthest = max(r.farthest, distance)
+}
+
+// quality scores the messages observed so far
+func (r *reception) quality() Quality {
+	q := Quality{Gaps: r.gaps, LongestGap: r.longestGap.Seconds(), Positions: r.positions,
+		Range: math.Round(r.farthest*10) / 10}
+	if r.messages == 0 {
+		return q
+	}
+
+	duration := max(r.last.Sub(r.first), time.Second)
+	continuity := 1 - r.gapTime.Seconds()/duration.Seconds()
+	q.PositionRate = float64(r.positions) / duration.Seconds()
+	q.SignalMean = r.signalSum / float64(r.messages)
+	q.SignalStdDev = math.Sqrt(max(0, r.signalSquares/float64(r.messages)-q.SignalMean*q.SignalMean))
+	stability := max(0, 1-q.SignalStdDev/6) // A spread of 6 dB or more is unstable
+
+	q.Score = int(math.Round(100 * (0.4*continuity + 0.4*min(1, q.PositionRate) + 0.2*stability)))
+	q.PositionRate = math.Round(q.PositionRate*100) / 100
+	q.SignalMean = math.Round(q.SignalMean*10) / 10
+	q.SignalStdDev = math.Round(q.SignalStdDev*10) / 10
+	return q
+}
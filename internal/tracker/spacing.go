@@ -0,0 +1,93 @@
+package tracker
+
+import (
+	"math"
+	"sort"
+
+	"flight_trmnl/internal/decoder"
+	"flight_trmnl/internal/models"
+)
+
+// Limits for telling a pair of aircraft in trail on the same approach from traffic that merely passes nearby
+const (
+	approachCeiling     = 6000  // Feet, aircraft above aren't on final approach
+	approachDescent     = -300  // Feet per minute, aircraft must descend at least this fast
+	approachMaxSpacing  = 15.0  // Nautical miles, farther apart aircraft aren't paired
+	approachTrackDiff   = 20.0  // Degrees, the most the two tracks may differ
+	approachBearingDiff = 20.0  // Degrees, the most the leader may be off the follower's nose
+	kmPerNauticalMile   = 1.852 // For converting decoder distances
+)
+
+// Spacing is one pair of aircraft in trail on approach, the follower behind the leader
+type Spacing struct {
+	Leader         AircraftState `json:"leader"`
+	Follower       AircraftState `json:"follower"`
+	LeaderWake     string        `json:"leader_wake,omitempty"`   // Wake turbulence category, e.g. heavy
+	FollowerWake   string        `json:"follower_wake,omitempty"` // Wake turbulence category, e.g. small
+	Distance       float64       `json:"distance"`                // Nautical miles between them
+	Seconds        int           `json:"seconds"`                 // Time for the follower to reach the leader's position
+	MinimumSpacing float64       `json:"minimum_spacing"`         // Nautical miles required for the pair's categories
+	Tight          bool          `json:"tight"`                   // Closer than the minimum spacing
+}
+
+// ApproachSpacing pairs each aircraft on approach with the nearest one ahead of it on the same track, tightest
+// first. An aircraft is on approach while it's airborne below 6,000 ft and descending, with a position, track, and
+// speed.
+func ApproachSpacing(states []AircraftState) []Spacing {
+	var approaching []AircraftState
+	for _, state := range states {
+		if onApproach(state) {
+			approaching = append(approaching, state)
+		}
+	}
+
+	var pairs []Spacing
+	for _, follower := range approaching {
+		from := decoder.Position{Latitude: *follower.Latitude, Longitude: *follower.Longitude}
+		var best *Spacing
+		for _, leader := range approaching {
+			if leader.ICAO == follower.ICAO || angleDiff(*leader.Track, *follower.Track) > approachTrackDiff {
+				continue
+			}
+			to := decoder.Position{Latitude: *leader.Latitude, Longitude: *leader.Longitude}
+			if angleDiff(decoder.Bearing(from, to), *follower.Track) > approachBearingDiff {
+				continue
+			}
+			distance := decoder.Distance(from, to) / kmPerNauticalMile
+			if distance > approachMaxSpacing || (best != nil && distance >= best.Distance) {
+				continue
+			}
+			best = &Spacing{Leader: leader, Follower: follower, Distance: distance}
+		}
+		if best == nil {
+			continue
+		}
+		best.LeaderWake = models.WakeCategory(best.Leader.Category)
+		best.FollowerWake = models.WakeCategory(follower.Category)
+		best.MinimumSpacing = models.MinimumSpacing(best.LeaderWake, best.FollowerWake)
+		best.Tight = best.Distance < best.MinimumSpacing
+		if *follower.Speed > 0 {
+			best.Seconds = int(math.Round(best.Distance / float64(*follower.Speed) * 3600))
+		}
+		best.Distance = math.Round(best.Distance*100) / 100
+		pairs = append(pairs, *best)
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Distance/pairs[i].MinimumSpacing < pairs[j].Distance/pairs[j].MinimumSpacing
+	})
+	return pairs
+}
+
+// onApproach reports whether an aircraft is low, descending, and has what spacing needs
+func onApproach(state AircraftState) bool {
+	return !state.OnGround && state.Altitude != nil && *state.Altitude <= approachCeiling &&
+		state.VerticalRate != nil && *state.VerticalRate <= approachDescent &&
+		state.Latitude != nil && state.Longitude != nil && state.Track != nil && state.Speed != nil
+}
+
+// angleDiff returns the difference between two directions in degrees, 0 to 180
+func angleDiff(a, b float64) float64 {
+	d := math.Mod(math.Abs(a-b), 360)
+	return math.Min(d, 360-d)
+}
@@ -0,0 +1,447 @@
+package tracker
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"flight_trmnl/internal/decoder"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/pkg/schema"
+)
+
+// Update types sent to subscribers
+const (
+	UpdateAircraft = "update" // Aircraft state changed
+	UpdateRemove   = "remove" // Aircraft expired after going silent
+)
+
+// advisoryTimeout is how long an aircraft keeps its TCAS advisory without another broadcast of it. Aircraft stop
+// broadcasting about 18 seconds after the advisory ends.
+const advisoryTimeout = 30 * time.Second
+
+// AircraftState is the live state of one aircraft as heard by the receiver
+type AircraftState = schema.Aircraft
+
+// Update is a change in tracker state delivered to subscribers
+type Update struct {
+	Type     string        `json:"type"`
+	Aircraft AircraftState `json:"aircraft"`
+	Quality  *Quality      `json:"quality,omitempty"` // How well the visit was received, on removal
+}
+
+// Subscription receives tracker updates until Unsubscribe is called
+type Subscription struct {
+	C       <-chan Update
+	ch      chan Update
+	tracker *Tracker
+}
+
+// Unsubscribe stops delivery and closes the subscription channel
+func (s *Subscription) Unsubscribe() {
+	s.tracker.mu.Lock()
+	defer s.tracker.mu.Unlock()
+	if _, ok := s.tracker.subscribers[s]; ok {
+		delete(s.tracker.subscribers, s)
+		close(s.ch)
+	}
+}
+
+// Tracker maintains the in-memory state of aircraft currently in range
+type Tracker struct {
+	expiry      time.Duration      // aircraft silent for longer than this are removed
+	positions   *decoder.Positions // CPR state for decoding positions
+	receiver    *decoder.Position  // Where the receiver is, nil when not set
+	dropCorrupt bool               // Tee drops messages failing the parity check instead of passing them on
+
+	mu          sync.RWMutex
+	aircraft    map[string]*AircraftState
+	reception   map[string]*reception                 // Per aircraft, for scoring the visit's quality
+	status      map[string]*decoder.OperationalStatus // Per aircraft, the last operational status heard
+	subscribers map[*Subscription]struct{}
+	dropped     int64
+	rejected    int64 // Messages with a reserved or unallocated address, left untracked
+
+	started       time.Time
+	messageTypes  *models.MessageTypeCounter  // Every message received, including formats not tracked
+	interrogators *models.InterrogatorCounter // The radars that all-call replies answered
+}
+
+// New creates a tracker that forgets aircraft after expiry without messages
+func New(expiry time.Duration) *Tracker {
+	return &Tracker{
+		expiry:        expiry,
+		positions:     decoder.NewPositions(nil),
+		aircraft:      make(map[string]*AircraftState),
+		reception:     make(map[string]*reception),
+		status:        make(map[string]*decoder.OperationalStatus),
+		subscribers:   make(map[*Subscription]struct{}),
+		started:       time.Now(),
+		messageTypes:  models.NewMessageTypeCounter(),
+		interrogators: models.NewInterrogatorCounter(),
+	}
+}
+
+// SetReceiver sets the receiver's location, which surface positions are decoded relative to and positions too
+// far from are rejected. Call it before the first Update.
+func (t *Tracker) SetReceiver(latitude, longitude float64) {
+	t.receiver = &decoder.Position{Latitude: latitude, Longitude: longitude}
+	t.positions = decoder.NewPositions(t.receiver)
+}
+
+// DropCorrupt makes Tee drop messages whose parity check fails, so neither the tracker nor what reads its output
+// sees them. Call it before Tee.
+func (t *Tracker) DropCorrupt(drop bool) {
+	t.dropCorrupt = drop
+}
+
+// Update applies a received message to the tracked state
+// Every message is counted by type, but only DF11, DF17, and DF18 update aircraft: they carry the address in the
+// clear, other formats would create phantom aircraft. TIS-B and ADS-R targets without an ICAO address are tracked
+// under their ~-prefixed address.
+// Liveness uses the time the message was received, Beast timestamps are not reliable wall-clock times.
+func (t *Tracker) Update(msg *models.BeastMessage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.messageTypes.Add(msg)
+
+	df := msg.DownlinkFormat()
+	now := time.Now()
+	if (df != 11 && df != 17 && df != 18) || msg.ICAO == "" {
+		return
+	}
+	// A corrupt frame's address is usually unallocated, and would leave a ghost aircraft behind
+	addressType := msg.AddressType()
+	if !models.ValidAddress(addressType) {
+		t.rejected++
+		return
+	}
+	t.interrogators.Add(msg, now)
+
+	state, ok := t.aircraft[msg.ICAO]
+	if !ok {
+		state = &AircraftState{ICAO: msg.ICAO, FirstSeen: now}
+		t.aircraft[msg.ICAO] = state
+		t.reception[msg.ICAO] = &reception{}
+	}
+	state.LastSeen = now
+	state.Messages++
+	state.AddressType = addressType
+	state.SignalLevel = msg.SignalLevel
+	state.SignalDBFS = msg.SignalDBFS()
+	state.MessageType = msg.MessageType
+	if !msg.CRCError() {
+		t.decode(state, msg)
+	}
+	t.reception[msg.ICAO].observe(now, msg.SignalDBFS(), msg.Position != nil)
+	if msg.Position != nil && t.receiver != nil {
+		t.reception[msg.ICAO].reach(decoder.Distance(*t.receiver, *msg.Position))
+	}
+
+	t.publish(Update{Type: UpdateAircraft, Aircraft: *state})
+}
+
+// Report applies an aircraft's state as another decoder reports it, e.g. dump1090's aircraft.json, for inputs
+// without raw messages. Fields the report leaves empty are kept. A report no newer than the state is ignored, since
+// a poller sees an aircraft again on each poll until the decoder forgets it; its LastSeen should be on this host's
+// clock, the poll time less how long ago the decoder last heard it.
+func (t *Tracker) Report(report AircraftState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !models.ValidAddress(report.AddressType) {
+		t.rejected++
+		return
+	}
+	state, ok := t.aircraft[report.ICAO]
+	if ok && !report.LastSeen.After(state.LastSeen) {
+		return
+	}
+	if !ok {
+		state = &AircraftState{ICAO: report.ICAO, FirstSeen: report.LastSeen}
+		t.aircraft[report.ICAO] = state
+		t.reception[report.ICAO] = &reception{}
+	}
+	state.LastSeen = report.LastSeen
+	state.Messages = max(state.Messages+1, report.Messages)
+	state.AddressType = report.AddressType
+	state.SignalLevel, state.SignalDBFS = report.SignalLevel, report.SignalDBFS
+	if report.MessageType != "" {
+		state.MessageType = report.MessageType
+	}
+	if report.Source != "" {
+		state.Source = report.Source
+	}
+	state.OnGround = report.OnGround
+	if state.OnGround {
+		state.Altitude = nil
+	} else if report.Altitude != nil {
+		state.Altitude = report.Altitude
+	}
+	state.AltitudeBand = models.AltitudeBand(state.Altitude, state.OnGround)
+	if report.Latitude != nil && report.Longitude != nil {
+		state.Latitude, state.Longitude = report.Latitude, report.Longitude
+	}
+	if report.Speed != nil {
+		state.Speed = report.Speed
+	}
+	if report.Track != nil {
+		state.Track = report.Track
+	}
+	if report.Heading != nil {
+		state.Heading = report.Heading
+	}
+	if report.VerticalRate != nil {
+		state.VerticalRate = report.VerticalRate
+	}
+	if report.NIC != nil {
+		state.NIC = report.NIC
+	}
+	if report.NACp != nil {
+		state.NACp = report.NACp
+	}
+	if report.SIL != nil {
+		state.SIL = report.SIL
+	}
+	if report.SelectedAltitude != nil {
+		state.SelectedAltitude = report.SelectedAltitude
+	}
+	if report.SelectedHeading != nil {
+		state.SelectedHeading = report.SelectedHeading
+	}
+	if report.BaroSetting != nil {
+		state.BaroSetting = report.BaroSetting
+	}
+	if report.Callsign != "" {
+		state.Callsign = report.Callsign
+	}
+	if report.Category != "" {
+		state.Category, state.CategoryName = report.Category, models.CategoryName(report.Category)
+	}
+	if report.Squawk != "" {
+		state.Squawk = report.Squawk
+	}
+	state.Emergency = report.Emergency
+	if report.NavModes != nil {
+		state.NavModes = report.NavModes
+	}
+
+	positioned := report.Latitude != nil && report.Longitude != nil
+	t.reception[report.ICAO].observe(report.LastSeen, report.SignalDBFS, positioned)
+	if positioned && t.receiver != nil {
+		position := decoder.Position{Latitude: *report.Latitude, Longitude: *report.Longitude}
+		t.reception[report.ICAO].reach(decoder.Distance(*t.receiver, position))
+	}
+
+	t.publish(Update{Type: UpdateAircraft, Aircraft: *state})
+}
+
+// decode applies the fields a message reports to the state
+// Only frames whose parity checks are decoded, a corrupt frame could set an altitude or a station record for good.
+func (t *Tracker) decode(state *AircraftState, msg *models.BeastMessage) {
+	if onGround, known := msg.OnGround(); known {
+		state.OnGround = onGround
+	}
+	if feet, ok := msg.Altitude(); ok && !state.OnGround {
+		state.Altitude = &feet // A new pointer, states handed out earlier share the old one
+	} else if state.OnGround {
+		state.Altitude = nil
+	}
+	state.AltitudeBand = models.AltitudeBand(state.Altitude, state.OnGround)
+	if m, err := decoder.Decode(msg.Message); err == nil {
+		t.decodeSquitter(state, msg, m)
+	}
+}
+
+// decodeSquitter applies the fields of a decoded extended squitter, and sets the message's position when it has one
+func (t *Tracker) decodeSquitter(state *AircraftState, msg *models.BeastMessage, m *decoder.Message) {
+	state.Source = m.Source
+	if s := m.OperationalStatus; s != nil {
+		t.status[state.ICAO] = s
+		if s.Version >= decoder.Version1 {
+			nacp, sil := s.NACp, s.SIL
+			state.NACp, state.SIL = &nacp, &sil
+		}
+	}
+	if pos, ok := t.positions.Resolve(state.ICAO, state.LastSeen, m); ok {
+		state.Latitude, state.Longitude = &pos.Latitude, &pos.Longitude
+		msg.Position = &pos
+		msg.Integrity = m.Integrity(t.status[state.ICAO])
+		state.NIC = &msg.Integrity.NIC
+	}
+	if s := m.SurfacePosition; s != nil && s.Track != nil {
+		state.Track = s.Track
+	}
+	if v := m.Velocity; v != nil {
+		if v.Speed != nil {
+			state.Speed = v.Speed
+		}
+		if v.Track != nil {
+			state.Track = v.Track
+		}
+		if v.Heading != nil {
+			state.Heading = v.Heading
+		}
+		if v.VerticalRate != nil {
+			state.VerticalRate = v.VerticalRate
+		}
+	}
+	if id := m.Identification; id != nil {
+		state.Category, state.CategoryName = id.Category, models.CategoryName(id.Category)
+		if id.Callsign != "" {
+			state.Callsign = id.Callsign
+		}
+	}
+	if s := m.Status; s != nil {
+		state.Emergency = s.Emergency
+		state.Squawk = s.Squawk
+	}
+	if s := m.TargetState; s != nil {
+		if s.SelectedAltitude != nil {
+			state.SelectedAltitude = s.SelectedAltitude
+		}
+		if s.SelectedHeading != nil {
+			state.SelectedHeading = s.SelectedHeading
+		}
+		if s.BaroSetting != nil {
+			state.BaroSetting = s.BaroSetting
+		}
+		state.NavModes = s.Modes
+	}
+	if ra := m.Advisory; ra != nil {
+		if ra.ARA == 0 && !ra.Terminated {
+			state.Advisory = nil
+		} else {
+			ra.Time = state.LastSeen
+			state.Advisory = ra
+		}
+	}
+}
+
+// Expire removes aircraft that have been silent longer than the expiry and notifies subscribers with the quality
+// of their visit. TCAS advisories no longer broadcast are cleared too.
+func (t *Tracker) Expire(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for icao, state := range t.aircraft {
+		switch {
+		case now.Sub(state.LastSeen) > t.expiry:
+			quality := t.reception[icao].quality()
+			delete(t.aircraft, icao)
+			delete(t.reception, icao)
+			delete(t.status, icao)
+			t.positions.Forget(icao)
+			t.publish(Update{Type: UpdateRemove, Aircraft: *state, Quality: &quality})
+		case state.Advisory != nil && now.Sub(state.Advisory.Time) > advisoryTimeout:
+			state.Advisory = nil
+			t.publish(Update{Type: UpdateAircraft, Aircraft: *state})
+		}
+	}
+}
+
+// Snapshot returns a copy of every tracked aircraft, most recently seen first
+func (t *Tracker) Snapshot() []AircraftState {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	states := make([]AircraftState, 0, len(t.aircraft))
+	for _, state := range t.aircraft {
+		states = append(states, *state)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].LastSeen.After(states[j].LastSeen)
+	})
+	return states
+}
+
+// Get returns the state of one aircraft
+func (t *Tracker) Get(icao string) (AircraftState, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	state, ok := t.aircraft[icao]
+	if !ok {
+		return AircraftState{}, false
+	}
+	return *state, true
+}
+
+// Dropped returns how many updates were dropped because subscribers fell behind
+func (t *Tracker) Dropped() int64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.dropped
+}
+
+// Rejected returns how many messages were left untracked because their address is reserved or unallocated
+func (t *Tracker) Rejected() int64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.rejected
+}
+
+// MessageTypes returns the messages received since the tracker started by downlink format and type code
+func (t *Tracker) MessageTypes() (models.MessageTypeBreakdown, time.Time) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.messageTypes.Breakdown(), t.started
+}
+
+// Interrogators returns the radars heard through the all-call replies received since the tracker started
+func (t *Tracker) Interrogators() ([]models.InterrogatorActivity, time.Time) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.interrogators.Activity(), t.started
+}
+
+// Subscribe registers for updates; buffer sizes the channel absorbing bursts
+// Updates are dropped for subscribers that fall behind rather than stalling message ingest.
+func (t *Tracker) Subscribe(buffer int) *Subscription {
+	ch := make(chan Update, buffer)
+	sub := &Subscription{C: ch, ch: ch, tracker: t}
+
+	t.mu.Lock()
+	t.subscribers[sub] = struct{}{}
+	t.mu.Unlock()
+
+	return sub
+}
+
+// publish delivers an update to every subscriber without blocking; must be called with mu held
+func (t *Tracker) publish(update Update) {
+	for sub := range t.subscribers {
+		select {
+		case sub.ch <- update:
+		default:
+			t.dropped++
+		}
+	}
+}
+
+// Tee updates the tracker with every message from in and forwards it to out
+// This method blocks until in is closed, then closes out. Expired aircraft are swept once per second.
+func (t *Tracker) Tee(in <-chan *models.BeastMessage, out chan<- *models.BeastMessage) {
+	defer close(out)
+
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg, ok := <-in:
+			if !ok {
+				return
+			}
+			if msg == nil || t.dropCorrupt && msg.CRCError() {
+				continue
+			}
+			t.Update(msg)
+			out <- msg
+
+		case now := <-ticker.C:
+			t.Expire(now)
+		}
+	}
+}
@@ -0,0 +1,525 @@
+package tracker
+
+import (
+	"encoding/hex"
+	"net/url"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/decoder"
+	"flight_trmnl/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMessage(icao string, df byte, signal uint8) *models.BeastMessage {
+	return &models.BeastMessage{
+		Timestamp:       time.Now(),
+		SignalLevel:     signal,
+		MessageTypeCode: models.BeastTypeModeSLong,
+		Message:         []byte{df << 3, 0x00, 0x00, 0x00},
+		ICAO:            icao,
+		MessageType:     "extended_squitter",
+	}
+}
+
+func TestTracker_UpdateAndSnapshot(t *testing.T) {
+	trk := New(time.Minute)
+
+	trk.Update(testMessage("A1B2C3", 17, 100))
+	trk.Update(testMessage("A1B2C3", 17, 120))
+	trk.Update(testMessage("ABCDEF", 11, 50))
+	trk.Update(testMessage("001122", 4, 50)) // DF4 address is parity overlaid, ignored
+
+	snapshot := trk.Snapshot()
+	require.Len(t, snapshot, 2)
+
+	state, ok := trk.Get("A1B2C3")
+	require.True(t, ok)
+	assert.Equal(t, int64(2), state.Messages)
+	assert.Equal(t, uint8(120), state.SignalLevel)
+
+	_, ok = trk.Get("001122")
+	assert.False(t, ok)
+}
+
+func TestTracker_AltitudeBand(t *testing.T) {
+	trk := New(time.Minute)
+	// frame decodes a long frame and fills in its parity, so addresses can be swapped
+	frame := func(s string) *models.BeastMessage {
+		msg, err := hex.DecodeString(s)
+		require.NoError(t, err)
+		parity := models.ModeSCRC(msg[:11])
+		msg[11], msg[12], msg[13] = byte(parity>>16), byte(parity>>8), byte(parity)
+		m, err := models.NewBeastMessage(models.BeastTypeModeSLong, 0, 0, msg, time.Time{}) // Derives the address and type
+		require.NoError(t, err)
+		return m
+	}
+
+	trk.Update(frame("8D40621D58C382D690C8AC2863A7")) // Airborne position at 38000 ft
+	state, _ := trk.Get("40621D")
+	require.NotNil(t, state.Altitude)
+	assert.Equal(t, 38000, *state.Altitude)
+	assert.Equal(t, models.BandHigh, state.AltitudeBand)
+
+	trk.Update(testMessage("40621D", 11, 100))
+	state, _ = trk.Get("40621D")
+	assert.Equal(t, models.BandHigh, state.AltitudeBand, "kept until another altitude is reported")
+
+	corrupt := frame("8C40621D3A9A153237AEF0F275BE")
+	corrupt.Message[13] ^= 1
+	trk.Update(corrupt)
+	state, _ = trk.Get("40621D")
+	assert.Equal(t, models.BandHigh, state.AltitudeBand, "frames failing the parity check aren't decoded")
+
+	trk.Update(frame("8C40621D3A9A153237AEF0F275BE")) // Surface position
+	state, _ = trk.Get("40621D")
+	assert.True(t, state.OnGround)
+	assert.Nil(t, state.Altitude)
+	assert.Equal(t, models.BandSurface, state.AltitudeBand)
+}
+
+func TestTracker_Position(t *testing.T) {
+	trk := New(time.Minute)
+	frame := func(s string) *models.BeastMessage {
+		msg, err := hex.DecodeString(s)
+		require.NoError(t, err)
+		m, err := models.NewBeastMessage(models.BeastTypeModeSLong, 0, 0, msg, time.Time{}) // Derives the address and type
+		require.NoError(t, err)
+		return m
+	}
+
+	odd := frame("8D40621D58C386435CC412692AD6")
+	trk.Update(odd)
+	assert.Nil(t, odd.Position, "one frame isn't enough for a first fix")
+	even := frame("8D40621D58C382D690C8AC2863A7")
+	trk.Update(even)
+	require.NotNil(t, even.Position)
+	assert.InDelta(t, 52.2572, even.Position.Latitude, 0.0001)
+
+	trk.Update(frame("8D485020994409940838175B284F")) // Another aircraft's velocity
+	state, _ := trk.Get("40621D")
+	require.NotNil(t, state.Latitude)
+	assert.InDelta(t, 52.2572, *state.Latitude, 0.0001)
+	assert.InDelta(t, 3.9194, *state.Longitude, 0.0001)
+
+	state, _ = trk.Get("485020")
+	require.NotNil(t, state.Track)
+	assert.InDelta(t, 182.88, *state.Track, 0.01)
+	assert.Equal(t, -832, *state.VerticalRate)
+
+	trk.Update(frame("8DA05F219B06B6AF189400CBC33F")) // Airspeed and heading
+	state, _ = trk.Get("A05F21")
+	assert.Nil(t, state.Track)
+	require.NotNil(t, state.Heading)
+	assert.InDelta(t, 243.98, *state.Heading, 0.01)
+}
+
+func TestTracker_Integrity(t *testing.T) {
+	trk := New(time.Minute)
+	frame := func(s string) *models.BeastMessage {
+		msg, err := hex.DecodeString(s)
+		require.NoError(t, err)
+		return &models.BeastMessage{Message: msg, MessageTypeCode: models.BeastTypeModeSLong, ICAO: "40621D", MessageType: "extended_squitter"}
+	}
+	// Airborne operational status, version 2 with NIC-A set, NACp 10, and SIL 3
+	status := []byte{0x8D, 0x40, 0x62, 0x1D, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	me := uint64(31<<51 | 2<<13 | 1<<12 | 10<<8 | 3<<4)
+	for i := 0; i < 7; i++ {
+		status[4+i] = byte(me >> (48 - 8*uint(i)))
+	}
+	parity := models.ModeSCRC(status[:11])
+	status[11], status[12], status[13] = byte(parity>>16), byte(parity>>8), byte(parity)
+
+	trk.Update(frame("8D40621D58C386435CC412692AD6"))
+	trk.Update(frame(hex.EncodeToString(status)))
+	state, _ := trk.Get("40621D")
+	assert.Nil(t, state.NIC, "no position yet")
+	require.NotNil(t, state.NACp)
+	assert.Equal(t, 10, *state.NACp)
+	assert.Equal(t, 3, *state.SIL)
+
+	even := frame("8D40621D58C382D690C8AC2863A7")
+	trk.Update(even)
+	require.NotNil(t, even.Integrity)
+	assert.Equal(t, 8, even.Integrity.NIC, "TC11 without NIC-B")
+	assert.Equal(t, 10, *even.Integrity.NACp)
+	state, _ = trk.Get("40621D")
+	require.NotNil(t, state.NIC)
+	assert.Equal(t, 8, *state.NIC)
+}
+
+func TestTracker_TISB(t *testing.T) {
+	trk := New(time.Minute)
+	// DF18 airborne positions: fine TIS-B of a track number (IMF set), and ADS-R of an ICAO address
+	frame := func(cf byte, aa uint32, me uint64) *models.BeastMessage {
+		msg := []byte{18<<3 | cf, byte(aa >> 16), byte(aa >> 8), byte(aa), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+		for i := 0; i < 7; i++ {
+			msg[4+i] = byte(me >> (48 - 8*uint(i)))
+		}
+		parity := models.ModeSCRC(msg[:11])
+		msg[11], msg[12], msg[13] = byte(parity>>16), byte(parity>>8), byte(parity)
+		m, err := models.NewBeastMessage(models.BeastTypeModeSLong, 0, 100, msg, time.Now())
+		require.NoError(t, err)
+		return m
+	}
+
+	tisb := frame(2, 0xABCDEF, 0x59C382D690C8AC)
+	assert.Equal(t, "tis_b", tisb.MessageType)
+	trk.Update(tisb)
+	trk.Update(frame(6, 0xA05F21, 0x58C382D690C8AC))
+	trk.Update(frame(4, 0x123456, 0)) // Management, no aircraft
+
+	require.Len(t, trk.Snapshot(), 2)
+	state, ok := trk.Get("~ABCDEF")
+	require.True(t, ok, "tracked under its non-ICAO address")
+	assert.Equal(t, "tisb", state.Source)
+	assert.Equal(t, models.AddressTrackFile, state.AddressType)
+	require.NotNil(t, state.Altitude)
+	assert.Equal(t, 38000, *state.Altitude)
+
+	state, ok = trk.Get("A05F21")
+	require.True(t, ok)
+	assert.Equal(t, "adsr", state.Source)
+	assert.Equal(t, "ads_r", state.MessageType)
+	assert.Equal(t, models.AddressICAO, state.AddressType)
+}
+
+func TestTracker_RejectsInvalidAddresses(t *testing.T) {
+	trk := New(time.Minute)
+
+	trk.Update(testMessage("000000", 17, 100)) // Reserved
+	trk.Update(testMessage("FFFFFF", 11, 100))
+	trk.Update(testMessage("EEEEEE", 17, 100)) // Unallocated, as a corrupt frame's address usually is
+	trk.Update(testMessage("4840D6", 17, 100))
+
+	require.Len(t, trk.Snapshot(), 1)
+	_, ok := trk.Get("4840D6")
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), trk.Rejected())
+}
+
+func TestTracker_Status(t *testing.T) {
+	trk := New(time.Minute)
+	// Built on a TC28 ME field: subtype 1 is emergency status, 2 a TCAS RA broadcast
+	frame := func(me uint64) *models.BeastMessage {
+		msg := []byte{0x8D, 0xA0, 0x5F, 0x21, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+		for i := 0; i < 7; i++ {
+			msg[4+i] = byte(me >> (48 - 8*uint(i)))
+		}
+		parity := models.ModeSCRC(msg[:11])
+		msg[11], msg[12], msg[13] = byte(parity>>16), byte(parity>>8), byte(parity)
+		return &models.BeastMessage{Message: msg, MessageTypeCode: models.BeastTypeModeSLong, ICAO: "A05F21", MessageType: "extended_squitter"}
+	}
+	sub := trk.Subscribe(10)
+	defer sub.Unsubscribe()
+
+	trk.Update(frame(28<<51 | 1<<48 | 3<<45)) // Minimum fuel, squawking 0000
+	state, _ := trk.Get("A05F21")
+	assert.Equal(t, "minimum_fuel", state.Emergency)
+	assert.Equal(t, "0000", state.Squawk)
+
+	trk.Update(frame(28<<51 | 2<<48 | 0b11000010000000<<34)) // Climb
+	state, _ = trk.Get("A05F21")
+	require.NotNil(t, state.Advisory)
+	assert.Equal(t, "Climb, corrective", state.Advisory.Summary)
+	assert.Equal(t, state.LastSeen, state.Advisory.Time)
+
+	trk.Update(frame(28<<51 | 1<<48))
+	state, _ = trk.Get("A05F21")
+	assert.Empty(t, state.Emergency, "the emergency ended")
+	require.NotNil(t, state.Advisory, "kept until the advisory times out")
+
+	for len(sub.C) > 0 {
+		<-sub.C
+	}
+	trk.Expire(time.Now().Add(advisoryTimeout + time.Second))
+	state, _ = trk.Get("A05F21")
+	assert.Nil(t, state.Advisory)
+	update := <-sub.C
+	assert.Equal(t, UpdateAircraft, update.Type)
+	assert.Nil(t, update.Aircraft.Advisory)
+}
+
+func TestTracker_TargetState(t *testing.T) {
+	trk := New(time.Minute)
+	msg, err := hex.DecodeString("8DA05629EA21485CBF3F8CADAEEB")
+	require.NoError(t, err)
+	trk.Update(&models.BeastMessage{Message: msg, MessageTypeCode: models.BeastTypeModeSLong, ICAO: "A05629", MessageType: "extended_squitter"})
+
+	state, ok := trk.Get("A05629")
+	require.True(t, ok)
+	require.NotNil(t, state.SelectedAltitude)
+	assert.Equal(t, 16992, *state.SelectedAltitude)
+	require.NotNil(t, state.BaroSetting)
+	assert.Equal(t, 1012.8, *state.BaroSetting)
+	require.NotNil(t, state.SelectedHeading)
+	assert.InDelta(t, 66.8, *state.SelectedHeading, 0.1)
+	assert.Equal(t, []string{"autopilot", "vnav", "lnav", "tcas"}, state.NavModes)
+}
+
+func TestTracker_Report(t *testing.T) {
+	trk := New(time.Minute)
+	sub := trk.Subscribe(10)
+	now := time.Now()
+	altitude, speed := 35000, 450
+	lat, lon := 53.1, -6.2
+
+	trk.Report(AircraftState{ICAO: "4CA2D3", AddressType: models.AddressICAO, LastSeen: now, Messages: 40,
+		Altitude: &altitude, Speed: &speed, Latitude: &lat, Longitude: &lon, Callsign: "RYR1AB", Category: "A3"})
+	state, ok := trk.Get("4CA2D3")
+	require.True(t, ok)
+	assert.Equal(t, now, state.FirstSeen)
+	assert.Equal(t, int64(40), state.Messages)
+	assert.Equal(t, "high", state.AltitudeBand)
+	assert.NotEmpty(t, state.CategoryName)
+	assert.Equal(t, UpdateAircraft, (<-sub.C).Type)
+
+	// The same report again, as the next poll lists it without news, changes nothing
+	trk.Report(AircraftState{ICAO: "4CA2D3", AddressType: models.AddressICAO, LastSeen: now, Messages: 40})
+	assert.Empty(t, sub.C)
+
+	// A newer one keeps what it leaves out
+	trk.Report(AircraftState{ICAO: "4CA2D3", AddressType: models.AddressICAO, LastSeen: now.Add(time.Second),
+		Messages: 45, OnGround: true})
+	state, _ = trk.Get("4CA2D3")
+	assert.Equal(t, int64(45), state.Messages)
+	assert.Nil(t, state.Altitude)
+	assert.Equal(t, "surface", state.AltitudeBand)
+	assert.Equal(t, 450, *state.Speed)
+	assert.Equal(t, "RYR1AB", state.Callsign)
+	assert.Equal(t, 53.1, *state.Latitude)
+
+	trk.Report(AircraftState{ICAO: "000000", AddressType: models.AddressReserved, LastSeen: now})
+	assert.Len(t, trk.Snapshot(), 1)
+	assert.Equal(t, int64(1), trk.Rejected())
+}
+
+func TestTracker_ExpireNotifiesSubscribers(t *testing.T) {
+	trk := New(time.Minute)
+	sub := trk.Subscribe(10)
+	defer sub.Unsubscribe()
+
+	trk.Update(testMessage("A1B2C3", 17, 100))
+	update := <-sub.C
+	assert.Equal(t, UpdateAircraft, update.Type)
+	assert.Equal(t, "A1B2C3", update.Aircraft.ICAO)
+
+	trk.Expire(time.Now().Add(30 * time.Second))
+	assert.Len(t, trk.Snapshot(), 1, "not silent long enough to expire")
+
+	trk.Expire(time.Now().Add(2 * time.Minute))
+	assert.Empty(t, trk.Snapshot())
+	update = <-sub.C
+	assert.Equal(t, UpdateRemove, update.Type)
+	require.NotNil(t, update.Quality, "removals score the visit")
+	assert.Equal(t, -8.1, update.Quality.SignalMean, "dBFS")
+}
+
+func TestReception_Quality(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// Steady reception: a message every half second for a minute, every other one positioned, at a steady level
+	var steady reception
+	for i := 0; i <= 120; i++ {
+		steady.observe(start.Add(time.Duration(i)*500*time.Millisecond), -8.1, i%2 == 0)
+	}
+	q := steady.quality()
+	assert.Equal(t, 100, q.Score)
+	assert.Zero(t, q.Gaps)
+	assert.Equal(t, int64(61), q.Positions)
+	assert.Equal(t, 0.0, q.SignalStdDev)
+	assert.Zero(t, q.Range, "without the receiver's location")
+
+	steady.reach(120.04)
+	steady.reach(80)
+	assert.Equal(t, 120.0, steady.quality().Range)
+
+	// Patchy reception: 40 seconds of silence in the middle of a minute, no positions, a fluctuating level
+	var patchy reception
+	patchy.observe(start, -18, false)
+	patchy.observe(start.Add(10*time.Second), -7, false)
+	patchy.observe(start.Add(50*time.Second), -18, false)
+	patchy.observe(start.Add(60*time.Second), -7, false)
+	q = patchy.quality()
+	assert.Equal(t, 3, q.Gaps, "the 10 second gaps count too")
+	assert.Equal(t, 40.0, q.LongestGap)
+	assert.Equal(t, -12.5, q.SignalMean)
+	assert.Equal(t, 5.5, q.SignalStdDev)
+	assert.Equal(t, 2, q.Score, "all gaps, no positions, barely stable")
+
+	var empty reception
+	assert.Zero(t, empty.quality().Score)
+}
+
+func TestTracker_SlowSubscriberDoesNotBlock(t *testing.T) {
+	trk := New(time.Minute)
+	sub := trk.Subscribe(1)
+	defer sub.Unsubscribe()
+
+	for i := 0; i < 5; i++ {
+		trk.Update(testMessage("A1B2C3", 17, 100))
+	}
+	assert.Equal(t, int64(4), trk.Dropped())
+}
+
+func TestTracker_Tee(t *testing.T) {
+	trk := New(time.Minute)
+	in := make(chan *models.BeastMessage, 10)
+	out := make(chan *models.BeastMessage, 10)
+
+	go trk.Tee(in, out)
+	in <- testMessage("A1B2C3", 17, 100)
+	close(in)
+
+	msg, ok := <-out
+	require.True(t, ok)
+	assert.Equal(t, "A1B2C3", msg.ICAO)
+
+	_, ok = <-out
+	assert.False(t, ok, "out is closed once in is drained")
+
+	_, tracked := trk.Get("A1B2C3")
+	assert.True(t, tracked)
+}
+
+func TestTracker_TeeDropsCorrupt(t *testing.T) {
+	frame := func(s string) *models.BeastMessage {
+		msg, _ := hex.DecodeString(s)
+		m, _ := models.NewBeastMessage(models.BeastTypeModeSLong, 0, 0, msg, time.Time{})
+		return m
+	}
+	corrupt := frame("8D4840D6202CC371C32CE0576099")
+	corrupt.ICAO = "ABCDEF"
+
+	trk := New(time.Minute)
+	trk.DropCorrupt(true)
+	in := make(chan *models.BeastMessage, 10)
+	out := make(chan *models.BeastMessage, 10)
+	go trk.Tee(in, out)
+	in <- corrupt
+	in <- frame("8D4840D6202CC371C32CE0576098")
+	close(in)
+
+	msg, ok := <-out
+	require.True(t, ok)
+	assert.Equal(t, "4840D6", msg.ICAO)
+	_, ok = <-out
+	assert.False(t, ok, "the corrupt frame isn't passed on")
+
+	_, tracked := trk.Get("ABCDEF")
+	assert.False(t, tracked)
+}
+
+func TestParseFilter(t *testing.T) {
+	f, err := ParseFilter(url.Values{"icao": {"a1b2c3, ABCDEF"}, "min_signal": {"60"}})
+	require.NoError(t, err)
+
+	assert.True(t, f.Match(AircraftState{ICAO: "A1B2C3", SignalLevel: 60}))
+	assert.False(t, f.Match(AircraftState{ICAO: "A1B2C3", SignalLevel: 59}))
+	assert.False(t, f.Match(AircraftState{ICAO: "000001", SignalLevel: 200}))
+
+	f, err = ParseFilter(url.Values{"type": {"Extended_Squitter"}})
+	require.NoError(t, err)
+	assert.True(t, f.Match(AircraftState{ICAO: "000001", MessageType: "extended_squitter"}))
+	assert.False(t, f.Match(AircraftState{ICAO: "000001", MessageType: "surveillance"}))
+
+	_, err = ParseFilter(url.Values{"min_signal": {"300"}})
+	assert.Error(t, err)
+}
+
+func TestParseFilter_Altitude(t *testing.T) {
+	feet := func(n int) *int { return &n }
+
+	f, err := ParseFilter(url.Values{"band": {"Surface,low"}})
+	require.NoError(t, err)
+	assert.True(t, f.Match(AircraftState{AltitudeBand: "surface"}))
+	assert.True(t, f.Match(AircraftState{AltitudeBand: "low"}))
+	assert.False(t, f.Match(AircraftState{AltitudeBand: "high"}))
+	assert.False(t, f.Match(AircraftState{}), "unknown altitude isn't in any band")
+
+	f, err = ParseFilter(url.Values{"min_altitude": {"10000"}, "max_altitude": {"30000"}})
+	require.NoError(t, err)
+	assert.True(t, f.Match(AircraftState{Altitude: feet(10000)}))
+	assert.True(t, f.Match(AircraftState{Altitude: feet(30000)}))
+	assert.False(t, f.Match(AircraftState{Altitude: feet(30025)}))
+	assert.False(t, f.Match(AircraftState{OnGround: true}))
+
+	_, err = ParseFilter(url.Values{"band": {"cruise"}})
+	assert.Error(t, err)
+	_, err = ParseFilter(url.Values{"min_altitude": {"FL350"}})
+	assert.Error(t, err)
+}
+
+func TestParseFilter_Where(t *testing.T) {
+	knots := 450
+	f, err := ParseFilter(url.Values{"where": {`speed > 400 && !on_ground`}})
+	require.NoError(t, err)
+	assert.True(t, f.Match(AircraftState{Speed: &knots}))
+	assert.False(t, f.Match(AircraftState{}), "no reported speed")
+
+	_, err = ParseFilter(url.Values{"where": {"speed > fast"}})
+	assert.ErrorContains(t, err, "invalid where: column 9")
+}
+
+func TestApproachSpacing(t *testing.T) {
+	// approaching is an aircraft descending on a track of 270°, west along latitude 52
+	approaching := func(icao, category string, longitude float64) AircraftState {
+		lat, track, altitude, speed, rate := 52.0, 270.0, 3000, 140, -700
+		return AircraftState{ICAO: icao, Category: category, Latitude: &lat, Longitude: &longitude, Track: &track,
+			Altitude: &altitude, Speed: &speed, VerticalRate: &rate}
+	}
+	// A degree of longitude at 52°N is about 37 NM
+	heavy := approaching("A00001", "A5", 4.0)
+	small := approaching("A00002", "A1", 4.1)    // 3.7 NM behind the heavy, which needs 6
+	large := approaching("A00003", "A3", 4.3)    // 7.4 NM behind the small
+	cruising := approaching("A00004", "A3", 4.2) // Between them but far above
+	high := 35000
+	cruising.Altitude = &high
+
+	pairs := ApproachSpacing([]AircraftState{large, cruising, small, heavy})
+	require.Len(t, pairs, 2)
+	assert.Equal(t, "A00001", pairs[0].Leader.ICAO)
+	assert.Equal(t, "A00002", pairs[0].Follower.ICAO)
+	assert.Equal(t, "heavy", pairs[0].LeaderWake)
+	assert.Equal(t, "small", pairs[0].FollowerWake)
+	assert.InDelta(t, 3.7, pairs[0].Distance, 0.1)
+	assert.InDelta(t, 95, pairs[0].Seconds, 3)
+	assert.Equal(t, 6.0, pairs[0].MinimumSpacing)
+	assert.True(t, pairs[0].Tight)
+
+	assert.Equal(t, "A00002", pairs[1].Leader.ICAO)
+	assert.Equal(t, "A00003", pairs[1].Follower.ICAO)
+	assert.False(t, pairs[1].Tight)
+}
+
+func TestAntennaSectors(t *testing.T) {
+	receiver := decoder.Position{Latitude: 52, Longitude: 4}
+	// positioned is an aircraft at a position with a message count and signal level
+	positioned := func(icao string, lat, lon float64, messages int64, signal uint8) AircraftState {
+		return AircraftState{ICAO: icao, Latitude: &lat, Longitude: &lon, Messages: messages, SignalLevel: signal}
+	}
+	before := []AircraftState{
+		positioned("A00001", 52.5, 4, 100, 80),
+		positioned("A00002", 52, 4.5, 50, 40),
+	}
+	after := []AircraftState{
+		positioned("A00001", 52.5, 4, 120, 100),  // North, 20 new messages
+		positioned("A00002", 52, 4.5, 50, 40),    // East, nothing new
+		positioned("A00003", 51.5, 3.9, 10, 60),  // South, new since the first snapshot
+		positioned("A00004", 52.4, 4.05, 10, 20), // North too
+		{ICAO: "A00005", Messages: 30},           // No position
+	}
+
+	sectors := AntennaSectors(receiver, before, after, 2*time.Second, 4)
+	require.Len(t, sectors, 4)
+	assert.Equal(t, []float64{0, 90, 180, 270}, []float64{sectors[0].Bearing, sectors[1].Bearing, sectors[2].Bearing, sectors[3].Bearing})
+	assert.Equal(t, 2, sectors[0].Aircraft)
+	assert.Equal(t, 15.0, sectors[0].Rate)
+	assert.InDelta(t, -15.1, sectors[0].Signal, 0.001, "the mean of -8.1 and -22.1 dBFS")
+	assert.Zero(t, sectors[1].Aircraft, "no new messages from the east")
+	assert.Equal(t, 1, sectors[2].Aircraft)
+	assert.Equal(t, 5.0, sectors[2].Rate)
+	assert.Zero(t, sectors[3].Rate)
+}
@@ -0,0 +1,294 @@
+package trmnl
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/links"
+	"flight_trmnl/internal/metadata"
+	"flight_trmnl/internal/privacy"
+	"flight_trmnl/internal/report"
+	"flight_trmnl/internal/tracker"
+)
+
+// Built-in layout names used in profile configuration
+const (
+	LayoutNearest = "nearest"
+	LayoutStats   = "stats"
+	LayoutSpecial = "special"
+	// LayoutComparison compares the last week's traffic with the week before, a weekly digest
+	LayoutComparison = "comparison"
+)
+
+// maxListedAircraft keeps payloads under TRMNL's webhook size limit
+const maxListedAircraft = 8
+
+// Sources are the subsystems layouts read from; nil sources are skipped
+type Sources struct {
+	Tracker   *tracker.Tracker
+	Sightings database.SeenAircraftRepository
+	Metadata  *metadata.Chain
+	Tags      database.TagRepository
+	Privacy   *privacy.Output  // Hides or anonymizes blocked aircraft, nil shows everything
+	Links     *links.Generator // Deep link to the first listed aircraft, for QR codes
+	Records   database.RecordRepository
+	Report    report.Sources // Tables the comparison layout reads
+}
+
+// Layout builds the merge variables a TRMNL screen template renders
+type Layout func(ctx context.Context, src Sources, profile *Profile, now time.Time) (map[string]any, error)
+
+var layouts = map[string]Layout{
+	LayoutNearest:    nearestLayout,
+	LayoutStats:      statsLayout,
+	LayoutSpecial:    specialLayout,
+	LayoutComparison: comparisonLayout,
+}
+
+// HasLayout reports whether a layout with this name exists
+func HasLayout(name string) bool {
+	_, ok := layouts[name]
+	return ok
+}
+
+// aircraftEntry is one aircraft as listed on a screen
+type aircraftEntry struct {
+	ICAO         string `json:"icao"`
+	Registration string `json:"registration,omitempty"`
+	Type         string `json:"type,omitempty"`
+	Operator     string `json:"operator,omitempty"`
+	Signal       uint8  `json:"signal"`
+	SeenAgo      int    `json:"seen_ago"` // Seconds since the last message
+	Favorite     bool   `json:"favorite"`
+}
+
+// nearestLayout lists the aircraft currently in range, favorites first and then by signal strength
+// Signal strength stands in for distance, so aircraft heard without a decoded position are ranked too.
+func nearestLayout(ctx context.Context, src Sources, profile *Profile, now time.Time) (map[string]any, error) {
+	if src.Tracker == nil {
+		return nil, fmt.Errorf("layout %s requires the live tracker", LayoutNearest)
+	}
+
+	var states []tracker.AircraftState
+	for _, state := range src.Tracker.Snapshot() {
+		if !profile.Filter.Match(state) {
+			continue
+		}
+		if state, ok := src.Privacy.State(state); ok {
+			states = append(states, state)
+		}
+	}
+	sort.SliceStable(states, func(i, j int) bool {
+		fi, fj := profile.Favorites[states[i].ICAO], profile.Favorites[states[j].ICAO]
+		if fi != fj {
+			return fi
+		}
+		return states[i].SignalLevel > states[j].SignalLevel
+	})
+
+	entries := make([]aircraftEntry, 0, maxListedAircraft)
+	for _, state := range states {
+		if len(entries) == maxListedAircraft {
+			break
+		}
+		entry := aircraftEntry{
+			ICAO:     state.ICAO,
+			Signal:   state.SignalLevel,
+			SeenAgo:  int(now.Sub(state.LastSeen).Seconds()),
+			Favorite: profile.Favorites[state.ICAO],
+		}
+		// ~ marks pseudonyms of blocked aircraft, which must not be looked up or have details shown
+		if src.Metadata != nil && !strings.HasPrefix(state.ICAO, "~") {
+			ac, _, err := src.Metadata.Resolve(ctx, state.ICAO)
+			if err != nil {
+				slog.Debug("Metadata lookup failed", "icao", state.ICAO, "error", err)
+			} else if ac != nil {
+				entry.Registration = ac.Registration
+				entry.Type = ac.TypeCode
+				entry.Operator = ac.Operator
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	vars := map[string]any{
+		"in_range": len(states),
+		"aircraft": entries,
+	}
+	if len(entries) > 0 {
+		addLink(vars, src.Links, links.Aircraft{ICAO: entries[0].ICAO, Registration: entries[0].Registration})
+	}
+	return vars, nil
+}
+
+// statsLayout summarizes today's activity (since local midnight) and which favorites were seen
+func statsLayout(ctx context.Context, src Sources, profile *Profile, now time.Time) (map[string]any, error) {
+	if src.Sightings == nil {
+		return nil, fmt.Errorf("layout %s requires the database", LayoutStats)
+	}
+
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	summary, err := src.Sightings.Summary(midnight)
+	if err != nil {
+		return nil, err
+	}
+
+	favoritesSeen := make([]string, 0)
+	for icao := range profile.Favorites {
+		if src.Privacy.Blocked(icao) {
+			continue
+		}
+		seen, err := src.Sightings.Get(icao)
+		if err != nil {
+			return nil, err
+		}
+		if seen != nil && !seen.LastSeen.Before(midnight) {
+			favoritesSeen = append(favoritesSeen, icao)
+		}
+	}
+	sort.Strings(favoritesSeen)
+
+	vars := map[string]any{
+		"date":           now.Format("Mon Jan 2"),
+		"aircraft_today": summary.Aircraft,
+		"new_today":      summary.NewAircraft,
+		"favorites_seen": favoritesSeen,
+	}
+	if src.Tracker != nil {
+		vars["in_range"] = len(src.Tracker.Snapshot())
+	}
+	if src.Records != nil {
+		records, err := overallRecords(src)
+		if err != nil {
+			return nil, err
+		}
+		vars["records"] = records
+	}
+	return vars, nil
+}
+
+// recordEntry is a station record as listed on a screen
+type recordEntry struct {
+	Kind   string `json:"kind"` // highest, fastest, slowest, or farthest
+	Value  int    `json:"value"`
+	Unit   string `json:"unit"`   // ft, kt, or km
+	Flight string `json:"flight"` // Callsign, or the ICAO address when unknown
+}
+
+// overallRecords lists the station records across all aircraft; per category records would outgrow the payload
+func overallRecords(src Sources) ([]recordEntry, error) {
+	records, err := src.Records.List()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]recordEntry, 0)
+	for _, r := range privacy.Filter(records, src.Privacy.Record) {
+		if r.Category != database.RecordAllCategories {
+			continue
+		}
+		entry := recordEntry{Kind: r.Kind, Value: r.Value, Unit: r.Unit(), Flight: r.Callsign}
+		if entry.Flight == "" {
+			entry.Flight = r.ICAO
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// specialEntry is one aircraft from a special aircraft list as listed on a screen
+type specialEntry struct {
+	ICAO         string   `json:"icao"`
+	Registration string   `json:"registration,omitempty"`
+	Type         string   `json:"type,omitempty"`
+	Operator     string   `json:"operator,omitempty"`
+	Category     string   `json:"category,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	Signal       uint8    `json:"signal"`
+	SeenAgo      int      `json:"seen_ago"` // Seconds since the last message
+}
+
+// specialLayout lists aircraft in range that appear on an imported special aircraft list, strongest signal first
+func specialLayout(ctx context.Context, src Sources, profile *Profile, now time.Time) (map[string]any, error) {
+	if src.Tracker == nil || src.Tags == nil {
+		return nil, fmt.Errorf("layout %s requires the live tracker and the database", LayoutSpecial)
+	}
+
+	states := src.Tracker.Snapshot()
+	sort.Slice(states, func(i, j int) bool { return states[i].SignalLevel > states[j].SignalLevel })
+
+	entries := make([]specialEntry, 0, maxListedAircraft)
+	found := 0
+	for _, state := range states {
+		if !profile.Filter.Match(state) {
+			continue
+		}
+		published, ok := src.Privacy.State(state)
+		if !ok {
+			continue
+		}
+		tags, err := src.Tags.Get(state.ICAO)
+		if err != nil {
+			return nil, err
+		}
+		if len(tags) == 0 {
+			continue
+		}
+		found++
+		if len(entries) == maxListedAircraft {
+			continue
+		}
+		entry := specialEntry{
+			ICAO:    published.ICAO,
+			Signal:  state.SignalLevel,
+			SeenAgo: int(now.Sub(state.LastSeen).Seconds()),
+		}
+		if published.ICAO == state.ICAO {
+			entry.Registration = tags[0].Registration
+			entry.Type = tags[0].Type
+			entry.Operator = tags[0].Operator
+			entry.Category = tags[0].Category
+			entry.Tags = tags[0].Tags
+		}
+		entries = append(entries, entry)
+	}
+
+	vars := map[string]any{
+		"in_range": len(states),
+		"special":  found,
+		"aircraft": entries,
+	}
+	if len(entries) > 0 {
+		addLink(vars, src.Links, links.Aircraft{ICAO: entries[0].ICAO, Registration: entries[0].Registration})
+	}
+	return vars, nil
+}
+
+// addLink sets the link variable to the deep link for an aircraft, left out when there is none
+// since an empty string is truthy in TRMNL's Liquid markup
+func addLink(vars map[string]any, generator *links.Generator, ac links.Aircraft) {
+	if link := generator.QR(ac); link != "" {
+		vars["link"] = link
+	}
+}
+
+// comparisonLayout compares the last seven days' traffic with the seven days before
+func comparisonLayout(ctx context.Context, src Sources, profile *Profile, now time.Time) (map[string]any, error) {
+	if src.Report.Flights == nil {
+		return nil, fmt.Errorf("layout %s requires the database", LayoutComparison)
+	}
+	c, err := report.Compare(src.Report, now, 7*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"period":   c.Current.From.Format("Jan 2") + " – " + now.Format("Jan 2"),
+		"current":  c.Current,
+		"previous": c.Previous,
+		"changes":  c.Changes,
+	}, nil
+}
@@ -0,0 +1,151 @@
+package trmnl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"flight_trmnl/internal/secrets"
+	"flight_trmnl/internal/tracker"
+	"flight_trmnl/pkg/schema"
+)
+
+// Profile is one TRMNL device (or private plugin) and what it shows
+type Profile struct {
+	Name            string
+	WebhookURL      string // Private plugin webhook, e.g. https://usetrmnl.com/api/custom_plugins/<uuid>
+	Layout          string
+	RefreshInterval time.Duration
+	Filter          tracker.Filter
+	Favorites       map[string]bool // ICAO addresses highlighted on this device's screens
+	QR              bool            // Send the link variable as a QR code image too, for built-in layouts
+}
+
+// Pusher pushes layout data to every configured TRMNL profile on its own schedule
+type Pusher struct {
+	profiles  []*Profile
+	src       Sources
+	templates *TemplateSet // nil when no layouts directory is configured
+	client    *http.Client
+}
+
+// NewPusher creates a pusher; profiles with unknown layouts are rejected
+// Profiles may use a built-in layout or one loaded into templates, which may be nil.
+func NewPusher(profiles []*Profile, src Sources, templates *TemplateSet) (*Pusher, error) {
+	for _, profile := range profiles {
+		if _, ok := templates.Get(profile.Layout); !ok && !HasLayout(profile.Layout) {
+			return nil, fmt.Errorf("TRMNL profile %s: unknown layout %s", profile.Name, profile.Layout)
+		}
+	}
+	return &Pusher{
+		profiles:  profiles,
+		src:       src,
+		templates: templates,
+		client:    &http.Client{Timeout: 15 * time.Second},
+	}, nil
+}
+
+// Start pushes every profile immediately and then once per refresh interval
+// This method blocks until the context is cancelled.
+func (p *Pusher) Start(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, profile := range p.profiles {
+		wg.Add(1)
+		go func(profile *Profile) {
+			defer wg.Done()
+			p.run(ctx, profile)
+		}(profile)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+func (p *Pusher) run(ctx context.Context, profile *Profile) {
+	ticker := time.NewTicker(profile.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.Push(ctx, profile); err != nil && ctx.Err() == nil {
+			slog.Warn("TRMNL push failed", "profile", profile.Name, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Render builds the merge variables for a profile without sending them
+// Template layouts are rendered here and sent as a single html variable.
+func (p *Pusher) Render(ctx context.Context, profile *Profile) (map[string]any, error) {
+	// Looked up on every push so hot-reloaded templates take effect on the next refresh
+	tmpl, isTemplate := p.templates.Get(profile.Layout)
+	dataLayout := profile.Layout
+	if isTemplate {
+		dataLayout = tmpl.Data
+	}
+
+	layout, ok := layouts[dataLayout]
+	if !ok {
+		return nil, fmt.Errorf("unknown layout %s", profile.Layout)
+	}
+	now := time.Now()
+	vars, err := layout(ctx, p.src, profile, now)
+	if err != nil {
+		return nil, err
+	}
+	vars["updated_at"] = now.Format("15:04")
+	vars["schema_version"] = schema.TRMNLVersion
+
+	if !isTemplate {
+		if link, ok := vars["link"].(string); ok && profile.QR {
+			addQR(vars, link)
+		}
+		return vars, nil
+	}
+	vars["profile"] = profile.Name
+	html, err := tmpl.Execute(vars)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"html": html, "updated_at": vars["updated_at"], "schema_version": schema.TRMNLVersion}, nil
+}
+
+// Push renders a profile's layout and posts it to the profile's webhook
+func (p *Pusher) Push(ctx context.Context, profile *Profile) error {
+	vars, err := p.Render(ctx, profile)
+	if err != nil {
+		return fmt.Errorf("failed to render layout %s: %w", profile.Layout, err)
+	}
+
+	body, err := json.Marshal(map[string]any{"merge_variables": vars})
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, profile.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", secrets.RedactError(err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", secrets.RedactError(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	slog.Debug("Pushed TRMNL screen", "profile", profile.Name, "layout", profile.Layout, "bytes", len(body))
+	return nil
+}
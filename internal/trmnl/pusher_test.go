@@ -0,0 +1,253 @@
+package trmnl
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/links"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/report"
+	"flight_trmnl/internal/tracker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockSightings serves canned sightings for the stats layout
+type mockSightings struct {
+	sightings map[string]*models.Sighting
+	summary   database.SightingSummary
+}
+
+func (m *mockSightings) UpsertBatch(sightings []*models.Sighting) error { return nil }
+
+func (m *mockSightings) Get(icao string) (*models.Sighting, error) {
+	return m.sightings[icao], nil
+}
+
+func (m *mockSightings) QueryHistory(filter database.SightingFilter, page database.PageRequest) ([]*models.Sighting, string, error) {
+	return nil, "", nil
+}
+
+func (m *mockSightings) Summary(since time.Time) (*database.SightingSummary, error) {
+	return &m.summary, nil
+}
+
+func liveMessage(icao string, signal uint8) *models.BeastMessage {
+	return &models.BeastMessage{
+		SignalLevel:     signal,
+		MessageTypeCode: models.BeastTypeModeSLong,
+		Message:         []byte{0x8D, 0x00, 0x00, 0x00},
+		ICAO:            icao,
+		MessageType:     "extended_squitter",
+	}
+}
+
+func TestNearestLayout_FavoritesFirst(t *testing.T) {
+	trk := tracker.New(time.Minute)
+	trk.Update(liveMessage("AAAAAA", 200))
+	trk.Update(liveMessage("ABBBBB", 50))
+	trk.Update(liveMessage("ACCCCC", 120))
+	trk.Update(liveMessage("ADDDDD", 10))
+
+	profile := &Profile{
+		Layout:    LayoutNearest,
+		Filter:    tracker.NewFilter(nil, nil, nil, 20),
+		Favorites: map[string]bool{"ABBBBB": true},
+	}
+
+	vars, err := nearestLayout(context.Background(), Sources{Tracker: trk}, profile, time.Now())
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, vars["in_range"], "filtered aircraft are not counted")
+	entries := vars["aircraft"].([]aircraftEntry)
+	require.Len(t, entries, 3)
+	assert.Equal(t, "ABBBBB", entries[0].ICAO)
+	assert.True(t, entries[0].Favorite)
+	assert.Equal(t, "AAAAAA", entries[1].ICAO)
+	assert.Equal(t, "ACCCCC", entries[2].ICAO)
+	assert.NotContains(t, vars, "link", "no links configured")
+
+	generator, err := links.New(links.Options{Trackers: []string{"adsbexchange"}})
+	require.NoError(t, err)
+	vars, err = nearestLayout(context.Background(), Sources{Tracker: trk, Links: generator}, profile, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "https://globe.adsbexchange.com/?icao=abbbbb", vars["link"], "links to the first listed aircraft")
+}
+
+func TestStatsLayout(t *testing.T) {
+	now := time.Date(2024, 5, 1, 15, 0, 0, 0, time.UTC)
+	repo := &mockSightings{
+		sightings: map[string]*models.Sighting{
+			"AAAAAA": {ICAO: "AAAAAA", LastSeen: now.Add(-time.Hour)},
+			"BBBBBB": {ICAO: "BBBBBB", LastSeen: now.Add(-24 * time.Hour)},
+		},
+		summary: database.SightingSummary{Aircraft: 57, NewAircraft: 4},
+	}
+	profile := &Profile{
+		Layout:    LayoutStats,
+		Favorites: map[string]bool{"AAAAAA": true, "BBBBBB": true, "CCCCCC": true},
+	}
+
+	vars, err := statsLayout(context.Background(), Sources{Sightings: repo}, profile, now)
+	require.NoError(t, err)
+	assert.Equal(t, 57, vars["aircraft_today"])
+	assert.Equal(t, 4, vars["new_today"])
+	assert.Equal(t, []string{"AAAAAA"}, vars["favorites_seen"])
+	assert.NotContains(t, vars, "in_range", "no tracker, no live count")
+	assert.NotContains(t, vars, "records")
+
+	records := &mockRecords{records: []*database.StationRecord{
+		{Category: database.RecordAllCategories, Kind: database.RecordHighest, Value: 43000, ICAO: "A00001"},
+		{Category: database.RecordAllCategories, Kind: database.RecordFastest, Value: 612, ICAO: "A00002", Callsign: "UAE9"},
+		{Category: "A5", Kind: database.RecordFastest, Value: 612, ICAO: "A00002", Callsign: "UAE9"},
+	}}
+	vars, err = statsLayout(context.Background(), Sources{Sightings: repo, Records: records}, profile, now)
+	require.NoError(t, err)
+	assert.Equal(t, []recordEntry{
+		{Kind: "highest", Value: 43000, Unit: "ft", Flight: "A00001"},
+		{Kind: "fastest", Value: 612, Unit: "kt", Flight: "UAE9"},
+	}, vars["records"], "only the records across all aircraft")
+}
+
+// mockFlights summarizes every period the same, so changes are 0
+type mockFlights struct{}
+
+func (m *mockFlights) Insert(flight *database.Flight) error { return nil }
+
+func (m *mockFlights) List(filter database.FlightFilter, limit int) ([]*database.Flight, error) {
+	return nil, nil
+}
+
+func (m *mockFlights) Summary(from, to time.Time) (*database.FlightSummary, error) {
+	return &database.FlightSummary{Flights: 40, Aircraft: 25, MaxRange: 250}, nil
+}
+
+func (m *mockFlights) SightingLog(from, to time.Time) ([]*database.LogEntry, error) { return nil, nil }
+
+func TestComparisonLayout(t *testing.T) {
+	now := time.Date(2024, 5, 15, 9, 0, 0, 0, time.UTC)
+	_, err := comparisonLayout(context.Background(), Sources{}, &Profile{}, now)
+	assert.Error(t, err, "needs the database")
+
+	vars, err := comparisonLayout(context.Background(), Sources{Report: report.Sources{Flights: &mockFlights{}}}, &Profile{}, now)
+	require.NoError(t, err)
+	assert.Equal(t, "May 8 – May 15", vars["period"])
+	current := vars["current"].(report.Period)
+	assert.Equal(t, 25, current.Aircraft)
+	changes := vars["changes"].(report.Changes)
+	require.NotNil(t, changes.Aircraft)
+	assert.Zero(t, *changes.Aircraft)
+	assert.Nil(t, changes.Messages, "no receiver stats")
+}
+
+type mockRecords struct {
+	records []*database.StationRecord
+}
+
+func (m *mockRecords) Offer(record *database.StationRecord) (bool, error) { return false, nil }
+
+func (m *mockRecords) List() ([]*database.StationRecord, error) { return m.records, nil }
+
+type mockTags struct {
+	tags map[string][]*models.AircraftTag
+}
+
+func (m *mockTags) ReplaceSource(source string, tags []*models.AircraftTag) error { return nil }
+
+func (m *mockTags) Get(icao string) ([]*models.AircraftTag, error) { return m.tags[icao], nil }
+
+func (m *mockTags) Sources() ([]*database.TagSource, error) { return nil, nil }
+
+func (m *mockTags) SourceICAOs(source string) ([]string, error) { return nil, nil }
+
+func TestSpecialLayout(t *testing.T) {
+	trk := tracker.New(time.Minute)
+	trk.Update(liveMessage("AAAAAA", 200))
+	trk.Update(liveMessage("AE1234", 50))
+	trk.Update(liveMessage("AE5678", 120))
+
+	tags := &mockTags{tags: map[string][]*models.AircraftTag{
+		"AE1234": {{ICAO: "AE1234", Registration: "12-3456", Category: "USAF"}},
+		"AE5678": {{ICAO: "AE5678", Registration: "08-0001", Category: "USAF", Tags: []string{"Reach"}}},
+	}}
+
+	vars, err := specialLayout(context.Background(), Sources{Tracker: trk, Tags: tags}, &Profile{Layout: LayoutSpecial}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 3, vars["in_range"])
+	assert.Equal(t, 2, vars["special"])
+	entries := vars["aircraft"].([]specialEntry)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "AE5678", entries[0].ICAO, "strongest signal first")
+	assert.Equal(t, []string{"Reach"}, entries[0].Tags)
+
+	_, err = specialLayout(context.Background(), Sources{Tracker: trk}, &Profile{Layout: LayoutSpecial}, time.Now())
+	assert.Error(t, err)
+}
+
+func TestPusher_Push(t *testing.T) {
+	var payload map[string]map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+	}))
+	defer server.Close()
+
+	profiles := []*Profile{{Name: "office", WebhookURL: server.URL, Layout: LayoutStats, RefreshInterval: time.Hour}}
+	pusher, err := NewPusher(profiles, Sources{Sightings: &mockSightings{summary: database.SightingSummary{Aircraft: 3}}}, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, pusher.Push(context.Background(), profiles[0]))
+	require.Contains(t, payload, "merge_variables")
+	assert.Equal(t, float64(3), payload["merge_variables"]["aircraft_today"])
+	assert.Contains(t, payload["merge_variables"], "updated_at")
+}
+
+func TestPusher_RenderQR(t *testing.T) {
+	trk := tracker.New(time.Minute)
+	trk.Update(liveMessage("AAAAAA", 200))
+	generator, err := links.New(links.Options{Trackers: []string{"adsbexchange"}})
+	require.NoError(t, err)
+
+	profile := &Profile{Name: "kitchen", Layout: LayoutNearest}
+	pusher, err := NewPusher([]*Profile{profile}, Sources{Tracker: trk, Links: generator}, nil)
+	require.NoError(t, err)
+
+	vars, err := pusher.Render(context.Background(), profile)
+	require.NoError(t, err)
+	assert.Contains(t, vars, "link")
+	assert.NotContains(t, vars, "qr", "QR codes are opt-in")
+
+	profile.QR = true
+	vars, err = pusher.Render(context.Background(), profile)
+	require.NoError(t, err)
+	assert.Contains(t, vars["qr"], "data:image/png;base64,")
+	body, err := json.Marshal(vars)
+	require.NoError(t, err)
+	assert.Less(t, len(body), 2048, "fits TRMNL's webhook payload limit")
+}
+
+func TestPusher_Errors(t *testing.T) {
+	_, err := NewPusher([]*Profile{{Name: "x", Layout: "radar"}}, Sources{}, nil)
+	assert.Error(t, err, "unknown layouts are rejected up front")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	profile := &Profile{Name: "kitchen", WebhookURL: server.URL, Layout: LayoutNearest}
+	pusher, err := NewPusher([]*Profile{profile}, Sources{Tracker: tracker.New(time.Minute)}, nil)
+	require.NoError(t, err)
+	assert.ErrorContains(t, pusher.Push(context.Background(), profile), "429")
+
+	pusher, err = NewPusher([]*Profile{profile}, Sources{}, nil)
+	require.NoError(t, err)
+	assert.Error(t, pusher.Push(context.Background(), profile), "nearest needs the tracker")
+}
@@ -0,0 +1,293 @@
+package trmnl
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"flight_trmnl/internal/qr"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Files making up a layout directory
+const (
+	manifestFile = "layout.yaml"
+	templateFile = "template.html"
+)
+
+// templatePollInterval is how often the layouts directory is checked for changes
+const templatePollInterval = 5 * time.Second
+
+// TemplateLayout is a screen loaded from a layout directory
+// A layout directory holds layout.yaml (the manifest) and template.html, a Go html/template
+// executed with the variables of the built-in layout named by Data. The rendered markup is
+// pushed as the html merge variable, so the TRMNL plugin markup only needs {{ html }}.
+type TemplateLayout struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description"`
+	Author      string `yaml:"author" json:"author"`
+	Version     string `yaml:"version" json:"version"`
+	Data        string `yaml:"data" json:"data"` // Built-in layout providing the template variables
+	Dir         string `yaml:"-" json:"dir"`
+
+	tmpl *template.Template
+}
+
+// templateFuncs are available to every layout template
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"truncate": func(n int, s string) string {
+		if len([]rune(s)) <= n {
+			return s
+		}
+		return string([]rune(s)[:n])
+	},
+	"ago": func(seconds int) string {
+		return (time.Duration(seconds) * time.Second).String()
+	},
+	// qr renders text (such as the link variable) as a QR code image filling the width of its container
+	"qr": func(text string) template.HTML {
+		uri := qrDataURI(text)
+		if uri == "" {
+			return ""
+		}
+		return template.HTML(`<img src="` + uri + `" alt="QR code" style="width: 100%; image-rendering: pixelated">`)
+	},
+}
+
+// qrDataURI encodes text as a QR code PNG data URI, empty for empty or overlong text
+func qrDataURI(text string) string {
+	if text == "" {
+		return ""
+	}
+	code, err := qr.Encode(text)
+	if err != nil {
+		slog.Warn("Failed to encode QR code", "error", err)
+		return ""
+	}
+	uri, err := code.DataURI()
+	if err != nil {
+		slog.Warn("Failed to render QR code", "error", err)
+		return ""
+	}
+	return uri
+}
+
+// addQR sets the qr variable of built-in layouts, whose Liquid markup can't encode the link itself
+// It is an image data URI, e.g. <img src="{{ qr }}" style="width: 160px; image-rendering: pixelated">.
+func addQR(vars map[string]any, link string) {
+	if uri := qrDataURI(link); uri != "" {
+		vars["qr"] = uri
+	}
+}
+
+// Execute renders the layout template with the given variables
+func (l *TemplateLayout) Execute(vars map[string]any) (string, error) {
+	var buf bytes.Buffer
+	if err := l.tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("failed to execute template %s: %w", l.Name, err)
+	}
+	return buf.String(), nil
+}
+
+// LoadTemplateLayout loads one layout directory
+func LoadTemplateLayout(dir string) (*TemplateLayout, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	layout := &TemplateLayout{Dir: dir}
+	if err := yaml.Unmarshal(data, layout); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", manifestFile, err)
+	}
+	if layout.Name == "" {
+		layout.Name = filepath.Base(dir)
+	}
+	if HasLayout(layout.Name) {
+		return nil, fmt.Errorf("layout name %s is reserved for a built-in layout", layout.Name)
+	}
+	if !HasLayout(layout.Data) {
+		return nil, fmt.Errorf("unknown data source %q (must be a built-in layout: %s, %s, %s, %s)", layout.Data, LayoutNearest, LayoutStats, LayoutSpecial,
+			LayoutComparison)
+	}
+
+	tmpl, err := template.New(templateFile).Funcs(templateFuncs).ParseFiles(filepath.Join(dir, templateFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	layout.tmpl = tmpl
+
+	return layout, nil
+}
+
+// TemplateSet holds the layouts loaded from a directory and reloads them when files change
+// A layout that fails to reload keeps its previous version so a half-saved edit doesn't blank a screen.
+type TemplateSet struct {
+	dir string
+
+	mu          sync.RWMutex
+	layouts     map[string]*TemplateLayout
+	errors      map[string]error // Load errors by layout directory
+	fingerprint string
+}
+
+// NewTemplateSet loads every layout directory under dir
+// Broken layouts are logged and skipped; only an unreadable dir is an error.
+func NewTemplateSet(dir string) (*TemplateSet, error) {
+	s := &TemplateSet{dir: dir, layouts: make(map[string]*TemplateLayout)}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get returns a loaded layout by name
+func (s *TemplateSet) Get(name string) (*TemplateLayout, bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	layout, ok := s.layouts[name]
+	return layout, ok
+}
+
+// Layouts returns the loaded layouts sorted by name
+func (s *TemplateSet) Layouts() []*TemplateLayout {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	layouts := make([]*TemplateLayout, 0, len(s.layouts))
+	for _, layout := range s.layouts {
+		layouts = append(layouts, layout)
+	}
+	sort.Slice(layouts, func(i, j int) bool { return layouts[i].Name < layouts[j].Name })
+	return layouts
+}
+
+// Errors returns the load errors from the last reload by layout directory
+func (s *TemplateSet) Errors() map[string]error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	errors := make(map[string]error, len(s.errors))
+	for dir, err := range s.errors {
+		errors[dir] = err
+	}
+	return errors
+}
+
+// Reload loads every layout directory again
+func (s *TemplateSet) Reload() error {
+	fingerprint, err := s.scan()
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read layouts directory: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous := make(map[string]*TemplateLayout)
+	for _, layout := range s.layouts {
+		previous[layout.Dir] = layout
+	}
+
+	layouts := make(map[string]*TemplateLayout)
+	errors := make(map[string]error)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(s.dir, entry.Name())
+
+		layout, err := LoadTemplateLayout(dir)
+		if err != nil {
+			errors[dir] = err
+			slog.Warn("Failed to load TRMNL layout", "dir", dir, "error", err)
+			if layout = previous[dir]; layout == nil {
+				continue
+			}
+		}
+		if existing, ok := layouts[layout.Name]; ok {
+			errors[dir] = fmt.Errorf("duplicate layout name %s (also in %s)", layout.Name, existing.Dir)
+			slog.Warn("Skipping duplicate TRMNL layout", "name", layout.Name, "dir", dir)
+			continue
+		}
+		layouts[layout.Name] = layout
+	}
+
+	s.layouts = layouts
+	s.errors = errors
+	s.fingerprint = fingerprint
+	return nil
+}
+
+// Watch reloads layouts whenever files in the directory change
+// This method blocks until the context is cancelled.
+func (s *TemplateSet) Watch(ctx context.Context) {
+	ticker := time.NewTicker(templatePollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		fingerprint, err := s.scan()
+		if err != nil {
+			slog.Warn("Failed to scan TRMNL layouts", "dir", s.dir, "error", err)
+			continue
+		}
+
+		s.mu.RLock()
+		changed := fingerprint != s.fingerprint
+		s.mu.RUnlock()
+		if !changed {
+			continue
+		}
+
+		if err := s.Reload(); err != nil {
+			slog.Warn("Failed to reload TRMNL layouts", "dir", s.dir, "error", err)
+			continue
+		}
+		slog.Info("Reloaded TRMNL layouts", "layouts", len(s.Layouts()))
+	}
+}
+
+// scan fingerprints the directory tree by file names, sizes, and modification times
+// Polling is cheap for a handful of small files and behaves the same on every filesystem.
+func (s *TemplateSet) scan() (string, error) {
+	var b strings.Builder
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "%s|%d|%d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to scan layouts directory: %w", err)
+	}
+	return b.String(), nil
+}
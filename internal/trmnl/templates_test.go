@@ -0,0 +1,115 @@
+package trmnl
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/tracker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeLayout(t *testing.T, dir, manifest, tmpl string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, manifestFile), []byte(manifest), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, templateFile), []byte(tmpl), 0o644))
+}
+
+func TestTemplateSet_Load(t *testing.T) {
+	dir := t.TempDir()
+	writeLayout(t, filepath.Join(dir, "board"), "name: big-board\ndata: nearest\nversion: 1.0.0\n", "{{ .in_range }} aircraft")
+	writeLayout(t, filepath.Join(dir, "unnamed"), "data: stats\n", "{{ .aircraft_today }} today")
+	writeLayout(t, filepath.Join(dir, "reserved"), "name: nearest\ndata: nearest\n", "x")
+	writeLayout(t, filepath.Join(dir, "nodata"), "name: broken\ndata: radar\n", "x")
+	writeLayout(t, filepath.Join(dir, "badtmpl"), "name: badtmpl\ndata: stats\n", "{{ .aircraft_today ")
+
+	set, err := NewTemplateSet(dir)
+	require.NoError(t, err)
+
+	var names []string
+	for _, layout := range set.Layouts() {
+		names = append(names, layout.Name)
+	}
+	assert.Equal(t, []string{"big-board", "unnamed"}, names, "name defaults to the directory name")
+	assert.Len(t, set.Errors(), 3)
+
+	layout, ok := set.Get("big-board")
+	require.True(t, ok)
+	assert.Equal(t, "1.0.0", layout.Version)
+	html, err := layout.Execute(map[string]any{"in_range": 4})
+	require.NoError(t, err)
+	assert.Equal(t, "4 aircraft", html)
+
+	_, err = NewTemplateSet(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestTemplateSet_ReloadKeepsPreviousVersionOnError(t *testing.T) {
+	dir := t.TempDir()
+	layoutDir := filepath.Join(dir, "board")
+	writeLayout(t, layoutDir, "name: board\ndata: stats\n", "v1")
+
+	set, err := NewTemplateSet(dir)
+	require.NoError(t, err)
+
+	writeLayout(t, layoutDir, "name: board\ndata: stats\n", "v2")
+	require.NoError(t, set.Reload())
+	layout, _ := set.Get("board")
+	html, _ := layout.Execute(nil)
+	assert.Equal(t, "v2", html)
+
+	// A half-saved template keeps the last good version on screen
+	writeLayout(t, layoutDir, "name: board\ndata: stats\n", "{{ if }")
+	require.NoError(t, set.Reload())
+	layout, ok := set.Get("board")
+	require.True(t, ok)
+	html, _ = layout.Execute(nil)
+	assert.Equal(t, "v2", html)
+	assert.Contains(t, set.Errors(), layoutDir)
+
+	require.NoError(t, os.RemoveAll(layoutDir))
+	require.NoError(t, set.Reload())
+	_, ok = set.Get("board")
+	assert.False(t, ok, "removed layouts are unloaded")
+}
+
+func TestPusher_RenderTemplateLayout(t *testing.T) {
+	set, err := NewTemplateSet(filepath.Join("..", "..", "examples", "layouts"))
+	require.NoError(t, err)
+	require.Empty(t, set.Errors(), "example layouts must stay valid")
+
+	trk := tracker.New(time.Minute)
+	trk.Update(liveMessage("A1B2C3", 100))
+
+	profile := &Profile{Name: "kitchen", Layout: "departure-board", Favorites: map[string]bool{"A1B2C3": true}}
+	pusher, err := NewPusher([]*Profile{profile}, Sources{Tracker: trk}, set)
+	require.NoError(t, err)
+
+	vars, err := pusher.Render(context.Background(), profile)
+	require.NoError(t, err)
+	require.Contains(t, vars, "html")
+	html := vars["html"].(string)
+	assert.Contains(t, html, "&#9733; A1B2C3")
+	assert.Contains(t, html, "1 aircraft in range")
+	assert.Contains(t, html, "kitchen")
+}
+
+func TestTemplateFuncs_QR(t *testing.T) {
+	dir := t.TempDir()
+	writeLayout(t, dir, "name: qr\ndata: nearest\n", `<div class="qr">{{ with .link }}{{ qr . }}{{ end }}</div>`)
+	layout, err := LoadTemplateLayout(dir)
+	require.NoError(t, err)
+
+	html, err := layout.Execute(map[string]any{"link": "https://globe.adsbexchange.com/?icao=4840d6"})
+	require.NoError(t, err)
+	assert.Contains(t, html, `<div class="qr"><img src="data:image/png;base64,`, "the image is not escaped")
+
+	html, err = layout.Execute(map[string]any{})
+	require.NoError(t, err)
+	assert.Equal(t, `<div class="qr"></div>`, html, "no code without a link")
+}
@@ -0,0 +1,315 @@
+package update
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Files published with every release besides the binaries
+const (
+	ChecksumsFile = "SHA256SUMS"     // sha256sum output for every binary
+	SignatureFile = "SHA256SUMS.sig" // Raw ed25519 signature of ChecksumsFile
+)
+
+// DefaultRepository is the GitHub repository releases are published to
+const DefaultRepository = "kctraveler/flight_trmnl"
+
+// PublicKey is the base64 ed25519 key release checksums are signed with, set at build time:
+//
+//	go build -ldflags "-X flight_trmnl/internal/update.PublicKey=..."
+//
+// Builds without a key (e.g. from source) verify checksums only.
+var PublicKey string
+
+// ErrNoAsset is returned when a release has no binary for this platform
+var ErrNoAsset = errors.New("release has no binary for this platform")
+
+// Release is a published release and its downloadable files
+type Release struct {
+	Tag    string
+	Assets map[string]string // File name -> download URL
+}
+
+// Updater checks GitHub releases and replaces the running binary with a verified download
+type Updater struct {
+	client  *http.Client
+	apiURL  string // GitHub API base, replaced in tests
+	repo    string
+	current string // Version of the running binary
+}
+
+// New creates an updater for the repository's releases
+func New(repo, current string) *Updater {
+	return &Updater{
+		client:  &http.Client{Timeout: 5 * time.Minute},
+		apiURL:  "https://api.github.com",
+		repo:    repo,
+		current: current,
+	}
+}
+
+// AssetName returns the release binary name for a platform, e.g. flight_trmnl_linux_arm64
+// 32-bit ARM releases are built for ARMv7 (Raspberry Pi 2 and later).
+func AssetName(goos, goarch string) string {
+	if goarch == "arm" {
+		goarch = "armv7"
+	}
+	return fmt.Sprintf("flight_trmnl_%s_%s", goos, goarch)
+}
+
+// Latest returns the newest published release
+func (u *Updater) Latest(ctx context.Context) (*Release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/repos/%s/releases/latest", u.apiURL, u.repo), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check releases: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to check releases: %s", resp.Status)
+	}
+
+	var body struct {
+		TagName string `json:"tag_name"`
+		Assets  []struct {
+			Name string `json:"name"`
+			URL  string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode release: %w", err)
+	}
+
+	release := &Release{Tag: body.TagName, Assets: make(map[string]string, len(body.Assets))}
+	for _, asset := range body.Assets {
+		release.Assets[asset.Name] = asset.URL
+	}
+	return release, nil
+}
+
+// UpToDate reports whether the running binary is the release or newer, e.g. a pre-release of the next version.
+// Builds without a semantic version (e.g. "dev" from source) are up to date only with a release of the same name,
+// and a release tag that isn't a semantic version is never installed over a versioned build.
+func (u *Updater) UpToDate(release *Release) bool {
+	latest, latestOK := parseVersion(release.Tag)
+	current, currentOK := parseVersion(u.current)
+	switch {
+	case !currentOK:
+		return strings.TrimPrefix(release.Tag, "v") == strings.TrimPrefix(u.current, "v")
+	case !latestOK:
+		return true
+	}
+	return compareVersions(current, latest) >= 0
+}
+
+// version is a parsed semantic version, MAJOR.MINOR.PATCH[-PRERELEASE][+BUILD]; build metadata is dropped
+type version struct {
+	core       [3]int
+	prerelease []string
+}
+
+// parseVersion parses a semantic version with an optional v prefix
+func parseVersion(s string) (version, bool) {
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+	var v version
+	core := s
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		core = s[:i]
+		v.prerelease = strings.Split(s[i+1:], ".")
+		for _, id := range v.prerelease {
+			if id == "" {
+				return version{}, false
+			}
+		}
+	}
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return version{}, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || strings.Trim(part, "0123456789") != "" {
+			return version{}, false
+		}
+		v.core[i] = n
+	}
+	return v, true
+}
+
+// compareVersions returns -1, 0, or 1 as a is older than, the same as, or newer than b, by semver precedence:
+// a pre-release is older than its release, and its identifiers compare numerically when both are numbers
+func compareVersions(a, b version) int {
+	for i := range a.core {
+		if a.core[i] != b.core[i] {
+			return cmpInt(a.core[i], b.core[i])
+		}
+	}
+	switch {
+	case len(a.prerelease) == 0 && len(b.prerelease) == 0:
+		return 0
+	case len(a.prerelease) == 0:
+		return 1
+	case len(b.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(a.prerelease) && i < len(b.prerelease); i++ {
+		x, y := a.prerelease[i], b.prerelease[i]
+		xn, xErr := strconv.Atoi(x)
+		yn, yErr := strconv.Atoi(y)
+		switch {
+		case xErr == nil && yErr == nil:
+			if xn != yn {
+				return cmpInt(xn, yn)
+			}
+		case xErr == nil:
+			return -1 // Numeric identifiers sort before alphanumeric ones
+		case yErr == nil:
+			return 1
+		case x != y:
+			return strings.Compare(x, y)
+		}
+	}
+	return cmpInt(len(a.prerelease), len(b.prerelease))
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Apply downloads the release binary for this platform, verifies it, and replaces the binary at path
+// The new binary is written next to the old one and renamed over it, so a failed update leaves the old binary in place.
+func (u *Updater) Apply(ctx context.Context, release *Release, path string) error {
+	name := AssetName(runtime.GOOS, runtime.GOARCH)
+	url, ok := release.Assets[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoAsset, name)
+	}
+
+	want, err := u.checksum(ctx, release, name)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".flight_trmnl-update-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	hash := sha256.New()
+	if err := u.download(ctx, url, io.MultiWriter(tmp, hash)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp.Name(), err)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		return fmt.Errorf("checksum mismatch for %s: got %s, want %s", name, got, want)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return fmt.Errorf("failed to make %s executable: %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// checksum returns the published sha256 of a release file, verifying the checksum list's signature when a key is built in
+func (u *Updater) checksum(ctx context.Context, release *Release, name string) (string, error) {
+	url, ok := release.Assets[ChecksumsFile]
+	if !ok {
+		return "", fmt.Errorf("release %s has no %s", release.Tag, ChecksumsFile)
+	}
+	var sums bytes.Buffer
+	if err := u.download(ctx, url, &sums); err != nil {
+		return "", err
+	}
+
+	if PublicKey != "" {
+		if err := u.verifySignature(ctx, release, sums.Bytes()); err != nil {
+			return "", err
+		}
+	}
+
+	scanner := bufio.NewScanner(&sums)
+	for scanner.Scan() {
+		// sha256sum format: "<hex>  <name>", binary mode marks the name with *
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no checksum for %s", ChecksumsFile, name)
+}
+
+func (u *Updater) verifySignature(ctx context.Context, release *Release, sums []byte) error {
+	key, err := base64.StdEncoding.DecodeString(PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid built-in release public key")
+	}
+
+	url, ok := release.Assets[SignatureFile]
+	if !ok {
+		return fmt.Errorf("release %s is not signed (no %s)", release.Tag, SignatureFile)
+	}
+	var sig bytes.Buffer
+	if err := u.download(ctx, url, &sig); err != nil {
+		return err
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), sums, sig.Bytes()) {
+		return fmt.Errorf("invalid signature on %s of release %s", ChecksumsFile, release.Tag)
+	}
+	return nil
+}
+
+func (u *Updater) download(ctx context.Context, url string, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	return nil
+}
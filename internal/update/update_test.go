@@ -0,0 +1,165 @@
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// releaseServer serves a GitHub-like latest release with the given files
+func releaseServer(t *testing.T, files map[string][]byte) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	mux.HandleFunc("/repos/owner/repo/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		type asset struct {
+			Name string `json:"name"`
+			URL  string `json:"browser_download_url"`
+		}
+		var assets []asset
+		for name := range files {
+			assets = append(assets, asset{Name: name, URL: server.URL + "/download/" + name})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"tag_name": "v1.2.0", "assets": assets})
+	})
+	mux.HandleFunc("/download/", func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[filepath.Base(r.URL.Path)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	})
+	return server
+}
+
+func checksums(name string, data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return []byte(hex.EncodeToString(sum[:]) + "  " + name + "\n")
+}
+
+func testUpdater(server *httptest.Server, current string) *Updater {
+	u := New("owner/repo", current)
+	u.apiURL = server.URL
+	return u
+}
+
+func TestAssetName(t *testing.T) {
+	assert.Equal(t, "flight_trmnl_linux_arm64", AssetName("linux", "arm64"))
+	assert.Equal(t, "flight_trmnl_linux_armv7", AssetName("linux", "arm"))
+}
+
+func TestUpdater_UpToDate(t *testing.T) {
+	release := &Release{Tag: "v1.2.0"}
+	for current, upToDate := range map[string]bool{
+		"v1.2.0":       true,
+		"1.2.0":        true,
+		"v1.1.9":       false,
+		"v1.2.0-rc.1":  false, // A pre-release of the latest release
+		"v1.2.1":       true,  // Newer than the latest release, e.g. built from a local tag
+		"v1.3.0-rc.1":  true,  // Pre-release of the next version
+		"v2.0.0":       true,
+		"v1.10.0":      true, // Numeric, not string, order
+		"v1.2.0+local": true,
+		"dev":          false, // Built from source without a version
+	} {
+		assert.Equal(t, upToDate, (&Updater{current: current}).UpToDate(release), current)
+	}
+
+	assert.False(t, (&Updater{current: "v1.3.0-rc.1"}).UpToDate(&Release{Tag: "v1.3.0"}))
+	assert.False(t, (&Updater{current: "v1.3.0-rc.2"}).UpToDate(&Release{Tag: "v1.3.0-rc.10"}))
+	assert.True(t, (&Updater{current: "v1.3.0-rc"}).UpToDate(&Release{Tag: "v1.3.0-1"}), "numeric identifiers sort first")
+	assert.True(t, (&Updater{current: "v1.2.0"}).UpToDate(&Release{Tag: "nightly"}), "unversioned releases aren't installed")
+}
+
+func TestUpdater_Apply(t *testing.T) {
+	name := AssetName(runtime.GOOS, runtime.GOARCH)
+	binary := []byte("new binary")
+	server := releaseServer(t, map[string][]byte{
+		name:          binary,
+		ChecksumsFile: checksums(name, binary),
+	})
+	updater := testUpdater(server, "v1.1.0")
+
+	release, err := updater.Latest(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "v1.2.0", release.Tag)
+	assert.False(t, updater.UpToDate(release))
+	assert.True(t, testUpdater(server, "1.2.0").UpToDate(release))
+
+	path := filepath.Join(t.TempDir(), "flight_trmnl")
+	require.NoError(t, os.WriteFile(path, []byte("old binary"), 0o755))
+	require.NoError(t, updater.Apply(context.Background(), release, path))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, binary, data)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+}
+
+func TestUpdater_ApplyRejectsBadChecksum(t *testing.T) {
+	name := AssetName(runtime.GOOS, runtime.GOARCH)
+	server := releaseServer(t, map[string][]byte{
+		name:          []byte("tampered binary"),
+		ChecksumsFile: checksums(name, []byte("new binary")),
+	})
+	updater := testUpdater(server, "v1.1.0")
+	release, err := updater.Latest(context.Background())
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "flight_trmnl")
+	require.NoError(t, os.WriteFile(path, []byte("old binary"), 0o755))
+	assert.ErrorContains(t, updater.Apply(context.Background(), release, path), "checksum mismatch")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "old binary", string(data), "a failed update keeps the old binary")
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "the download is cleaned up")
+}
+
+func TestUpdater_ApplyVerifiesSignature(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	PublicKey = base64.StdEncoding.EncodeToString(public)
+	t.Cleanup(func() { PublicKey = "" })
+
+	name := AssetName(runtime.GOOS, runtime.GOARCH)
+	binary := []byte("new binary")
+	sums := checksums(name, binary)
+	path := filepath.Join(t.TempDir(), "flight_trmnl")
+
+	unsigned := releaseServer(t, map[string][]byte{name: binary, ChecksumsFile: sums})
+	release, err := testUpdater(unsigned, "v1.1.0").Latest(context.Background())
+	require.NoError(t, err)
+	assert.ErrorContains(t, testUpdater(unsigned, "v1.1.0").Apply(context.Background(), release, path), "not signed")
+
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	forged := releaseServer(t, map[string][]byte{name: binary, ChecksumsFile: sums, SignatureFile: ed25519.Sign(otherKey, sums)})
+	release, err = testUpdater(forged, "v1.1.0").Latest(context.Background())
+	require.NoError(t, err)
+	assert.ErrorContains(t, testUpdater(forged, "v1.1.0").Apply(context.Background(), release, path), "invalid signature")
+
+	signed := releaseServer(t, map[string][]byte{name: binary, ChecksumsFile: sums, SignatureFile: ed25519.Sign(private, sums)})
+	release, err = testUpdater(signed, "v1.1.0").Latest(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, testUpdater(signed, "v1.1.0").Apply(context.Background(), release, path))
+}
@@ -3,20 +3,52 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
+	"io"
 	"log/slog"
+	"math"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
+	"flight_trmnl/internal/api"
 	"flight_trmnl/internal/config"
+	"flight_trmnl/internal/coverage"
+	"flight_trmnl/internal/crash"
+	"flight_trmnl/internal/crypt"
 	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/dbsync"
+	"flight_trmnl/internal/decoder"
 	"flight_trmnl/internal/dump1090"
+	"flight_trmnl/internal/events"
+	"flight_trmnl/internal/filter"
+	"flight_trmnl/internal/hub"
+	"flight_trmnl/internal/links"
+	"flight_trmnl/internal/metadata"
 	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/notify"
+	"flight_trmnl/internal/privacy"
+	"flight_trmnl/internal/report"
+	"flight_trmnl/internal/secrets"
+	"flight_trmnl/internal/service"
 	"flight_trmnl/internal/tasks"
+	"flight_trmnl/internal/terrain"
+	"flight_trmnl/internal/tracker"
+	"flight_trmnl/internal/trmnl"
 )
 
-func initLogger(cfg *config.Config) {
+// shutdownTimeout bounds how long shutdown waits for each service to stop, e.g. for API requests to drain and
+// running tasks to return
+const shutdownTimeout = 15 * time.Second
+
+// crashLogLines is how many of the last log lines a crash report includes
+const crashLogLines = 500
+
+// initLogger logs to stdout, and to logs for crash reports
+func initLogger(cfg *config.Config, logs io.Writer) {
 	var logLevel slog.Level
 	switch cfg.Log.Level {
 	case "debug":
@@ -35,17 +67,21 @@ func initLogger(cfg *config.Config) {
 		Level: logLevel,
 	}
 
+	out := io.MultiWriter(os.Stdout, logs)
 	var handler slog.Handler
 	if cfg.Log.Format == "json" {
-		handler = slog.NewJSONHandler(os.Stdout, opts)
+		handler = slog.NewJSONHandler(out, opts)
 	} else {
-		handler = slog.NewTextHandler(os.Stdout, opts)
+		handler = slog.NewTextHandler(out, opts)
 	}
 
 	logger := slog.New(handler)
 	slog.SetDefault(logger)
 }
 
+// version is the release the binary was built from, set with -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
 func main() {
 	configPath := flag.String("config", "", "Path to config file (YAML)")
 	flag.Parse()
@@ -54,16 +90,42 @@ func main() {
 		os.Setenv("FLIGHT_TRMNL_CONFIG_PATH", *configPath)
 	}
 
+	// init writes the config, so it runs before one is loaded
+	if args := flag.Args(); len(args) > 0 && args[0] == "init" {
+		if err := runInit(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "init failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// decode only looks at the frames it's given, so it needs no config or database
+	if args := flag.Args(); len(args) > 0 && args[0] == "decode" {
+		if err := runDecode(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "decode failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// sync serve is the remote end of a sync and talks over stdin and stdout, so it needs no config and must not log
+	if args := flag.Args(); len(args) > 1 && args[0] == "sync" && args[1] == "serve" {
+		if err := runSyncServe(args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "sync serve failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
-		// Use basic logging for config errors since logger isn't initialized yet
-		// Initialize a basic logger just for this error
-		basicLogger := slog.New(slog.NewTextHandler(os.Stderr, nil))
-		basicLogger.Error("Failed to load configuration", "error", err)
+		// The logger isn't initialized yet; print plainly so each config problem gets its own line
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
 		os.Exit(1)
 	}
 
-	initLogger(cfg)
+	logs := crash.NewLogBuffer(crashLogLines)
+	initLogger(cfg, logs)
 
 	// Initialize database
 	db, err := database.New(cfg.DBPath)
@@ -73,8 +135,41 @@ func main() {
 	}
 	defer db.Close()
 
-	// Setup beast message repository
-	beastRepo := db.BeastMessageRepository()
+	// Encrypt raw messages and positions before they are written, and decrypt them when read back
+	if cfg.Encryption.Key != "" {
+		key, _ := crypt.ParseKey(cfg.Encryption.Key) // Checked when the configuration was loaded
+		c, err := crypt.NewAES(key)
+		if err != nil {
+			slog.Error("Failed to initialize encryption", "error", err)
+			os.Exit(1)
+		}
+		db.SetCipher(c)
+		slog.Info("Encrypting stored raw messages and positions")
+	}
+
+	// Run a one-shot command (e.g. import) instead of the collector when one is given
+	if args := flag.Args(); len(args) > 0 {
+		if err := runCommand(cfg, db, args); err != nil {
+			slog.Error("Command failed", "command", args[0], "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Write a diagnostic bundle if the daemon panics, so crash reports from the field come with what led up to it
+	crash.SetDefault(crash.NewReporter(crash.Options{
+		Dir:     cfg.Log.CrashDir,
+		Version: version,
+		Logs:    logs,
+		Config:  crashConfig(cfg),
+		DB:      db,
+		DBPath:  cfg.DBPath,
+	}))
+	defer crash.Recover()
+
+	// Setup beast message repository, storing as much of each message as the storage mode keeps
+	beastRepo := db.BeastMessageRepositoryWithMode(cfg.StorageMode, cfg.OmitColumns...)
+	slog.Info("Message storage", "mode", cfg.StorageMode)
 
 	// Setup aircraft repository
 	aircraftRepo := db.AircraftRepository()
@@ -84,14 +179,8 @@ func main() {
 		os.Exit(1)
 	}
 	if !populated {
-		csvPaths := []string{
-			"internal/database/datasets/aircraft-database-part1.csv",
-			"internal/database/datasets/aircraft-database-part2.csv",
-		}
-		slog.Info("Aircraft table is empty, loading from CSV files", "csv_paths", csvPaths)
-
-		batchSize := 5000 // large batch size for efficient loading expect > 500,000 records
-		if err := aircraftRepo.LoadFromMultipleCSV(csvPaths, batchSize); err != nil {
+		slog.Info("Aircraft table is empty, loading from CSV files")
+		if err := loadDataset(cfg, aircraftRepo); err != nil {
 			slog.Error("Failed to load aircraft from CSV", "error", err)
 			os.Exit(1)
 		}
@@ -106,39 +195,696 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
-	messageChan := make(chan *models.BeastMessage, 1000) // buffered channel for high message rate (~200/sec)
+	streamChan := make(chan *models.BeastMessage, 1000)  // buffered channel for high message rate (~200/sec)
+	messageChan := make(chan *models.BeastMessage, 1000) // messages after the tracker has seen them
+
+	// Services stop in the reverse order they start, so the collector starts first to store what the others
+	// receive until they have stopped
+	services := service.NewGroup(shutdownTimeout)
+	services.Start(ctx, service.New("collector", tasks.NewBeastCollector(beastRepo, messageChan).Start, nil))
+
+	// Accept messages from stations alongside (or instead of) the local receiver
+	var receiver *hub.Receiver
+	if cfg.Hub.Enabled {
+		keys := make(map[string]string, len(cfg.Hub.Stations))
+		rateLimits := make(map[string]float64, len(cfg.Hub.Stations))
+		for _, s := range cfg.Hub.Stations {
+			keys[s.Name] = s.Token
+			rateLimits[s.Name] = float64(s.RateLimit)
+		}
+		// Stations from the config, then stations issued keys with the stations command
+		lookup := hub.Lookups{hub.NewStaticStations(keys, rateLimits), hub.NewRegistry(db.HubStationRepository())}
+		receiver = hub.NewReceiver(lookup, float64(cfg.Hub.RateLimit), streamChan)
+		hubServer := hub.NewServer(cfg.Hub.Addr, cfg.Hub.TLSCertFile, cfg.Hub.TLSKeyFile, receiver)
+		slog.Info("Starting hub", "addr", cfg.Hub.Addr, "config_stations", len(cfg.Hub.Stations))
+		services.Start(ctx, service.New("hub", hubServer.Start, nil))
+		if cfg.Hub.PushAddr != "" {
+			pushServer := hub.NewPushServer(cfg.Hub.PushAddr, cfg.Hub.TLSCertFile, cfg.Hub.TLSKeyFile, receiver)
+			services.Start(ctx, service.New("hub-push", pushServer.Start, nil))
+		}
+	}
+
+	// The client runs even without beast_addr, e.g. on a hub, so an input added by a reload is attached to the
+	// pipeline like the one from startup
 	beastClient := dump1090.NewBeastClient(cfg.BeastAddr)
+	beastClient.Reconfigure(beastInput(cfg)) // Checked when the configuration was loaded
+	beastClient.RecordConnections(db.ConnectionRepository())
+	stream := beastClient.StreamMessages
+	var backlog *dump1090.Replay
+	if cfg.Replay.File != "" {
+		// A recording stands in for the receiver; once it's done the pipeline stays up to look at the results
+		recording, err := dump1090.NewPacedReplay(cfg.Replay.File, cfg.BeastFormat, cfg.BeastClock, cfg.Replay.Speed)
+		if err != nil {
+			slog.Error("Failed to open replay file", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("Replaying a recording instead of beast_addr", "file", cfg.Replay.File, "speed", cfg.Replay.Speed)
+		stream = replayStream(recording)
+	} else {
+		slog.Info("Starting Beast message collector", "beast_addr", cfg.BeastAddr)
+	}
+	if cfg.CatchUpFile != "" {
+		backlog, err = dump1090.NewReplay(cfg.CatchUpFile, cfg.BeastFormat, cfg.BeastClock)
+		if err != nil {
+			slog.Error("Failed to open catch-up file", "error", err)
+			os.Exit(1)
+		}
+		stream = func(ctx context.Context, ch chan<- *models.BeastMessage) error {
+			return backlog.CatchUp(ctx, beastClient.StreamMessages, ch)
+		}
+	}
+	// Every input from here on sends to streamChan, so it's closed by a service that starts before them and so stops
+	// after the last of them, however early one of them returned. The hub receiver may send until the end.
+	if receiver == nil {
+		services.Start(ctx, service.New("stream", closeOnStop(streamChan), nil))
+	}
+	services.Start(ctx, service.New("beast", func(ctx context.Context) error {
+		err := stream(ctx, streamChan)
+		if closeErr := beastClient.Close(); closeErr != nil {
+			slog.Error("Error closing Beast client", "error", closeErr)
+		}
+		return err
+	}, func() error {
+		if stats := beastClient.Stats(); !stats.Connected && !stats.Detached && cfg.Replay.File == "" {
+			return fmt.Errorf("not connected to %s", beastClient.Addr())
+		}
+		return nil
+	}))
+
+	// More receivers read at the same time, each message tagged with the receiver that heard it, frames forwarded
+	// over UDP, and receivers on serial ports read without dump1090
+	extraInputs := newInputs(services, streamChan, db.ConnectionRepository())
+	if err := extraInputs.apply(ctx, cfg); err != nil {
+		slog.Error("Failed to create input", "error", err)
+		os.Exit(1)
+	}
+	crash.Go(func() { reloadInputs(ctx, beastClient, extraInputs) })
+
+	// Forward received messages to a hub, keeping the local pipeline as it is
+	trackerChan := streamChan
+	if cfg.Station.HubURL != "" {
+		client, err := hub.NewClient(cfg.Station.CAFile)
+		if err != nil {
+			slog.Error("Failed to create hub client", "error", err)
+			os.Exit(1)
+		}
+		forwarder := hub.NewForwarder(cfg.Station.HubURL, cfg.Station.Name, cfg.Station.Token, client)
+		where, _ := filter.Compile(cfg.Station.Where, filter.KindMessage) // Checked by config validation
+		forwarder.Filter(where)
+		forwardChan := make(chan *models.BeastMessage, 1000)
+		crash.Go(func() { forwarder.Tee(streamChan, forwardChan) })
+		trackerChan = forwardChan
+		slog.Info("Forwarding messages to hub", "hub", secrets.RedactURL(cfg.Station.HubURL), "station", cfg.Station.Name)
+		services.Start(ctx, service.New("forwarder", forwarder.Start, nil))
+	}
+
+	// Track live aircraft state on the way to the collector
+	aircraftTracker := tracker.New(time.Duration(cfg.Tracker.Expiry) * time.Second)
+	aircraftTracker.DropCorrupt(cfg.DropCorrupt)
+	if cfg.Location.IsSet() {
+		aircraftTracker.SetReceiver(cfg.Location.Latitude, cfg.Location.Longitude)
+	}
+	crash.Go(func() { aircraftTracker.Tee(trackerChan, messageChan) })
+
+	// States polled from a receiver's aircraft.json go to the tracker directly, there are no messages to store
+	if cfg.AircraftJSON.URL != "" {
+		poller := dump1090.NewAircraftJSONPoller(cfg.AircraftJSON.URL,
+			time.Duration(cfg.AircraftJSON.Interval)*time.Second, aircraftTracker)
+		services.Start(ctx, service.New("aircraft-json", poller.Start, poller.Healthy))
+	}
+
+	// API statistics rolled up from the snapshots and coverage checks are dropped as new ones are stored
+	queryCache := api.NewQueryCache(time.Duration(cfg.API.CacheTTL) * time.Second)
+
+	// Store the tracker state periodically so the sky can be replayed later
+	if cfg.Tracker.SnapshotInterval > 0 {
+		snapshotter := tasks.NewStateSnapshotter(aircraftTracker, db.StateSnapshotRepository(),
+			time.Duration(cfg.Tracker.SnapshotInterval)*time.Second,
+			time.Duration(cfg.Tracker.SnapshotRetention)*24*time.Hour)
+		snapshotter.OnStored(func() { queryCache.Invalidate(api.CacheBandStats) })
+		slog.Info("Starting tracker state snapshots", "interval", cfg.Tracker.SnapshotInterval)
+		crash.Go(func() { snapshotter.Start(ctx) })
+	}
+
+	// Record each visit with how well it was received
+	crash.Go(func() { tasks.NewFlightRecorder(aircraftTracker, db.FlightRepository()).Start(ctx) })
+
+	// Record events emitted by detectors so they can be reviewed later
+	eventBus := events.NewBus()
+	recorder := events.NewRecorder(eventBus, db.EventRepository())
+	crash.Go(func() { recorder.Start(ctx) })
+	crash.Go(func() {
+		events.NewFirstSightingDetector(aircraftTracker, db.SeenAircraftRepository(), eventBus).Start(ctx)
+	})
+	crash.Go(func() { events.NewTaggedAircraftDetector(aircraftTracker, db.TagRepository(), eventBus).Start(ctx) })
+	crash.Go(func() { events.NewStatusDetector(aircraftTracker, db.AdvisoryRepository(), eventBus).Start(ctx) })
+	recordDetector := events.NewRecordDetector(aircraftTracker, db.RecordRepository(), eventBus)
+	if cfg.Location.IsSet() {
+		recordDetector.SetReceiver(cfg.Location.Latitude, cfg.Location.Longitude)
+	}
+	crash.Go(func() {
+		if err := recordDetector.Start(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("Record detector stopped", "error", err)
+		}
+	})
+
+	// Raise maintenance alerts when the local receiver goes quiet or starts sending garbage
+	if cfg.Maintenance.Enabled {
+		detector := events.NewReceiverHealthDetector(beastClient, db.ReceiverStatsRepository(), eventBus, events.ReceiverThresholds{
+			RateDrop:       float64(cfg.Maintenance.RateDrop) / 100,
+			MinBaseline:    int64(cfg.Maintenance.MinBaseline),
+			ParseErrorRate: float64(cfg.Maintenance.ParseErrorRate) / 100,
+			Silence:        time.Duration(cfg.Maintenance.Silence) * time.Second,
+		})
+		crash.Go(func() { detector.Start(ctx) })
+	}
+
+	// Background jobs, each can also be run on demand with the tasks command or POST /api/tasks/<name>
+	scheduler := tasks.NewScheduler(time.Duration(cfg.Scheduler.Jitter)*time.Second, db.TaskRunRepository())
+	scheduler.Add(tasks.Task{Name: "dataset", Run: func(ctx context.Context) error {
+		if err := loadDataset(cfg, aircraftRepo); err != nil {
+			return err
+		}
+		slog.Info("Reloaded aircraft database from CSV")
+		return nil
+	}})
+
+	// Keep special aircraft lists (e.g. plane-alert-db) up to date
+	if len(cfg.Tags.Lists) > 0 {
+		interval := time.Duration(cfg.Tags.RefreshInterval) * time.Hour
+		tagSync := tasks.NewTagSync(db.TagRepository(), newTagLists(cfg), interval)
+		slog.Info("Starting tag list sync", "lists", len(cfg.Tags.Lists))
+		scheduler.Add(tasks.Task{Name: "tags", Interval: interval, Startup: true, Run: tagSync.Run})
+	}
+
+	// Backfill metadata for seen aircraft missing from the aircraft table, again after a dataset reload since
+	// aircraft it now covers no longer need looking up
+	if cfg.Enrichment.Enabled {
+		backfill, closeBackfill, err := newEnrichmentBackfill(cfg, db)
+		if err != nil {
+			slog.Error("Failed to create enrichment resolvers", "error", err)
+			os.Exit(1)
+		}
+		defer closeBackfill()
+		slog.Info("Starting enrichment backfill", "resolvers", cfg.Enrichment.Resolvers)
+		scheduler.Add(tasks.Task{
+			Name:     "enrichment",
+			Interval: time.Duration(cfg.Enrichment.Interval) * time.Second,
+			Startup:  true,
+			After:    []string{"dataset"},
+			Run:      backfill.Pass,
+		})
+	}
+
+	// Measure coverage completeness against an aggregator's view of the same radius
+	var terrainRanges []float64
+	if cfg.Coverage.Enabled {
+		checker := coverage.NewChecker(
+			coverage.NewClient(cfg.Coverage.URL, cfg.Coverage.APIKeyHeader, cfg.Coverage.APIKey),
+			aircraftTracker, db.CoverageRepository(),
+			decoder.Position{Latitude: cfg.Location.Latitude, Longitude: cfg.Location.Longitude},
+			float64(cfg.Coverage.Radius), cfg.Coverage.Sectors)
+		slog.Info("Starting coverage checks", "aggregator", secrets.RedactURL(cfg.Coverage.URL), "radius", cfg.Coverage.Radius)
+		scheduler.Add(tasks.Task{
+			Name:     "coverage",
+			Interval: time.Duration(cfg.Coverage.Interval) * time.Second,
+			Run: func(ctx context.Context) error {
+				defer queryCache.Invalidate(api.CacheCoverage)
+				return checker.Run(ctx)
+			},
+		})
+
+		if cfg.Coverage.TerrainDir != "" {
+			terrainRanges, err = modelTerrain(cfg)
+			if err != nil {
+				slog.Error("Failed to model line-of-sight range", "error", err)
+			}
+		}
+	}
+
+	// Copy new rows to a remote database, e.g. a home server, resuming where the last sync stopped
+	if cfg.Sync.To != "" {
+		syncer := dbsync.NewClient(db.SyncRepository(), dbsync.Options{
+			To:            cfg.Sync.To,
+			Source:        cfg.Sync.Source,
+			Tables:        cfg.Sync.Tables,
+			RateLimit:     cfg.Sync.RateLimit * 1024,
+			RemoteCommand: cfg.Sync.RemoteCommand,
+		})
+		slog.Info("Syncing the database", "to", cfg.Sync.To, "source", cfg.Sync.Source, "interval", cfg.Sync.Interval)
+		scheduler.Add(tasks.Task{
+			Name:     "sync",
+			Interval: time.Duration(cfg.Sync.Interval) * time.Second,
+			Run:      syncer.Run,
+		})
+	}
+
+	// Hide blocked aircraft (e.g. LADD) from public-facing outputs
+	var blocklist *privacy.Blocklist
+	if len(cfg.Privacy.Blocked) > 0 || len(cfg.Privacy.BlockLists) > 0 {
+		blocklist = privacy.NewBlocklist(cfg.Privacy.Blocked, cfg.Privacy.BlockLists, db.TagRepository())
+		if err := blocklist.Reload(); err != nil {
+			slog.Error("Failed to load privacy blocklist", "error", err)
+			os.Exit(1)
+		}
+		crash.Go(func() { blocklist.Watch(ctx) })
+		if len(cfg.Tags.Lists) > 0 && len(cfg.Privacy.BlockLists) > 0 {
+			scheduler.Add(tasks.Task{Name: "blocklist", After: []string{"tags"}, Run: func(ctx context.Context) error {
+				return blocklist.Reload()
+			}})
+		}
+	}
+
+	// Email a traffic overview of the week before, charts included
+	if cfg.Notify.Email.WeeklyReport.Enabled {
+		email := newEmail(cfg)
+		station := report.Location(cfg.Location.Name, cfg.Location.Latitude, cfg.Location.Longitude)
+		weekly := cfg.Notify.Email.WeeklyReport
+		slog.Info("Scheduling the weekly report", "day", weekly.Weekday(), "hour", weekly.Hour, "to", cfg.Notify.Email.To)
+		scheduler.Add(tasks.Task{
+			Name: "weekly_report",
+			Next: tasks.Weekly(weekly.Weekday(), weekly.Hour),
+			Run: func(ctx context.Context) error {
+				return sendWeeklyReport(ctx, db, email, station)
+			},
+		})
+	}
+	services.Start(ctx, service.New("scheduler", scheduler.Start, nil))
+
+	// Aircraft profiles, TRMNL screens and deep links share one metadata cache
+	var chain *metadata.Chain
+	notifying := len(cfg.Notify.Webhooks) > 0 || len(cfg.Notify.Email.Types) > 0
+	if cfg.API.Enabled || len(cfg.TRMNL.Profiles) > 0 || notifying || cfg.DeepDive.Enabled {
+		var closeChain func() error
+		chain, closeChain, err = newResolverChain(cfg, db)
+		if err != nil {
+			slog.Error("Failed to create metadata resolvers", "error", err)
+			os.Exit(1)
+		}
+		defer closeChain()
+	}
+	linkOpts := links.Options{
+		Trackers: cfg.Links.Trackers,
+		Custom:   cfg.Links.Custom,
+		BaseURL:  cfg.Links.BaseURL,
+		QR:       cfg.Links.QR,
+	}
+	if chain != nil {
+		linkOpts.Registry = chain
+	}
+	linkGenerator, err := links.New(linkOpts)
+	if err != nil {
+		slog.Error("Failed to create deep links", "error", err)
+		os.Exit(1)
+	}
+
+	// Follow aircraft heard for the first time and report on them
+	if cfg.DeepDive.Enabled {
+		opts := events.DeepDiveOptions{
+			Wait:       time.Duration(cfg.DeepDive.Wait) * time.Second,
+			MaxPerHour: cfg.DeepDive.MaxPerHour,
+			PhotoURL:   cfg.API.PhotoURL,
+		}
+		if chain != nil {
+			opts.Registry = chain
+		}
+		if cfg.Location.IsSet() {
+			opts.Receiver = &decoder.Position{Latitude: cfg.Location.Latitude, Longitude: cfg.Location.Longitude}
+		}
+		reporter := events.NewDeepDiveReporter(aircraftTracker, eventBus, opts)
+		crash.Go(func() { reporter.Start(ctx) })
+	}
+
+	// Send events to notification webhooks and email
+	var dispatcher *notify.Dispatcher
+	if notifying {
+		dispatcher = notify.NewDispatcher(eventBus, newNotifyTargets(cfg, privacyOutput(blocklist, cfg.Privacy.Outputs.Notify), linkGenerator))
+		slog.Info("Starting notification dispatcher", "webhooks", len(cfg.Notify.Webhooks),
+			"email", len(cfg.Notify.Email.Types) > 0)
+		services.Start(ctx, service.New("notify", dispatcher.Start, nil))
+	}
 
-	slog.Info("Starting Beast message collector", "beast_addr", cfg.BeastAddr)
-	go func() {
-		if err := beastClient.StreamMessages(ctx, messageChan); err != nil {
-			if ctx.Err() == nil { // Only log if not cancelled
-				slog.Error("Beast streamer stopped", "error", err)
+	// Start API server and web UI
+	if cfg.API.Enabled {
+		apiServer, err := api.NewServer(api.Options{
+			Addr:        cfg.API.Addr,
+			Tracker:     aircraftTracker,
+			Messages:    db.BeastMessageRepository(),
+			Sightings:   db.SeenAircraftRepository(),
+			Events:      db.EventRepository(),
+			Notify:      dispatcher,
+			Fleets:      db.FleetRepository(),
+			Snapshots:   db.StateSnapshotRepository(),
+			Connections: db.ConnectionRepository(),
+			Advisories:  db.AdvisoryRepository(),
+			Flights:     db.FlightRepository(),
+			Coverage:    db.CoverageRepository(),
+			Terrain:     terrainRanges,
+			Tags:        db.TagRepository(),
+			Records:     db.RecordRepository(),
+			Tasks:       scheduler,
+			TaskRuns:    db.TaskRunRepository(),
+			Services:    services,
+			Metadata:    chain,
+			Links:       linkGenerator,
+			PhotoURL:    cfg.API.PhotoURL,
+			Location:    report.Location(cfg.Location.Name, cfg.Location.Latitude, cfg.Location.Longitude),
+			Capture:     beastClient,
+			CatchUp:     backlog,
+			CaptureDir:  cfg.Maintenance.CaptureDir,
+			Privacy:     privacyOutput(blocklist, cfg.Privacy.Outputs.API),
+			Cache:       queryCache,
+			CORS: api.CORSOptions{
+				AllowedOrigins: cfg.API.CORS.AllowedOrigins,
+				AllowedHeaders: cfg.API.CORS.AllowedHeaders,
+				MaxAge:         time.Duration(cfg.API.CORS.MaxAge) * time.Second,
+			},
+		})
+		if err != nil {
+			slog.Error("Failed to create API server", "error", err)
+			os.Exit(1)
+		}
+		if receiver != nil {
+			apiServer.Handle("/api/stations", receiver.StationsHandler())
+			apiServer.Handle("/api/stations/clocks", receiver.ClocksHandler())
+		}
+		services.Start(ctx, service.New("api", apiServer.Start, nil))
+	}
+
+	// Push screens to TRMNL devices
+	if len(cfg.TRMNL.Profiles) > 0 {
+		var templates *trmnl.TemplateSet
+		if cfg.TRMNL.LayoutsDir != "" {
+			templates, err = trmnl.NewTemplateSet(cfg.TRMNL.LayoutsDir)
+			if err != nil {
+				slog.Error("Failed to load TRMNL layouts", "error", err)
+				os.Exit(1)
 			}
+			crash.Go(func() { templates.Watch(ctx) })
 		}
-		close(messageChan)
-	}()
 
-	// Start collector to batch and store messages in database
-	collector := tasks.NewBeastCollector(beastRepo, messageChan)
-	go func() {
-		if err := collector.Start(ctx); err != nil && ctx.Err() == nil {
-			slog.Error("Beast collector stopped", "error", err)
+		pusher, err := trmnl.NewPusher(newTRMNLProfiles(cfg), trmnl.Sources{
+			Tracker:   aircraftTracker,
+			Sightings: db.SeenAircraftRepository(),
+			Metadata:  chain,
+			Tags:      db.TagRepository(),
+			Records:   db.RecordRepository(),
+			Report:    report.Sources{Flights: db.FlightRepository(), Receiver: db.ReceiverStatsRepository()},
+			Links:     linkGenerator,
+			Privacy:   privacyOutput(blocklist, cfg.Privacy.Outputs.TRMNL),
+		}, templates)
+		if err != nil {
+			slog.Error("Failed to create TRMNL pusher", "error", err)
+			os.Exit(1)
 		}
-	}()
+		slog.Info("Starting TRMNL pusher", "profiles", len(cfg.TRMNL.Profiles))
+		services.Start(ctx, service.New("trmnl", pusher.Start, nil))
+	}
 
 	// Wait for interrupt signal
 	<-sigChan
 	slog.Info("Received interrupt signal, shutting down...")
 
+	// Stop the services first, e.g. the API and the outputs before ingest and the collector last, then the
+	// detectors and watchers still running on the context
+	services.Stop()
 	cancel()
 
-	if err := beastClient.Close(); err != nil {
-		slog.Error("Error closing Beast client", "error", err)
+	slog.Info("Shutdown complete")
+}
+
+// reloadInputs reloads the configuration on SIGHUP and applies the input settings, beast_addr, beast_format,
+// beast_clock and beast_transport, to the running client, and receivers, udp_inputs and serial_inputs to the other
+// inputs, so receivers can be changed, added, or removed without a restart. Other settings still take a restart. A
+// configuration that fails to load is logged and the running one kept.
+func reloadInputs(ctx context.Context, client *dump1090.BeastClient, others *inputs) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		}
+		cfg, err := config.Load()
+		if err != nil {
+			slog.Error("Failed to reload configuration, keeping the running one", "error", err)
+			continue
+		}
+		if err := client.Reconfigure(beastInput(cfg)); err != nil {
+			slog.Error("Failed to apply reloaded input settings", "error", err)
+			continue
+		}
+		if err := others.apply(ctx, cfg); err != nil {
+			slog.Error("Failed to apply reloaded input settings", "error", err)
+		}
+		slog.Info("Reloaded input settings", "beast_addr", cfg.BeastAddr, "beast_format", cfg.BeastFormat, "beast_clock", cfg.BeastClock,
+			"receivers", len(cfg.Receivers), "udp_inputs", len(cfg.UDPInputs), "serial_inputs", len(cfg.SerialInputs))
+	}
+}
+
+// replayStream streams a recording in place of the receiver, then waits to be stopped so the pipeline stays up to
+// look at the results
+func replayStream(recording *dump1090.Replay) func(context.Context, chan<- *models.BeastMessage) error {
+	return func(ctx context.Context, ch chan<- *models.BeastMessage) error {
+		if err := recording.Run(ctx, ch); err != nil {
+			return err
+		}
+		<-ctx.Done()
+		return nil
+	}
+}
+
+// closeOnStop returns a service start function that closes ch once it's stopped. Started before the services that
+// send to ch, it's stopped after all of them.
+func closeOnStop(ch chan *models.BeastMessage) func(context.Context) error {
+	return func(ctx context.Context) error {
+		<-ctx.Done()
+		close(ch)
+		return nil
 	}
+}
 
-	// Give collector time to flush final batch
-	time.Sleep(500 * time.Millisecond)
+// beastInput returns the receiver input the configuration sets
+func beastInput(cfg *config.Config) dump1090.Input {
+	t := cfg.BeastTransport
+	return dump1090.Input{
+		Addr:       cfg.BeastAddr,
+		Format:     cfg.BeastFormat,
+		Timestamps: cfg.BeastClock,
+		Transport:  dump1090.Transport{SSH: t.SSH, TLS: t.TLS, CAFile: t.CAFile, ServerName: t.ServerName},
+	}
+}
 
-	slog.Info("Shutdown complete")
+// crashConfig is the config as crash reports include it: redacted, and without the receiver's coordinates since
+// reports are meant to be shared
+func crashConfig(cfg *config.Config) *config.Config {
+	summary := cfg.Redacted()
+	summary.Location.Latitude, summary.Location.Longitude = 0, 0
+	return summary
+}
+
+// loadDataset loads the configured aircraft dataset CSVs into the aircraft table, keeping curated fields
+func loadDataset(cfg *config.Config, repo database.AircraftRepository) error {
+	// Column names are checked against the dataset format here, the config package doesn't know the table
+	mapping, err := database.DatasetMapping(cfg.Dataset.Format, cfg.Dataset.Columns)
+	if err != nil {
+		return fmt.Errorf("invalid aircraft dataset configuration: %w", err)
+	}
+	csvPaths := cfg.Dataset.Paths
+	if len(csvPaths) == 0 {
+		csvPaths = []string{
+			datasetPath("internal/database/datasets/aircraft-database-part1"),
+			datasetPath("internal/database/datasets/aircraft-database-part2"),
+		}
+	}
+	slog.Info("Loading aircraft dataset", "csv_paths", csvPaths, "format", cfg.Dataset.Format)
+
+	batchSize := 50000 // rows per transaction, large for efficient loading expect > 500,000 records
+	return repo.LoadFromMultipleCSV(csvPaths, mapping, batchSize)
+}
+
+// modelTerrain works out the line-of-sight range in each direction from the receiver over the SRTM tiles
+func modelTerrain(cfg *config.Config) ([]float64, error) {
+	tiles, err := terrain.OpenTiles(cfg.Coverage.TerrainDir)
+	if err != nil {
+		return nil, err
+	}
+	defer tiles.Close()
+
+	start := time.Now()
+	receiver := decoder.Position{Latitude: cfg.Location.Latitude, Longitude: cfg.Location.Longitude}
+	ranges, err := terrain.Ranges(tiles, receiver, float64(cfg.Coverage.AntennaHeight), float64(cfg.Coverage.TargetAltitude)*0.3048)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("Modelled line-of-sight range", "target_altitude", cfg.Coverage.TargetAltitude, "max_km", math.Round(slices.Max(ranges)),
+		"took", time.Since(start).Round(time.Millisecond))
+	return ranges, nil
+}
+
+// datasetPath returns the dataset file for a base path, preferring plain CSV over compressed copies
+// Falls back to the .csv name when none exists so the load error names the expected file.
+func datasetPath(base string) string {
+	for _, ext := range []string{".csv", ".csv.gz", ".zip"} {
+		if _, err := os.Stat(base + ext); err == nil {
+			return base + ext
+		}
+	}
+	return base + ".csv"
+}
+
+// newTRMNLProfiles converts configured TRMNL profiles into pusher profiles
+func newTRMNLProfiles(cfg *config.Config) []*trmnl.Profile {
+	profiles := make([]*trmnl.Profile, 0, len(cfg.TRMNL.Profiles))
+	for _, p := range cfg.TRMNL.Profiles {
+		favorites := make(map[string]bool)
+		for _, icao := range p.Favorites {
+			favorites[strings.ToUpper(icao)] = true
+		}
+		f := tracker.NewFilter(p.Filter.ICAO, p.Filter.Type, p.Filter.Band, uint8(p.Filter.MinSignal))
+		f.Where, _ = filter.Compile(p.Filter.Where, filter.KindAircraft) // Checked by config validation
+		profiles = append(profiles, &trmnl.Profile{
+			Name:            p.Name,
+			WebhookURL:      p.WebhookURL,
+			Layout:          p.Layout,
+			RefreshInterval: time.Duration(p.RefreshInterval) * time.Second,
+			Filter:          f,
+			Favorites:       favorites,
+			QR:              p.QR,
+		})
+	}
+	return profiles
+}
+
+// newNotifyTargets creates a signed, retrying webhook target for each configured webhook
+// Blocked aircraft are filtered out or anonymized before any other middleware sees the events,
+// and deep links are added last so only the events actually sent cost a registration lookup.
+func newNotifyTargets(cfg *config.Config, policy *privacy.Output, generator *links.Generator) []*notify.Target {
+	retry := notify.RetryPolicy{
+		MaxAttempts:    cfg.Notify.Retry.MaxAttempts,
+		InitialBackoff: time.Duration(cfg.Notify.Retry.InitialBackoff) * time.Second,
+		MaxBackoff:     time.Duration(cfg.Notify.Retry.MaxBackoff) * time.Second,
+	}
+	var deadLetter *notify.DeadLetterLog
+	if cfg.Notify.DeadLetterPath != "" {
+		deadLetter = notify.NewDeadLetterLog(cfg.Notify.DeadLetterPath)
+	}
+
+	targets := make([]*notify.Target, 0, len(cfg.Notify.Webhooks))
+	for _, w := range cfg.Notify.Webhooks {
+		var types map[string]bool
+		if len(w.Types) > 0 {
+			types = make(map[string]bool)
+			for _, t := range w.Types {
+				types[t] = true
+			}
+		}
+		webhook := notify.NewWebhook(w.Name, w.URL, w.Secret, retry, deadLetter)
+
+		// Dedupe first so repeats never reach a digest or burst, then hold low-priority events for the digest
+		var middleware []notify.Middleware
+		if policy != nil {
+			middleware = append(middleware, notify.Filter(policy.Event))
+		}
+		if where, _ := filter.Compile(w.Where, filter.KindEvent); where != nil { // Checked by config validation
+			middleware = append(middleware, notify.Filter(func(e *models.Event) (*models.Event, bool) {
+				return e, where.Match(filter.Event(e))
+			}))
+		}
+		if w.DedupeWindow > 0 {
+			middleware = append(middleware, notify.Dedupe(time.Duration(w.DedupeWindow)*time.Second))
+		}
+		if w.DigestInterval > 0 {
+			maxSeverity := w.DigestMaxSeverity
+			if maxSeverity == "" {
+				maxSeverity = models.SeverityInfo
+			}
+			middleware = append(middleware, notify.Digest(time.Duration(w.DigestInterval)*time.Second, maxSeverity))
+		}
+		if w.AggregateWindow > 0 {
+			middleware = append(middleware, notify.Aggregate(time.Duration(w.AggregateWindow)*time.Second))
+		}
+		middleware = append(middleware, notify.Links(generator))
+
+		targets = append(targets, &notify.Target{
+			Name:        w.Name,
+			Types:       types,
+			MinSeverity: w.MinSeverity,
+			Sender:      notify.Chain(webhook, middleware...),
+			Webhook:     webhook,
+		})
+	}
+
+	if email := cfg.Notify.Email; len(email.Types) > 0 {
+		types := make(map[string]bool)
+		for _, t := range email.Types {
+			types[t] = true
+		}
+		var middleware []notify.Middleware
+		if policy != nil {
+			middleware = append(middleware, notify.Filter(policy.Event))
+		}
+		middleware = append(middleware, notify.Links(generator))
+		targets = append(targets, &notify.Target{
+			Name:        "email",
+			Types:       types,
+			MinSeverity: email.MinSeverity,
+			Sender:      notify.Chain(newEmail(cfg), middleware...),
+		})
+	}
+	return targets
+}
+
+// newEmail creates the SMTP sender for email notifications and the weekly report
+func newEmail(cfg *config.Config) *notify.Email {
+	email := cfg.Notify.Email
+	return notify.NewEmail(notify.EmailOptions{
+		Addr:     email.Addr,
+		TLS:      email.TLS,
+		Username: email.Username,
+		Password: email.Password,
+		From:     email.From,
+		To:       email.To,
+	})
+}
+
+// sendWeeklyReport emails the overview of the seven days up to now
+func sendWeeklyReport(ctx context.Context, db *database.DB, email *notify.Email, station string) error {
+	o, err := report.NewOverview(report.Sources{
+		Flights:  db.FlightRepository(),
+		Receiver: db.ReceiverStatsRepository(),
+		Coverage: db.CoverageRepository(),
+	}, time.Now(), 7*24*time.Hour, time.Local, station)
+	if err != nil {
+		return err
+	}
+	html, err := o.HTML()
+	if err != nil {
+		return err
+	}
+	if err := email.SendMessage(ctx, notify.Message{Subject: o.Subject(), Text: o.Text(), HTML: html}); err != nil {
+		return err
+	}
+	slog.Info("Sent the weekly report", "subject", o.Subject())
+	return nil
+}
+
+// newTagLists converts configured special aircraft lists into sync lists
+func newTagLists(cfg *config.Config) []tasks.TagList {
+	lists := make([]tasks.TagList, 0, len(cfg.Tags.Lists))
+	for _, l := range cfg.Tags.Lists {
+		lists = append(lists, tasks.TagList{Name: l.Name, Location: l.URL})
+	}
+	return lists
+}
+
+// privacyOutput returns an output's view of the blocklist, nil when nothing is blocked
+func privacyOutput(blocklist *privacy.Blocklist, policy string) *privacy.Output {
+	if blocklist == nil {
+		return nil
+	}
+	return blocklist.Output(policy)
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"flight_trmnl/internal/dump1090"
+	"flight_trmnl/internal/models"
+	"flight_trmnl/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloseOnStop_ReplayFailsWhileOthersSend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recording.bin")
+	require.NoError(t, os.WriteFile(path, nil, 0o644))
+	recording, err := dump1090.NewPacedReplay(path, dump1090.FormatBeast, models.ClockFreeRunning, 0)
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(path)) // Gone by the time the replay opens it
+
+	ch := make(chan *models.BeastMessage, 10)
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		for range ch {
+		}
+	}()
+
+	sent, returned := make(chan struct{}, 1), make(chan struct{})
+	services := service.NewGroup(time.Second)
+	ctx := context.Background()
+	services.Start(ctx, service.New("stream", closeOnStop(ch), nil))
+	services.Start(ctx, service.New("beast", func(ctx context.Context) error {
+		return replayStream(recording)(ctx, ch)
+	}, nil))
+	services.Start(ctx, service.New("udp", func(ctx context.Context) error {
+		for ctx.Err() == nil {
+			ch <- &models.BeastMessage{}
+			select {
+			case sent <- struct{}{}:
+			default:
+			}
+		}
+		close(returned) // Not reached if a send panicked
+		return nil
+	}, nil))
+
+	require.Eventually(t, func() bool { return services.Health()[1].State == service.StateFailed }, time.Second, 5*time.Millisecond)
+	<-sent
+	<-sent // Still sending after the replay failed
+	select {
+	case <-received:
+		t.Fatal("channel closed while an input was still sending")
+	default:
+	}
+
+	services.Stop()
+	<-returned
+	<-received
+	assert.Equal(t, service.StateStopped, services.Health()[2].State)
+}
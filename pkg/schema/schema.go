@@ -0,0 +1,129 @@
+// Package schema documents the JSON payloads flight_trmnl sends to other programs
+//
+// Each output is versioned on its own with a schema_version field. Adding fields is backwards compatible
+// and keeps the version; removing, renaming, or changing the meaning of a field increments it, so consumers
+// can check the version and keep working while new decoded fields are added.
+package schema
+
+import "time"
+
+// Output schema versions
+const (
+	APIVersion     = 1 // JSON responses and server-sent events of the HTTP API
+	WebhookVersion = 1 // Notification webhook payloads
+	TRMNLVersion   = 1 // TRMNL merge variables
+	HubVersion     = 1 // Message batches forwarded from stations to a hub
+)
+
+// VersionHeader carries the API schema version on every API response, including event streams
+const VersionHeader = "X-Flight-Trmnl-Schema-Version"
+
+// Aircraft is the live state of one aircraft as heard by the receiver
+type Aircraft struct {
+	ICAO        string    `json:"icao"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	Messages    int64     `json:"messages"`
+	SignalLevel uint8     `json:"signal_level"`        // Beast signal byte of the most recent message, 0-255
+	SignalDBFS  float64   `json:"signal_dbfs"`         // The same in dBFS, 0 at full scale
+	MessageType string    `json:"message_type"`        // Type of the most recent message
+	Altitude    *int      `json:"altitude,omitempty"`  // Feet, the last reported while airborne
+	OnGround    bool      `json:"on_ground,omitempty"` // From surface positions and the transponder capability
+	// Source is how the latest extended squitter reached the receiver: adsb from the aircraft itself, tisb from a
+	// ground station's radar, or adsr rebroadcast by a ground station from another data link. Empty until one does.
+	Source string `json:"source,omitempty"`
+	// AddressType is icao for an allocated ICAO address, anonymous for a self-assigned or privacy address, or
+	// trackfile for a TIS-B ground station's number for a radar target; the latter two ICAO prefixed with ~
+	AddressType string `json:"address_type,omitempty"`
+	// AltitudeBand is surface, low (below 10,000 ft), mid (below 30,000 ft), or high; empty until known
+	AltitudeBand string `json:"altitude_band,omitempty"`
+	Speed        *int   `json:"speed,omitempty"` // Knots, ground speed or else airspeed, the last reported
+	// Latitude and Longitude are the last decoded ADS-B position in decimal degrees
+	Latitude  *float64 `json:"lat,omitempty"`
+	Longitude *float64 `json:"lon,omitempty"`
+	// NIC is the navigation integrity category of the last position, 0 (unknown) to 11 (within 7.5 m). NACp, the
+	// accuracy category, and SIL, the source integrity level, are from the last operational status.
+	NIC          *int     `json:"nic,omitempty"`
+	NACp         *int     `json:"nacp,omitempty"`
+	SIL          *int     `json:"sil,omitempty"`
+	Track        *float64 `json:"track,omitempty"`         // Degrees true over the ground, the last reported
+	Heading      *float64 `json:"heading,omitempty"`       // Degrees magnetic, from airspeed velocity messages
+	VerticalRate *int     `json:"vertical_rate,omitempty"` // Feet per minute, negative when descending
+	Callsign     string   `json:"callsign,omitempty"`      // From ADS-B identification
+	Category     string   `json:"category,omitempty"`      // ADS-B emitter category, e.g. A3 (large) or A7 (rotorcraft)
+	CategoryName string   `json:"category_name,omitempty"` // Description of the emitter category, e.g. Rotorcraft
+	Squawk       string   `json:"squawk,omitempty"`        // Mode A code from ADS-B aircraft status, e.g. 7700
+	// Emergency is the emergency or priority status the aircraft broadcasts, e.g. general or minimum_fuel; empty
+	// when none
+	Emergency string    `json:"emergency,omitempty"`
+	Advisory  *Advisory `json:"advisory,omitempty"` // TCAS resolution advisory, while the aircraft broadcasts one
+	// What the crew set the autopilot to, from ADS-B target state and status; each the last reported
+	SelectedAltitude *int     `json:"selected_altitude,omitempty"` // Feet, on the MCP/FCU or in the FMS
+	SelectedHeading  *float64 `json:"selected_heading,omitempty"`  // Degrees
+	BaroSetting      *float64 `json:"baro_setting,omitempty"`      // Millibars (hPa), the altimeter setting
+	// NavModes are the modes engaged: autopilot, vnav, althold, approach, lnav, and tcas while ACAS is operational
+	NavModes []string `json:"nav_modes,omitempty"`
+}
+
+// Advisory is a TCAS (ACAS) resolution advisory an aircraft broadcasts in ADS-B aircraft status messages
+type Advisory struct {
+	Time            time.Time `json:"time"`                       // When it was last broadcast
+	ARA             int       `json:"ara"`                        // Active resolution advisories, the raw 14 bits
+	RAC             int       `json:"rac"`                        // Resolution advisory complements, the raw 4 bits
+	Summary         string    `json:"summary"`                    // What the pilot is told, e.g. "Climb, corrective"
+	Terminated      bool      `json:"terminated,omitempty"`       // The advisory ended, it's broadcast a while longer
+	MultipleThreats bool      `json:"multiple_threats,omitempty"` // More than one intruder
+	ThreatICAO      string    `json:"threat_icao,omitempty"`      // The intruder, when the broadcast identifies it
+}
+
+// Event is something noteworthy the station observed
+type Event struct {
+	ID       int64          `json:"id"`
+	Time     time.Time      `json:"time"`
+	Type     string         `json:"type"`     // new_aircraft, alert, geofence, emergency, advisory, maintenance, record, or deep_dive
+	Severity string         `json:"severity"` // info, warning, or critical
+	ICAO     string         `json:"icao,omitempty"`
+	Callsign string         `json:"callsign,omitempty"`
+	Message  string         `json:"message"`        // Human readable summary
+	Data     map[string]any `json:"data,omitempty"` // Type specific details
+	// Links to live views of the aircraft by name (local, adsbexchange, ...), only in notifications
+	Links map[string]string `json:"links,omitempty"`
+}
+
+// AircraftList is the body of GET /api/aircraft
+type AircraftList struct {
+	SchemaVersion int        `json:"schema_version"`
+	Now           int64      `json:"now"` // Unix seconds
+	Aircraft      []Aircraft `json:"aircraft"`
+}
+
+// PlaybackFrame is the data of a "snapshot" event of GET /api/playback
+type PlaybackFrame struct {
+	SchemaVersion int        `json:"schema_version"`
+	Time          time.Time  `json:"time"`
+	Aircraft      []Aircraft `json:"aircraft"`
+}
+
+// WebhookPayload is the body posted to notification webhooks
+type WebhookPayload struct {
+	SchemaVersion int      `json:"schema_version"`
+	Delivery      string   `json:"delivery"` // Unique per payload and unchanged across retries
+	Events        []*Event `json:"events"`
+}
+
+// StationBatch is the body a station posts to a hub's /ingest endpoint (gzip-compressed)
+type StationBatch struct {
+	SchemaVersion int              `json:"schema_version"`
+	Station       string           `json:"station"` // Must match the name the hub's token is configured for
+	SentAt        time.Time        `json:"sent_at"` // Station's clock when the batch was sent
+	Messages      []StationMessage `json:"messages"`
+}
+
+// StationMessage is one Mode S or Mode A/C message as received by a station
+type StationMessage struct {
+	Time   time.Time `json:"t"`      // When the station received the message
+	Type   byte      `json:"type"`   // Beast message type: 0x31 Mode A/C, 0x32 Mode S short, 0x33 Mode S long
+	Ticks  uint64    `json:"ticks"`  // Receiver clock in 12 MHz ticks (Beast timestamp)
+	Signal uint8     `json:"signal"` // Beast signal level
+	Data   string    `json:"data"`   // Message bytes as hex
+}
@@ -0,0 +1,44 @@
+#!/usr/bin/env bash
+# Builds release binaries for every supported platform into dist/, with SHA256SUMS
+# and, when RELEASE_SIGNING_KEY names an ed25519 PEM private key, SHA256SUMS.sig.
+#
+# go-sqlite3 needs cgo, so cross builds use the Debian/Ubuntu cross compilers:
+#   apt-get install gcc-aarch64-linux-gnu gcc-arm-linux-gnueabihf
+#
+# Usage: scripts/build-release.sh v1.2.3
+set -euo pipefail
+
+version="${1:?usage: $0 <version>}"
+public_key="${RELEASE_PUBLIC_KEY:-}" # base64 raw ed25519 public key built into the binaries
+dist=dist
+
+rm -rf "$dist"
+mkdir -p "$dist"
+
+ldflags="-s -w -X main.version=${version}"
+if [[ -n "$public_key" ]]; then
+  ldflags="$ldflags -X flight_trmnl/internal/update.PublicKey=${public_key}"
+fi
+
+# name goarch goarm cc
+targets=(
+  "amd64 amd64 '' gcc"
+  "arm64 arm64 '' aarch64-linux-gnu-gcc"
+  "armv7 arm 7 arm-linux-gnueabihf-gcc"
+)
+
+for target in "${targets[@]}"; do
+  eval "set -- $target"
+  name=$1 goarch=$2 goarm=$3 cc=$4
+  echo "building linux/${name}"
+  CGO_ENABLED=1 GOOS=linux GOARCH="$goarch" GOARM="$goarm" CC="$cc" \
+    go build -trimpath -ldflags "$ldflags" -o "${dist}/flight_trmnl_linux_${name}" .
+done
+
+(cd "$dist" && sha256sum flight_trmnl_* > SHA256SUMS)
+
+if [[ -n "${RELEASE_SIGNING_KEY:-}" ]]; then
+  openssl pkeyutl -sign -rawin -inkey "$RELEASE_SIGNING_KEY" -in "${dist}/SHA256SUMS" -out "${dist}/SHA256SUMS.sig"
+fi
+
+ls -l "$dist"
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"flight_trmnl/internal/config"
+	"flight_trmnl/internal/discovery"
+)
+
+// configExample is the commented example config, used as the starting point for init
+//
+//go:embed config.yaml.example
+var configExample string
+
+// runInit walks through first-run setup and writes a commented starter config.
+// It runs before any config is loaded, since there usually isn't one yet.
+func runInit(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	output := fs.String("o", "config.yaml", "config file to write")
+	force := fs.Bool("force", false, "overwrite an existing config file")
+	scan := fs.Bool("scan", true, "search the local network for receivers")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if _, err := os.Stat(*output); err == nil && !*force {
+		return fmt.Errorf("%s already exists, use -force to overwrite it", *output)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	p := &prompter{in: bufio.NewReader(os.Stdin), out: os.Stdout}
+	var s config.Starter
+	var err error
+
+	fmt.Println("== Receiver")
+	var receivers []discovery.Receiver
+	if *scan {
+		fmt.Println("Searching this machine and the local network for dump1090/readsb...")
+		found, err := discovery.Scan(ctx, discovery.Options{})
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+		receivers = found
+	}
+	if s.BeastAddr, err = askReceiver(ctx, p, receivers); err != nil {
+		return err
+	}
+
+	fmt.Println("\n== Location")
+	if s.Location, err = askLocation(p); err != nil {
+		return err
+	}
+
+	fmt.Println("\n== TRMNL display")
+	if s.TRMNLWebhookURL, err = askWebhook(ctx, p); err != nil {
+		return err
+	}
+
+	fmt.Println("\n== Retention")
+	if s.StorageMode, s.SnapshotRetention, err = askRetention(p); err != nil {
+		return err
+	}
+
+	cfg, err := config.RenderStarter(configExample, s)
+	if err != nil {
+		return err
+	}
+	// The file may hold the webhook URL, which is a credential
+	if err := os.WriteFile(*output, []byte(cfg), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", *output, err)
+	}
+	fmt.Printf("\nWrote %s, start collecting with: flight_trmnl -config %s\n", *output, *output)
+	return nil
+}
+
+// askReceiver lists the discovered receivers and reads the user's pick, a number or a host:port.
+// A receiver that doesn't accept connections is only kept when the user confirms it.
+func askReceiver(ctx context.Context, p *prompter, receivers []discovery.Receiver) (string, error) {
+	def := "localhost:30005"
+	if len(receivers) == 0 {
+		fmt.Println("No receivers found.")
+	} else {
+		def = receivers[0].Addr
+		fmt.Println("Found:")
+		for i, r := range receivers {
+			status := "streaming Beast"
+			if !r.Verified {
+				status = "port open, no data yet"
+			}
+			fmt.Printf("  %d) %-28s %-6s %s\n", i+1, r.Addr, r.Source, status)
+		}
+	}
+
+	for {
+		answer, err := p.ask("Receiver (number or host:port)", def)
+		if err != nil {
+			return "", err
+		}
+		addr := answer
+		if n, convErr := strconv.Atoi(answer); convErr == nil {
+			if n < 1 || n > len(receivers) {
+				fmt.Println("Enter a number from the list or an address like piaware.local:30005")
+				continue
+			}
+			addr = receivers[n-1].Addr
+		} else if _, _, err := net.SplitHostPort(answer); err != nil {
+			fmt.Println("Enter a number from the list or an address like piaware.local:30005")
+			continue
+		}
+
+		open, beast := discovery.Probe(ctx, addr, 3*time.Second)
+		switch {
+		case beast:
+			fmt.Printf("Connected to %s, receiving Beast messages.\n", addr)
+			return addr, nil
+		case open:
+			fmt.Printf("Connected to %s, no messages yet (normal with no aircraft in range).\n", addr)
+			return addr, nil
+		}
+		keep, err := p.confirm(fmt.Sprintf("Can't connect to %s. Use it anyway?", addr), false)
+		if err != nil || keep {
+			return addr, err
+		}
+	}
+}
+
+// askLocation reads the receiver's position; an empty answer leaves it unset
+func askLocation(p *prompter) (config.LocationConfig, error) {
+	fmt.Println("Where the antenna is, in decimal degrees (e.g. 51.4700, -0.4543). Leave empty to skip.")
+	for {
+		answer, err := p.ask("Latitude, longitude", "")
+		if err != nil || answer == "" {
+			return config.LocationConfig{}, err
+		}
+		lat, lon, err := parseCoordinates(answer)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		name, err := p.ask("Location name", "Home")
+		return config.LocationConfig{Name: name, Latitude: lat, Longitude: lon}, err
+	}
+}
+
+// parseCoordinates parses "lat, lon" or "lat lon"
+func parseCoordinates(s string) (lat, lon float64, err error) {
+	parts := strings.Fields(strings.ReplaceAll(s, ",", " "))
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("enter the latitude and longitude separated by a comma")
+	}
+	if lat, err = strconv.ParseFloat(parts[0], 64); err != nil || lat < -90 || lat > 90 {
+		return 0, 0, fmt.Errorf("latitude must be a number between -90 and 90")
+	}
+	if lon, err = strconv.ParseFloat(parts[1], 64); err != nil || lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("longitude must be a number between -180 and 180")
+	}
+	if lat == 0 && lon == 0 {
+		return 0, 0, fmt.Errorf("0, 0 is used for no location, leave it empty instead")
+	}
+	return lat, lon, nil
+}
+
+// askWebhook reads a TRMNL private plugin webhook URL and checks its host is reachable.
+// Nothing is posted, since a test push would replace what the display shows.
+func askWebhook(ctx context.Context, p *prompter) (string, error) {
+	fmt.Println("The webhook URL of a TRMNL private plugin (Plugins > Private Plugin > Webhook URL). Leave empty to skip.")
+	for {
+		answer, err := p.ask("Webhook URL", "")
+		if err != nil || answer == "" {
+			return "", err
+		}
+		u, err := url.Parse(answer)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			fmt.Println("Enter a URL like https://usetrmnl.com/api/custom_plugins/<plugin-uuid>")
+			continue
+		}
+
+		port := u.Port()
+		if port == "" {
+			port = map[string]string{"https": "443", "http": "80"}[u.Scheme]
+		}
+		dialer := net.Dialer{Timeout: 5 * time.Second}
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+		if err == nil {
+			conn.Close()
+			fmt.Printf("%s is reachable, the display will update within 15 minutes of starting.\n", u.Hostname())
+			return answer, nil
+		}
+		keep, err := p.confirm(fmt.Sprintf("Can't reach %s. Use it anyway?", u.Hostname()), false)
+		if err != nil || keep {
+			return answer, err
+		}
+	}
+}
+
+// askRetention reads how much of each message to store and how long to keep playback snapshots
+func askRetention(p *prompter) (storageMode string, snapshotDays int, err error) {
+	fmt.Println("How much of each received message to store:")
+	fmt.Println("  raw     - every message with its raw bytes and decoded fields (largest)")
+	fmt.Println("  decoded - decoded fields of messages from identified aircraft, no raw bytes")
+	fmt.Println("  state   - only the seen aircraft summary, orders of magnitude smaller")
+	for {
+		if storageMode, err = p.ask("Storage mode", "raw"); err != nil {
+			return "", 0, err
+		}
+		if storageMode == "raw" || storageMode == "decoded" || storageMode == "state" {
+			break
+		}
+		fmt.Println("Enter raw, decoded, or state")
+	}
+
+	for {
+		answer, err := p.ask("Days to keep playback snapshots (0 keeps them forever)", "7")
+		if err != nil {
+			return "", 0, err
+		}
+		if snapshotDays, err = strconv.Atoi(answer); err == nil && snapshotDays >= 0 {
+			return storageMode, snapshotDays, nil
+		}
+		fmt.Println("Enter a number of days")
+	}
+}
+
+// prompter asks questions on a terminal. At the end of input every question takes its default
+// and confirmations are accepted, so init can also run unattended with its input redirected from /dev/null.
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+	eof bool
+}
+
+// ask prints a question with its default and returns the trimmed answer, or the default when empty
+func (p *prompter) ask(question, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+	line, err := p.in.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	if err == io.EOF {
+		p.eof = true
+		fmt.Fprintln(p.out) // Keep the next output off the prompt line
+	}
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer, nil
+	}
+	return def, nil
+}
+
+// confirm asks a yes/no question
+func (p *prompter) confirm(question string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	answer, err := p.ask(question+" ("+hint+")", "")
+	if err != nil {
+		return false, err
+	}
+	if p.eof {
+		return true, nil // Nobody is there to answer, keep what was given rather than asking again
+	}
+	if answer == "" {
+		return def, nil
+	}
+	return strings.HasPrefix(strings.ToLower(answer), "y"), nil
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"flight_trmnl/internal/config"
+	"flight_trmnl/internal/database"
+)
+
+// readOnlyKeywords are the statements the read-only shell runs; PRAGMA only when it takes no argument
+var readOnlyKeywords = map[string]bool{"SELECT": true, "WITH": true, "EXPLAIN": true, "VALUES": true, "PRAGMA": true}
+
+// pragmaRead matches a PRAGMA that only reads a setting, such as "PRAGMA main.journal_mode"
+var pragmaRead = regexp.MustCompile(`(?i)^PRAGMA\s+(\w+\s*\.\s*)?\w+\s*;?$`)
+
+// runDB runs database tools
+// Usage: db shell [-readonly=false] [-c "SQL"] [-max-rows 500]
+func runDB(cfg *config.Config, db *database.DB, args []string) error {
+	if len(args) == 0 || args[0] != "shell" {
+		return fmt.Errorf("usage: db shell [-readonly=false] [-c \"SQL\"] [-max-rows 500]")
+	}
+
+	fs := flag.NewFlagSet("db shell", flag.ContinueOnError)
+	readOnly := fs.Bool("readonly", true, "block statements that change the database")
+	command := fs.String("c", "", "run these statements and exit instead of prompting")
+	maxRows := fs.Int("max-rows", 500, "rows to print per query, 0 for all")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	conn := db.DB()
+	if *readOnly {
+		ro, err := database.OpenReadOnly(cfg.DBPath)
+		if err != nil {
+			return err
+		}
+		defer ro.Close()
+		conn = ro
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	sh := &sqlShell{db: conn, readOnly: *readOnly, maxRows: *maxRows, out: os.Stdout}
+	if *command != "" {
+		return sh.run(ctx, strings.NewReader(*command), false)
+	}
+
+	interactive := false
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		interactive = true
+		mode := "read-only, start with -readonly=false to make changes"
+		if !*readOnly {
+			mode = "WRITABLE, statements change the live database"
+		}
+		fmt.Printf("%s (%s). End statements with ; and type .help for commands.\n", cfg.DBPath, mode)
+	}
+	return sh.run(ctx, os.Stdin, interactive)
+}
+
+// sqlShell reads SQL statements and prints their results as aligned columns
+type sqlShell struct {
+	db       *sql.DB
+	readOnly bool
+	maxRows  int
+	out      io.Writer
+}
+
+// run executes the statements in r until it ends, .quit, or the context is cancelled.
+// Interactive shells prompt, and carry on after a failed statement instead of stopping.
+func (s *sqlShell) run(ctx context.Context, r io.Reader, interactive bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var pending strings.Builder
+	prompt := func() {
+		if !interactive {
+			return
+		}
+		if pending.Len() == 0 {
+			fmt.Fprint(s.out, "sql> ")
+		} else {
+			fmt.Fprint(s.out, "...> ")
+		}
+	}
+
+	for prompt(); scanner.Scan(); prompt() {
+		line := strings.TrimSpace(scanner.Text())
+		if pending.Len() == 0 && strings.HasPrefix(line, ".") {
+			if line == ".quit" || line == ".exit" {
+				return nil
+			}
+			if err := s.dotCommand(ctx, line); err != nil {
+				if !interactive {
+					return err
+				}
+				fmt.Fprintln(s.out, "Error:", err)
+			}
+			continue
+		}
+
+		pending.WriteString(scanner.Text())
+		pending.WriteString("\n")
+		if !strings.HasSuffix(line, ";") {
+			continue
+		}
+		statement := strings.TrimSpace(pending.String())
+		pending.Reset()
+		if err := s.exec(ctx, statement); err != nil {
+			if !interactive || ctx.Err() != nil {
+				return err
+			}
+			fmt.Fprintln(s.out, "Error:", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read statements: %w", err)
+	}
+	if statement := strings.TrimSpace(pending.String()); statement != "" {
+		return s.exec(ctx, statement) // The last statement may leave out its ;
+	}
+	return nil
+}
+
+func (s *sqlShell) dotCommand(ctx context.Context, line string) error {
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case ".tables":
+		return s.exec(ctx, "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	case ".schema":
+		if len(fields) > 1 {
+			return s.query(ctx, "SELECT sql FROM sqlite_master WHERE name = ? AND sql IS NOT NULL", fields[1])
+		}
+		return s.exec(ctx, "SELECT sql FROM sqlite_master WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%' ORDER BY tbl_name, type DESC")
+	case ".help":
+		fmt.Fprintln(s.out, ".tables          list the tables")
+		fmt.Fprintln(s.out, ".schema [name]   show the CREATE statements of all tables, or of one table or index")
+		fmt.Fprintln(s.out, ".quit            leave the shell")
+		return nil
+	default:
+		return fmt.Errorf("unknown command %s, try .help", fields[0])
+	}
+}
+
+// exec runs one statement, printing the rows of queries and the rows changed by anything else
+func (s *sqlShell) exec(ctx context.Context, statement string) error {
+	if s.readOnly {
+		if err := checkReadOnly(statement); err != nil {
+			return err
+		}
+	}
+	if returnsRows(statement) {
+		return s.query(ctx, statement)
+	}
+
+	start := time.Now()
+	result, err := s.db.ExecContext(ctx, statement)
+	if err != nil {
+		return err
+	}
+	changed, _ := result.RowsAffected()
+	fmt.Fprintf(s.out, "%d rows changed (%s)\n", changed, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+func (s *sqlShell) query(ctx context.Context, statement string, args ...any) error {
+	start := time.Now()
+	rows, err := s.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	// Rows are written once the query has succeeded, a write caught by the read-only connection fails while stepping
+	var table bytes.Buffer
+	tw := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(columns, "\t"))
+
+	values := make([]any, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	count := 0
+	for rows.Next() {
+		count++
+		if s.maxRows > 0 && count > s.maxRows {
+			continue // Keep counting, so the total is known
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return err
+		}
+		cells := make([]string, len(values))
+		for i, v := range values {
+			cells[i] = formatCell(v)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	tw.Flush()
+	table.WriteTo(s.out)
+
+	if s.maxRows > 0 && count > s.maxRows {
+		fmt.Fprintf(s.out, "%d rows, first %d shown (%s)\n", count, s.maxRows, time.Since(start).Round(time.Millisecond))
+	} else {
+		fmt.Fprintf(s.out, "%d rows (%s)\n", count, time.Since(start).Round(time.Millisecond))
+	}
+	return nil
+}
+
+func formatCell(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return "x'" + hex.EncodeToString(v) + "'"
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case string:
+		// Keep multi-line values such as CREATE statements on their row
+		return strings.Join(strings.Fields(v), " ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// checkReadOnly rejects statements other than queries, and any PRAGMA given an argument, whether
+// with = or in parentheses. The connection is opened read-only too, so SQLite catches the rest,
+// such as a WITH clause in front of a DELETE.
+func checkReadOnly(statement string) error {
+	keyword := firstKeyword(statement)
+	if !readOnlyKeywords[keyword] {
+		return fmt.Errorf("%s is blocked in read-only mode, start the shell with -readonly=false to make changes", keyword)
+	}
+	if keyword == "PRAGMA" && !pragmaRead.MatchString(skipComments(statement)) {
+		return fmt.Errorf("PRAGMA with an argument is blocked in read-only mode, query the pragma_ table functions instead")
+	}
+	if strings.Count(strings.TrimRight(strings.TrimSpace(statement), ";"), ";") > 0 {
+		return fmt.Errorf("run one statement at a time in read-only mode")
+	}
+	return nil
+}
+
+// returnsRows reports whether a statement is a query whose rows should be printed
+func returnsRows(statement string) bool {
+	return readOnlyKeywords[firstKeyword(statement)]
+}
+
+// firstKeyword returns the statement's first word in upper case, skipping leading comments
+func firstKeyword(statement string) string {
+	s := skipComments(statement)
+	end := strings.IndexFunc(s, func(r rune) bool { return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') })
+	if end < 0 {
+		end = len(s)
+	}
+	return strings.ToUpper(s[:end])
+}
+
+// skipComments trims the statement and drops its leading comments, or returns "" if they don't end
+func skipComments(statement string) string {
+	s := strings.TrimSpace(statement)
+	for {
+		switch {
+		case strings.HasPrefix(s, "--"):
+			if i := strings.Index(s, "\n"); i >= 0 {
+				s = strings.TrimSpace(s[i+1:])
+				continue
+			}
+			return ""
+		case strings.HasPrefix(s, "/*"):
+			if i := strings.Index(s, "*/"); i >= 0 {
+				s = strings.TrimSpace(s[i+2:])
+				continue
+			}
+			return ""
+		}
+		return s
+	}
+}
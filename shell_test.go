@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"flight_trmnl/internal/database"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestShell opens a read-only shell on a new database that the returned connection can still write to
+func newTestShell(t *testing.T) (*sqlShell, *bytes.Buffer, *database.DB) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := database.New(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	_, err = db.DB().Exec("INSERT INTO beast_messages (timestamp, message_hex, message_type, icao) VALUES (CURRENT_TIMESTAMP, '8D4840D6', 'DF17', '4840D6')")
+	require.NoError(t, err)
+
+	ro, err := database.OpenReadOnly(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { ro.Close() })
+	var out bytes.Buffer
+	return &sqlShell{db: ro, readOnly: true, maxRows: 500, out: &out}, &out, db
+}
+
+func countMessages(t *testing.T, db *database.DB) int {
+	t.Helper()
+	var n int
+	require.NoError(t, db.DB().QueryRow("SELECT COUNT(*) FROM beast_messages").Scan(&n))
+	return n
+}
+
+func TestCheckReadOnly(t *testing.T) {
+	allowed := []string{
+		"SELECT * FROM beast_messages;",
+		"  -- busiest\n/* today */ select icao from beast_messages",
+		"WITH x AS (SELECT 1) SELECT * FROM x",
+		"EXPLAIN QUERY PLAN SELECT 1",
+		"VALUES (1)",
+		"PRAGMA journal_mode;",
+		"pragma main.user_version",
+	}
+	for _, statement := range allowed {
+		assert.NoError(t, checkReadOnly(statement), statement)
+	}
+
+	blocked := []string{
+		"DELETE FROM beast_messages",
+		"/* hidden */ DROP TABLE beast_messages",
+		"PRAGMA query_only=OFF",
+		"PRAGMA query_only = 0",
+		"PRAGMA query_only(0)",
+		"PRAGMA main.query_only (false)",
+		"PRAGMA table_info(beast_messages)",
+		"PRAGMA /* x */ query_only(0)",
+		"SELECT 1; DELETE FROM beast_messages",
+		"ATTACH DATABASE '/tmp/x.db' AS x",
+	}
+	for _, statement := range blocked {
+		assert.Error(t, checkReadOnly(statement), statement)
+	}
+}
+
+func TestFirstKeyword(t *testing.T) {
+	assert.Equal(t, "SELECT", firstKeyword("select 1"))
+	assert.Equal(t, "WITH", firstKeyword("-- note\n  /* more */ with x as (select 1) select * from x"))
+	assert.Equal(t, "PRAGMA", firstKeyword("PRAGMA query_only(0)"))
+	assert.Equal(t, "", firstKeyword("-- only a comment"))
+	assert.Equal(t, "", firstKeyword("/* unterminated"))
+}
+
+func TestSQLShell_Query(t *testing.T) {
+	sh, out, _ := newTestShell(t)
+	require.NoError(t, sh.exec(context.Background(), "SELECT icao, message_type FROM beast_messages"))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, "icao    message_type", lines[0])
+	assert.Equal(t, "4840D6  DF17", lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], "1 rows ("))
+}
+
+func TestSQLShell_MaxRows(t *testing.T) {
+	sh, out, _ := newTestShell(t)
+	sh.maxRows = 2
+	require.NoError(t, sh.exec(context.Background(), "WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i+1 FROM n WHERE i < 5) SELECT i FROM n"))
+	assert.Contains(t, out.String(), "5 rows, first 2 shown")
+	assert.NotContains(t, out.String(), "\n3\n")
+}
+
+func TestSQLShell_ReadOnly(t *testing.T) {
+	sh, _, db := newTestShell(t)
+	ctx := context.Background()
+
+	assert.ErrorContains(t, sh.exec(ctx, "DELETE FROM beast_messages"), "blocked in read-only mode")
+	assert.ErrorContains(t, sh.exec(ctx, "PRAGMA query_only(0)"), "blocked in read-only mode")
+
+	// Past the statement check, SQLite itself refuses the write, on every new connection too
+	sh.readOnly = false
+	sh.db.SetMaxIdleConns(0)
+	require.NoError(t, sh.exec(ctx, "PRAGMA query_only(0)"))
+	assert.ErrorContains(t, sh.exec(ctx, "WITH x AS (SELECT 1) DELETE FROM beast_messages"), "readonly database")
+	assert.ErrorContains(t, sh.exec(ctx, "INSERT INTO beast_messages (timestamp, message_hex, message_type, icao) VALUES (CURRENT_TIMESTAMP, '00', 'DF0', '000000')"), "readonly database")
+	assert.Equal(t, 1, countMessages(t, db))
+}
+
+func TestSQLShell_ReadOnlyClosedDatabase(t *testing.T) {
+	sh, out, db := newTestShell(t)
+	require.NoError(t, db.Close()) // The daemon isn't running, and its WAL files are gone
+	sh.db.SetMaxIdleConns(0)
+	require.NoError(t, sh.exec(context.Background(), "SELECT COUNT(*) FROM beast_messages"))
+	assert.Contains(t, out.String(), "1 rows")
+}
+
+func TestSQLShell_Run(t *testing.T) {
+	sh, out, _ := newTestShell(t)
+	script := "SELECT icao\nFROM beast_messages;\n.tables\n.schema beast_messages\n.quit\nSELECT 'not reached';\n"
+	require.NoError(t, sh.run(context.Background(), strings.NewReader(script), false))
+	assert.Contains(t, out.String(), "4840D6")
+	assert.Contains(t, out.String(), "seen_aircraft")
+	assert.Contains(t, out.String(), "CREATE TABLE beast_messages")
+	assert.NotContains(t, out.String(), "not reached")
+}
+
+func TestSQLShell_RunStopsOnError(t *testing.T) {
+	sh, out, _ := newTestShell(t)
+	err := sh.run(context.Background(), strings.NewReader("DELETE FROM beast_messages;\nSELECT 'after';\n"), false)
+	assert.ErrorContains(t, err, "DELETE is blocked")
+	assert.NotContains(t, out.String(), "after")
+
+	out.Reset()
+	require.NoError(t, sh.run(context.Background(), strings.NewReader("DELETE FROM beast_messages;\nSELECT 'after'\n"), true))
+	assert.Contains(t, out.String(), "Error: DELETE is blocked")
+	assert.Contains(t, out.String(), "after", "interactive shells carry on, and the last statement may leave out its ;")
+}
+
+func TestSQLShell_UnknownDotCommand(t *testing.T) {
+	sh, _, _ := newTestShell(t)
+	assert.ErrorContains(t, sh.dotCommand(context.Background(), ".drop"), "unknown command .drop")
+}
+
+func TestFormatCell(t *testing.T) {
+	assert.Equal(t, "NULL", formatCell(nil))
+	assert.Equal(t, "x'8d48'", formatCell([]byte{0x8d, 0x48}))
+	assert.Equal(t, "CREATE TABLE t ( id INTEGER )", formatCell("CREATE TABLE t (\n  id INTEGER\n)"))
+	assert.Equal(t, "42", formatCell(int64(42)))
+}
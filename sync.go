@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"flight_trmnl/internal/config"
+	"flight_trmnl/internal/database"
+	"flight_trmnl/internal/dbsync"
+)
+
+// runSync copies the rows added since the last sync to the remote database now, with the sync settings as defaults
+// Usage: sync [-to ssh://host/path] [-limit KB/s] [-tables beast_messages,events]
+func runSync(cfg *config.Config, db *database.DB, args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ContinueOnError)
+	to := fs.String("to", cfg.Sync.To, "ssh://[user@]host[:port]/path of the remote database, or a local path")
+	limit := fs.Int("limit", cfg.Sync.RateLimit, "kilobytes per second sent at most, 0 is unlimited")
+	tables := fs.String("tables", strings.Join(cfg.Sync.Tables, ","), "tables to copy, all when empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("usage: sync [-to ssh://host/path] [-limit KB/s] [-tables beast_messages,events]")
+	}
+	if err := dbsync.ParseDestination(*to); err != nil {
+		return err
+	}
+	if *to == cfg.DBPath {
+		return fmt.Errorf("-to must be another database than db_path")
+	}
+	var only []string
+	if *tables != "" {
+		only = strings.Split(*tables, ",")
+		for _, table := range only {
+			if err := database.CheckSyncTable(table); err != nil {
+				return err
+			}
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client := dbsync.NewClient(db.SyncRepository(), dbsync.Options{
+		To:            *to,
+		Source:        cfg.Sync.Source,
+		Tables:        only,
+		RateLimit:     *limit * 1024,
+		RemoteCommand: cfg.Sync.RemoteCommand,
+	})
+	copied, err := client.Sync(ctx)
+	for _, table := range database.SyncTables {
+		if copied[table] > 0 {
+			fmt.Printf("%-22s %d rows\n", table, copied[table])
+		}
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Up to date with %s\n", *to)
+	return nil
+}
+
+// runSyncServe is the remote end of a sync: it adds the rows a sync sends on stdin to the database at path and
+// answers on stdout
+// Usage: sync serve <path>
+func runSyncServe(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: sync serve <path>")
+	}
+	db, err := database.New(args[0])
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return dbsync.Serve(db.SyncRepository(), os.Stdin, os.Stdout)
+}